@@ -0,0 +1,354 @@
+// Command eobs is the command-line client of a running ElasticObservability instance, for
+// on-call engineers working in a terminal:
+//
+//	eobs clusters list
+//	eobs jobs trigger runCatIndices --wait
+//	eobs pressure list --cluster prod-01
+//	eobs onboard --cluster prod-02 --hosts es-01,es-02,es-03
+//
+// The instance and token are taken from -server and -token, or EOBS_SERVER and EOBS_TOKEN.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"ElasticObservability/pkg/client"
+)
+
+const usage = `Usage: eobs [-server URL] [-token TOKEN] [-json] <command>
+
+Commands:
+  status                               Application status
+  clusters list                        Clusters visible to the token
+  jobs list                            Jobs with their last run and counts
+  jobs trigger <job> [--wait] [--timeout 30m]
+                                       Run a job now; --wait waits for it to finish
+  pressure list [--cluster X] [--state firing|resolved|all]
+                                       Write pressure events, firing by default
+  onboard --cluster X --hosts a,b,c [--owner X] [--env X] [--port 9200]
+                                       Generate the CSV rows and job changes of a new cluster
+
+Environment:
+  EOBS_SERVER   Instance URL (default http://localhost:9092)
+  EOBS_TOKEN    API token
+`
+
+// errUsage is returned for invalid command lines; main prints the usage for it
+var errUsage = errors.New("invalid command")
+
+// cli carries the client and output format shared by the commands
+type cli struct {
+	client *client.Client
+	json   bool
+}
+
+func main() {
+	global := flag.NewFlagSet("eobs", flag.ContinueOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	server := global.String("server", envOr("EOBS_SERVER", "http://localhost:9092"), "Instance URL")
+	token := global.String("token", os.Getenv("EOBS_TOKEN"), "API token")
+	asJSON := global.Bool("json", false, "Print JSON instead of tables")
+	if err := global.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &cli{client: client.New(*server, *token), json: *asJSON}
+	if err := c.run(ctx, global.Args()); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "eobs: %v\n\n%s", err, usage)
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "eobs: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run dispatches a command line
+func (c *cli) run(ctx context.Context, args []string) error {
+	command := strings.Join(firstN(args, 2), " ")
+	switch {
+	case len(args) > 0 && args[0] == "status":
+		return c.status(ctx)
+	case command == "clusters list":
+		return c.listClusters(ctx)
+	case command == "jobs list":
+		return c.listJobs(ctx)
+	case command == "jobs trigger":
+		return c.triggerJob(ctx, args[2:])
+	case command == "pressure list":
+		return c.listPressure(ctx, args[2:])
+	case len(args) > 0 && args[0] == "onboard":
+		return c.onboard(ctx, args[1:])
+	case len(args) == 0:
+		return fmt.Errorf("%w: no command given", errUsage)
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, strings.Join(args, " "))
+	}
+}
+
+func (c *cli) status(ctx context.Context) error {
+	status, err := c.client.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(status)
+	}
+	fmt.Printf("%s: %d clusters, %d indexing rates tracked\n", status.Status, status.Clusters, status.RatesTracked)
+	return nil
+}
+
+func (c *cli) listClusters(ctx context.Context) error {
+	clusters, err := c.client.Clusters(ctx)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(clusters)
+	}
+	sort.Strings(clusters)
+	for _, cluster := range clusters {
+		fmt.Println(cluster)
+	}
+	return nil
+}
+
+func (c *cli) listJobs(ctx context.Context) error {
+	jobs, err := c.client.Jobs(ctx)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(jobs)
+	}
+
+	names := make([]string, 0, len(jobs))
+	for name := range jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB\tRUNNING\tLAST RUN\tNEXT RUN\tRUNS\tERRORS")
+	for _, name := range names {
+		job := jobs[name]
+		fmt.Fprintf(tw, "%s\t%t\t%s\t%s\t%d\t%d\n", name, job.Running,
+			formatTime(job.LastRun), formatTime(job.NextRun), job.RunCount, job.ErrorCount)
+	}
+	return tw.Flush()
+}
+
+// triggerJob runs a job now. With --wait it polls the job status until the run count grows
+// and the job is no longer running, and fails if the error count grew meanwhile.
+func (c *cli) triggerJob(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("jobs trigger", flag.ContinueOnError)
+	wait := fs.Bool("wait", false, "Wait for the run to finish")
+	timeout := fs.Duration("timeout", 30*time.Minute, "How long to wait with --wait")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: jobs trigger takes one job name", errUsage)
+	}
+	jobName := positional[0]
+
+	var before client.JobStatus
+	if *wait {
+		jobs, err := c.client.Jobs(ctx)
+		if err != nil {
+			return err
+		}
+		before = jobs[jobName]
+	}
+
+	if err := c.client.TriggerJob(ctx, jobName); err != nil {
+		return err
+	}
+	if !*wait {
+		fmt.Printf("Job %s triggered\n", jobName)
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Job %s triggered, waiting for it to finish...\n", jobName)
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	started := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("job %s did not finish within %s", jobName, *timeout)
+		case <-ticker.C:
+		}
+
+		jobs, err := c.client.Jobs(ctx)
+		if err != nil {
+			return err
+		}
+		job, ok := jobs[jobName]
+		if !ok || job.Running || job.RunCount <= before.RunCount {
+			continue
+		}
+		if job.ErrorCount > before.ErrorCount {
+			return fmt.Errorf("job %s failed after %s, see the job log", jobName, time.Since(started).Round(time.Second))
+		}
+		fmt.Printf("Job %s completed in %s\n", jobName, time.Since(started).Round(time.Second))
+		return nil
+	}
+}
+
+// listPressure lists the write pressure events of the event store
+func (c *cli) listPressure(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("pressure list", flag.ContinueOnError)
+	cluster := fs.String("cluster", "", "Only this cluster")
+	state := fs.String("state", "firing", "firing, resolved or all")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, positional[0])
+	}
+
+	filter := client.EventFilter{Source: "writePressure", Cluster: *cluster}
+	switch *state {
+	case "firing", "resolved":
+		filter.State = *state
+	case "all":
+	default:
+		return fmt.Errorf("%w: --state must be firing, resolved or all", errUsage)
+	}
+
+	list, err := c.client.Events(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(list.Events)
+	}
+	if len(list.Events) == 0 {
+		fmt.Println("No write pressure events")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tHOST\tSEVERITY\tSTATE\tSINCE\tDURATION\tTOP CONTRIBUTORS")
+	for _, event := range list.Events {
+		state := event.State
+		if event.Suppressed {
+			state += " (maintenance)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", event.Labels["cluster"], event.Labels["host"], event.Severity, state,
+			formatTime(time.UnixMilli(event.StartsAt)), (time.Duration(event.DurationMs) * time.Millisecond).Round(time.Second),
+			event.Annotations["topContributors"])
+	}
+	return tw.Flush()
+}
+
+// onboard prints the configuration of a new cluster generated by the instance: the rows to
+// append to the master and credentials CSVs and the job parameters to change
+func (c *cli) onboard(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("onboard", flag.ContinueOnError)
+	req := client.OnboardingRequest{}
+	fs.StringVar(&req.Cluster, "cluster", "", "Cluster name")
+	hosts := fs.String("hosts", "", "Comma-separated host names or IPs of the nodes")
+	fs.StringVar(&req.Owner, "owner", "", "Owning team")
+	fs.StringVar(&req.Environment, "env", "", "Environment (default production)")
+	fs.StringVar(&req.Port, "port", "", "Elasticsearch port (default 9200)")
+	fs.StringVar(&req.NodeType, "node-type", "", `Roles of the nodes (default "master data")`)
+	fs.StringVar(&req.Zone, "zone", "", "Zone")
+	fs.StringVar(&req.DataCenter, "dc", "", "Data center")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, positional[0])
+	}
+	if req.Cluster == "" || *hosts == "" {
+		return fmt.Errorf("%w: onboard needs --cluster and --hosts", errUsage)
+	}
+	req.Hosts = strings.Split(*hosts, ",")
+
+	onboarding, err := c.client.Onboard(ctx, req)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(onboarding)
+	}
+
+	for _, warning := range onboarding.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	fmt.Printf("# Master CSV: append the rows below the header\n%s\n", onboarding.ClustersCSV)
+	fmt.Printf("# Credentials CSV: append the row and fill in the placeholders\n%s\n", onboarding.CredentialsCSV)
+	if len(onboarding.JobEntries) == 0 {
+		fmt.Println("# Jobs: no job parameter needs a change")
+		return nil
+	}
+	fmt.Println("# Jobs: change these parameters")
+	for _, entry := range onboarding.JobEntries {
+		fmt.Printf("%s: %s: [%s]  # %s\n", entry.Job, entry.Parameter, strings.Join(entry.Value, ", "), entry.Reason)
+	}
+	return nil
+}
+
+// parseInterspersed parses flags given before, between or after the positional arguments,
+// e.g. "runCatIndices --wait", and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(os.Stderr)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func firstN(args []string, n int) []string {
+	if len(args) < n {
+		return args
+	}
+	return args[:n]
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -8,14 +8,27 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // time zones must resolve even where the host has no zoneinfo
 
 	"ElasticObservability/pkg/api"
+	"ElasticObservability/pkg/audit"
+	"ElasticObservability/pkg/blackout"
 	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
 	"ElasticObservability/pkg/jobs"
+	"ElasticObservability/pkg/kafka"
 	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/maintenance"
+	"ElasticObservability/pkg/notify"
+	"ElasticObservability/pkg/output"
+	"ElasticObservability/pkg/remediation"
 	"ElasticObservability/pkg/scheduler"
+	"ElasticObservability/pkg/selftelemetry"
+	"ElasticObservability/pkg/utils"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -49,10 +62,91 @@ func main() {
 		fmt.Printf("Failed to initialize logger: %v\n", err)
 		os.Exit(1)
 	}
+	if err := logger.InitAccessLog(filepath.Join(*logDir, "access.log")); err != nil {
+		fmt.Printf("Failed to initialize access log: %v\n", err)
+		os.Exit(1)
+	}
 
 	logger.AppInfo("ElasticObservability started")
 	logger.AppInfo("Configuration loaded from: %s", *configFile)
 
+	if err := maintenance.Configure(config.Global.MaintenanceWindows); err != nil {
+		logger.AppError("Invalid maintenance windows: %v", err)
+		os.Exit(1)
+	}
+
+	// The time zone was validated when loading the configuration
+	timeZone, _ := time.LoadLocation(config.Global.TimeZone)
+	if err := blackout.Configure(config.Global.BlackoutCalendars, timeZone); err != nil {
+		logger.AppError("Invalid blackout calendars: %v", err)
+		os.Exit(1)
+	}
+
+	eventRetention, err := utils.ParseDuration(config.Global.Events.Retention)
+	if err != nil {
+		logger.AppError("Invalid events.retention: %v", err)
+		os.Exit(1)
+	}
+	if err := events.Configure(config.Global.Events.File, eventRetention); err != nil {
+		logger.AppError("Failed to load event store: %v", err)
+		os.Exit(1)
+	}
+	logger.AppInfo("Event store: %s (resolved events kept %s)", config.Global.Events.File, config.Global.Events.Retention)
+	events.ConfigureMetadata(config.Global.EventMetadata)
+
+	if err := kafka.Configure(config.Global.Kafka); err != nil {
+		logger.AppError("Invalid kafka settings: %v", err)
+		os.Exit(1)
+	}
+	events.Subscribe(kafka.PublishEvent)
+
+	if err := notify.ConfigureForwarding(config.Global.Notifications); err != nil {
+		logger.AppError("Invalid event forwarding: %v", err)
+		os.Exit(1)
+	}
+	events.Subscribe(notify.ForwardEvent)
+	if syslog := config.Global.Notifications.Syslog; syslog.Address != "" {
+		logger.AppInfo("Forwarding %s events to syslog %s (%s)", strings.Join(syslog.Severities, ", "), syslog.Address, syslog.Protocol)
+	}
+	if snmp := config.Global.Notifications.SNMP; snmp.Address != "" {
+		logger.AppInfo("Forwarding %s events as SNMP traps to %s", strings.Join(snmp.Severities, ", "), snmp.Address)
+	}
+	if kafkaConfig := config.Global.Kafka; len(kafkaConfig.Brokers) > 0 && kafkaConfig.Topic != "" {
+		logger.AppInfo("Publishing events to Kafka topic %s (%s)", kafkaConfig.Topic, kafkaConfig.Format)
+	}
+
+	if err := audit.Configure(config.Global.Audit.File, config.Global.Audit.MaxEntries); err != nil {
+		logger.AppError("Failed to load audit log: %v", err)
+		os.Exit(1)
+	}
+	logger.AppInfo("Audit log: %s", config.Global.Audit.File)
+
+	if err := remediation.Configure(config.Global.Remediations); err != nil {
+		logger.AppError("Invalid remediations: %v", err)
+		os.Exit(1)
+	}
+	events.Subscribe(remediation.HandleEvent)
+	if remediations := config.Global.Remediations; remediations.Enabled {
+		logger.AppInfo("Remediations enabled: %d actions (dry run %t by default)", len(remediations.Actions),
+			remediations.DryRun == nil || *remediations.DryRun)
+	}
+
+	if err := output.Configure(config.Global.OutDir, config.Global.Outputs); err != nil {
+		logger.AppError("Invalid outputs: %v", err)
+		os.Exit(1)
+	}
+	logger.AppInfo("Output destinations: %s", strings.Join(output.Names(), ", "))
+	if destination := config.Global.BackupDestination; destination != "" && !output.Exists(destination) {
+		logger.AppError("Invalid backupDestination: unknown output destination %q", destination)
+		os.Exit(1)
+	}
+
+	selftelemetry.Configure(config.Global.SelfTelemetry.Enabled, config.Global.SelfTelemetry.RunsKept)
+	if config.Global.SelfTelemetry.Enabled {
+		logger.AppInfo("Self-telemetry enabled (%d runs kept per job, pprof %t)",
+			config.Global.SelfTelemetry.RunsKept, config.Global.SelfTelemetry.Pprof)
+	}
+
 	// Create scheduler
 	sched := scheduler.NewScheduler()
 
@@ -110,6 +204,25 @@ func main() {
 		}
 	}()
 
+	// Start admin server (profiles and runtime debug endpoints) when configured
+	var adminServer *http.Server
+	if adminConfig := config.Global.Admin; adminConfig.Port > 0 {
+		runtime.SetBlockProfileRate(adminConfig.BlockProfileRate)
+		runtime.SetMutexProfileFraction(adminConfig.MutexProfileFraction)
+		adminAddr := fmt.Sprintf("%s:%d", adminConfig.Address, adminConfig.Port)
+		adminServer = &http.Server{
+			Addr:    adminAddr,
+			Handler: api.NewAdminServer(sched),
+		}
+
+		go func() {
+			logger.AppInfo("Admin server listening on %s", adminAddr)
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.AppError("Admin server error: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -124,6 +237,13 @@ func main() {
 	// Stop scheduler
 	sched.Stop()
 
+	// Send the messages queued for Kafka and the events queued for forwarding
+	kafka.Close()
+	notify.StopForwarding()
+
+	// Stop remediating; pending rollbacks are persisted and run after the restart
+	remediation.Stop()
+
 	// Shutdown API server
 	if err := httpServer.Shutdown(ctx); err != nil {
 		logger.AppError("API server shutdown error: %v", err)
@@ -134,6 +254,13 @@ func main() {
 		logger.AppError("Metrics server shutdown error: %v", err)
 	}
 
+	// Shutdown admin server
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			logger.AppError("Admin server shutdown error: %v", err)
+		}
+	}
+
 	logger.AppInfo("ElasticObservability stopped")
 }
 
@@ -148,6 +275,39 @@ func registerPredefinedJobs(sched *scheduler.Scheduler) {
 	sched.RegisterJobFunc("getThreadPoolWriteQueue", jobs.GetThreadPoolWriteQueue)
 	sched.RegisterJobFunc("checkForWritePressure", jobs.CheckForWritePressure)
 	sched.RegisterJobFunc("getTDataWriteBulk_sTasks", jobs.GetTDataWriteBulk_sTasks)
+	sched.RegisterJobFunc("enforceMemoryBudgets", jobs.EnforceMemoryBudgets)
+	sched.RegisterJobFunc("loadOwners", jobs.LoadOwners)
+	sched.RegisterJobFunc("sendOwnerReports", jobs.SendOwnerReports)
+	sched.RegisterJobFunc("evaluateRules", jobs.EvaluateRules)
+	sched.RegisterJobFunc("checkRetention", jobs.CheckRetention)
+	sched.RegisterJobFunc("getNodeDiskUsage", jobs.GetNodeDiskUsage)
+	sched.RegisterJobFunc("getNodeJVMStats", jobs.GetNodeJVMStats)
+	sched.RegisterJobFunc("checkForHeapPressure", jobs.CheckForHeapPressure)
+	sched.RegisterJobFunc("getThreadPoolRejections", jobs.GetThreadPoolRejections)
+	sched.RegisterJobFunc("getNodeSegmentStats", jobs.GetNodeSegmentStats)
+	sched.RegisterJobFunc("getShardRecoveries", jobs.GetShardRecoveries)
+	sched.RegisterJobFunc("checkSettingsDrift", jobs.CheckSettingsDrift)
+	sched.RegisterJobFunc("getIndexFieldCounts", jobs.GetIndexFieldCounts)
+	sched.RegisterJobFunc("getIngestPipelineStats", jobs.GetIngestPipelineStats)
+	sched.RegisterJobFunc("getRemoteClusters", jobs.GetRemoteClusters)
+	sched.RegisterJobFunc("watchLongRunningTasks", jobs.WatchLongRunningTasks)
+	sched.RegisterJobFunc("trackTasks", jobs.TrackTasks)
+	sched.RegisterJobFunc("getDataTiers", jobs.GetDataTiers)
+	sched.RegisterJobFunc("dumpState", jobs.DumpState)
+	sched.RegisterJobFunc("renameCluster", jobs.RenameCluster)
+	sched.RegisterJobFunc("decommissionNodes", jobs.DecommissionNodes)
+	sched.RegisterJobFunc("verifyInventory", jobs.VerifyInventory)
+	sched.RegisterJobFunc("pruneArtifacts", jobs.PruneArtifacts)
+
+	sched.RegisterJobValidator("getThreadPoolWriteQueue", jobs.ValidateThreadPoolWriteQueueParams)
+	sched.RegisterJobValidator("evaluateRules", jobs.ValidateEvaluateRulesParams)
+	sched.RegisterJobValidator("checkForWritePressure", jobs.ValidateWritePressureParams)
+	sched.RegisterJobValidator("getTDataWriteBulk_sTasks", jobs.ValidateBulkTasksParams)
+	sched.RegisterJobValidator("watchLongRunningTasks", jobs.ValidateLongRunningTaskParams)
+	sched.RegisterJobValidator("checkRetention", jobs.ValidateCheckRetentionParams)
+	sched.RegisterJobValidator("renameCluster", jobs.ValidateRenameClusterParams)
+	sched.RegisterJobValidator("decommissionNodes", jobs.ValidateDecommissionNodesParams)
+	sched.RegisterJobValidator("pruneArtifacts", jobs.ValidatePruneArtifactsParams)
 	logger.AppInfo("Predefined jobs registered")
 }
 
@@ -207,7 +367,7 @@ func loadAndRunInitializationJobs(sched *scheduler.Scheduler) error {
 		return fmt.Errorf("initialization jobs failed: %w", err)
 	}
 
-	logger.AppInfo("All initialization jobs completed successfully")
+	logger.AppInfo("Initialization jobs completed")
 	return nil
 }
 
@@ -233,6 +393,40 @@ func loadScheduledJobs(sched *scheduler.Scheduler) error {
 		logger.AppInfo("Added scheduled job: %s", jobConfig.Name)
 	}
 
+	return loadTenantJobs(sched)
+}
+
+// loadTenantJobs adds the scheduled jobs of the tenant directories. Only predefined jobs that
+// can be restricted to a tenant's clusters are accepted.
+func loadTenantJobs(sched *scheduler.Scheduler) error {
+	jobConfigs, err := config.LoadTenantJobs(config.Global.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to load tenant jobs: %w", err)
+	}
+	if len(jobConfigs) == 0 {
+		return nil
+	}
+
+	logger.AppInfo("Loaded %d tenant job(s)", len(jobConfigs))
+
+	for _, jobConfig := range jobConfigs {
+		if !jobConfig.Enabled {
+			logger.AppInfo("Skipping disabled tenant job: %s", jobConfig.Name)
+			continue
+		}
+		if jobConfig.Type != "preDefined" || !jobs.IsTenantScoped(jobConfig.InternalJobName) {
+			logger.AppWarn("Skipping tenant job %s: %s cannot be restricted to a tenant's clusters",
+				jobConfig.Name, jobConfig.InternalJobName)
+			continue
+		}
+
+		if err := sched.AddJob(jobConfig); err != nil {
+			logger.AppWarn("Failed to add tenant job %s: %v", jobConfig.Name, err)
+			continue
+		}
+		logger.AppInfo("Added tenant job: %s (tenant %s)", jobConfig.Name, jobConfig.Tenant)
+	}
+
 	return nil
 }
 
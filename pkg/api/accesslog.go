@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// unmatchedRoute is the route label of requests no route matched
+const unmatchedRoute = "unmatched"
+
+// accessEntry is a line of the access log
+type accessEntry struct {
+	Time       int64  `json:"time"` // epoch milliseconds (UTC) the request arrived
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Query      string `json:"query,omitempty"`
+	Route      string `json:"route"`
+	Status     int    `json:"status"`
+	Bytes      int    `json:"bytes"`
+	DurationMs int64  `json:"durationMs"`
+	Principal  string `json:"principal"`
+	Tenant     string `json:"tenant,omitempty"`
+	RemoteAddr string `json:"remoteAddr"`
+	UserAgent  string `json:"userAgent,omitempty"`
+}
+
+// requestTrace collects what the inner handlers learn about a request: the matched route
+// (recordRoute) and the principal (authenticate)
+type requestTrace struct {
+	route     string
+	principal *principal
+}
+
+type requestTraceKey struct{}
+
+// traceOf returns the trace of a request, or nil outside logRequests
+func traceOf(r *http.Request) *requestTrace {
+	trace, _ := r.Context().Value(requestTraceKey{}).(*requestTrace)
+	return trace
+}
+
+// tracePrincipal notes the principal of a request in its trace
+func tracePrincipal(r *http.Request, p *principal) {
+	if trace := traceOf(r); trace != nil {
+		trace.principal = p
+	}
+}
+
+// statusRecorder keeps the status and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// logRequests writes every request to the access log and counts it per route, method,
+// status and principal, with its latency per route. It wraps the router, so requests
+// rejected by authentication and requests no route matches are included.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		trace := &requestTrace{route: unmatchedRoute}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestTraceKey{}, trace)))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		elapsed := time.Since(start)
+
+		principalName, tenant := "", ""
+		if trace.principal != nil {
+			principalName, tenant = trace.principal.Name, trace.principal.Tenant
+		}
+		metrics.APIRequestsTotal.WithLabelValues(trace.route, r.Method, strconv.Itoa(rec.status), principalName).Inc()
+		metrics.APIRequestDuration.WithLabelValues(trace.route, r.Method).Observe(elapsed.Seconds())
+
+		logger.Access(accessEntry{
+			Time:       start.UnixMilli(),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Query:      r.URL.RawQuery,
+			Route:      trace.route,
+			Status:     rec.status,
+			Bytes:      rec.bytes,
+			DurationMs: elapsed.Milliseconds(),
+			Principal:  principalName,
+			Tenant:     tenant,
+			RemoteAddr: r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+		})
+	})
+}
+
+// recordRoute notes the template of the matched route in the request trace
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if trace := traceOf(r); trace != nil {
+			if template, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+				trace.route = template
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
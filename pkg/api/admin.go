@@ -0,0 +1,125 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rtpprof "runtime/pprof"
+	"sort"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/scheduler"
+
+	"github.com/gorilla/mux"
+)
+
+// NewAdminServer creates the server of the admin port: pprof profiles, goroutine stacks, lock
+// contention and the jobs the scheduler is running, for diagnosing hangs in production. It
+// authenticates like the API and admits principals without a tenant holding one of the admin
+// roles, when configured.
+func NewAdminServer(sched *scheduler.Scheduler) *Server {
+	s := &Server{
+		router:    mux.NewRouter(),
+		scheduler: sched,
+	}
+	s.setupAdminRoutes()
+	return s
+}
+
+// setupAdminRoutes configures the admin port routes
+func (s *Server) setupAdminRoutes() {
+	s.router.Use(s.authenticate, s.authorizeAdmin)
+
+	s.router.HandleFunc("/debug/goroutines", s.handleGetGoroutines).Methods("GET")
+	s.router.HandleFunc("/debug/contention", s.handleGetContention).Methods("GET")
+	s.router.HandleFunc("/debug/jobs", s.handleGetRunningJobs).Methods("GET")
+	registerPprofRoutes(s.router, nil)
+}
+
+// registerPprofRoutes adds the net/http/pprof handlers under /debug/pprof/, each wrapped in
+// the given middleware, if any
+func registerPprofRoutes(router *mux.Router, wrap mux.MiddlewareFunc) {
+	handler := func(fn http.HandlerFunc) http.Handler {
+		if wrap == nil {
+			return fn
+		}
+		return wrap(fn)
+	}
+	router.Handle("/debug/pprof/cmdline", handler(pprof.Cmdline)).Methods("GET")
+	router.Handle("/debug/pprof/profile", handler(pprof.Profile)).Methods("GET")
+	router.Handle("/debug/pprof/symbol", handler(pprof.Symbol)).Methods("GET", "POST")
+	router.Handle("/debug/pprof/trace", handler(pprof.Trace)).Methods("GET")
+	router.PathPrefix("/debug/pprof/").Handler(handler(pprof.Index)).Methods("GET")
+}
+
+// authorizeAdmin restricts the admin port to principals without a tenant and, when admin roles
+// are configured and the API has tokens, to principals holding one of them
+func (s *Server) authorizeAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := principalOf(r)
+		if p.Tenant != "" {
+			respondError(w, http.StatusForbidden, "Tenant tokens cannot access admin endpoints")
+			return
+		}
+		if p != anonymous && len(config.Global.Admin.Roles) > 0 && !p.hasAnyRole(config.Global.Admin.Roles) {
+			respondError(w, http.StatusForbidden, "Admin role required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleGetGoroutines dumps the stacks of all goroutines, with the time each has been blocked.
+// ?grouped=true groups identical stacks with their counts instead.
+func (s *Server) handleGetGoroutines(w http.ResponseWriter, r *http.Request) {
+	debug := 2
+	if r.URL.Query().Get("grouped") == "true" {
+		debug = 1
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "goroutines: %d\n\n", runtime.NumGoroutine())
+	rtpprof.Lookup("goroutine").WriteTo(w, debug)
+}
+
+// handleGetContention dumps the mutex and block profiles: where goroutines waited for locks,
+// channels and select, and for how long. Both stay empty unless sampling is enabled with
+// admin.mutexProfileFraction and admin.blockProfileRate.
+func (s *Server) handleGetContention(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "mutexProfileFraction: %d\nblockProfileRate: %d\n\n",
+		config.Global.Admin.MutexProfileFraction, config.Global.Admin.BlockProfileRate)
+	fmt.Fprintln(w, "=== mutex contention ===")
+	rtpprof.Lookup("mutex").WriteTo(w, 1)
+	fmt.Fprintln(w, "\n=== blocking ===")
+	rtpprof.Lookup("block").WriteTo(w, 1)
+}
+
+// handleGetRunningJobs returns the jobs the scheduler is running and for how long, longest
+// running first
+func (s *Server) handleGetRunningJobs(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	running := make([]map[string]interface{}, 0)
+	for name, value := range s.scheduler.GetJobStatus() {
+		status, ok := value.(map[string]interface{})
+		if !ok || status["running"] != true {
+			continue
+		}
+		since, _ := status["lastRun"].(time.Time)
+		running = append(running, map[string]interface{}{
+			"job":          name,
+			"runningSince": since,
+			"runningFor":   now.Sub(since).Round(time.Second).String(),
+		})
+	}
+	sort.Slice(running, func(i, j int) bool {
+		return running[i]["runningSince"].(time.Time).Before(running[j]["runningSince"].(time.Time))
+	})
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"running":    running,
+		"count":      len(running),
+		"goroutines": runtime.NumGoroutine(),
+	})
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ElasticObservability/pkg/audit"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// maxAuditBody bounds the request and response bodies read for an audit entry
+const maxAuditBody = 64 * 1024
+
+// redactedParams are parameter names whose values never reach the audit log
+var redactedParams = []string{"password", "token", "apikey", "secret", "credential"}
+
+// readOnlyRoutes are the names of routes that only read although their method is not GET,
+// e.g. the queries of the Grafana datasource; they are not audited
+var readOnlyRoutes = map[string]bool{"grafanaSearch": true, "grafanaQuery": true, "backtestWritePressure": true}
+
+// auditRecorder captures the status and the start of the body of a response
+type auditRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (a *auditRecorder) WriteHeader(status int) {
+	a.status = status
+	a.ResponseWriter.WriteHeader(status)
+}
+
+func (a *auditRecorder) Write(b []byte) (int, error) {
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	if room := maxAuditBody - a.body.Len(); room > 0 {
+		a.body.Write(b[:min(room, len(b))])
+	}
+	return a.ResponseWriter.Write(b)
+}
+
+// auditMutations records every mutating request (any method but GET, HEAD and OPTIONS) in
+// the audit log, except the readOnlyRoutes. It runs after authenticate, so the principal is
+// known; requests rejected by authentication are not audited.
+func (s *Server) auditMutations(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if readOnlyRoutes[routeName(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, maxAuditBody))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		}
+
+		rec := &auditRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		p := principalOf(r)
+		vars := mux.Vars(r)
+		entry := audit.Entry{
+			Time:       utils.TimeNowMillis(),
+			Principal:  p.Name,
+			Tenant:     p.Tenant,
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Action:     routeName(r),
+			Target:     vars["jobName"] + vars["id"] + vars["clusterName"],
+			Parameters: auditParameters(r, vars, body),
+			Status:     rec.status,
+			Result:     audit.ResultSuccess,
+		}
+
+		var response struct {
+			ID    string `json:"id"`
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(rec.body.Bytes(), &response)
+		if rec.status >= http.StatusBadRequest {
+			entry.Result = audit.ResultFailure
+			entry.Error = response.Error
+		} else if entry.Target == "" {
+			entry.Target = response.ID // e.g. the ID of a created silence
+		}
+
+		if err := audit.Record(entry); err != nil {
+			logger.AppError("Failed to record audit entry for %s %s by %s: %v", r.Method, r.URL.Path, p.Name, err)
+		}
+	})
+}
+
+// routeName returns the name of the matched route, or its path template
+func routeName(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return r.URL.Path
+	}
+	if name := route.GetName(); name != "" {
+		return name
+	}
+	template, _ := route.GetPathTemplate()
+	return template
+}
+
+// auditParameters collects the path variables, query parameters and top-level JSON body
+// fields of a request. Values of secret-looking parameters are redacted.
+func auditParameters(r *http.Request, vars map[string]string, body []byte) map[string]string {
+	params := make(map[string]string)
+	for k, v := range vars {
+		params[k] = v
+	}
+	for k, v := range r.URL.Query() {
+		params[k] = strings.Join(v, ",")
+	}
+
+	var fields map[string]interface{}
+	if len(body) > 0 && json.Unmarshal(body, &fields) == nil {
+		for k, v := range fields {
+			if s, ok := v.(string); ok {
+				params[k] = s
+			} else if data, err := json.Marshal(v); err == nil {
+				params[k] = string(data)
+			} else {
+				params[k] = fmt.Sprint(v)
+			}
+		}
+	}
+
+	for k := range params {
+		lower := strings.ToLower(k)
+		for _, secret := range redactedParams {
+			if strings.Contains(lower, secret) {
+				params[k] = "[redacted]"
+				break
+			}
+		}
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	return params
+}
+
+// handleGetAudit returns the most recent audit entries passing the filters, newest first.
+// Tenant principals only see the entries of their tenant.
+func (s *Server) handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Principal: query.Get("principal"),
+		Tenant:    query.Get("tenant"),
+		Action:    query.Get("action"),
+		Target:    query.Get("target"),
+		Result:    query.Get("result"),
+	}
+	if filter.Result != "" && filter.Result != audit.ResultSuccess && filter.Result != audit.ResultFailure {
+		respondError(w, http.StatusBadRequest, "Invalid result: must be success or failure")
+		return
+	}
+	if p := principalOf(r); p.Tenant != "" {
+		filter.Tenant = p.Tenant
+	}
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid from: %v", err))
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid to: %v", err))
+		return
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			respondError(w, http.StatusBadRequest, "Invalid limit: must be a positive integer")
+			return
+		}
+		filter.Limit = n
+	}
+
+	list := make([]map[string]interface{}, 0)
+	for _, entry := range audit.Query(filter) {
+		item := map[string]interface{}{
+			"id":         entry.ID,
+			"principal":  entry.Principal,
+			"tenant":     entry.Tenant,
+			"remoteAddr": entry.RemoteAddr,
+			"method":     entry.Method,
+			"path":       entry.Path,
+			"action":     entry.Action,
+			"target":     entry.Target,
+			"parameters": entry.Parameters,
+			"status":     entry.Status,
+			"result":     entry.Result,
+			"error":      entry.Error,
+		}
+		tr.put(item, "time", entry.Time)
+		list = append(list, item)
+	}
+
+	response := map[string]interface{}{
+		"entries":   list,
+		"count":     len(list),
+		"timestamp": utils.TimeNowMillis(),
+	}
+	tr.annotate(response)
+	respondJSON(w, http.StatusOK, response)
+}
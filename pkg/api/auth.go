@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// principal is the caller of an API request
+type principal struct {
+	Name   string
+	Tenant string // "" = all tenants
+	Roles  []string
+}
+
+type principalKey struct{}
+
+// anonymous is the principal of every request when no API tokens are configured
+var anonymous = &principal{Name: "anonymous"}
+
+// authenticate resolves the bearer token of a request to its principal. Without configured
+// tokens the API is open and every request is anonymous with access to all tenants.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.Global == nil || len(config.Global.APITokens) == 0 {
+			tracePrincipal(r, anonymous)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, anonymous)))
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ElasticObservability"`)
+			respondError(w, http.StatusUnauthorized, "Missing bearer token")
+			return
+		}
+
+		p := lookupToken(token)
+		if p == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ElasticObservability", error="invalid_token"`)
+			respondError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+		tracePrincipal(r, p)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+// lookupToken returns the principal of a configured token, or nil. Every token is compared
+// in constant time.
+func lookupToken(token string) *principal {
+	var found *principal
+	for _, t := range config.Global.APITokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 && found == nil {
+			found = &principal{Name: t.Name, Tenant: t.Tenant, Roles: t.Roles}
+		}
+	}
+	return found
+}
+
+// authorizeJob allows a job request only to principals holding a role of every job
+// permission matching the job. Jobs of other tenants are reported as not found to tenant
+// principals. The anonymous principal of an open API is not restricted.
+func (s *Server) authorizeJob(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status, err := s.checkJobAccess(principalOf(r), mux.Vars(r)["jobName"]); err != nil {
+			respondError(w, status, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkJobAccess checks whether a principal may trigger a job, see authorizeJob. It returns
+// the status to answer with when not.
+func (s *Server) checkJobAccess(p *principal, jobName string) (int, error) {
+	if p.Tenant != "" {
+		if tenant, _ := s.scheduler.JobTenant(jobName); tenant != p.Tenant {
+			return http.StatusNotFound, fmt.Errorf("Failed to trigger job: job not found: %s", jobName)
+		}
+	}
+	if p == anonymous || config.Global == nil {
+		return 0, nil
+	}
+
+	internalJobName, _ := s.scheduler.JobInternalName(jobName)
+	for _, permission := range config.Global.JobPermissions {
+		if !permissionMatches(permission, jobName, internalJobName) {
+			continue
+		}
+		if !p.hasAnyRole(permission.Roles) {
+			logger.AppWarn("Principal %s is not permitted to trigger job %s", p.Name, jobName)
+			return http.StatusForbidden, fmt.Errorf("Not permitted to trigger job %s", jobName)
+		}
+	}
+	return 0, nil
+}
+
+// authorizeAction allows an endpoint changing the inventory, its reports or alerting to
+// principals holding a role of every job permission naming its action (e.g. renameCluster
+// for the rename of a cluster). Unlike a job, an action no permission names is refused, so
+// the roles allowed to take it are always configured. The anonymous principal of an open API
+// is not restricted.
+func (s *Server) authorizeAction(action string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := principalOf(r)
+		if p == anonymous || config.Global == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		named := false
+		for _, permission := range config.Global.JobPermissions {
+			if !permissionMatches(permission, action, "") {
+				continue
+			}
+			named = true
+			if !p.hasAnyRole(permission.Roles) {
+				logger.AppWarn("Principal %s is not permitted to %s", p.Name, action)
+				respondError(w, http.StatusForbidden, fmt.Sprintf("Not permitted to %s", action))
+				return
+			}
+		}
+		if !named {
+			logger.AppWarn("Principal %s refused %s: no jobPermissions entry names it", p.Name, action)
+			respondError(w, http.StatusForbidden, fmt.Sprintf("%s requires a jobPermissions entry naming it", action))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorizeInstance restricts endpoints about the instance as a whole (e.g. profiles) to
+// principals without a tenant
+func (s *Server) authorizeInstance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if principalOf(r).Tenant != "" {
+			respondError(w, http.StatusForbidden, "Tenant tokens cannot access instance profiles")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// permissionMatches reports whether a job permission applies to a job
+func permissionMatches(permission config.JobPermission, jobName, internalJobName string) bool {
+	for _, job := range permission.Jobs {
+		if job == "*" || job == jobName || (internalJobName != "" && job == internalJobName) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnyRole reports whether the principal holds at least one of the roles
+func (p *principal) hasAnyRole(roles []string) bool {
+	for _, role := range p.Roles {
+		if utils.Contains(roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// principalOf returns the principal of an authenticated request
+func principalOf(r *http.Request) *principal {
+	if p, ok := r.Context().Value(principalKey{}).(*principal); ok {
+		return p
+	}
+	return anonymous
+}
+
+// seesTenant reports whether the principal may see data of a tenant
+func (p *principal) seesTenant(tenant string) bool {
+	return p.Tenant == "" || p.Tenant == tenant
+}
+
+// seesCluster reports whether the principal may see a cluster (unknown clusters are not seen)
+func (p *principal) seesCluster(clusterName string) bool {
+	tenant, exists := types.ClusterTenant(clusterName)
+	return exists && p.seesTenant(tenant)
+}
+
+// clusterVisible reports whether a cluster exists and the caller may see it. Clusters of
+// other tenants are reported as not found, so their names do not leak.
+func clusterVisible(r *http.Request, clusterName string) bool {
+	return principalOf(r).seesCluster(clusterName)
+}
+
+// visibleClusterNames returns the sorted names of the clusters the caller may see
+func visibleClusterNames(r *http.Request) []string {
+	p := principalOf(r)
+	if p.Tenant == "" {
+		return types.ClusterNames()
+	}
+	return types.ClustersOfTenant(p.Tenant)
+}
+
+// seesAnyCluster reports whether the principal may see at least one cluster of a list
+// ("*" matches every cluster, so it is seen by everyone)
+func (p *principal) seesAnyCluster(clusterNames []string) bool {
+	for _, clusterName := range clusterNames {
+		if clusterName == "*" || p.seesCluster(clusterName) {
+			return true
+		}
+	}
+	return p.Tenant == ""
+}
+
+// seesAllClusters reports whether the principal may see every cluster of a list. Only
+// principals of all tenants see "*".
+func (p *principal) seesAllClusters(clusterNames []string) bool {
+	if p.Tenant == "" {
+		return true
+	}
+	for _, clusterName := range clusterNames {
+		if clusterName == "*" || !p.seesCluster(clusterName) {
+			return false
+		}
+	}
+	return true
+}
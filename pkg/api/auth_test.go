@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/types"
+)
+
+func TestAuthorizeAction(t *testing.T) {
+	operator := &principal{Name: "ops", Roles: []string{"operator"}}
+	viewer := &principal{Name: "dashboards", Roles: []string{"viewer"}}
+	renamePermissions := []config.JobPermission{
+		{Jobs: []string{"loadFromMasterCSV"}, Roles: []string{"platform-admin"}},
+		{Jobs: []string{"renameCluster"}, Roles: []string{"platform-admin", "operator"}},
+	}
+	tests := []struct {
+		name        string
+		principal   *principal
+		permissions []config.JobPermission
+		want        int
+	}{
+		{"open API", anonymous, nil, http.StatusOK},
+		{"no entry names the action", operator, []config.JobPermission{{Jobs: []string{"loadFromMasterCSV"}, Roles: []string{"operator"}}}, http.StatusForbidden},
+		{"role of the entry", operator, renamePermissions, http.StatusOK},
+		{"other role", viewer, renamePermissions, http.StatusForbidden},
+		{"wildcard entry", operator, []config.JobPermission{{Jobs: []string{"*"}, Roles: []string{"operator"}}}, http.StatusOK},
+		{"role of one of two entries", operator, append(renamePermissions, config.JobPermission{Jobs: []string{"*"}, Roles: []string{"platform-admin"}}), http.StatusForbidden},
+	}
+	saved := config.Global
+	t.Cleanup(func() { config.Global = saved })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Global = &config.GlobalConfig{JobPermissions: tt.permissions}
+			handler := (&Server{}).authorizeAction("renameCluster", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest("POST", "/api/v1/clusters/prod-01/rename", nil)
+			req = req.WithContext(context.WithValue(req.Context(), principalKey{}, tt.principal))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestStateChangingRoutesRequireAction checks that the routes changing the inventory or
+// alerting go through authorizeAction with their action: a token without a role of its entry
+// is refused before the handler runs
+func TestStateChangingRoutesRequireAction(t *testing.T) {
+	routes := []struct {
+		method, path, action string
+		permitted            int // status of a token with the role, from the handler
+	}{
+		{"POST", "/api/v1/clusters/missing-cluster/rename", "renameCluster", http.StatusNotFound},
+		{"POST", "/api/v1/clusters/missing-cluster/endpoints", "switchEndpoints", http.StatusNotFound},
+		{"POST", "/api/v1/clusters/missing-cluster/nodes/es-data-01/decommission", "decommissionNode", http.StatusNotFound},
+		{"DELETE", "/api/v1/clusters/missing-cluster/nodes/es-data-01/decommission", "decommissionNode", http.StatusNotFound},
+		{"POST", "/api/v1/settingsDrift/missing-cluster/baseline", "acceptSettingsBaseline", http.StatusNotFound},
+		{"POST", "/api/v1/maintenance/silences", "manageSilences", http.StatusBadRequest},
+		{"DELETE", "/api/v1/maintenance/silences/missing-silence", "manageSilences", http.StatusNotFound},
+	}
+	saved := config.Global
+	t.Cleanup(func() { config.Global = saved })
+	config.Global = &config.GlobalConfig{
+		APITokens: []config.APIToken{
+			{Name: "ops", Token: "ops-token", Roles: []string{"operator"}},
+			{Name: "dashboards", Token: "dashboards-token", Roles: []string{"viewer"}},
+		},
+	}
+	for _, route := range routes {
+		config.Global.JobPermissions = append(config.Global.JobPermissions,
+			config.JobPermission{Jobs: []string{route.action}, Roles: []string{"operator"}})
+	}
+	s := NewServer(nil)
+
+	for _, route := range routes {
+		for token, want := range map[string]int{"dashboards-token": http.StatusForbidden, "ops-token": route.permitted} {
+			req := httptest.NewRequest(route.method, route.path, strings.NewReader(`{}`))
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, req)
+			if rec.Code != want {
+				t.Errorf("%s %s with %s: status = %d, want %d: %s", route.method, route.path, token, rec.Code, want, rec.Body.String())
+			}
+		}
+	}
+}
+
+// TestAcceptSettingsBaselineOtherTenant checks that a tenant token with the role cannot accept
+// the baseline of a cluster of another tenant, which is reported as not found
+func TestAcceptSettingsBaselineOtherTenant(t *testing.T) {
+	saved := config.Global
+	t.Cleanup(func() { config.Global = saved })
+	config.Global = &config.GlobalConfig{
+		APITokens: []config.APIToken{
+			{Name: "search-ops", Token: "search-token", Tenant: "search", Roles: []string{"operator"}},
+			{Name: "ops", Token: "ops-token", Roles: []string{"operator"}},
+		},
+		JobPermissions: []config.JobPermission{{Jobs: []string{"acceptSettingsBaseline"}, Roles: []string{"operator"}}},
+	}
+	const clusterName = "baseline-payments"
+	types.MutateClusters(func(clusters map[string]*types.ClusterData) {
+		clusters[clusterName] = &types.ClusterData{ClusterName: clusterName, Tenant: "payments", Active: true}
+	})
+	t.Cleanup(func() {
+		types.MutateClusters(func(clusters map[string]*types.ClusterData) { delete(clusters, clusterName) })
+	})
+	s := NewServer(nil)
+
+	for token, want := range map[string]string{"search-token": "Cluster not found", "ops-token": "Settings drift not checked for this cluster yet"} {
+		req := httptest.NewRequest("POST", "/api/v1/settingsDrift/"+clusterName+"/baseline", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s: status = %d %s, want 404 %q", token, rec.Code, rec.Body.String(), want)
+		}
+	}
+}
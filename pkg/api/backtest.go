@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"ElasticObservability/pkg/jobs"
+	"ElasticObservability/pkg/types"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBacktestCandidates is the number of candidate configurations a backtest replays at most
+const maxBacktestCandidates = 20
+
+// writePressureBacktestRequest is the body of POST /api/writePressure/{clusterName}/backtest
+type writePressureBacktestRequest struct {
+	Resolution string                        `json:"resolution"` // raw (default) or the interval of a rollup, e.g. 5m
+	Aggregate  string                        `json:"aggregate"`  // queue of a rollup bucket: avg (default) or max
+	Window     int                           `json:"window"`     // data points a check sees, default the raw data points kept
+	Candidates []jobs.WritePressureCandidate `json:"candidates"`
+}
+
+// handleWritePressureBacktest replays the stored write queue history of a cluster through the
+// configured checkForWritePressure job and candidate configurations, and reports the events
+// each would have fired. Nothing is fired or stored.
+func (s *Server) handleWritePressureBacktest(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req writePressureBacktestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if len(req.Candidates) > maxBacktestCandidates {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("At most %d candidates per backtest", maxBacktestCandidates))
+		return
+	}
+	resolutionMs, err := parseResolution(req.Resolution)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, ok := types.GetTPWQueue(clusterName); !ok {
+		respondError(w, http.StatusNotFound, "Thread pool write queue data not available for this cluster yet")
+		return
+	}
+
+	// The configured job is the baseline every candidate changes
+	jobName, base := s.writePressureJob(principalOf(r))
+	candidates := append([]jobs.WritePressureCandidate{{Name: "current"}}, req.Candidates...)
+	report, err := jobs.BacktestWritePressure(clusterName, base, candidates, jobs.WritePressureBacktestOptions{
+		ResolutionMs: resolutionMs,
+		Aggregate:    req.Aggregate,
+		Window:       req.Window,
+	})
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := map[string]interface{}{
+		"cluster":    clusterName,
+		"job":        jobName,
+		"resolution": resolutionName(report.ResolutionMs),
+		"aggregate":  report.Aggregate,
+		"window":     report.Window,
+		"hosts":      report.Hosts,
+		"intervals":  report.Intervals,
+		"candidates": report.Candidates,
+	}
+	tr.put(response, "from", report.From)
+	tr.put(response, "to", report.To)
+	tr.annotate(response)
+	respondJSON(w, http.StatusOK, response)
+}
+
+// writePressureJob returns the name and parameters of the checkForWritePressure job of the
+// principal's tenant, preferring an enabled one; no job is the defaults of the job
+func (s *Server) writePressureJob(p *principal) (string, map[string]interface{}) {
+	name, params := "", map[string]interface{}(nil)
+	for _, jobConfig := range s.scheduler.JobConfigs() {
+		if jobConfig.InternalJobName != "checkForWritePressure" || !p.seesTenant(jobConfig.Tenant) {
+			continue
+		}
+		if name == "" || jobConfig.Enabled {
+			name, params = jobConfig.Name, jobConfig.Parameters
+		}
+		if jobConfig.Enabled {
+			break
+		}
+	}
+	return name, params
+}
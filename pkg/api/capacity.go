@@ -0,0 +1,293 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ElasticObservability/pkg/jobs"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Growth window of the capacity summaries, in days of the daily statistics
+const (
+	defaultGrowthDays = 7
+	maxGrowthDays     = 90
+)
+
+// clusterCapacity is the capacity summary of a cluster, from the latest indexing rate, daily
+// statistics, disk usage and data tier collections; a summary lacks what was not collected yet
+type clusterCapacity struct {
+	cluster            string
+	nodes              int            // nodes in the inventory
+	dataNodes          int            // data nodes reporting disk usage
+	tierNodes          map[string]int // data nodes per tier, from getDataTiers
+	ingestBytesPerSec  float64        // primary store, -1 = unknown
+	ingestWindow       string
+	indexingDocsPerSec float64 // from getDataTiers, -1 = unknown
+	dailyGrowthBytes   float64 // store size, replicas included
+	growthDays         int     // days the daily growth is averaged over, 0 = unknown
+	diskTotalBytes     uint64
+	diskAvailBytes     uint64
+	headroomBytes      uint64 // free disk below the high watermark
+	diskSnapShotTime   int64
+	missing            []string
+}
+
+// capacityOf builds the capacity summary of a cluster, averaging the daily growth over the
+// latest growthDays days
+func capacityOf(clusterName string, growthDays int) *clusterCapacity {
+	c := &clusterCapacity{cluster: clusterName, ingestBytesPerSec: -1, indexingDocsPerSec: -1}
+
+	if cluster, exists := types.GetCluster(clusterName); exists {
+		c.nodes = len(cluster.Nodes)
+	}
+
+	if rate, ok := types.GetIndexingRate(clusterName); ok && rate != nil {
+		for _, window := range []struct {
+			name string
+			rate float64
+		}{{"60m", rate.Total.Last60Minutes}, {"15m", rate.Total.Last15Minutes}, {"3m", rate.Total.Last3Minutes}} {
+			if window.rate >= 0 {
+				c.ingestBytesPerSec, c.ingestWindow = window.rate, window.name
+				break
+			}
+		}
+	} else {
+		c.missing = append(c.missing, "indexingRate")
+	}
+
+	if stats, ok := types.GetStatsByDay(clusterName); ok && stats != nil {
+		c.dailyGrowthBytes, c.growthDays = dailyGrowth(stats, growthDays)
+	} else {
+		c.missing = append(c.missing, "statsByDay")
+	}
+
+	if usage, ok := types.GetDiskUsage(clusterName); ok && usage != nil {
+		c.diskSnapShotTime = usage.SnapShotTime
+		for _, node := range usage.Nodes {
+			point := node.Points.At(0)
+			if point.TimeStamp != usage.SnapShotTime || !isDataNode(node.Roles) {
+				continue // not reported in the latest collection, or holds no shards
+			}
+			c.dataNodes++
+			c.diskTotalBytes += point.TotalBytes
+			c.diskAvailBytes += point.AvailableBytes
+			reserve, err := jobs.WatermarkReserve(usage.Watermarks.High, point.TotalBytes)
+			if err == nil && point.AvailableBytes > reserve {
+				c.headroomBytes += point.AvailableBytes - reserve
+			}
+		}
+	} else {
+		c.missing = append(c.missing, "diskUsage")
+	}
+
+	if tiers, ok := types.GetTiers(clusterName); ok && tiers != nil {
+		c.tierNodes = make(map[string]int, len(tiers.Tiers))
+		for tier, usage := range tiers.Tiers {
+			c.tierNodes[tier] = len(usage.Nodes)
+			if usage.IndexingRate >= 0 {
+				c.indexingDocsPerSec = max(c.indexingDocsPerSec, 0) + usage.IndexingRate
+			}
+		}
+	}
+	return c
+}
+
+// dailyGrowth returns the average daily growth of the store size of the indices over the
+// latest days with daily statistics, and the number of such days. Indices deleted in between
+// are not subtracted, so the growth errs on the side of capacity.
+func dailyGrowth(stats *types.IndicesStatsByDay, days int) (float64, int) {
+	var total int64
+	counted := 0
+	for day := 0; day < days; day++ {
+		var growth int64
+		seen := false
+		for _, history := range stats.StatHistory {
+			if history == nil {
+				continue
+			}
+			if delta, ok := history.Stats.Delta(day, day+1, func(stat *types.IndexStat) float64 { return float64(stat.TotalSize) }); ok {
+				growth += int64(delta)
+				seen = true
+			}
+		}
+		if seen {
+			total += growth
+			counted++
+		}
+	}
+	if counted == 0 {
+		return 0, 0
+	}
+	return float64(total) / float64(counted), counted
+}
+
+// isDataNode reports whether a node holds shards, by its roles
+func isDataNode(roles []string) bool {
+	for _, role := range roles {
+		if strings.HasPrefix(role, "data") {
+			return true
+		}
+	}
+	return false
+}
+
+// daysLeft returns how many days free bytes last at a daily growth, or nil without growth
+func daysLeft(free uint64, dailyGrowth float64) interface{} {
+	if dailyGrowth <= 0 {
+		return nil
+	}
+	return math.Round(float64(free)/dailyGrowth*10) / 10
+}
+
+// render returns the capacity summary as a response item
+func (c *clusterCapacity) render(tr *timeRenderer) map[string]interface{} {
+	item := map[string]interface{}{
+		"cluster":             c.cluster,
+		"nodes":               c.nodes,
+		"dataNodes":           c.dataNodes,
+		"ingestBytesPerSec":   c.ingestBytesPerSec,
+		"indexingDocsPerSec":  c.indexingDocsPerSec,
+		"dailyGrowthBytes":    c.dailyGrowthBytes,
+		"growthDays":          c.growthDays,
+		"diskTotalBytes":      c.diskTotalBytes,
+		"diskAvailableBytes":  c.diskAvailBytes,
+		"headroomBytes":       c.headroomBytes,
+		"daysToHighWatermark": daysLeft(c.headroomBytes, c.dailyGrowthBytes),
+		"daysToFull":          daysLeft(c.diskAvailBytes, c.dailyGrowthBytes),
+	}
+	if c.ingestWindow != "" {
+		item["ingestWindow"] = c.ingestWindow
+	}
+	if c.dataNodes > 0 {
+		if c.ingestBytesPerSec >= 0 {
+			item["ingestBytesPerSecPerDataNode"] = c.ingestBytesPerSec / float64(c.dataNodes)
+		}
+		if c.indexingDocsPerSec >= 0 {
+			item["indexingDocsPerSecPerDataNode"] = c.indexingDocsPerSec / float64(c.dataNodes)
+		}
+	}
+	if c.tierNodes != nil {
+		item["tierNodes"] = c.tierNodes
+	}
+	if len(c.missing) > 0 {
+		item["missing"] = c.missing
+	}
+	if c.diskSnapShotTime > 0 {
+		tr.put(item, "diskSnapShotTime", c.diskSnapShotTime)
+	}
+	return item
+}
+
+// growthDaysParam reads the days parameter of a capacity request
+func growthDaysParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	days := defaultGrowthDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		if days, err = strconv.Atoi(v); err != nil || days < 1 || days > maxGrowthDays {
+			respondError(w, http.StatusBadRequest, "Invalid days: must be between 1 and 90")
+			return 0, false
+		}
+	}
+	return days, true
+}
+
+// handleGetCapacity returns the capacity summary of every cluster, the clusters closest to
+// their high disk watermark first, with fleet-wide totals
+func (s *Server) handleGetCapacity(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	days, ok := growthDaysParam(w, r)
+	if !ok {
+		return
+	}
+
+	summaries := make([]*clusterCapacity, 0)
+	for _, clusterName := range visibleClusterNames(r) {
+		summaries = append(summaries, capacityOf(clusterName, days))
+	}
+	// Clusters without growth have no days left and come last
+	remaining := func(c *clusterCapacity) float64 {
+		if c.dailyGrowthBytes <= 0 {
+			return math.Inf(1)
+		}
+		return float64(c.headroomBytes) / c.dailyGrowthBytes
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := remaining(summaries[i]), remaining(summaries[j])
+		if a != b {
+			return a < b
+		}
+		return summaries[i].cluster < summaries[j].cluster
+	})
+
+	fleet := &clusterCapacity{cluster: "", ingestBytesPerSec: -1, indexingDocsPerSec: -1}
+	clusters := make([]map[string]interface{}, 0, len(summaries))
+	for _, c := range summaries {
+		clusters = append(clusters, c.render(tr))
+		fleet.nodes += c.nodes
+		fleet.dataNodes += c.dataNodes
+		fleet.dailyGrowthBytes += c.dailyGrowthBytes
+		fleet.diskTotalBytes += c.diskTotalBytes
+		fleet.diskAvailBytes += c.diskAvailBytes
+		fleet.headroomBytes += c.headroomBytes
+		if c.ingestBytesPerSec >= 0 {
+			fleet.ingestBytesPerSec = max(fleet.ingestBytesPerSec, 0) + c.ingestBytesPerSec
+		}
+		if c.indexingDocsPerSec >= 0 {
+			fleet.indexingDocsPerSec = max(fleet.indexingDocsPerSec, 0) + c.indexingDocsPerSec
+		}
+	}
+	fleetItem := fleet.render(tr)
+	delete(fleetItem, "cluster")
+	delete(fleetItem, "growthDays")
+
+	response := map[string]interface{}{
+		"days":     days,
+		"fleet":    fleetItem,
+		"clusters": clusters,
+		"count":    len(clusters),
+	}
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetCapacityCluster returns the capacity summary of a cluster
+func (s *Server) handleGetCapacityCluster(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	days, ok := growthDaysParam(w, r)
+	if !ok {
+		return
+	}
+
+	response := capacityOf(clusterName, days).render(tr)
+	response["days"] = days
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
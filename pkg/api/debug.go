@@ -0,0 +1,139 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"ElasticObservability/pkg/jobs"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Structures dumped by GET /debug/snapshot/{structure}/{clusterName}
+const (
+	debugIndices   = "indices"   // indices history of runCatIndices
+	debugTPWQueue  = "tpwqueue"  // thread pool write queues of getThreadPoolWriteQueue
+	debugBulkTasks = "bulkTasks" // task histories of getTDataWriteBulk_sTasks
+)
+
+// handleGetDebugSnapshot dumps an in-memory structure of a cluster as the collection holds it,
+// not as the other endpoints derive from it: timestamps stay epoch milliseconds, rings are
+// newest first, and nothing is filled, aligned or converted. The indices and bulk task
+// histories dump one snapshot (?slot, 0 = latest) unless ?all=true; the write queues dump all
+// hosts unless ?host names one. Admins only, as it bypasses the tenant views.
+func (s *Server) handleGetDebugSnapshot(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	structure := vars["structure"]
+	clusterName := vars["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+
+	query := r.URL.Query()
+	slot := 0
+	if v := query.Get("slot"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			respondError(w, http.StatusBadRequest, "slot must be a non-negative integer")
+			return
+		}
+		slot = n
+	}
+	all := false
+	if v := query.Get("all"); v != "" {
+		var err error
+		if all, err = strconv.ParseBool(v); err != nil {
+			respondError(w, http.StatusBadRequest, "all must be true or false")
+			return
+		}
+	}
+
+	response := map[string]interface{}{
+		"structure":   structure,
+		"clusterName": clusterName,
+	}
+	switch structure {
+	case debugIndices:
+		history, exists := types.GetHistory(clusterName)
+		if !exists || history == nil {
+			respondError(w, http.StatusNotFound, "Indices history not available for this cluster")
+			return
+		}
+		held := history.GetCopy()
+		if !putDebugSlot(w, response, held.Snapshots.Cap(), slot, all, held, func(i int) interface{} {
+			return held.Snapshots.At(i)
+		}) {
+			return
+		}
+
+	case debugTPWQueue:
+		queue, exists := types.HeldTPWQueue(clusterName)
+		if !exists {
+			respondError(w, http.StatusNotFound, "Thread pool write queue data not available for this cluster")
+			return
+		}
+		if v := query.Get("host"); v != "" {
+			hostName := jobs.ResolveHostName(clusterName, v)
+			tpwq, ok := queue.HostTPWQueue[hostName]
+			if !ok {
+				respondError(w, http.StatusNotFound, fmt.Sprintf("Host %s not found in the write queue data", hostName))
+				return
+			}
+			response["hostName"] = hostName
+			response["held"] = tpwq
+		} else {
+			response["held"] = queue
+		}
+		if published, ok := types.GetTPWQueue(clusterName); ok {
+			response["publishedGeneration"] = published.Generation
+		}
+
+	case debugBulkTasks:
+		action := taskAction(r)
+		history, exists := types.HeldTaskActionHistory(clusterName, action)
+		if !exists {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Task history %q not available for this cluster", action))
+			return
+		}
+		response["action"] = action
+		if !putDebugSlot(w, response, history.PtrClusterDataWriteBulk_sTasks.Cap(), slot, all, history, func(i int) interface{} {
+			return history.PtrClusterDataWriteBulk_sTasks.At(i)
+		}) {
+			return
+		}
+		if published, ok := types.GetTaskActionHistory(clusterName, action); ok {
+			response["publishedSnapShotTime"] = published.LatestSnapShotTime
+		}
+
+	default:
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Unknown structure %q, expected %s, %s or %s",
+			structure, debugIndices, debugTPWQueue, debugBulkTasks))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// putDebugSlot adds a history of slots to a debug response: the whole history with all, else
+// the one slot asked for. It reports whether the slot exists, answering 404 when it does not.
+func putDebugSlot(w http.ResponseWriter, response map[string]interface{}, slots, slot int, all bool,
+	history interface{}, at func(i int) interface{}) bool {
+
+	response["slots"] = slots
+	if all {
+		response["held"] = history
+		return true
+	}
+	if slot >= slots {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Slot %d out of range, the history has %d slots", slot, slots))
+		return false
+	}
+	response["slot"] = slot
+	response["held"] = at(slot)
+	return true
+}
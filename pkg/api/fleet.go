@@ -0,0 +1,170 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// pressureSources are the event sources counted as pressure events in the fleet roll-up
+var pressureSources = []string{"writePressure", "heapPressure"}
+
+// fleetPressureWindow is how far back the fleet roll-up counts pressure events
+const fleetPressureWindow = 24 * time.Hour
+
+// healthOrder ranks cluster health for the fleet roll-up, worst first
+var healthOrder = map[string]int{"red": 0, "yellow": 1, "unknown": 2, "green": 3}
+
+// clusterHealth returns the health of a cluster from the index health of its latest indices
+// snapshot, and the snapshot, if any
+func clusterHealth(clusterName string) (string, *types.IndicesSnapShot) {
+	history, ok := types.GetHistory(clusterName)
+	if !ok {
+		return "unknown", nil
+	}
+	snapshot := history.Latest(0)
+	switch {
+	case snapshot == nil:
+		return "unknown", nil
+	case snapshot.Totals.Red > 0:
+		return "red", snapshot
+	case snapshot.Totals.Yellow > 0:
+		return "yellow", snapshot
+	default:
+		return "green", snapshot
+	}
+}
+
+// handleGetFleet returns one document for the whole fleet: per visible cluster its health,
+// ingest rate, firing events, pressure events of the last 24 hours and the outcome of its
+// latest collections, worst health first, with fleet-wide totals
+func (s *Server) handleGetFleet(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	nowMs := utils.TimeNowMillis()
+	clusterNames := visibleClusterNames(r)
+
+	// Events and collection statuses are read once and bucketed by cluster
+	pressure := make(map[string]map[string]int)
+	firing := make(map[string]int)
+	critical := make(map[string]int)
+	for _, event := range events.Query(events.Filter{From: nowMs - fleetPressureWindow.Milliseconds()}) {
+		clusterName := event.Cluster()
+		if event.State == events.StateFiring && !event.Suppressed {
+			firing[clusterName]++
+			if event.Severity == "critical" {
+				critical[clusterName]++
+			}
+		}
+		if utils.Contains(pressureSources, event.Source) && event.StartsAt >= nowMs-fleetPressureWindow.Milliseconds() {
+			if pressure[clusterName] == nil {
+				pressure[clusterName] = make(map[string]int, len(pressureSources))
+			}
+			pressure[clusterName][event.Source]++
+		}
+	}
+	collections := make(map[string][]types.CollectionStatus)
+	for _, status := range types.SnapshotCollectionStatus() {
+		collections[status.ClusterName] = append(collections[status.ClusterName], status)
+	}
+
+	healthCounts := map[string]int{"green": 0, "yellow": 0, "red": 0, "unknown": 0}
+	ingestTotal := -1.0
+	pressureTotal := 0
+	failingTotal := 0
+
+	clusters := make([]map[string]interface{}, 0, len(clusterNames))
+	for _, clusterName := range clusterNames {
+		health, snapshot := clusterHealth(clusterName)
+		healthCounts[health]++
+
+		entry := map[string]interface{}{
+			"cluster":        clusterName,
+			"health":         health,
+			"firingEvents":   firing[clusterName],
+			"criticalEvents": critical[clusterName],
+		}
+		if cluster, exists := types.GetCluster(clusterName); exists {
+			entry["active"] = cluster.ActiveEndpoint != ""
+			entry["env"] = cluster.Env
+		}
+		if snapshot != nil {
+			indices := map[string]interface{}{
+				"count":  snapshot.Totals.IndexCount,
+				"red":    snapshot.Totals.Red,
+				"yellow": snapshot.Totals.Yellow,
+			}
+			tr.put(indices, "snapShotTime", snapshot.SnapShotTime)
+			entry["indices"] = indices
+		}
+
+		if rate, ok := types.GetIndexingRate(clusterName); ok && rate != nil {
+			ingest := map[string]interface{}{
+				"last3Minutes":  rate.Total.Last3Minutes,
+				"last15Minutes": rate.Total.Last15Minutes,
+				"last60Minutes": rate.Total.Last60Minutes,
+			}
+			tr.put(ingest, "timestamp", rate.Timestamp)
+			entry["ingestRate"] = ingest
+			if rate.Total.Last15Minutes >= 0 {
+				ingestTotal = max(ingestTotal, 0) + rate.Total.Last15Minutes
+			}
+		}
+
+		clusterPressure := 0
+		bySource := make(map[string]int, len(pressureSources))
+		for _, source := range pressureSources {
+			bySource[source] = pressure[clusterName][source]
+			clusterPressure += bySource[source]
+		}
+		entry["pressureEvents24h"] = bySource
+		pressureTotal += clusterPressure
+
+		// The latest success over all collections, and the collections failing now
+		var lastSuccess int64
+		failing := make([]string, 0)
+		for _, status := range collections[clusterName] {
+			lastSuccess = max(lastSuccess, status.LastSuccess)
+			if status.ConsecutiveFailures > 0 {
+				failing = append(failing, status.JobName)
+			}
+		}
+		collection := map[string]interface{}{"failingJobs": failing}
+		tr.put(collection, "lastSuccess", lastSuccess)
+		entry["collection"] = collection
+		if len(failing) > 0 {
+			failingTotal++
+		}
+
+		clusters = append(clusters, entry)
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		a, b := healthOrder[clusters[i]["health"].(string)], healthOrder[clusters[j]["health"].(string)]
+		if a != b {
+			return a < b
+		}
+		return clusters[i]["cluster"].(string) < clusters[j]["cluster"].(string)
+	})
+
+	response := map[string]interface{}{
+		"count":             len(clusters),
+		"health":            healthCounts,
+		"ingestBytesPerSec": ingestTotal,
+		"pressureEvents24h": pressureTotal,
+		"failingClusters":   failingTotal,
+		"clusters":          clusters,
+	}
+	tr.put(response, "generatedAt", nowMs)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
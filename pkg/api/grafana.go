@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"ElasticObservability/pkg/types"
+)
+
+// Series of the Grafana datasource. Targets are named <series>:<cluster>[:<qualifier>]:
+// indexingRate:<cluster> (cluster total) and indexingRate:<cluster>:<indexBase> in bytes/s
+// over 3 minutes, tpwQueue:<cluster>:<host>, and the daily cluster totals
+// storeSize:<cluster> and docCount:<cluster>.
+const (
+	grafanaIndexingRate = "indexingRate"
+	grafanaTPWQueue     = "tpwQueue"
+	grafanaStoreSize    = "storeSize"
+	grafanaDocCount     = "docCount"
+)
+
+// maxGrafanaSearchResults bounds the target names returned by a search
+const maxGrafanaSearchResults = 1000
+
+// grafanaQueryRequest is the part of a SimpleJSON query that is used
+type grafanaQueryRequest struct {
+	Range struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+		Hide   bool   `json:"hide"`
+	} `json:"targets"`
+	MaxDataPoints int `json:"maxDataPoints"`
+}
+
+// grafanaPoint is a datapoint of a series: [value, epoch milliseconds]
+type grafanaPoint [2]float64
+
+// handleGrafanaTest answers the connection test of the datasource
+func (s *Server) handleGrafanaTest(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleGrafanaSearch returns the targets of the visible clusters containing the searched
+// text, for the query editor
+func (s *Server) handleGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Target string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	search := strings.ToLower(req.Target)
+
+	targets := make([]string, 0)
+	add := func(target string) {
+		if len(targets) < maxGrafanaSearchResults && strings.Contains(strings.ToLower(target), search) {
+			targets = append(targets, target)
+		}
+	}
+	for _, clusterName := range visibleClusterNames(r) {
+		if rate, ok := types.GetIndexingRate(clusterName); ok && rate != nil {
+			add(grafanaIndexingRate + ":" + clusterName)
+			for _, indexBase := range sortedKeys(rate.MapIndices) {
+				add(grafanaIndexingRate + ":" + clusterName + ":" + indexBase)
+			}
+		}
+		if queues, ok := types.GetTPWQueue(clusterName); ok {
+			for _, hostName := range sortedKeys(queues.HostTPWQueue) {
+				add(grafanaTPWQueue + ":" + clusterName + ":" + hostName)
+			}
+		}
+		if _, ok := types.GetStatsByDay(clusterName); ok {
+			add(grafanaStoreSize + ":" + clusterName)
+			add(grafanaDocCount + ":" + clusterName)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, targets)
+}
+
+// handleGrafanaQuery returns the datapoints of the requested targets within the range, at
+// most maxDataPoints per target
+func (s *Server) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var req grafanaQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	from, err := parseTimeParam(req.Range.From)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid range.from: "+err.Error())
+		return
+	}
+	to, err := parseTimeParam(req.Range.To)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid range.to: "+err.Error())
+		return
+	}
+
+	response := make([]map[string]interface{}, 0, len(req.Targets))
+	for _, target := range req.Targets {
+		if target.Hide || target.Target == "" {
+			continue
+		}
+		points, err := grafanaSeries(r, target.Target)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		inRange := make([]grafanaPoint, 0, len(points))
+		for _, point := range points {
+			if (from == 0 || int64(point[1]) >= from) && (to == 0 || int64(point[1]) <= to) {
+				inRange = append(inRange, point)
+			}
+		}
+		response = append(response, map[string]interface{}{
+			"target":     target.Target,
+			"datapoints": downsample(inRange, req.MaxDataPoints),
+		})
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// grafanaSeries returns the datapoints of a target, oldest first
+func grafanaSeries(r *http.Request, target string) ([]grafanaPoint, error) {
+	parts := strings.SplitN(target, ":", 3)
+	if len(parts) < 2 || !clusterVisible(r, parts[1]) {
+		return nil, fmt.Errorf("Unknown target %q", target)
+	}
+	series, clusterName, qualifier := parts[0], parts[1], ""
+	if len(parts) == 3 {
+		qualifier = parts[2]
+	}
+
+	points := make([]grafanaPoint, 0)
+	switch {
+	case series == grafanaIndexingRate:
+		rates, _ := types.IndexingRateHistorySince(clusterName, 0)
+		for _, rate := range rates {
+			value := rate.Total.Last3Minutes
+			if qualifier != "" {
+				value = -1
+				if indexRate := rate.MapIndices[qualifier]; indexRate != nil && indexRate.Last3Minutes >= 0 {
+					value = indexRate.Last3Minutes * float64(indexRate.NumberOfShards)
+				}
+			}
+			if value >= 0 {
+				points = append(points, grafanaPoint{value, float64(rate.Timestamp)})
+			}
+		}
+
+	case series == grafanaTPWQueue && qualifier != "":
+		queues, ok := types.GetTPWQueue(clusterName)
+		if !ok || queues.HostTPWQueue[qualifier] == nil {
+			return nil, fmt.Errorf("Unknown target %q", target)
+		}
+		for _, point := range queues.HostTPWQueue[qualifier].Points.OldestFirst() {
+			if point.Exists {
+				points = append(points, grafanaPoint{float64(point.Queue), float64(point.TimeStamp)})
+			}
+		}
+
+	case (series == grafanaStoreSize || series == grafanaDocCount) && qualifier == "":
+		stats, ok := types.GetStatsByDay(clusterName)
+		if !ok {
+			return points, nil
+		}
+		// Cluster totals per day slot, oldest first
+		totals := make(map[int64]float64)
+		for _, history := range stats.StatHistory {
+			if history == nil {
+				continue
+			}
+			for _, stat := range history.Stats.NewestFirst() {
+				if stat == nil {
+					continue
+				}
+				if series == grafanaStoreSize {
+					totals[stat.StatTime] += float64(stat.TotalSize)
+				} else {
+					totals[stat.StatTime] += float64(stat.DocCount)
+				}
+			}
+		}
+		for timestamp, total := range totals {
+			points = append(points, grafanaPoint{total, float64(timestamp)})
+		}
+		sort.Slice(points, func(i, j int) bool { return points[i][1] < points[j][1] })
+
+	default:
+		return nil, fmt.Errorf("Unknown target %q", target)
+	}
+	return points, nil
+}
+
+// downsample keeps at most maxPoints evenly spaced points, the latest point included;
+// maxPoints <= 0 keeps all
+func downsample(points []grafanaPoint, maxPoints int) []grafanaPoint {
+	if maxPoints <= 0 || len(points) <= maxPoints {
+		return points
+	}
+	step := float64(len(points)-1) / float64(maxPoints-1)
+	kept := make([]grafanaPoint, 0, maxPoints)
+	for i := 0; i < maxPoints; i++ {
+		kept = append(kept, points[int(float64(i)*step+0.5)])
+	}
+	return kept
+}
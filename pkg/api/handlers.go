@@ -2,11 +2,29 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/audit"
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/jobs"
 	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/maintenance"
+	"ElasticObservability/pkg/memory"
+	"ElasticObservability/pkg/onboard"
+	"ElasticObservability/pkg/rules"
 	"ElasticObservability/pkg/scheduler"
+	"ElasticObservability/pkg/selftelemetry"
 	"ElasticObservability/pkg/types"
 	"ElasticObservability/pkg/utils"
 
@@ -16,7 +34,11 @@ import (
 // Server represents the API server
 type Server struct {
 	router    *mux.Router
+	handler   http.Handler // router behind the proxy, base path and CORS handling
 	scheduler *scheduler.Scheduler
+
+	openAPIMu sync.Mutex
+	openAPI   map[int]map[string]interface{} // by API version, built on first request
 }
 
 // NewServer creates a new API server
@@ -24,51 +46,181 @@ func NewServer(sched *scheduler.Scheduler) *Server {
 	s := &Server{
 		router:    mux.NewRouter(),
 		scheduler: sched,
+		openAPI:   make(map[int]map[string]interface{}),
 	}
 	s.setupRoutes()
+	if config.Global != nil {
+		s.handler = frontend(s.router, config.Global.HTTP)
+	}
 	return s
 }
 
-// setupRoutes configures all API routes
+// setupRoutes configures all API routes: the versioned routes under /api/v1 and, deprecated,
+// the same routes under /api without a version
 func (s *Server) setupRoutes() {
+	s.router.Use(recordRoute, s.authenticate, s.auditMutations)
+
+	v1 := s.router.PathPrefix(apiPrefix(1)).Subrouter()
+	v1.Use(withAPIVersion(1), cacheAndCompress)
+	s.registerAPIRoutes(v1)
+
+	legacy := s.router.PathPrefix(legacyAPIPrefix).Subrouter()
+	legacy.Use(negotiateLegacyVersion, cacheAndCompress)
+	s.registerAPIRoutes(legacy)
+
+	// pprof profiles of job runs, when enabled
+	if config.Global != nil && config.Global.SelfTelemetry.Pprof {
+		registerPprofRoutes(s.router, s.authorizeInstance)
+	}
+}
+
+// registerAPIRoutes adds the API routes to a router for one API prefix
+func (s *Server) registerAPIRoutes(r *mux.Router) {
 	// Cluster endpoints
-	s.router.HandleFunc("/api/clusters", s.handleGetClusters).Methods("GET")
-	s.router.HandleFunc("/api/clusters/{clusterName}/nodes", s.handleGetNodes).Methods("GET")
+	r.HandleFunc("/clusters", s.handleGetClusters).Methods("GET")
+	r.HandleFunc("/clusters/{clusterName}/nodes", s.handleGetNodes).Methods("GET")
+	r.HandleFunc("/clusters/{clusterName}/summary", s.handleGetClusterSummary).Methods("GET")
+	r.Handle("/clusters/{clusterName}/rename", s.authorizeAction("renameCluster", http.HandlerFunc(s.handleRenameCluster))).Methods("POST").Name("renameCluster")
+	r.Handle("/clusters/{clusterName}/endpoints", s.authorizeAction("switchEndpoints", http.HandlerFunc(s.handleSwitchEndpoints))).Methods("POST").Name("switchEndpoints")
+	r.HandleFunc("/fleet", s.handleGetFleet).Methods("GET")
 
 	// Indexing rate endpoints
-	s.router.HandleFunc("/api/indexingRate/{clusterName}", s.handleGetIndexingRate).Methods("GET")
+	r.HandleFunc("/indexingRate/{clusterName}", s.handleGetIndexingRate).Methods("GET")
+	r.HandleFunc("/indexingRate/{clusterName}/history", s.handleGetIndexingRateHistory).Methods("GET")
+
+	// Daily statistics endpoints
+	r.HandleFunc("/statsByDay/{clusterName}", s.handleGetStatsByDayCluster).Methods("GET")
+	r.HandleFunc("/statsByDay/{clusterName}/{indexName}", s.handleGetStatsByDayIndex).Methods("GET")
+
+	// Retention compliance endpoints
+	r.HandleFunc("/retention", s.handleGetRetention).Methods("GET")
+	r.HandleFunc("/retention/{clusterName}", s.handleGetRetentionCluster).Methods("GET")
+
+	// Remote cluster endpoints
+	r.HandleFunc("/remoteClusters", s.handleGetRemoteClusters).Methods("GET")
+	r.HandleFunc("/remoteClusters/{clusterName}", s.handleGetRemoteClustersCluster).Methods("GET")
+	r.HandleFunc("/masterHistory/{clusterName}", s.handleGetMasterHistory).Methods("GET")
+
+	// Data tier endpoints
+	r.HandleFunc("/tiers/{clusterName}", s.handleGetTiers).Methods("GET")
+
+	// Capacity planning endpoints
+	r.HandleFunc("/capacity", s.handleGetCapacity).Methods("GET")
+	r.HandleFunc("/capacity/{clusterName}", s.handleGetCapacityCluster).Methods("GET")
+
+	// Ingest pipeline endpoints
+	r.HandleFunc("/pipelines/{clusterName}", s.handleGetPipelines).Methods("GET")
+	r.HandleFunc("/pipelines/{clusterName}/{pipeline}", s.handleGetPipelineHistory).Methods("GET")
+
+	// Index field count endpoint
+	r.HandleFunc("/indexFields/{clusterName}", s.handleGetIndexFields).Methods("GET")
+
+	// Settings drift endpoints
+	r.HandleFunc("/settingsDrift", s.handleGetSettingsDrift).Methods("GET")
+	r.HandleFunc("/settingsDrift/{clusterName}", s.handleGetSettingsDriftCluster).Methods("GET")
+	r.Handle("/settingsDrift/{clusterName}/baseline", s.authorizeAction("acceptSettingsBaseline", http.HandlerFunc(s.handleAcceptSettingsBaseline))).Methods("POST").Name("acceptSettingsBaseline")
+
+	// Node disk usage endpoint
+	r.HandleFunc("/diskUsage/{clusterName}", s.handleGetDiskUsage).Methods("GET")
+
+	// Node JVM heap and GC endpoint
+	r.HandleFunc("/jvm/{clusterName}", s.handleGetJVMStats).Methods("GET")
 
 	// Stale indices endpoint
-	s.router.HandleFunc("/api/staleIndices/{clusterName}/{days}", s.handleGetStaleIndices).Methods("GET")
+	r.HandleFunc("/staleIndices/{clusterName}/{days}", s.handleGetStaleIndices).Methods("GET")
+
+	// Segment and merge endpoints
+	r.HandleFunc("/segments/{clusterName}", s.handleGetSegments).Methods("GET")
+	r.HandleFunc("/segments/{clusterName}/{hostName}", s.handleGetSegmentsHost).Methods("GET")
+
+	// Shard recovery endpoint
+	r.HandleFunc("/recoveries/{clusterName}", s.handleGetRecoveries).Methods("GET")
 
 	// Thread Pool Write Queue endpoints
-	s.router.HandleFunc("/api/tpwqueue/{clusterName}", s.handleGetTPWQueueCluster).Methods("GET")
-	s.router.HandleFunc("/api/tpwqueue/{clusterName}/{hostName}", s.handleGetTPWQueueHost).Methods("GET")
+	r.HandleFunc("/tpwqueue/{clusterName}", s.handleGetTPWQueueCluster).Methods("GET")
+	r.HandleFunc("/tpwqueue/{clusterName}/{hostName}", s.handleGetTPWQueueHost).Methods("GET")
+	r.HandleFunc("/monitoringClusters", s.handleGetMonitoringClusters).Methods("GET")
+
+	// Write pressure threshold backtest over the stored write queue history (read-only)
+	r.HandleFunc("/writePressure/{clusterName}/backtest", s.handleWritePressureBacktest).Methods("POST").Name("backtestWritePressure")
+
+	// Thread pool rejections endpoint
+	r.HandleFunc("/threadPoolRejections/{clusterName}", s.handleGetThreadPoolRejections).Methods("GET")
 
 	// Bulk Write Tasks endpoints
-	s.router.HandleFunc("/api/bulkTasks/clusters", s.handleGetBulkTasksClusters).Methods("GET")
-	s.router.HandleFunc("/api/bulkTasks/{clusterName}", s.handleGetBulkTasksHistory).Methods("GET")
-	s.router.HandleFunc("/api/bulkTasks/{clusterName}/latest", s.handleGetBulkTasksLatest).Methods("GET")
+	r.HandleFunc("/bulkTasks/clusters", s.handleGetBulkTasksClusters).Methods("GET")
+	r.HandleFunc("/bulkTasks/{clusterName}", s.handleGetBulkTasksHistory).Methods("GET")
+	r.HandleFunc("/bulkTasks/{clusterName}/latest", s.handleGetBulkTasksLatest).Methods("GET")
+
+	// Progress of tracked long-running tasks (reindex, by-query, force merge)
+	r.HandleFunc("/tasks/tracked", s.handleGetTrackedTasks).Methods("GET")
+	r.HandleFunc("/tasks/tracked", s.handleTrackTask).Methods("POST").Name("trackTask")
+	r.HandleFunc("/tasks/tracked/{clusterName}/{taskId}", s.handleUntrackTask).Methods("DELETE").Name("untrackTask")
+
+	// Guarded remediations: pre-approved index settings changes with automatic rollback
+	r.HandleFunc("/remediations", s.handleGetRemediations).Methods("GET")
+	r.HandleFunc("/remediations/{name}/run", s.handleRunRemediation).Methods("POST").Name("runRemediation")
+	r.HandleFunc("/remediations/runs/{id}/rollback", s.handleRollbackRemediation).Methods("POST").Name("rollbackRemediation")
 
 	// Status endpoints
-	s.router.HandleFunc("/api/status", s.handleGetStatus).Methods("GET")
-	s.router.HandleFunc("/api/jobs", s.handleGetJobs).Methods("GET")
+	r.HandleFunc("/status", s.handleGetStatus).Methods("GET")
+	r.HandleFunc("/jobs", s.handleGetJobs).Methods("GET")
+	r.HandleFunc("/memory", s.handleGetMemory).Methods("GET")
+	r.HandleFunc("/collectionStatus", s.handleGetCollectionStatus).Methods("GET")
+
+	// Raw in-memory structures of a cluster, for admins verifying what is held
+	r.Handle("/debug/snapshot/{structure}/{clusterName}", s.authorizeAdmin(http.HandlerFunc(s.handleGetDebugSnapshot))).Methods("GET")
+
+	// Self-telemetry of job runs
+	r.HandleFunc("/selftelemetry", s.handleGetSelfTelemetry).Methods("GET")
+	r.HandleFunc("/selftelemetry/{jobName}", s.handleGetSelfTelemetryJob).Methods("GET")
 
 	// Job control
-	s.router.HandleFunc("/api/jobs/{jobName}/trigger", s.handleTriggerJob).Methods("POST")
+	r.Handle("/jobs/{jobName}/trigger", s.authorizeJob(http.HandlerFunc(s.handleTriggerJob))).Methods("POST").Name("triggerJob")
+	r.HandleFunc("/jobs/triggerGroup", s.handleTriggerJobGroup).Methods("POST").Name("triggerJobGroup")
+
+	// Onboarding of new clusters
+	r.HandleFunc("/onboarding", s.handleGenerateOnboarding).Methods("POST").Name("generateOnboarding")
+
+	// Maintenance windows and silences
+	r.HandleFunc("/maintenance", s.handleGetMaintenance).Methods("GET")
+	r.Handle("/maintenance/silences", s.authorizeAction("manageSilences", http.HandlerFunc(s.handleCreateSilence))).Methods("POST").Name("createSilence")
+	r.Handle("/maintenance/silences/{id}", s.authorizeAction("manageSilences", http.HandlerFunc(s.handleDeleteSilence))).Methods("DELETE").Name("deleteSilence")
+	r.HandleFunc("/maintenance/decommissions", s.handleGetDecommissions).Methods("GET")
+	r.Handle("/clusters/{clusterName}/nodes/{hostName}/decommission", s.authorizeAction("decommissionNode", http.HandlerFunc(s.handleStartDecommission))).Methods("POST").Name("startDecommission")
+	r.Handle("/clusters/{clusterName}/nodes/{hostName}/decommission", s.authorizeAction("decommissionNode", http.HandlerFunc(s.handleCancelDecommission))).Methods("DELETE").Name("cancelDecommission")
+
+	// Alert rules
+	r.HandleFunc("/alerts", s.handleGetAlerts).Methods("GET")
+
+	// Event store
+	r.HandleFunc("/events", s.handleGetEvents).Methods("GET")
+	r.HandleFunc("/events/{id}", s.handleGetEvent).Methods("GET")
+
+	// Audit log of mutating calls
+	r.HandleFunc("/audit", s.handleGetAudit).Methods("GET")
+
+	// Grafana SimpleJSON datasource of the collected series
+	r.HandleFunc("/grafana", s.handleGrafanaTest).Methods("GET")
+	r.HandleFunc("/grafana/search", s.handleGrafanaSearch).Methods("POST").Name("grafanaSearch")
+	r.HandleFunc("/grafana/query", s.handleGrafanaQuery).Methods("POST").Name("grafanaQuery")
+
+	// OpenAPI document of the endpoints above
+	r.HandleFunc("/openapi.json", s.handleGetOpenAPI).Methods("GET")
 }
 
 // ServeHTTP implements http.Handler
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.handler != nil {
+		s.handler.ServeHTTP(w, r)
+		return
+	}
 	s.router.ServeHTTP(w, r)
 }
 
 // handleGetClusters returns list of all clusters
 func (s *Server) handleGetClusters(w http.ResponseWriter, r *http.Request) {
-	types.ClustersMu.RLock()
-	clusters := make([]string, len(types.AllClustersList))
-	copy(clusters, types.AllClustersList)
-	types.ClustersMu.RUnlock()
+	clusters := visibleClusterNames(r)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"clusters": clusters,
@@ -87,11 +239,9 @@ func (s *Server) handleGetNodes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	types.ClustersMu.RLock()
-	cluster, exists := types.AllClusters[clusterName]
-	types.ClustersMu.RUnlock()
+	cluster, exists := types.GetCluster(clusterName)
 
-	if !exists {
+	if !exists || !clusterVisible(r, clusterName) {
 		respondError(w, http.StatusNotFound, "Cluster not found")
 		return
 	}
@@ -99,7 +249,7 @@ func (s *Server) handleGetNodes(w http.ResponseWriter, r *http.Request) {
 	// Build response with node information
 	nodes := make([]map[string]interface{}, 0, len(cluster.Nodes))
 	for _, node := range cluster.Nodes {
-		nodes = append(nodes, map[string]interface{}{
+		entry := map[string]interface{}{
 			"hostName":   node.HostName,
 			"ipAddress":  node.IPAddress,
 			"port":       node.Port,
@@ -107,7 +257,13 @@ func (s *Server) handleGetNodes(w http.ResponseWriter, r *http.Request) {
 			"zone":       node.Zone,
 			"nodeTier":   node.NodeTier,
 			"dataCenter": node.DataCenter,
-		})
+			// Only report whether an override exists, never the credentials themselves
+			"hasCredentialOverride": node.AccessCred != nil && node.AccessCred.Preferred != 0,
+		}
+		if reason, ok := maintenance.Decommissioning(clusterName, node.HostName); ok {
+			entry["decommissioning"] = reason
+		}
+		nodes = append(nodes, entry)
 	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
@@ -117,6 +273,158 @@ func (s *Server) handleGetNodes(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleGetClusterSummary returns the cluster-wide totals of the latest indices snapshot and
+// indexing rate computation, so dashboards do not need to sum the indices themselves
+func (s *Server) handleGetClusterSummary(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	cluster, exists := types.GetCluster(clusterName)
+	if !exists || !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := map[string]interface{}{
+		"cluster":   clusterName,
+		"nodeCount": len(cluster.Nodes),
+	}
+
+	if history, ok := types.GetHistory(clusterName); ok {
+		if snapshot := history.Latest(0); snapshot != nil {
+			totals := snapshot.Totals
+			indices := map[string]interface{}{
+				"count":          totals.IndexCount,
+				"open":           totals.OpenIndices,
+				"closed":         totals.ClosedIndices,
+				"docCount":       totals.DocCount,
+				"totalStorage":   totals.TotalStorage,
+				"primaryStorage": totals.PrimaryStorage,
+				"health": map[string]int{
+					"green":   totals.Green,
+					"yellow":  totals.Yellow,
+					"red":     totals.Red,
+					"unknown": totals.UnknownHealth,
+				},
+			}
+			tr.put(indices, "snapShotTime", snapshot.SnapShotTime)
+			response["indices"] = indices
+		}
+	}
+
+	if rate, ok := types.GetIndexingRate(clusterName); ok && rate != nil {
+		ingest := map[string]interface{}{
+			"last3Minutes":  rate.Total.Last3Minutes,
+			"last15Minutes": rate.Total.Last15Minutes,
+			"last60Minutes": rate.Total.Last60Minutes,
+		}
+		tr.put(ingest, "timestamp", rate.Timestamp)
+		response["ingestRate"] = ingest
+	}
+
+	if _, ok := response["indices"]; !ok {
+		if _, ok := response["ingestRate"]; !ok {
+			respondError(w, http.StatusNotFound, "Indices data not available for this cluster yet")
+			return
+		}
+	}
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// renameClusterRequest is the body of POST /api/clusters/{clusterName}/rename
+type renameClusterRequest struct {
+	NewName string `json:"newName"`
+}
+
+// handleRenameCluster re-keys a cluster renamed in the CMDB and everything collected for it to
+// its new name; a new name already in the inventory is merged with the cluster
+func (s *Server) handleRenameCluster(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	var req renameClusterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if !utils.ValidateClusterName(req.NewName) || req.NewName == clusterName {
+		respondError(w, http.StatusBadRequest, "Invalid newName")
+		return
+	}
+	// Merging into a cluster of another tenant would hand it the history of this one
+	if types.ClusterExists(req.NewName) && !clusterVisible(r, req.NewName) {
+		respondError(w, http.StatusForbidden, fmt.Sprintf("Cluster %s belongs to another tenant", req.NewName))
+		return
+	}
+
+	result, err := jobs.ApplyClusterRename(clusterName, req.NewName)
+	if err != nil && result == nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	logger.AppInfo("Cluster %s renamed to %s by %s (merged: %t)", clusterName, req.NewName, principalOf(r).Name, result.Merged)
+	respondJSON(w, http.StatusOK, result)
+}
+
+// switchEndpointsRequest is the body of POST /api/clusters/{clusterName}/endpoints
+type switchEndpointsRequest struct {
+	ClusterSAN     []string `json:"clusterSAN"`
+	ActiveEndpoint string   `json:"activeEndpoint"` // default: the first of clusterSAN
+	DryRun         bool     `json:"dryRun"`
+}
+
+// handleSwitchEndpoints switches a cluster to a new ClusterSAN set (blue/green load balancer
+// rotation) after checking that every new endpoint is reachable and reports the cluster's UUID
+func (s *Server) handleSwitchEndpoints(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+	if !types.ClusterExists(clusterName) || !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	var req switchEndpointsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	p := principalOf(r)
+	by := audit.Entry{Principal: p.Name, Tenant: p.Tenant, RemoteAddr: r.RemoteAddr, Method: r.Method, Path: r.URL.Path}
+	result, err := jobs.SwitchClusterEndpoints(r.Context(), clusterName, req.ClusterSAN, req.ActiveEndpoint, req.DryRun, by)
+	var validationErr *jobs.EndpointValidationError
+	if errors.As(err, &validationErr) {
+		// The checks tell which endpoints failed and why
+		respondJSON(w, http.StatusUnprocessableEntity, struct {
+			Error string `json:"error"`
+			*jobs.EndpointSwitch
+		}{err.Error(), result})
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
 // handleGetIndexingRate returns indexing rate for a cluster
 func (s *Server) handleGetIndexingRate(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -129,24 +437,26 @@ func (s *Server) handleGetIndexingRate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if cluster exists
-	types.ClustersMu.RLock()
-	_, exists := types.AllClusters[clusterName]
-	types.ClustersMu.RUnlock()
-
-	if !exists {
+	if !clusterVisible(r, clusterName) {
 		respondError(w, http.StatusNotFound, "Cluster not found")
 		return
 	}
 
-	// Get indexing rate
-	types.IndexingRateMu.RLock()
-	clusterRate, hasRate := types.AllIndexingRate[clusterName]
-	types.IndexingRateMu.RUnlock()
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	if !hasRate || clusterRate == nil {
+	// Get indexing rate
+	clusterRate, hasRate := types.GetIndexingRate(clusterName)
+	if !hasRate {
 		respondError(w, http.StatusNotFound, "Indexing rate data not available yet")
 		return
 	}
+	if notModified(w, r, clusterRate.Timestamp) {
+		return
+	}
 
 	// Build response
 	indices := make(map[string]interface{})
@@ -162,212 +472,2199 @@ func (s *Server) handleGetIndexingRate(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"cluster":   clusterName,
-		"timestamp": clusterRate.Timestamp,
-		"indices":   indices,
-	})
-}
-
-// handleGetStatus returns application status
-func (s *Server) handleGetStatus(w http.ResponseWriter, r *http.Request) {
-	types.ClustersMu.RLock()
-	clusterCount := len(types.AllClusters)
-	types.ClustersMu.RUnlock()
-
-	types.IndexingRateMu.RLock()
-	rateCount := len(types.AllIndexingRate)
-	types.IndexingRateMu.RUnlock()
+	// Actual spans of the windows, by snapshot time; windows without a snapshot are left out
+	windows := make(map[string]float64)
+	for window, span := range map[string]int64{
+		"last3Minutes":  clusterRate.Windows.Last3Minutes,
+		"last15Minutes": clusterRate.Windows.Last15Minutes,
+		"last60Minutes": clusterRate.Windows.Last60Minutes,
+	} {
+		if span > 0 {
+			windows[window] = float64(span) / 1000
+		}
+	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"status":       "running",
-		"clusters":     clusterCount,
-		"ratesTracked": rateCount,
-		"timestamp":    utils.TimeNowMillis(),
-	})
-}
+	response := map[string]interface{}{
+		"cluster":       clusterName,
+		"indices":       indices,
+		"windowSeconds": windows,
+	}
+	tr.put(response, "timestamp", clusterRate.Timestamp)
+	tr.annotate(response)
 
-// handleGetJobs returns job status
-func (s *Server) handleGetJobs(w http.ResponseWriter, r *http.Request) {
-	jobStatus := s.scheduler.GetJobStatus()
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"jobs": jobStatus,
-	})
+	respondJSON(w, http.StatusOK, response)
 }
 
-// handleGetStaleIndices returns indices that have not been modified in n days
-func (s *Server) handleGetStaleIndices(w http.ResponseWriter, r *http.Request) {
+// handleGetIndexingRateHistory returns the indexing rates of the last N hours as one series
+// per index base, oldest point first
+func (s *Server) handleGetIndexingRateHistory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	clusterName := vars["clusterName"]
-	daysStr := vars["days"]
 
-	// Validate cluster name
 	if !utils.ValidateClusterName(clusterName) {
 		respondError(w, http.StatusBadRequest, "Invalid cluster name")
 		return
 	}
-
-	// Parse days parameter
-	var days int
-	if _, err := fmt.Sscanf(daysStr, "%d", &days); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid days parameter, must be a positive integer")
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
 		return
 	}
 
-	if days < 1 {
-		respondError(w, http.StatusBadRequest, "Days parameter must be at least 1")
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Check if cluster exists
-	types.ClustersMu.RLock()
-	_, exists := types.AllClusters[clusterName]
-	types.ClustersMu.RUnlock()
-
-	if !exists {
-		respondError(w, http.StatusNotFound, "Cluster not found")
-		return
+	hours := 24.0
+	if value := r.URL.Query().Get("hours"); value != "" {
+		hours, err = strconv.ParseFloat(value, 64)
+		if err != nil || hours <= 0 {
+			respondError(w, http.StatusBadRequest, "Invalid hours: must be a positive number")
+			return
+		}
 	}
+	indexFilter := r.URL.Query().Get("index")
 
-	// Get stats for the cluster and make a copy (thread-safe)
-	types.StatsByDayMu.RLock()
-	clusterStats, hasStats := types.AllStatsByDay[clusterName]
-
-	if !hasStats || clusterStats == nil {
-		types.StatsByDayMu.RUnlock()
-		respondError(w, http.StatusNotFound, "Daily statistics not available for this cluster yet")
+	since := utils.TimeNowMillis() - int64(hours*float64(time.Hour/time.Millisecond))
+	rates, hasHistory := types.IndexingRateHistorySince(clusterName, since)
+	if !hasHistory {
+		respondError(w, http.StatusNotFound, "Indexing rate data not available yet")
 		return
 	}
 
-	// Check if we have enough history
-	if clusterStats.StatHistory == nil || len(clusterStats.StatHistory) == 0 {
-		types.StatsByDayMu.RUnlock()
-		respondError(w, http.StatusNotFound, "No index statistics available")
-		return
+	// One series per index base; points are only added where the index base was present
+	series := make(map[string][]map[string]interface{})
+	timestamps := make([]int64, 0, len(rates))
+	for _, clusterRate := range rates {
+		timestamps = append(timestamps, clusterRate.Timestamp)
+		for indexBase, rate := range clusterRate.MapIndices {
+			if rate == nil || (indexFilter != "" && indexBase != indexFilter) {
+				continue
+			}
+			point := map[string]interface{}{
+				"fromCreation":   rate.FromCreation,
+				"last3Minutes":   rate.Last3Minutes,
+				"last15Minutes":  rate.Last15Minutes,
+				"last60Minutes":  rate.Last60Minutes,
+				"numberOfShards": rate.NumberOfShards,
+			}
+			tr.put(point, "timestamp", clusterRate.Timestamp)
+			series[indexBase] = append(series[indexBase], point)
+		}
 	}
 
-	// Make a copy of data we need for processing (shallow copy of map entries)
-	statHistoryCopy := make(map[string]*types.IndexStatHistory, len(clusterStats.StatHistory))
-	for indexName, statHistory := range clusterStats.StatHistory {
-		statHistoryCopy[indexName] = statHistory
+	response := map[string]interface{}{
+		"cluster":        clusterName,
+		"hours":          hours,
+		"numberOfPoints": len(timestamps),
+		"timestamps":     timestamps,
+		"indices":        series,
 	}
-	lastUpdateTime := clusterStats.LastUpdateTime
+	tr.annotate(response)
 
-	// Release lock immediately after copying
-	types.StatsByDayMu.RUnlock()
+	respondJSON(w, http.StatusOK, response)
+}
 
-	// Now work on the copy without holding the lock
-	staleIndices := make([]map[string]interface{}, 0)
-	totalIndices := 0
-	insufficientData := 0
+// handleGetStatsByDayCluster returns the daily size and document count series of a cluster
+// (sum over its indices) and of each index, with day-over-day deltas
+func (s *Server) handleGetStatsByDayCluster(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
 
-	for indexName, statHistory := range statHistoryCopy {
-		totalIndices++
+	stats, tr, ok := statsByDayRequest(w, r, clusterName)
+	if !ok {
+		return
+	}
 
-		// Validate statHistory is not nil
+	// Cluster totals per day slot, over the indices with stats for that day
+	days := 0
+	indices := make(map[string]interface{}, len(stats.StatHistory))
+	for indexName, statHistory := range stats.StatHistory {
 		if statHistory == nil {
-			logger.AppWarn("StatHistory is nil for index %s in cluster %s", indexName, clusterName)
-			insufficientData++
 			continue
 		}
-
-		// Validate StatsPtr array is not nil and has enough size
-		if statHistory.StatsPtr == nil || len(statHistory.StatsPtr) <= days {
-			insufficientData++
-			continue
+		if statHistory.Stats.Cap() > days {
+			days = statHistory.Stats.Cap()
 		}
-
-		// Get current stats (StatsPtr[0])
-		currentStats := statHistory.StatsPtr[0]
-		if currentStats == nil {
-			insufficientData++
+		indices[indexName] = dailySeries(tr, statHistory.Stats.NewestFirst())
+	}
+	totals := make([]*types.IndexStat, days)
+	for _, statHistory := range stats.StatHistory {
+		if statHistory == nil {
 			continue
 		}
-
-		// Get stats from n days ago (StatsPtr[days])
-		oldStats := statHistory.StatsPtr[days]
-		if oldStats == nil {
-			// Not enough historical data yet
-			insufficientData++
-			continue
+		for day, stat := range statHistory.Stats.NewestFirst() {
+			if stat == nil {
+				continue
+			}
+			if totals[day] == nil {
+				totals[day] = &types.IndexStat{StatTime: stat.StatTime}
+			}
+			totals[day].TotalSize += stat.TotalSize
+			totals[day].DocCount += stat.DocCount
 		}
+	}
 
-		// Compare DocCount - if same, index hasn't been modified
-		if currentStats.DocCount == oldStats.DocCount {
-			staleIndices = append(staleIndices, map[string]interface{}{
-				"indexName":        indexName,
-				"docCount":         currentStats.DocCount,
-				"currentSize":      currentStats.TotalSize,
-				"currentTimestamp": currentStats.StatTime,
-				"oldSize":          oldStats.TotalSize,
-				"oldTimestamp":     oldStats.StatTime,
-				"daysStale":        days,
-				"sizeChange":       int64(currentStats.TotalSize) - int64(oldStats.TotalSize),
-			})
-		}
+	response := map[string]interface{}{
+		"cluster":    clusterName,
+		"days":       days,
+		"totals":     dailySeries(tr, totals),
+		"indices":    indices,
+		"indexCount": len(indices),
 	}
+	tr.put(response, "lastUpdateTime", stats.LastUpdateTime)
+	tr.annotate(response)
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"cluster":               clusterName,
-		"daysChecked":           days,
-		"totalIndices":          totalIndices,
-		"staleIndices":          staleIndices,
-		"staleCount":            len(staleIndices),
-		"insufficientDataCount": insufficientData,
-		"lastUpdateTime":        lastUpdateTime,
-	})
+	respondJSON(w, http.StatusOK, response)
 }
 
-// handleGetTPWQueueCluster returns thread pool write queue data for all hosts in a cluster
-func (s *Server) handleGetTPWQueueCluster(w http.ResponseWriter, r *http.Request) {
+// handleGetStatsByDayIndex returns the daily size and document count series of one index,
+// with day-over-day deltas
+func (s *Server) handleGetStatsByDayIndex(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	clusterName := vars["clusterName"]
+	indexName := vars["indexName"]
 
-	// Validate cluster name
-	if !utils.ValidateClusterName(clusterName) {
-		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+	stats, tr, ok := statsByDayRequest(w, r, clusterName)
+	if !ok {
 		return
 	}
 
-	// Check if cluster exists
-	types.ClustersMu.RLock()
-	_, exists := types.AllClusters[clusterName]
-	types.ClustersMu.RUnlock()
-
-	if !exists {
-		respondError(w, http.StatusNotFound, "Cluster not found")
+	statHistory, exists := stats.StatHistory[indexName]
+	if !exists || statHistory == nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Index %s not found in daily statistics of cluster %s", indexName, clusterName))
 		return
 	}
 
-	// Get TPWQueue data for cluster (thread-safe)
-	types.TPWQueueMu.RLock()
-	clusterData, hasData := types.AllThreadPoolWriteQueues[clusterName]
+	response := map[string]interface{}{
+		"cluster": clusterName,
+		"index":   indexName,
+		"days":    statHistory.Stats.Cap(),
+		"series":  dailySeries(tr, statHistory.Stats.NewestFirst()),
+	}
+	tr.put(response, "lastUpdateTime", stats.LastUpdateTime)
+	tr.annotate(response)
 
-	if !hasData || clusterData == nil {
-		types.TPWQueueMu.RUnlock()
-		respondError(w, http.StatusNotFound, "Thread pool write queue data not available for this cluster yet")
-		return
+	respondJSON(w, http.StatusOK, response)
+}
+
+// statsByDayRequest validates the cluster and tz of a statsByDay request and returns the
+// cluster's published daily statistics; on failure the error response has been sent
+func statsByDayRequest(w http.ResponseWriter, r *http.Request, clusterName string) (*types.IndicesStatsByDay, *timeRenderer, bool) {
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return nil, nil, false
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return nil, nil, false
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return nil, nil, false
 	}
 
-	// Make a copy of the data
-	hostnames := make([]string, len(clusterData.HostnameList))
-	copy(hostnames, clusterData.HostnameList)
+	stats, exists := types.GetStatsByDay(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Daily statistics not available for this cluster yet")
+		return nil, nil, false
+	}
+	return stats, tr, true
+}
 
-	hostsData := make(map[string]map[string]interface{})
-	for hostName, tpwq := range clusterData.HostTPWQueue {
-		if tpwq == nil {
+// dailySeries renders daily stats (slot n = n days ago) as points, oldest first. Days
+// without stats are skipped; a point has deltas only if the day before has stats.
+func dailySeries(tr *timeRenderer, newestFirst []*types.IndexStat) []map[string]interface{} {
+	series := make([]map[string]interface{}, 0, len(newestFirst))
+	for day := len(newestFirst) - 1; day >= 0; day-- {
+		stat := newestFirst[day]
+		if stat == nil {
 			continue
 		}
-
-		// Build data point arrays with only existing data
-		dataPoints := make([]map[string]interface{}, 0, tpwq.NumberOfDataPoints)
-		for i := 0; i < tpwq.NumberOfDataPoints; i++ {
-			if tpwq.DataExists[i] {
-				dataPoints = append(dataPoints, map[string]interface{}{
-					"timestamp": tpwq.TimeStamps[i],
-					"queue":     tpwq.ThreadPoolWriteQueues[i],
-					"index":     i,
-				})
+		point := map[string]interface{}{
+			"daysAgo":   day,
+			"totalSize": stat.TotalSize,
+			"docCount":  stat.DocCount,
+		}
+		tr.put(point, "statTime", stat.StatTime)
+		if day+1 < len(newestFirst) {
+			if previous := newestFirst[day+1]; previous != nil {
+				point["sizeDelta"] = int64(stat.TotalSize) - int64(previous.TotalSize)
+				point["docCountDelta"] = int64(stat.DocCount) - int64(previous.DocCount)
+			}
+		}
+		series = append(series, point)
+	}
+	return series
+}
+
+// handleGetRetention returns the retention violations and daily growth of every cluster,
+// as of the last checkRetention run
+func (s *Server) handleGetRetention(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reports := types.RetentionReports()
+	names := make([]string, 0, len(reports))
+	for clusterName := range reports {
+		if clusterVisible(r, clusterName) {
+			names = append(names, clusterName)
+		}
+	}
+	sort.Strings(names)
+
+	clusters := make([]map[string]interface{}, 0, len(names))
+	totalViolations := 0
+	for _, clusterName := range names {
+		report := reports[clusterName]
+		entry := map[string]interface{}{
+			"cluster":    clusterName,
+			"indexCount": len(report.Indices),
+			"violations": report.Violations,
+			"sizeGrowth": report.SizeGrowth,
+			"docGrowth":  report.DocGrowth,
+		}
+		tr.put(entry, "checkTime", report.CheckTime)
+		clusters = append(clusters, entry)
+		totalViolations += report.Violations
+	}
+
+	response := map[string]interface{}{
+		"clusters":   clusters,
+		"count":      len(clusters),
+		"violations": totalViolations,
+	}
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetRetentionCluster returns the retention status and daily growth of each index of a
+// cluster (?violations=true: only the violations)
+func (s *Server) handleGetRetentionCluster(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	onlyViolations := false
+	if v := r.URL.Query().Get("violations"); v != "" {
+		if onlyViolations, err = strconv.ParseBool(v); err != nil {
+			respondError(w, http.StatusBadRequest, "violations must be true or false")
+			return
+		}
+	}
+
+	report, exists := types.GetRetentionReport(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Retention report not available for this cluster yet")
+		return
+	}
+
+	indices := make([]map[string]interface{}, 0, len(report.Indices))
+	for _, index := range report.Indices {
+		if onlyViolations && !index.Violation {
+			continue
+		}
+		entry := map[string]interface{}{
+			"index":     index.Index,
+			"totalSize": index.TotalSize,
+			"docCount":  index.DocCount,
+			"violation": index.Violation,
+		}
+		tr.put(entry, "creationTime", index.CreationTime)
+		if index.DataStream != "" {
+			entry["dataStream"] = index.DataStream
+		}
+		if index.HasGrowth {
+			entry["sizeGrowth"] = index.SizeGrowth
+			entry["docGrowth"] = index.DocGrowth
+		}
+		if index.Policy != "" {
+			entry["policy"] = index.Policy
+			entry["maxAge"] = index.MaxAge
+		}
+		if index.Violation {
+			entry["overdue"] = (time.Duration(index.OverdueMs) * time.Millisecond).Round(time.Minute).String()
+			entry["overdueMs"] = index.OverdueMs
+		}
+		indices = append(indices, entry)
+	}
+
+	response := map[string]interface{}{
+		"cluster":    clusterName,
+		"indexCount": len(report.Indices),
+		"violations": report.Violations,
+		"sizeGrowth": report.SizeGrowth,
+		"docGrowth":  report.DocGrowth,
+		"indices":    indices,
+	}
+	tr.put(response, "checkTime", report.CheckTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetRemoteClusters returns the number of configured and disconnected remote clusters
+// of every cluster that has remotes, as of the last getRemoteClusters run
+func (s *Server) handleGetRemoteClusters(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	byCluster := types.RemotesByCluster()
+	names := make([]string, 0, len(byCluster))
+	for clusterName, remotes := range byCluster {
+		if len(remotes.Remotes) > 0 && clusterVisible(r, clusterName) {
+			names = append(names, clusterName)
+		}
+	}
+	sort.Strings(names)
+
+	clusters := make([]map[string]interface{}, 0, len(names))
+	totalDisconnected := 0
+	for _, clusterName := range names {
+		remotes := byCluster[clusterName]
+		disconnected := make([]string, 0)
+		for alias, remote := range remotes.Remotes {
+			if !remote.Connected {
+				disconnected = append(disconnected, alias)
+			}
+		}
+		sort.Strings(disconnected)
+		entry := map[string]interface{}{
+			"cluster":      clusterName,
+			"remotes":      len(remotes.Remotes),
+			"disconnected": disconnected,
+		}
+		tr.put(entry, "snapShotTime", remotes.SnapShotTime)
+		clusters = append(clusters, entry)
+		totalDisconnected += len(disconnected)
+	}
+
+	response := map[string]interface{}{
+		"clusters":     clusters,
+		"count":        len(clusters),
+		"disconnected": totalDisconnected,
+	}
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetRemoteClustersCluster returns the remote clusters of a cluster with their
+// connection history, disconnected ones first
+func (s *Server) handleGetRemoteClustersCluster(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	remotes, exists := types.GetRemotes(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Remote clusters not checked for this cluster yet")
+		return
+	}
+
+	sorted := make([]*types.RemoteClusterState, 0, len(remotes.Remotes))
+	for _, remote := range remotes.Remotes {
+		sorted = append(sorted, remote)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Connected != b.Connected {
+			return !a.Connected
+		}
+		if a.DisconnectedSince != b.DisconnectedSince {
+			return a.DisconnectedSince < b.DisconnectedSince
+		}
+		return a.Alias < b.Alias
+	})
+
+	list := make([]map[string]interface{}, 0, len(sorted))
+	for _, remote := range sorted {
+		changes := make([]map[string]interface{}, 0, remote.Changes.Cap())
+		for _, change := range remote.Changes.NewestFirst() {
+			if change.TimeStamp == 0 {
+				break
+			}
+			item := map[string]interface{}{"connected": change.Connected}
+			tr.put(item, "timeStamp", change.TimeStamp)
+			changes = append(changes, item)
+		}
+		item := map[string]interface{}{
+			"alias":           remote.Alias,
+			"mode":            remote.Mode,
+			"addresses":       remote.Addresses,
+			"connected":       remote.Connected,
+			"nodesConnected":  remote.NodesConnected,
+			"skipUnavailable": remote.SkipUnavailable,
+			"disconnects":     remote.Disconnects,
+			"changes":         changes,
+		}
+		tr.put(item, "firstSeen", remote.FirstSeen)
+		if remote.LastConnected != 0 {
+			tr.put(item, "lastConnected", remote.LastConnected)
+		} else {
+			item["lastConnected"] = nil
+		}
+		if remote.DisconnectedSince != 0 {
+			tr.put(item, "disconnectedSince", remote.DisconnectedSince)
+		} else {
+			item["disconnectedSince"] = nil
+		}
+		list = append(list, item)
+	}
+
+	response := map[string]interface{}{
+		"cluster": clusterName,
+		"remotes": list,
+		"count":   len(list),
+	}
+	tr.put(response, "snapShotTime", remotes.SnapShotTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetMasterHistory returns the elected master of a cluster with its transitions, newest
+// first, as tracked by updateCurrentMasterEndPoints
+func (s *Server) handleGetMasterHistory(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	history, exists := types.GetMasterHistory(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Master not looked up for this cluster yet")
+		return
+	}
+
+	hourAgo := time.Now().Add(-time.Hour).UnixMilli()
+	lastHour := 0
+	transitions := make([]map[string]interface{}, 0, history.Transitions.Cap())
+	for _, transition := range history.Transitions.NewestFirst() {
+		if transition.TimeStamp == 0 {
+			break
+		}
+		if transition.TimeStamp > hourAgo {
+			lastHour++
+		}
+		item := map[string]interface{}{"from": transition.From, "to": transition.To}
+		tr.put(item, "timeStamp", transition.TimeStamp)
+		transitions = append(transitions, item)
+	}
+
+	response := map[string]interface{}{
+		"cluster":         clusterName,
+		"master":          history.Master,
+		"changes":         history.Changes,
+		"changesLastHour": lastHour,
+		"transitions":     transitions,
+	}
+	if endpoint, ok := types.GetCurrentMasterEndpoint(clusterName); ok {
+		response["endpoint"] = endpoint
+	}
+	tr.put(response, "since", history.Since)
+	tr.put(response, "lastChecked", history.LastChecked)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// tierOrder ranks the data tiers from hot to frozen; other tiers follow by name
+var tierOrder = map[string]int{"hot": 1, "warm": 2, "cold": 3, "frozen": 4}
+
+// handleGetTiers returns the storage and write load of a cluster by data tier, as of the last
+// getDataTiers run, with each tier's share of the cluster's store and indexing rate
+func (s *Server) handleGetTiers(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	breakdown, exists := types.GetTiers(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Data tiers not collected for this cluster yet")
+		return
+	}
+
+	sorted := make([]*types.TierUsage, 0, len(breakdown.Tiers))
+	var storeBytes uint64
+	indexingRate := -1.0
+	for _, usage := range breakdown.Tiers {
+		sorted = append(sorted, usage)
+		storeBytes += usage.StoreBytes
+		if usage.IndexingRate >= 0 {
+			indexingRate = max(indexingRate, 0) + usage.IndexingRate
+		}
+	}
+	rank := func(tier string) int {
+		if order, ok := tierOrder[tier]; ok {
+			return order
+		}
+		return len(tierOrder) + 1
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if a, b := rank(sorted[i].Tier), rank(sorted[j].Tier); a != b {
+			return a < b
+		}
+		return sorted[i].Tier < sorted[j].Tier
+	})
+
+	tiers := make([]map[string]interface{}, 0, len(sorted))
+	for _, usage := range sorted {
+		item := map[string]interface{}{
+			"tier":               usage.Tier,
+			"nodes":              usage.Nodes,
+			"shards":             usage.Shards,
+			"docs":               usage.Docs,
+			"storeBytes":         usage.StoreBytes,
+			"diskTotalBytes":     usage.DiskTotalBytes,
+			"diskAvailableBytes": usage.DiskAvailableBytes,
+			"indexingRate":       usage.IndexingRate,
+		}
+		if usage.DiskTotalBytes > 0 {
+			item["diskUsedPercent"] = float64(usage.DiskTotalBytes-usage.DiskAvailableBytes) * 100 / float64(usage.DiskTotalBytes)
+		}
+		if storeBytes > 0 {
+			item["storeShare"] = float64(usage.StoreBytes) / float64(storeBytes)
+		}
+		if indexingRate > 0 && usage.IndexingRate >= 0 {
+			item["indexingShare"] = usage.IndexingRate / indexingRate
+		}
+		tiers = append(tiers, item)
+	}
+
+	response := map[string]interface{}{
+		"cluster":      clusterName,
+		"storeBytes":   storeBytes,
+		"indexingRate": indexingRate,
+		"tiers":        tiers,
+	}
+	tr.put(response, "snapShotTime", breakdown.SnapShotTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetPipelines returns the ingest pipelines of a cluster that did the most work between
+// from and to (default: the kept history), ranked by processing time, documents or failures
+func (s *Server) handleGetPipelines(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	from, err := parseTimeParam(query.Get("from"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid from: %v", err))
+		return
+	}
+	to, err := parseTimeParam(query.Get("to"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid to: %v", err))
+		return
+	}
+	sortBy := query.Get("sortBy")
+	switch sortBy {
+	case "":
+		sortBy = "time"
+	case "time", "count", "failed":
+	default:
+		respondError(w, http.StatusBadRequest, "sortBy must be time, count or failed")
+		return
+	}
+	limit := 10
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			respondError(w, http.StatusBadRequest, "Invalid limit: must be a positive integer")
+			return
+		}
+	}
+
+	stats, exists := types.GetPipelineStats(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Ingest pipeline stats not available for this cluster yet")
+		return
+	}
+
+	type pipelineWork struct {
+		pipeline              string
+		nodes                 int
+		count, timeMs, failed uint64
+		current               uint64
+		samples               int
+	}
+	work := make([]pipelineWork, 0, len(stats.Pipelines))
+	var totalTimeMs, totalCount uint64
+	windowStart, windowEnd := int64(0), int64(0)
+	for pipeline, history := range stats.Pipelines {
+		pw := pipelineWork{pipeline: pipeline, nodes: history.Nodes, current: history.Points.At(0).Current}
+		// The deltas of the first sample in the window cover the time before it
+		first := true
+		for _, point := range history.Points.OldestFirst() {
+			if point.TimeStamp == 0 || (from > 0 && point.TimeStamp < from) || (to > 0 && point.TimeStamp > to) {
+				continue
+			}
+			if windowStart == 0 || point.TimeStamp < windowStart {
+				windowStart = point.TimeStamp
+			}
+			windowEnd = max(windowEnd, point.TimeStamp)
+			if first {
+				first = false
+				continue
+			}
+			pw.count += point.CountDelta
+			pw.timeMs += point.TimeMsDelta
+			pw.failed += point.FailedDelta
+			pw.samples++
+		}
+		totalTimeMs += pw.timeMs
+		totalCount += pw.count
+		work = append(work, pw)
+	}
+
+	rank := func(pw pipelineWork) uint64 {
+		switch sortBy {
+		case "count":
+			return pw.count
+		case "failed":
+			return pw.failed
+		}
+		return pw.timeMs
+	}
+	sort.Slice(work, func(i, j int) bool {
+		if a, b := rank(work[i]), rank(work[j]); a != b {
+			return a > b
+		}
+		return work[i].pipeline < work[j].pipeline
+	})
+	if len(work) > limit {
+		work = work[:limit]
+	}
+
+	list := make([]map[string]interface{}, 0, len(work))
+	for _, pw := range work {
+		item := map[string]interface{}{
+			"pipeline":           pw.pipeline,
+			"nodes":              pw.nodes,
+			"count":              pw.count,
+			"timeMs":             pw.timeMs,
+			"failed":             pw.failed,
+			"current":            pw.current,
+			"avgTimeMsPerDoc":    0.0,
+			"failureRatePercent": 0.0,
+			"timeSharePercent":   0.0,
+		}
+		if pw.count > 0 {
+			item["avgTimeMsPerDoc"] = float64(pw.timeMs) / float64(pw.count)
+			item["failureRatePercent"] = float64(pw.failed) * 100 / float64(pw.count)
+		}
+		if totalTimeMs > 0 {
+			item["timeSharePercent"] = float64(pw.timeMs) * 100 / float64(totalTimeMs)
+		}
+		list = append(list, item)
+	}
+
+	response := map[string]interface{}{
+		"cluster":     clusterName,
+		"sortBy":      sortBy,
+		"pipelines":   list,
+		"count":       len(list),
+		"total":       len(stats.Pipelines),
+		"totalDocs":   totalCount,
+		"totalTimeMs": totalTimeMs,
+	}
+	tr.put(response, "snapShotTime", stats.SnapShotTime)
+	if windowStart != 0 {
+		tr.put(response, "from", windowStart)
+		tr.put(response, "to", windowEnd)
+	}
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetPipelineHistory returns the samples of one ingest pipeline of a cluster, oldest
+// first, with the documents, processing time and failures since the previous sample
+func (s *Server) handleGetPipelineHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	clusterName := vars["clusterName"]
+	pipeline := vars["pipeline"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats, exists := types.GetPipelineStats(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Ingest pipeline stats not available for this cluster yet")
+		return
+	}
+	history, pipelineExists := stats.Pipelines[pipeline]
+	if !pipelineExists {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Pipeline %s not found in cluster %s", pipeline, clusterName))
+		return
+	}
+
+	samples := make([]map[string]interface{}, 0, history.Points.Cap())
+	for _, point := range history.Points.OldestFirst() {
+		if point.TimeStamp == 0 {
+			continue
+		}
+		sample := map[string]interface{}{
+			"count":           point.Count,
+			"timeMs":          point.TimeMs,
+			"failed":          point.Failed,
+			"current":         point.Current,
+			"countDelta":      point.CountDelta,
+			"timeMsDelta":     point.TimeMsDelta,
+			"failedDelta":     point.FailedDelta,
+			"avgTimeMsPerDoc": 0.0,
+		}
+		if point.CountDelta > 0 {
+			sample["avgTimeMsPerDoc"] = float64(point.TimeMsDelta) / float64(point.CountDelta)
+		}
+		tr.put(sample, "timeStamp", point.TimeStamp)
+		samples = append(samples, sample)
+	}
+
+	response := map[string]interface{}{
+		"cluster":  clusterName,
+		"pipeline": pipeline,
+		"nodes":    history.Nodes,
+		"samples":  samples,
+		"count":    len(samples),
+	}
+	tr.put(response, "snapShotTime", stats.SnapShotTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetIndexFields returns the mapped field count of the indices of a cluster against their
+// total fields limit, the indices closest to their limit first, with the growth over the kept
+// history and the hosts of the cluster under write pressure for correlation
+func (s *Server) handleGetIndexFields(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	withHistory := false
+	if v := query.Get("history"); v != "" {
+		if withHistory, err = strconv.ParseBool(v); err != nil {
+			respondError(w, http.StatusBadRequest, "history must be true or false")
+			return
+		}
+	}
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			respondError(w, http.StatusBadRequest, "Invalid limit: must be a positive integer")
+			return
+		}
+	}
+
+	fieldCounts, exists := types.GetFieldCounts(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Field counts not available for this cluster yet")
+		return
+	}
+
+	pattern := query.Get("index")
+	if _, err := path.Match(pattern, ""); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid index pattern")
+		return
+	}
+	sorted := make([]*types.IndexFieldCount, 0, len(fieldCounts.Indices))
+	for _, count := range fieldCounts.Indices {
+		if matched, _ := path.Match(pattern, count.Index); pattern == "" || matched {
+			sorted = append(sorted, count)
+		}
+	}
+	usedPercent := func(count *types.IndexFieldCount) float64 {
+		if count.Limit <= 0 {
+			return 0
+		}
+		return float64(count.Fields) * 100 / float64(count.Limit)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := usedPercent(sorted[i]), usedPercent(sorted[j])
+		if a != b {
+			return a > b
+		}
+		return sorted[i].Index < sorted[j].Index
+	})
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+
+	list := make([]map[string]interface{}, 0, len(sorted))
+	for _, count := range sorted {
+		samples := make([]map[string]interface{}, 0, count.Points.Cap())
+		oldest := count.Points.At(0)
+		for _, point := range count.Points.NewestFirst() {
+			if point.TimeStamp == 0 {
+				break
+			}
+			oldest = point
+			if withHistory {
+				sample := map[string]interface{}{"fields": point.Fields}
+				tr.put(sample, "timeStamp", point.TimeStamp)
+				samples = append(samples, sample)
+			}
+		}
+		item := map[string]interface{}{
+			"index":       count.Index,
+			"fields":      count.Fields,
+			"limit":       count.Limit,
+			"usedPercent": usedPercent(count),
+			"dynamic":     count.Dynamic,
+			"growth":      count.Fields - oldest.Fields,
+		}
+		tr.put(item, "growthSince", oldest.TimeStamp)
+		if withHistory {
+			item["history"] = samples
+		}
+		list = append(list, item)
+	}
+
+	writePressureHosts := make([]string, 0)
+	for _, event := range events.Query(events.Filter{Source: "writePressure", State: events.StateFiring, Cluster: clusterName}) {
+		writePressureHosts = append(writePressureHosts, event.Labels["host"])
+	}
+	sort.Strings(writePressureHosts)
+
+	response := map[string]interface{}{
+		"cluster":            clusterName,
+		"indices":            list,
+		"count":              len(list),
+		"totalIndices":       len(fieldCounts.Indices),
+		"writePressureHosts": writePressureHosts,
+	}
+	tr.put(response, "snapShotTime", fieldCounts.SnapShotTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetSettingsDrift returns the number of settings of every cluster that drifted from
+// its baseline, as of the last checkSettingsDrift run
+func (s *Server) handleGetSettingsDrift(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reports := types.SettingsDriftReports()
+	names := make([]string, 0, len(reports))
+	for clusterName := range reports {
+		if clusterVisible(r, clusterName) {
+			names = append(names, clusterName)
+		}
+	}
+	sort.Strings(names)
+
+	clusters := make([]map[string]interface{}, 0, len(names))
+	totalDrift := 0
+	for _, clusterName := range names {
+		report := reports[clusterName]
+		clusterDrift, indexDrift := 0, 0
+		for _, drift := range report.Drift {
+			if drift.Index == "" {
+				clusterDrift++
+			} else {
+				indexDrift++
+			}
+		}
+		entry := map[string]interface{}{
+			"cluster":             clusterName,
+			"drift":               len(report.Drift),
+			"clusterSettingDrift": clusterDrift,
+			"indexSettingDrift":   indexDrift,
+		}
+		tr.put(entry, "checkTime", report.CheckTime)
+		tr.put(entry, "baselineTime", report.BaselineTime)
+		clusters = append(clusters, entry)
+		totalDrift += len(report.Drift)
+	}
+
+	response := map[string]interface{}{
+		"clusters": clusters,
+		"count":    len(clusters),
+		"drift":    totalDrift,
+	}
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetSettingsDriftCluster returns the settings of a cluster that drifted from its
+// baseline (?index= narrows the index settings to one index)
+func (s *Server) handleGetSettingsDriftCluster(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, exists := types.GetSettingsDrift(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Settings drift not checked for this cluster yet")
+		return
+	}
+	respondJSON(w, http.StatusOK, settingsDriftResponse(tr, report, r.URL.Query().Get("index")))
+}
+
+// handleAcceptSettingsBaseline makes the latest settings snapshot of a cluster its baseline
+func (s *Server) handleAcceptSettingsBaseline(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, exists := types.GetSettingsDrift(clusterName); !exists {
+		respondError(w, http.StatusNotFound, "Settings drift not checked for this cluster yet")
+		return
+	}
+	report, err := jobs.AcceptSettingsBaseline(clusterName)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, settingsDriftResponse(tr, report, ""))
+}
+
+// settingsDriftResponse renders a drift report, optionally limited to the settings of one index
+func settingsDriftResponse(tr *timeRenderer, report *types.SettingsDriftReport, index string) map[string]interface{} {
+	clusterSettings := make([]types.SettingDrift, 0)
+	indexSettings := make([]types.SettingDrift, 0)
+	for _, drift := range report.Drift {
+		switch {
+		case drift.Index == "":
+			clusterSettings = append(clusterSettings, drift)
+		case index == "" || drift.Index == index:
+			indexSettings = append(indexSettings, drift)
+		}
+	}
+
+	response := map[string]interface{}{
+		"cluster":         report.ClusterName,
+		"clusterSettings": clusterSettings,
+		"indexSettings":   indexSettings,
+		"count":           len(clusterSettings) + len(indexSettings),
+	}
+	tr.put(response, "checkTime", report.CheckTime)
+	tr.put(response, "baselineTime", report.BaselineTime)
+	tr.annotate(response)
+	return response
+}
+
+// handleGetDiskUsage returns the disk usage of every node of a cluster and how often each
+// node was beyond the watermarks within the kept history, the most frequent first
+func (s *Server) handleGetDiskUsage(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	withHistory := false
+	if v := r.URL.Query().Get("history"); v != "" {
+		if withHistory, err = strconv.ParseBool(v); err != nil {
+			respondError(w, http.StatusBadRequest, "history must be true or false")
+			return
+		}
+	}
+
+	usage, exists := types.GetDiskUsage(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Disk usage not available for this cluster yet")
+		return
+	}
+
+	type nodeEntry struct {
+		entry        map[string]interface{}
+		highBreaches int
+		usedPercent  float64
+	}
+	nodes := make([]nodeEntry, 0, len(usage.Nodes))
+	for hostName, history := range usage.Nodes {
+		latest := history.Points.At(0)
+		samples, lowBreaches, highBreaches, floodStageBreaches := 0, 0, 0, 0
+		maxUsed := 0.0
+		series := make([]map[string]interface{}, 0)
+		for _, point := range history.Points.OldestFirst() {
+			if point.TimeStamp == 0 {
+				continue
+			}
+			samples++
+			maxUsed = max(maxUsed, point.UsedPercent)
+			switch point.Level {
+			case types.DiskLevelFloodStage:
+				floodStageBreaches++
+				highBreaches++
+				lowBreaches++
+			case types.DiskLevelHigh:
+				highBreaches++
+				lowBreaches++
+			case types.DiskLevelLow:
+				lowBreaches++
+			}
+			if withHistory {
+				item := map[string]interface{}{
+					"usedPercent":    point.UsedPercent,
+					"availableBytes": point.AvailableBytes,
+					"level":          point.Level,
+				}
+				tr.put(item, "timeStamp", point.TimeStamp)
+				series = append(series, item)
+			}
+		}
+
+		entry := map[string]interface{}{
+			"hostName":           hostName,
+			"roles":              history.Roles,
+			"usedPercent":        latest.UsedPercent,
+			"totalBytes":         latest.TotalBytes,
+			"availableBytes":     latest.AvailableBytes,
+			"level":              latest.Level,
+			"samples":            samples,
+			"maxUsedPercent":     maxUsed,
+			"lowBreaches":        lowBreaches,
+			"highBreaches":       highBreaches,
+			"floodStageBreaches": floodStageBreaches,
+		}
+		if withHistory {
+			entry["history"] = series
+		}
+		nodes = append(nodes, nodeEntry{entry: entry, highBreaches: highBreaches, usedPercent: latest.UsedPercent})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].highBreaches != nodes[j].highBreaches {
+			return nodes[i].highBreaches > nodes[j].highBreaches
+		}
+		return nodes[i].usedPercent > nodes[j].usedPercent
+	})
+
+	list := make([]map[string]interface{}, 0, len(nodes))
+	for _, node := range nodes {
+		list = append(list, node.entry)
+	}
+
+	response := map[string]interface{}{
+		"cluster":     clusterName,
+		"watermarks":  usage.Watermarks,
+		"historySize": usage.HistorySize,
+		"nodes":       list,
+		"count":       len(list),
+	}
+	tr.put(response, "snapShotTime", usage.SnapShotTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetJVMStats returns the heap usage and old generation GC activity of every node of a
+// cluster within the kept history, the nodes with the most heap used first
+func (s *Server) handleGetJVMStats(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	withHistory := false
+	if v := r.URL.Query().Get("history"); v != "" {
+		if withHistory, err = strconv.ParseBool(v); err != nil {
+			respondError(w, http.StatusBadRequest, "history must be true or false")
+			return
+		}
+	}
+
+	stats, exists := types.GetJVMStats(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "JVM stats not available for this cluster yet")
+		return
+	}
+
+	type nodeEntry struct {
+		entry           map[string]interface{}
+		heapUsedPercent float64
+	}
+	nodes := make([]nodeEntry, 0, len(stats.Nodes))
+	for hostName, history := range stats.Nodes {
+		var latest, previous types.NodeJVMPoint
+		samples, missing := 0, 0
+		maxHeap := 0.0
+		var oldGCCount, oldGCTimeMs uint64
+		series := make([]map[string]interface{}, 0)
+		for _, point := range history.Points.OldestFirst() {
+			if point.TimeStamp == 0 {
+				continue
+			}
+			if !point.Exists {
+				missing++
+				if withHistory {
+					item := map[string]interface{}{"exists": false}
+					tr.put(item, "timeStamp", point.TimeStamp)
+					series = append(series, item)
+				}
+				continue
+			}
+			samples++
+			maxHeap = max(maxHeap, point.HeapUsedPercent)
+
+			// GC counters are cumulative since the node started
+			var oldGCDelta, oldGCTimeDelta uint64
+			if previous.Exists {
+				oldGCDelta = counterDelta(previous.OldGCCount, point.OldGCCount)
+				oldGCTimeDelta = counterDelta(previous.OldGCTimeMs, point.OldGCTimeMs)
+				oldGCCount += oldGCDelta
+				oldGCTimeMs += oldGCTimeDelta
+			}
+			previous, latest = point, point
+
+			if withHistory {
+				item := map[string]interface{}{
+					"exists":          true,
+					"heapUsedPercent": point.HeapUsedPercent,
+					"heapUsedBytes":   point.HeapUsedBytes,
+					"oldGCCount":      oldGCDelta,
+					"oldGCTimeMs":     oldGCTimeDelta,
+				}
+				tr.put(item, "timeStamp", point.TimeStamp)
+				series = append(series, item)
+			}
+		}
+
+		entry := map[string]interface{}{
+			"hostName":           hostName,
+			"roles":              history.Roles,
+			"heapUsedPercent":    latest.HeapUsedPercent,
+			"heapUsedBytes":      latest.HeapUsedBytes,
+			"heapMaxBytes":       latest.HeapMaxBytes,
+			"maxHeapUsedPercent": maxHeap,
+			"samples":            samples,
+			"missingSamples":     missing,
+			"oldGCCount":         oldGCCount,
+			"oldGCTimeMs":        oldGCTimeMs,
+			"oldGCCountTotal":    latest.OldGCCount,
+			"oldGCTimeMsTotal":   latest.OldGCTimeMs,
+			"youngGCCountTotal":  latest.YoungGCCount,
+			"youngGCTimeMsTotal": latest.YoungGCTimeMs,
+		}
+		tr.put(entry, "lastSeen", latest.TimeStamp)
+		if withHistory {
+			entry["history"] = series
+		}
+		nodes = append(nodes, nodeEntry{entry: entry, heapUsedPercent: latest.HeapUsedPercent})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].heapUsedPercent > nodes[j].heapUsedPercent
+	})
+
+	list := make([]map[string]interface{}, 0, len(nodes))
+	for _, node := range nodes {
+		list = append(list, node.entry)
+	}
+
+	response := map[string]interface{}{
+		"cluster":     clusterName,
+		"historySize": stats.HistorySize,
+		"nodes":       list,
+		"count":       len(list),
+	}
+	tr.put(response, "snapShotTime", stats.SnapShotTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// counterDelta is the growth of a cumulative node counter between two samples. A counter
+// that went down was reset by a node restart, so the new value is all growth.
+func counterDelta(previous, current uint64) uint64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
+
+// handleGetSegments returns the latest segments, merges and refresh sample of every node of a
+// cluster with the merge and refresh time spent over the kept history, the nodes with the
+// most segments first
+func (s *Server) handleGetSegments(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats, exists := types.GetSegmentStats(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Segment stats not available for this cluster yet")
+		return
+	}
+
+	type nodeEntry struct {
+		entry    map[string]interface{}
+		segments uint64
+	}
+	nodes := make([]nodeEntry, 0, len(stats.Nodes))
+	for hostName, history := range stats.Nodes {
+		var previous types.NodeSegmentPoint
+		var mergeTimeMs, mergeThrottledTimeMs, refreshes, refreshTimeMs uint64
+		samples := 0
+		for _, point := range history.Points.OldestFirst() {
+			if point.TimeStamp == 0 {
+				continue
+			}
+			samples++
+			if previous.TimeStamp != 0 {
+				mergeTimeMs += counterDelta(previous.MergeTimeMs, point.MergeTimeMs)
+				mergeThrottledTimeMs += counterDelta(previous.MergeThrottledTimeMs, point.MergeThrottledTimeMs)
+				refreshes += counterDelta(previous.Refreshes, point.Refreshes)
+				refreshTimeMs += counterDelta(previous.RefreshTimeMs, point.RefreshTimeMs)
+			}
+			previous = point
+		}
+
+		latest := history.Points.At(0)
+		entry := map[string]interface{}{
+			"hostName":               hostName,
+			"roles":                  history.Roles,
+			"segments":               latest.Segments,
+			"mergesCurrent":          latest.MergesCurrent,
+			"mergesCurrentDocs":      latest.MergesCurrentDocs,
+			"mergesCurrentSizeBytes": latest.MergesCurrentSizeBytes,
+			"samples":                samples,
+			"mergeTimeMs":            mergeTimeMs,
+			"mergeThrottledTimeMs":   mergeThrottledTimeMs,
+			"refreshes":              refreshes,
+			"refreshTimeMs":          refreshTimeMs,
+		}
+		nodes = append(nodes, nodeEntry{entry: entry, segments: latest.Segments})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].segments > nodes[j].segments
+	})
+
+	list := make([]map[string]interface{}, 0, len(nodes))
+	for _, node := range nodes {
+		list = append(list, node.entry)
+	}
+
+	response := map[string]interface{}{
+		"cluster":     clusterName,
+		"historySize": stats.HistorySize,
+		"nodes":       list,
+		"count":       len(list),
+	}
+	tr.put(response, "snapShotTime", stats.SnapShotTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetSegmentsHost returns the segments, merges and refresh samples of a host with the
+// thread pool write queue of the same host over the same window, so a write queue spike can
+// be matched with merge or refresh activity. Each sample carries the highest write queue
+// seen since the previous sample. The window is the kept segment history unless from/to
+// narrow it.
+func (s *Server) handleGetSegmentsHost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	clusterName := vars["clusterName"]
+	hostName := jobs.ResolveHostName(clusterName, vars["hostName"])
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	from, err := parseTimeParam(query.Get("from"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid from: %v", err))
+		return
+	}
+	to, err := parseTimeParam(query.Get("to"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid to: %v", err))
+		return
+	}
+
+	stats, exists := types.GetSegmentStats(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Segment stats not available for this cluster yet")
+		return
+	}
+	history, hostExists := stats.Nodes[hostName]
+	if !hostExists {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Host %s not found in cluster %s", hostName, clusterName))
+		return
+	}
+
+	points := make([]types.NodeSegmentPoint, 0, history.Points.Cap())
+	for _, point := range history.Points.OldestFirst() {
+		if point.TimeStamp != 0 {
+			points = append(points, point)
+		}
+	}
+	if from == 0 && len(points) > 0 {
+		from = points[0].TimeStamp
+	}
+	if to == 0 {
+		to = utils.TimeNowMillis()
+	}
+
+	// Write queue data points of the host in the window, oldest first
+	queue := make([]types.TPWPoint, 0)
+	if tpwq, ok := types.GetTPWQueue(clusterName); ok {
+		if hostQueue := tpwq.HostTPWQueue[hostName]; hostQueue != nil {
+			for _, point := range hostQueue.Points.WithData() {
+				if point.TimeStamp >= from && point.TimeStamp <= to {
+					queue = append(queue, point)
+				}
+			}
+			sort.Slice(queue, func(i, j int) bool { return queue[i].TimeStamp < queue[j].TimeStamp })
+		}
+	}
+
+	series := make([]map[string]interface{}, 0, len(points))
+	var previous types.NodeSegmentPoint
+	q := 0
+	for _, point := range points {
+		if point.TimeStamp < from || point.TimeStamp > to {
+			previous = point
+			continue
+		}
+		item := map[string]interface{}{
+			"segments":               point.Segments,
+			"mergesCurrent":          point.MergesCurrent,
+			"mergesCurrentDocs":      point.MergesCurrentDocs,
+			"mergesCurrentSizeBytes": point.MergesCurrentSizeBytes,
+		}
+		if previous.TimeStamp != 0 {
+			item["mergeTimeMs"] = counterDelta(previous.MergeTimeMs, point.MergeTimeMs)
+			item["mergeThrottledTimeMs"] = counterDelta(previous.MergeThrottledTimeMs, point.MergeThrottledTimeMs)
+			item["refreshes"] = counterDelta(previous.Refreshes, point.Refreshes)
+			item["refreshTimeMs"] = counterDelta(previous.RefreshTimeMs, point.RefreshTimeMs)
+		}
+
+		// Highest write queue since the previous sample
+		var maxQueue *uint32
+		for ; q < len(queue) && queue[q].TimeStamp <= point.TimeStamp; q++ {
+			if queue[q].TimeStamp > previous.TimeStamp && (maxQueue == nil || queue[q].Queue > *maxQueue) {
+				maxQueue = &queue[q].Queue
+			}
+		}
+		if maxQueue != nil {
+			item["maxWriteQueue"] = *maxQueue
+		} else {
+			item["maxWriteQueue"] = nil
+		}
+
+		tr.put(item, "timeStamp", point.TimeStamp)
+		series = append(series, item)
+		previous = point
+	}
+
+	writeQueue := make([]map[string]interface{}, 0, len(queue))
+	for _, point := range queue {
+		item := map[string]interface{}{"queue": point.Queue}
+		tr.put(item, "timestamp", point.TimeStamp)
+		writeQueue = append(writeQueue, item)
+	}
+
+	response := map[string]interface{}{
+		"cluster":    clusterName,
+		"hostName":   hostName,
+		"roles":      history.Roles,
+		"segments":   series,
+		"writeQueue": writeQueue,
+	}
+	tr.put(response, "from", from)
+	tr.put(response, "to", to)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetRecoveries returns the active shard recoveries of a cluster as of the last
+// getShardRecoveries run, the longest slow ones first, then the ones with the longest
+// estimated time to completion
+func (s *Server) handleGetRecoveries(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	recoveries, exists := types.GetRecoveries(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Recoveries not available for this cluster yet")
+		return
+	}
+
+	sorted := make([]*types.ShardRecovery, 0, len(recoveries.Recoveries))
+	for _, recovery := range recoveries.Recoveries {
+		sorted = append(sorted, recovery)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if (a.SlowSince != 0) != (b.SlowSince != 0) {
+			return a.SlowSince != 0
+		}
+		if a.SlowSince != b.SlowSince {
+			return a.SlowSince < b.SlowSince
+		}
+		return a.EtaMs > b.EtaMs
+	})
+
+	list := make([]map[string]interface{}, 0, len(sorted))
+	for _, recovery := range sorted {
+		recoveredPercent := 0.0
+		if recovery.BytesTotal > 0 {
+			recoveredPercent = float64(recovery.BytesRecovered) * 100 / float64(recovery.BytesTotal)
+		}
+		item := map[string]interface{}{
+			"index":            recovery.Index,
+			"shard":            recovery.Shard,
+			"type":             recovery.Type,
+			"stage":            recovery.Stage,
+			"sourceNode":       recovery.SourceNode,
+			"targetNode":       recovery.TargetNode,
+			"bytesTotal":       recovery.BytesTotal,
+			"bytesRecovered":   recovery.BytesRecovered,
+			"recoveredPercent": recoveredPercent,
+			"elapsedMs":        recovery.ElapsedMs,
+			"throughputBps":    recovery.ThroughputBps,
+			"avgThroughputBps": recovery.AvgThroughputBps,
+			"etaMs":            recovery.EtaMs,
+		}
+		tr.put(item, "firstSeen", recovery.FirstSeen)
+		if recovery.SlowSince != 0 {
+			tr.put(item, "slowSince", recovery.SlowSince)
+		} else {
+			item["slowSince"] = nil
+		}
+		if recovery.EtaMs > 0 {
+			tr.put(item, "estimatedCompletion", recovery.LastSeen+recovery.EtaMs)
+		}
+		list = append(list, item)
+	}
+
+	response := map[string]interface{}{
+		"cluster":    clusterName,
+		"recoveries": list,
+		"count":      len(list),
+	}
+	tr.put(response, "snapShotTime", recoveries.SnapShotTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetThreadPoolRejections returns the rejected executions of the thread pools of a
+// cluster within the kept history, the pools with the most rejections first. Pools without
+// rejections in the history are left out unless all=true.
+func (s *Server) handleGetThreadPoolRejections(w http.ResponseWriter, r *http.Request) {
+	clusterName := mux.Vars(r)["clusterName"]
+
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	withHistory, all := false, false
+	if v := query.Get("history"); v != "" {
+		if withHistory, err = strconv.ParseBool(v); err != nil {
+			respondError(w, http.StatusBadRequest, "history must be true or false")
+			return
+		}
+	}
+	if v := query.Get("all"); v != "" {
+		if all, err = strconv.ParseBool(v); err != nil {
+			respondError(w, http.StatusBadRequest, "all must be true or false")
+			return
+		}
+	}
+	poolFilter := query.Get("pool")
+	hostFilter := jobs.ResolveHostName(clusterName, query.Get("host"))
+
+	rejections, exists := types.GetThreadPoolRejections(clusterName)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Thread pool rejections not available for this cluster yet")
+		return
+	}
+
+	type poolEntry struct {
+		entry            map[string]interface{}
+		rejectedInWindow uint64
+		rejected         uint64
+	}
+	entries := make([]poolEntry, 0)
+	for hostName, history := range rejections.Nodes {
+		if hostFilter != "" && hostName != hostFilter {
+			continue
+		}
+		for pool, ring := range history.Pools {
+			if poolFilter != "" && pool != poolFilter {
+				continue
+			}
+			var rejectedInWindow uint64
+			samples, samplesWithRejections := 0, 0
+			series := make([]map[string]interface{}, 0)
+			for _, point := range ring.OldestFirst() {
+				if point.TimeStamp == 0 {
+					continue
+				}
+				samples++
+				rejectedInWindow += point.Delta
+				if point.Delta > 0 {
+					samplesWithRejections++
+				}
+				if withHistory {
+					item := map[string]interface{}{
+						"rejected": point.Rejected,
+						"delta":    point.Delta,
+						"queue":    point.Queue,
+						"active":   point.Active,
+					}
+					tr.put(item, "timeStamp", point.TimeStamp)
+					series = append(series, item)
+				}
+			}
+			if rejectedInWindow == 0 && !all {
+				continue
+			}
+
+			latest := ring.At(0)
+			entry := map[string]interface{}{
+				"hostName":              hostName,
+				"roles":                 history.Roles,
+				"pool":                  pool,
+				"rejected":              latest.Rejected,
+				"latestDelta":           latest.Delta,
+				"queue":                 latest.Queue,
+				"active":                latest.Active,
+				"rejectedInHistory":     rejectedInWindow,
+				"samples":               samples,
+				"samplesWithRejections": samplesWithRejections,
+			}
+			if withHistory {
+				entry["history"] = series
+			}
+			entries = append(entries, poolEntry{entry: entry, rejectedInWindow: rejectedInWindow, rejected: latest.Rejected})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].rejectedInWindow != entries[j].rejectedInWindow {
+			return entries[i].rejectedInWindow > entries[j].rejectedInWindow
+		}
+		return entries[i].rejected > entries[j].rejected
+	})
+
+	list := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry.entry)
+	}
+
+	response := map[string]interface{}{
+		"cluster":     clusterName,
+		"historySize": rejections.HistorySize,
+		"pools":       list,
+		"count":       len(list),
+	}
+	tr.put(response, "snapShotTime", rejections.SnapShotTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetStatus returns application status
+func (s *Server) handleGetStatus(w http.ResponseWriter, r *http.Request) {
+	clusterCount := len(visibleClusterNames(r))
+	rateCount := types.IndexingRateCount()
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"status":       "running",
+		"clusters":     clusterCount,
+		"ratesTracked": rateCount,
+		"timestamp":    utils.TimeNowMillis(),
+	})
+}
+
+// handleGetMemory returns the estimated memory held by each data structure
+func (s *Server) handleGetMemory(w http.ResponseWriter, r *http.Request) {
+	usages, err := memory.Collect()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to collect memory usage: %v", err))
+		return
+	}
+
+	var totalBytes int64
+	for _, usage := range usages {
+		totalBytes += usage.Bytes
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"subsystems": usages,
+		"totalBytes": totalBytes,
+		"timestamp":  utils.TimeNowMillis(),
+	})
+}
+
+// handleGetCollectionStatus returns the last success, last error and consecutive failures of
+// every collection job per cluster. Optional filters: ?job=, ?cluster= and ?failing=true
+// (only entries whose latest collection failed).
+func (s *Server) handleGetCollectionStatus(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	jobFilter := query.Get("job")
+	clusterFilter := query.Get("cluster")
+	failingOnly := query.Get("failing") == "true"
+
+	entries := make([]map[string]interface{}, 0)
+	failing := 0
+	for _, status := range types.SnapshotCollectionStatus() {
+		if jobFilter != "" && status.JobName != jobFilter {
+			continue
+		}
+		if (clusterFilter != "" && status.ClusterName != clusterFilter) || !clusterVisible(r, status.ClusterName) {
+			continue
+		}
+		if status.ConsecutiveFailures > 0 {
+			failing++
+		} else if failingOnly {
+			continue
+		}
+
+		entry := map[string]interface{}{
+			"jobName":             status.JobName,
+			"clusterName":         status.ClusterName,
+			"consecutiveFailures": status.ConsecutiveFailures,
+		}
+		tr.put(entry, "lastAttempt", status.LastAttempt)
+		tr.put(entry, "lastSuccess", status.LastSuccess)
+		tr.put(entry, "lastErrorTime", status.LastErrorTime)
+		if status.LastError != "" {
+			entry["lastError"] = status.LastError
+		}
+		entries = append(entries, entry)
+	}
+
+	response := map[string]interface{}{
+		"collections": entries,
+		"count":       len(entries),
+		"failing":     failing,
+		"timestamp":   utils.TimeNowMillis(),
+	}
+	tr.annotate(response)
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetJobs returns job status
+func (s *Server) handleGetJobs(w http.ResponseWriter, r *http.Request) {
+	jobStatus := s.scheduler.GetJobStatus()
+
+	// Tenant principals only see their tenant's jobs
+	if p := principalOf(r); p.Tenant != "" {
+		for jobName := range jobStatus {
+			if tenant, _ := s.scheduler.JobTenant(jobName); tenant != p.Tenant {
+				delete(jobStatus, jobName)
+			}
+		}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"jobs": jobStatus,
+	})
+}
+
+// handleGetSelfTelemetry returns the resource usage of the job runs, the most allocating
+// jobs first, and of the process as a whole
+func (s *Server) handleGetSelfTelemetry(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := principalOf(r)
+	jobs := make([]map[string]interface{}, 0)
+	for _, summary := range selftelemetry.Summaries() {
+		// Tenant principals only see their tenant's jobs
+		if tenant, exists := s.scheduler.JobTenant(summary.Job); !exists || (p.Tenant != "" && tenant != p.Tenant) {
+			continue
+		}
+		item := map[string]interface{}{
+			"job":             summary.Job,
+			"internalJobName": summary.InternalJobName,
+			"runs":            summary.Runs,
+			"failed":          summary.Failed,
+			"avgWallMs":       summary.AvgWallMs,
+			"maxWallMs":       summary.MaxWallMs,
+			"avgAllocBytes":   summary.AvgAllocBytes,
+			"maxAllocBytes":   summary.MaxAllocBytes,
+			"totalAllocBytes": summary.TotalAllocBytes,
+			"goroutineGrowth": summary.GoroutineGrowth,
+		}
+		tr.put(item, "lastRun", summary.LastRun)
+		jobs = append(jobs, item)
+	}
+
+	response := map[string]interface{}{
+		"enabled": selftelemetry.Enabled(),
+		"jobs":    jobs,
+		"count":   len(jobs),
+	}
+	if p.Tenant == "" {
+		response["process"] = selftelemetry.ProcessStats()
+		response["pprof"] = config.Global != nil && config.Global.SelfTelemetry.Pprof
+	}
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetSelfTelemetryJob returns the kept runs of a job, newest first
+func (s *Server) handleGetSelfTelemetryJob(w http.ResponseWriter, r *http.Request) {
+	jobName := mux.Vars(r)["jobName"]
+
+	tenant, exists := s.scheduler.JobTenant(jobName)
+	if p := principalOf(r); !exists || (p.Tenant != "" && tenant != p.Tenant) {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Job not found: %s", jobName))
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	runs := selftelemetry.Runs(jobName)
+	list := make([]map[string]interface{}, 0, len(runs))
+	for _, run := range runs {
+		item := map[string]interface{}{
+			"wallMs":          run.WallMs,
+			"failed":          run.Failed,
+			"allocBytes":      run.AllocBytes,
+			"mallocs":         run.Mallocs,
+			"gcCycles":        run.GCCycles,
+			"goroutinesStart": run.GoroutinesStart,
+			"goroutinesEnd":   run.GoroutinesEnd,
+			"heapInUseEnd":    run.HeapInUseEnd,
+			"overlapped":      run.Overlapped,
+		}
+		tr.put(item, "startTime", run.StartTime)
+		list = append(list, item)
+	}
+
+	response := map[string]interface{}{
+		"job":     jobName,
+		"enabled": selftelemetry.Enabled(),
+		"runs":    list,
+		"count":   len(list),
+	}
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetStaleIndices returns indices that have not been modified in n days
+func (s *Server) handleGetStaleIndices(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	clusterName := vars["clusterName"]
+	daysStr := vars["days"]
+
+	// Validate cluster name
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+
+	// Parse days parameter
+	var days int
+	if _, err := fmt.Sscanf(daysStr, "%d", &days); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid days parameter, must be a positive integer")
+		return
+	}
+
+	if days < 1 {
+		respondError(w, http.StatusBadRequest, "Days parameter must be at least 1")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Check if cluster exists
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	// Published statistics are read-only, so no lock or copy is needed
+	clusterStats, hasStats := types.GetStatsByDay(clusterName)
+	if !hasStats {
+		respondError(w, http.StatusNotFound, "Daily statistics not available for this cluster yet")
+		return
+	}
+
+	// Check if we have enough history
+	if len(clusterStats.StatHistory) == 0 {
+		respondError(w, http.StatusNotFound, "No index statistics available")
+		return
+	}
+
+	statHistoryCopy := clusterStats.StatHistory
+	lastUpdateTime := clusterStats.LastUpdateTime
+
+	staleIndices := make([]map[string]interface{}, 0)
+	totalIndices := 0
+	insufficientData := 0
+
+	for indexName, statHistory := range statHistoryCopy {
+		totalIndices++
+
+		// Validate statHistory is not nil
+		if statHistory == nil {
+			logger.AppWarn("StatHistory is nil for index %s in cluster %s", indexName, clusterName)
+			insufficientData++
+			continue
+		}
+
+		// Validate the history keeps enough days
+		if statHistory.Stats.Cap() <= days {
+			insufficientData++
+			continue
+		}
+
+		// Get current stats (slot 0)
+		currentStats := statHistory.Stats.At(0)
+		if currentStats == nil {
+			insufficientData++
+			continue
+		}
+
+		// Get stats from n days ago (slot days)
+		oldStats := statHistory.Stats.At(days)
+		if oldStats == nil {
+			// Not enough historical data yet
+			insufficientData++
+			continue
+		}
+
+		// Compare DocCount - if same, index hasn't been modified
+		if currentStats.DocCount == oldStats.DocCount {
+			staleIndex := map[string]interface{}{
+				"indexName":   indexName,
+				"docCount":    currentStats.DocCount,
+				"currentSize": currentStats.TotalSize,
+				"oldSize":     oldStats.TotalSize,
+				"daysStale":   days,
+				"sizeChange":  int64(currentStats.TotalSize) - int64(oldStats.TotalSize),
+			}
+			tr.put(staleIndex, "currentTimestamp", currentStats.StatTime)
+			tr.put(staleIndex, "oldTimestamp", oldStats.StatTime)
+			staleIndices = append(staleIndices, staleIndex)
+		}
+	}
+
+	response := map[string]interface{}{
+		"cluster":               clusterName,
+		"daysChecked":           days,
+		"totalIndices":          totalIndices,
+		"staleIndices":          staleIndices,
+		"staleCount":            len(staleIndices),
+		"insufficientDataCount": insufficientData,
+	}
+	tr.put(response, "lastUpdateTime", lastUpdateTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetTPWQueueCluster returns thread pool write queue data for all hosts in a cluster
+func (s *Server) handleGetTPWQueueCluster(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	clusterName := vars["clusterName"]
+
+	// Validate cluster name
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+
+	// Check if cluster exists
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resolutionMs, err := parseResolution(r.URL.Query().Get("resolution"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fill, err := parseFill(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Get published TPWQueue data for cluster (read-only)
+	clusterData, hasData := types.GetTPWQueue(clusterName)
+	if !hasData {
+		respondError(w, http.StatusNotFound, "Thread pool write queue data not available for this cluster yet")
+		return
+	}
+
+	hostnames := clusterData.HostnameList
+
+	// Filled data points of every host end with the newest bucket of the cluster
+	var newestStart int64
+	for _, tpwq := range clusterData.HostTPWQueue {
+		if tpwq != nil {
+			newestStart = max(newestStart, tpwq.NewestStart)
+		}
+	}
+
+	hostsData := make(map[string]map[string]interface{})
+	for hostName, tpwq := range clusterData.HostTPWQueue {
+		if tpwq == nil {
+			continue
+		}
+
+		// Buckets of a rollup, hosts without it have none
+		if resolutionMs > 0 {
+			rollup, buckets := tpwq.Rollup(resolutionMs), 0
+			if rollup != nil {
+				buckets = rollup.Points.Cap()
+			}
+			dataPoints := rollupDataPoints(rollup, tr, fill)
+			hostsData[hostName] = map[string]interface{}{
+				"numberOfDataPoints": buckets,
+				"dataPoints":         dataPoints,
+				"dataPointCount":     len(dataPoints),
+			}
+			continue
+		}
+
+		// Every slot when gaps are filled
+		if fill != fillNone {
+			dataPoints := filledTPWPoints(tpwq, newestStart, tr, fill)
+			hostsData[hostName] = map[string]interface{}{
+				"numberOfDataPoints": tpwq.NumberOfDataPoints,
+				"dataPoints":         dataPoints,
+				"dataPointCount":     len(dataPoints),
+			}
+			continue
+		}
+
+		// Build data point arrays with only existing data
+		dataPoints := make([]map[string]interface{}, 0, tpwq.NumberOfDataPoints)
+		for i := 0; i < tpwq.NumberOfDataPoints; i++ {
+			if dp := tpwq.Points.At(i); dp.Exists {
+				point := map[string]interface{}{
+					"queue": dp.Queue,
+					"index": i,
+				}
+				tr.put(point, "timestamp", dp.TimeStamp)
+				dataPoints = append(dataPoints, point)
 			}
 		}
 
@@ -377,131 +2674,865 @@ func (s *Server) handleGetTPWQueueCluster(w http.ResponseWriter, r *http.Request
 			"dataPointCount":     len(dataPoints),
 		}
 	}
-	types.TPWQueueMu.RUnlock()
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"cluster":   clusterName,
-		"hostnames": hostnames,
-		"hostCount": len(hostnames),
-		"hosts":     hostsData,
-	})
+	for hostName, hostData := range hostsData {
+		if reason, ok := maintenance.Decommissioning(clusterName, hostName); ok {
+			hostData["decommissioning"] = reason
+		}
+	}
+
+	response := map[string]interface{}{
+		"cluster":    clusterName,
+		"hostnames":  hostnames,
+		"hostCount":  len(hostnames),
+		"resolution": resolutionName(resolutionMs),
+		"hosts":      hostsData,
+	}
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetTPWQueueHost returns thread pool write queue data for a specific host
+func (s *Server) handleGetTPWQueueHost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	clusterName := vars["clusterName"]
+	hostName := jobs.ResolveHostName(clusterName, vars["hostName"])
+
+	// Validate cluster name
+	if !utils.ValidateClusterName(clusterName) {
+		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+		return
+	}
+
+	if hostName == "" {
+		respondError(w, http.StatusBadRequest, "Host name is required")
+		return
+	}
+
+	// Check if cluster exists
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resolutionMs, err := parseResolution(r.URL.Query().Get("resolution"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fill, err := parseFill(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Get published TPWQueue data for host (read-only)
+	clusterData, hasData := types.GetTPWQueue(clusterName)
+	if !hasData {
+		respondError(w, http.StatusNotFound, "Thread pool write queue data not available for this cluster yet")
+		return
+	}
+
+	tpwq, hostExists := clusterData.HostTPWQueue[hostName]
+	if !hostExists || tpwq == nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Host %s not found in cluster %s", hostName, clusterName))
+		return
+	}
+
+	resolutions := []string{resolutionName(0)}
+	for _, rollup := range tpwq.Rollups {
+		resolutions = append(resolutions, resolutionName(rollup.IntervalMs))
+	}
+
+	// Buckets of a rollup holding data, newest first
+	if resolutionMs > 0 {
+		rollup := tpwq.Rollup(resolutionMs)
+		if rollup == nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("No %s resolution for host %s, available: %s",
+				resolutionName(resolutionMs), hostName, strings.Join(resolutions, ", ")))
+			return
+		}
+		dataPoints := rollupDataPoints(rollup, tr, fill)
+		existingCount := 0
+		for _, point := range dataPoints {
+			if point["count"].(int) > 0 {
+				existingCount++
+			}
+		}
+		response := map[string]interface{}{
+			"cluster":            clusterName,
+			"hostName":           hostName,
+			"resolution":         resolutionName(resolutionMs),
+			"resolutions":        resolutions,
+			"numberOfDataPoints": rollup.Points.Cap(),
+			"existingCount":      existingCount,
+			"missingCount":       rollup.Points.Cap() - existingCount,
+			"dataPoints":         dataPoints,
+		}
+		if reason, ok := maintenance.Decommissioning(clusterName, hostName); ok {
+			response["decommissioning"] = reason
+		}
+		tr.annotate(response)
+		respondJSON(w, http.StatusOK, response)
+		return
+	}
+
+	// Build response with all data points
+	dataPoints := make([]map[string]interface{}, 0, tpwq.NumberOfDataPoints)
+	existingCount := 0
+	missingCount := 0
+
+	for i := 0; i < tpwq.NumberOfDataPoints; i++ {
+		dp := tpwq.Points.At(i)
+		point := map[string]interface{}{
+			"index":      i,
+			"dataExists": dp.Exists,
+		}
+
+		if dp.Exists {
+			tr.put(point, "timestamp", dp.TimeStamp)
+			point["queue"] = dp.Queue
+			existingCount++
+		} else {
+			point["timestamp"] = nil
+			point["queue"] = nil
+			missingCount++
+		}
+
+		dataPoints = append(dataPoints, point)
+	}
+	if fill != fillNone {
+		dataPoints = filledTPWPoints(tpwq, tpwq.NewestStart, tr, fill)
+	}
+
+	response := map[string]interface{}{
+		"cluster":            clusterName,
+		"hostName":           hostName,
+		"resolution":         resolutionName(0),
+		"resolutions":        resolutions,
+		"numberOfDataPoints": tpwq.NumberOfDataPoints,
+		"existingCount":      existingCount,
+		"missingCount":       missingCount,
+		"dataPoints":         dataPoints,
+	}
+	if reason, ok := maintenance.Decommissioning(clusterName, hostName); ok {
+		response["decommissioning"] = reason
+	}
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetMonitoringClusters returns the monitoring clusters of config.yaml (without their API
+// keys) with their routing rules and the clusters routed to each. Clusters no monitoring
+// cluster holds are queried with the APIEndPoints of getThreadPoolWriteQueue.
+func (s *Server) handleGetMonitoringClusters(w http.ResponseWriter, r *http.Request) {
+	routed := make(map[string][]string)
+	unrouted := make([]string, 0)
+	clusters := types.SnapshotClusters()
+	for _, clusterName := range visibleClusterNames(r) {
+		cluster, ok := clusters[clusterName]
+		if !ok {
+			continue
+		}
+		if m := jobs.MonitoringClusterOf(cluster); m != nil {
+			routed[m.Name] = append(routed[m.Name], clusterName)
+		} else {
+			unrouted = append(unrouted, clusterName)
+		}
+	}
+
+	p := principalOf(r)
+	monitoringClusters := make([]map[string]interface{}, 0, len(config.Global.MonitoringClusters))
+	for i := range config.Global.MonitoringClusters {
+		m := &config.Global.MonitoringClusters[i]
+		// Tenants only see the monitoring clusters holding data of theirs
+		if p.Tenant != "" && len(routed[m.Name]) == 0 {
+			continue
+		}
+		monitoringClusters = append(monitoringClusters, map[string]interface{}{
+			"name":        m.Name,
+			"endpoints":   m.Endpoints,
+			"insecureTls": m.InsecureTLS,
+			"profile":     jobs.MonitoringClusterProfile(m),
+			"index":       m.Index,
+			"default":     m.IsDefault(),
+			"rules": map[string]interface{}{
+				"clusters": m.Clusters,
+				"envs":     m.Envs,
+				"tenants":  m.Tenants,
+				"zones":    m.Zones,
+			},
+			"routedClusters": append(make([]string, 0), routed[m.Name]...),
+		})
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"monitoringClusters": monitoringClusters,
+		"count":              len(monitoringClusters),
+		"jobEndpoints":       unrouted,
+	})
+}
+
+// parseResolution parses the resolution parameter of the thread pool write queue endpoints:
+// empty or "raw" for the raw data points (0), else the bucket interval of a rollup in ms
+func parseResolution(value string) (int64, error) {
+	if value == "" || value == "raw" {
+		return 0, nil
+	}
+	interval, err := utils.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("Invalid resolution %q, expected raw or a rollup interval such as 5m", value)
+	}
+	return interval.Milliseconds(), nil
+}
+
+// resolutionName renders a rollup interval with the largest unit that represents it exactly,
+// e.g. "5m" or "1h"; 0 is "raw"
+func resolutionName(intervalMs int64) string {
+	if intervalMs == 0 {
+		return "raw"
+	}
+	for _, unit := range []struct {
+		name string
+		ms   int64
+	}{{"d", 86400000}, {"h", 3600000}, {"m", 60000}, {"s", 1000}} {
+		if intervalMs%unit.ms == 0 {
+			return strconv.FormatInt(intervalMs/unit.ms, 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(intervalMs, 10) + "ms"
+}
+
+// rollupDataPoints returns the buckets of a rollup holding data, newest first; a nil rollup
+// has none. When gaps are filled, every bucket since the oldest one holding data is returned,
+// with the average filled as fill asks.
+func rollupDataPoints(rollup *types.TPWRollup, tr *timeRenderer, fill string) []map[string]interface{} {
+	dataPoints := make([]map[string]interface{}, 0)
+	if rollup == nil {
+		return dataPoints
+	}
+
+	oldest := -1
+	for i := 0; i < rollup.Points.Cap(); i++ {
+		if rollup.Points.At(i).Count > 0 {
+			oldest = i
+		}
+	}
+	var averages []interface{}
+	if fill != fillNone && oldest >= 0 {
+		values := make([]float64, oldest+1)
+		exists := make([]bool, oldest+1)
+		for k := range values { // oldest first
+			bucket := rollup.Points.At(oldest - k)
+			values[k], exists[k] = bucket.Avg, bucket.Count > 0
+		}
+		averages = fillValues(values, exists, fill)
+	}
+
+	for i := 0; i <= oldest; i++ {
+		bucket := rollup.Points.At(i)
+		if bucket.Count == 0 && fill == fillNone {
+			continue
+		}
+		point := map[string]interface{}{
+			"index": i,
+			"avg":   bucket.Avg,
+			"max":   bucket.Max,
+			"count": bucket.Count,
+		}
+		if bucket.Count == 0 {
+			point["avg"], point["max"] = averages[oldest-i], nil
+			point["filled"] = averages[oldest-i] != nil
+		}
+		tr.put(point, "timestamp", rollup.NewestStart-int64(i)*rollup.IntervalMs)
+		dataPoints = append(dataPoints, point)
+	}
+	return dataPoints
+}
+
+// filledTPWPoints returns every raw data point slot of a host, newest first, with the slots
+// without data filled as fill asks. The slots are the buckets ending with the bucket starting
+// at newestStart, so hosts given the same newestStart line up; slots without data have the
+// start of their bucket as time stamp (derived from the other slots for hosts stored before
+// the data points were kept by bucket).
+func filledTPWPoints(tpwq *types.TPWQueue, newestStart int64, tr *timeRenderer, fill string) []map[string]interface{} {
+	n := tpwq.NumberOfDataPoints
+	view := tpwq.View(newestStart, n) // oldest first
+	timestamps := make([]int64, n)
+	values := make([]float64, n)
+	exists := make([]bool, n)
+	for k, dp := range view {
+		timestamps[k], values[k], exists[k] = dp.TimeStamp, float64(dp.Queue), dp.Exists
+	}
+	if tpwq.IntervalMs == 0 {
+		timestamps = fillTimestamps(timestamps, exists)
+	}
+	queues := fillValues(values, exists, fill)
+
+	dataPoints := make([]map[string]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		k := n - 1 - i
+		point := map[string]interface{}{
+			"index":      i,
+			"dataExists": exists[k],
+			"queue":      queues[k],
+		}
+		if exists[k] {
+			point["queue"] = view[k].Queue
+		} else {
+			point["filled"] = queues[k] != nil
+		}
+		if timestamps[k] != 0 {
+			tr.put(point, "timestamp", timestamps[k])
+		} else {
+			point["timestamp"] = nil
+		}
+		dataPoints = append(dataPoints, point)
+	}
+	return dataPoints
+}
+
+// handleTriggerJob manually triggers a job
+func (s *Server) handleTriggerJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobName := vars["jobName"]
+
+	err := s.scheduler.TriggerJob(jobName)
+	if errors.Is(err, scheduler.ErrBlackout) {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Failed to trigger job: %v", err))
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Failed to trigger job: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("Job %s triggered successfully", jobName),
+	})
+}
+
+// triggerGroupRequest is the body of POST /api/jobs/triggerGroup: a configured group or a list
+// of jobs
+type triggerGroupRequest struct {
+	Group           string   `json:"group"`
+	Jobs            []string `json:"jobs"`
+	ContinueOnError bool     `json:"continueOnError"` // run the remaining jobs after a failure
+}
+
+// handleTriggerJobGroup runs a job group or list of jobs one after another, e.g. the refresh
+// chain updateActiveEndpoint → updateCurrentMasterEndPoints → runCatIndices → analyseIngest,
+// and answers with the combined run report once the last job finished. The principal must be
+// allowed to trigger every job.
+func (s *Server) handleTriggerJobGroup(w http.ResponseWriter, r *http.Request) {
+	var req triggerGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	jobNames := req.Jobs
+	switch {
+	case req.Group != "" && len(req.Jobs) > 0:
+		respondError(w, http.StatusBadRequest, "Give either group or jobs, not both")
+		return
+	case req.Group != "":
+		var exists bool
+		if config.Global != nil {
+			jobNames, exists = config.Global.JobGroups[req.Group]
+		}
+		if !exists {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Job group not found: %s", req.Group))
+			return
+		}
+	case len(req.Jobs) == 0:
+		respondError(w, http.StatusBadRequest, "group or jobs is required")
+		return
+	}
+
+	p := principalOf(r)
+	for _, jobName := range jobNames {
+		if _, exists := s.scheduler.JobTenant(jobName); !exists {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Failed to trigger job: job not found: %s", jobName))
+			return
+		}
+		if status, err := s.checkJobAccess(p, jobName); err != nil {
+			respondError(w, status, err.Error())
+			return
+		}
+	}
+
+	logger.AppInfo("Principal %s triggered jobs %v", p.Name, jobNames)
+	run := s.scheduler.RunJobs(jobNames, req.ContinueOnError)
+	run.Group = req.Group
+	logger.AppInfo("Triggered jobs %v finished: %s in %dms", jobNames, run.Status, run.DurationMs)
+	respondJSON(w, http.StatusOK, run)
+}
+
+// handleGenerateOnboarding generates the CSV rows, credentials placeholder and job parameter
+// changes of a new cluster from the onboarding templates. Nothing is written: the operator
+// reviews the output and adds it to the files.
+func (s *Server) handleGenerateOnboarding(w http.ResponseWriter, r *http.Request) {
+	var req onboard.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	// Tenant principals only get the entries of their tenant's jobs
+	p := principalOf(r)
+	jobConfigs := make([]*config.JobConfig, 0)
+	for _, jobConfig := range s.scheduler.JobConfigs() {
+		if p.Tenant == "" || jobConfig.Tenant == p.Tenant {
+			jobConfigs = append(jobConfigs, jobConfig)
+		}
+	}
+
+	templateDir := ""
+	if config.Global != nil {
+		templateDir = config.Global.Onboarding.TemplateDir
+	}
+	result, err := onboard.Generate(req, templateDir, jobConfigs)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if p.seesCluster(result.Cluster) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("Cluster %s is already loaded", result.Cluster))
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// handleGetMaintenance returns the configured maintenance windows, the active silences and
+// the clusters currently in maintenance
+func (s *Server) handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := principalOf(r)
+	silences := make([]map[string]interface{}, 0)
+	for _, silence := range maintenance.Silences() {
+		if !p.seesAnyCluster(silence.Clusters) {
+			continue
+		}
+		entry := map[string]interface{}{
+			"id":       silence.ID,
+			"clusters": silence.Clusters,
+			"reason":   silence.Reason,
+		}
+		tr.put(entry, "start", silence.Start)
+		tr.put(entry, "end", silence.End)
+		silences = append(silences, entry)
+	}
+
+	now := time.Now()
+	inMaintenance := make(map[string]string)
+	for _, clusterName := range visibleClusterNames(r) {
+		if reason, ok := maintenance.InMaintenance(clusterName, now); ok {
+			inMaintenance[clusterName] = reason
+		}
+	}
+
+	windows := make([]config.MaintenanceWindow, 0, len(config.Global.MaintenanceWindows))
+	for _, window := range config.Global.MaintenanceWindows {
+		if p.seesAnyCluster(window.Clusters) {
+			windows = append(windows, window)
+		}
+	}
+
+	response := map[string]interface{}{
+		"windows":               windows,
+		"silences":              silences,
+		"clustersInMaintenance": inMaintenance,
+		"timestamp":             utils.TimeNowMillis(),
+	}
+	tr.annotate(response)
+	respondJSON(w, http.StatusOK, response)
 }
 
-// handleGetTPWQueueHost returns thread pool write queue data for a specific host
-func (s *Server) handleGetTPWQueueHost(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	clusterName := vars["clusterName"]
-	hostName := vars["hostName"]
+// silenceRequest is the body of POST /api/maintenance/silences
+type silenceRequest struct {
+	Clusters []string `json:"clusters"`
+	Hours    float64  `json:"hours"`    // either hours or duration
+	Duration string   `json:"duration"` // e.g. "90m", "2h"
+	Reason   string   `json:"reason"`
+}
 
-	// Validate cluster name
-	if !utils.ValidateClusterName(clusterName) {
-		respondError(w, http.StatusBadRequest, "Invalid cluster name")
+// handleCreateSilence starts an ad-hoc silence for the given clusters
+func (s *Server) handleCreateSilence(w http.ResponseWriter, r *http.Request) {
+	var req silenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
 		return
 	}
 
-	if hostName == "" {
-		respondError(w, http.StatusBadRequest, "Host name is required")
+	duration := time.Duration(req.Hours * float64(time.Hour))
+	if req.Duration != "" {
+		d, err := utils.ParseDuration(req.Duration)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid duration: %v", err))
+			return
+		}
+		duration = d
+	}
+
+	p := principalOf(r)
+	for _, clusterName := range req.Clusters {
+		if clusterName == "*" && p.Tenant != "" {
+			respondError(w, http.StatusForbidden, "Tenant tokens cannot silence all clusters")
+			return
+		}
+		if clusterName != "*" && !p.seesCluster(clusterName) {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Cluster not found: %s", clusterName))
+			return
+		}
+	}
+
+	silence, err := maintenance.AddSilence(req.Clusters, duration, req.Reason)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Check if cluster exists
-	types.ClustersMu.RLock()
-	_, exists := types.AllClusters[clusterName]
-	types.ClustersMu.RUnlock()
+	logger.AppInfo("Silence %s created for %v until %s: %s", silence.ID, silence.Clusters,
+		time.UnixMilli(silence.End).UTC().Format(time.RFC3339), silence.Reason)
+	respondJSON(w, http.StatusCreated, silence)
+}
 
-	if !exists {
-		respondError(w, http.StatusNotFound, "Cluster not found")
+// handleDeleteSilence ends a silence early
+func (s *Server) handleDeleteSilence(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	// Tenant principals may only end silences of their own clusters
+	if p := principalOf(r); p.Tenant != "" {
+		for _, silence := range maintenance.Silences() {
+			if silence.ID == id && !p.seesAllClusters(silence.Clusters) {
+				respondError(w, http.StatusNotFound, "Silence not found")
+				return
+			}
+		}
+	}
+
+	if !maintenance.RemoveSilence(id) {
+		respondError(w, http.StatusNotFound, "Silence not found")
 		return
 	}
 
-	// Get TPWQueue data for host (thread-safe)
-	types.TPWQueueMu.RLock()
-	clusterData, hasData := types.AllThreadPoolWriteQueues[clusterName]
+	logger.AppInfo("Silence %s removed", id)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("Silence %s removed", id),
+	})
+}
 
-	if !hasData || clusterData == nil {
-		types.TPWQueueMu.RUnlock()
-		respondError(w, http.StatusNotFound, "Thread pool write queue data not available for this cluster yet")
+// handleGetDecommissions returns the nodes being decommissioned with the shards left on them
+// at the latest check of the decommissionNodes job
+func (s *Server) handleGetDecommissions(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	tpwq, hostExists := clusterData.HostTPWQueue[hostName]
-	if !hostExists || tpwq == nil {
-		types.TPWQueueMu.RUnlock()
+	decommissions := make([]map[string]interface{}, 0)
+	for _, d := range maintenance.Decommissions() {
+		if !clusterVisible(r, d.Cluster) {
+			continue
+		}
+		entry := map[string]interface{}{
+			"cluster": d.Cluster,
+			"host":    d.Host,
+			"reason":  d.Reason,
+			"shards":  d.Shards,
+		}
+		tr.put(entry, "since", d.Since)
+		if d.CheckedAt > 0 {
+			tr.put(entry, "checkedAt", d.CheckedAt)
+		}
+		if d.Error != "" {
+			entry["error"] = d.Error
+		}
+		decommissions = append(decommissions, entry)
+	}
+
+	response := map[string]interface{}{
+		"decommissions": decommissions,
+		"count":         len(decommissions),
+		"timestamp":     utils.TimeNowMillis(),
+	}
+	tr.annotate(response)
+	respondJSON(w, http.StatusOK, response)
+}
+
+// decommissionRequest is the body of POST /api/clusters/{clusterName}/nodes/{hostName}/decommission
+type decommissionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// handleStartDecommission marks a node as decommissioning: its events are suppressed, and the
+// decommissionNodes job removes it from the inventory once its shards are gone
+func (s *Server) handleStartDecommission(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	clusterName, hostName := vars["clusterName"], vars["hostName"]
+
+	cluster, exists := types.GetCluster(clusterName)
+	if !exists || !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
+	if cluster.GetNode(hostName) == nil {
 		respondError(w, http.StatusNotFound, fmt.Sprintf("Host %s not found in cluster %s", hostName, clusterName))
 		return
 	}
 
-	// Build response with all data points
-	dataPoints := make([]map[string]interface{}, 0, tpwq.NumberOfDataPoints)
-	existingCount := 0
-	missingCount := 0
+	// The body is optional
+	var req decommissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
 
-	for i := 0; i < tpwq.NumberOfDataPoints; i++ {
-		point := map[string]interface{}{
-			"index":      i,
-			"dataExists": tpwq.DataExists[i],
-		}
+	decommission, created, err := maintenance.StartDecommission(clusterName, hostName, req.Reason)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !created {
+		respondJSON(w, http.StatusOK, decommission)
+		return
+	}
 
-		if tpwq.DataExists[i] {
-			point["timestamp"] = tpwq.TimeStamps[i]
-			point["queue"] = tpwq.ThreadPoolWriteQueues[i]
-			existingCount++
-		} else {
-			point["timestamp"] = nil
-			point["queue"] = nil
-			missingCount++
-		}
+	logger.AppInfo("Node %s of cluster %s decommissioning, started by %s: %s", hostName, clusterName, principalOf(r).Name, req.Reason)
+	respondJSON(w, http.StatusCreated, decommission)
+}
 
-		dataPoints = append(dataPoints, point)
+// handleCancelDecommission stops decommissioning a node, which is kept in the inventory
+func (s *Server) handleCancelDecommission(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	clusterName, hostName := vars["clusterName"], vars["hostName"]
+
+	if !clusterVisible(r, clusterName) || !maintenance.EndDecommission(clusterName, hostName) {
+		respondError(w, http.StatusNotFound, "Decommission not found")
+		return
 	}
-	types.TPWQueueMu.RUnlock()
 
+	logger.AppInfo("Decommission of node %s of cluster %s cancelled by %s", hostName, clusterName, principalOf(r).Name)
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"cluster":            clusterName,
-		"hostName":           hostName,
-		"numberOfDataPoints": tpwq.NumberOfDataPoints,
-		"existingCount":      existingCount,
-		"missingCount":       missingCount,
-		"dataPoints":         dataPoints,
+		"message": fmt.Sprintf("Decommission of node %s of cluster %s cancelled", hostName, clusterName),
 	})
 }
 
-// handleTriggerJob manually triggers a job
-func (s *Server) handleTriggerJob(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	jobName := vars["jobName"]
+// handleGetAlerts returns the pending and firing alerts of the rule engine, optionally
+// filtered by state, severity, cluster and rule
+func (s *Server) handleGetAlerts(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	err := s.scheduler.TriggerJob(jobName)
+	query := r.URL.Query()
+	state := query.Get("state")
+	severity := query.Get("severity")
+	clusterName := query.Get("cluster")
+	ruleName := query.Get("rule")
+	if state != "" && state != rules.StatePending && state != rules.StateFiring {
+		respondError(w, http.StatusBadRequest, "Invalid state: must be pending or firing")
+		return
+	}
+
+	alerts := make([]map[string]interface{}, 0)
+	firing := 0
+	for _, alert := range rules.Alerts() {
+		if (state != "" && alert.State != state) ||
+			(severity != "" && alert.Severity != severity) ||
+			(clusterName != "" && alert.Cluster() != clusterName) ||
+			(ruleName != "" && alert.Rule != ruleName) ||
+			!clusterVisible(r, alert.Cluster()) {
+			continue
+		}
+		if alert.State == rules.StateFiring {
+			firing++
+		}
+		entry := map[string]interface{}{
+			"rule":        alert.Rule,
+			"severity":    alert.Severity,
+			"state":       alert.State,
+			"labels":      alert.Labels,
+			"annotations": alert.Annotations,
+			"value":       alert.Value,
+			"suppressed":  alert.Suppressed,
+		}
+		tr.put(entry, "activeSince", alert.ActiveSince)
+		if alert.FiringSince > 0 {
+			tr.put(entry, "firingSince", alert.FiringSince)
+		}
+		tr.put(entry, "lastEvaluated", alert.LastEvaluated)
+		alerts = append(alerts, entry)
+	}
+
+	response := map[string]interface{}{
+		"alerts":    alerts,
+		"total":     len(alerts),
+		"firing":    firing,
+		"series":    rules.SeriesNames(),
+		"timestamp": utils.TimeNowMillis(),
+	}
+	tr.annotate(response)
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetEvents returns the events of the event store that overlap a time range,
+// optionally filtered by source, name, state, severity and cluster
+func (s *Server) handleGetEvents(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
 	if err != nil {
-		respondError(w, http.StatusNotFound, fmt.Sprintf("Failed to trigger job: %v", err))
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"message": fmt.Sprintf("Job %s triggered successfully", jobName),
-	})
+	query := r.URL.Query()
+	filter := events.Filter{
+		Source:   query.Get("source"),
+		Name:     query.Get("name"),
+		State:    query.Get("state"),
+		Severity: query.Get("severity"),
+		Cluster:  query.Get("cluster"),
+	}
+	if filter.State != "" && filter.State != events.StateFiring && filter.State != events.StateResolved {
+		respondError(w, http.StatusBadRequest, "Invalid state: must be firing or resolved")
+		return
+	}
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid from: %v", err))
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid to: %v", err))
+		return
+	}
+
+	now := time.Now()
+	list := make([]map[string]interface{}, 0)
+	firing := 0
+	for _, event := range events.Query(filter) {
+		if !clusterVisible(r, event.Cluster()) {
+			continue
+		}
+		if event.State == events.StateFiring {
+			firing++
+		}
+		list = append(list, eventEntry(tr, event, now))
+	}
+
+	response := map[string]interface{}{
+		"events":    list,
+		"count":     len(list),
+		"firing":    firing,
+		"timestamp": utils.TimeNowMillis(),
+	}
+	tr.annotate(response)
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleGetEvent returns one event by ID
+func (s *Server) handleGetEvent(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	event, ok := events.Get(mux.Vars(r)["id"])
+	if !ok || !clusterVisible(r, event.Cluster()) {
+		respondError(w, http.StatusNotFound, "Event not found")
+		return
+	}
+
+	response := eventEntry(tr, event, time.Now())
+	tr.annotate(response)
+	respondJSON(w, http.StatusOK, response)
+}
+
+// eventEntry renders an event with its duration (so far, for firing events)
+func eventEntry(tr *timeRenderer, event events.Event, now time.Time) map[string]interface{} {
+	entry := map[string]interface{}{
+		"id":                event.ID,
+		"source":            event.Source,
+		"name":              event.Name,
+		"severity":          event.Severity,
+		"state":             event.State,
+		"labels":            event.Labels,
+		"annotations":       event.Annotations,
+		"durationMs":        event.Duration(now).Milliseconds(),
+		"suppressed":        event.Suppressed,
+		"maintenanceReason": event.MaintenanceReason,
+	}
+	tr.put(entry, "startsAt", event.StartsAt)
+	if event.EndsAt > 0 {
+		tr.put(entry, "endsAt", event.EndsAt)
+	}
+	tr.put(entry, "updatedAt", event.UpdatedAt)
+	return entry
+}
+
+// parseTimeParam parses a time query parameter given as epoch milliseconds or RFC 3339;
+// an empty value is 0 (unbounded)
+func parseTimeParam(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("expected epoch milliseconds or RFC 3339, got %q", value)
+	}
+	return t.UnixMilli(), nil
 }
 
 // handleGetBulkTasksClusters returns list of clusters with bulk tasks history
 func (s *Server) handleGetBulkTasksClusters(w http.ResponseWriter, r *http.Request) {
-	types.ClusterDataWriteBulkTasksHistoryMu.RLock()
-	clusters := make([]map[string]interface{}, 0, len(types.AllClusterDataWriteBulk_sTasksHistory))
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	for clusterName, history := range types.AllClusterDataWriteBulk_sTasksHistory {
-		if history != nil {
-			clusters = append(clusters, map[string]interface{}{
-				"clusterName":        clusterName,
-				"historySize":        history.HistorySize,
-				"latestSnapshotTime": history.LatestSnapShotTime,
-			})
+	histories := types.BulkTasksHistories()
+	clusters := make([]map[string]interface{}, 0, len(histories))
+
+	for clusterName, history := range histories {
+		if history != nil && clusterVisible(r, clusterName) {
+			cluster := map[string]interface{}{
+				"clusterName": clusterName,
+				"historySize": history.HistorySize,
+				"actions":     types.TaskActions(clusterName),
+			}
+			tr.put(cluster, "latestSnapshotTime", history.LatestSnapShotTime)
+			clusters = append(clusters, cluster)
 		}
 	}
-	types.ClusterDataWriteBulkTasksHistoryMu.RUnlock()
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	response := map[string]interface{}{
 		"clusters": clusters,
 		"count":    len(clusters),
-	})
+	}
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
 }
 
 // handleGetBulkTasksHistory returns complete bulk tasks history for a cluster
@@ -514,34 +3545,43 @@ func (s *Server) handleGetBulkTasksHistory(w http.ResponseWriter, r *http.Reques
 		respondError(w, http.StatusBadRequest, "Invalid cluster name")
 		return
 	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
 
-	// Get history data (thread-safe)
-	types.ClusterDataWriteBulkTasksHistoryMu.RLock()
-	history, exists := types.AllClusterDataWriteBulk_sTasksHistory[clusterName]
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	if !exists || history == nil {
-		types.ClusterDataWriteBulkTasksHistoryMu.RUnlock()
-		respondError(w, http.StatusNotFound, "Bulk tasks history not available for this cluster yet")
+	// Get published history data (read-only)
+	action := taskAction(r)
+	history, exists := types.GetTaskActionHistory(clusterName, action)
+	if !exists {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Task history %q not available for this cluster yet", action))
 		return
 	}
 
 	// Build response with all snapshots
 	snapshots := make([]interface{}, 0, history.HistorySize)
 	for i := uint(0); i < history.HistorySize; i++ {
-		snapshot := history.PtrClusterDataWriteBulk_sTasks[i]
+		snapshot := history.PtrClusterDataWriteBulk_sTasks.At(int(i))
 		if snapshot != nil {
 			snapshots = append(snapshots, snapshot)
 		}
 	}
 
 	response := map[string]interface{}{
-		"clusterName":        history.ClusterName,
-		"historySize":        history.HistorySize,
-		"latestSnapshotTime": history.LatestSnapShotTime,
-		"snapshots":          snapshots,
-		"snapshotCount":      len(snapshots),
+		"clusterName":   history.ClusterName,
+		"action":        action,
+		"historySize":   history.HistorySize,
+		"snapshots":     snapshots,
+		"snapshotCount": len(snapshots),
 	}
-	types.ClusterDataWriteBulkTasksHistoryMu.RUnlock()
+	tr.put(response, "latestSnapshotTime", history.LatestSnapShotTime)
+	tr.annotate(response)
 
 	respondJSON(w, http.StatusOK, response)
 }
@@ -556,35 +3596,206 @@ func (s *Server) handleGetBulkTasksLatest(w http.ResponseWriter, r *http.Request
 		respondError(w, http.StatusBadRequest, "Invalid cluster name")
 		return
 	}
+	if !clusterVisible(r, clusterName) {
+		respondError(w, http.StatusNotFound, "Cluster not found")
+		return
+	}
 
-	// Get history data (thread-safe)
-	types.ClusterDataWriteBulkTasksHistoryMu.RLock()
-	history, exists := types.AllClusterDataWriteBulk_sTasksHistory[clusterName]
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	if !exists || history == nil {
-		types.ClusterDataWriteBulkTasksHistoryMu.RUnlock()
-		respondError(w, http.StatusNotFound, "Bulk tasks history not available for this cluster yet")
+	// Get published history data (read-only)
+	action := taskAction(r)
+	history, exists := types.GetTaskActionHistory(clusterName, action)
+	if !exists {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Task history %q not available for this cluster yet", action))
 		return
 	}
 
 	// Get latest snapshot (at index 0)
-	latestSnapshot := history.PtrClusterDataWriteBulk_sTasks[0]
+	latestSnapshot := history.PtrClusterDataWriteBulk_sTasks.At(0)
 	if latestSnapshot == nil {
-		types.ClusterDataWriteBulkTasksHistoryMu.RUnlock()
 		respondError(w, http.StatusNotFound, "No bulk tasks data available yet")
 		return
 	}
 
 	response := map[string]interface{}{
-		"clusterName":        clusterName,
-		"snapshot":           latestSnapshot,
-		"latestSnapshotTime": history.LatestSnapShotTime,
+		"clusterName": clusterName,
+		"action":      action,
+		"snapshot":    latestSnapshot,
+	}
+	tr.put(response, "latestSnapshotTime", history.LatestSnapShotTime)
+	tr.annotate(response)
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// taskIDRegex matches an Elasticsearch task ID, <node id>:<task number>
+var taskIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+:\d+$`)
+
+// handleGetTrackedTasks returns the tracked tasks with their progress, optionally of one
+// cluster
+func (s *Server) handleGetTrackedTasks(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	clusterName := r.URL.Query().Get("cluster")
+	list := make([]map[string]interface{}, 0)
+	running := 0
+	for _, task := range types.TrackedTasks(clusterName) {
+		if !clusterVisible(r, task.ClusterName) {
+			continue
+		}
+		if task.State == types.TaskRunning {
+			running++
+		}
+		list = append(list, trackedTaskEntry(tr, task))
 	}
-	types.ClusterDataWriteBulkTasksHistoryMu.RUnlock()
 
+	response := map[string]interface{}{
+		"tasks":     list,
+		"count":     len(list),
+		"running":   running,
+		"timestamp": utils.TimeNowMillis(),
+	}
+	tr.annotate(response)
 	respondJSON(w, http.StatusOK, response)
 }
 
+// trackTaskRequest is the body of POST /api/tasks/tracked
+type trackTaskRequest struct {
+	ClusterName string `json:"clusterName"`
+	TaskID      string `json:"taskId"` // <node id>:<task number>, as returned with wait_for_completion=false
+	Note        string `json:"note"`
+}
+
+// handleTrackTask starts tracking a task; the trackTasks job polls its progress
+func (s *Server) handleTrackTask(w http.ResponseWriter, r *http.Request) {
+	var req trackTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if !taskIDRegex.MatchString(req.TaskID) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid taskId %q, expected <node id>:<task number>", req.TaskID))
+		return
+	}
+	if _, ok := types.GetCluster(req.ClusterName); !ok || !clusterVisible(r, req.ClusterName) {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Cluster not found: %s", req.ClusterName))
+		return
+	}
+
+	task := types.TrackedTask{
+		ClusterName: req.ClusterName,
+		TaskID:      req.TaskID,
+		Note:        req.Note,
+		AddedAt:     utils.TimeNowMillis(),
+		State:       types.TaskRunning,
+		Progress:    -1,
+	}
+	if !types.TrackTask(task) {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Task %s of cluster %s is already tracked", req.TaskID, req.ClusterName))
+		return
+	}
+
+	logger.AppInfo("Tracking task %s of cluster %s: %s", task.TaskID, task.ClusterName, task.Note)
+	tr, _ := newTimeRenderer(r)
+	respondJSON(w, http.StatusCreated, trackedTaskEntry(tr, task))
+}
+
+// handleUntrackTask stops tracking a task; the task itself is left running
+func (s *Server) handleUntrackTask(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	clusterName, taskID := vars["clusterName"], vars["taskId"]
+
+	if !clusterVisible(r, clusterName) || !types.UntrackTask(clusterName, taskID) {
+		respondError(w, http.StatusNotFound, "Tracked task not found")
+		return
+	}
+
+	logger.AppInfo("Stopped tracking task %s of cluster %s", taskID, clusterName)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("Stopped tracking task %s of cluster %s", taskID, clusterName),
+	})
+}
+
+// trackedTaskEntry renders a tracked task
+func trackedTaskEntry(tr *timeRenderer, task types.TrackedTask) map[string]interface{} {
+	entry := map[string]interface{}{
+		"clusterName":   task.ClusterName,
+		"taskId":        task.TaskID,
+		"note":          task.Note,
+		"state":         task.State,
+		"action":        task.Action,
+		"description":   task.Description,
+		"runningTimeMs": task.RunningTimeMs,
+		"total":         task.Total,
+		"done":          task.Done,
+		"progress":      task.Progress,
+		"ratePerSec":    task.RatePerSec,
+		"avgRatePerSec": task.AvgRatePerSec,
+		"etaMs":         task.EtaMs,
+		"failures":      task.Failures,
+		"error":         task.Error,
+		"lastError":     task.LastError,
+	}
+	tr.put(entry, "addedAt", task.AddedAt)
+	tr.put(entry, "startTime", task.StartTime)
+	tr.put(entry, "endTime", task.EndTime)
+	tr.put(entry, "lastPolled", task.LastPolled)
+	return entry
+}
+
+// taskAction reads the action query parameter of the bulk task endpoints: the task action
+// filter of getTDataWriteBulk_sTasks whose history is returned, bulk writes by default
+func taskAction(r *http.Request) string {
+	if action := r.URL.Query().Get("action"); action != "" {
+		return action
+	}
+	return types.BulkTaskAction
+}
+
+// timeRenderer renders stored timestamps (UTC epoch milliseconds) for API responses. The
+// numeric value is always returned; with ?tz=<IANA zone> each timestamp also gets a
+// "<field>Local" RFC 3339 string in that zone.
+type timeRenderer struct {
+	loc *time.Location
+}
+
+// newTimeRenderer reads the optional tz query parameter
+func newTimeRenderer(r *http.Request) (*timeRenderer, error) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		return &timeRenderer{}, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tz parameter %q", tz)
+	}
+	return &timeRenderer{loc: loc}, nil
+}
+
+// put stores a timestamp under key and, if a zone was requested, its local rendering
+func (t *timeRenderer) put(m map[string]interface{}, key string, epochMillis int64) {
+	m[key] = epochMillis
+	if t.loc != nil && epochMillis != 0 {
+		m[key+"Local"] = time.UnixMilli(epochMillis).In(t.loc).Format(time.RFC3339)
+	}
+}
+
+// annotate records the requested zone in a top-level response
+func (t *timeRenderer) annotate(response map[string]interface{}) {
+	if t.loc != nil {
+		response["timeZone"] = t.loc.String()
+	}
+}
+
 // respondJSON sends a JSON response
 func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+
+	"ElasticObservability/pkg/logger"
+)
+
+// gzipMinBytes is the size from which responses are compressed; smaller ones are not worth it
+const gzipMinBytes = 1024
+
+// bufferedResponse holds a response until the handler has finished, so it can be answered
+// with 304 Not Modified or compressed
+type bufferedResponse struct {
+	w      http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.w.Header()
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(data)
+}
+
+// cacheAndCompress serves GET responses with an ETag and gzip Content-Encoding. Handlers can
+// set an ETag from the snapshot timestamps a response is built from (notModified), so polling
+// dashboards are answered before the response is built; other successful responses get a weak
+// ETag of their body. A request whose If-None-Match holds the ETag is answered with 304 Not
+// Modified, and responses of gzipMinBytes or more are compressed for clients accepting gzip.
+func cacheAndCompress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buffered := &bufferedResponse{w: w}
+		next.ServeHTTP(buffered, r)
+		if buffered.status == 0 {
+			buffered.status = http.StatusOK
+		}
+		body := buffered.body.Bytes()
+
+		switch buffered.status {
+		case http.StatusNotModified:
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case http.StatusOK:
+			etag := w.Header().Get("ETag")
+			if etag == "" {
+				etag = bodyETag(body)
+				w.Header().Set("ETag", etag)
+			}
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				w.Header().Del("Content-Type")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		if len(body) >= gzipMinBytes && acceptsGzip(r) {
+			var compressed bytes.Buffer
+			zw, _ := gzip.NewWriterLevel(&compressed, gzip.BestSpeed)
+			if _, err := zw.Write(body); err == nil && zw.Close() == nil {
+				w.Header().Set("Content-Encoding", "gzip")
+				body = compressed.Bytes()
+			}
+		}
+		w.WriteHeader(buffered.status)
+		if _, err := w.Write(body); err != nil {
+			logger.AppDebug("Failed to write response of %s: %v", r.URL.Path, err)
+		}
+	})
+}
+
+// notModified sets the ETag of a response from the snapshot timestamps it is built from and
+// reports whether the client already has that response, answering 304 Not Modified then. The
+// ETag also covers the path, query, API version and tenant, as they shape the response.
+func notModified(w http.ResponseWriter, r *http.Request, timestamps ...int64) bool {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s?%s|v%d|%s", r.URL.Path, r.URL.RawQuery, apiVersionOf(r), principalOf(r).Tenant)
+	for _, timestamp := range timestamps {
+		binary.Write(h, binary.BigEndian, timestamp)
+	}
+	etag := fmt.Sprintf(`W/"s%x"`, h.Sum64())
+	w.Header().Set("ETag", etag)
+
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// bodyETag is the weak ETag of a response body
+func bodyETag(body []byte) string {
+	h := fnv.New64a()
+	h.Write(body)
+	return fmt.Sprintf(`W/"b%x"`, h.Sum64())
+}
+
+// etagMatches reports whether an If-None-Match header holds the ETag or "*". Comparison is
+// weak: W/ prefixes are ignored.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether the Accept-Encoding of a request allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,568 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// queryParam documents a query parameter of an endpoint
+type queryParam struct {
+	name        string
+	kind        string // OpenAPI type: string, integer, number or boolean
+	description string
+}
+
+// routeDoc documents an endpoint for the OpenAPI document. Path parameters are taken from
+// the route template; timestamps adds the tz parameter of the time renderer.
+type routeDoc struct {
+	tag         string
+	summary     string
+	timestamps  bool
+	query       []queryParam
+	requestBody string // name of a schema in openAPISchemas
+	created     bool   // answers 201 Created instead of 200 OK
+}
+
+var (
+	historyParam = queryParam{"history", "boolean", "Include the history of each node"}
+	fromParam    = queryParam{"from", "string", "Start of the time range, epoch milliseconds or RFC 3339"}
+	toParam      = queryParam{"to", "string", "End of the time range, epoch milliseconds or RFC 3339"}
+
+	resolutionParam = queryParam{"resolution", "string", "raw (default) or the interval of a rollup, e.g. 5m or 1h"}
+	fillParam       = queryParam{"fill", "string", "Return every point, filling those without data: null, previous or linear"}
+	taskActionParam = queryParam{"action", "string", "Task action filter of getTDataWriteBulk_sTasks, e.g. deleteByQuery (default bulk)"}
+)
+
+// routeDocs documents the API endpoints by method and route template, relative to the version
+// prefix. Routes without an entry still appear in the document, with their path as summary.
+var routeDocs = map[string]routeDoc{
+	"GET /clusters":                     {tag: "Clusters", summary: "List the clusters"},
+	"GET /clusters/{clusterName}/nodes": {tag: "Clusters", summary: "List the nodes of a cluster"},
+	"GET /clusters/{clusterName}/summary": {tag: "Clusters", summary: "Index totals and ingest rate of a cluster",
+		timestamps: true},
+	"POST /clusters/{clusterName}/rename": {tag: "Clusters", summary: "Move a cluster renamed in the CMDB and its history to the new name",
+		requestBody: "RenameClusterRequest"},
+	"POST /clusters/{clusterName}/endpoints": {tag: "Clusters",
+		summary:     "Switch a cluster to a new ClusterSAN set once every new endpoint reports its UUID",
+		requestBody: "SwitchEndpointsRequest"},
+	"GET /fleet": {tag: "Clusters", summary: "Health, ingest, pressure events and collection outcome of every cluster",
+		timestamps: true},
+
+	"GET /indexingRate/{clusterName}": {tag: "Indexing Rate", summary: "Indexing rate of a cluster and its indices",
+		timestamps: true},
+	"GET /indexingRate/{clusterName}/history": {tag: "Indexing Rate", summary: "Indexing rate history of a cluster",
+		timestamps: true, query: []queryParam{
+			{"hours", "number", "Hours of history to return"},
+			{"index", "string", "Return the history of this index only"},
+		}},
+
+	"GET /statsByDay/{clusterName}":             {tag: "Daily Statistics", summary: "Daily statistics of the indices of a cluster"},
+	"GET /statsByDay/{clusterName}/{indexName}": {tag: "Daily Statistics", summary: "Daily statistics of an index", timestamps: true},
+
+	"GET /retention": {tag: "Retention", summary: "Retention compliance of all clusters", timestamps: true},
+	"GET /retention/{clusterName}": {tag: "Retention", summary: "Retention compliance of a cluster",
+		timestamps: true, query: []queryParam{
+			{"violations", "boolean", "Return only the indices violating their retention"},
+		}},
+
+	"GET /remoteClusters":               {tag: "Remote Clusters", summary: "Remote cluster connections of all clusters", timestamps: true},
+	"GET /remoteClusters/{clusterName}": {tag: "Remote Clusters", summary: "Remote cluster connections of a cluster", timestamps: true},
+
+	"GET /masterHistory/{clusterName}": {tag: "Elected Master", summary: "Elected master of a cluster and its transitions", timestamps: true},
+
+	"GET /tiers/{clusterName}": {tag: "Data Tiers", summary: "Storage and write load of a cluster by data tier", timestamps: true},
+
+	"GET /capacity": {tag: "Capacity", summary: "Capacity summary of all clusters with fleet-wide totals",
+		timestamps: true, query: []queryParam{
+			{"days", "integer", "Days the daily growth is averaged over (default 7, max 90)"},
+		}},
+	"GET /capacity/{clusterName}": {tag: "Capacity", summary: "Capacity summary of a cluster",
+		timestamps: true, query: []queryParam{
+			{"days", "integer", "Days the daily growth is averaged over (default 7, max 90)"},
+		}},
+
+	"GET /pipelines/{clusterName}": {tag: "Ingest Pipelines", summary: "Top ingest pipelines of a cluster",
+		timestamps: true, query: []queryParam{
+			fromParam, toParam,
+			{"sortBy", "string", "Sort by time (default), count or failed"},
+			{"limit", "integer", "Number of pipelines to return"},
+		}},
+	"GET /pipelines/{clusterName}/{pipeline}": {tag: "Ingest Pipelines", summary: "History of an ingest pipeline", timestamps: true},
+
+	"GET /indexFields/{clusterName}": {tag: "Index Fields", summary: "Mapped field counts of the indices of a cluster",
+		timestamps: true, query: []queryParam{
+			{"index", "string", "Return the indices matching this pattern only"},
+			{"limit", "integer", "Number of indices to return"},
+			{"history", "boolean", "Include the field count history"},
+		}},
+
+	"GET /settingsDrift": {tag: "Settings Drift", summary: "Settings drift of all clusters", timestamps: true},
+	"GET /settingsDrift/{clusterName}": {tag: "Settings Drift", summary: "Settings drift of a cluster",
+		timestamps: true, query: []queryParam{
+			{"index", "string", "Return the drift of this index only"},
+		}},
+	"POST /settingsDrift/{clusterName}/baseline": {tag: "Settings Drift", summary: "Accept the current settings of a cluster as its baseline",
+		timestamps: true},
+
+	"GET /diskUsage/{clusterName}": {tag: "Nodes", summary: "Disk usage of the nodes of a cluster",
+		timestamps: true, query: []queryParam{historyParam}},
+	"GET /jvm/{clusterName}": {tag: "Nodes", summary: "JVM heap and GC of the nodes of a cluster",
+		timestamps: true, query: []queryParam{historyParam}},
+
+	"GET /staleIndices/{clusterName}/{days}": {tag: "Stale Indices", summary: "Indices without writes for the given number of days",
+		timestamps: true},
+
+	"GET /segments/{clusterName}": {tag: "Segments", summary: "Segment and merge stats of a cluster", timestamps: true},
+	"GET /segments/{clusterName}/{hostName}": {tag: "Segments", summary: "Segment stats of a host with its write queue",
+		timestamps: true, query: []queryParam{fromParam, toParam}},
+
+	"GET /recoveries/{clusterName}": {tag: "Recoveries", summary: "Shard recoveries of a cluster", timestamps: true},
+
+	"GET /tpwqueue/{clusterName}": {tag: "Write Queue", summary: "Write thread pool queues of a cluster",
+		timestamps: true, query: []queryParam{resolutionParam, fillParam}},
+	"GET /tpwqueue/{clusterName}/{hostName}": {tag: "Write Queue", summary: "Write thread pool queue of a host",
+		timestamps: true, query: []queryParam{resolutionParam, fillParam}},
+	"POST /writePressure/{clusterName}/backtest": {tag: "Write Queue", summary: "Events candidate write pressure thresholds would have fired",
+		timestamps: true, requestBody: "WritePressureBacktestRequest"},
+	"GET /monitoringClusters": {tag: "Write Queue",
+		summary: "Monitoring clusters holding the .monitoring data and the clusters routed to each"},
+
+	"GET /threadPoolRejections/{clusterName}": {tag: "Nodes", summary: "Thread pool rejections of the nodes of a cluster",
+		timestamps: true, query: []queryParam{
+			{"host", "string", "Return this host only"},
+			{"pool", "string", "Return this thread pool only"},
+			historyParam,
+			{"all", "boolean", "Include pools without rejections"},
+		}},
+
+	"GET /bulkTasks/clusters": {tag: "Bulk Tasks", summary: "Clusters with bulk task history", timestamps: true},
+	"GET /bulkTasks/{clusterName}": {tag: "Bulk Tasks", summary: "Bulk task history of a cluster", timestamps: true,
+		query: []queryParam{taskActionParam}},
+	"GET /bulkTasks/{clusterName}/latest": {tag: "Bulk Tasks", summary: "Latest bulk task snapshot of a cluster", timestamps: true,
+		query: []queryParam{taskActionParam}},
+
+	"GET /tasks/tracked": {tag: "Tasks", summary: "Progress of the tracked tasks", timestamps: true,
+		query: []queryParam{{"cluster", "string", "Return the tasks of this cluster only"}}},
+	"POST /tasks/tracked": {tag: "Tasks", summary: "Track the progress of a task",
+		requestBody: "TrackTaskRequest", created: true},
+	"DELETE /tasks/tracked/{clusterName}/{taskId}": {tag: "Tasks", summary: "Stop tracking a task"},
+
+	"GET /remediations": {tag: "Remediations", summary: "Configured remediations and their runs", timestamps: true,
+		query: []queryParam{{"cluster", "string", "Return the runs on this cluster only"}}},
+	"POST /remediations/{name}/run": {tag: "Remediations", summary: "Run a remediation on a cluster",
+		requestBody: "RunRemediationRequest", created: true},
+	"POST /remediations/runs/{id}/rollback": {tag: "Remediations", summary: "Roll back an active remediation run now"},
+
+	"GET /status": {tag: "Status", summary: "Application status"},
+	"GET /jobs":   {tag: "Jobs", summary: "Status of the scheduled jobs"},
+	"GET /memory": {tag: "Status", summary: "Memory usage of the stored data"},
+	"GET /collectionStatus": {tag: "Status", summary: "Collection status per job and cluster",
+		timestamps: true, query: []queryParam{
+			{"cluster", "string", "Return this cluster only"},
+			{"job", "string", "Return this job only"},
+			{"failing", "boolean", "Return failing collections only"},
+		}},
+
+	"GET /debug/snapshot/{structure}/{clusterName}": {tag: "Status",
+		summary: "Raw in-memory indices history, write queues or task history of a cluster (admins only)",
+		query: []queryParam{
+			{"slot", "integer", "History slot to dump, 0 = latest (indices, bulkTasks)"},
+			{"all", "boolean", "Dump the whole history (indices, bulkTasks)"},
+			{"host", "string", "Dump this host only (tpwqueue)"},
+			taskActionParam,
+		}},
+
+	"GET /selftelemetry":           {tag: "Status", summary: "Run telemetry of all jobs", timestamps: true},
+	"GET /selftelemetry/{jobName}": {tag: "Status", summary: "Run telemetry of a job", timestamps: true},
+
+	"POST /jobs/{jobName}/trigger": {tag: "Jobs", summary: "Run a job now"},
+	"POST /jobs/triggerGroup": {tag: "Jobs", summary: "Run a job group or list of jobs one after another",
+		requestBody: "TriggerGroupRequest"},
+
+	"POST /onboarding": {tag: "Onboarding", summary: "Generate the CSV rows and job parameter changes of a new cluster",
+		requestBody: "OnboardingRequest"},
+
+	"GET /maintenance": {tag: "Maintenance", summary: "Maintenance windows and silences", timestamps: true},
+	"POST /maintenance/silences": {tag: "Maintenance", summary: "Silence clusters for a while",
+		requestBody: "SilenceRequest", created: true},
+	"DELETE /maintenance/silences/{id}": {tag: "Maintenance", summary: "End a silence early"},
+	"GET /maintenance/decommissions":    {tag: "Maintenance", summary: "Nodes being decommissioned", timestamps: true},
+	"POST /clusters/{clusterName}/nodes/{hostName}/decommission": {tag: "Maintenance",
+		summary:     "Decommission a node: suppress its events and remove it once its shards are gone",
+		requestBody: "DecommissionRequest", created: true},
+	"DELETE /clusters/{clusterName}/nodes/{hostName}/decommission": {tag: "Maintenance", summary: "Cancel the decommission of a node"},
+
+	"GET /alerts": {tag: "Alerts", summary: "Pending and firing alerts of the rule engine",
+		timestamps: true, query: []queryParam{
+			{"cluster", "string", "Return this cluster only"},
+			{"rule", "string", "Return this rule only"},
+			{"severity", "string", "Return this severity only"},
+			{"state", "string", "pending or firing"},
+		}},
+
+	"GET /events": {tag: "Events", summary: "Query the event store",
+		timestamps: true, query: []queryParam{
+			{"source", "string", "Reporting subsystem, e.g. writePressure or rules"},
+			{"name", "string", "Event name"},
+			{"state", "string", "firing or resolved"},
+			{"severity", "string", "Event severity"},
+			{"cluster", "string", "Return this cluster only"},
+			fromParam, toParam,
+		}},
+	"GET /events/{id}": {tag: "Events", summary: "Get an event", timestamps: true},
+
+	"GET /audit": {tag: "Audit", summary: "Audit log of mutating calls",
+		timestamps: true, query: []queryParam{
+			{"principal", "string", "Return this principal only"},
+			{"tenant", "string", "Return this tenant only"},
+			{"action", "string", "Return this action only"},
+			{"target", "string", "Return this target only"},
+			{"result", "string", "success or failure"},
+			fromParam, toParam,
+			{"limit", "integer", "Number of entries to return"},
+		}},
+
+	"GET /grafana": {tag: "Grafana", summary: "Connection test of the Grafana SimpleJSON datasource"},
+	"POST /grafana/search": {tag: "Grafana", summary: "Series names for the Grafana query editor",
+		requestBody: "GrafanaSearchRequest"},
+	"POST /grafana/query": {tag: "Grafana", summary: "Datapoints of series for Grafana",
+		requestBody: "GrafanaQueryRequest"},
+
+	"GET /openapi.json": {tag: "Status", summary: "This OpenAPI document"},
+}
+
+// openAPISchemas are the schemas of request bodies and errors
+var openAPISchemas = map[string]interface{}{
+	"Error": map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"error": map[string]interface{}{"type": "string"}},
+	},
+	"SilenceRequest": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"clusters": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": `Clusters to silence, "*" for all`,
+			},
+			"hours":    map[string]interface{}{"type": "number", "description": "Length of the silence in hours"},
+			"duration": map[string]interface{}{"type": "string", "description": `Length of the silence, e.g. "90m" (overrides hours)`},
+			"reason":   map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"clusters"},
+	},
+	"DecommissionRequest": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"reason": map[string]interface{}{"type": "string", "description": "Shown on the suppressed events of the node"},
+		},
+	},
+	"SwitchEndpointsRequest": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"clusterSAN": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "The new endpoints, e.g. the load balancers of the green side",
+			},
+			"activeEndpoint": map[string]interface{}{"type": "string", "description": "One of clusterSAN (default: the first)"},
+			"dryRun":         map[string]interface{}{"type": "boolean", "description": "Only validate the endpoints"},
+		},
+		"required": []string{"clusterSAN"},
+	},
+	"RenameClusterRequest": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"newName": map[string]interface{}{"type": "string", "description": "A cluster already in the inventory under this name is merged with the renamed one"},
+		},
+		"required": []string{"newName"},
+	},
+	"TrackTaskRequest": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"clusterName": map[string]interface{}{"type": "string"},
+			"taskId":      map[string]interface{}{"type": "string", "description": "<node id>:<task number>, e.g. from wait_for_completion=false"},
+			"note":        map[string]interface{}{"type": "string", "description": "Free text, e.g. the migration step"},
+		},
+		"required": []string{"clusterName", "taskId"},
+	},
+	"RunRemediationRequest": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"clusterName": map[string]interface{}{"type": "string"},
+			"dryRun":      map[string]interface{}{"type": "boolean", "description": "Only report the indices that would change"},
+		},
+		"required": []string{"clusterName"},
+	},
+	"WritePressureBacktestRequest": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"resolution": map[string]interface{}{"type": "string", "description": "raw (default) or the interval of a rollup, e.g. 5m or 1h"},
+			"aggregate":  map[string]interface{}{"type": "string", "description": "Queue of a rollup bucket: avg (default) or max"},
+			"window":     map[string]interface{}{"type": "integer", "description": "Data points a check sees, default the raw data points kept per host"},
+			"candidates": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name":       map[string]interface{}{"type": "string"},
+						"parameters": map[string]interface{}{"type": "object", "description": "checkForWritePressure parameters over those of the configured job, e.g. thresholdValue"},
+					},
+				},
+				"description": "Configurations compared with the configured job (always reported first, as current)",
+			},
+		},
+	},
+	"TriggerGroupRequest": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"group": map[string]interface{}{"type": "string", "description": "Name of a group of jobGroups"},
+			"jobs": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Jobs to run in this order, instead of a group",
+			},
+			"continueOnError": map[string]interface{}{"type": "boolean", "description": "Run the remaining jobs after a failure"},
+		},
+	},
+	"OnboardingRequest": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"cluster": map[string]interface{}{"type": "string"},
+			"hosts": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Host names or IPs of the nodes",
+			},
+			"owner":       map[string]interface{}{"type": "string"},
+			"environment": map[string]interface{}{"type": "string", "description": "Default production"},
+			"port":        map[string]interface{}{"type": "string", "description": "Default 9200"},
+			"nodeType":    map[string]interface{}{"type": "string", "description": `Roles of the nodes, default "master data"`},
+			"zone":        map[string]interface{}{"type": "string"},
+			"dataCenter":  map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"cluster", "hosts"},
+	},
+	"GrafanaSearchRequest": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"target": map[string]interface{}{"type": "string", "description": "Text the series names must contain"},
+		},
+	},
+	"GrafanaQueryRequest": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"range": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"from": map[string]interface{}{"type": "string", "description": "Epoch milliseconds or RFC3339"},
+					"to":   map[string]interface{}{"type": "string", "description": "Epoch milliseconds or RFC3339"},
+				},
+			},
+			"targets": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"target": map[string]interface{}{"type": "string", "description": `Series name, e.g. "tpwQueue:<cluster>:<host>"`},
+						"refId":  map[string]interface{}{"type": "string"},
+						"hide":   map[string]interface{}{"type": "boolean"},
+					},
+				},
+			},
+			"maxDataPoints": map[string]interface{}{"type": "integer", "description": "Datapoints to return at most per series"},
+		},
+		"required": []string{"targets"},
+	},
+}
+
+// integerPathParams are the path parameters that are numbers
+var integerPathParams = map[string]bool{"days": true}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// buildOpenAPI generates the OpenAPI 3 document of the routes of an API version
+func buildOpenAPI(router *mux.Router, version int) map[string]interface{} {
+	prefix := apiPrefix(version)
+	paths := make(map[string]map[string]interface{})
+	tags := make(map[string]bool)
+
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(template, prefix+"/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		relative := strings.TrimPrefix(template, prefix)
+		for _, method := range methods {
+			doc, ok := routeDocs[method+" "+relative]
+			if !ok {
+				doc = routeDoc{summary: relative}
+			}
+			if paths[relative] == nil {
+				paths[relative] = make(map[string]interface{})
+			}
+			paths[relative][strings.ToLower(method)] = openAPIOperation(route, method, relative, doc)
+			if doc.tag != "" {
+				tags[doc.tag] = true
+			}
+		}
+		return nil
+	})
+
+	tagList := make([]map[string]string, 0, len(tags))
+	for _, tag := range sortedKeys(tags) {
+		tagList = append(tagList, map[string]string{"name": tag})
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "ElasticObservability API",
+			"version":     fmt.Sprintf("v%d", version),
+			"description": "Metrics and state collected from the managed Elasticsearch clusters. Timestamps are UTC epoch milliseconds.",
+		},
+		"tags":  tagList,
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": openAPISchemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Required when apiTokens are configured",
+				},
+			},
+		},
+		"security": []map[string][]string{{"bearerAuth": {}}},
+	}
+}
+
+// openAPIOperation documents one method of a route
+func openAPIOperation(route *mux.Route, method, template string, doc routeDoc) map[string]interface{} {
+	parameters := make([]map[string]interface{}, 0)
+	for _, match := range pathParamPattern.FindAllStringSubmatch(template, -1) {
+		kind := "string"
+		if integerPathParams[match[1]] {
+			kind = "integer"
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]string{"type": kind},
+		})
+	}
+	query := doc.query
+	if doc.timestamps {
+		query = append(query, queryParam{"tz", "string", "IANA time zone to also render timestamps in, e.g. Asia/Kolkata"})
+	}
+	for _, param := range query {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        param.name,
+			"in":          "query",
+			"description": param.description,
+			"schema":      map[string]string{"type": param.kind},
+		})
+	}
+
+	success := "200"
+	if doc.created {
+		success = "201"
+	}
+	jsonContent := func(schema interface{}) map[string]interface{} {
+		return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+	}
+	errorResponse := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": description,
+			"content":     jsonContent(map[string]string{"$ref": "#/components/schemas/Error"}),
+		}
+	}
+	responses := map[string]interface{}{
+		success: map[string]interface{}{
+			"description": "Success",
+			"content":     jsonContent(map[string]string{"type": "object"}),
+		},
+		"400": errorResponse("Invalid parameter"),
+		"401": errorResponse("Missing or invalid bearer token"),
+		"403": errorResponse("Not permitted for this token"),
+		"404": errorResponse("Not found, or not visible to the tenant of the token"),
+	}
+
+	operation := map[string]interface{}{
+		"operationId": operationID(route, method, template),
+		"summary":     doc.summary,
+		"parameters":  parameters,
+		"responses":   responses,
+	}
+	if doc.tag != "" {
+		operation["tags"] = []string{doc.tag}
+	}
+	if doc.requestBody != "" {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(map[string]string{"$ref": "#/components/schemas/" + doc.requestBody}),
+		}
+	}
+	return operation
+}
+
+// operationID is the route name, or the name of the handler without its handle prefix, e.g.
+// getIndexingRateHistory for handleGetIndexingRateHistory
+func operationID(route *mux.Route, method, template string) string {
+	if name := route.GetName(); name != "" {
+		return name
+	}
+	if handler := route.GetHandler(); handler != nil {
+		if fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()); fn != nil {
+			name := fn.Name()
+			name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+			if rest, ok := strings.CutPrefix(name, "handle"); ok && rest != "" {
+				return strings.ToLower(rest[:1]) + rest[1:]
+			}
+		}
+	}
+	return strings.ToLower(method) + " " + template
+}
+
+func sortedKeys[V any](set map[string]V) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// handleGetOpenAPI serves the OpenAPI 3 document of the API version of the request
+func (s *Server) handleGetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	version := apiVersionOf(r)
+
+	s.openAPIMu.Lock()
+	doc, ok := s.openAPI[version]
+	if !ok {
+		doc = buildOpenAPI(s.router, version)
+		s.openAPI[version] = doc
+	}
+	s.openAPIMu.Unlock()
+
+	// The server URL depends on where the client reaches the API
+	u := publicURLOf(r)
+	response := make(map[string]interface{}, len(doc))
+	for key, value := range doc {
+		response[key] = value
+	}
+	response["servers"] = []map[string]string{{"url": u.origin + u.prefix + apiPrefix(version)}}
+	respondJSON(w, http.StatusOK, response)
+}
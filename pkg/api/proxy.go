@@ -0,0 +1,214 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ElasticObservability/pkg/config"
+)
+
+// Request headers browsers may send cross-origin besides the configured ones, and response
+// headers they may read
+var (
+	corsAllowedHeaders = []string{"Authorization", "Content-Type", "If-None-Match", apiVersionHeader}
+	corsExposedHeaders = []string{"ETag", apiVersionHeader, "Deprecation", "Link", "Sunset", "WWW-Authenticate"}
+)
+
+// publicURL is where clients reach the API: the origin (scheme://host) forwarded by a trusted
+// proxy, if any, and the path prefix in front of the routes
+type publicURL struct {
+	origin string
+	prefix string
+}
+
+type publicURLKey struct{}
+
+// publicURLOf returns where the client of a request reaches the API
+func publicURLOf(r *http.Request) publicURL {
+	if u, ok := r.Context().Value(publicURLKey{}).(publicURL); ok {
+		return u
+	}
+	return publicURL{}
+}
+
+// frontend wraps the router in what the API needs behind an ingress and for browsers: the
+// X-Forwarded-* headers of trusted proxies, the access log, the base path and CORS
+func frontend(router http.Handler, cfg config.HTTPConfig) http.Handler {
+	handler := withCORS(router, cfg.CORS)
+	handler = withBasePath(handler, cfg.BasePath)
+	handler = logRequests(handler)
+	return withForwarded(handler, parseTrustedProxies(cfg.TrustedProxies))
+}
+
+// withForwarded applies the X-Forwarded-* headers of requests from trusted proxies: the
+// client address of X-Forwarded-For (the right-most address that is not a trusted proxy)
+// becomes the remote address, X-Forwarded-Proto and -Host the public origin and
+// X-Forwarded-Prefix the path prefix the proxy stripped. Other requests' headers are ignored.
+func withForwarded(next http.Handler, trusted []*net.IPNet) http.Handler {
+	if len(trusted) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isTrustedProxy(remoteIP(r.RemoteAddr), trusted) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u := publicURLOf(r)
+		if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+			scheme := "http"
+			if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" || proto == "http" {
+				scheme = proto
+			}
+			u.origin = scheme + "://" + strings.TrimSpace(strings.Split(host, ",")[0])
+		}
+		u.prefix = strings.TrimRight(r.Header.Get("X-Forwarded-Prefix"), "/")
+
+		r = r.WithContext(context.WithValue(r.Context(), publicURLKey{}, u))
+		if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+			addresses := strings.Split(forwardedFor, ",")
+			for i := len(addresses) - 1; i >= 0; i-- {
+				ip := net.ParseIP(strings.TrimSpace(addresses[i]))
+				if ip == nil {
+					break
+				}
+				r.RemoteAddr = ip.String()
+				if !isTrustedProxy(ip, trusted) {
+					break
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withBasePath serves the routes under a path prefix; other paths are not found
+func withBasePath(next http.Handler, basePath string) http.Handler {
+	if basePath == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, basePath)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			respondError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		if rest == "" {
+			rest = "/"
+		}
+
+		u := publicURLOf(r)
+		u.prefix += basePath
+		r = r.WithContext(context.WithValue(r.Context(), publicURLKey{}, u))
+		stripped := *r.URL
+		stripped.Path = rest
+		stripped.RawPath = strings.TrimPrefix(r.URL.RawPath, basePath)
+		r.URL = &stripped
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withCORS lets the configured origins call the API from browsers: it answers preflight
+// requests itself (they carry no token) and marks the responses of allowed origins readable
+func withCORS(next http.Handler, cfg config.CORSConfig) http.Handler {
+	if len(cfg.AllowedOrigins) == 0 {
+		return next
+	}
+	allowedHeaders := strings.Join(append(append([]string{}, corsAllowedHeaders...), cfg.AllowedHeaders...), ", ")
+	exposedHeaders := strings.Join(corsExposedHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		allowed, wildcard := corsOriginAllowed(origin, cfg.AllowedOrigins)
+		if !allowed {
+			if preflight {
+				respondError(w, http.StatusForbidden, "Origin not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if wildcard && !cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsOriginAllowed reports whether an origin is allowed, and whether by "*"
+func corsOriginAllowed(origin string, allowed []string) (bool, bool) {
+	for _, candidate := range allowed {
+		if candidate == "*" {
+			return true, true
+		}
+		if strings.EqualFold(strings.TrimRight(candidate, "/"), origin) {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// parseTrustedProxies parses IPs and CIDRs; invalid entries are rejected by config loading
+func parseTrustedProxies(entries []string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			networks = append(networks, network)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+	return networks
+}
+
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns the IP of a remote address given as host:port or host
+func remoteIP(remoteAddr string) net.IP {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return net.ParseIP(host)
+}
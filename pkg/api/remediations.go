@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/remediation"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// runRemediationRequest is the body of POST /api/remediations/{name}/run
+type runRemediationRequest struct {
+	ClusterName string `json:"clusterName"`
+	DryRun      bool   `json:"dryRun"` // only report what would change; actions configured as dry runs always are
+}
+
+// mayRemediate reports whether a principal may run or roll back an action through the API:
+// only principals holding one of its roles, or anyone on an open API. Actions without roles
+// only run on events.
+func mayRemediate(p *principal, action remediation.Action) bool {
+	return p == anonymous || p.hasAnyRole(action.Roles)
+}
+
+// handleGetRemediations returns the configured remediations and the runs on the visible
+// clusters, newest first
+func (s *Server) handleGetRemediations(w http.ResponseWriter, r *http.Request) {
+	tr, err := newTimeRenderer(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	runs := make([]map[string]interface{}, 0)
+	active := 0
+	for _, run := range remediation.Runs(r.URL.Query().Get("cluster")) {
+		if !clusterVisible(r, run.Cluster) {
+			continue
+		}
+		if run.State == remediation.StateActive {
+			active++
+		}
+		runs = append(runs, remediationRunEntry(tr, run))
+	}
+
+	response := map[string]interface{}{
+		"enabled":   remediation.Enabled(),
+		"actions":   remediation.Actions(),
+		"runs":      runs,
+		"active":    active,
+		"timestamp": utils.TimeNowMillis(),
+	}
+	tr.annotate(response)
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleRunRemediation runs a remediation on a cluster; it is rolled back after its
+// rollbackAfter unless it was a dry run
+func (s *Server) handleRunRemediation(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	var req runRemediationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	action, ok := remediation.GetAction(name)
+	if !ok {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Remediation not found: %s", name))
+		return
+	}
+	if _, ok := types.GetCluster(req.ClusterName); !ok || !clusterVisible(r, req.ClusterName) {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Cluster not found: %s", req.ClusterName))
+		return
+	}
+	p := principalOf(r)
+	if !mayRemediate(p, action) {
+		logger.AppWarn("Principal %s is not permitted to run remediation %s", p.Name, name)
+		respondError(w, http.StatusForbidden, fmt.Sprintf("Not permitted to run remediation %s", name))
+		return
+	}
+
+	run, err := remediation.Execute(r.Context(), name, req.ClusterName, p.Name, req.DryRun)
+	if err != nil {
+		respondError(w, remediationStatus(err), fmt.Sprintf("Failed to run remediation %s: %v", name, err))
+		return
+	}
+	tr, _ := newTimeRenderer(r)
+	respondJSON(w, http.StatusCreated, remediationRunEntry(tr, run))
+}
+
+// handleRollbackRemediation rolls back an active remediation run before its rollback time
+func (s *Server) handleRollbackRemediation(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	run, ok := remediation.GetRun(id)
+	if !ok || !clusterVisible(r, run.Cluster) {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Remediation run not found: %s", id))
+		return
+	}
+	p := principalOf(r)
+	if action, ok := remediation.GetAction(run.Action); ok && !mayRemediate(p, action) {
+		logger.AppWarn("Principal %s is not permitted to roll back remediation %s", p.Name, run.Action)
+		respondError(w, http.StatusForbidden, fmt.Sprintf("Not permitted to roll back remediation %s", run.Action))
+		return
+	}
+
+	run, err := remediation.Rollback(r.Context(), id, p.Name)
+	if err != nil {
+		respondError(w, remediationStatus(err), fmt.Sprintf("Failed to roll back remediation run %s: %v", id, err))
+		return
+	}
+	tr, _ := newTimeRenderer(r)
+	respondJSON(w, http.StatusOK, remediationRunEntry(tr, run))
+}
+
+// remediationStatus returns the status answering a failed run or rollback
+func remediationStatus(err error) int {
+	switch {
+	case errors.Is(err, remediation.ErrUnknownAction), errors.Is(err, remediation.ErrUnknownRun):
+		return http.StatusNotFound
+	case errors.Is(err, remediation.ErrClusterDenied), errors.Is(err, remediation.ErrTooManyIndices):
+		return http.StatusBadRequest
+	case errors.Is(err, remediation.ErrDisabled), errors.Is(err, remediation.ErrAlreadyActive), errors.Is(err, remediation.ErrNotActive):
+		return http.StatusConflict
+	default:
+		return http.StatusBadGateway // the cluster rejected or did not answer the change
+	}
+}
+
+// remediationRunEntry renders a remediation run
+func remediationRunEntry(tr *timeRenderer, run remediation.Run) map[string]interface{} {
+	entry := map[string]interface{}{
+		"id":           run.ID,
+		"action":       run.Action,
+		"clusterName":  run.Cluster,
+		"setting":      run.Setting,
+		"value":        run.Value,
+		"previous":     run.Previous,
+		"indices":      len(run.Previous),
+		"triggeredBy":  run.TriggeredBy,
+		"state":        run.State,
+		"rolledBackBy": run.RolledBackBy,
+		"error":        run.Error,
+	}
+	tr.put(entry, "startedAt", run.StartedAt)
+	tr.put(entry, "rollbackAt", run.RollbackAt)
+	tr.put(entry, "endedAt", run.EndedAt)
+	return entry
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Values of the fill query parameter of the series endpoints: how points without data are
+// returned
+const (
+	fillNone     = ""         // as the endpoint always did, e.g. left out
+	fillNull     = "null"     // every point, null where there is no data
+	fillPrevious = "previous" // the last value before the gap
+	fillLinear   = "linear"   // interpolated between the values around the gap
+)
+
+// parseFill reads the fill query parameter
+func parseFill(r *http.Request) (string, error) {
+	switch fill := r.URL.Query().Get("fill"); fill {
+	case fillNone, fillNull, fillPrevious, fillLinear:
+		return fill, nil
+	default:
+		return "", fmt.Errorf("Invalid fill %q, expected null, previous or linear", fill)
+	}
+}
+
+// fillValues returns the values of a regular series, oldest first, with the points without
+// data filled as fill asks. Gaps that cannot be filled (before the first value, or after the
+// last one with linear) and every gap with fill null are nil.
+func fillValues(values []float64, exists []bool, fill string) []interface{} {
+	filled := make([]interface{}, len(values))
+	previous := -1 // index of the last point with data
+	for i := range values {
+		if exists[i] {
+			filled[i] = values[i]
+			if fill == fillLinear && previous >= 0 {
+				step := (values[i] - values[previous]) / float64(i-previous)
+				for j := previous + 1; j < i; j++ {
+					filled[j] = values[previous] + step*float64(j-previous)
+				}
+			}
+			previous = i
+			continue
+		}
+		if fill == fillPrevious && previous >= 0 {
+			filled[i] = values[previous]
+		}
+	}
+	return filled
+}
+
+// fillTimestamps sets the time stamps of the points without data of a regular series, oldest
+// first, from the spacing of the points with data. They stay 0 when fewer than two points
+// have data.
+func fillTimestamps(timestamps []int64, exists []bool) []int64 {
+	first, last := -1, -1
+	for i := range timestamps {
+		if exists[i] {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	filled := append([]int64(nil), timestamps...)
+	if first == last {
+		return filled
+	}
+	step := (timestamps[last] - timestamps[first]) / int64(last-first)
+	for i := range filled {
+		if !exists[i] {
+			filled[i] = timestamps[first] + step*int64(i-first)
+		}
+	}
+	return filled
+}
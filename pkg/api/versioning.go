@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// The API is versioned by path: /api/v1/... Payload changes that would break consumers (e.g.
+// pagination envelopes) go into a new version, registered next to v1 in setupRoutes, while
+// handlers serve the old shape to requests of the old version (apiVersionOf). The unversioned
+// /api routes of earlier releases serve the version asked for in the X-API-Version header,
+// v1 by default, and are deprecated.
+const (
+	legacyAPIPrefix  = "/api"
+	apiVersionHeader = "X-API-Version"
+)
+
+// apiVersions are the supported API versions, oldest first
+var apiVersions = []int{1}
+
+type apiVersionKey struct{}
+
+// apiPrefix returns the path prefix of an API version, e.g. /api/v1
+func apiPrefix(version int) string {
+	return fmt.Sprintf("%s/v%d", legacyAPIPrefix, version)
+}
+
+// apiVersionOf returns the API version a request is served in
+func apiVersionOf(r *http.Request) int {
+	if version, ok := r.Context().Value(apiVersionKey{}).(int); ok {
+		return version
+	}
+	return apiVersions[0]
+}
+
+// withAPIVersion serves the routes of a router in an API version
+func withAPIVersion(version int) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(apiVersionHeader, strconv.Itoa(version))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+		})
+	}
+}
+
+// negotiateLegacyVersion serves the unversioned /api routes in the version of the
+// X-API-Version header ("1" or "v1"; default v1) and marks them deprecated: Deprecation, a
+// Link to the same route under the version prefix and, when scheduled, the Sunset date.
+// An unsupported version is answered with 406 Not Acceptable.
+func negotiateLegacyVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := apiVersions[0]
+		if requested := r.Header.Get(apiVersionHeader); requested != "" {
+			parsed, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(requested), "v"))
+			if err != nil || !supportedAPIVersion(parsed) {
+				respondError(w, http.StatusNotAcceptable, fmt.Sprintf("Unsupported API version %q (supported: %s)",
+					requested, strings.Join(supportedAPIVersionNames(), ", ")))
+				return
+			}
+			version = parsed
+		}
+
+		if template, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+			metrics.APILegacyRequestsTotal.WithLabelValues(template).Inc()
+		}
+		successor := publicURLOf(r).prefix + apiPrefix(version) + strings.TrimPrefix(r.URL.Path, legacyAPIPrefix)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+		if config.Global != nil && config.Global.LegacyAPISunset != "" {
+			if sunset, err := time.Parse("2006-01-02", config.Global.LegacyAPISunset); err == nil {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+		}
+
+		withAPIVersion(version)(next).ServeHTTP(w, r)
+	})
+}
+
+func supportedAPIVersion(version int) bool {
+	for _, v := range apiVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func supportedAPIVersionNames() []string {
+	names := make([]string, len(apiVersions))
+	for i, v := range apiVersions {
+		names[i] = fmt.Sprintf("v%d", v)
+	}
+	return names
+}
@@ -0,0 +1,173 @@
+// Package audit is the audit log of mutating API calls (job triggers, silences, ...). Every
+// entry records who made the call, what was called with which parameters, when, and the
+// result.
+//
+// Entries are appended to a JSON lines file that is never rewritten, so the file is the
+// complete record for compliance review. The most recent entries are also kept in memory
+// for the /api/audit endpoint.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Entry results
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Entry is one mutating API call
+type Entry struct {
+	ID         string            `json:"id"`
+	Time       int64             `json:"time"` // epoch milliseconds (UTC)
+	Principal  string            `json:"principal"`
+	Tenant     string            `json:"tenant,omitempty"`
+	RemoteAddr string            `json:"remoteAddr,omitempty"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Action     string            `json:"action"`           // e.g. "triggerJob"
+	Target     string            `json:"target,omitempty"` // e.g. the job name or silence ID
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Status     int               `json:"status"` // HTTP status of the response
+	Result     string            `json:"result"`
+	Error      string            `json:"error,omitempty"`
+}
+
+var (
+	mu         sync.RWMutex
+	entries    []*Entry // oldest first, at most maxEntries
+	nextID     int
+	path       string // audit log file, "" = memory only
+	maxEntries = 10000
+)
+
+// Configure sets the audit log file and the number of entries kept in memory and loads the
+// most recent entries of the file (a missing file is an empty log)
+func Configure(file string, keep int) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	path = file
+	if keep > 0 {
+		maxEntries = keep
+	}
+	entries = nil
+	nextID = 0
+
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("failed to parse audit log %s line %d: %w", path, line, err)
+		}
+		if id, err := strconv.Atoi(entry.ID); err == nil && id > nextID {
+			nextID = id
+		}
+		keepEntry(&entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return nil
+}
+
+// keepEntry adds an entry to the in-memory log; callers hold mu
+func keepEntry(entry *Entry) {
+	entries = append(entries, entry)
+	if len(entries) > maxEntries {
+		entries = entries[len(entries)-maxEntries:]
+	}
+}
+
+// Record assigns the entry an ID, appends it to the audit log file and keeps it in memory.
+// The entry is kept even if the file cannot be written.
+func Record(entry Entry) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	nextID++
+	entry.ID = strconv.Itoa(nextID)
+	keepEntry(&entry)
+
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// Filter selects entries; zero fields match everything
+type Filter struct {
+	Principal string
+	Tenant    string
+	Action    string
+	Target    string
+	Result    string
+	From      int64 // epoch milliseconds (UTC)
+	To        int64 // epoch milliseconds (UTC)
+	Limit     int   // most recent entries returned, 0 = all
+}
+
+// matches reports whether an entry passes the filter
+func (f Filter) matches(entry *Entry) bool {
+	return (f.Principal == "" || entry.Principal == f.Principal) &&
+		(f.Tenant == "" || entry.Tenant == f.Tenant) &&
+		(f.Action == "" || entry.Action == f.Action) &&
+		(f.Target == "" || entry.Target == f.Target) &&
+		(f.Result == "" || entry.Result == f.Result) &&
+		(f.From == 0 || entry.Time >= f.From) &&
+		(f.To == 0 || entry.Time <= f.To)
+}
+
+// Query returns copies of the in-memory entries passing the filter, newest first
+func Query(f Filter) []Entry {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]Entry, 0)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if f.Limit > 0 && len(result) >= f.Limit {
+			break
+		}
+		if f.matches(entries[i]) {
+			result = append(result, *entries[i])
+		}
+	}
+	return result
+}
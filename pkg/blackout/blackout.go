@@ -0,0 +1,297 @@
+// Package blackout decides whether a job may run now or is held by a blackout calendar
+// (holidays, change freezes). Jobs opt in by naming calendars in their blackoutCalendars, and
+// jobs marked mutating honor every calendar with mutatingJobs set.
+//
+// Calendars come from the blackoutCalendars section of config.yaml: days listed in the
+// configuration and the events of an iCalendar feed, fetched at startup and refreshed in the
+// background. A feed that cannot be fetched keeps its last known events.
+package blackout
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/utils"
+)
+
+// defaultRefreshInterval is how often iCalendar feeds are fetched unless configured
+const defaultRefreshInterval = 6 * time.Hour
+
+// maxFeedBytes bounds the size of an iCalendar feed
+const maxFeedBytes = 10 << 20
+
+// feedClient fetches iCalendar feeds
+var feedClient = &http.Client{Timeout: 30 * time.Second}
+
+// period is a blackout period, end excluded
+type period struct {
+	start   time.Time
+	end     time.Time
+	summary string
+}
+
+// calendar is a parsed blackout calendar
+type calendar struct {
+	name         string
+	mutatingJobs bool
+	dates        []period // from the configuration
+	url          string
+	refresh      time.Duration
+	feed         []period // from the iCalendar feed
+	fetched      time.Time
+	refreshing   bool
+}
+
+var (
+	mu        sync.Mutex
+	calendars = make(map[string]*calendar)
+	location  = time.UTC
+)
+
+// Configure parses the configured blackout calendars, with days in loc, and fetches their
+// feeds. A feed that cannot be fetched is logged and retried at the next refresh.
+func Configure(configured []config.BlackoutCalendar, loc *time.Location) error {
+	parsed := make(map[string]*calendar, len(configured))
+	for i, cfg := range configured {
+		if cfg.Name == "" {
+			return fmt.Errorf("blackoutCalendars[%d]: name is required", i)
+		}
+		if _, exists := parsed[cfg.Name]; exists {
+			return fmt.Errorf("blackoutCalendars[%d]: calendar %s is defined twice", i, cfg.Name)
+		}
+		c, err := parseCalendar(cfg, loc)
+		if err != nil {
+			return fmt.Errorf("blackoutCalendars[%d] (%s): %w", i, cfg.Name, err)
+		}
+		parsed[cfg.Name] = c
+	}
+
+	for _, c := range parsed {
+		if c.url == "" {
+			continue
+		}
+		feed, err := fetchFeed(c.url, loc)
+		c.fetched = time.Now()
+		if err != nil {
+			logger.AppError("Failed to fetch blackout calendar %s: %v", c.name, err)
+			continue
+		}
+		c.feed = feed
+		logger.AppInfo("Blackout calendar %s: %d events from %s", c.name, len(feed), c.url)
+	}
+
+	mu.Lock()
+	calendars = parsed
+	location = loc
+	mu.Unlock()
+	return nil
+}
+
+func parseCalendar(cfg config.BlackoutCalendar, loc *time.Location) (*calendar, error) {
+	c := &calendar{name: cfg.Name, mutatingJobs: cfg.MutatingJobs, url: cfg.URL, refresh: defaultRefreshInterval}
+	if len(cfg.Dates) == 0 && cfg.URL == "" {
+		return nil, fmt.Errorf("dates or url is required")
+	}
+	if cfg.RefreshInterval != "" {
+		refresh, err := utils.ParseDuration(cfg.RefreshInterval)
+		if err != nil || refresh < time.Minute {
+			return nil, fmt.Errorf("invalid refreshInterval %q: must be 1m or longer", cfg.RefreshInterval)
+		}
+		c.refresh = refresh
+	}
+
+	for _, date := range cfg.Dates {
+		first, last, _ := strings.Cut(date, "/")
+		if last == "" {
+			last = first
+		}
+		start, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(first), loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q: use YYYY-MM-DD or YYYY-MM-DD/YYYY-MM-DD", date)
+		}
+		end, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(last), loc)
+		if err != nil || end.Before(start) {
+			return nil, fmt.Errorf("invalid date %q: use YYYY-MM-DD or YYYY-MM-DD/YYYY-MM-DD", date)
+		}
+		c.dates = append(c.dates, period{start: start, end: end.AddDate(0, 0, 1)})
+	}
+	return c, nil
+}
+
+// Exists reports whether a blackout calendar is configured
+func Exists(name string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	_, exists := calendars[name]
+	return exists
+}
+
+// Blocks reports whether a job is held by a blackout calendar at t, and by which (with the
+// summary of the feed event, if any)
+func Blocks(job *config.JobConfig, t time.Time) (string, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, c := range calendars {
+		if !(job.Mutating && c.mutatingJobs) && !utils.Contains(job.BlackoutCalendars, c.name) {
+			continue
+		}
+		c.refreshIfStale()
+		for _, periods := range [][]period{c.dates, c.feed} {
+			for _, p := range periods {
+				if !t.Before(p.start) && t.Before(p.end) {
+					if p.summary != "" {
+						return fmt.Sprintf("%s (%s)", c.name, p.summary), true
+					}
+					return c.name, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// refreshIfStale fetches the feed of the calendar in the background when it is due; mu is held
+func (c *calendar) refreshIfStale() {
+	if c.url == "" || c.refreshing || time.Since(c.fetched) < c.refresh {
+		return
+	}
+	c.refreshing = true
+	loc := location
+	go func() {
+		feed, err := fetchFeed(c.url, loc)
+
+		mu.Lock()
+		defer mu.Unlock()
+		c.refreshing = false
+		c.fetched = time.Now()
+		if err != nil {
+			logger.AppWarn("Failed to refresh blackout calendar %s, keeping %d known events: %v", c.name, len(c.feed), err)
+			return
+		}
+		c.feed = feed
+	}()
+}
+
+// fetchFeed fetches and parses an iCalendar feed
+func fetchFeed(url string, loc *time.Location) ([]period, error) {
+	resp, err := feedClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return parseICal(io.LimitReader(resp.Body, maxFeedBytes), loc)
+}
+
+// parseICal reads the VEVENTs of an iCalendar document as periods. All-day events cover their
+// days in loc; an event without DTEND lasts one day (all-day) or is skipped. Recurrence rules
+// are not expanded: feeds are expected to list every blackout day.
+func parseICal(r io.Reader, loc *time.Location) ([]period, error) {
+	var (
+		periods  []period
+		inEvent  bool
+		event    period
+		allDay   bool
+		lines    []string
+		scanner  = bufio.NewScanner(r)
+		parseErr error
+	)
+	scanner.Buffer(make([]byte, 64*1024), maxFeedBytes)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Folded lines continue the previous line after a space or tab
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		nameAndParams, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, params, _ := strings.Cut(nameAndParams, ";")
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent, event, allDay = true, period{}, false
+			}
+		case "END":
+			if !strings.EqualFold(value, "VEVENT") || !inEvent {
+				continue
+			}
+			inEvent = false
+			if event.start.IsZero() {
+				continue
+			}
+			if event.end.IsZero() && allDay {
+				event.end = event.start.AddDate(0, 0, 1)
+			}
+			if event.end.After(event.start) {
+				periods = append(periods, event)
+			}
+		case "DTSTART", "DTEND":
+			if !inEvent {
+				continue
+			}
+			t, date, err := parseICalTime(value, params, loc)
+			if err != nil {
+				parseErr = err
+				continue
+			}
+			if strings.EqualFold(name, "DTSTART") {
+				event.start, allDay = t, date
+			} else {
+				event.end = t
+			}
+		case "SUMMARY":
+			if inEvent {
+				event.summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(value)
+			}
+		}
+	}
+	if len(periods) == 0 && parseErr != nil {
+		return nil, parseErr
+	}
+	return periods, nil
+}
+
+// parseICalTime parses a DATE (20261224) or DATE-TIME (20261224T090000, UTC with Z or in the
+// TZID parameter's zone) value and reports whether it is a date
+func parseICalTime(value, params string, loc *time.Location) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+
+	zone := loc
+	for _, param := range strings.Split(params, ";") {
+		if tzid, ok := strings.CutPrefix(param, "TZID="); ok {
+			if tzLoc, err := time.LoadLocation(strings.Trim(tzid, `"`)); err == nil {
+				zone = tzLoc
+			}
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, zone)
+	return t, false, err
+}
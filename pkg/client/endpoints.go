@@ -0,0 +1,567 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Status is the application status
+type Status struct {
+	Status       string `json:"status"`
+	Clusters     int    `json:"clusters"`
+	RatesTracked int    `json:"ratesTracked"`
+	Timestamp    int64  `json:"timestamp"` // epoch milliseconds (UTC)
+}
+
+// JobStatus is the scheduling state of a job
+type JobStatus struct {
+	Running    bool      `json:"running"`
+	LastRun    time.Time `json:"lastRun"`
+	NextRun    time.Time `json:"nextRun"`
+	RunCount   int       `json:"runCount"`
+	ErrorCount int       `json:"errorCount"`
+	Tenant     string    `json:"tenant,omitempty"`
+}
+
+// JobGroupRequest runs a configured job group, or a list of jobs, one after another
+type JobGroupRequest struct {
+	Group           string   `json:"group,omitempty"`
+	Jobs            []string `json:"jobs,omitempty"`
+	ContinueOnError bool     `json:"continueOnError,omitempty"`
+}
+
+// JobGroupRun is the combined report of a job group run
+type JobGroupRun struct {
+	Group      string   `json:"group,omitempty"`
+	Status     string   `json:"status"`    // succeeded or failed
+	StartedAt  int64    `json:"startedAt"` // epoch milliseconds (UTC)
+	DurationMs int64    `json:"durationMs"`
+	Jobs       []JobRun `json:"jobs"`
+}
+
+// JobRun is the outcome of a job in a job group run
+type JobRun struct {
+	Job        string `json:"job"`
+	Status     string `json:"status"`              // succeeded, failed, alreadyRunning, notFound or skipped
+	StartedAt  int64  `json:"startedAt,omitempty"` // epoch milliseconds (UTC)
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OnboardingRequest describes a new cluster to generate the configuration of
+type OnboardingRequest struct {
+	Cluster     string   `json:"cluster"`
+	Hosts       []string `json:"hosts"`
+	Owner       string   `json:"owner,omitempty"`
+	Environment string   `json:"environment,omitempty"`
+	Port        string   `json:"port,omitempty"`
+	NodeType    string   `json:"nodeType,omitempty"`
+	Zone        string   `json:"zone,omitempty"`
+	DataCenter  string   `json:"dataCenter,omitempty"`
+}
+
+// Onboarding is the generated configuration of a new cluster
+type Onboarding struct {
+	Cluster        string               `json:"cluster"`
+	ClustersCSV    string               `json:"clustersCsv"`    // header and one row per host
+	CredentialsCSV string               `json:"credentialsCsv"` // header and a row with placeholders
+	JobEntries     []OnboardingJobEntry `json:"jobEntries"`
+	Warnings       []string             `json:"warnings,omitempty"`
+}
+
+// OnboardingJobEntry is a job parameter to change for a new cluster to be monitored by the job
+type OnboardingJobEntry struct {
+	Job       string   `json:"job"`
+	Parameter string   `json:"parameter"`
+	Value     []string `json:"value"` // the recommended value of the parameter
+	Reason    string   `json:"reason"`
+}
+
+// Event is an entry of the event store
+type Event struct {
+	ID                string            `json:"id"`
+	Source            string            `json:"source"`
+	Name              string            `json:"name"`
+	Severity          string            `json:"severity"`
+	State             string            `json:"state"` // firing or resolved
+	Labels            map[string]string `json:"labels"`
+	Annotations       map[string]string `json:"annotations"`
+	StartsAt          int64             `json:"startsAt"`         // epoch milliseconds (UTC)
+	EndsAt            int64             `json:"endsAt,omitempty"` // epoch milliseconds (UTC), resolved events only
+	UpdatedAt         int64             `json:"updatedAt"`        // epoch milliseconds (UTC)
+	DurationMs        int64             `json:"durationMs"`
+	Suppressed        bool              `json:"suppressed"`
+	MaintenanceReason string            `json:"maintenanceReason"`
+}
+
+// EventFilter selects events; empty fields match everything
+type EventFilter struct {
+	Source   string
+	Name     string
+	State    string
+	Severity string
+	Cluster  string
+	From     time.Time
+	To       time.Time
+}
+
+// EventList is the response of an event query
+type EventList struct {
+	Events    []Event `json:"events"`
+	Count     int     `json:"count"`
+	Firing    int     `json:"firing"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// SilenceRequest starts a silence of Duration (e.g. "90m") or Hours
+type SilenceRequest struct {
+	Clusters []string `json:"clusters"` // "*" = all clusters
+	Hours    float64  `json:"hours,omitempty"`
+	Duration string   `json:"duration,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// Silence is an ad-hoc silence of alerts and events
+type Silence struct {
+	ID       string   `json:"id"`
+	Clusters []string `json:"clusters"`
+	Start    int64    `json:"start"` // epoch milliseconds (UTC)
+	End      int64    `json:"end"`   // epoch milliseconds (UTC)
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// Decommission is a node being decommissioned
+type Decommission struct {
+	Cluster   string `json:"cluster"`
+	Host      string `json:"host"`
+	Reason    string `json:"reason,omitempty"`
+	Since     int64  `json:"since"`               // epoch milliseconds (UTC)
+	Shards    int    `json:"shards"`              // shards left at the latest check, -1 = not checked yet
+	CheckedAt int64  `json:"checkedAt,omitempty"` // epoch milliseconds (UTC)
+	Error     string `json:"error,omitempty"`     // why the latest check failed
+}
+
+// TrackTaskRequest starts tracking a task, e.g. a reindex started with
+// wait_for_completion=false
+type TrackTaskRequest struct {
+	ClusterName string `json:"clusterName"`
+	TaskID      string `json:"taskId"` // <node id>:<task number>
+	Note        string `json:"note,omitempty"`
+}
+
+// TrackedTask is the progress of a tracked task, as of its latest poll by the trackTasks job
+type TrackedTask struct {
+	ClusterName   string  `json:"clusterName"`
+	TaskID        string  `json:"taskId"`
+	Note          string  `json:"note"`
+	State         string  `json:"state"` // running, completed, failed, gone or notFound
+	Action        string  `json:"action"`
+	Description   string  `json:"description"`
+	AddedAt       int64   `json:"addedAt"`    // epoch milliseconds (UTC)
+	StartTime     int64   `json:"startTime"`  // epoch milliseconds (UTC), 0 until found
+	EndTime       int64   `json:"endTime"`    // epoch milliseconds (UTC), 0 while running
+	LastPolled    int64   `json:"lastPolled"` // epoch milliseconds (UTC), 0 = not polled yet
+	RunningTimeMs int64   `json:"runningTimeMs"`
+	Total         int64   `json:"total"`
+	Done          int64   `json:"done"`
+	Progress      float64 `json:"progress"` // percent, -1 = unknown
+	RatePerSec    float64 `json:"ratePerSec"`
+	AvgRatePerSec float64 `json:"avgRatePerSec"`
+	EtaMs         int64   `json:"etaMs"` // 0 = unknown
+	Failures      int     `json:"failures"`
+	Error         string  `json:"error"`
+	LastError     string  `json:"lastError"`
+}
+
+// TrackedTaskList is the response of GET /api/v1/tasks/tracked
+type TrackedTaskList struct {
+	Tasks     []TrackedTask `json:"tasks"`
+	Count     int           `json:"count"`
+	Running   int           `json:"running"`
+	Timestamp int64         `json:"timestamp"`
+}
+
+// RemediationAction is a configured remediation
+type RemediationAction struct {
+	Name          string   `json:"name"`
+	Type          string   `json:"type"` // refreshInterval, replicas or disablePipeline
+	Indices       string   `json:"indices"`
+	Value         string   `json:"value,omitempty"`
+	Pipeline      string   `json:"pipeline,omitempty"`
+	Clusters      []string `json:"clusters"`
+	RollbackAfter string   `json:"rollbackAfter"`
+	Cooldown      string   `json:"cooldown"`
+	MaxIndices    int      `json:"maxIndices"`
+	Roles         []string `json:"roles,omitempty"`
+	DryRun        bool     `json:"dryRun"`
+	On            *struct {
+		Source     string   `json:"source"`
+		Name       string   `json:"name,omitempty"`
+		Severities []string `json:"severities,omitempty"`
+	} `json:"on,omitempty"` // nil = API only
+}
+
+// RunRemediationRequest runs a remediation on a cluster
+type RunRemediationRequest struct {
+	ClusterName string `json:"clusterName"`
+	DryRun      bool   `json:"dryRun,omitempty"`
+}
+
+// RemediationRun is one run of a remediation on a cluster
+type RemediationRun struct {
+	ID           string             `json:"id"`
+	Action       string             `json:"action"`
+	ClusterName  string             `json:"clusterName"`
+	Setting      string             `json:"setting"`
+	Value        string             `json:"value"`
+	Previous     map[string]*string `json:"previous"` // index -> setting before the run, nil = not set
+	Indices      int                `json:"indices"`
+	TriggeredBy  string             `json:"triggeredBy"`
+	State        string             `json:"state"`      // dryRun, active, rolledBack or failed
+	StartedAt    int64              `json:"startedAt"`  // epoch milliseconds (UTC)
+	RollbackAt   int64              `json:"rollbackAt"` // epoch milliseconds (UTC), 0 unless active
+	EndedAt      int64              `json:"endedAt"`    // epoch milliseconds (UTC), 0 until rolled back
+	RolledBackBy string             `json:"rolledBackBy"`
+	Error        string             `json:"error"`
+}
+
+// Remediations is the response of GET /api/v1/remediations
+type Remediations struct {
+	Enabled   bool                `json:"enabled"`
+	Actions   []RemediationAction `json:"actions"`
+	Runs      []RemediationRun    `json:"runs"`
+	Active    int                 `json:"active"`
+	Timestamp int64               `json:"timestamp"`
+}
+
+// ClusterRename is the response of POST /api/v1/clusters/{clusterName}/rename
+type ClusterRename struct {
+	From             string   `json:"from"`
+	To               string   `json:"to"`
+	Merged           bool     `json:"merged"` // the new name was in the inventory already
+	Moved            []string `json:"moved"`  // structures whose data moved to the new name
+	Events           int      `json:"events"`
+	SettingsBaseline bool     `json:"settingsBaseline"`
+}
+
+// EndpointSwitchRequest switches a cluster to a new ClusterSAN set
+type EndpointSwitchRequest struct {
+	ClusterSAN     []string `json:"clusterSAN"`
+	ActiveEndpoint string   `json:"activeEndpoint,omitempty"` // default: the first of ClusterSAN
+	DryRun         bool     `json:"dryRun,omitempty"`
+}
+
+// EndpointCheck is the validation of one endpoint of a switch
+type EndpointCheck struct {
+	Endpoint    string `json:"endpoint"`
+	Reachable   bool   `json:"reachable"`
+	ClusterUUID string `json:"clusterUUID,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// EndpointSwitch is the response of POST /api/v1/clusters/{clusterName}/endpoints
+type EndpointSwitch struct {
+	Cluster                string          `json:"cluster"`
+	PreviousActiveEndpoint string          `json:"previousActiveEndpoint"`
+	PreviousClusterSAN     []string        `json:"previousClusterSAN"`
+	ActiveEndpoint         string          `json:"activeEndpoint"`
+	ClusterSAN             []string        `json:"clusterSAN"`
+	ClusterUUID            string          `json:"clusterUUID"`
+	UUIDSource             string          `json:"uuidSource"` // inventory, currentEndpoint or newEndpoints
+	Checks                 []EndpointCheck `json:"checks"`
+	Switched               bool            `json:"switched"`
+}
+
+// WritePressureCandidate is a write pressure job configuration to backtest
+type WritePressureCandidate struct {
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters"` // checkForWritePressure parameters, e.g. thresholdValue
+}
+
+// WritePressureBacktestRequest replays the write queue history of a cluster through candidate
+// configurations
+type WritePressureBacktestRequest struct {
+	Resolution string                   `json:"resolution,omitempty"` // raw (default) or a rollup interval, e.g. 5m
+	Aggregate  string                   `json:"aggregate,omitempty"`  // avg (default) or max
+	Window     int                      `json:"window,omitempty"`
+	Candidates []WritePressureCandidate `json:"candidates"`
+}
+
+// WritePressureBacktestResult is what a configuration would have fired over the history
+type WritePressureBacktestResult struct {
+	Name                 string         `json:"name"` // "current" for the configured job
+	Threshold            int            `json:"threshold"`
+	CriticalThreshold    int            `json:"criticalThreshold"`
+	ClearThreshold       int            `json:"clearThreshold"`
+	GrowthPerInterval    int            `json:"growthPerInterval"`
+	DetectionMode        string         `json:"detectionMode"`
+	ConsecutiveIntervals int            `json:"consecutiveIntervals"`
+	GrowthIntervals      int            `json:"growthIntervals"`
+	MissingDataMode      string         `json:"missingDataMode"`
+	Events               int            `json:"events"`
+	Critical             int            `json:"critical"`
+	Escalations          int            `json:"escalations"`
+	Resolved             int            `json:"resolved"`
+	Flaps                int            `json:"flaps"`
+	StillFiring          int            `json:"stillFiring"`
+	PressuredIntervals   int            `json:"pressuredIntervals"`
+	HostEvents           map[string]int `json:"hostEvents"`
+}
+
+// WritePressureBacktest is the response of POST /api/v1/writePressure/{clusterName}/backtest
+type WritePressureBacktest struct {
+	Cluster    string                        `json:"cluster"`
+	Job        string                        `json:"job"` // configured job the candidates change, "" = job defaults
+	Resolution string                        `json:"resolution"`
+	Aggregate  string                        `json:"aggregate"`
+	Window     int                           `json:"window"`
+	Hosts      int                           `json:"hosts"`
+	Intervals  int                           `json:"intervals"`
+	From       int64                         `json:"from"` // epoch milliseconds (UTC)
+	To         int64                         `json:"to"`   // epoch milliseconds (UTC)
+	Candidates []WritePressureBacktestResult `json:"candidates"`
+}
+
+// Status returns the application status
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.Get(ctx, "/api/v1/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Clusters returns the names of the clusters visible to the token
+func (c *Client) Clusters(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Clusters []string `json:"clusters"`
+	}
+	if err := c.Get(ctx, "/api/v1/clusters", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Clusters, nil
+}
+
+// ClusterSummary returns the index totals and ingest rate of a cluster
+func (c *Client) ClusterSummary(ctx context.Context, clusterName string) (map[string]interface{}, error) {
+	var summary map[string]interface{}
+	if err := c.Get(ctx, pathOf("clusters", clusterName, "summary"), nil, &summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// RenameCluster moves a cluster renamed in the CMDB, and everything collected for it, to its
+// new name
+func (c *Client) RenameCluster(ctx context.Context, clusterName, newName string) (*ClusterRename, error) {
+	var rename ClusterRename
+	body := map[string]string{"newName": newName}
+	if err := c.Post(ctx, pathOf("clusters", clusterName, "rename"), body, &rename); err != nil {
+		return nil, err
+	}
+	return &rename, nil
+}
+
+// SwitchEndpoints switches a cluster to a new ClusterSAN set once every new endpoint is
+// reachable and reports the cluster's UUID; a failed validation is an *Error with status 422
+func (c *Client) SwitchEndpoints(ctx context.Context, clusterName string, req EndpointSwitchRequest) (*EndpointSwitch, error) {
+	var result EndpointSwitch
+	if err := c.Post(ctx, pathOf("clusters", clusterName, "endpoints"), req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Jobs returns the scheduling state of the jobs by name
+func (c *Client) Jobs(ctx context.Context) (map[string]JobStatus, error) {
+	var resp struct {
+		Jobs map[string]JobStatus `json:"jobs"`
+	}
+	if err := c.Get(ctx, "/api/v1/jobs", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Jobs, nil
+}
+
+// TriggerJob starts a run of a job now; it does not wait for the run to finish
+func (c *Client) TriggerJob(ctx context.Context, jobName string) error {
+	return c.Post(ctx, pathOf("jobs", jobName, "trigger"), nil, nil)
+}
+
+// TriggerJobGroup runs a job group or list of jobs and waits for the last job to finish. The
+// HTTPClient of New times out after 30s; long groups need a client with a longer timeout.
+func (c *Client) TriggerJobGroup(ctx context.Context, req JobGroupRequest) (*JobGroupRun, error) {
+	var run JobGroupRun
+	if err := c.Post(ctx, pathOf("jobs", "triggerGroup"), req, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// Onboard generates the CSV rows, credentials placeholder and job parameter changes of a
+// new cluster; nothing is changed on the server
+func (c *Client) Onboard(ctx context.Context, req OnboardingRequest) (*Onboarding, error) {
+	var onboarding Onboarding
+	if err := c.Post(ctx, pathOf("onboarding"), req, &onboarding); err != nil {
+		return nil, err
+	}
+	return &onboarding, nil
+}
+
+// Events queries the event store
+func (c *Client) Events(ctx context.Context, filter EventFilter) (*EventList, error) {
+	query := url.Values{}
+	for name, value := range map[string]string{
+		"source":   filter.Source,
+		"name":     filter.Name,
+		"state":    filter.State,
+		"severity": filter.Severity,
+		"cluster":  filter.Cluster,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if !filter.From.IsZero() {
+		query.Set("from", strconv.FormatInt(filter.From.UnixMilli(), 10))
+	}
+	if !filter.To.IsZero() {
+		query.Set("to", strconv.FormatInt(filter.To.UnixMilli(), 10))
+	}
+
+	var list EventList
+	if err := c.Get(ctx, "/api/v1/events", query, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Event returns an event by ID
+func (c *Client) Event(ctx context.Context, id string) (*Event, error) {
+	var event Event
+	if err := c.Get(ctx, pathOf("events", id), nil, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// CreateSilence silences clusters for a while
+func (c *Client) CreateSilence(ctx context.Context, req SilenceRequest) (*Silence, error) {
+	var silence Silence
+	if err := c.Post(ctx, "/api/v1/maintenance/silences", req, &silence); err != nil {
+		return nil, err
+	}
+	return &silence, nil
+}
+
+// DeleteSilence ends a silence early
+func (c *Client) DeleteSilence(ctx context.Context, id string) error {
+	return c.Delete(ctx, pathOf("maintenance", "silences", id), nil)
+}
+
+// Decommissions returns the nodes being decommissioned
+func (c *Client) Decommissions(ctx context.Context) ([]Decommission, error) {
+	var resp struct {
+		Decommissions []Decommission `json:"decommissions"`
+	}
+	if err := c.Get(ctx, "/api/v1/maintenance/decommissions", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Decommissions, nil
+}
+
+// StartDecommission marks a node as decommissioning; it is removed from the inventory once
+// its shards are gone
+func (c *Client) StartDecommission(ctx context.Context, clusterName, hostName, reason string) (*Decommission, error) {
+	var decommission Decommission
+	body := map[string]string{"reason": reason}
+	if err := c.Post(ctx, pathOf("clusters", clusterName, "nodes", hostName, "decommission"), body, &decommission); err != nil {
+		return nil, err
+	}
+	return &decommission, nil
+}
+
+// CancelDecommission stops decommissioning a node
+func (c *Client) CancelDecommission(ctx context.Context, clusterName, hostName string) error {
+	return c.Delete(ctx, pathOf("clusters", clusterName, "nodes", hostName, "decommission"), nil)
+}
+
+// TrackedTasks returns the tracked tasks, of one cluster unless clusterName is empty
+func (c *Client) TrackedTasks(ctx context.Context, clusterName string) (*TrackedTaskList, error) {
+	query := url.Values{}
+	if clusterName != "" {
+		query.Set("cluster", clusterName)
+	}
+	var list TrackedTaskList
+	if err := c.Get(ctx, "/api/v1/tasks/tracked", query, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// TrackTask starts tracking the progress of a task
+func (c *Client) TrackTask(ctx context.Context, req TrackTaskRequest) (*TrackedTask, error) {
+	var task TrackedTask
+	if err := c.Post(ctx, "/api/v1/tasks/tracked", req, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UntrackTask stops tracking a task; the task itself keeps running
+func (c *Client) UntrackTask(ctx context.Context, clusterName, taskID string) error {
+	return c.Delete(ctx, pathOf("tasks", "tracked", clusterName, taskID), nil)
+}
+
+// Remediations returns the configured remediations and their runs, on one cluster unless
+// clusterName is empty
+func (c *Client) Remediations(ctx context.Context, clusterName string) (*Remediations, error) {
+	query := url.Values{}
+	if clusterName != "" {
+		query.Set("cluster", clusterName)
+	}
+	var remediations Remediations
+	if err := c.Get(ctx, "/api/v1/remediations", query, &remediations); err != nil {
+		return nil, err
+	}
+	return &remediations, nil
+}
+
+// RunRemediation runs a remediation on a cluster
+func (c *Client) RunRemediation(ctx context.Context, name string, req RunRemediationRequest) (*RemediationRun, error) {
+	var run RemediationRun
+	if err := c.Post(ctx, pathOf("remediations", name, "run"), req, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// RollbackRemediation rolls back an active remediation run before its rollback time
+func (c *Client) RollbackRemediation(ctx context.Context, runID string) (*RemediationRun, error) {
+	var run RemediationRun
+	if err := c.Post(ctx, pathOf("remediations", "runs", runID, "rollback"), nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// BacktestWritePressure reports the write pressure events the configured job and candidate
+// configurations would have fired over the stored write queue history of a cluster
+func (c *Client) BacktestWritePressure(ctx context.Context, clusterName string, req WritePressureBacktestRequest) (*WritePressureBacktest, error) {
+	var backtest WritePressureBacktest
+	if err := c.Post(ctx, pathOf("writePressure", clusterName, "backtest"), req, &backtest); err != nil {
+		return nil, err
+	}
+	return &backtest, nil
+}
+
+// OpenAPI returns the OpenAPI 3 document of the API
+func (c *Client) OpenAPI(ctx context.Context) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := c.Get(ctx, "/api/v1/openapi.json", nil, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
@@ -3,8 +3,14 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -21,6 +27,362 @@ type GlobalConfig struct {
 	Cert                         CertConfig `json:"cert" yaml:"cert"`
 	OutDir                       string     `json:"out_dir" yaml:"out_dir"`
 	ConfigDir                    string     `json:"config_dir" yaml:"config_dir"`
+	// TimeZone is the default IANA time zone used by monitoring queries (e.g. date histogram
+	// bucketing); stored timestamps are always UTC epoch milliseconds
+	TimeZone string `json:"timeZone,omitempty" yaml:"timeZone,omitempty"`
+	// MemoryBudgets caps the estimated memory per data structure, e.g. indicesHistory: 2gb
+	MemoryBudgets map[string]string `json:"memoryBudgets,omitempty" yaml:"memoryBudgets,omitempty"`
+	// Notifications configures how alerts and reports reach cluster owners
+	Notifications NotificationConfig `json:"notifications,omitempty" yaml:"notifications,omitempty"`
+	// MaintenanceWindows suppress alerts (not collection) for clusters under maintenance
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty" yaml:"maintenanceWindows,omitempty"`
+	// Events configures the event store (write pressure events, alerts)
+	Events EventsConfig `json:"events,omitempty" yaml:"events,omitempty"`
+	// Kafka publishes event changes and job failures to a Kafka topic
+	Kafka KafkaConfig `json:"kafka,omitempty" yaml:"kafka,omitempty"`
+	// BackupDestination is the output destination the stats backup is also written to and
+	// restored from when the local backup file is missing; "" = local file only
+	BackupDestination string `json:"backupDestination,omitempty" yaml:"backupDestination,omitempty"`
+	// Audit configures the audit log of mutating API calls
+	Audit AuditConfig `json:"audit,omitempty" yaml:"audit,omitempty"`
+	// Admin configures the admin port serving pprof and runtime debug endpoints
+	Admin AdminConfig `json:"admin,omitempty" yaml:"admin,omitempty"`
+	// SelfTelemetry records the resource usage of every job run of this process
+	SelfTelemetry SelfTelemetryConfig `json:"selfTelemetry,omitempty" yaml:"selfTelemetry,omitempty"`
+	// Outputs are the destinations report and dump jobs write their artifacts to; the
+	// destination "local" (out_dir) always exists
+	Outputs []OutputConfig `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+	// SettingsBaselineDir holds the settings baseline of each cluster checked by
+	// checkSettingsDrift, one <cluster>.json per cluster
+	SettingsBaselineDir string `json:"settingsBaselineDir,omitempty" yaml:"settingsBaselineDir,omitempty"`
+	// APITokens authenticate API requests (Authorization: Bearer <token>); without tokens
+	// the API is open
+	APITokens []APIToken `json:"apiTokens,omitempty" yaml:"apiTokens,omitempty"`
+	// JobPermissions restrict which API principals may trigger jobs; jobs without a matching
+	// permission can be triggered by every principal
+	JobPermissions []JobPermission `json:"jobPermissions,omitempty" yaml:"jobPermissions,omitempty"`
+	// JobGroups name lists of jobs triggered together through POST /api/v1/jobs/triggerGroup,
+	// run one after another in the listed order (e.g. a refresh chain)
+	JobGroups map[string][]string `json:"jobGroups,omitempty" yaml:"jobGroups,omitempty"`
+	// BlackoutCalendars are days (holidays, change freezes) on which the jobs opting in do not
+	// run
+	BlackoutCalendars []BlackoutCalendar `json:"blackoutCalendars,omitempty" yaml:"blackoutCalendars,omitempty"`
+	// Onboarding configures the generation of the configuration of new clusters
+	Onboarding OnboardingConfig `json:"onboarding,omitempty" yaml:"onboarding,omitempty"`
+	// HTTP configures how the API is served behind a reverse proxy and to browsers
+	HTTP HTTPConfig `json:"http,omitempty" yaml:"http,omitempty"`
+	// LegacyAPISunset is the date (YYYY-MM-DD) after which the unversioned /api routes may be
+	// removed, announced in their Sunset header; "" = not scheduled
+	LegacyAPISunset string `json:"legacyApiSunset,omitempty" yaml:"legacyApiSunset,omitempty"`
+	// EventMetadata adds runbook and dashboard links and an owner to the events of a source or
+	// event name, and to the alerts sent about them
+	EventMetadata []EventMetadata `json:"eventMetadata,omitempty" yaml:"eventMetadata,omitempty"`
+	// Remediations configures the pre-approved actions run on events, off unless enabled
+	Remediations RemediationsConfig `json:"remediations,omitempty" yaml:"remediations,omitempty"`
+	// MonitoringClusters are the monitoring deployments holding the .monitoring data of the
+	// clusters, each with the routing rules of the clusters it holds
+	MonitoringClusters []MonitoringCluster `json:"monitoringClusters,omitempty" yaml:"monitoringClusters,omitempty"`
+	// HostNames normalizes the host names of the nodes as they are collected, so the series of
+	// a host match across jobs and sources (e.g. an FQDN in the monitoring data and a short
+	// name in _tasks)
+	HostNames HostNameConfig `json:"hostNames,omitempty" yaml:"hostNames,omitempty"`
+}
+
+// HostNameConfig are the normalization rules of collected host names, applied in order:
+// lowercase, the relabel rules, stripDomain. The rules should be idempotent, as host names
+// given to the API are normalized the same way.
+type HostNameConfig struct {
+	Lowercase   bool          `json:"lowercase,omitempty" yaml:"lowercase,omitempty"`
+	Relabel     []HostRelabel `json:"relabel,omitempty" yaml:"relabel,omitempty"`
+	StripDomain bool          `json:"stripDomain,omitempty" yaml:"stripDomain,omitempty"` // keep the name up to the first dot; IP addresses are kept
+
+	relabel []*regexp.Regexp // compiled Relabel matches
+}
+
+// HostRelabel rewrites the host names matching a regular expression, anchored at both ends
+// like Prometheus relabeling, e.g. match "(.*)\.corp\.example\.com", replace "$1"
+type HostRelabel struct {
+	Match   string `json:"match" yaml:"match"`
+	Replace string `json:"replace" yaml:"replace"` // $1 ... refer to the groups of match
+}
+
+// Normalize applies the rules to a host name
+func (h *HostNameConfig) Normalize(host string) string {
+	if h.Lowercase {
+		host = strings.ToLower(host)
+	}
+	for i, pattern := range h.relabel {
+		if pattern.MatchString(host) {
+			host = pattern.ReplaceAllString(host, h.Relabel[i].Replace)
+		}
+	}
+	if h.StripDomain && net.ParseIP(host) == nil {
+		host, _, _ = strings.Cut(host, ".")
+	}
+	return host
+}
+
+// NormalizeHostName applies the hostNames rules of the global configuration to a host name
+// collected from a cluster or its monitoring data
+func NormalizeHostName(host string) string {
+	if Global == nil || host == "" {
+		return host
+	}
+	return Global.HostNames.Normalize(host)
+}
+
+// MonitoringCluster is a monitoring deployment queried by getThreadPoolWriteQueue for the
+// clusters its rules route to it. A cluster matches when it satisfies every rule that is set
+// (a rule lists alternatives); the first matching monitoring cluster in the list holds its
+// data, and a monitoring cluster without rules holds the data of the clusters no other matches.
+type MonitoringCluster struct {
+	Name        string   `json:"name" yaml:"name"`
+	Endpoints   []string `json:"endpoints" yaml:"endpoints"` // equivalent search URLs, e.g. https://monitoring-es:9200/.monitoring-es-*/_search
+	APIKey      string   `json:"apiKey" yaml:"apiKey"`
+	InsecureTLS bool     `json:"insecureTls,omitempty" yaml:"insecureTls,omitempty"`
+	Profile     string   `json:"profile,omitempty" yaml:"profile,omitempty"` // data layout: legacy (.monitoring-es-*, default) or agent (metrics-*)
+	Index       string   `json:"index,omitempty" yaml:"index,omitempty"`     // index pattern searched, default the profile's; {date} selects daily indices
+	// Routing rules
+	Clusters []string `json:"clusters,omitempty" yaml:"clusters,omitempty"` // cluster names or glob patterns, e.g. prod-eu-*
+	Envs     []string `json:"envs,omitempty" yaml:"envs,omitempty"`
+	Tenants  []string `json:"tenants,omitempty" yaml:"tenants,omitempty"`
+	Zones    []string `json:"zones,omitempty" yaml:"zones,omitempty"` // zone identifiers of the clusters
+}
+
+// IsDefault reports whether the monitoring cluster has no routing rules
+func (m *MonitoringCluster) IsDefault() bool {
+	return len(m.Clusters) == 0 && len(m.Envs) == 0 && len(m.Tenants) == 0 && len(m.Zones) == 0
+}
+
+// JobPermission limits the triggering of jobs to principals holding one of the roles. A job
+// matched by several permissions must satisfy each of them.
+type JobPermission struct {
+	Jobs  []string `json:"jobs" yaml:"jobs"` // job names or internal job names, "*" = all jobs
+	Roles []string `json:"roles" yaml:"roles"`
+}
+
+// APIToken is a bearer token accepted by the API. A token with a tenant only sees the
+// clusters, events and jobs of that tenant.
+type APIToken struct {
+	Name   string   `json:"name" yaml:"name"` // principal, used in logs
+	Token  string   `json:"token" yaml:"token"`
+	Tenant string   `json:"tenant,omitempty" yaml:"tenant,omitempty"` // "" = all tenants
+	Roles  []string `json:"roles,omitempty" yaml:"roles,omitempty"`   // matched against jobPermissions
+}
+
+// AuditConfig holds the settings of the audit log
+type AuditConfig struct {
+	File       string `json:"file,omitempty" yaml:"file,omitempty"`             // JSON lines file, appended to
+	MaxEntries int    `json:"maxEntries,omitempty" yaml:"maxEntries,omitempty"` // entries kept in memory for the API
+}
+
+// AdminConfig holds the settings of the admin port. The port is off unless Port is set; it
+// binds to the loopback interface unless Address says otherwise.
+type AdminConfig struct {
+	Port                 int      `json:"port,omitempty" yaml:"port,omitempty"`
+	Address              string   `json:"address,omitempty" yaml:"address,omitempty"`                           // listen address, default 127.0.0.1
+	Roles                []string `json:"roles,omitempty" yaml:"roles,omitempty"`                               // roles allowed in, besides tokens without a tenant; empty = any token without a tenant
+	BlockProfileRate     int      `json:"blockProfileRate,omitempty" yaml:"blockProfileRate,omitempty"`         // runtime.SetBlockProfileRate, 0 = off
+	MutexProfileFraction int      `json:"mutexProfileFraction,omitempty" yaml:"mutexProfileFraction,omitempty"` // runtime.SetMutexProfileFraction, 0 = off
+}
+
+// OnboardingConfig holds the settings of cluster onboarding
+type OnboardingConfig struct {
+	TemplateDir string `json:"templateDir,omitempty" yaml:"templateDir,omitempty"` // clusters.csv.tmpl and credentials.csv.tmpl replacing the built-in templates
+}
+
+// HTTPConfig holds the settings of the API behind an ingress or reverse proxy. X-Forwarded-*
+// headers are only honored from TrustedProxies.
+type HTTPConfig struct {
+	BasePath       string     `json:"basePath,omitempty" yaml:"basePath,omitempty"`             // path prefix the API is served under, e.g. /elastic-observability
+	TrustedProxies []string   `json:"trustedProxies,omitempty" yaml:"trustedProxies,omitempty"` // IPs or CIDRs of proxies setting X-Forwarded-For, -Proto, -Host and -Prefix
+	CORS           CORSConfig `json:"cors,omitempty" yaml:"cors,omitempty"`
+}
+
+// CORSConfig allows browser dashboards of other origins to call the API. CORS is off unless
+// AllowedOrigins is set.
+type CORSConfig struct {
+	AllowedOrigins   []string `json:"allowedOrigins,omitempty" yaml:"allowedOrigins,omitempty"` // e.g. https://grafana.example.com, "*" = any
+	AllowedHeaders   []string `json:"allowedHeaders,omitempty" yaml:"allowedHeaders,omitempty"` // request headers allowed besides the defaults
+	AllowCredentials bool     `json:"allowCredentials,omitempty" yaml:"allowCredentials,omitempty"`
+	MaxAge           int      `json:"maxAge,omitempty" yaml:"maxAge,omitempty"` // seconds browsers cache a preflight, default 600
+}
+
+// OutputConfig is a destination for the artifacts of report and dump jobs. Only the fields of
+// its type are used.
+type OutputConfig struct {
+	Name string `json:"name" yaml:"name"`
+	Type string `json:"type" yaml:"type"`                     // local, s3, azure or sftp
+	Path string `json:"path,omitempty" yaml:"path,omitempty"` // local and sftp: directory; s3 and azure: key prefix
+	// S3 and S3-compatible stores (MinIO); credentials fall back to AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY
+	Bucket          string `json:"bucket,omitempty" yaml:"bucket,omitempty"`
+	Region          string `json:"region,omitempty" yaml:"region,omitempty"`     // default us-east-1
+	Endpoint        string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"` // path-style endpoint, e.g. https://minio:9000; default AWS
+	AccessKeyID     string `json:"accessKeyId,omitempty" yaml:"accessKeyId,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty" yaml:"secretAccessKey,omitempty"`
+	// ServerSideEncryption is AES256 (SSE-S3) or aws:kms (SSE-KMS, with KMSKeyID or the bucket's key)
+	ServerSideEncryption string `json:"serverSideEncryption,omitempty" yaml:"serverSideEncryption,omitempty"`
+	KMSKeyID             string `json:"kmsKeyId,omitempty" yaml:"kmsKeyId,omitempty"`
+	// Versioning enables versioning on the bucket before the first write, so overwritten and
+	// deleted artifacts stay recoverable
+	Versioning bool `json:"versioning,omitempty" yaml:"versioning,omitempty"`
+	// Azure Blob, authorized with a container SAS token
+	AccountURL string `json:"accountUrl,omitempty" yaml:"accountUrl,omitempty"` // e.g. https://account.blob.core.windows.net
+	Container  string `json:"container,omitempty" yaml:"container,omitempty"`
+	SASToken   string `json:"sasToken,omitempty" yaml:"sasToken,omitempty"`
+	// SFTP through the sftp command, with key authentication
+	Host         string `json:"host,omitempty" yaml:"host,omitempty"`
+	Port         int    `json:"port,omitempty" yaml:"port,omitempty"` // default 22
+	User         string `json:"user,omitempty" yaml:"user,omitempty"`
+	IdentityFile string `json:"identityFile,omitempty" yaml:"identityFile,omitempty"`
+	// Retention applies to each folder of the destination after every write
+	Retention OutputRetention `json:"retention,omitempty" yaml:"retention,omitempty"`
+}
+
+// OutputRetention limits the artifacts kept per folder of a destination; zero keeps everything
+type OutputRetention struct {
+	MaxAge   string `json:"maxAge,omitempty" yaml:"maxAge,omitempty"`     // e.g. "30d"
+	MaxFiles int    `json:"maxFiles,omitempty" yaml:"maxFiles,omitempty"` // newest files kept
+}
+
+// SelfTelemetryConfig holds the settings of the self-telemetry of job runs
+type SelfTelemetryConfig struct {
+	Enabled  bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`   // record wall-clock, allocations and goroutines per job run
+	RunsKept int  `json:"runsKept,omitempty" yaml:"runsKept,omitempty"` // runs kept per job, default 50
+	Pprof    bool `json:"pprof,omitempty" yaml:"pprof,omitempty"`       // serve pprof profiles under /debug/pprof/ on the API port
+}
+
+// EventsConfig holds the persistence settings of the event store
+type EventsConfig struct {
+	File      string `json:"file,omitempty" yaml:"file,omitempty"`           // default ./data/events.json
+	Retention string `json:"retention,omitempty" yaml:"retention,omitempty"` // how long resolved events are kept, default 30d
+}
+
+// KafkaConfig holds the settings of the publishing of events and job failures to a Kafka
+// topic. Publishing is off unless Brokers and Topic are set.
+type KafkaConfig struct {
+	Brokers     []string `json:"brokers,omitempty" yaml:"brokers,omitempty"` // host:port of the bootstrap brokers
+	Topic       string   `json:"topic,omitempty" yaml:"topic,omitempty"`
+	Format      string   `json:"format,omitempty" yaml:"format,omitempty"`           // json (default) or avro
+	SchemaID    int      `json:"schemaId,omitempty" yaml:"schemaId,omitempty"`       // avro: schema registry ID, prefixed in the Confluent wire format; 0 = plain Avro
+	Sources     []string `json:"sources,omitempty" yaml:"sources,omitempty"`         // event sources published, empty = all
+	JobFailures *bool    `json:"jobFailures,omitempty" yaml:"jobFailures,omitempty"` // publish failed job runs, default true
+	ClientID    string   `json:"clientId,omitempty" yaml:"clientId,omitempty"`       // default elastic-observability
+	Acks        int      `json:"acks,omitempty" yaml:"acks,omitempty"`               // 1 = leader, -1 = all in-sync replicas (default)
+	Timeout     string   `json:"timeout,omitempty" yaml:"timeout,omitempty"`         // per request, default 10s
+	BufferSize  int      `json:"bufferSize,omitempty" yaml:"bufferSize,omitempty"`   // messages queued for sending, default 10000; further messages are dropped
+	TLS         bool     `json:"tls,omitempty" yaml:"tls,omitempty"`
+	InsecureTLS bool     `json:"insecureTls,omitempty" yaml:"insecureTls,omitempty"`
+	CACert      string   `json:"caCert,omitempty" yaml:"caCert,omitempty"`
+	Username    string   `json:"username,omitempty" yaml:"username,omitempty"` // SASL/PLAIN, "" = no authentication
+	Password    string   `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+// EventMetadata is the responder metadata of the events of a source, or of one event (or
+// rule) name of it. {{label}} placeholders, e.g. {{cluster}} or {{host}}, are expanded from the
+// labels of the event.
+type EventMetadata struct {
+	Source       string `json:"source" yaml:"source"`                 // e.g. writePressure, rules
+	Name         string `json:"name,omitempty" yaml:"name,omitempty"` // event or rule name, "" = every event of the source
+	RunbookURL   string `json:"runbookUrl,omitempty" yaml:"runbookUrl,omitempty"`
+	DashboardURL string `json:"dashboardUrl,omitempty" yaml:"dashboardUrl,omitempty"`
+	Owner        string `json:"owner,omitempty" yaml:"owner,omitempty"` // team or person responding
+}
+
+// RemediationsConfig holds the pre-approved remediations and how they are run
+type RemediationsConfig struct {
+	Enabled bool                `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	DryRun  *bool               `json:"dryRun,omitempty" yaml:"dryRun,omitempty"` // only log and audit what would change, default true
+	File    string              `json:"file,omitempty" yaml:"file,omitempty"`     // runs and their pending rollbacks, default ./data/remediations.json
+	Actions []RemediationAction `json:"actions,omitempty" yaml:"actions,omitempty"`
+}
+
+// RemediationAction is a pre-approved change of index settings, run when a matching event
+// fires or through the API, and rolled back after RollbackAfter
+type RemediationAction struct {
+	Name          string              `json:"name" yaml:"name"`
+	Type          string              `json:"type" yaml:"type"`                                       // refreshInterval, replicas or disablePipeline
+	Indices       string              `json:"indices" yaml:"indices"`                                 // index pattern changed, e.g. logs-*
+	Value         string              `json:"value,omitempty" yaml:"value,omitempty"`                 // refreshInterval: e.g. 30s; replicas: number of replicas
+	Pipeline      string              `json:"pipeline,omitempty" yaml:"pipeline,omitempty"`           // disablePipeline: removed where it is the default pipeline
+	On            *RemediationTrigger `json:"on,omitempty" yaml:"on,omitempty"`                       // nil = run through the API only
+	Clusters      []string            `json:"clusters,omitempty" yaml:"clusters,omitempty"`           // clusters it may run on, "*" = all (default)
+	RollbackAfter string              `json:"rollbackAfter,omitempty" yaml:"rollbackAfter,omitempty"` // default 1h
+	Cooldown      string              `json:"cooldown,omitempty" yaml:"cooldown,omitempty"`           // minimum time between event-triggered runs per cluster, default 6h
+	MaxIndices    int                 `json:"maxIndices,omitempty" yaml:"maxIndices,omitempty"`       // runs changing more indices are refused, default 50
+	Roles         []string            `json:"roles,omitempty" yaml:"roles,omitempty"`                 // roles allowed to run it and roll it back through the API
+	DryRun        *bool               `json:"dryRun,omitempty" yaml:"dryRun,omitempty"`               // overrides remediations.dryRun
+}
+
+// RemediationTrigger selects the firing events that run a remediation
+type RemediationTrigger struct {
+	Source     string   `json:"source" yaml:"source"`                             // e.g. writePressure
+	Name       string   `json:"name,omitempty" yaml:"name,omitempty"`             // "" = any event of the source
+	Severities []string `json:"severities,omitempty" yaml:"severities,omitempty"` // empty = any severity
+}
+
+// MaintenanceWindow is a recurring (Cron + Duration) or absolute (Start/End) period during
+// which alerts for the listed clusters are suppressed
+type MaintenanceWindow struct {
+	Clusters []string `json:"clusters" yaml:"clusters"`                     // cluster names, "*" = all clusters
+	Cron     string   `json:"cron,omitempty" yaml:"cron,omitempty"`         // window start, same format as job schedules
+	Duration string   `json:"duration,omitempty" yaml:"duration,omitempty"` // window length for Cron, e.g. "2h"
+	Start    string   `json:"start,omitempty" yaml:"start,omitempty"`       // RFC 3339, absolute window
+	End      string   `json:"end,omitempty" yaml:"end,omitempty"`           // RFC 3339, absolute window
+	Reason   string   `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// BlackoutCalendar lists periods during which jobs honoring the calendar do not run: jobs
+// naming it in blackoutCalendars and, with MutatingJobs, every job marked mutating. Dates are
+// days in timeZone; the URL serves an iCalendar feed whose events are blackout periods.
+type BlackoutCalendar struct {
+	Name            string   `json:"name" yaml:"name"`
+	Dates           []string `json:"dates,omitempty" yaml:"dates,omitempty"`                     // YYYY-MM-DD or YYYY-MM-DD/YYYY-MM-DD (both days included)
+	URL             string   `json:"url,omitempty" yaml:"url,omitempty"`                         // iCalendar (.ics) feed
+	RefreshInterval string   `json:"refreshInterval,omitempty" yaml:"refreshInterval,omitempty"` // how often the feed is fetched, default 6h
+	MutatingJobs    bool     `json:"mutatingJobs,omitempty" yaml:"mutatingJobs,omitempty"`       // applies to every job marked mutating
+}
+
+// NotificationConfig holds the settings for owner notifications. Owners themselves
+// (name -> email/Slack/webhook) are loaded by the loadOwners job.
+type NotificationConfig struct {
+	SMTPHost     string `json:"smtpHost,omitempty" yaml:"smtpHost,omitempty"`
+	SMTPPort     int    `json:"smtpPort,omitempty" yaml:"smtpPort,omitempty"` // default 25
+	SMTPUser     string `json:"smtpUser,omitempty" yaml:"smtpUser,omitempty"` // optional, PLAIN auth
+	SMTPPassword string `json:"smtpPassword,omitempty" yaml:"smtpPassword,omitempty"`
+	From         string `json:"from,omitempty" yaml:"from,omitempty"`
+	// DefaultOwner receives notifications for clusters whose owner is not in the registry
+	DefaultOwner string `json:"defaultOwner,omitempty" yaml:"defaultOwner,omitempty"`
+	// Syslog and SNMP forward events of the configured severities to NOC tooling, whatever
+	// the owner of the cluster
+	Syslog SyslogConfig `json:"syslog,omitempty" yaml:"syslog,omitempty"`
+	SNMP   SNMPConfig   `json:"snmp,omitempty" yaml:"snmp,omitempty"`
+}
+
+// SyslogConfig forwards events as RFC 5424 syslog messages; off unless Address is set
+type SyslogConfig struct {
+	Address          string   `json:"address,omitempty" yaml:"address,omitempty"`                   // host:port
+	Protocol         string   `json:"protocol,omitempty" yaml:"protocol,omitempty"`                 // udp (default), tcp or tls; tcp and tls frame messages by octet counting
+	Facility         string   `json:"facility,omitempty" yaml:"facility,omitempty"`                 // e.g. daemon, local0 (default)
+	AppName          string   `json:"appName,omitempty" yaml:"appName,omitempty"`                   // default elastic-observability
+	EnterpriseNumber int      `json:"enterpriseNumber,omitempty" yaml:"enterpriseNumber,omitempty"` // of the structured data IDs, default 32473
+	Severities       []string `json:"severities,omitempty" yaml:"severities,omitempty"`             // event severities forwarded, default critical
+	SendResolved     bool     `json:"sendResolved,omitempty" yaml:"sendResolved,omitempty"`         // also forward the resolution of forwarded events
+	InsecureTLS      bool     `json:"insecureTls,omitempty" yaml:"insecureTls,omitempty"`
+	CACert           string   `json:"caCert,omitempty" yaml:"caCert,omitempty"`
+}
+
+// SNMPConfig forwards events as SNMPv2c traps; off unless Address is set. Trap and variable
+// OIDs default to OIDs under EnterpriseOID.
+type SNMPConfig struct {
+	Address         string            `json:"address,omitempty" yaml:"address,omitempty"`                 // host:port, default port 162
+	Community       string            `json:"community,omitempty" yaml:"community,omitempty"`             // default public
+	EnterpriseOID   string            `json:"enterpriseOid,omitempty" yaml:"enterpriseOid,omitempty"`     // e.g. 1.3.6.1.4.1.<PEN>.1
+	TrapOID         string            `json:"trapOid,omitempty" yaml:"trapOid,omitempty"`                 // firing events, default <enterpriseOid>.0.1
+	ResolvedTrapOID string            `json:"resolvedTrapOid,omitempty" yaml:"resolvedTrapOid,omitempty"` // resolved events, default <enterpriseOid>.0.2
+	VariableOIDs    map[string]string `json:"variableOids,omitempty" yaml:"variableOids,omitempty"`       // OID per event field, default <enterpriseOid>.1.<n>
+	Severities      []string          `json:"severities,omitempty" yaml:"severities,omitempty"`           // event severities forwarded, default critical
+	SendResolved    bool              `json:"sendResolved,omitempty" yaml:"sendResolved,omitempty"`       // also forward the resolution of forwarded events
 }
 
 // CertConfig holds certificate paths
@@ -32,15 +394,63 @@ type CertConfig struct {
 
 // JobConfig represents a job configuration
 type JobConfig struct {
-	Name            string                 `json:"name" yaml:"name"`
-	Type            string                 `json:"type" yaml:"type"` // shell, api, func, preDefined
-	InternalJobName string                 `json:"internalJobName" yaml:"internalJobName"`
-	Enabled         bool                   `json:"enabled" yaml:"enabled"`
-	Schedule        *ScheduleConfig        `json:"schedule,omitempty" yaml:"schedule,omitempty"`
-	DependsOn       []string               `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
-	InitJob         bool                   `json:"initJob,omitempty" yaml:"initJob,omitempty"`
+	Name            string          `json:"name" yaml:"name"`
+	Type            string          `json:"type" yaml:"type"` // shell, api, func, preDefined
+	InternalJobName string          `json:"internalJobName" yaml:"internalJobName"`
+	Enabled         bool            `json:"enabled" yaml:"enabled"`
+	Schedule        *ScheduleConfig `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	DependsOn       []string        `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+	InitJob         bool            `json:"initJob,omitempty" yaml:"initJob,omitempty"`
+	// IncludeClusters and ExcludeClusters restrict the clusters the job processes; the scheduler
+	// passes them to the job as the includeClusters and excludeClusters parameters
+	IncludeClusters []string               `json:"includeClusters,omitempty" yaml:"includeClusters,omitempty"`
 	ExcludeClusters []string               `json:"excludeClusters,omitempty" yaml:"excludeClusters,omitempty"`
 	Parameters      map[string]interface{} `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	// Mutating marks jobs that change clusters or other systems; they honor the blackout
+	// calendars with mutatingJobs set
+	Mutating bool `json:"mutating,omitempty" yaml:"mutating,omitempty"`
+	// BlackoutCalendars are the names of the blackout calendars the job honors
+	BlackoutCalendars []string `json:"blackoutCalendars,omitempty" yaml:"blackoutCalendars,omitempty"`
+	// InitPolicy decides what a failure of an initialization job does: InitRequired (default)
+	// aborts startup, InitOptional logs it and continues with the next initialization job
+	InitPolicy string `json:"initPolicy,omitempty" yaml:"initPolicy,omitempty"`
+	// Retry retries failed runs instead of waiting for the next scheduled run
+	Retry *RetryConfig `json:"retry,omitempty" yaml:"retry,omitempty"`
+	// Tenant is set for jobs loaded from a tenant directory (see LoadTenantJobs)
+	Tenant string `json:"tenant,omitempty" yaml:"-"`
+}
+
+// EffectiveParameters returns the parameters a job runs with: its parameters and its
+// job-level includeClusters and excludeClusters
+func (j *JobConfig) EffectiveParameters() map[string]interface{} {
+	if len(j.IncludeClusters) == 0 && len(j.ExcludeClusters) == 0 {
+		return j.Parameters
+	}
+	effective := make(map[string]interface{}, len(j.Parameters)+2)
+	for k, v := range j.Parameters {
+		effective[k] = v
+	}
+	if len(j.IncludeClusters) > 0 {
+		effective["includeClusters"] = j.IncludeClusters
+	}
+	if len(j.ExcludeClusters) > 0 {
+		effective["excludeClusters"] = j.ExcludeClusters
+	}
+	return effective
+}
+
+// Initialization job policies (JobConfig.InitPolicy)
+const (
+	InitRequired = "required"
+	InitOptional = "optional"
+)
+
+// RetryConfig retries a failed run up to MaxRetries times, waiting Backoff before the first
+// retry and twice as long before each further retry, up to MaxBackoff
+type RetryConfig struct {
+	MaxRetries int    `json:"maxRetries" yaml:"maxRetries"`                     // at most 10
+	Backoff    string `json:"backoff,omitempty" yaml:"backoff,omitempty"`       // default 30s
+	MaxBackoff string `json:"maxBackoff,omitempty" yaml:"maxBackoff,omitempty"` // default 10m
 }
 
 // ScheduleConfig represents job scheduling configuration
@@ -48,6 +458,10 @@ type ScheduleConfig struct {
 	Cron        string `json:"cron,omitempty" yaml:"cron,omitempty"`
 	Interval    string `json:"interval,omitempty" yaml:"interval,omitempty"` // e.g., "3m", "1h"
 	InitialWait string `json:"initialWait,omitempty" yaml:"initialWait,omitempty"`
+	// Adaptive stretches the interval while runs take most of it (default true); MaxInterval
+	// bounds the stretched interval (default 4 intervals)
+	Adaptive    *bool  `json:"adaptive,omitempty" yaml:"adaptive,omitempty"`
+	MaxInterval string `json:"maxInterval,omitempty" yaml:"maxInterval,omitempty"`
 }
 
 // CSVMappingConfig represents CSV mapping configuration for loadFromMasterCSV
@@ -64,13 +478,15 @@ type InputMapping struct {
 	Derived  []DerivedField         `json:"derived,omitempty" yaml:"derived,omitempty"`
 }
 
-// DerivedField represents a derived field configuration
+// DerivedField represents a derived field configuration.
+// Either Column/Function/Arg or Expression is used; Expression takes precedence.
 type DerivedField struct {
-	Field    string      `json:"field" yaml:"field"`
-	Column   string      `json:"column" yaml:"column"`
-	Function string      `json:"function" yaml:"function"`
-	Arg      interface{} `json:"arg" yaml:"arg"`
-	RetVal   []string    `json:"retVal,omitempty" yaml:"retVal,omitempty"`
+	Field      string      `json:"field" yaml:"field"`
+	Column     string      `json:"column" yaml:"column"`
+	Function   string      `json:"function" yaml:"function"`
+	Arg        interface{} `json:"arg" yaml:"arg"`
+	RetVal     []string    `json:"retVal,omitempty" yaml:"retVal,omitempty"`
+	Expression string      `json:"expression,omitempty" yaml:"expression,omitempty"`
 }
 
 var (
@@ -127,10 +543,244 @@ func LoadGlobalConfig(configPath string) error {
 	if Global.MetricsPort == 0 {
 		Global.MetricsPort = 9091
 	}
+	if Global.Notifications.SMTPPort == 0 {
+		Global.Notifications.SMTPPort = 25
+	}
+	if syslog := &Global.Notifications.Syslog; syslog.Address != "" {
+		if syslog.Protocol == "" {
+			syslog.Protocol = "udp"
+		}
+		if syslog.Protocol != "udp" && syslog.Protocol != "tcp" && syslog.Protocol != "tls" {
+			return fmt.Errorf("invalid notifications.syslog.protocol %q: must be udp, tcp or tls", syslog.Protocol)
+		}
+		if syslog.Facility == "" {
+			syslog.Facility = "local0"
+		}
+		if syslog.AppName == "" {
+			syslog.AppName = "elastic-observability"
+		}
+		if syslog.EnterpriseNumber == 0 {
+			syslog.EnterpriseNumber = 32473
+		}
+		if len(syslog.Severities) == 0 {
+			syslog.Severities = []string{"critical"}
+		}
+	}
+	if snmp := &Global.Notifications.SNMP; snmp.Address != "" {
+		if snmp.EnterpriseOID == "" {
+			return fmt.Errorf("notifications.snmp.enterpriseOid is required")
+		}
+		if _, _, err := net.SplitHostPort(snmp.Address); err != nil {
+			snmp.Address = net.JoinHostPort(snmp.Address, "162")
+		}
+		if snmp.Community == "" {
+			snmp.Community = "public"
+		}
+		if snmp.TrapOID == "" {
+			snmp.TrapOID = snmp.EnterpriseOID + ".0.1"
+		}
+		if snmp.ResolvedTrapOID == "" {
+			snmp.ResolvedTrapOID = snmp.EnterpriseOID + ".0.2"
+		}
+		if len(snmp.Severities) == 0 {
+			snmp.Severities = []string{"critical"}
+		}
+	}
+	if Global.Events.File == "" {
+		Global.Events.File = "./data/events.json"
+	}
+	if Global.Events.Retention == "" {
+		Global.Events.Retention = "30d"
+	}
+	if Global.Kafka.Format == "" {
+		Global.Kafka.Format = "json"
+	}
+	if Global.Kafka.Format != "json" && Global.Kafka.Format != "avro" {
+		return fmt.Errorf("invalid kafka.format %q: must be json or avro", Global.Kafka.Format)
+	}
+	if Global.Kafka.Acks == 0 {
+		Global.Kafka.Acks = -1
+	}
+	if Global.Kafka.Acks != 1 && Global.Kafka.Acks != -1 {
+		return fmt.Errorf("invalid kafka.acks %d: must be 1 or -1", Global.Kafka.Acks)
+	}
+	if Global.Kafka.ClientID == "" {
+		Global.Kafka.ClientID = "elastic-observability"
+	}
+	if Global.Kafka.Timeout == "" {
+		Global.Kafka.Timeout = "10s"
+	}
+	if Global.Kafka.BufferSize == 0 {
+		Global.Kafka.BufferSize = 10000
+	}
+	if Global.Audit.File == "" {
+		Global.Audit.File = "./data/audit.log"
+	}
+	if Global.Audit.MaxEntries == 0 {
+		Global.Audit.MaxEntries = 10000
+	}
+	if Global.Admin.Address == "" {
+		Global.Admin.Address = "127.0.0.1"
+	}
+	if Global.SelfTelemetry.RunsKept == 0 {
+		Global.SelfTelemetry.RunsKept = 50
+	}
+	if Global.SettingsBaselineDir == "" {
+		Global.SettingsBaselineDir = "./data/settingsBaselines"
+	}
+	if Global.TimeZone == "" {
+		Global.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(Global.TimeZone); err != nil {
+		return fmt.Errorf("invalid timeZone %q: %w", Global.TimeZone, err)
+	}
+	for i, token := range Global.APITokens {
+		if token.Name == "" || token.Token == "" {
+			return fmt.Errorf("apiTokens[%d]: name and token are required", i)
+		}
+	}
+	for i, permission := range Global.JobPermissions {
+		if len(permission.Jobs) == 0 || len(permission.Roles) == 0 {
+			return fmt.Errorf("jobPermissions[%d]: jobs and roles are required", i)
+		}
+	}
+	for name, jobs := range Global.JobGroups {
+		if len(jobs) == 0 {
+			return fmt.Errorf("jobGroups.%s: at least one job is required", name)
+		}
+	}
+	if basePath := Global.HTTP.BasePath; basePath != "" {
+		if !strings.HasPrefix(basePath, "/") {
+			return fmt.Errorf("invalid http.basePath %q: must start with /", basePath)
+		}
+		Global.HTTP.BasePath = strings.TrimRight(basePath, "/")
+	}
+	for _, proxy := range Global.HTTP.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("invalid http.trustedProxies entry %q: must be an IP or CIDR", proxy)
+		}
+	}
+	if Global.HTTP.CORS.MaxAge == 0 {
+		Global.HTTP.CORS.MaxAge = 600
+	}
+	for i, metadata := range Global.EventMetadata {
+		if metadata.Source == "" {
+			return fmt.Errorf("eventMetadata[%d]: source is required", i)
+		}
+		if metadata.RunbookURL == "" && metadata.DashboardURL == "" && metadata.Owner == "" {
+			return fmt.Errorf("eventMetadata[%d]: runbookUrl, dashboardUrl or owner is required", i)
+		}
+	}
+	if err := validateRemediations(&Global.Remediations); err != nil {
+		return err
+	}
+	if err := validateMonitoringClusters(Global.MonitoringClusters); err != nil {
+		return err
+	}
+	for i, rule := range Global.HostNames.Relabel {
+		pattern, err := regexp.Compile("^(?:" + rule.Match + ")$")
+		if rule.Match == "" || err != nil {
+			return fmt.Errorf("hostNames.relabel[%d]: invalid match %q", i, rule.Match)
+		}
+		Global.HostNames.relabel = append(Global.HostNames.relabel, pattern)
+	}
+	if Global.LegacyAPISunset != "" {
+		if _, err := time.Parse("2006-01-02", Global.LegacyAPISunset); err != nil {
+			return fmt.Errorf("invalid legacyApiSunset %q: must be YYYY-MM-DD", Global.LegacyAPISunset)
+		}
+	}
 
 	return nil
 }
 
+// validateMonitoringClusters checks the names, endpoints and routing patterns of the
+// monitoring clusters; at most one may be the default
+func validateMonitoringClusters(monitoringClusters []MonitoringCluster) error {
+	names := make(map[string]bool)
+	defaultCluster := ""
+	for i, m := range monitoringClusters {
+		if m.Name == "" {
+			return fmt.Errorf("monitoringClusters[%d]: name is required", i)
+		}
+		if names[m.Name] {
+			return fmt.Errorf("monitoringClusters[%d]: duplicate name %s", i, m.Name)
+		}
+		names[m.Name] = true
+		if len(m.Endpoints) == 0 || m.APIKey == "" {
+			return fmt.Errorf("monitoringClusters.%s: endpoints and apiKey are required", m.Name)
+		}
+		for _, pattern := range m.Clusters {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("monitoringClusters.%s: invalid cluster pattern %q", m.Name, pattern)
+			}
+		}
+		if m.IsDefault() {
+			if defaultCluster != "" {
+				return fmt.Errorf("monitoringClusters: %s and %s both have no routing rules", defaultCluster, m.Name)
+			}
+			defaultCluster = m.Name
+		}
+	}
+	return nil
+}
+
+// remediationTypes are the supported remediation action types
+var remediationTypes = []string{"refreshInterval", "replicas", "disablePipeline"}
+
+// validateRemediations sets the defaults of the remediations and checks every action
+func validateRemediations(cfg *RemediationsConfig) error {
+	if cfg.File == "" {
+		cfg.File = "./data/remediations.json"
+	}
+	names := make(map[string]bool)
+	for i := range cfg.Actions {
+		action := &cfg.Actions[i]
+		if action.Name == "" || action.Indices == "" {
+			return fmt.Errorf("remediations.actions[%d]: name and indices are required", i)
+		}
+		if names[action.Name] {
+			return fmt.Errorf("remediations.actions[%d]: duplicate name %q", i, action.Name)
+		}
+		names[action.Name] = true
+
+		switch action.Type {
+		case "refreshInterval":
+			if action.Value != "-1" {
+				if _, err := time.ParseDuration(action.Value); err != nil {
+					return fmt.Errorf("remediations.actions[%d]: invalid refresh interval %q", i, action.Value)
+				}
+			}
+		case "replicas":
+			if replicas, err := strconv.Atoi(action.Value); err != nil || replicas < 0 {
+				return fmt.Errorf("remediations.actions[%d]: invalid number of replicas %q", i, action.Value)
+			}
+		case "disablePipeline":
+			if action.Pipeline == "" {
+				return fmt.Errorf("remediations.actions[%d]: pipeline is required", i)
+			}
+		default:
+			return fmt.Errorf("remediations.actions[%d]: invalid type %q: must be one of %s", i, action.Type, strings.Join(remediationTypes, ", "))
+		}
+
+		if action.On != nil && action.On.Source == "" {
+			return fmt.Errorf("remediations.actions[%d]: on.source is required", i)
+		}
+		if len(action.Clusters) == 0 {
+			action.Clusters = []string{"*"}
+		}
+		if action.RollbackAfter == "" {
+			action.RollbackAfter = "1h"
+		}
+		if action.Cooldown == "" {
+			action.Cooldown = "6h"
+		}
+		if action.MaxIndices == 0 {
+			action.MaxIndices = 50
+		}
+	}
+	return nil
+}
+
 // LoadInitializationJobs loads initialization job configurations from initialization_jobs file
 func LoadInitializationJobs(configDir string) ([]*JobConfig, error) {
 	// Try YAML first
@@ -177,6 +827,67 @@ func LoadScheduledJobs(configDir string) ([]*JobConfig, error) {
 	return nil, fmt.Errorf("scheduled_jobs file not found in %s", configDir)
 }
 
+// LoadTenantJobs loads the scheduled jobs of every tenant directory
+// (<configDir>/tenants/<tenant>/scheduled_jobs.yaml). Each job is renamed <tenant>.<name>,
+// dependencies on jobs of the same file are renamed alike, and the tenant is passed to the
+// job as its "tenant" parameter so it only processes the tenant's clusters.
+func LoadTenantJobs(configDir string) ([]*JobConfig, error) {
+	tenantsDir := filepath.Join(configDir, "tenants")
+	entries, err := os.ReadDir(tenantsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants directory: %w", err)
+	}
+
+	var jobs []*JobConfig
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tenant := entry.Name()
+		tenantJobs, err := LoadScheduledJobs(filepath.Join(tenantsDir, tenant))
+		if err != nil && !hasScheduledJobsFile(filepath.Join(tenantsDir, tenant)) {
+			continue // a tenant directory without jobs
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+
+		names := make(map[string]bool, len(tenantJobs))
+		for _, job := range tenantJobs {
+			names[job.Name] = true
+		}
+		for _, job := range tenantJobs {
+			job.Name = tenant + "." + job.Name
+			for i, dep := range job.DependsOn {
+				if names[dep] {
+					job.DependsOn[i] = tenant + "." + dep
+				}
+			}
+			job.InitJob = false
+			job.Tenant = tenant
+			if job.Parameters == nil {
+				job.Parameters = make(map[string]interface{})
+			}
+			job.Parameters["tenant"] = tenant
+		}
+		jobs = append(jobs, tenantJobs...)
+	}
+	return jobs, nil
+}
+
+// hasScheduledJobsFile reports whether a directory has a scheduled_jobs file
+func hasScheduledJobsFile(dir string) bool {
+	for _, name := range []string{"scheduled_jobs.yaml", "scheduled_jobs.yml", "scheduled_jobs.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // LoadJobConfigs loads job configurations from a directory (kept for backward compatibility)
 func LoadJobConfigs(configDir string) ([]*JobConfig, error) {
 	var jobs []*JobConfig
@@ -0,0 +1,422 @@
+// Package events is the event store. An event is a condition observed by a job (write
+// pressure on a host, a firing alert rule, ...) with a firing -> resolved lifecycle.
+//
+// Jobs report the conditions they currently observe with Sync: a new condition fires an
+// event, a condition that is observed again keeps its event firing, and a firing event whose
+// condition is no longer observed is resolved. Events get the runbook, dashboard and owner
+// configured for them as annotations (see Enrich). Events are kept for the configured retention
+// after they resolve and are persisted to a JSON file, so they survive restarts.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/maintenance"
+	"ElasticObservability/pkg/metrics"
+)
+
+// Event states
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Event is a condition observed by a job, from the first time it was observed until it resolved
+type Event struct {
+	ID          string            `json:"id"`
+	Source      string            `json:"source"` // reporting subsystem, e.g. "writePressure", "rules"
+	Name        string            `json:"name"`   // e.g. "WritePressure" or the rule name
+	Severity    string            `json:"severity,omitempty"`
+	State       string            `json:"state"`
+	Labels      map[string]string `json:"labels"` // identify the entity, e.g. cluster and host
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    int64             `json:"startsAt"`         // epoch milliseconds (UTC) the condition started
+	EndsAt      int64             `json:"endsAt,omitempty"` // epoch milliseconds (UTC), resolved events only
+	UpdatedAt   int64             `json:"updatedAt"`        // epoch milliseconds (UTC) last observed or resolved
+	// Suppressed is set for events that fired while the cluster was in a maintenance window
+	Suppressed        bool   `json:"suppressed,omitempty"`
+	MaintenanceReason string `json:"maintenanceReason,omitempty"`
+}
+
+// Cluster returns the cluster the event is about
+func (e *Event) Cluster() string {
+	return e.Labels["cluster"]
+}
+
+// Duration returns how long the condition held (until now while firing)
+func (e *Event) Duration(now time.Time) time.Duration {
+	end := e.EndsAt
+	if e.State == StateFiring {
+		end = now.UnixMilli()
+	}
+	return time.Duration(end-e.StartsAt) * time.Millisecond
+}
+
+// Observation is a condition a job currently observes
+type Observation struct {
+	Name        string
+	Severity    string
+	Labels      map[string]string
+	Annotations map[string]string
+	StartsAt    int64 // epoch milliseconds (UTC), 0 = now
+}
+
+// storeFile is the layout of the persisted store
+type storeFile struct {
+	NextID int      `json:"nextId"`
+	Events []*Event `json:"events"`
+}
+
+var (
+	mu        sync.RWMutex
+	events    = make(map[string]*Event) // key: ID
+	firing    = make(map[string]*Event) // key: source + name + label fingerprint
+	nextID    int
+	path      string        // persistence file, "" = memory only
+	retention time.Duration // how long resolved events are kept, 0 = forever
+
+	subscribersMu sync.RWMutex
+	subscribers   []func(Event)
+)
+
+// Subscribe registers a function called with every event that fires or resolves, after the
+// store is updated. Subscribers are called synchronously by the reporting job and must not
+// block.
+func Subscribe(fn func(Event)) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+// notify calls the subscribers with the events that fired and resolved
+func notify(fired, resolved []Event) {
+	subscribersMu.RLock()
+	defer subscribersMu.RUnlock()
+	for _, fn := range subscribers {
+		for _, event := range fired {
+			fn(event)
+		}
+		for _, event := range resolved {
+			fn(event)
+		}
+	}
+}
+
+// Configure sets the persistence file and the retention of resolved events and loads the
+// events stored in the file (a missing file is an empty store)
+func Configure(file string, keep time.Duration) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	path = file
+	retention = keep
+	events = make(map[string]*Event)
+	firing = make(map[string]*Event)
+	nextID = 0
+
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read event store: %w", err)
+	}
+
+	var stored storeFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to parse event store %s: %w", path, err)
+	}
+	nextID = stored.NextID
+	for _, event := range stored.Events {
+		events[event.ID] = event
+		if event.State == StateFiring {
+			firing[key(event.Source, event.Name, event.Labels)] = event
+		}
+	}
+	updateMetrics()
+	return nil
+}
+
+// key identifies the condition of an event within its source
+func key(source, name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(source)
+	b.WriteByte('|')
+	b.WriteString(name)
+	b.WriteByte('|')
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// Sync reconciles the firing events of a source with the conditions the source observes at
+// now and returns the events that fired and resolved. Firing events of the source that are
+// not observed any more are resolved.
+func Sync(source string, observed []Observation, now time.Time) (fired, resolved []Event, err error) {
+	nowMs := now.UnixMilli()
+
+	// Subscribers are notified once the store is unlocked
+	defer func() { notify(fired, resolved) }()
+	mu.Lock()
+	defer mu.Unlock()
+
+	seen := make(map[string]bool, len(observed))
+	for _, o := range observed {
+		k := key(source, o.Name, o.Labels)
+		seen[k] = true
+		o.Annotations = Enrich(source, o.Name, o.Labels, o.Annotations)
+
+		if event, ok := firing[k]; ok {
+			event.Severity = o.Severity
+			event.Annotations = o.Annotations
+			event.UpdatedAt = nowMs
+			continue
+		}
+
+		nextID++
+		event := &Event{
+			ID:          strconv.Itoa(nextID),
+			Source:      source,
+			Name:        o.Name,
+			Severity:    o.Severity,
+			State:       StateFiring,
+			Labels:      o.Labels,
+			Annotations: o.Annotations,
+			StartsAt:    o.StartsAt,
+			UpdatedAt:   nowMs,
+		}
+		if event.StartsAt == 0 {
+			event.StartsAt = nowMs
+		}
+		if reason, ok := maintenance.InMaintenance(event.Cluster(), now); ok {
+			event.Suppressed = true
+			event.MaintenanceReason = reason
+		} else if reason, ok := maintenance.Decommissioning(event.Cluster(), event.Labels["host"]); ok {
+			event.Suppressed = true
+			event.MaintenanceReason = reason
+		}
+		events[event.ID] = event
+		firing[k] = event
+		fired = append(fired, *event)
+		metrics.EventsTotal.WithLabelValues(source, StateFiring).Inc()
+	}
+
+	for k, event := range firing {
+		if event.Source != source || seen[k] {
+			continue
+		}
+		event.State = StateResolved
+		event.EndsAt = nowMs
+		event.UpdatedAt = nowMs
+		delete(firing, k)
+		resolved = append(resolved, *event)
+		metrics.EventsTotal.WithLabelValues(source, StateResolved).Inc()
+	}
+
+	pruned := prune(now)
+	updateMetrics()
+
+	if len(fired) > 0 || len(resolved) > 0 || pruned > 0 {
+		err = save()
+	}
+	return fired, resolved, err
+}
+
+// RenameCluster moves the events of a cluster to its new name and returns how many moved. A
+// firing event of the old name whose condition fires under the new name already (the new name
+// was collected before the rename) is resolved, the event of the new name keeps firing.
+func RenameCluster(oldName, newName string, now time.Time) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	nowMs := now.UnixMilli()
+	renamed := 0
+	for _, event := range events {
+		if event.Cluster() != oldName {
+			continue
+		}
+		oldKey := key(event.Source, event.Name, event.Labels)
+
+		// Copies handed out by Query share the labels, so they are replaced, not modified
+		labels := make(map[string]string, len(event.Labels))
+		for k, v := range event.Labels {
+			labels[k] = v
+		}
+		labels["cluster"] = newName
+		event.Labels = labels
+		renamed++
+
+		if event.State != StateFiring {
+			continue
+		}
+		delete(firing, oldKey)
+		newKey := key(event.Source, event.Name, labels)
+		if _, ok := firing[newKey]; ok {
+			event.State = StateResolved
+			event.EndsAt = nowMs
+			event.UpdatedAt = nowMs
+			metrics.EventsTotal.WithLabelValues(event.Source, StateResolved).Inc()
+			continue
+		}
+		firing[newKey] = event
+	}
+
+	if renamed == 0 {
+		return 0, nil
+	}
+	updateMetrics()
+	return renamed, save()
+}
+
+// prune drops resolved events older than the retention; callers hold mu
+func prune(now time.Time) int {
+	if retention <= 0 {
+		return 0
+	}
+	cutoff := now.Add(-retention).UnixMilli()
+	removed := 0
+	for id, event := range events {
+		if event.State == StateResolved && event.EndsAt < cutoff {
+			delete(events, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// save writes the store to its file; callers hold mu
+func save() error {
+	if path == "" {
+		return nil
+	}
+
+	stored := storeFile{NextID: nextID, Events: make([]*Event, 0, len(events))}
+	for _, event := range events {
+		stored.Events = append(stored.Events, event)
+	}
+	sort.Slice(stored.Events, func(i, j int) bool { return less(stored.Events[i], stored.Events[j]) })
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal events: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create event store directory: %w", err)
+	}
+
+	// Write and rename, so a crash never leaves a truncated store behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write event store: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write event store: %w", err)
+	}
+	return nil
+}
+
+// updateMetrics refreshes the event gauges; callers hold mu
+func updateMetrics() {
+	perSource := make(map[string]float64)
+	for _, event := range firing {
+		perSource[event.Source]++
+	}
+	metrics.EventsFiring.Reset()
+	for source, count := range perSource {
+		metrics.EventsFiring.WithLabelValues(source).Set(count)
+	}
+	metrics.EventsStored.Set(float64(len(events)))
+}
+
+// less orders events by start time, then ID
+func less(a, b *Event) bool {
+	if a.StartsAt != b.StartsAt {
+		return a.StartsAt < b.StartsAt
+	}
+	ai, _ := strconv.Atoi(a.ID)
+	bi, _ := strconv.Atoi(b.ID)
+	return ai < bi
+}
+
+// Filter selects events; zero fields match everything
+type Filter struct {
+	Source   string
+	Name     string
+	State    string
+	Severity string
+	Cluster  string
+	From     int64 // epoch milliseconds (UTC); events that ended before From are excluded
+	To       int64 // epoch milliseconds (UTC); events that started after To are excluded
+}
+
+// matches reports whether an event passes the filter
+func (f Filter) matches(event *Event) bool {
+	if (f.Source != "" && event.Source != f.Source) ||
+		(f.Name != "" && event.Name != f.Name) ||
+		(f.State != "" && event.State != f.State) ||
+		(f.Severity != "" && event.Severity != f.Severity) ||
+		(f.Cluster != "" && event.Cluster() != f.Cluster) {
+		return false
+	}
+	// The event overlaps [From, To]
+	if f.To > 0 && event.StartsAt > f.To {
+		return false
+	}
+	if f.From > 0 && event.State == StateResolved && event.EndsAt < f.From {
+		return false
+	}
+	return true
+}
+
+// Query returns copies of the events passing the filter, sorted by start time
+func Query(f Filter) []Event {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	selected := make([]*Event, 0)
+	for _, event := range events {
+		if f.matches(event) {
+			selected = append(selected, event)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return less(selected[i], selected[j]) })
+
+	result := make([]Event, 0, len(selected))
+	for _, event := range selected {
+		result = append(result, *event)
+	}
+	return result
+}
+
+// Get returns a copy of an event by ID
+func Get(id string) (Event, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	event, ok := events[id]
+	if !ok {
+		return Event{}, false
+	}
+	return *event, true
+}
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"regexp"
+	"sync"
+
+	"ElasticObservability/pkg/config"
+)
+
+// Annotations holding the responder metadata of an event
+const (
+	AnnotationRunbookURL   = "runbookUrl"
+	AnnotationDashboardURL = "dashboardUrl"
+	AnnotationOwner        = "owner"
+)
+
+var (
+	metadataMu sync.RWMutex
+	metadata   []config.EventMetadata
+)
+
+var placeholderRegex = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.]+)\s*\}\}`)
+
+// ConfigureMetadata sets the responder metadata added to events
+func ConfigureMetadata(configured []config.EventMetadata) {
+	metadataMu.Lock()
+	defer metadataMu.Unlock()
+	metadata = configured
+}
+
+// Enrich returns the annotations of an event completed with the runbook URL, dashboard URL
+// and owner configured for its source and name. The metadata of the name wins over the
+// metadata of the whole source, and annotations already set (e.g. from the fields of an alert
+// rule) are kept.
+func Enrich(source, name string, labels, annotations map[string]string) map[string]string {
+	metadataMu.RLock()
+	defer metadataMu.RUnlock()
+
+	var forSource, forName *config.EventMetadata
+	for i := range metadata {
+		m := &metadata[i]
+		switch {
+		case m.Source != source:
+		case m.Name == name && forName == nil:
+			forName = m
+		case m.Name == "" && forSource == nil:
+			forSource = m
+		}
+	}
+	if forName == nil && forSource == nil {
+		return annotations
+	}
+
+	enriched := make(map[string]string, len(annotations)+3)
+	for k, v := range annotations {
+		enriched[k] = v
+	}
+	for _, m := range []*config.EventMetadata{forName, forSource} {
+		if m == nil {
+			continue
+		}
+		setMissing(enriched, AnnotationRunbookURL, m.RunbookURL, labels)
+		setMissing(enriched, AnnotationDashboardURL, m.DashboardURL, labels)
+		setMissing(enriched, AnnotationOwner, m.Owner, labels)
+	}
+	return enriched
+}
+
+// setMissing sets an annotation to a template expanded from the labels, unless it is set or
+// the template is empty
+func setMissing(annotations map[string]string, key, template string, labels map[string]string) {
+	if template == "" || annotations[key] != "" {
+		return
+	}
+	annotations[key] = placeholderRegex.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if value, ok := labels[placeholderRegex.FindStringSubmatch(placeholder)[1]]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
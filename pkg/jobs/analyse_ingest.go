@@ -2,43 +2,38 @@ package jobs
 
 import (
 	"context"
+	"time"
 
 	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
 	"ElasticObservability/pkg/types"
-	"ElasticObservability/pkg/utils"
 )
 
+// defaultRateHistorySize keeps 24 hours of indexing rates at the usual 3 minute interval
+const defaultRateHistorySize = 480
+
 // AnalyseIngest analyzes indexing rates based on historical data
 func AnalyseIngest(ctx context.Context, params map[string]interface{}) error {
 	logger.JobInfo("analyseIngest", "Starting indexing rate analysis")
 
-	// Get exclude list
-	excludeClusters := make([]string, 0)
-	if exclude, ok := params["excludeClusters"].([]interface{}); ok {
-		for _, item := range exclude {
-			if str, ok := item.(string); ok {
-				excludeClusters = append(excludeClusters, str)
-			}
-		}
+	p := jobparams.New(params)
+	selection := clusterSelectionFromParams(p)
+	historySize := p.IntInRange("historySize", defaultRateHistorySize, 1, 2880)
+	if err := checkParams("analyseIngest", p); err != nil {
+		return err
 	}
 
-	// Get a deep copy of all history (copying pointers)
-	types.HistoryMu.RLock()
-	historyCopy := make(map[string]*types.IndicesHistory)
-	for clusterName, history := range types.AllHistory {
-		if history != nil {
-			historyCopy[clusterName] = history.GetCopy()
-		}
-	}
-	types.HistoryMu.RUnlock()
+	// Get a copy of all history (copying pointers)
+	historyCopy := types.SnapshotHistories()
 
 	processedCount := 0
 	skippedCount := 0
 
 	// Process each cluster
 	for clusterName, history := range historyCopy {
-		// Skip excluded clusters
-		if utils.Contains(excludeClusters, clusterName) {
+		// Skip the clusters the job does not select
+		if !selection.selects(clusterName) {
 			logger.JobInfo("analyseIngest", "Skipping excluded cluster: %s", clusterName)
 			skippedCount++
 			continue
@@ -51,11 +46,14 @@ func AnalyseIngest(ctx context.Context, params map[string]interface{}) error {
 			skippedCount++
 			continue
 		}
+		if clusterRate == nil {
+			logger.JobInfo("analyseIngest", "Cluster %s: No indices snapshot yet", clusterName)
+			skippedCount++
+			continue
+		}
 
-		// Store the indexing rate (thread-safe)
-		types.IndexingRateMu.Lock()
-		types.AllIndexingRate[clusterName] = clusterRate
-		types.IndexingRateMu.Unlock()
+		types.SetIndexingRate(clusterName, clusterRate, historySize)
+		recordIngestTotals(clusterName, clusterRate.Total)
 
 		processedCount++
 		logger.JobInfo("analyseIngest", "Cluster %s: Calculated rates for %d indices",
@@ -66,53 +64,57 @@ func AnalyseIngest(ctx context.Context, params map[string]interface{}) error {
 	return nil
 }
 
-func calculateClusterIndexingRate(clusterName string, history *types.IndicesHistory) (*types.ClusterIndexingRate, error) {
-	// Find the latest snapshot index
-	latestIdx := history.GetLatestIndex()
-	if latestIdx < 0 {
-		return nil, nil // No data yet
-	}
-
-	// Get snapshot pointers for different time windows
-	p_0 := history.Ptr[latestIdx]
-	if p_0 == nil {
-		return nil, nil
-	}
-
-	// Find previous snapshots for time windows
-	// Assuming 3 minute intervals: p_1 = 3min ago, p_5 = 15min ago, p_20 = 60min ago
-	var p_1, p_5, p_20 *types.IndicesSnapShot
+// rateLookbacks are the windows rates are computed over; a window uses the snapshot closest
+// to its lookback, within rateLookbackTolerance of it, since runs are not exactly on schedule
+var rateLookbacks = []time.Duration{3 * time.Minute, 15 * time.Minute, 60 * time.Minute}
 
-	if latestIdx >= 1 && history.Ptr[latestIdx-1] != nil {
-		p_1 = history.Ptr[latestIdx-1]
-	}
+// rateLookbackTolerance is the largest deviation of a window from its lookback, as a fraction
+// of the lookback: a window spans at most twice its lookback
+const rateLookbackTolerance = 1.0
 
-	if latestIdx >= 5 && history.Ptr[latestIdx-5] != nil {
-		p_5 = history.Ptr[latestIdx-5]
+func calculateClusterIndexingRate(clusterName string, history *types.IndicesHistory) (*types.ClusterIndexingRate, error) {
+	// The latest snapshot and the earlier snapshot of each window, read together
+	lookbacks := make([]int64, len(rateLookbacks))
+	for i, window := range rateLookbacks {
+		lookbacks[i] = window.Milliseconds()
 	}
-
-	if latestIdx >= 20 && history.Ptr[latestIdx-20] != nil {
-		p_20 = history.Ptr[latestIdx-20]
+	p_0, previous := history.LatestAndClosest(lookbacks, rateLookbackTolerance)
+	if p_0 == nil {
+		return nil, nil // No data yet
 	}
-
-	// Get timestamps
 	t_0 := p_0.SnapShotTime
-	var t_1, t_5, t_20 int64
 
-	if p_1 != nil {
-		t_1 = p_1.SnapShotTime
-	}
-	if p_5 != nil {
-		t_5 = p_5.SnapShotTime
+	// The actual span of each window, by the SnapShotTime of its earlier snapshot
+	windows := make([]int64, len(rateLookbacks))
+	for i, snapshot := range previous {
+		if snapshot != nil {
+			windows[i] = t_0 - snapshot.SnapShotTime
+		}
 	}
-	if p_20 != nil {
-		t_20 = p_20.SnapShotTime
+
+	// The earlier snapshots hold the index of a base under its name at the time
+	previousByBase := make([]map[string]*types.IndexInfo, len(previous))
+	for i, snapshot := range previous {
+		if snapshot == nil {
+			continue
+		}
+		previousByBase[i] = make(map[string]*types.IndexInfo, len(snapshot.MapIndices))
+		for _, index := range snapshot.MapIndices {
+			if index != nil {
+				previousByBase[i][index.IndexBase] = index
+			}
+		}
 	}
 
 	// Create cluster indexing rate structure
 	clusterRate := &types.ClusterIndexingRate{
 		Timestamp:  t_0,
 		MapIndices: make(map[string]*types.IndexingRate),
+		Windows: types.RateWindows{
+			Last3Minutes:  windows[0],
+			Last15Minutes: windows[1],
+			Last60Minutes: windows[2],
+		},
 	}
 
 	// Process each index in the latest snapshot
@@ -124,9 +126,6 @@ func calculateClusterIndexingRate(clusterName string, history *types.IndicesHist
 		indexRate := &types.IndexingRate{
 			NumberOfShards: currentIndex.PrimaryShards,
 			FromCreation:   -1,
-			Last3Minutes:   -1,
-			Last15Minutes:  -1,
-			Last60Minutes:  -1,
 		}
 
 		numberOfShards := float64(currentIndex.PrimaryShards)
@@ -143,48 +142,77 @@ func calculateClusterIndexingRate(clusterName string, history *types.IndicesHist
 			}
 		}
 
-		// Calculate rate for last 3 minutes
-		if p_1 != nil && t_1 > 0 {
-			if prevIndex, exists := p_1.MapIndices[indexBase]; exists && prevIndex != nil {
-				// Check if index rolled over
-				if currentIndex.SeqNo == prevIndex.SeqNo {
-					timeDiff := float64(t_0 - t_1)
-					if timeDiff > 0 && currentIndex.PrimaryStorage >= prevIndex.PrimaryStorage {
-						storageDiff := float64(currentIndex.PrimaryStorage - prevIndex.PrimaryStorage)
-						indexRate.Last3Minutes = (storageDiff * 1000) / (numberOfShards * timeDiff)
-					}
-				}
+		rates := []*float64{&indexRate.Last3Minutes, &indexRate.Last15Minutes, &indexRate.Last60Minutes}
+		for i, rate := range rates {
+			*rate = -1
+			if previous[i] == nil || windows[i] <= 0 {
+				continue
 			}
-		}
-
-		// Calculate rate for last 15 minutes
-		if p_5 != nil && t_5 > 0 {
-			if prevIndex, exists := p_5.MapIndices[indexBase]; exists && prevIndex != nil {
-				if currentIndex.SeqNo == prevIndex.SeqNo {
-					timeDiff := float64(t_0 - t_5)
-					if timeDiff > 0 && currentIndex.PrimaryStorage >= prevIndex.PrimaryStorage {
-						storageDiff := float64(currentIndex.PrimaryStorage - prevIndex.PrimaryStorage)
-						indexRate.Last15Minutes = (storageDiff * 1000) / (numberOfShards * timeDiff)
-					}
-				}
-			}
-		}
-
-		// Calculate rate for last 60 minutes
-		if p_20 != nil && t_20 > 0 {
-			if prevIndex, exists := p_20.MapIndices[indexBase]; exists && prevIndex != nil {
-				if currentIndex.SeqNo == prevIndex.SeqNo {
-					timeDiff := float64(t_0 - t_20)
-					if timeDiff > 0 && currentIndex.PrimaryStorage >= prevIndex.PrimaryStorage {
-						storageDiff := float64(currentIndex.PrimaryStorage - prevIndex.PrimaryStorage)
-						indexRate.Last60Minutes = (storageDiff * 1000) / (numberOfShards * timeDiff)
-					}
-				}
+			growth, ok := storageGrowth(currentIndex, p_0.Previous[currentIndex.IndexBase], previousByBase[i][currentIndex.IndexBase])
+			if !ok {
+				continue
 			}
+			*rate = (float64(growth) * 1000) / (numberOfShards * float64(windows[i]))
 		}
 
 		clusterRate.MapIndices[indexBase] = indexRate
 	}
 
+	clusterRate.Total = clusterIngestRate(clusterRate.MapIndices)
 	return clusterRate, nil
 }
+
+// storageGrowth returns the primary storage an index base gained since an earlier snapshot,
+// where it was at then. Across a rollover, that is the growth of the generation it was at
+// (now the previous generation) plus the storage of the new one; a window spanning more than
+// one rollover, or an earlier generation that is gone, has no growth.
+func storageGrowth(current, currentPrevious, then *types.IndexInfo) (uint64, bool) {
+	if then == nil {
+		return 0, false
+	}
+	if current.SeqNo == then.SeqNo {
+		if current.PrimaryStorage < then.PrimaryStorage {
+			return 0, false
+		}
+		return current.PrimaryStorage - then.PrimaryStorage, true
+	}
+	if currentPrevious == nil || currentPrevious.SeqNo != then.SeqNo || currentPrevious.PrimaryStorage < then.PrimaryStorage {
+		return 0, false
+	}
+	return current.PrimaryStorage + currentPrevious.PrimaryStorage - then.PrimaryStorage, true
+}
+
+// clusterIngestRate sums the per-shard rates of the indices times their primary shards.
+// Indices without a rate for a window are left out; a window no index has a rate for is -1.
+func clusterIngestRate(indices map[string]*types.IndexingRate) types.ClusterIngestRate {
+	total := types.ClusterIngestRate{Last3Minutes: -1, Last15Minutes: -1, Last60Minutes: -1}
+	add := func(sum *float64, rate float64, shards uint8) {
+		if rate < 0 {
+			return
+		}
+		if *sum < 0 {
+			*sum = 0
+		}
+		if shards == 0 {
+			shards = 1
+		}
+		*sum += rate * float64(shards)
+	}
+	for _, rate := range indices {
+		add(&total.Last3Minutes, rate.Last3Minutes, rate.NumberOfShards)
+		add(&total.Last15Minutes, rate.Last15Minutes, rate.NumberOfShards)
+		add(&total.Last60Minutes, rate.Last60Minutes, rate.NumberOfShards)
+	}
+	return total
+}
+
+// recordIngestTotals exports the cluster ingest rate; windows without data are not exported
+func recordIngestTotals(clusterName string, total types.ClusterIngestRate) {
+	for window, rate := range map[string]float64{"3m": total.Last3Minutes, "15m": total.Last15Minutes, "60m": total.Last60Minutes} {
+		if rate < 0 {
+			metrics.ClusterIngestBytesPerSecond.DeleteLabelValues(clusterName, window)
+			continue
+		}
+		metrics.ClusterIngestBytesPerSecond.WithLabelValues(clusterName, window).Set(rate)
+	}
+}
@@ -0,0 +1,159 @@
+package jobs
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"ElasticObservability/pkg/types"
+)
+
+// TestCalculateClusterIndexingRateWindows checks that each window spans from the latest
+// snapshot to the earlier snapshot closest to its lookback
+func TestCalculateClusterIndexingRateWindows(t *testing.T) {
+	const t0 = int64(1_700_000_000_000)
+	const minute = int64(60_000)
+	history := types.NewIndicesHistory(30)
+	for _, age := range []int64{70, 61, 14, 3, 0} {
+		history.AddSnapshot(&types.IndicesSnapShot{SnapShotTime: t0 - age*minute, MapIndices: map[string]*types.IndexInfo{}})
+	}
+
+	rate, err := calculateClusterIndexingRate("windows", history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := types.RateWindows{Last3Minutes: 3 * minute, Last15Minutes: 14 * minute, Last60Minutes: 61 * minute}
+	if rate == nil || rate.Timestamp != t0 || rate.Windows != want {
+		t.Errorf("rate = %+v, want windows %+v at %d", rate, want, t0)
+	}
+
+	if rate, err := calculateClusterIndexingRate("empty", types.NewIndicesHistory(30)); rate != nil || err != nil {
+		t.Errorf("rate of an empty history = %+v, %v, want nil", rate, err)
+	}
+}
+
+// TestStorageGrowth checks the growth of an index base between an earlier snapshot and the
+// latest, within a generation and across rollovers
+func TestStorageGrowth(t *testing.T) {
+	index := func(seqNo, primaryStorage uint64) *types.IndexInfo {
+		return &types.IndexInfo{IndexBase: "logs", SeqNo: seqNo, PrimaryStorage: primaryStorage}
+	}
+	tests := []struct {
+		name            string
+		current         *types.IndexInfo
+		currentPrevious *types.IndexInfo
+		then            *types.IndexInfo
+		want            uint64
+		wantOK          bool
+	}{
+		{"same generation", index(1, 1500), nil, index(1, 1000), 500, true},
+		{"same generation without growth", index(1, 1000), nil, index(1, 1000), 0, true},
+		{"same generation shrunk", index(1, 900), nil, index(1, 1000), 0, false},
+		{"rollover between the snapshots", index(2, 300), index(1, 1500), index(1, 1000), 800, true},
+		{"rollover right after the earlier snapshot", index(2, 300), index(1, 1000), index(1, 1000), 300, true},
+		{"rollover onto an empty generation", index(2, 0), index(1, 1200), index(1, 1000), 200, true},
+		{"two rollovers", index(3, 300), index(2, 1500), index(1, 1000), 0, false},
+		{"previous generation deleted", index(2, 300), nil, index(1, 1000), 0, false},
+		{"previous generation shrunk", index(2, 300), index(1, 900), index(1, 1000), 0, false},
+		{"index base new since the earlier snapshot", index(1, 300), nil, nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := storageGrowth(tt.current, tt.currentPrevious, tt.then)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("storageGrowth = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// TestCalculateClusterIndexingRateRollover checks the rates of index bases that grew, rolled
+// over, were deleted or are new between two snapshots, and that the cluster total only sums
+// the ones with a growth
+func TestCalculateClusterIndexingRateRollover(t *testing.T) {
+	const t0 = int64(1_700_000_000_000)
+	const window = int64(3 * 60_000)
+	index := func(base string, seqNo, primaryStorage uint64) *types.IndexInfo {
+		return &types.IndexInfo{IndexBase: base, SeqNo: seqNo, PrimaryShards: 2, PrimaryStorage: primaryStorage}
+	}
+	history := types.NewIndicesHistory(10)
+	history.AddSnapshot(&types.IndicesSnapShot{
+		SnapShotTime: t0 - window,
+		MapIndices: map[string]*types.IndexInfo{
+			"metrics": index("metrics", 4, 2000),
+			"logs":    index("logs", 1, 1000),
+			"audit":   index("audit", 1, 5000),
+			"traces":  index("traces", 7, 4000),
+			"deleted": index("deleted", 1, 3000),
+		},
+	})
+	history.AddSnapshot(&types.IndicesSnapShot{
+		SnapShotTime: t0,
+		MapIndices: map[string]*types.IndexInfo{
+			"metrics": index("metrics", 4, 2600), // grew
+			"logs":    index("logs", 2, 300),     // rolled over
+			"audit":   index("audit", 3, 100),    // rolled over twice
+			"traces":  index("traces", 8, 200),   // rolled over, earlier generation deleted
+			"new":     index("new", 1, 700),      // created
+		},
+		Previous: map[string]*types.IndexInfo{
+			"logs":  index("logs", 1, 1500),
+			"audit": index("audit", 2, 900),
+		},
+	})
+
+	rate, err := calculateClusterIndexingRate("rollover", history)
+	if err != nil || rate == nil {
+		t.Fatalf("rate = %+v, %v", rate, err)
+	}
+	perShard := func(growth uint64) float64 {
+		return float64(growth) * 1000 / (2 * float64(window))
+	}
+	want := map[string]float64{
+		"metrics": perShard(600),
+		"logs":    perShard(1500 - 1000 + 300),
+		"audit":   -1,
+		"traces":  -1,
+		"new":     -1,
+	}
+	if len(rate.MapIndices) != len(want) {
+		t.Errorf("rates for %d index bases, want %d (deleted left out)", len(rate.MapIndices), len(want))
+	}
+	for base, wantRate := range want {
+		indexRate, ok := rate.MapIndices[base]
+		if !ok {
+			t.Errorf("no rate for %s", base)
+			continue
+		}
+		if indexRate.Last3Minutes != wantRate {
+			t.Errorf("%s rate = %v, want %v", base, indexRate.Last3Minutes, wantRate)
+		}
+	}
+	if wantTotal := 2 * (perShard(600) + perShard(800)); math.Abs(rate.Total.Last3Minutes-wantTotal) > 1e-9 {
+		t.Errorf("total rate = %v, want %v", rate.Total.Last3Minutes, wantTotal)
+	}
+}
+
+// TestAnalyseIngestIncludeClusters checks that a job-level includeClusters, passed as a
+// parameter, limits the clusters whose rates are computed
+func TestAnalyseIngestIncludeClusters(t *testing.T) {
+	const included, other = "ingest-included", "ingest-other"
+	for _, clusterName := range []string{included, other} {
+		history := types.GetOrCreateHistory(clusterName, 10)
+		history.AddSnapshot(&types.IndicesSnapShot{SnapShotTime: 1_700_000_000_000, MapIndices: map[string]*types.IndexInfo{}})
+	}
+	t.Cleanup(func() {
+		types.RemoveClusterData(included)
+		types.RemoveClusterData(other)
+	})
+
+	if err := AnalyseIngest(context.Background(), map[string]interface{}{"includeClusters": []string{included}}); err != nil {
+		t.Fatalf("AnalyseIngest: %v", err)
+	}
+	if _, ok := types.GetIndexingRate(included); !ok {
+		t.Errorf("no rate for %s, which includeClusters names", included)
+	}
+	if _, ok := types.GetIndexingRate(other); ok {
+		t.Errorf("rate for %s, which includeClusters leaves out", other)
+	}
+}
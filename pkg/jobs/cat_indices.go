@@ -2,7 +2,6 @@ package jobs
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,45 +12,44 @@ import (
 
 	"ElasticObservability/pkg/config"
 	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/scheduler"
 	"ElasticObservability/pkg/types"
 	"ElasticObservability/pkg/utils"
 )
 
 // CatIndicesResponse represents the response from _cat/indices API
-type CatIndicesResponse []map[string]interface{}
+type CatIndicesResponse []catIndexRow
+
+// catIndexRow is a row of the _cat/indices response with the columns requested by
+// fetchIndices. _cat renders every value as a string; some are null for closed indices.
+type catIndexRow struct {
+	Health       string `json:"health"`
+	Status       string `json:"status"`
+	DocsCount    string `json:"docs.count"`
+	Index        string `json:"index"`
+	Pri          string `json:"pri"`
+	CreationDate string `json:"creation.date"`
+	StoreSize    string `json:"store.size"`
+	PriStoreSize string `json:"pri.store.size"`
+}
 
 // RunCatIndices fetches indices information from all clusters
 func RunCatIndices(ctx context.Context, params map[string]interface{}) error {
 	logger.JobInfo("runCatIndices", "Starting indices fetch job")
 
-	// Get exclude list
-	excludeClusters := make([]string, 0)
-	if exclude, ok := params["excludeClusters"].([]interface{}); ok {
-		for _, item := range exclude {
-			if str, ok := item.(string); ok {
-				excludeClusters = append(excludeClusters, str)
-			}
-		}
-	}
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("runCatIndices", p)
+	opts.MaxConcurrent = p.Int("maxConcurrent", 5)
+	cacheTTL := p.Duration("cacheTTL", 0)
 
-	// Get exclude indices patterns (optional)
-	excludeIndices := make([]string, 0)
-	if exclude, ok := params["excludeIndices"].([]interface{}); ok {
-		for _, item := range exclude {
-			if str, ok := item.(string); ok && str != "" {
-				excludeIndices = append(excludeIndices, str)
-			}
-		}
-	}
+	// Index filter patterns (optional); includeOnlyIndices takes precedence
+	excludeIndices := p.StringSlice("excludeIndices")
+	includeOnlyIndices := p.StringSlice("includeOnlyIndices")
 
-	// Get include only indices patterns (optional)
-	includeOnlyIndices := make([]string, 0)
-	if include, ok := params["includeOnlyIndices"].([]interface{}); ok {
-		for _, item := range include {
-			if str, ok := item.(string); ok && str != "" {
-				includeOnlyIndices = append(includeOnlyIndices, str)
-			}
-		}
+	if err := p.Err(); err != nil {
+		return err
 	}
 
 	// Log filtering configuration
@@ -61,50 +59,34 @@ func RunCatIndices(ctx context.Context, params map[string]interface{}) error {
 		logger.JobInfo("runCatIndices", "Index filter: excludeIndices enabled with %d patterns", len(excludeIndices))
 	}
 
-	types.ClustersMu.RLock()
-	clustersCopy := make(map[string]*types.ClusterData)
-	for name, cluster := range types.AllClusters {
-		clustersCopy[name] = cluster
-	}
-	types.ClustersMu.RUnlock()
-
-	successCount := 0
-	failedCount := 0
 	currentTime := utils.TimeNowMillis()
 
-	for clusterName, cluster := range clustersCopy {
-		// Skip excluded clusters
-		if utils.Contains(excludeClusters, clusterName) {
-			logger.JobInfo("runCatIndices", "Skipping excluded cluster: %s", clusterName)
-			continue
+	_, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, exists := types.GetCluster(clusterName)
+		if !exists {
+			return fmt.Errorf("cluster not found")
 		}
 
-		// Skip clusters without credentials
+		// Clusters without credentials or an active endpoint cannot be queried
 		if cluster.AccessCred.Preferred == 0 {
-			logger.JobInfo("runCatIndices", "Skipping cluster %s: No credentials available (Preferred=0)", clusterName)
-			failedCount++
-			continue
+			return fmt.Errorf("no credentials available (Preferred=0)")
 		}
-
-		// Skip if no active endpoint
 		if cluster.ActiveEndpoint == "" {
-			logger.JobWarn("runCatIndices", "Cluster %s: No active endpoint, skipping", clusterName)
-			failedCount++
-			continue
+			return fmt.Errorf("no active endpoint")
 		}
 
 		// Fetch indices
-		indices, err := fetchIndices(cluster)
+		indices, err := fetchIndices(ctx, cluster, cacheTTL)
 		if err != nil {
-			logger.JobError("runCatIndices", "Cluster %s: Failed to fetch indices: %v", clusterName, err)
-			failedCount++
-			continue
+			return fmt.Errorf("failed to fetch indices: %w", err)
 		}
 
 		// Process and store indices
 		snapshot := &types.IndicesSnapShot{
 			SnapShotTime: currentTime,
+			RunID:        scheduler.RunID(ctx),
 			MapIndices:   make(map[string]*types.IndexInfo),
+			Previous:     make(map[string]*types.IndexInfo),
 		}
 
 		totalFetched := len(indices)
@@ -117,11 +99,17 @@ func RunCatIndices(ctx context.Context, params map[string]interface{}) error {
 			if indexInfo != nil {
 				// Apply index filtering
 				if shouldIncludeIndex(indexInfo.Index, includeOnlyIndices, excludeIndices) {
+					// Totals cover every included index, older generations too
+					snapshot.Totals.Add(indexInfo)
 					// Only add if this indexBase hasn't been seen before
 					if !indexBaseSeen[indexInfo.IndexBase] {
 						snapshot.MapIndices[indexInfo.Index] = indexInfo // Store by index name, not indexBase
 						indexBaseSeen[indexInfo.IndexBase] = true
 					} else {
+						// Indices come newest first: keep the generation before the latest
+						if _, seen := snapshot.Previous[indexInfo.IndexBase]; !seen {
+							snapshot.Previous[indexInfo.IndexBase] = indexInfo
+						}
 						duplicateCount++ // Skip duplicate indexBase
 					}
 				} else {
@@ -130,17 +118,15 @@ func RunCatIndices(ctx context.Context, params map[string]interface{}) error {
 			}
 		}
 
-		// Store in history
-		types.HistoryMu.Lock()
-		history, exists := types.AllHistory[clusterName]
-		if !exists {
-			history = types.NewIndicesHistory(config.Global.HistoryForIndices)
-			types.AllHistory[clusterName] = history
+		// Store in history; a retried run replaces its own snapshot, duplicate or late
+		// snapshots are dropped
+		history := types.GetOrCreateHistory(clusterName, config.Global.HistoryForIndices)
+		if outcome := history.AddSnapshot(snapshot); outcome.Dropped() {
+			logger.JobWarn("runCatIndices", "Cluster %s: dropped %s snapshot taken at %d", clusterName, outcome, snapshot.SnapShotTime)
+			return nil
 		}
-		history.AddSnapshot(snapshot)
-		types.HistoryMu.Unlock()
+		recordIndicesTotals(clusterName, snapshot.Totals)
 
-		successCount++
 		if filteredCount > 0 || duplicateCount > 0 {
 			logger.JobInfo("runCatIndices", "Cluster %s: Fetched %d indices, filtered %d, duplicates %d, stored %d",
 				clusterName, totalFetched, filteredCount, duplicateCount, len(snapshot.MapIndices))
@@ -148,10 +134,20 @@ func RunCatIndices(ctx context.Context, params map[string]interface{}) error {
 			logger.JobInfo("runCatIndices", "Cluster %s: Fetched %d indices, stored %d",
 				clusterName, totalFetched, len(snapshot.MapIndices))
 		}
-	}
+		return nil
+	})
+	return err
+}
 
-	logger.JobInfo("runCatIndices", "Completed: %d clusters succeeded, %d failed", successCount, failedCount)
-	return nil
+// recordIndicesTotals exports the totals of a cluster's latest indices snapshot
+func recordIndicesTotals(clusterName string, totals types.IndicesTotals) {
+	metrics.ClusterIndices.WithLabelValues(clusterName, "green").Set(float64(totals.Green))
+	metrics.ClusterIndices.WithLabelValues(clusterName, "yellow").Set(float64(totals.Yellow))
+	metrics.ClusterIndices.WithLabelValues(clusterName, "red").Set(float64(totals.Red))
+	metrics.ClusterIndices.WithLabelValues(clusterName, "unknown").Set(float64(totals.UnknownHealth))
+	metrics.ClusterDocs.WithLabelValues(clusterName).Set(float64(totals.DocCount))
+	metrics.ClusterStorageBytes.WithLabelValues(clusterName, "total").Set(float64(totals.TotalStorage))
+	metrics.ClusterStorageBytes.WithLabelValues(clusterName, "primary").Set(float64(totals.PrimaryStorage))
 }
 
 // shouldIncludeIndex determines if an index should be included based on filter patterns
@@ -178,20 +174,13 @@ func shouldIncludeIndex(indexName string, includeOnlyPatterns []string, excludeP
 	return true // Include by default
 }
 
-func fetchIndices(cluster *types.ClusterData) (CatIndicesResponse, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: cluster.InsecureTLS,
-			},
-		},
-	}
+func fetchIndices(ctx context.Context, cluster *types.ClusterData, cacheTTL time.Duration) (CatIndicesResponse, error) {
+	client := esHTTPClient(cluster.InsecureTLS, 30*time.Second)
 
 	url := fmt.Sprintf("%s/_cat/indices?format=json&pretty&h=health,status,docs.count,index,pri,creation.date,store.size,pri.store.size&s=creation.date:desc",
 		cluster.ActiveEndpoint)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -204,95 +193,59 @@ func fetchIndices(cluster *types.ClusterData) (CatIndicesResponse, error) {
 		req.SetBasicAuth(cred.UserID, cred.Password)
 	}
 
-	resp, err := client.Do(req)
+	body, err := doQuery(client, req, nil, queryOptions{JobName: "runCatIndices", Cluster: cluster.ClusterName, TTL: cacheTTL})
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
-	defer resp.Body.Close()
+	defer body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
-	}
+	return decodeCatIndices(body)
+}
 
+// decodeCatIndices decodes a _cat/indices?format=json response
+func decodeCatIndices(r io.Reader) (CatIndicesResponse, error) {
 	var result CatIndicesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
-
 	return result, nil
 }
 
-func parseIndexInfo(data map[string]interface{}) *types.IndexInfo {
-	indexName, ok := data["index"].(string)
-	if !ok || indexName == "" {
+// parseIndexInfo converts a _cat/indices row; values that do not parse are left 0. Rows
+// without an index name are skipped (nil).
+func parseIndexInfo(row catIndexRow) *types.IndexInfo {
+	if row.Index == "" {
 		return nil
 	}
 
-	indexBase, seqNo := utils.ParseIndexName(indexName)
+	indexBase, seqNo := utils.ParseIndexName(row.Index)
 
-	// Parse health
-	health := uint8(0)
-	if healthStr, ok := data["health"].(string); ok {
-		health = utils.ParseHealth(healthStr)
-	}
-
-	// Parse status
-	isOpen := false
-	if statusStr, ok := data["status"].(string); ok {
-		isOpen = utils.ParseStatus(statusStr)
-	}
-
-	// Parse doc count
-	docCount := uint64(0)
-	if docsStr, ok := data["docs.count"].(string); ok {
-		if val, err := strconv.ParseUint(docsStr, 10, 64); err == nil {
-			docCount = val
-		}
-	}
-
-	// Parse primary shards
-	primaryShards := uint8(0)
-	if priStr, ok := data["pri"].(string); ok {
-		if val, err := strconv.ParseUint(priStr, 10, 8); err == nil {
-			primaryShards = uint8(val)
-		}
-	}
-
-	// Parse creation time
-	creationTime := int64(0)
-	if creationStr, ok := data["creation.date"].(string); ok {
-		if val, err := strconv.ParseInt(creationStr, 10, 64); err == nil {
-			creationTime = val
-		}
-	}
-
-	// Parse total storage
-	totalStorage := uint64(0)
-	if ssStr, ok := data["store.size"].(string); ok {
-		if val, err := utils.ParseStorageSize(ssStr); err == nil {
-			totalStorage = val
-		}
-	}
-
-	// Parse primary storage
-	primaryStorage := uint64(0)
-	if priStoreStr, ok := data["pri.store.size"].(string); ok {
-		if val, err := utils.ParseStorageSize(priStoreStr); err == nil {
-			primaryStorage = val
-		}
+	creationTime, err := strconv.ParseInt(row.CreationDate, 10, 64)
+	if err != nil {
+		creationTime = 0
 	}
+	totalStorage, _ := utils.ParseStorageSize(row.StoreSize)
+	primaryStorage, _ := utils.ParseStorageSize(row.PriStoreSize)
 
 	return &types.IndexInfo{
-		Health:         health,
-		IsOpen:         isOpen,
-		DocCount:       docCount,
-		Index:          indexName,
+		Health:         utils.ParseHealth(row.Health),
+		IsOpen:         utils.ParseStatus(row.Status),
+		DocCount:       parseUintOrZero(row.DocsCount, 64),
+		Index:          row.Index,
 		IndexBase:      indexBase,
 		SeqNo:          seqNo,
-		PrimaryShards:  primaryShards,
+		PrimaryShards:  uint8(parseUintOrZero(row.Pri, 8)),
 		CreationTime:   creationTime,
 		TotalStorage:   totalStorage,
 		PrimaryStorage: primaryStorage,
 	}
 }
+
+// parseUintOrZero parses an unsigned integer of bitSize bits, 0 when it does not parse or fit
+func parseUintOrZero(s string, bitSize int) uint64 {
+	val, err := strconv.ParseUint(s, 10, bitSize)
+	if err != nil {
+		return 0
+	}
+	return val
+}
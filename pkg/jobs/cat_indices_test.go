@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+
+	"ElasticObservability/pkg/types"
+)
+
+// TestCatIndicesGolden decodes a _cat/indices response with a closed index (null columns),
+// unparsable values and a row without an index name
+func TestCatIndicesGolden(t *testing.T) {
+	rows, err := decodeCatIndices(openTestdata(t, "cat_indices.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	indices := make([]*types.IndexInfo, 0, len(rows))
+	for _, row := range rows {
+		if info := parseIndexInfo(row); info != nil {
+			indices = append(indices, info)
+		}
+	}
+	checkGolden(t, "cat_indices", indices)
+}
+
+func TestDecodeCatIndicesInvalid(t *testing.T) {
+	for _, body := range []string{``, `{"error":"forbidden"}`, `[{"index": 1}]`} {
+		if _, err := decodeCatIndices(strings.NewReader(body)); err == nil {
+			t.Errorf("decodeCatIndices(%q) = nil error", body)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/notify"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+)
+
+// heapPressureSource is the event store source of heap pressure events
+const heapPressureSource = "heapPressure"
+
+// CheckForHeapPressure detects heap pressure on Elasticsearch nodes from the samples of
+// getNodeJVMStats. A node whose heap usage stays at or above thresholdValue percent for
+// noOfConsecutiveIntervals samples fires a heap pressure event; the event resolves once the
+// node is no longer under pressure. Missing samples are handled as in checkForWritePressure.
+func CheckForHeapPressure(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("checkForHeapPressure", "Starting heap pressure check")
+
+	p := jobparams.New(params)
+	selection := clusterSelectionFromParams(p)
+	thresholdValue := p.IntInRange("thresholdValue", 85, 1, 100)
+	noOfConsecutiveIntervals := p.IntInRange("noOfConsecutiveIntervals", 3, 1, 2016)
+	considerMissingDataPoint := p.OneOf("considerMissingDataPoint", "missing", "missing", "nonOffending", "offending")
+	notifyOwners := p.Bool("notifyOwners", false)
+	if err := checkParams("checkForHeapPressure", p); err != nil {
+		return err
+	}
+
+	logger.JobInfo("checkForHeapPressure", "Config: threshold=%d%%, consecutiveIntervals=%d, missingDataPoint=%s",
+		thresholdValue, noOfConsecutiveIntervals, considerMissingDataPoint)
+
+	clusterList := make([]string, 0)
+	for _, clusterName := range types.JVMStatsClusters() {
+		if selection.selects(clusterName) {
+			clusterList = append(clusterList, clusterName)
+		}
+	}
+	sort.Strings(clusterList)
+
+	totalHostsChecked := 0
+	active := make(map[[2]string]bool) // cluster, host -> under pressure
+	observed := make([]events.Observation, 0)
+	summary := fmt.Sprintf("JVM heap used >= %d%% for %d consecutive intervals",
+		thresholdValue, noOfConsecutiveIntervals)
+	now := time.Now()
+
+	for _, clusterName := range clusterList {
+		stats, ok := types.GetJVMStats(clusterName)
+		if !ok {
+			continue
+		}
+		for hostName, history := range stats.Nodes {
+			totalHostsChecked++
+			points := history.Points.NewestFirst()
+			pressured, startTime := isHeapUnderPressure(points, float64(thresholdValue),
+				noOfConsecutiveIntervals, considerMissingDataPoint)
+			active[[2]string{clusterName, hostName}] = pressured
+			if !pressured {
+				continue
+			}
+
+			latest := latestJVMPoint(points)
+			oldGCCount, oldGCTimeMs := oldGCSince(points, startTime)
+			observed = append(observed, events.Observation{
+				Name:     "HeapPressure",
+				Severity: "warning",
+				Labels:   map[string]string{"cluster": clusterName, "host": hostName},
+				Annotations: map[string]string{
+					"summary":         summary,
+					"heapUsedPercent": strconv.FormatFloat(latest.HeapUsedPercent, 'f', 1, 64),
+					"oldGCCount":      strconv.FormatUint(oldGCCount, 10),
+					"oldGCTimeMs":     strconv.FormatUint(oldGCTimeMs, 10),
+				},
+				StartsAt: startTime,
+			})
+		}
+	}
+
+	// Nodes no longer under pressure (or in clusters no longer checked) resolve their events
+	fired, resolved, err := events.Sync(heapPressureSource, observed, now)
+	if err != nil {
+		logger.JobWarn("checkForHeapPressure", "Failed to persist events: %v", err)
+	}
+
+	// Hosts no longer checked lose their series
+	metrics.HeapPressureActive.Reset()
+	for host, underPressure := range active {
+		value := 0.0
+		if underPressure {
+			value = 1
+		}
+		metrics.HeapPressureActive.WithLabelValues(host[0], host[1]).Set(value)
+	}
+
+	firedByCluster := make(map[string][]events.Event)
+	for _, event := range fired {
+		metrics.HeapPressureEventsTotal.WithLabelValues(event.Cluster(), event.Labels["host"]).Inc()
+		logger.JobInfo("checkForHeapPressure", "New heap pressure event %s: cluster=%s, host=%s, heapUsed=%s%%, oldGC=%s collections in %sms",
+			event.ID, event.Cluster(), event.Labels["host"], event.Annotations["heapUsedPercent"],
+			event.Annotations["oldGCCount"], event.Annotations["oldGCTimeMs"])
+		firedByCluster[event.Cluster()] = append(firedByCluster[event.Cluster()], event)
+	}
+	for _, event := range resolved {
+		logger.JobInfo("checkForHeapPressure", "Heap pressure event %s resolved: cluster=%s, host=%s, duration=%s",
+			event.ID, event.Cluster(), event.Labels["host"], event.Duration(now).Round(time.Second))
+	}
+
+	if notifyOwners {
+		for clusterName, clusterEvents := range firedByCluster {
+			notifyHeapPressure(ctx, clusterName, clusterEvents)
+		}
+	}
+
+	logger.JobInfo("checkForHeapPressure", "Completed: checked %d hosts, %d under pressure, %d new events, %d resolved",
+		totalHostsChecked, len(observed), len(fired), len(resolved))
+	return nil
+}
+
+// isHeapUnderPressure looks for consecutiveIntervals samples in a row at or above the
+// threshold, oldest first, and returns the time of the first sample of the run. Missing
+// samples are left out ("missing"), break a run ("nonOffending") or extend it ("offending").
+// Slots that were never filled are not samples at all.
+func isHeapUnderPressure(points []types.NodeJVMPoint, threshold float64, consecutiveIntervals int, missingDataMode string) (bool, int64) {
+	samples := make([]types.NodeJVMPoint, 0, len(points))
+	for _, point := range points {
+		if point.TimeStamp == 0 || (!point.Exists && missingDataMode == "missing") {
+			continue
+		}
+		samples = append(samples, point)
+	}
+
+	// samples are newest first; a run starts at i and ends at i-consecutiveIntervals+1
+	for i := len(samples) - 1; i >= consecutiveIntervals-1; i-- {
+		run := 0
+		for j := 0; j < consecutiveIntervals; j++ {
+			point := samples[i-j]
+			offending := point.HeapUsedPercent >= threshold
+			if !point.Exists {
+				offending = missingDataMode == "offending"
+			}
+			if !offending {
+				break
+			}
+			run++
+		}
+		if run == consecutiveIntervals {
+			return true, samples[i].TimeStamp
+		}
+	}
+	return false, 0
+}
+
+// latestJVMPoint returns the newest reported sample
+func latestJVMPoint(points []types.NodeJVMPoint) types.NodeJVMPoint {
+	for _, point := range points {
+		if point.Exists {
+			return point
+		}
+	}
+	return types.NodeJVMPoint{}
+}
+
+// oldGCSince sums the old generation collections and collection time between the reported
+// samples taken at or after since
+func oldGCSince(points []types.NodeJVMPoint, since int64) (count, timeMs uint64) {
+	var previous *types.NodeJVMPoint
+	for i := len(points) - 1; i >= 0; i-- {
+		point := points[i]
+		if !point.Exists || point.TimeStamp < since {
+			continue
+		}
+		if previous != nil {
+			count += gcDelta(previous.OldGCCount, point.OldGCCount)
+			timeMs += gcDelta(previous.OldGCTimeMs, point.OldGCTimeMs)
+		}
+		previous = &point
+	}
+	return count, timeMs
+}
+
+// notifyHeapPressure sends the owner of a cluster one alert for the cluster's new events
+func notifyHeapPressure(ctx context.Context, clusterName string, clusterEvents []events.Event) {
+	// Events detected during maintenance are recorded but not alerted on
+	alerting := clusterEvents[:0:0]
+	for _, event := range clusterEvents {
+		if !event.Suppressed {
+			alerting = append(alerting, event)
+		}
+	}
+	if len(alerting) == 0 {
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Heap pressure detected on %d hosts of cluster %s:\n", len(alerting), clusterName)
+	for _, event := range alerting {
+		fmt.Fprintf(&text, "  %s since %s: heap %s%% used, %s old GCs taking %sms (event %s)\n",
+			event.Labels["host"], time.UnixMilli(event.StartsAt).UTC().Format(time.RFC3339),
+			event.Annotations["heapUsedPercent"], event.Annotations["oldGCCount"],
+			event.Annotations["oldGCTimeMs"], event.ID)
+		writeEventLinks(&text, event.Annotations)
+	}
+
+	err := notify.NotifyCluster(ctx, clusterName, notify.Message{
+		Subject:  fmt.Sprintf("Heap pressure on cluster %s", clusterName),
+		Text:     text.String(),
+		Severity: "warning",
+	})
+	if err != nil {
+		logger.JobWarn("checkForHeapPressure", "Failed to notify owner of cluster %s: %v", clusterName, err)
+	}
+}
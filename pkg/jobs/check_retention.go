@@ -0,0 +1,324 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/notify"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// retentionSource is the event store source of retention violations
+const retentionSource = "retention"
+
+// retentionPolicy is the maximum age of the indices (or data streams) matching a pattern
+type retentionPolicy struct {
+	Pattern string // glob, e.g. "logs-*"
+	MaxAge  string // as configured, e.g. "30d"
+	maxAge  time.Duration
+}
+
+// CheckRetention lists the indices of every cluster, computes their daily growth from the
+// daily statistics and flags the indices that are older than the retention of their policy
+// plus a grace period, i.e. that should have been deleted by ILM or a curator job.
+// Violations are recorded in the event store and, with notifyOwners, sent to the owners.
+func CheckRetention(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("checkRetention", "Starting retention check")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("checkRetention", p)
+	opts.MaxConcurrent = p.Int("maxConcurrent", 5)
+	cacheTTL := p.Duration("cacheTTL", 0)
+	grace := p.Duration("grace", 24*time.Hour)
+	severity := p.OneOf("severity", "warning", "info", "warning", "critical")
+	notifyOwners := p.Bool("notifyOwners", false)
+	policies, err := retentionPolicies(p)
+	if err != nil {
+		return err
+	}
+	if err := checkParams("checkRetention", p); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var mu sync.Mutex
+	reports := make(map[string]*types.RetentionReport)
+
+	_, err = ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, exists := types.GetCluster(clusterName)
+		if !exists {
+			return fmt.Errorf("cluster not found")
+		}
+		if cluster.AccessCred.Preferred == 0 {
+			return fmt.Errorf("no credentials available (Preferred=0)")
+		}
+		if cluster.ActiveEndpoint == "" {
+			return fmt.Errorf("no active endpoint")
+		}
+
+		// The indices snapshot keeps the latest index of each index base only, so the
+		// older generations this check is about are listed here
+		indices, err := fetchIndices(ctx, cluster, cacheTTL)
+		if err != nil {
+			return fmt.Errorf("failed to fetch indices: %w", err)
+		}
+
+		report := buildRetentionReport(clusterName, indices, policies, grace, now)
+		types.SetRetentionReport(clusterName, report)
+
+		mu.Lock()
+		reports[clusterName] = report
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Violations are recorded in the event store; the firing events of clusters that could
+	// not be checked this time are kept as they are
+	observed := make([]events.Observation, 0)
+	metrics.RetentionViolations.Reset()
+	for clusterName, report := range types.RetentionReports() {
+		metrics.RetentionViolations.WithLabelValues(clusterName).Set(float64(report.Violations))
+		if _, checked := reports[clusterName]; !checked {
+			continue
+		}
+		for _, index := range report.Indices {
+			if index.Violation {
+				observed = append(observed, retentionObservation(clusterName, index, severity, now))
+			}
+		}
+	}
+	checked := make(map[string]bool, len(reports))
+	for clusterName := range reports {
+		checked[clusterName] = true
+	}
+	observed = append(observed, carriedOverEvents(retentionSource, checked)...)
+
+	fired, resolved, err := events.Sync(retentionSource, observed, now)
+	if err != nil {
+		logger.JobWarn("checkRetention", "Failed to persist events: %v", err)
+	}
+
+	firedByCluster := make(map[string][]events.Event)
+	for _, event := range fired {
+		logger.JobWarn("checkRetention", "Retention violation: cluster=%s index=%s policy=%s maxAge=%s overdue=%s (event %s)",
+			event.Cluster(), event.Labels["index"], event.Annotations["policy"], event.Annotations["maxAge"],
+			event.Annotations["overdue"], event.ID)
+		firedByCluster[event.Cluster()] = append(firedByCluster[event.Cluster()], event)
+	}
+	for _, event := range resolved {
+		logger.JobInfo("checkRetention", "Retention violation resolved: cluster=%s index=%s (event %s)",
+			event.Cluster(), event.Labels["index"], event.ID)
+	}
+
+	if notifyOwners {
+		clusterNames := make([]string, 0, len(firedByCluster))
+		for clusterName := range firedByCluster {
+			clusterNames = append(clusterNames, clusterName)
+		}
+		sort.Strings(clusterNames)
+		for _, clusterName := range clusterNames {
+			notifyRetentionViolations(ctx, clusterName, firedByCluster[clusterName], severity)
+		}
+	}
+
+	violations := 0
+	for _, report := range reports {
+		violations += report.Violations
+	}
+	logger.JobInfo("checkRetention", "Completed: %d clusters checked, %d violations (%d new, %d resolved)",
+		len(reports), violations, len(fired), len(resolved))
+	return nil
+}
+
+// ValidateCheckRetentionParams checks the retention policies when the job is loaded
+func ValidateCheckRetentionParams(params map[string]interface{}) error {
+	_, err := retentionPolicies(jobparams.New(params))
+	return err
+}
+
+// retentionPolicies reads the retention parameter, a map of index pattern to maximum age,
+// ordered so that the most specific (longest) pattern is tried first
+func retentionPolicies(p *jobparams.Reader) ([]retentionPolicy, error) {
+	configured := p.RequiredMap("retention")
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+
+	policies := make([]retentionPolicy, 0, len(configured))
+	for pattern, value := range configured {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("retention: invalid pattern %q: %w", pattern, err)
+		}
+		maxAge, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("retention: maximum age of %q must be a duration string such as \"30d\"", pattern)
+		}
+		d, err := utils.ParseDuration(maxAge)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("retention: invalid maximum age %q for %q", maxAge, pattern)
+		}
+		policies = append(policies, retentionPolicy{Pattern: pattern, MaxAge: maxAge, maxAge: d})
+	}
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("retention: at least one pattern is required")
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		if len(policies[i].Pattern) != len(policies[j].Pattern) {
+			return len(policies[i].Pattern) > len(policies[j].Pattern)
+		}
+		return policies[i].Pattern < policies[j].Pattern
+	})
+	return policies, nil
+}
+
+// matchRetentionPolicy returns the policy of an index; a backing index also matches the
+// policies of its data stream
+func matchRetentionPolicy(policies []retentionPolicy, indexName, dataStream string) (retentionPolicy, bool) {
+	for _, policy := range policies {
+		if matched, _ := path.Match(policy.Pattern, indexName); matched {
+			return policy, true
+		}
+		if dataStream != "" {
+			if matched, _ := path.Match(policy.Pattern, dataStream); matched {
+				return policy, true
+			}
+		}
+	}
+	return retentionPolicy{}, false
+}
+
+// buildRetentionReport joins the indices listed by _cat/indices with the cluster's daily
+// statistics and retention policies
+func buildRetentionReport(clusterName string, indices CatIndicesResponse, policies []retentionPolicy, grace time.Duration, now time.Time) *types.RetentionReport {
+	nowMs := now.UnixMilli()
+	stats, _ := types.GetStatsByDay(clusterName)
+
+	report := &types.RetentionReport{
+		ClusterName: clusterName,
+		CheckTime:   nowMs,
+		Indices:     make([]*types.IndexRetention, 0, len(indices)),
+	}
+
+	for _, idx := range indices {
+		info := parseIndexInfo(idx)
+		if info == nil {
+			continue
+		}
+
+		index := &types.IndexRetention{
+			Index:        info.Index,
+			CreationTime: info.CreationTime,
+			TotalSize:    info.TotalStorage,
+			DocCount:     info.DocCount,
+		}
+		if strings.HasPrefix(info.IndexBase, ".ds-") {
+			index.DataStream = strings.TrimPrefix(info.IndexBase, ".ds-")
+		}
+
+		// Daily growth: today's stats against the day before
+		if stats != nil {
+			if statHistory, ok := stats.StatHistory[info.Index]; ok && statHistory != nil {
+				if sizeGrowth, ok := statHistory.Stats.Delta(0, 1, statTotalSize); ok {
+					docGrowth, _ := statHistory.Stats.Delta(0, 1, statDocCount)
+					index.HasGrowth = true
+					index.SizeGrowth = int64(sizeGrowth)
+					index.DocGrowth = int64(docGrowth)
+					report.SizeGrowth += index.SizeGrowth
+					report.DocGrowth += index.DocGrowth
+				}
+			}
+		}
+
+		if policy, ok := matchRetentionPolicy(policies, info.Index, index.DataStream); ok {
+			index.Policy = policy.Pattern
+			index.MaxAge = policy.MaxAge
+			if info.CreationTime > 0 {
+				deleteBy := info.CreationTime + (policy.maxAge + grace).Milliseconds()
+				if nowMs > deleteBy {
+					index.Violation = true
+					index.OverdueMs = nowMs - deleteBy
+					report.Violations++
+				}
+			}
+		}
+
+		report.Indices = append(report.Indices, index)
+	}
+
+	sort.Slice(report.Indices, func(i, j int) bool {
+		a, b := report.Indices[i], report.Indices[j]
+		if a.SizeGrowth != b.SizeGrowth {
+			return a.SizeGrowth > b.SizeGrowth
+		}
+		return a.Index < b.Index
+	})
+	return report
+}
+
+// statTotalSize and statDocCount are the values of daily statistics compared for the growth
+func statTotalSize(stat *types.IndexStat) float64 { return float64(stat.TotalSize) }
+func statDocCount(stat *types.IndexStat) float64  { return float64(stat.DocCount) }
+
+// retentionObservation is the event store observation of an index violating its retention
+func retentionObservation(clusterName string, index *types.IndexRetention, severity string, now time.Time) events.Observation {
+	age := time.Duration(now.UnixMilli()-index.CreationTime) * time.Millisecond
+	return events.Observation{
+		Name:     "RetentionViolation",
+		Severity: severity,
+		Labels:   map[string]string{"cluster": clusterName, "index": index.Index},
+		Annotations: map[string]string{
+			"policy":    index.Policy,
+			"maxAge":    index.MaxAge,
+			"age":       age.Round(time.Hour).String(),
+			"overdue":   (time.Duration(index.OverdueMs) * time.Millisecond).Round(time.Hour).String(),
+			"totalSize": fmt.Sprintf("%d", index.TotalSize),
+		},
+		StartsAt: now.UnixMilli() - index.OverdueMs,
+	}
+}
+
+// notifyRetentionViolations sends the owner of a cluster one alert for its new violations
+func notifyRetentionViolations(ctx context.Context, clusterName string, clusterEvents []events.Event, severity string) {
+	// Violations detected during maintenance are recorded but not alerted on
+	alerting := clusterEvents[:0:0]
+	for _, event := range clusterEvents {
+		if !event.Suppressed {
+			alerting = append(alerting, event)
+		}
+	}
+	if len(alerting) == 0 {
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d indices of cluster %s are older than their retention:\n", len(alerting), clusterName)
+	for _, event := range alerting {
+		fmt.Fprintf(&text, "  %s (policy %s, max age %s): %s old, overdue by %s (event %s)\n",
+			event.Labels["index"], event.Annotations["policy"], event.Annotations["maxAge"],
+			event.Annotations["age"], event.Annotations["overdue"], event.ID)
+		writeEventLinks(&text, event.Annotations)
+	}
+
+	err := notify.NotifyCluster(ctx, clusterName, notify.Message{
+		Subject:  fmt.Sprintf("Retention violations on cluster %s", clusterName),
+		Text:     text.String(),
+		Severity: severity,
+	})
+	if err != nil {
+		logger.JobWarn("checkRetention", "Failed to notify owner of cluster %s: %v", clusterName, err)
+	}
+}
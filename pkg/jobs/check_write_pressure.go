@@ -4,44 +4,101 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"ElasticObservability/pkg/events"
 	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/notify"
+	jobparams "ElasticObservability/pkg/params"
 	"ElasticObservability/pkg/types"
 	"ElasticObservability/pkg/utils"
 )
 
-var (
-	// Write pressure tracking variables
-	oldRunTime      int64
-	previousRunTime int64
-	lastRunTime     int64
+// writePressureSource is the event store source of write pressure events
+const writePressureSource = "writePressure"
 
-	// Write pressure log file
-	writePressureLogger *log.Logger
+// clusterWritePressureSource is the event store source of cluster-wide write pressure events:
+// a share of the data nodes of a cluster under pressure at the same time
+const clusterWritePressureSource = "clusterWritePressure"
+
+// Write pressure log file
+var writePressureLogger *log.Logger
+
+// Write pressure severities: a host is under pressure from the warning threshold, and under
+// critical pressure from the critical threshold if one is set
+const (
+	writePressureWarning  = "warning"
+	writePressureCritical = "critical"
+)
+
+// Values of the detectionMode parameter: what puts a host under write pressure
+const (
+	detectThreshold = "threshold" // a write queue at or above the threshold
+	detectGrowth    = "growth"    // a write queue growing by growthPerInterval or more
+	detectAny       = "any"       // either of them
 )
 
-// CheckForWritePressure detects write pressure on Elasticsearch hosts
+// writePressureThresholds are the thread pool write queue thresholds of a cluster
+type writePressureThresholds struct {
+	Warning  int // a host is under pressure from this queue depth
+	Critical int // critical pressure from this queue depth, 0 = no critical tier
+	Clear    int // a host under pressure recovers once its queue drops below this
+	Growth   int // growth of the queue per interval that puts a host under pressure
+}
+
+// writePressureDetection is how hosts are checked, the same for every cluster
+type writePressureDetection struct {
+	mode                 string
+	consecutiveIntervals int
+	growthIntervals      int
+	missingDataMode      string
+}
+
+// hostPressure is the write pressure of a host in a run
+type hostPressure struct {
+	startTime int64
+	severity  string
+	trigger   string // detectThreshold or detectGrowth
+	held      bool   // the warning threshold is not exceeded any more, but the clear threshold is
+}
+
+// CheckForWritePressure detects write pressure on Elasticsearch hosts. A host under pressure
+// fires a write pressure event of severity warning, or critical above criticalThreshold; the
+// event resolves once the host is no longer under pressure, i.e. once its queue dropped below
+// clearThreshold if one is set. clusterThresholds overrides the thresholds per cluster. With
+// detectionMode growth or any, a write queue growing by growthPerInterval or more for
+// growthIntervals intervals in a row also puts a host under (warning) pressure, below the
+// threshold. With clusterPressurePercent, a cluster with at least that percentage of its data
+// nodes under pressure fires a cluster-wide event of clusterPressureSeverity.
 func CheckForWritePressure(ctx context.Context, params map[string]interface{}) error {
 	logger.JobInfo("checkForWritePressure", "Starting write pressure check")
 
 	// Get parameters
-	excludeClusters := getStringSliceParam(params, "excludeClusters")
-	thresholdValue := getIntParam(params, "thresholdValue", 700)
-	noOfConsecutiveIntervals := getIntParam(params, "noOfConsecutiveIntervals", 3)
-	considerMissingDataPoint := getStringParam(params, "considerMissingDataPoint", "missing")
-
-	// Validate considerMissingDataPoint parameter
-	if considerMissingDataPoint != "missing" && considerMissingDataPoint != "nonOffending" && considerMissingDataPoint != "offending" {
-		return fmt.Errorf("invalid considerMissingDataPoint value: %s (must be 'missing', 'nonOffending', or 'offending')", considerMissingDataPoint)
+	p := jobparams.New(params)
+	selection := clusterSelectionFromParams(p)
+	detection := writePressureDetectionParams(p)
+	notifyOwners := p.Bool("notifyOwners", false)
+	topContributors := p.IntInRange("topContributors", defaultTopContributors, 0, 50)
+	clusterPressurePercent := p.IntInRange("clusterPressurePercent", 0, 0, 100)
+	clusterPressureSeverity := p.OneOf("clusterPressureSeverity", writePressureCritical, writePressureWarning, writePressureCritical)
+	defaults, clusterThresholds, err := writePressureThresholdParams(p)
+	if err != nil {
+		return err
+	}
+	if err := checkParams("checkForWritePressure", p); err != nil {
+		return err
 	}
 
-	logger.JobInfo("checkForWritePressure", "Config: threshold=%d, consecutiveIntervals=%d, missingDataPoint=%s",
-		thresholdValue, noOfConsecutiveIntervals, considerMissingDataPoint)
+	logger.JobInfo("checkForWritePressure", "Config: mode=%s, threshold=%d, critical=%d, clear=%d, consecutiveIntervals=%d, growth=%d/interval for %d intervals, missingDataPoint=%s, clusterOverrides=%d",
+		detection.mode, defaults.Warning, defaults.Critical, defaults.Clear, detection.consecutiveIntervals,
+		defaults.Growth, detection.growthIntervals, detection.missingDataMode, len(clusterThresholds))
 
 	// Initialize write pressure logger if not already done
 	if writePressureLogger == nil {
@@ -60,19 +117,11 @@ func CheckForWritePressure(ctx context.Context, params map[string]interface{}) e
 		logger.JobInfo("checkForWritePressure", "Initialized write pressure log: %s", logPath)
 	}
 
-	// Update runtime tracking variables
-	oldRunTime = previousRunTime
-	previousRunTime = lastRunTime
-	lastRunTime = time.Now().Unix()
-
-	logger.JobInfo("checkForWritePressure", "Runtime tracking: old=%d, previous=%d, last=%d",
-		oldRunTime, previousRunTime, lastRunTime)
-
 	// Build cluster list for assessment
 	types.TPWQueueMu.RLock()
 	clusterList := make([]string, 0)
 	for clusterName := range types.AllThreadPoolWriteQueues {
-		if !utils.Contains(excludeClusters, clusterName) {
+		if selection.selects(clusterName) {
 			clusterList = append(clusterList, clusterName)
 		}
 	}
@@ -80,38 +129,307 @@ func CheckForWritePressure(ctx context.Context, params map[string]interface{}) e
 
 	logger.JobInfo("checkForWritePressure", "Checking %d clusters for write pressure", len(clusterList))
 
+	// Severity of the hosts under pressure before this run, for hysteresis and escalations
+	previous := make(map[[2]string]string)
+	for _, event := range events.Query(events.Filter{Source: writePressureSource, State: events.StateFiring}) {
+		previous[[2]string{event.Cluster(), event.Labels["host"]}] = event.Severity
+	}
+
 	// Process each cluster
 	totalHostsChecked := 0
-	pressureEventsDetected := 0
+	active := make(map[[2]string]string) // cluster, host -> severity, "" = not under pressure
+	observed := make([]events.Observation, 0)
+	clusterObserved := make([]events.Observation, 0)
+	now := time.Now()
 
+	metrics.WritePressureDataNodesPercent.Reset()
 	for _, clusterName := range clusterList {
-		hostsChecked, eventsDetected := checkClusterForWritePressure(
+		thresholds := defaults
+		if override, ok := clusterThresholds[clusterName]; ok {
+			thresholds = override
+		}
+		hostsChecked, pressured := checkClusterForWritePressure(
 			clusterName,
-			thresholdValue,
-			noOfConsecutiveIntervals,
-			considerMissingDataPoint,
+			thresholds,
+			detection,
+			previous,
 		)
-		totalHostsChecked += hostsChecked
-		pressureEventsDetected += eventsDetected
+		totalHostsChecked += len(hostsChecked)
+		for _, hostname := range hostsChecked {
+			active[[2]string{clusterName, hostname}] = ""
+		}
+		for hostname, pressure := range pressured {
+			active[[2]string{clusterName, hostname}] = pressure.severity
+			annotations := map[string]string{
+				"summary": writePressureSummary(thresholds, pressure, detection),
+				"trigger": pressure.trigger,
+			}
+			if pressure.held {
+				annotations["clearThreshold"] = strconv.Itoa(thresholds.Clear)
+			}
+			// Name the index shards with the most bulk write time on the host in the window
+			if topContributors > 0 {
+				contributors, totalMs := writePressureContributors(clusterName, hostname,
+					pressure.startTime, now.UnixMilli(), topContributors)
+				if len(contributors) > 0 {
+					annotations["topContributors"] = formatContributors(contributors, totalMs)
+				}
+			}
+			observed = append(observed, events.Observation{
+				Name:        "WritePressure",
+				Severity:    pressure.severity,
+				Labels:      map[string]string{"cluster": clusterName, "host": hostname},
+				Annotations: annotations,
+				StartsAt:    pressure.startTime,
+			})
+		}
+
+		share := dataNodePressure(clusterName, hostsChecked, pressured)
+		metrics.WritePressureDataNodesPercent.WithLabelValues(clusterName).Set(share.percent())
+		if clusterPressurePercent > 0 && share.total > 0 && share.percent() >= float64(clusterPressurePercent) {
+			clusterObserved = append(clusterObserved, events.Observation{
+				Name:     "ClusterWritePressure",
+				Severity: clusterPressureSeverity,
+				Labels:   map[string]string{"cluster": clusterName},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("%d of %d data nodes (%.0f%%) under write pressure, >= %d%%",
+						len(share.hosts), share.total, share.percent(), clusterPressurePercent),
+					"hosts": strings.Join(share.hosts, ","),
+				},
+				StartsAt: share.since(clusterPressurePercent),
+			})
+		}
 	}
 
-	// Clean up old events from WritePressureMap
-	cleanupOldEvents(oldRunTime)
+	// Hosts no longer under pressure (or in clusters no longer checked) resolve their events
+	fired, resolved, err := events.Sync(writePressureSource, observed, now)
+	if err != nil {
+		logger.JobWarn("checkForWritePressure", "Failed to persist events: %v", err)
+	}
+	clusterFired, clusterResolved, err := events.Sync(clusterWritePressureSource, clusterObserved, now)
+	if err != nil {
+		logger.JobWarn("checkForWritePressure", "Failed to persist cluster events: %v", err)
+	}
+
+	// Hosts no longer checked lose their series
+	metrics.WritePressureActive.Reset()
+	metrics.WritePressureSeverity.Reset()
+	for host, severity := range active {
+		value, level := 0.0, 0.0
+		switch severity {
+		case writePressureWarning:
+			value, level = 1, 1
+		case writePressureCritical:
+			value, level = 1, 2
+		}
+		metrics.WritePressureActive.WithLabelValues(host[0], host[1]).Set(value)
+		metrics.WritePressureSeverity.WithLabelValues(host[0], host[1]).Set(level)
+	}
 
-	logger.JobInfo("checkForWritePressure", "Completed: checked %d hosts, detected %d pressure events",
-		totalHostsChecked, pressureEventsDetected)
+	alertByCluster := make(map[string][]events.Event)
+	for _, event := range fired {
+		logWritePressureEvent(event)
+		metrics.WritePressureEventsTotal.WithLabelValues(event.Cluster(), event.Labels["host"], event.Severity).Inc()
+		logger.JobInfo("checkForWritePressure", "New write pressure event %s: cluster=%s, host=%s, severity=%s, startTime=%d",
+			event.ID, event.Cluster(), event.Labels["host"], event.Severity, event.StartsAt)
+		if contributors := event.Annotations["topContributors"]; contributors != "" {
+			logger.JobInfo("checkForWritePressure", "Top contributors on %s: %s", event.Labels["host"], contributors)
+		}
+		alertByCluster[event.Cluster()] = append(alertByCluster[event.Cluster()], event)
+	}
+
+	// Events that became critical are alerted on again
+	escalated := 0
+	for _, event := range events.Query(events.Filter{Source: writePressureSource, State: events.StateFiring, Severity: writePressureCritical}) {
+		if previous[[2]string{event.Cluster(), event.Labels["host"]}] != writePressureWarning {
+			continue
+		}
+		escalated++
+		metrics.WritePressureEventsTotal.WithLabelValues(event.Cluster(), event.Labels["host"], event.Severity).Inc()
+		logger.JobInfo("checkForWritePressure", "Write pressure event %s escalated to critical: cluster=%s, host=%s",
+			event.ID, event.Cluster(), event.Labels["host"])
+		alertByCluster[event.Cluster()] = append(alertByCluster[event.Cluster()], event)
+	}
+
+	for _, event := range clusterFired {
+		logWritePressureEvent(event)
+		logger.JobInfo("checkForWritePressure", "New cluster write pressure event %s: cluster=%s, %s",
+			event.ID, event.Cluster(), event.Annotations["summary"])
+		alertByCluster[event.Cluster()] = append(alertByCluster[event.Cluster()], event)
+	}
+	resolved = append(resolved, clusterResolved...)
+
+	for _, event := range resolved {
+		logWritePressureEvent(event)
+		logger.JobInfo("checkForWritePressure", "Write pressure event %s resolved: cluster=%s, host=%s, duration=%s",
+			event.ID, event.Cluster(), event.Labels["host"], event.Duration(now).Round(time.Second))
+	}
+
+	if notifyOwners {
+		for clusterName, clusterEvents := range alertByCluster {
+			notifyWritePressure(ctx, clusterName, clusterEvents)
+		}
+	}
+
+	logger.JobInfo("checkForWritePressure", "Completed: checked %d hosts, %d under pressure, %d clusters under pressure, %d new events, %d escalated, %d resolved",
+		totalHostsChecked, len(observed), len(clusterObserved), len(fired)+len(clusterFired), escalated, len(resolved))
 
 	return nil
 }
 
-// checkClusterForWritePressure checks all hosts in a cluster for write pressure
-func checkClusterForWritePressure(clusterName string, threshold, consecutiveIntervals int, missingDataMode string) (int, int) {
+// dataNodeShare is the share of the data nodes of a cluster under write pressure
+type dataNodeShare struct {
+	total  int      // data nodes checked
+	hosts  []string // data nodes under pressure, sorted
+	starts []int64  // start of their pressure, oldest first
+}
+
+// percent returns the percentage of the data nodes under pressure
+func (s dataNodeShare) percent() float64 {
+	if s.total == 0 {
+		return 0
+	}
+	return float64(len(s.hosts)) * 100 / float64(s.total)
+}
+
+// since returns when percent of the data nodes were under pressure: the start of the pressure
+// of the host that brought the share to percent
+func (s dataNodeShare) since(percent int) int64 {
+	needed := int(math.Ceil(float64(percent) * float64(s.total) / 100))
+	if needed < 1 || needed > len(s.starts) {
+		return 0
+	}
+	return s.starts[needed-1]
+}
+
+// dataNodePressure returns the share of the data nodes of a cluster under pressure. Data
+// nodes are the hosts checked that the cluster inventory knows with the data type; when it
+// knows none of them, every host checked counts.
+func dataNodePressure(clusterName string, hostsChecked []string, pressured map[string]hostPressure) dataNodeShare {
+	dataHosts := make([]string, 0, len(hostsChecked))
+	if cluster, ok := types.GetCluster(clusterName); ok {
+		hosts := newHostResolver(cluster)
+		for _, hostname := range hostsChecked {
+			if node := hosts.node(hostname); node != nil && utils.Contains(node.Type, "data") {
+				dataHosts = append(dataHosts, hostname)
+			}
+		}
+	}
+	if len(dataHosts) == 0 {
+		dataHosts = hostsChecked
+	}
+
+	share := dataNodeShare{total: len(dataHosts)}
+	for _, hostname := range dataHosts {
+		if pressure, ok := pressured[hostname]; ok {
+			share.hosts = append(share.hosts, hostname)
+			share.starts = append(share.starts, pressure.startTime)
+		}
+	}
+	sort.Strings(share.hosts)
+	sort.Slice(share.starts, func(i, j int) bool { return share.starts[i] < share.starts[j] })
+	return share
+}
+
+// ValidateWritePressureParams checks the thresholds when the job is loaded
+func ValidateWritePressureParams(params map[string]interface{}) error {
+	p := jobparams.New(params)
+	_, _, err := writePressureThresholdParams(p)
+	if err != nil {
+		return err
+	}
+	return p.Err()
+}
+
+// writePressureDetectionParams reads how hosts are checked: detectionMode,
+// noOfConsecutiveIntervals, growthIntervals and considerMissingDataPoint
+func writePressureDetectionParams(p *jobparams.Reader) writePressureDetection {
+	return writePressureDetection{
+		mode:                 p.OneOf("detectionMode", detectThreshold, detectThreshold, detectGrowth, detectAny),
+		consecutiveIntervals: p.Int("noOfConsecutiveIntervals", 3),
+		growthIntervals:      p.IntInRange("growthIntervals", 5, 1, 2016),
+		missingDataMode:      p.OneOf("considerMissingDataPoint", "missing", "missing", "nonOffending", "offending"),
+	}
+}
+
+// writePressureThresholdParams reads the thresholds of the job (thresholdValue,
+// criticalThreshold, clearThreshold, growthPerInterval) and their overrides per cluster
+// (clusterThresholds, a map of cluster name to any of them; the others are those of the job)
+func writePressureThresholdParams(p *jobparams.Reader) (writePressureThresholds, map[string]writePressureThresholds, error) {
+	defaults, err := readWritePressureThresholds(p, writePressureThresholds{Warning: 700, Growth: 100})
+	if err != nil {
+		return defaults, nil, err
+	}
+
+	overrides := make(map[string]writePressureThresholds)
+	for clusterName, value := range p.Map("clusterThresholds") {
+		params, ok := value.(map[string]interface{})
+		if !ok {
+			return defaults, nil, fmt.Errorf("clusterThresholds: thresholds of %s must be a map", clusterName)
+		}
+		cp := jobparams.New(params)
+		thresholds, err := readWritePressureThresholds(cp, defaults)
+		if err == nil {
+			err = cp.Err()
+		}
+		if err != nil {
+			return defaults, nil, fmt.Errorf("clusterThresholds: %s: %w", clusterName, err)
+		}
+		overrides[clusterName] = thresholds
+	}
+	return defaults, overrides, nil
+}
+
+// readWritePressureThresholds reads thresholdValue, criticalThreshold, clearThreshold and
+// growthPerInterval over base. Without a clear threshold a host clears at the warning threshold (no hysteresis); an
+// inherited clear threshold above the warning threshold is lowered to it.
+func readWritePressureThresholds(p *jobparams.Reader, base writePressureThresholds) (writePressureThresholds, error) {
+	t := writePressureThresholds{
+		Warning:  p.IntInRange("thresholdValue", base.Warning, 1, math.MaxInt32),
+		Critical: p.IntInRange("criticalThreshold", base.Critical, 0, math.MaxInt32),
+		Clear:    p.IntInRange("clearThreshold", base.Clear, 0, math.MaxInt32),
+		Growth:   p.IntInRange("growthPerInterval", base.Growth, 1, math.MaxInt32),
+	}
+	if t.Clear == 0 || (!p.Has("clearThreshold") && t.Clear > t.Warning) {
+		t.Clear = t.Warning
+	}
+	if t.Critical > 0 && t.Critical <= t.Warning {
+		return t, fmt.Errorf("criticalThreshold (%d) must be above thresholdValue (%d)", t.Critical, t.Warning)
+	}
+	if t.Clear > t.Warning {
+		return t, fmt.Errorf("clearThreshold (%d) must not be above thresholdValue (%d)", t.Clear, t.Warning)
+	}
+	return t, nil
+}
+
+// writePressureSummary describes the condition of a write pressure event
+func writePressureSummary(t writePressureThresholds, pressure hostPressure, detection writePressureDetection) string {
+	threshold := t.Warning
+	if pressure.severity == writePressureCritical {
+		threshold = t.Critical
+	}
+	summary := fmt.Sprintf("Thread pool write queue >= %d for %d consecutive intervals", threshold, detection.consecutiveIntervals)
+	if pressure.trigger == detectGrowth {
+		summary = fmt.Sprintf("Thread pool write queue growing by >= %d per interval for %d consecutive intervals",
+			t.Growth, detection.growthIntervals)
+	}
+	if t.Clear < t.Warning {
+		summary += fmt.Sprintf(", until it drops below %d", t.Clear)
+	}
+	return summary
+}
+
+// checkClusterForWritePressure checks all hosts in a cluster for write pressure and returns
+// the hosts checked and the pressure of each pressured host. Hosts under pressure before
+// (previous) stay under pressure while their latest queue is at or above the clear threshold.
+func checkClusterForWritePressure(clusterName string, thresholds writePressureThresholds, detection writePressureDetection,
+	previous map[[2]string]string) ([]string, map[string]hostPressure) {
 	// Get a private copy of cluster's TPWQueue data
 	types.TPWQueueMu.RLock()
 	clusterData, exists := types.AllThreadPoolWriteQueues[clusterName]
 	if !exists {
 		types.TPWQueueMu.RUnlock()
-		return 0, 0
+		return nil, nil
 	}
 
 	// Make a shallow copy to avoid holding lock too long
@@ -124,8 +442,8 @@ func checkClusterForWritePressure(clusterName string, threshold, consecutiveInte
 	}
 	types.TPWQueueMu.RUnlock()
 
-	hostsChecked := 0
-	eventsDetected := 0
+	hostsChecked := make([]string, 0, len(hostnames))
+	pressured := make(map[string]hostPressure)
 
 	// Check each host for write pressure
 	for _, hostname := range hostnames {
@@ -134,25 +452,162 @@ func checkClusterForWritePressure(clusterName string, threshold, consecutiveInte
 			continue
 		}
 
-		hostsChecked++
+		hostsChecked = append(hostsChecked, hostname)
 
-		// Check if this host is under write pressure
-		isPressured, eventStartTime := isHostUnderPressure(tpwq, threshold, consecutiveIntervals, missingDataMode)
+		_, wasPressured := previous[[2]string{clusterName, hostname}]
+		if pressure, ok := checkHostForWritePressure(tpwq, thresholds, detection, wasPressured); ok {
+			pressured[hostname] = pressure
+		}
+	}
 
-		if isPressured {
-			// Create event and check if it's new
-			if recordWritePressureEvent(hostname, clusterName, eventStartTime) {
-				eventsDetected++
+	return hostsChecked, pressured
+}
+
+// checkHostForWritePressure checks the write queue of a host for write pressure. A host under
+// pressure before (wasPressured) stays under pressure while its latest queue is at or above the
+// clear threshold.
+func checkHostForWritePressure(tpwq *types.TPWQueue, thresholds writePressureThresholds, detection writePressureDetection,
+	wasPressured bool) (hostPressure, bool) {
+	isPressured, eventStartTime := false, int64(0)
+	if detection.mode != detectGrowth {
+		isPressured, eventStartTime = isHostUnderPressure(tpwq, thresholds.Warning, detection.consecutiveIntervals, detection.missingDataMode)
+	}
+	if isPressured {
+		pressure := hostPressure{startTime: eventStartTime, severity: writePressureWarning, trigger: detectThreshold}
+		if thresholds.Critical > 0 {
+			if critical, _ := isHostUnderPressure(tpwq, thresholds.Critical, detection.consecutiveIntervals, detection.missingDataMode); critical {
+				pressure.severity = writePressureCritical
 			}
 		}
+		return pressure, true
+	}
+
+	// A growing queue is pressure too, before it reaches the threshold
+	if detection.mode != detectThreshold {
+		if growing, startTime := isQueueGrowing(tpwq, thresholds.Growth, detection.growthIntervals, detection.missingDataMode); growing {
+			return hostPressure{startTime: startTime, severity: writePressureWarning, trigger: detectGrowth}, true
+		}
+	}
+
+	if wasPressured && detection.mode != detectGrowth && thresholds.Clear < thresholds.Warning &&
+		isLatestQueueAtOrAbove(tpwq, thresholds.Clear, detection.missingDataMode) {
+		return hostPressure{severity: writePressureWarning, trigger: detectThreshold, held: true}, true
+	}
+	return hostPressure{}, false
+}
+
+// isLatestQueueAtOrAbove reports whether the latest write queue sample of a host is at or
+// above threshold. A missing latest sample is skipped, or counts as below (nonOffending) or
+// above (offending) the threshold.
+func isLatestQueueAtOrAbove(tpwq *types.TPWQueue, threshold int, missingDataMode string) bool {
+	if tpwq == nil || tpwq.Points.Cap() == 0 {
+		return false
+	}
+	if !tpwq.Points.At(0).Exists {
+		switch missingDataMode {
+		case "nonOffending":
+			return false
+		case "offending":
+			return true
+		}
+	}
+	point, ok := tpwq.Points.Latest()
+	return ok && point.Queue >= uint32(threshold)
+}
+
+// notifyWritePressure sends the owner of a cluster one alert for the cluster's new and
+// escalated events, host and cluster-wide, as critical if one of them is
+func notifyWritePressure(ctx context.Context, clusterName string, clusterEvents []events.Event) {
+	// Events detected during maintenance are recorded but not alerted on
+	alerting := clusterEvents[:0:0]
+	for _, event := range clusterEvents {
+		if !event.Suppressed {
+			alerting = append(alerting, event)
+		}
+	}
+	if len(alerting) == 0 {
+		return
 	}
 
-	return hostsChecked, eventsDetected
+	severity := writePressureWarning
+	var text strings.Builder
+	hosts := 0
+	for _, event := range alerting {
+		if event.Source == writePressureSource {
+			hosts++
+		}
+	}
+	fmt.Fprintf(&text, "Write pressure detected on %d hosts of cluster %s:\n", hosts, clusterName)
+	for _, event := range alerting {
+		if event.Severity == writePressureCritical {
+			severity = writePressureCritical
+		}
+		if event.Source == clusterWritePressureSource {
+			fmt.Fprintf(&text, "  cluster-wide %s since %s (event %s): %s\n", event.Severity,
+				time.UnixMilli(event.StartsAt).UTC().Format(time.RFC3339), event.ID, event.Annotations["summary"])
+			writeEventLinks(&text, event.Annotations)
+			continue
+		}
+		fmt.Fprintf(&text, "  %s %s since %s (event %s)\n", event.Labels["host"], event.Severity,
+			time.UnixMilli(event.StartsAt).UTC().Format(time.RFC3339), event.ID)
+		if contributors := event.Annotations["topContributors"]; contributors != "" {
+			fmt.Fprintf(&text, "    top contributors: %s\n", contributors)
+		}
+		writeEventLinks(&text, event.Annotations)
+	}
+
+	err := notify.NotifyCluster(ctx, clusterName, notify.Message{
+		Subject:  fmt.Sprintf("Write pressure on cluster %s", clusterName),
+		Text:     text.String(),
+		Severity: severity,
+	})
+	if err != nil {
+		logger.JobWarn("checkForWritePressure", "Failed to notify owner of cluster %s: %v", clusterName, err)
+	}
+}
+
+// isQueueGrowing reports whether the write queue of a host grew for intervals intervals in a
+// row, never falling and by at least growth per interval on average, and the time stamp of
+// the first point of the growth. Missing points are skipped, except with nonOffending where
+// they break the growth.
+func isQueueGrowing(tpwq *types.TPWQueue, growth, intervals int, missingDataMode string) (bool, int64) {
+	if tpwq == nil || tpwq.Points.Cap() == 0 {
+		return false, 0
+	}
+
+	// Runs of points without gaps, oldest first
+	runs := make([][]types.TPWPoint, 0)
+	run := make([]types.TPWPoint, 0)
+	for _, point := range tpwq.Points.OldestFirst() {
+		if !point.Exists {
+			if missingDataMode == "nonOffending" && len(run) > 0 {
+				runs = append(runs, run)
+				run = make([]types.TPWPoint, 0)
+			}
+			continue
+		}
+		run = append(run, point)
+	}
+	runs = append(runs, run)
+
+	for _, points := range runs {
+		for start := 0; start+intervals < len(points); start++ {
+			first, last := points[start], points[start+intervals]
+			rising := true
+			for i := start + 1; i <= start+intervals && rising; i++ {
+				rising = points[i].Queue >= points[i-1].Queue
+			}
+			if rising && int64(last.Queue)-int64(first.Queue) >= int64(growth)*int64(intervals) {
+				return true, first.TimeStamp
+			}
+		}
+	}
+	return false, 0
 }
 
 // isHostUnderPressure checks if a host is experiencing write pressure
 func isHostUnderPressure(tpwq *types.TPWQueue, threshold, consecutiveIntervals int, missingDataMode string) (bool, int64) {
-	if tpwq == nil || len(tpwq.ThreadPoolWriteQueues) == 0 {
+	if tpwq == nil || tpwq.Points.Cap() == 0 {
 		return false, 0
 	}
 
@@ -171,21 +626,8 @@ func isHostUnderPressure(tpwq *types.TPWQueue, threshold, consecutiveIntervals i
 
 // checkPressureWithMissingFiltered removes missing data points and checks sequential elements
 func checkPressureWithMissingFiltered(tpwq *types.TPWQueue, threshold, consecutiveIntervals int) (bool, int64) {
-	// Build array of valid data points (filtering out missing ones)
-	type dataPoint struct {
-		timestamp int64
-		value     uint32
-	}
-
-	validPoints := make([]dataPoint, 0)
-	for i := 0; i < len(tpwq.ThreadPoolWriteQueues); i++ {
-		if tpwq.DataExists[i] {
-			validPoints = append(validPoints, dataPoint{
-				timestamp: tpwq.TimeStamps[i],
-				value:     tpwq.ThreadPoolWriteQueues[i],
-			})
-		}
-	}
+	// Valid data points, newest first (missing ones filtered out)
+	validPoints := tpwq.Points.WithData()
 
 	// Need at least consecutiveIntervals valid points
 	if len(validPoints) < consecutiveIntervals {
@@ -198,10 +640,10 @@ func checkPressureWithMissingFiltered(tpwq *types.TPWQueue, threshold, consecuti
 		var startTime int64
 
 		for j := 0; j < consecutiveIntervals; j++ {
-			if validPoints[i-j].value >= uint32(threshold) {
+			if validPoints[i-j].Queue >= uint32(threshold) {
 				consecutiveCount++
 				if j == consecutiveIntervals-1 {
-					startTime = validPoints[i-j].timestamp
+					startTime = validPoints[i-j].TimeStamp
 				}
 			} else {
 				break
@@ -218,26 +660,26 @@ func checkPressureWithMissingFiltered(tpwq *types.TPWQueue, threshold, consecuti
 
 // checkPressureWithMissingAsNonOffending treats missing data as below threshold
 func checkPressureWithMissingAsNonOffending(tpwq *types.TPWQueue, threshold, consecutiveIntervals int) (bool, int64) {
-	if len(tpwq.ThreadPoolWriteQueues) < consecutiveIntervals {
+	if tpwq.Points.Cap() < consecutiveIntervals {
 		return false, 0
 	}
 
 	// Check from oldest to newest
-	for i := len(tpwq.ThreadPoolWriteQueues) - 1; i >= consecutiveIntervals-1; i-- {
+	for i := tpwq.Points.Cap() - 1; i >= consecutiveIntervals-1; i-- {
 		consecutiveCount := 0
 		var startTime int64
 
 		for j := 0; j < consecutiveIntervals; j++ {
-			idx := i - j
+			point := tpwq.Points.At(i - j)
 			// If data doesn't exist, treat as non-offending (below threshold) - breaks the sequence
-			if !tpwq.DataExists[idx] {
+			if !point.Exists {
 				break
 			}
 
-			if tpwq.ThreadPoolWriteQueues[idx] >= uint32(threshold) {
+			if point.Queue >= uint32(threshold) {
 				consecutiveCount++
 				if j == consecutiveIntervals-1 {
-					startTime = tpwq.TimeStamps[idx]
+					startTime = point.TimeStamp
 				}
 			} else {
 				break
@@ -254,30 +696,30 @@ func checkPressureWithMissingAsNonOffending(tpwq *types.TPWQueue, threshold, con
 
 // checkPressureWithMissingAsOffending treats missing data as above threshold
 func checkPressureWithMissingAsOffending(tpwq *types.TPWQueue, threshold, consecutiveIntervals int) (bool, int64) {
-	if len(tpwq.ThreadPoolWriteQueues) < consecutiveIntervals {
+	if tpwq.Points.Cap() < consecutiveIntervals {
 		return false, 0
 	}
 
 	// Check from oldest to newest
-	for i := len(tpwq.ThreadPoolWriteQueues) - 1; i >= consecutiveIntervals-1; i-- {
+	for i := tpwq.Points.Cap() - 1; i >= consecutiveIntervals-1; i-- {
 		consecutiveCount := 0
 		var startTime int64
 
 		for j := 0; j < consecutiveIntervals; j++ {
-			idx := i - j
+			point := tpwq.Points.At(i - j)
 			// If data doesn't exist, treat as offending (above threshold)
-			if !tpwq.DataExists[idx] {
+			if !point.Exists {
 				consecutiveCount++
 				if j == consecutiveIntervals-1 {
 					// Use the timestamp if available, otherwise use 0
-					if tpwq.TimeStamps[idx] != 0 {
-						startTime = tpwq.TimeStamps[idx]
+					if point.TimeStamp != 0 {
+						startTime = point.TimeStamp
 					}
 				}
-			} else if tpwq.ThreadPoolWriteQueues[idx] >= uint32(threshold) {
+			} else if point.Queue >= uint32(threshold) {
 				consecutiveCount++
 				if j == consecutiveIntervals-1 {
-					startTime = tpwq.TimeStamps[idx]
+					startTime = point.TimeStamp
 				}
 			} else {
 				break
@@ -292,87 +734,36 @@ func checkPressureWithMissingAsOffending(tpwq *types.TPWQueue, threshold, consec
 	return false, 0
 }
 
-// recordWritePressureEvent records a write pressure event if it's new
-func recordWritePressureEvent(hostname, clusterName string, eventStartTime int64) bool {
-	// Create event key: hostname_epochseconds
-	eventKey := fmt.Sprintf("%s_%d", hostname, eventStartTime)
-
-	types.WritePressureMu.Lock()
-	defer types.WritePressureMu.Unlock()
+// logWritePressureEvent writes a fired or resolved event to the write pressure log file
+func logWritePressureEvent(event events.Event) {
+	currentTime := time.Now()
+	observedTime := time.UnixMilli(event.StartsAt)
 
-	// Check if event already exists
-	if _, exists := types.WritePressureMap[eventKey]; exists {
-		return false // Event already recorded
+	tag, host := "PRESSURE_EVENT", event.Labels["host"]
+	if event.Source == clusterWritePressureSource {
+		tag, host = "CLUSTER_PRESSURE_EVENT", "*"
 	}
-
-	// Create new event
-	event := &types.WritePressureEvent{
-		EventStartTime: eventStartTime,
-		HostName:       hostname,
-		ClusterName:    clusterName,
+	if event.State == events.StateResolved {
+		tag = strings.TrimSuffix(tag, "EVENT") + "RESOLVED"
 	}
-
-	// Add to global map
-	types.WritePressureMap[eventKey] = event
-
-	// Log to write pressure log file
-	logWritePressureEvent(event)
-
-	logger.JobInfo("checkForWritePressure", "New write pressure event: cluster=%s, host=%s, startTime=%d",
-		clusterName, hostname, eventStartTime)
-
-	return true
-}
-
-// logWritePressureEvent writes an event to the write pressure log file
-func logWritePressureEvent(event *types.WritePressureEvent) {
-	currentTime := time.Now()
-	observedTime := time.Unix(event.EventStartTime, 0)
-
-	logEntry := fmt.Sprintf("[%s] [PRESSURE_EVENT] CurrentTime=%s, ObservedTime=%s, Host=%s, Cluster=%s",
+	logEntry := fmt.Sprintf("[%s] [%s] CurrentTime=%s, ObservedTime=%s, Host=%s, Cluster=%s, EventID=%s, Severity=%s",
 		currentTime.Format("2006-01-02 15:04:05.000"),
+		tag,
 		currentTime.Format("2006-01-02 15:04:05"),
 		observedTime.Format("2006-01-02 15:04:05"),
-		event.HostName,
-		event.ClusterName,
+		host,
+		event.Cluster(),
+		event.ID,
+		event.Severity,
 	)
-
-	if writePressureLogger != nil {
-		writePressureLogger.Println(logEntry)
-	}
-}
-
-// cleanupOldEvents removes events older than oldRunTime from the WritePressureMap
-func cleanupOldEvents(oldRunTime int64) {
-	if oldRunTime == 0 {
-		// Not enough runs yet to clean up
-		return
+	if event.State == events.StateResolved {
+		logEntry += fmt.Sprintf(", Duration=%s", event.Duration(currentTime).Round(time.Second))
 	}
-
-	types.WritePressureMu.Lock()
-	defer types.WritePressureMu.Unlock()
-
-	removedCount := 0
-	for key := range types.WritePressureMap {
-		// Extract timestamp from key (format: hostname_epochseconds)
-		parts := strings.Split(key, "_")
-		if len(parts) < 2 {
-			continue
-		}
-
-		timestamp, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
-		if err != nil {
-			continue
-		}
-
-		// Remove if timestamp is older than oldRunTime
-		if timestamp < oldRunTime {
-			delete(types.WritePressureMap, key)
-			removedCount++
-		}
+	if event.Suppressed {
+		logEntry += fmt.Sprintf(", Maintenance=%q", event.MaintenanceReason)
 	}
 
-	if removedCount > 0 {
-		logger.JobInfo("checkForWritePressure", "Cleaned up %d old write pressure events", removedCount)
+	if writePressureLogger != nil {
+		writePressureLogger.Println(logEntry)
 	}
 }
@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// untieredTier is the tier of data nodes without a tier in the inventory or a tier role
+const untieredTier = "untiered"
+
+// tierRoles are the Elasticsearch roles of the data tiers, in the order a node holding several
+// of them is attributed
+var tierRoles = []struct {
+	role string
+	tier string
+}{
+	{"data_hot", "hot"},
+	{"data_warm", "warm"},
+	{"data_cold", "cold"},
+	{"data_frozen", "frozen"},
+}
+
+// nodesTierStats is the part of the _nodes/stats/fs,indices response that is used
+type nodesTierStats struct {
+	Nodes map[string]struct {
+		Host    string   `json:"host"`
+		Name    string   `json:"name"`
+		Roles   []string `json:"roles"`
+		Indices struct {
+			Docs struct {
+				Count uint64 `json:"count"`
+			} `json:"docs"`
+			Store struct {
+				SizeInBytes uint64 `json:"size_in_bytes"`
+			} `json:"store"`
+			Indexing struct {
+				IndexTotal uint64 `json:"index_total"`
+			} `json:"indexing"`
+			ShardStats struct {
+				TotalCount uint64 `json:"total_count"`
+			} `json:"shard_stats"`
+		} `json:"indices"`
+		FS struct {
+			Total struct {
+				TotalInBytes     uint64 `json:"total_in_bytes"`
+				AvailableInBytes uint64 `json:"available_in_bytes"`
+			} `json:"total"`
+		} `json:"fs"`
+	} `json:"nodes"`
+}
+
+// GetDataTiers breaks down the storage and write load of every cluster by data tier: the shards,
+// documents and store size the data nodes of each tier hold, their disk, and the documents they
+// indexed per second since the previous run (_nodes/stats). A node's tier is its nodeTier in the
+// inventory, else its data tier role; data nodes with neither are untiered. The breakdown is
+// served by /api/tiers/{clusterName}.
+func GetDataTiers(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("getDataTiers", "Starting data tier breakdown")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("getDataTiers", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	cacheTTL := p.Duration("cacheTTL", 0)
+	if err := checkParams("getDataTiers", p); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	tieredNodes := 0
+
+	summary, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		var stats nodesTierStats
+		query := queryOptions{JobName: "getDataTiers", TTL: cacheTTL}
+		if err := getClusterJSON(ctx, cluster, "/_nodes/stats/fs,indices/docs,store,indexing,shard_stats", query, &stats); err != nil {
+			return fmt.Errorf("failed to fetch node stats: %w", err)
+		}
+
+		nowMs := utils.TimeNowMillis()
+		previous, _ := types.GetTiers(clusterName)
+		breakdown := &types.ClusterTiers{
+			SnapShotTime: nowMs,
+			Tiers:        make(map[string]*types.TierUsage),
+			IndexTotals:  make(map[string]uint64, len(stats.Nodes)),
+		}
+		indexed := make(map[string]uint64) // docs indexed per tier since the previous run
+		rated := make(map[string]bool)     // tiers with a node sampled in the previous run
+		hosts := newHostResolver(cluster)
+		for _, node := range stats.Nodes {
+			hostName := hosts.nodeHostName(node.Host, node.Name)
+			tier, isData := nodeTier(hosts, hostName, node.Roles)
+			if !isData {
+				continue
+			}
+
+			usage, exists := breakdown.Tiers[tier]
+			if !exists {
+				usage = &types.TierUsage{Tier: tier, IndexingRate: -1}
+				breakdown.Tiers[tier] = usage
+			}
+			usage.Nodes = append(usage.Nodes, hostName)
+			usage.Shards += node.Indices.ShardStats.TotalCount
+			usage.Docs += node.Indices.Docs.Count
+			usage.StoreBytes += node.Indices.Store.SizeInBytes
+			usage.DiskTotalBytes += node.FS.Total.TotalInBytes
+			usage.DiskAvailableBytes += node.FS.Total.AvailableInBytes
+
+			// A node's counter restarts with the node: it only counts from its next sample
+			indexTotal := node.Indices.Indexing.IndexTotal
+			breakdown.IndexTotals[hostName] = indexTotal
+			if previous != nil {
+				if last, ok := previous.IndexTotals[hostName]; ok && indexTotal >= last {
+					indexed[tier] += indexTotal - last
+					rated[tier] = true
+				}
+			}
+		}
+
+		for tier, usage := range breakdown.Tiers {
+			sort.Strings(usage.Nodes)
+			if rated[tier] && nowMs > previous.SnapShotTime {
+				usage.IndexingRate = float64(indexed[tier]) * 1000 / float64(nowMs-previous.SnapShotTime)
+			}
+		}
+
+		types.SetTiers(clusterName, breakdown)
+		recordTierMetrics(clusterName, breakdown)
+
+		mu.Lock()
+		tieredNodes += len(breakdown.IndexTotals)
+		mu.Unlock()
+
+		logger.JobInfo("getDataTiers", "Cluster %s: %d tiers, %d data nodes", clusterName, len(breakdown.Tiers), len(breakdown.IndexTotals))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.JobInfo("getDataTiers", "Completed: %d clusters, %d data nodes", summary.Succeeded, tieredNodes)
+	return nil
+}
+
+// nodeTier returns the data tier of a node and whether it is a data node
+func nodeTier(hosts *hostResolver, hostName string, roles []string) (string, bool) {
+	tier := ""
+	for _, tierRole := range tierRoles {
+		if utils.Contains(roles, tierRole.role) {
+			tier = tierRole.tier
+			break
+		}
+	}
+	if tier == "" && !utils.Contains(roles, "data") && !utils.Contains(roles, "data_content") {
+		return "", false
+	}
+	if node := hosts.node(hostName); node != nil && node.NodeTier != "" {
+		return node.NodeTier, true
+	}
+	if tier == "" {
+		tier = untieredTier
+	}
+	return tier, true
+}
+
+// recordTierMetrics exports the data tier breakdown of a cluster
+func recordTierMetrics(clusterName string, breakdown *types.ClusterTiers) {
+	clusterLabel := prometheus.Labels{"cluster": clusterName}
+	metrics.TierStoreBytes.DeletePartialMatch(clusterLabel)
+	metrics.TierDiskAvailableBytes.DeletePartialMatch(clusterLabel)
+	metrics.TierIndexingRate.DeletePartialMatch(clusterLabel)
+
+	for tier, usage := range breakdown.Tiers {
+		metrics.TierStoreBytes.WithLabelValues(clusterName, tier).Set(float64(usage.StoreBytes))
+		metrics.TierDiskAvailableBytes.WithLabelValues(clusterName, tier).Set(float64(usage.DiskAvailableBytes))
+		if usage.IndexingRate >= 0 {
+			metrics.TierIndexingRate.WithLabelValues(clusterName, tier).Set(usage.IndexingRate)
+		}
+	}
+}
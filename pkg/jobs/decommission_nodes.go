@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/maintenance"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+)
+
+// catAllocationRow is a row of the _cat/allocation?format=json response
+type catAllocationRow struct {
+	Shards string `json:"shards"`
+	Host   string `json:"host"`
+	IP     string `json:"ip"`
+	Node   string `json:"node"`
+}
+
+// DecommissionNodes follows the nodes being decommissioned (started through the API or with
+// the nodes parameter, entries of "<cluster>/<host>"): it counts the shards left on each with
+// _cat/allocation, and once a node holds none or has left its cluster, removes it from the
+// inventory (unless removeFromInventory is false) and prunes its write queue and task data.
+// Events of decommissioning nodes are suppressed meanwhile.
+func DecommissionNodes(ctx context.Context, params map[string]interface{}) error {
+	p := jobparams.New(params)
+	nodes := p.StringSlice("nodes")
+	reason := p.String("reason", "")
+	removeFromInventory := p.Bool("removeFromInventory", true)
+	if err := p.Err(); err != nil {
+		return err
+	}
+
+	for _, entry := range nodes {
+		clusterName, hostName, err := parseNodeEntry(entry)
+		if err != nil {
+			return err
+		}
+		if _, started, err := maintenance.StartDecommission(clusterName, hostName, reason); err != nil {
+			return err
+		} else if started {
+			logger.JobInfo("decommissionNodes", "Decommissioning node %s of cluster %s", hostName, clusterName)
+		}
+	}
+
+	decommissions := maintenance.Decommissions()
+	finished := 0
+	for _, d := range decommissions {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		shards, err := nodeShards(ctx, d.Cluster, d.Host)
+		maintenance.RecordDecommissionCheck(d.Cluster, d.Host, shards, err)
+		if err != nil {
+			logger.JobWarn("decommissionNodes", "Cannot count the shards of node %s of cluster %s: %v", d.Host, d.Cluster, err)
+			continue
+		}
+		if shards > 0 {
+			logger.JobInfo("decommissionNodes", "Node %s of cluster %s still holds %d shards", d.Host, d.Cluster, shards)
+			continue
+		}
+		FinishDecommission(d.Cluster, d.Host, removeFromInventory)
+		finished++
+	}
+
+	logger.JobInfo("decommissionNodes", "Completed: %d nodes decommissioning, %d finished", len(decommissions)-finished, finished)
+	return nil
+}
+
+// ValidateDecommissionNodesParams checks the nodes of a decommissionNodes job when it is loaded
+func ValidateDecommissionNodesParams(params map[string]interface{}) error {
+	p := jobparams.New(params)
+	nodes := p.StringSlice("nodes")
+	p.Bool("removeFromInventory", true)
+	if err := p.Err(); err != nil {
+		return err
+	}
+	for _, entry := range nodes {
+		if _, _, err := parseNodeEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseNodeEntry splits a "<cluster>/<host>" entry of the nodes parameter
+func parseNodeEntry(entry string) (string, string, error) {
+	i := strings.LastIndex(entry, "/")
+	if i <= 0 || i == len(entry)-1 {
+		return "", "", fmt.Errorf("nodes: invalid entry %q, expected <cluster>/<host>", entry)
+	}
+	return entry[:i], entry[i+1:], nil
+}
+
+// nodeShards returns the shards allocated to a node; a node that left its cluster holds none
+func nodeShards(ctx context.Context, clusterName, hostName string) (int, error) {
+	cluster, err := queryableCluster(clusterName)
+	if err != nil {
+		return 0, err
+	}
+	ipAddress := ""
+	if node := cluster.GetNode(hostName); node != nil {
+		ipAddress = node.IPAddress
+	}
+
+	var rows []catAllocationRow
+	query := queryOptions{JobName: "decommissionNodes"}
+	if err := getClusterJSON(ctx, cluster, "/_cat/allocation?format=json&h=shards,host,ip,node", query, &rows); err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
+		if row.Host == hostName || row.Node == hostName || (ipAddress != "" && row.IP == ipAddress) {
+			shards, err := strconv.Atoi(row.Shards)
+			if err != nil {
+				return 0, fmt.Errorf("invalid shard count %q of node %s", row.Shards, row.Node)
+			}
+			return shards, nil
+		}
+	}
+	return 0, nil
+}
+
+// FinishDecommission ends the decommission of a node: it prunes the node's write queue and
+// task data and, with removeFromInventory, drops it from the inventory. loadFromMasterCSV adds
+// the node again while the CSV still lists it.
+func FinishDecommission(clusterName, hostName string, removeFromInventory bool) {
+	if removeFromInventory {
+		types.UpdateCluster(clusterName, func(cluster *types.ClusterData) {
+			nodes := cluster.Nodes[:0]
+			for _, node := range cluster.Nodes {
+				if node.HostName != hostName {
+					nodes = append(nodes, node)
+				}
+			}
+			cluster.Nodes = nodes
+		})
+	}
+	types.RemoveNodeData(clusterName, hostName)
+	maintenance.EndDecommission(clusterName, hostName)
+	logger.JobInfo("decommissionNodes", "Node %s of cluster %s decommissioned (removed from inventory: %t)",
+		hostName, clusterName, removeFromInventory)
+}
@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/output"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+)
+
+// dumpedStructures are the per-cluster structures dumpState can write, by the name used in
+// its include parameter. Cluster inventory is left out as it carries credentials.
+var dumpedStructures = map[string]func(clusterName string) (interface{}, bool){
+	"indexingRate":  func(c string) (interface{}, bool) { return types.GetIndexingRate(c) },
+	"retention":     func(c string) (interface{}, bool) { return types.GetRetentionReport(c) },
+	"diskUsage":     func(c string) (interface{}, bool) { return types.GetDiskUsage(c) },
+	"jvmStats":      func(c string) (interface{}, bool) { return types.GetJVMStats(c) },
+	"rejections":    func(c string) (interface{}, bool) { return types.GetThreadPoolRejections(c) },
+	"segments":      func(c string) (interface{}, bool) { return types.GetSegmentStats(c) },
+	"recoveries":    func(c string) (interface{}, bool) { return types.GetRecoveries(c) },
+	"settingsDrift": func(c string) (interface{}, bool) { return types.GetSettingsDrift(c) },
+	"indexFields":   func(c string) (interface{}, bool) { return types.GetFieldCounts(c) },
+	"pipelines":     func(c string) (interface{}, bool) { return types.GetPipelineStats(c) },
+	"remotes":       func(c string) (interface{}, bool) { return types.GetRemotes(c) },
+	"events": func(c string) (interface{}, bool) {
+		clusterEvents := events.Query(events.Filter{Cluster: c, State: events.StateFiring})
+		return clusterEvents, len(clusterEvents) > 0
+	},
+}
+
+// DumpState writes the collected state of every cluster to an output destination, one JSON
+// document per cluster and run named <folder>/<cluster>/<time>.json (.json.gz with
+// compress), so the state can be inspected after the fact or loaded elsewhere. include
+// selects the structures (default all); structures a cluster has no data for are left out.
+// The retention of the destination applies to the folder of each cluster.
+func DumpState(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("dumpState", "Starting state dump")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("dumpState", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	opts.SkipUUIDCheck = true // the dump is of the stored data
+	destination := p.String("destination", output.DefaultDestination)
+	folder := strings.Trim(p.String("folder", "stateDump"), "/")
+	include := p.StringSlice("include")
+	compress := p.Bool("compress", false)
+	if err := checkParams("dumpState", p); err != nil {
+		return err
+	}
+	if !output.Exists(destination) {
+		return fmt.Errorf("unknown output destination %q (configured: %s)", destination, strings.Join(output.Names(), ", "))
+	}
+	if len(include) == 0 {
+		for name := range dumpedStructures {
+			include = append(include, name)
+		}
+		sort.Strings(include)
+	}
+	for _, name := range include {
+		if _, ok := dumpedStructures[name]; !ok {
+			return fmt.Errorf("unknown structure %q in include", name)
+		}
+	}
+
+	now := time.Now().UTC()
+	var mu sync.Mutex
+	var written int64
+
+	summary, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		dump := map[string]interface{}{
+			"cluster":      clusterName,
+			"snapShotTime": now.UnixMilli(),
+		}
+		for _, name := range include {
+			if structure, ok := dumpedStructures[name](clusterName); ok {
+				dump[name] = structure
+			}
+		}
+
+		data, err := json.MarshalIndent(dump, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal state: %w", err)
+		}
+		name := path.Join(folder, clusterName, now.Format("20060102-150405")+".json")
+		if compress {
+			if data, err = gzipBytes(data); err != nil {
+				return err
+			}
+			name += ".gz"
+		}
+
+		location, err := output.Write(ctx, destination, name, data)
+		if err != nil {
+			return err
+		}
+		logger.JobDebug("dumpState", "Cluster %s: state written to %s", clusterName, location)
+
+		mu.Lock()
+		written += int64(len(data))
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.JobInfo("dumpState", "Completed: %d clusters dumped to %s (%d bytes)", summary.Succeeded, destination, written)
+	return nil
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress state: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress state: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,190 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
+)
+
+// Endpoint selection strategies for jobs that query a list of equivalent endpoints
+const (
+	endpointStrategySticky     = "sticky"     // keep using the last endpoint that worked
+	endpointStrategyRoundRobin = "roundRobin" // spread queries over all healthy endpoints
+)
+
+const (
+	defaultEndpointCooldown = time.Minute
+	maxEndpointCooldown     = 30 * time.Minute
+)
+
+// endpointHealth tracks the recent behaviour of one endpoint
+type endpointHealth struct {
+	consecutiveFailures int
+	cooldownUntil       time.Time
+}
+
+// endpointRegistry keeps endpoint health across clusters and job runs, so an endpoint that
+// is down is skipped until its cooldown expires instead of delaying every query
+type endpointRegistry struct {
+	mu        sync.Mutex
+	health    map[string]*endpointHealth
+	preferred map[string]string // endpoint list key -> last endpoint that worked
+	next      map[string]int    // endpoint list key -> round-robin position
+}
+
+// monitoringEndpoints is shared by all jobs querying the monitoring cluster
+var monitoringEndpoints = newEndpointRegistry()
+
+// endpointSelection is the endpoint list of one job together with how to pick from it
+type endpointSelection struct {
+	endpoints []string
+	strategy  string
+	cooldown  time.Duration
+	registry  *endpointRegistry
+}
+
+// monitoringEndpointSelection reads the endpoint selection parameters of a job querying
+// the monitoring cluster (endpointStrategy, endpointCooldown)
+func monitoringEndpointSelection(endpoints []string, p *jobparams.Reader) endpointSelection {
+	return endpointSelection{
+		endpoints: endpoints,
+		strategy:  p.OneOf("endpointStrategy", endpointStrategySticky, endpointStrategySticky, endpointStrategyRoundRobin),
+		cooldown:  p.Duration("endpointCooldown", defaultEndpointCooldown),
+		registry:  monitoringEndpoints,
+	}
+}
+
+// ordered returns the endpoints in the order they should be tried for this query
+func (s endpointSelection) ordered() []string {
+	return s.registry.order(s.endpoints, s.strategy)
+}
+
+// succeeded records a successful query against endpoint
+func (s endpointSelection) succeeded(endpoint string) {
+	s.registry.success(s.endpoints, endpoint)
+}
+
+// failed records a failed query against endpoint. Failures caused by the caller's
+// context being cancelled say nothing about the endpoint and are ignored.
+func (s endpointSelection) failed(ctx context.Context, endpoint string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	s.registry.failure(endpoint, err, s.cooldown)
+}
+
+func newEndpointRegistry() *endpointRegistry {
+	return &endpointRegistry{
+		health:    make(map[string]*endpointHealth),
+		preferred: make(map[string]string),
+		next:      make(map[string]int),
+	}
+}
+
+// listKey identifies an endpoint list (jobs may be configured with different lists)
+func listKey(endpoints []string) string {
+	key := ""
+	for _, endpoint := range endpoints {
+		key += endpoint + "\n"
+	}
+	return key
+}
+
+// order returns the endpoints in the order they should be tried. Healthy endpoints come
+// first (preferred or rotated according to strategy); endpoints in cooldown are kept at the
+// end as a last resort, soonest-recovering first.
+func (reg *endpointRegistry) order(endpoints []string, strategy string) []string {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	key := listKey(endpoints)
+	candidates := append([]string(nil), endpoints...)
+
+	switch strategy {
+	case endpointStrategyRoundRobin:
+		if n := len(candidates); n > 0 {
+			start := reg.next[key] % n
+			reg.next[key] = start + 1
+			candidates = append(candidates[start:], candidates[:start]...)
+		}
+	default:
+		if preferred, ok := reg.preferred[key]; ok {
+			for i, endpoint := range candidates {
+				if endpoint == preferred {
+					candidates = append([]string{endpoint}, append(candidates[:i:i], candidates[i+1:]...)...)
+					break
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	healthy := make([]string, 0, len(candidates))
+	cooling := make([]string, 0)
+	for _, endpoint := range candidates {
+		if h, ok := reg.health[endpoint]; ok && now.Before(h.cooldownUntil) {
+			cooling = append(cooling, endpoint)
+			continue
+		}
+		healthy = append(healthy, endpoint)
+	}
+	for i := 1; i < len(cooling); i++ {
+		for j := i; j > 0 && reg.health[cooling[j]].cooldownUntil.Before(reg.health[cooling[j-1]].cooldownUntil); j-- {
+			cooling[j], cooling[j-1] = cooling[j-1], cooling[j]
+		}
+	}
+	return append(healthy, cooling...)
+}
+
+// success marks an endpoint healthy and makes it the preferred endpoint of its list
+func (reg *endpointRegistry) success(endpoints []string, endpoint string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	h := reg.get(endpoint)
+	if h.consecutiveFailures > 0 {
+		logger.AppInfo("Monitoring endpoint %s recovered after %d failures", endpoint, h.consecutiveFailures)
+	}
+	h.consecutiveFailures = 0
+	h.cooldownUntil = time.Time{}
+	reg.preferred[listKey(endpoints)] = endpoint
+
+	metrics.MonitoringEndpointHealthy.WithLabelValues(endpoint).Set(1)
+}
+
+// failure puts an endpoint in cooldown; the cooldown doubles with every consecutive failure
+func (reg *endpointRegistry) failure(endpoint string, err error, cooldown time.Duration) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if cooldown <= 0 {
+		cooldown = defaultEndpointCooldown
+	}
+
+	h := reg.get(endpoint)
+	h.consecutiveFailures++
+	for i := 1; i < h.consecutiveFailures && cooldown < maxEndpointCooldown; i++ {
+		cooldown *= 2
+	}
+	if cooldown > maxEndpointCooldown {
+		cooldown = maxEndpointCooldown
+	}
+	h.cooldownUntil = time.Now().Add(cooldown)
+
+	logger.AppWarn("Monitoring endpoint %s failed (%d in a row), cooling down for %s: %v",
+		endpoint, h.consecutiveFailures, cooldown, err)
+	metrics.MonitoringEndpointHealthy.WithLabelValues(endpoint).Set(0)
+}
+
+func (reg *endpointRegistry) get(endpoint string) *endpointHealth {
+	h, ok := reg.health[endpoint]
+	if !ok {
+		h = &endpointHealth{}
+		reg.health[endpoint] = h
+	}
+	return h
+}
@@ -0,0 +1,215 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// httpClientKey identifies the clients handed out by esHTTPClient
+type httpClientKey struct {
+	insecureTLS bool
+	timeout     time.Duration
+}
+
+var (
+	httpClientsMu sync.Mutex
+	httpClients   = make(map[httpClientKey]*http.Client)
+)
+
+// esHTTPClient returns the shared HTTP client for the given TLS setting and timeout, so
+// jobs reuse connections across clusters and runs instead of building a transport per request
+func esHTTPClient(insecureTLS bool, timeout time.Duration) *http.Client {
+	key := httpClientKey{insecureTLS: insecureTLS, timeout: timeout}
+
+	httpClientsMu.Lock()
+	defer httpClientsMu.Unlock()
+
+	client, ok := httpClients[key]
+	if !ok {
+		client = &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureTLS},
+			},
+		}
+		httpClients[key] = client
+	}
+	return client
+}
+
+// httpStatusError is returned by doQuery for responses other than 200 OK
+type httpStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// queryOptions describes a query for the query cache
+type queryOptions struct {
+	JobName string        // used as metric label
+	Cluster string        // cluster the data belongs to, part of the cache key
+	TTL     time.Duration // maximum age of a cached response this caller accepts, 0 = no caching
+}
+
+// doQuery executes req and returns the body of a 200 response; any other status is returned
+// as *httpStatusError. body must be the request body (nil for GET), it is hashed into the
+// cache key. With a TTL, a successful response younger than the TTL is served from the query
+// cache, so jobs and API requests asking for the same data of the same cluster share a result.
+// Without a TTL the response body is streamed and nothing is cached.
+func doQuery(client *http.Client, req *http.Request, body []byte, opts queryOptions) (io.ReadCloser, error) {
+	var key string
+	if opts.TTL > 0 {
+		key = queryCacheKey(opts.Cluster, req, body)
+		if data, ok := esQueryCache.get(key, opts.TTL); ok {
+			metrics.QueryCacheHitsTotal.WithLabelValues(opts.JobName).Inc()
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		metrics.QueryCacheMissesTotal.WithLabelValues(opts.JobName).Inc()
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, &httpStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	if opts.TTL <= 0 {
+		return resp.Body, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	esQueryCache.put(key, data, opts.TTL)
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// queryableCluster returns a snapshot of a cluster that has credentials and an active
+// endpoint, or the reason it cannot be queried
+func queryableCluster(clusterName string) (*types.ClusterData, error) {
+	cluster, exists := types.GetCluster(clusterName)
+	if !exists {
+		return nil, fmt.Errorf("cluster not found")
+	}
+	if cluster.AccessCred.Preferred == 0 {
+		return nil, fmt.Errorf("no credentials available (Preferred=0)")
+	}
+	if cluster.ActiveEndpoint == "" {
+		return nil, fmt.Errorf("no active endpoint")
+	}
+	return cluster, nil
+}
+
+// getClusterJSON sends a GET for path (e.g. "/_nodes/stats/fs") to the active endpoint of a
+// cluster with the cluster's credentials and decodes the JSON response into out
+func getClusterJSON(ctx context.Context, cluster *types.ClusterData, path string, opts queryOptions, out interface{}) error {
+	client := esHTTPClient(cluster.InsecureTLS, 30*time.Second)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(cluster.ActiveEndpoint, "/")+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	utils.AddAuthentication(req, &cluster.AccessCred)
+
+	opts.Cluster = cluster.ClusterName
+	body, err := doQuery(client, req, nil, opts)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// queryCacheKey is (cluster, method, path and query string, body hash)
+func queryCacheKey(cluster string, req *http.Request, body []byte) string {
+	sum := sha256.Sum256(body)
+	return cluster + "\x00" + req.Method + "\x00" + req.URL.RequestURI() + "\x00" + hex.EncodeToString(sum[:])
+}
+
+// queryCacheEntry is one cached response body
+type queryCacheEntry struct {
+	data      []byte
+	fetchedAt time.Time
+	keepUntil time.Time // the longest TTL any caller asked for, counted from fetchedAt
+}
+
+// queryCache holds successful responses. Each caller decides how old a response it accepts,
+// entries are dropped once they are older than every TTL they were requested with.
+type queryCache struct {
+	mu      sync.Mutex
+	entries map[string]*queryCacheEntry
+	bytes   int
+}
+
+var esQueryCache = &queryCache{entries: make(map[string]*queryCacheEntry)}
+
+func (c *queryCache) get(key string, ttl time.Duration) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	expires := entry.fetchedAt.Add(ttl)
+	if !time.Now().Before(expires) {
+		return nil, false
+	}
+	if expires.After(entry.keepUntil) {
+		entry.keepUntil = expires
+	}
+	return entry.data, true
+}
+
+func (c *queryCache) put(key string, data []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	// Drop expired entries
+	for k, entry := range c.entries {
+		if !now.Before(entry.keepUntil) {
+			c.bytes -= len(entry.data)
+			delete(c.entries, k)
+		}
+	}
+
+	if old, ok := c.entries[key]; ok {
+		c.bytes -= len(old.data)
+	}
+	c.entries[key] = &queryCacheEntry{data: data, fetchedAt: now, keepUntil: now.Add(ttl)}
+	c.bytes += len(data)
+
+	metrics.QueryCacheEntries.Set(float64(len(c.entries)))
+	metrics.QueryCacheBytes.Set(float64(c.bytes))
+}
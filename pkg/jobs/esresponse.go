@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// decodePath decodes the value at a dotted path of a JSON document, e.g.
+// "aggregations.hostname.buckets", into out. Keys may contain dots themselves
+// ("node_stats.thread_pool.write.queue"); the longest key present wins. The error names the
+// first part of the path that is missing or not an object, so a change in the shape of an
+// Elasticsearch response is reported instead of being read as no data.
+func decodePath(raw json.RawMessage, path string, out interface{}) error {
+	segments := strings.Split(path, ".")
+	walked := ""
+	for len(segments) > 0 {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil || object == nil {
+			if walked == "" {
+				return fmt.Errorf("response is not an object")
+			}
+			return fmt.Errorf("%s is not an object", walked)
+		}
+
+		found := false
+		for n := len(segments); n > 0 && !found; n-- {
+			key := strings.Join(segments[:n], ".")
+			if value, ok := object[key]; ok {
+				raw, segments, found = value, segments[n:], true
+				walked = strings.TrimPrefix(walked+"."+key, ".")
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s not found", strings.TrimPrefix(walked+"."+segments[0], "."))
+		}
+	}
+
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
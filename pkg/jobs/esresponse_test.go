@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ElasticObservability/pkg/types"
+)
+
+// update rewrites the golden files of the parser tests: go test ./pkg/jobs -run Golden -update
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenCluster is the inventory the golden responses are parsed for; es-data-03 is
+// reported by its IP address
+var goldenCluster = &types.ClusterData{
+	ClusterName: "golden",
+	Nodes: []*types.Node{
+		{HostName: "es-data-01", IPAddress: "10.0.4.11", Zone: "zone-a"},
+		{HostName: "es-data-02", IPAddress: "10.0.4.12", Zone: "zone-b"},
+		{HostName: "es-data-03", IPAddress: "10.0.4.13", Zone: "zone-c"},
+	},
+}
+
+// openTestdata opens a recorded response in testdata
+func openTestdata(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// checkGolden compares the JSON of a parse result with testdata/<name>.golden, or writes it
+// there with -update
+func checkGolden(t *testing.T, name string, got interface{}) {
+	t.Helper()
+	data, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, '\n')
+
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(golden, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("%s differs from %s (run with -update to accept):\n%s", name, golden, data)
+	}
+}
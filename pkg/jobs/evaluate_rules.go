@@ -0,0 +1,198 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/notify"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/rules"
+)
+
+// rulesSource is the event store source of firing alerts
+const rulesSource = "rules"
+
+// EvaluateRules evaluates the alert rules over the collected series and, with notifyOwners,
+// sends the owner of each cluster one message listing the alerts that fired or resolved
+func EvaluateRules(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("evaluateRules", "Starting rule evaluation")
+
+	p := jobparams.New(params)
+	notifyOwners := p.Bool("notifyOwners", false)
+	list, err := loadRules(p)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result := rules.Evaluate(list, now)
+
+	// Firing alerts are recorded in the event store, which keeps their history
+	observed := make([]events.Observation, 0, result.Firing)
+	for _, alert := range rules.Alerts() {
+		if alert.State == rules.StateFiring {
+			observed = append(observed, events.Observation{
+				Name:        alert.Rule,
+				Severity:    alert.Severity,
+				Labels:      alert.Labels,
+				Annotations: alert.Annotations,
+				StartsAt:    alert.FiringSince,
+			})
+		}
+	}
+	if _, _, err := events.Sync(rulesSource, observed, now); err != nil {
+		logger.JobWarn("evaluateRules", "Failed to persist events: %v", err)
+	}
+
+	for _, alert := range result.Fired {
+		logger.JobWarn("evaluateRules", "Alert firing: rule=%s severity=%s labels=%v value=%g",
+			alert.Rule, alert.Severity, alert.Labels, alert.Value)
+	}
+	for _, alert := range result.Resolved {
+		logger.JobInfo("evaluateRules", "Alert resolved: rule=%s labels=%v", alert.Rule, alert.Labels)
+	}
+
+	if notifyOwners {
+		notifyAlertChanges(ctx, result)
+	}
+
+	logger.JobInfo("evaluateRules", "Completed: %d rules, %d firing (%d new), %d pending, %d resolved",
+		len(list), result.Firing, len(result.Fired), result.Pending, len(result.Resolved))
+	return nil
+}
+
+// ValidateEvaluateRulesParams loads and checks the rules when the job is loaded, so an unknown
+// series or a bad condition is reported before the first run
+func ValidateEvaluateRulesParams(params map[string]interface{}) error {
+	_, err := loadRules(jobparams.New(params))
+	return err
+}
+
+// loadRules reads the rules from the rulesFile parameter and/or the inline rules parameter
+func loadRules(p *jobparams.Reader) ([]*rules.Rule, error) {
+	rulesFile := p.String("rulesFile", "")
+	inline := p.Raw("rules")
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	if rulesFile == "" && inline == nil {
+		return nil, fmt.Errorf("rulesFile or rules parameter is required")
+	}
+
+	var list []*rules.Rule
+	if rulesFile != "" {
+		fromFile, err := rules.LoadFile(rulesFile)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, fromFile...)
+	}
+	if inline != nil {
+		parsed, err := rules.Parse(inline)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, parsed...)
+	}
+
+	// Names must be unique across both sources
+	return rules.Prepare(list)
+}
+
+// notifyAlertChanges sends one message per cluster for the alerts that fired or resolved.
+// Alerts of clusters in maintenance are suppressed by notify.NotifyCluster.
+func notifyAlertChanges(ctx context.Context, result rules.Result) {
+	fired := make(map[string][]rules.Alert)
+	resolved := make(map[string][]rules.Alert)
+	clusters := make(map[string]bool)
+	for _, alert := range result.Fired {
+		fired[alert.Cluster()] = append(fired[alert.Cluster()], alert)
+		clusters[alert.Cluster()] = true
+	}
+	for _, alert := range result.Resolved {
+		resolved[alert.Cluster()] = append(resolved[alert.Cluster()], alert)
+		clusters[alert.Cluster()] = true
+	}
+
+	names := make([]string, 0, len(clusters))
+	for clusterName := range clusters {
+		names = append(names, clusterName)
+	}
+	sort.Strings(names)
+
+	for _, clusterName := range names {
+		var text strings.Builder
+		severity := "info"
+		for _, alert := range fired[clusterName] {
+			fmt.Fprintf(&text, "FIRING [%s] %s %s value=%g\n", alert.Severity, alert.Rule, formatLabels(alert.Labels), alert.Value)
+			annotations := events.Enrich(rulesSource, alert.Rule, alert.Labels, alert.Annotations)
+			for _, k := range sortedAnnotationKeys(annotations) {
+				fmt.Fprintf(&text, "  %s: %s\n", k, annotations[k])
+			}
+			severity = maxSeverity(severity, alert.Severity)
+		}
+		for _, alert := range resolved[clusterName] {
+			fmt.Fprintf(&text, "RESOLVED %s %s\n", alert.Rule, formatLabels(alert.Labels))
+		}
+
+		err := notify.NotifyCluster(ctx, clusterName, notify.Message{
+			Subject: fmt.Sprintf("Alerts on cluster %s: %d firing, %d resolved",
+				clusterName, len(fired[clusterName]), len(resolved[clusterName])),
+			Text:     text.String(),
+			Severity: severity,
+		})
+		if err != nil {
+			logger.JobWarn("evaluateRules", "Failed to notify owner of cluster %s: %v", clusterName, err)
+		}
+	}
+}
+
+// formatLabels renders labels as {k=v, ...} in key order
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+labels[k])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// writeEventLinks writes the runbook, dashboard and owner of an event (see events.Enrich)
+// below its line in an alert
+func writeEventLinks(text *strings.Builder, annotations map[string]string) {
+	for _, link := range []struct{ key, label string }{
+		{events.AnnotationRunbookURL, "runbook"},
+		{events.AnnotationDashboardURL, "dashboard"},
+		{events.AnnotationOwner, "owner"},
+	} {
+		if value := annotations[link.key]; value != "" {
+			fmt.Fprintf(text, "    %s: %s\n", link.label, value)
+		}
+	}
+}
+
+func sortedAnnotationKeys(annotations map[string]string) []string {
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func maxSeverity(a, b string) string {
+	rank := map[string]int{"info": 0, "warning": 1, "critical": 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
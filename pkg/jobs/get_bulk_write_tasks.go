@@ -2,12 +2,12 @@ package jobs
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
+	"net/url"
 	"regexp"
 	"sort"
 	"strconv"
@@ -15,6 +15,9 @@ import (
 	"time"
 
 	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/scheduler"
 	"ElasticObservability/pkg/types"
 	"ElasticObservability/pkg/utils"
 )
@@ -25,136 +28,140 @@ func GetTDataWriteBulk_sTasks(ctx context.Context, params map[string]interface{}
 	logger.JobInfo("getTDataWriteBulk_sTasks", "Starting bulk write tasks monitoring job")
 
 	// Get parameters
-	excludeClusters := getStringSliceParam(params, "excludeClusters")
-	includeClusters := getStringSliceParam(params, "includeClusters")
-	historySize := getIntParam(params, "historySize", 60)
-	insecureTLS := getBoolParam(params, "insecureTLS", false)
-	maxConcurrent := getIntParam(params, "maxConcurrent", 9) // Default: process 5 clusters concurrently
-
-	// Validate and adjust historySize
-	if historySize < 10 {
-		historySize = 10
-		logger.JobWarn("getTDataWriteBulk_sTasks", "historySize too small, using minimum value: 10")
-	} else if historySize > 180 {
-		historySize = 180
-		logger.JobWarn("getTDataWriteBulk_sTasks", "historySize too large, using maximum value: 180")
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("getTDataWriteBulk_sTasks", p)
+	historySize := p.IntInRange("historySize", 60, 10, 180)
+	insecureTLS := p.Bool("insecureTLS", false)
+	maxConcurrent := p.IntInRange("maxConcurrent", 9, 1, 20)
+	cacheTTL := p.Duration("cacheTTL", 0)
+	delta := p.Bool("delta", false)
+	actionsParam := defaultTaskActions
+	if p.Has("actions") {
+		actionsParam = p.Map("actions")
 	}
-
-	// Validate maxConcurrent
-	if maxConcurrent < 1 {
-		maxConcurrent = 1
-	} else if maxConcurrent > 20 {
-		maxConcurrent = 20
-		logger.JobWarn("getTDataWriteBulk_sTasks", "maxConcurrent too large, using maximum value: 20")
+	if err := checkParams("getTDataWriteBulk_sTasks", p); err != nil {
+		return err
+	}
+	filters, err := parseTaskActionFilters(actionsParam)
+	if err != nil {
+		return err
 	}
 
-	logger.JobInfo("getTDataWriteBulk_sTasks", "Config: historySize=%d, insecureTLS=%v, maxConcurrent=%d",
-		historySize, insecureTLS, maxConcurrent)
-
-	// Build cluster list
-	clusterList := buildClusterList(includeClusters, excludeClusters)
-	logger.JobInfo("getTDataWriteBulk_sTasks", "Processing %d clusters in parallel", len(clusterList))
+	logger.JobInfo("getTDataWriteBulk_sTasks", "Config: historySize=%d, insecureTLS=%v, maxConcurrent=%d, actions=%d, delta=%v",
+		historySize, insecureTLS, maxConcurrent, len(filters), delta)
 
 	// Process clusters in parallel with concurrency limit
-	type result struct {
-		clusterName string
-		err         error
+	opts.MaxConcurrent = maxConcurrent
+	_, err = ForEachCluster(ctx, opts, func(ctx context.Context, name string) error {
+		return processClusterBulkTasks(ctx, name, filters, uint(historySize), insecureTLS, cacheTTL, delta)
+	})
+	return err
+}
+
+// ValidateBulkTasksParams checks the task action filters when the job is loaded
+func ValidateBulkTasksParams(params map[string]interface{}) error {
+	p := jobparams.New(params)
+	if !p.Has("actions") {
+		return nil
 	}
+	actions := p.Map("actions")
+	if err := p.Err(); err != nil {
+		return err
+	}
+	_, err := parseTaskActionFilters(actions)
+	return err
+}
 
-	results := make(chan result, len(clusterList))
-	semaphore := make(chan struct{}, maxConcurrent) // Limit concurrent goroutines
+// defaultTaskActions tracks the bulk write shard tasks only
+var defaultTaskActions = map[string]interface{}{
+	types.BulkTaskAction: `^indices:data/write/bulk\[s\]`,
+}
 
-	// Launch goroutines for each cluster
-	for _, clusterName := range clusterList {
-		// Acquire semaphore slot
-		semaphore <- struct{}{}
+// taskActionNameRegex restricts filter names to what can be passed as the action query
+// parameter of the API
+var taskActionNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
 
-		go func(name string) {
-			defer func() { <-semaphore }() // Release semaphore slot
+// taskActionFilter is a named set of task action patterns; the tasks matching any of them are
+// aggregated into the history of that name
+type taskActionFilter struct {
+	name     string
+	patterns []*regexp.Regexp
+}
 
-			err := processClusterBulkTasks(ctx, name, uint(historySize), insecureTLS)
-			results <- result{clusterName: name, err: err}
-		}(clusterName)
+// matches reports whether a task action matches one of the filter's patterns
+func (f *taskActionFilter) matches(action string) bool {
+	for _, pattern := range f.patterns {
+		if pattern.MatchString(action) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Collect results
-	successCount := 0
-	failCount := 0
+// parseTaskActionFilters reads the actions parameter: filter name to a regular expression, or
+// a list of them, matched against the task action, e.g.
+// deleteByQuery: "^indices:data/write/delete/byquery$"
+func parseTaskActionFilters(actions map[string]interface{}) ([]*taskActionFilter, error) {
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("actions must name at least one task action filter")
+	}
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	for i := 0; i < len(clusterList); i++ {
-		res := <-results
-		if res.err != nil {
-			logger.JobError("getTDataWriteBulk_sTasks", "Failed to process cluster %s: %v", res.clusterName, res.err)
-			failCount++
+	p := jobparams.New(actions)
+	filters := make([]*taskActionFilter, 0, len(names))
+	for _, name := range names {
+		if !taskActionNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid actions name %q, expected letters, digits, _ or -", name)
+		}
+		var expressions []string
+		if expression, ok := actions[name].(string); ok {
+			expressions = []string{expression}
 		} else {
-			successCount++
+			expressions = p.StringSlice(name)
 		}
-	}
-
-	logger.JobInfo("getTDataWriteBulk_sTasks", "Completed: %d succeeded, %d failed", successCount, failCount)
-	return nil
-}
-
-// buildClusterList creates the list of clusters to process
-func buildClusterList(includeClusters, excludeClusters []string) []string {
-	types.ClustersMu.RLock()
-	defer types.ClustersMu.RUnlock()
-
-	if len(includeClusters) > 0 {
-		// Use included clusters, but validate they exist
-		validClusters := make([]string, 0, len(includeClusters))
-		for _, clusterName := range includeClusters {
-			if utils.Contains(types.AllClustersList, clusterName) {
-				validClusters = append(validClusters, clusterName)
-			} else {
-				logger.JobWarn("getTDataWriteBulk_sTasks", "Cluster %s in includeClusters not found in global cluster list", clusterName)
-			}
+		if err := p.Err(); err != nil {
+			return nil, fmt.Errorf("invalid actions: %w", err)
+		}
+		if len(expressions) == 0 {
+			return nil, fmt.Errorf("actions.%s has no pattern", name)
 		}
-		return validClusters
-	}
 
-	// Use all clusters minus excluded ones
-	clusterList := make([]string, 0, len(types.AllClustersList))
-	for _, clusterName := range types.AllClustersList {
-		if !utils.Contains(excludeClusters, clusterName) {
-			clusterList = append(clusterList, clusterName)
+		filter := &taskActionFilter{name: name}
+		for _, expression := range expressions {
+			pattern, err := regexp.Compile(expression)
+			if err != nil {
+				return nil, fmt.Errorf("invalid actions.%s pattern %q: %w", name, expression, err)
+			}
+			filter.patterns = append(filter.patterns, pattern)
 		}
+		filters = append(filters, filter)
 	}
-	return clusterList
+	return filters, nil
 }
 
-// processClusterBulkTasks processes bulk task data for a single cluster
-func processClusterBulkTasks(ctx context.Context, clusterName string, historySize uint, insecureTLS bool) error {
+// processClusterBulkTasks processes the task data of a single cluster, one history per task
+// action filter. In delta mode the tasks are correlated with the previous snapshot by task ID.
+func processClusterBulkTasks(ctx context.Context, clusterName string, filters []*taskActionFilter, historySize uint, insecureTLS bool, cacheTTL time.Duration, delta bool) error {
 	// Get master endpoint for cluster
-	types.CurrentMasterEndPtsMu.RLock()
-	masterEndpoint, exists := types.AllCurrentMasterEndPoints[clusterName]
-	types.CurrentMasterEndPtsMu.RUnlock()
-
-	if !exists || masterEndpoint == "" {
+	masterEndpoint, exists := types.GetCurrentMasterEndpoint(clusterName)
+	if !exists {
 		return fmt.Errorf("no master endpoint found for cluster %s", clusterName)
 	}
 
 	// Ensure proper endpoint formatting
-	endpoint := strings.TrimSuffix(masterEndpoint, "/") + "/_tasks?pretty&human&detailed=true"
+	endpoint := strings.TrimSuffix(masterEndpoint, "/") + "/_tasks?detailed=true"
 
 	// Get cluster data for authentication
-	types.ClustersMu.RLock()
-	cluster, clusterExists := types.AllClusters[clusterName]
-	types.ClustersMu.RUnlock()
-
+	cluster, clusterExists := types.GetCluster(clusterName)
 	if !clusterExists {
 		return fmt.Errorf("cluster %s not found in AllClusters", clusterName)
 	}
 
-	// Create HTTP client
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: insecureTLS || cluster.InsecureTLS,
-			},
-		},
-	}
+	client := esHTTPClient(insecureTLS || cluster.InsecureTLS, 30*time.Second)
 
 	// Create and execute request
 	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
@@ -162,153 +169,253 @@ func processClusterBulkTasks(ctx context.Context, clusterName string, historySiz
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	utils.AddAuthentication(req, &cluster.AccessCred)
+	// The master node is contacted directly, so honour node-level credential overrides
+	cred := &cluster.AccessCred
+	if masterURL, err := url.Parse(masterEndpoint); err == nil {
+		cred = cluster.CredentialsForNode(masterURL.Hostname())
+	}
+	utils.AddAuthentication(req, cred)
 
-	resp, err := client.Do(req)
+	body, err := doQuery(client, req, nil, queryOptions{JobName: "getTDataWriteBulk_sTasks", Cluster: clusterName, TTL: cacheTTL})
 	if err != nil {
 		return fmt.Errorf("failed to execute request: %w", err)
 	}
-	defer resp.Body.Close()
+	defer body.Close()
 
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	// Stream the response and process tasks node by node
+	runID := scheduler.RunID(ctx)
+	var previous map[string]*previousTasks
+	if delta {
+		previous = previousTasksSnapshots(clusterName, filters, runID)
 	}
-
-	// Parse response
-	body, err := io.ReadAll(resp.Body)
+	byAction, err := parseTasksResponse(body, clusterName, cluster, filters, delta, previous)
 	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
-	}
-
-	var tasksResponse map[string]interface{}
-	if err := json.Unmarshal(body, &tasksResponse); err != nil {
 		return fmt.Errorf("failed to parse JSON response: %w", err)
 	}
 
-	// Process tasks and create cluster data
-	clusterData := parseTasksResponse(tasksResponse, clusterName, cluster)
-
-	// Update global history
-	updateClusterTasksHistory(clusterName, clusterData, historySize)
+	// Update global histories
+	for _, filter := range filters {
+		clusterData := byAction[filter.name]
+		clusterData.RunID = runID
+		outcome := updateClusterTasksHistory(clusterName, filter.name, clusterData, historySize)
+		if outcome.Dropped() {
+			logger.JobWarn("getTDataWriteBulk_sTasks", "Cluster %s (%s): dropped %s snapshot taken at %d",
+				clusterName, filter.name, outcome, clusterData.SnapShotTime)
+			continue
+		}
+		if clusterData.Delta != nil {
+			// A retry replacing its own snapshot was counted by its first attempt
+			if outcome == types.SnapshotAdded {
+				metrics.TasksNewTotal.WithLabelValues(clusterName, filter.name).Add(float64(clusterData.Delta.NewTasks))
+				metrics.TaskRequestsNewTotal.WithLabelValues(clusterName, filter.name).Add(float64(clusterData.Delta.NewRequests))
+			}
+			logger.JobInfo("getTDataWriteBulk_sTasks", "Cluster %s (%s): %d new tasks, %d new requests in %dms",
+				clusterName, filter.name, clusterData.Delta.NewTasks, clusterData.Delta.NewRequests, clusterData.Delta.IntervalMs)
+		}
 
-	logger.JobInfo("getTDataWriteBulk_sTasks", "Successfully processed cluster %s: %d nodes, %d indices",
-		clusterName, len(clusterData.DataWriteBulk_sTasksByNode), len(clusterData.DataWriteBulk_sTasksByIndex))
+		logger.JobInfo("getTDataWriteBulk_sTasks", "Successfully processed cluster %s (%s): %d nodes, %d indices",
+			clusterName, filter.name, len(clusterData.DataWriteBulk_sTasksByNode), len(clusterData.DataWriteBulk_sTasksByIndex))
+	}
 
 	return nil
 }
 
-// parseTasksResponse parses the _tasks API response and creates ClusterDataWriteBulk_sTasks
-func parseTasksResponse(response map[string]interface{}, clusterName string, cluster *types.ClusterData) *types.ClusterDataWriteBulk_sTasks {
-	clusterData := &types.ClusterDataWriteBulk_sTasks{
-		SnapShotTime:                time.Now().Unix(),
-		DataWriteBulk_sTasksByNode:  make(map[string]*types.NodeDataWriteBulk_sTasks),
-		DataWriteBulk_sTasksByIndex: make(map[string]*types.AggShardTaskDataWriteBulk_s),
-	}
+// taskNode is the part of a node entry of the _tasks response that is used
+type taskNode struct {
+	Host  string              `json:"host"`
+	Tasks map[string]taskInfo `json:"tasks"`
+}
 
-	// Parse nodes
-	nodes, ok := response["nodes"].(map[string]interface{})
-	if !ok {
-		return clusterData
-	}
+// taskInfo is the part of a task of the _tasks response that is used
+type taskInfo struct {
+	Action             string   `json:"action"`
+	Description        string   `json:"description"`
+	RunningTimeInNanos *float64 `json:"running_time_in_nanos"`
+	StartTimeInMillis  int64    `json:"start_time_in_millis"`
+	Cancellable        bool     `json:"cancellable"`
+	ParentTaskID       string   `json:"parent_task_id"` // "" for top-level tasks
+}
 
-	// Process each node
-	for _, nodeData := range nodes {
-		nodeMap, ok := nodeData.(map[string]interface{})
-		if !ok {
-			continue
-		}
+var (
+	// descRegex parses descriptions like "requests[236], index[index03][2]"
+	descRegex = regexp.MustCompile(`requests\[(\d+)\].*index\[([^\]]+)\]\[(\d+)\]`)
 
-		// Get hostname
-		hostName, ok := nodeMap["host"].(string)
-		if !ok {
-			continue
+	// indexListRegex finds the indices of descriptions without a shard, like
+	// "delete-by-query [logs-1, logs-2]" or "Force-merge indices [logs-1], maxSegments[1], ..."
+	indexListRegex = regexp.MustCompile(`\[([^\]]+)\]`)
+
+	// shardSuffixRegex matches the trailing "_<shard>" of an index_shard key, "_*" for tasks on
+	// every shard of an index
+	shardSuffixRegex = regexp.MustCompile(`_(\d+|\*)$`)
+)
+
+// parseTasksResponse streams the _tasks API response and creates one ClusterDataWriteBulk_sTasks
+// per task action filter, keyed by filter name. Nodes are decoded one at a time into typed
+// structs, so only one node's tasks are held in memory and fields that are not needed are
+// skipped by the decoder. In delta mode the IDs of the counted tasks are kept, and the tasks
+// not in the previous snapshot of a filter are counted in its Delta.
+func parseTasksResponse(r io.Reader, clusterName string, cluster *types.ClusterData, filters []*taskActionFilter,
+	delta bool, previous map[string]*previousTasks) (map[string]*types.ClusterDataWriteBulk_sTasks, error) {
+	snapShotTime := utils.TimeNowMillis()
+	byAction := make(map[string]*types.ClusterDataWriteBulk_sTasks, len(filters))
+	for _, filter := range filters {
+		clusterData := &types.ClusterDataWriteBulk_sTasks{
+			SnapShotTime:                snapShotTime,
+			DataWriteBulk_sTasksByNode:  make(map[string]*types.NodeDataWriteBulk_sTasks),
+			DataWriteBulk_sTasksByIndex: make(map[string]*types.AggShardTaskDataWriteBulk_s),
+		}
+		if delta {
+			clusterData.TaskIDs = make(map[string]struct{})
+			if prev := previous[filter.name]; prev != nil {
+				clusterData.Delta = &types.BulkTasksDelta{
+					IntervalMs: snapShotTime - prev.snapShotTime,
+					ByNode:     make(map[string]*types.BulkTaskCounts),
+					ByIndex:    make(map[string]*types.BulkTaskCounts),
+				}
+			}
 		}
+		byAction[filter.name] = clusterData
+	}
 
-		// Get tasks for this node
-		tasks, ok := nodeMap["tasks"].(map[string]interface{})
-		if !ok {
-			continue
+	hosts := newHostResolver(cluster)
+	err := decodeTaskNodes(r, hosts, func(node *taskNode) {
+		if node.Host == "" || len(node.Tasks) == 0 {
+			return
 		}
 
 		// Process tasks for this node
-		nodeTaskData := processNodeTasks(tasks, hostName, clusterName, cluster)
-		if nodeTaskData != nil {
-			clusterData.DataWriteBulk_sTasksByNode[hostName] = nodeTaskData
+		for _, filter := range filters {
+			nodeTaskData := processNodeTasks(node.Tasks, filter, node.Host, hosts)
+			if nodeTaskData != nil {
+				byAction[filter.name].DataWriteBulk_sTasksByNode[node.Host] = nodeTaskData
+			}
+			if delta {
+				trackNewTasks(node, filter, byAction[filter.name], previous[filter.name])
+			}
 		}
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Build cluster-level aggregations
-	buildClusterAggregations(clusterData)
-
-	return clusterData
-}
-
-// processNodeTasks processes all tasks for a single node
-func processNodeTasks(tasks map[string]interface{}, hostName, clusterName string, cluster *types.ClusterData) *types.NodeDataWriteBulk_sTasks {
-	nodeData := &types.NodeDataWriteBulk_sTasks{
-		DataWriteBulk_sByShard: make(map[string]*types.AggShardTaskDataWriteBulk_s),
+	for _, clusterData := range byAction {
+		buildClusterAggregations(clusterData)
 	}
 
-	// Get zone information if available
-	nodeData.Zone = getNodeZone(hostName, clusterName, cluster)
-
-	// Regex to match bulk write tasks
-	bulkActionRegex := regexp.MustCompile(`^indices:data/write/bulk\[s\]`)
+	return byAction, nil
+}
 
-	// Regex to parse description: "requests[236], index[index03][2]"
-	descRegex := regexp.MustCompile(`requests\[(\d+)\].*index\[([^\]]+)\]\[(\d+)\]`)
+// decodeTaskNodes walks {"nodes": {"<id>": {...}, ...}, ...} and calls fn for every node, its
+// host resolved to the host name its series are stored under. Other top-level fields (e.g.
+// node_failures) are skipped.
+func decodeTaskNodes(r io.Reader, hosts *hostResolver, fn func(node *taskNode)) error {
+	dec := json.NewDecoder(r)
 
-	// Process each task
-	for _, taskData := range tasks {
-		taskMap, ok := taskData.(map[string]interface{})
-		if !ok {
-			continue
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
 		}
-
-		// Check if this is a bulk write task
-		action, ok := taskMap["action"].(string)
-		if !ok || !bulkActionRegex.MatchString(action) {
+		if key != "nodes" {
+			if err := skipValue(dec); err != nil {
+				return err
+			}
 			continue
 		}
 
-		// Parse description
-		description, ok := taskMap["description"].(string)
-		if !ok {
-			continue
+		if err := expectDelim(dec, '{'); err != nil {
+			return err
 		}
+		for dec.More() {
+			// Node id
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+			var node taskNode
+			if err := dec.Decode(&node); err != nil {
+				return err
+			}
+			node.Host = hosts.resolve(node.Host)
+			fn(&node)
+		}
+		if err := expectDelim(dec, '}'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
 
-		matches := descRegex.FindStringSubmatch(description)
-		if len(matches) < 4 {
-			continue
+// expectDelim reads the next token and checks that it is the given delimiter
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("unexpected token %v, expected %v", tok, delim)
+	}
+	return nil
+}
+
+// skipValue consumes the next value (scalar, object or array) without keeping it
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
 		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
 
-		requests, _ := strconv.ParseUint(matches[1], 10, 32)
-		indexName := matches[2]
-		shardNum := matches[3]
-		indexShard := fmt.Sprintf("%s_%s", indexName, shardNum)
+// processNodeTasks processes the tasks of a single node matching a task action filter
+func processNodeTasks(tasks map[string]taskInfo, filter *taskActionFilter, hostName string, hosts *hostResolver) *types.NodeDataWriteBulk_sTasks {
+	nodeData := &types.NodeDataWriteBulk_sTasks{
+		DataWriteBulk_sByShard: make(map[string]*types.AggShardTaskDataWriteBulk_s),
+	}
 
-		// Get running time in nanoseconds
-		runningTimeNanos, ok := taskMap["running_time_in_nanos"].(float64)
-		if !ok {
+	// Get zone information if available
+	if node := hosts.node(hostName); node != nil {
+		nodeData.Zone = node.Zone
+	}
+
+	// Process each task
+	for _, task := range tasks {
+		requests, indexShards, counted := countedTask(task, filter)
+		if !counted {
 			continue
 		}
 
-		timeTakenMs := uint64(math.Round(runningTimeNanos / 1000000))
+		timeTakenMs := uint64(math.Round(*task.RunningTimeInNanos / 1000000))
 
 		// Update or create shard data
-		shardData, exists := nodeData.DataWriteBulk_sByShard[indexShard]
-		if !exists {
-			shardData = &types.AggShardTaskDataWriteBulk_s{
-				NumberOfTasks:     1,
-				TotalRequests:     uint(requests),
-				TotalTimeTaken_ms: timeTakenMs,
+		for _, indexShard := range indexShards {
+			shardData, exists := nodeData.DataWriteBulk_sByShard[indexShard]
+			if !exists {
+				shardData = &types.AggShardTaskDataWriteBulk_s{
+					NumberOfTasks:     1,
+					TotalRequests:     uint(requests),
+					TotalTimeTaken_ms: timeTakenMs,
+				}
+				nodeData.DataWriteBulk_sByShard[indexShard] = shardData
+			} else {
+				shardData.NumberOfTasks++
+				shardData.TotalRequests += uint(requests)
+				shardData.TotalTimeTaken_ms += timeTakenMs
 			}
-			nodeData.DataWriteBulk_sByShard[indexShard] = shardData
-		} else {
-			shardData.NumberOfTasks++
-			shardData.TotalRequests += uint(requests)
-			shardData.TotalTimeTaken_ms += timeTakenMs
 		}
 	}
 
@@ -326,18 +433,115 @@ func processNodeTasks(tasks map[string]interface{}, hostName, clusterName string
 	return nodeData
 }
 
-// getNodeZone retrieves the zone for a node
-func getNodeZone(hostName, clusterName string, cluster *types.ClusterData) string {
-	if cluster == nil || cluster.Nodes == nil {
-		return ""
+// countedTask reports whether a task is one the filter tracks and that can be counted: its
+// description names an index and its running time is known. It returns the requests and the
+// index_shard keys of the task.
+func countedTask(task taskInfo, filter *taskActionFilter) (uint64, []string, bool) {
+	// Check if the task is one the filter tracks
+	if !filter.matches(task.Action) {
+		return 0, nil, false
+	}
+
+	// Parse description
+	requests, indexShards := parseTaskDescription(task.Description)
+	if len(indexShards) == 0 || task.RunningTimeInNanos == nil {
+		return 0, nil, false
 	}
+	return requests, indexShards, true
+}
+
+// previousTasks is what the tasks of a snapshot are correlated with
+type previousTasks struct {
+	snapShotTime int64
+	taskIDs      map[string]struct{}
+}
+
+// previousTasksSnapshots returns, per task action filter, the task IDs of the snapshot a run
+// is correlated with: the latest one, or the one before it when the latest was taken by the
+// same run (a retry replaces it). Filters without one are left out, as every task would be new.
+func previousTasksSnapshots(clusterName string, filters []*taskActionFilter, runID string) map[string]*previousTasks {
+	types.ClusterDataWriteBulkTasksHistoryMu.Lock()
+	defer types.ClusterDataWriteBulkTasksHistoryMu.Unlock()
 
-	for _, node := range cluster.Nodes {
-		if node.HostName == hostName {
-			return node.Zone
+	previous := make(map[string]*previousTasks, len(filters))
+	for _, filter := range filters {
+		history := tasksHistory(clusterName, filter.name)
+		if history == nil {
+			continue
+		}
+		snapshot := history.PtrClusterDataWriteBulk_sTasks.At(0)
+		if snapshot != nil && runID != "" && snapshot.RunID == runID {
+			snapshot = history.PtrClusterDataWriteBulk_sTasks.At(1)
+		}
+		if snapshot != nil && snapshot.TaskIDs != nil {
+			previous[filter.name] = &previousTasks{snapShotTime: snapshot.SnapShotTime, taskIDs: snapshot.TaskIDs}
 		}
 	}
-	return ""
+	return previous
+}
+
+// trackNewTasks records the IDs of the tasks of a node counted for a filter and counts those
+// the previous snapshot did not hold in the Delta of the snapshot, per node and per index
+func trackNewTasks(node *taskNode, filter *taskActionFilter, clusterData *types.ClusterDataWriteBulk_sTasks, previous *previousTasks) {
+	for id, task := range node.Tasks {
+		requests, indexShards, counted := countedTask(task, filter)
+		if !counted {
+			continue
+		}
+		clusterData.TaskIDs[id] = struct{}{}
+		if clusterData.Delta == nil {
+			continue
+		}
+		if _, seen := previous.taskIDs[id]; seen {
+			continue
+		}
+
+		addNewTask(&clusterData.Delta.BulkTaskCounts, requests)
+		addNewTask(bulkTaskCountsOf(clusterData.Delta.ByNode, node.Host), requests)
+		// Keys of one task name distinct indices
+		for _, indexShard := range indexShards {
+			addNewTask(bulkTaskCountsOf(clusterData.Delta.ByIndex, extractIndexName(indexShard)), requests)
+		}
+	}
+}
+
+// bulkTaskCountsOf returns the counts of a key, creating them
+func bulkTaskCountsOf(counts map[string]*types.BulkTaskCounts, key string) *types.BulkTaskCounts {
+	c, ok := counts[key]
+	if !ok {
+		c = &types.BulkTaskCounts{}
+		counts[key] = c
+	}
+	return c
+}
+
+// addNewTask counts a newly seen task and its requests
+func addNewTask(counts *types.BulkTaskCounts, requests uint64) {
+	counts.NewTasks++
+	counts.NewRequests += uint(requests)
+}
+
+// parseTaskDescription returns the requests and the index_shard keys of a task description.
+// Shard tasks like bulk writes ("requests[236], index[index03][2]") have one key; tasks on
+// whole indices like delete-by-query ("delete-by-query [logs-1, logs-2]") have one key
+// "<index>_*" per index and no requests.
+func parseTaskDescription(description string) (uint64, []string) {
+	if matches := descRegex.FindStringSubmatch(description); len(matches) == 4 {
+		requests, _ := strconv.ParseUint(matches[1], 10, 32)
+		return requests, []string{fmt.Sprintf("%s_%s", matches[2], matches[3])}
+	}
+
+	matches := indexListRegex.FindStringSubmatch(description)
+	if len(matches) < 2 {
+		return 0, nil
+	}
+	indexShards := make([]string, 0)
+	for _, indexName := range strings.Split(matches[1], ",") {
+		if indexName = strings.TrimSpace(indexName); indexName != "" {
+			indexShards = append(indexShards, indexName+"_*")
+		}
+	}
+	return 0, indexShards
 }
 
 // calculateNodeTotals calculates the total values for a node
@@ -349,7 +553,7 @@ func calculateNodeTotals(nodeData *types.NodeDataWriteBulk_sTasks) {
 	}
 }
 
-// sortNodeShards sorts the shards by different criteria
+// sortNodeShards sorts the shards by different criteria, ties by name so the order is stable
 func sortNodeShards(nodeData *types.NodeDataWriteBulk_sTasks) {
 	shards := make([]string, 0, len(nodeData.DataWriteBulk_sByShard))
 	for shard := range nodeData.DataWriteBulk_sByShard {
@@ -360,8 +564,10 @@ func sortNodeShards(nodeData *types.NodeDataWriteBulk_sTasks) {
 	sortedOnTasks := make([]string, len(shards))
 	copy(sortedOnTasks, shards)
 	sort.Slice(sortedOnTasks, func(i, j int) bool {
-		return nodeData.DataWriteBulk_sByShard[sortedOnTasks[i]].NumberOfTasks >
-			nodeData.DataWriteBulk_sByShard[sortedOnTasks[j]].NumberOfTasks
+		if a, b := nodeData.DataWriteBulk_sByShard[sortedOnTasks[i]].NumberOfTasks, nodeData.DataWriteBulk_sByShard[sortedOnTasks[j]].NumberOfTasks; a != b {
+			return a > b
+		}
+		return sortedOnTasks[i] < sortedOnTasks[j]
 	})
 	nodeData.SortedShardsOnTasks = sortedOnTasks
 
@@ -369,8 +575,10 @@ func sortNodeShards(nodeData *types.NodeDataWriteBulk_sTasks) {
 	sortedOnTime := make([]string, len(shards))
 	copy(sortedOnTime, shards)
 	sort.Slice(sortedOnTime, func(i, j int) bool {
-		return nodeData.DataWriteBulk_sByShard[sortedOnTime[i]].TotalTimeTaken_ms >
-			nodeData.DataWriteBulk_sByShard[sortedOnTime[j]].TotalTimeTaken_ms
+		if a, b := nodeData.DataWriteBulk_sByShard[sortedOnTime[i]].TotalTimeTaken_ms, nodeData.DataWriteBulk_sByShard[sortedOnTime[j]].TotalTimeTaken_ms; a != b {
+			return a > b
+		}
+		return sortedOnTime[i] < sortedOnTime[j]
 	})
 	nodeData.SortedShardsOnTimetaken = sortedOnTime
 
@@ -378,13 +586,15 @@ func sortNodeShards(nodeData *types.NodeDataWriteBulk_sTasks) {
 	sortedOnRequests := make([]string, len(shards))
 	copy(sortedOnRequests, shards)
 	sort.Slice(sortedOnRequests, func(i, j int) bool {
-		return nodeData.DataWriteBulk_sByShard[sortedOnRequests[i]].TotalRequests >
-			nodeData.DataWriteBulk_sByShard[sortedOnRequests[j]].TotalRequests
+		if a, b := nodeData.DataWriteBulk_sByShard[sortedOnRequests[i]].TotalRequests, nodeData.DataWriteBulk_sByShard[sortedOnRequests[j]].TotalRequests; a != b {
+			return a > b
+		}
+		return sortedOnRequests[i] < sortedOnRequests[j]
 	})
 	nodeData.SortedShardsOnRequest = sortedOnRequests
 }
 
-// buildClusterAggregations builds cluster-level aggregations
+// buildClusterAggregations builds cluster-level aggregations; ties are sorted by name
 func buildClusterAggregations(clusterData *types.ClusterDataWriteBulk_sTasks) {
 	// Sort hosts
 	hosts := make([]string, 0, len(clusterData.DataWriteBulk_sTasksByNode))
@@ -396,8 +606,10 @@ func buildClusterAggregations(clusterData *types.ClusterDataWriteBulk_sTasks) {
 	sortedHosts := make([]string, len(hosts))
 	copy(sortedHosts, hosts)
 	sort.Slice(sortedHosts, func(i, j int) bool {
-		return clusterData.DataWriteBulk_sTasksByNode[sortedHosts[i]].TotalWiteBulk_sTasks >
-			clusterData.DataWriteBulk_sTasksByNode[sortedHosts[j]].TotalWiteBulk_sTasks
+		if a, b := clusterData.DataWriteBulk_sTasksByNode[sortedHosts[i]].TotalWiteBulk_sTasks, clusterData.DataWriteBulk_sTasksByNode[sortedHosts[j]].TotalWiteBulk_sTasks; a != b {
+			return a > b
+		}
+		return sortedHosts[i] < sortedHosts[j]
 	})
 	clusterData.SortedHostsOnTasks = sortedHosts
 
@@ -405,8 +617,10 @@ func buildClusterAggregations(clusterData *types.ClusterDataWriteBulk_sTasks) {
 	sortedHostsTime := make([]string, len(hosts))
 	copy(sortedHostsTime, hosts)
 	sort.Slice(sortedHostsTime, func(i, j int) bool {
-		return clusterData.DataWriteBulk_sTasksByNode[sortedHostsTime[i]].TotalWrietBulk_sTimeTaken_ms >
-			clusterData.DataWriteBulk_sTasksByNode[sortedHostsTime[j]].TotalWrietBulk_sTimeTaken_ms
+		if a, b := clusterData.DataWriteBulk_sTasksByNode[sortedHostsTime[i]].TotalWrietBulk_sTimeTaken_ms, clusterData.DataWriteBulk_sTasksByNode[sortedHostsTime[j]].TotalWrietBulk_sTimeTaken_ms; a != b {
+			return a > b
+		}
+		return sortedHostsTime[i] < sortedHostsTime[j]
 	})
 	clusterData.SortedHostsOnTimetaken = sortedHostsTime
 
@@ -414,8 +628,10 @@ func buildClusterAggregations(clusterData *types.ClusterDataWriteBulk_sTasks) {
 	sortedHostsReq := make([]string, len(hosts))
 	copy(sortedHostsReq, hosts)
 	sort.Slice(sortedHostsReq, func(i, j int) bool {
-		return clusterData.DataWriteBulk_sTasksByNode[sortedHostsReq[i]].TotalWriteBulk_sRequests >
-			clusterData.DataWriteBulk_sTasksByNode[sortedHostsReq[j]].TotalWriteBulk_sRequests
+		if a, b := clusterData.DataWriteBulk_sTasksByNode[sortedHostsReq[i]].TotalWriteBulk_sRequests, clusterData.DataWriteBulk_sTasksByNode[sortedHostsReq[j]].TotalWriteBulk_sRequests; a != b {
+			return a > b
+		}
+		return sortedHostsReq[i] < sortedHostsReq[j]
 	})
 	clusterData.SortedHostsOnRequest = sortedHostsReq
 
@@ -452,8 +668,10 @@ func buildClusterAggregations(clusterData *types.ClusterDataWriteBulk_sTasks) {
 	sortedIndices := make([]string, len(indices))
 	copy(sortedIndices, indices)
 	sort.Slice(sortedIndices, func(i, j int) bool {
-		return clusterData.DataWriteBulk_sTasksByIndex[sortedIndices[i]].NumberOfTasks >
-			clusterData.DataWriteBulk_sTasksByIndex[sortedIndices[j]].NumberOfTasks
+		if a, b := clusterData.DataWriteBulk_sTasksByIndex[sortedIndices[i]].NumberOfTasks, clusterData.DataWriteBulk_sTasksByIndex[sortedIndices[j]].NumberOfTasks; a != b {
+			return a > b
+		}
+		return sortedIndices[i] < sortedIndices[j]
 	})
 	clusterData.IndicesSortedonTasks = sortedIndices
 
@@ -461,8 +679,10 @@ func buildClusterAggregations(clusterData *types.ClusterDataWriteBulk_sTasks) {
 	sortedIndicesReq := make([]string, len(indices))
 	copy(sortedIndicesReq, indices)
 	sort.Slice(sortedIndicesReq, func(i, j int) bool {
-		return clusterData.DataWriteBulk_sTasksByIndex[sortedIndicesReq[i]].TotalRequests >
-			clusterData.DataWriteBulk_sTasksByIndex[sortedIndicesReq[j]].TotalRequests
+		if a, b := clusterData.DataWriteBulk_sTasksByIndex[sortedIndicesReq[i]].TotalRequests, clusterData.DataWriteBulk_sTasksByIndex[sortedIndicesReq[j]].TotalRequests; a != b {
+			return a > b
+		}
+		return sortedIndicesReq[i] < sortedIndicesReq[j]
 	})
 	clusterData.IndicesSortedOnRequests = sortedIndicesReq
 
@@ -470,44 +690,71 @@ func buildClusterAggregations(clusterData *types.ClusterDataWriteBulk_sTasks) {
 	sortedIndicesTime := make([]string, len(indices))
 	copy(sortedIndicesTime, indices)
 	sort.Slice(sortedIndicesTime, func(i, j int) bool {
-		return clusterData.DataWriteBulk_sTasksByIndex[sortedIndicesTime[i]].TotalTimeTaken_ms >
-			clusterData.DataWriteBulk_sTasksByIndex[sortedIndicesTime[j]].TotalTimeTaken_ms
+		if a, b := clusterData.DataWriteBulk_sTasksByIndex[sortedIndicesTime[i]].TotalTimeTaken_ms, clusterData.DataWriteBulk_sTasksByIndex[sortedIndicesTime[j]].TotalTimeTaken_ms; a != b {
+			return a > b
+		}
+		return sortedIndicesTime[i] < sortedIndicesTime[j]
 	})
 	clusterData.IndicesSortedOnTimetaken = sortedIndicesTime
 }
 
 // extractIndexName extracts the index name from index_shard format (removes trailing _<digits>)
 func extractIndexName(indexShard string) string {
-	// Find last underscore followed by digits
-	re := regexp.MustCompile(`_\d+$`)
-	return re.ReplaceAllString(indexShard, "")
+	return shardSuffixRegex.ReplaceAllString(indexShard, "")
 }
 
-// updateClusterTasksHistory updates the global history for a cluster (thread-safe)
-func updateClusterTasksHistory(clusterName string, clusterData *types.ClusterDataWriteBulk_sTasks, historySize uint) {
+// updateClusterTasksHistory updates the global history of a task action filter for a cluster
+// (thread-safe). The bulk filter keeps the bulk task history, the others their own histories.
+// Duplicate and late snapshots are dropped, and a retried run replaces its own snapshot.
+func updateClusterTasksHistory(clusterName, action string, clusterData *types.ClusterDataWriteBulk_sTasks, historySize uint) types.SnapshotInsert {
 	types.ClusterDataWriteBulkTasksHistoryMu.Lock()
 	defer types.ClusterDataWriteBulkTasksHistoryMu.Unlock()
 
-	history, exists := types.AllClusterDataWriteBulk_sTasksHistory[clusterName]
-
-	if !exists {
+	history := tasksHistory(clusterName, action)
+	if history == nil {
 		// Create new history
 		history = &types.ClusterDataWriteBulk_sTasksHistory{
 			LatestSnapShotTime:             clusterData.SnapShotTime,
 			HistorySize:                    historySize,
 			ClusterName:                    clusterName,
-			PtrClusterDataWriteBulk_sTasks: make([]*types.ClusterDataWriteBulk_sTasks, historySize+1),
+			Action:                         action,
+			PtrClusterDataWriteBulk_sTasks: types.NewRing[*types.ClusterDataWriteBulk_sTasks](int(historySize) + 1),
+		}
+		if action == types.BulkTaskAction {
+			types.AllClusterDataWriteBulk_sTasksHistory[clusterName] = history
+		} else {
+			if types.AllTaskActionHistory[clusterName] == nil {
+				types.AllTaskActionHistory[clusterName] = make(map[string]*types.ClusterDataWriteBulk_sTasksHistory)
+			}
+			types.AllTaskActionHistory[clusterName][action] = history
 		}
-		types.AllClusterDataWriteBulk_sTasksHistory[clusterName] = history
 	}
 
-	// Roll data: shift everything down by one position
+	// Insert new data at position 0, dropping the oldest snapshot
 	// (Already protected by ClusterDataWriteBulkTasksHistoryMu)
-	for i := int(history.HistorySize); i > 0; i-- {
-		history.PtrClusterDataWriteBulk_sTasks[i] = history.PtrClusterDataWriteBulk_sTasks[i-1]
+	outcome := history.AddSnapshot(clusterData)
+	if outcome.Dropped() {
+		return outcome
+	}
+	// Task IDs are only needed to correlate the next snapshot, or the next attempt of a run
+	if older := history.PtrClusterDataWriteBulk_sTasks.At(2); older != nil {
+		older.TaskIDs = nil
 	}
-
-	// Insert new data at position 0
-	history.PtrClusterDataWriteBulk_sTasks[0] = clusterData
 	history.LatestSnapShotTime = clusterData.SnapShotTime
+
+	if action == types.BulkTaskAction {
+		types.PublishBulkTasksHistory(clusterName, history)
+	} else {
+		types.PublishTaskActionHistory(clusterName, action, history)
+	}
+	return outcome
+}
+
+// tasksHistory returns the history of a task action filter for a cluster, or nil (callers
+// hold ClusterDataWriteBulkTasksHistoryMu)
+func tasksHistory(clusterName, action string) *types.ClusterDataWriteBulk_sTasksHistory {
+	if action == types.BulkTaskAction {
+		return types.AllClusterDataWriteBulk_sTasksHistory[clusterName]
+	}
+	return types.AllTaskActionHistory[clusterName][action]
 }
@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ElasticObservability/pkg/testsupport"
+	"ElasticObservability/pkg/types"
+)
+
+// TestParseTasksGolden streams a _tasks response with node failures, a node reported by its IP
+// address, a node without tasks and tasks of other actions into the default bulk snapshot
+func TestParseTasksGolden(t *testing.T) {
+	filters, err := parseTaskActionFilters(defaultTaskActions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byAction, err := parseTasksResponse(openTestdata(t, "tasks.json"), goldenCluster.ClusterName, goldenCluster, filters, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := byAction[types.BulkTaskAction]
+	snapshot.SnapShotTime = 0 // time of the parse
+	checkGolden(t, "tasks", snapshot)
+}
+
+func TestParseTasksInvalid(t *testing.T) {
+	filters, err := parseTaskActionFilters(defaultTaskActions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{``, `[]`, `{"nodes": []}`, `{"nodes": {"n1": {"host": 1}}}`, `{"nodes": {}`} {
+		if _, err := parseTasksResponse(strings.NewReader(body), "invalid", nil, filters, false, nil); err == nil {
+			t.Errorf("parseTasksResponse(%q) = nil error", body)
+		}
+	}
+}
+
+// TestGetTDataWriteBulk_sTasksFakeES runs the job against a fake Elasticsearch serving the
+// recorded _tasks response and checks the snapshot it stores
+func TestGetTDataWriteBulk_sTasksFakeES(t *testing.T) {
+	es := testsupport.NewFakeES()
+	defer es.Close()
+	const clusterName = "fake-bulk-tasks"
+	es.RegisterCluster(clusterName, "uuid-bulk-tasks")
+	t.Cleanup(func() { removeTestCluster(clusterName) })
+
+	params := map[string]interface{}{"includeClusters": []interface{}{clusterName}}
+	if err := GetTDataWriteBulk_sTasks(context.Background(), params); err != nil {
+		t.Fatalf("GetTDataWriteBulk_sTasks: %v", err)
+	}
+	if status := collectionStatusOf("getTDataWriteBulk_sTasks", clusterName); status == nil || status.LastError != "" {
+		t.Fatalf("collection status = %+v, want a success", status)
+	}
+
+	history, ok := types.GetTaskActionHistory(clusterName, types.BulkTaskAction)
+	if !ok {
+		t.Fatal("no bulk task history stored")
+	}
+	snapshot := history.PtrClusterDataWriteBulk_sTasks.At(0)
+	if snapshot == nil {
+		t.Fatal("no snapshot in slot 0")
+	}
+
+	// bulk[s] and bulk[s][p] tasks count, the delete by query and the tasks listing do not
+	nodes := map[string]types.NodeDataWriteBulk_sTasks{}
+	for host, node := range snapshot.DataWriteBulk_sTasksByNode {
+		nodes[host] = types.NodeDataWriteBulk_sTasks{
+			TotalWiteBulk_sTasks:     node.TotalWiteBulk_sTasks,
+			TotalWriteBulk_sRequests: node.TotalWriteBulk_sRequests,
+		}
+	}
+	wantNodes := map[string]types.NodeDataWriteBulk_sTasks{
+		"es-data-01": {TotalWiteBulk_sTasks: 3, TotalWriteBulk_sRequests: 866},
+		"es-data-02": {TotalWiteBulk_sTasks: 1, TotalWriteBulk_sRequests: 90},
+	}
+	if !reflect.DeepEqual(nodes, wantNodes) {
+		t.Errorf("tasks by node = %+v, want %+v", nodes, wantNodes)
+	}
+	if got := snapshot.DataWriteBulk_sTasksByIndex["logs-app-000003"]; got == nil || got.NumberOfTasks != 3 || got.TotalRequests != 444 {
+		t.Errorf("logs-app-000003 = %+v, want 3 tasks of 444 requests", got)
+	}
+	if want := []string{"es-data-01", "es-data-02"}; !reflect.DeepEqual(snapshot.SortedHostsOnTasks, want) {
+		t.Errorf("SortedHostsOnTasks = %v, want %v", snapshot.SortedHostsOnTasks, want)
+	}
+
+	// The UUID check reads GET / before the collection, both with the cluster's API key
+	var paths []string
+	for _, req := range es.Requests() {
+		paths = append(paths, req.Method+" "+req.Path)
+		if got := req.Header.Get("Authorization"); got != "ApiKey fake-es-api-key" {
+			t.Errorf("%s %s: Authorization = %q", req.Method, req.Path, got)
+		}
+	}
+	if want := []string{"GET /", "GET /_tasks"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("requests = %v, want %v", paths, want)
+	}
+}
+
+// TestGetTDataWriteBulk_sTasksUUIDMismatch checks that a cluster whose endpoint answers for
+// another cluster fails its collection instead of storing the other cluster's tasks
+func TestGetTDataWriteBulk_sTasksUUIDMismatch(t *testing.T) {
+	es := testsupport.NewFakeES()
+	defer es.Close()
+	const clusterName = "fake-bulk-tasks-moved"
+	es.RegisterCluster(clusterName, "uuid-expected")
+	es.Handle(http.MethodGet, "/", http.StatusOK, testsupport.Root("another-cluster", "uuid-another"))
+	t.Cleanup(func() { removeTestCluster(clusterName) })
+
+	params := map[string]interface{}{"includeClusters": []interface{}{clusterName}}
+	if err := GetTDataWriteBulk_sTasks(context.Background(), params); err != nil {
+		t.Fatalf("GetTDataWriteBulk_sTasks: %v", err)
+	}
+	if status := collectionStatusOf("getTDataWriteBulk_sTasks", clusterName); status == nil || status.ConsecutiveFailures != 1 {
+		t.Errorf("collection status = %+v, want one failure", status)
+	}
+	if _, ok := types.GetTaskActionHistory(clusterName, types.BulkTaskAction); ok {
+		t.Error("bulk task history stored for a cluster with another UUID")
+	}
+	for _, req := range es.Requests() {
+		if req.Path == "/_tasks" {
+			t.Error("_tasks requested from an endpoint of another cluster")
+		}
+	}
+}
+
+// removeTestCluster drops a cluster registered by a test and the data collected for it
+func removeTestCluster(clusterName string) {
+	types.MutateClusters(func(clusters map[string]*types.ClusterData) {
+		delete(clusters, clusterName)
+	})
+	types.RemoveClusterData(clusterName)
+
+	// Responses cached under the name would answer the next fake cluster of that name
+	esQueryCache.mu.Lock()
+	for key, entry := range esQueryCache.entries {
+		if strings.HasPrefix(key, clusterName+"\x00") {
+			esQueryCache.bytes -= len(entry.data)
+			delete(esQueryCache.entries, key)
+		}
+	}
+	esQueryCache.mu.Unlock()
+}
+
+// collectionStatusOf returns the collection status of a job and cluster, or nil
+func collectionStatusOf(jobName, clusterName string) *types.CollectionStatus {
+	for _, status := range types.SnapshotCollectionStatus() {
+		if status.JobName == jobName && status.ClusterName == clusterName {
+			return &status
+		}
+	}
+	return nil
+}
+
+// BenchmarkParseTasksResponse measures the streaming decode of a _tasks response of 40 nodes
+// running 250 tasks each (about 3.4 MB) into the snapshot of the default bulk action
+func BenchmarkParseTasksResponse(b *testing.B) {
+	data := tasksFixture(40, 250)
+	filters, err := parseTaskActionFilters(defaultTaskActions)
+	if err != nil {
+		b.Fatal(err)
+	}
+	cluster := &types.ClusterData{ClusterName: "bench"}
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseTasksResponse(bytes.NewReader(data), "bench", cluster, filters, false, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// tasksFixture builds a _tasks?detailed=true response of a busy ingest cluster: nodes data
+// nodes running tasksPerNode tasks each, a third of them bulk shard tasks (primary and
+// replica), the rest searches, bulk coordination and a few background tasks
+func tasksFixture(nodes, tasksPerNode int) []byte {
+	nodeEntries := make(map[string]interface{}, nodes)
+	for n := 0; n < nodes; n++ {
+		nodeID := fmt.Sprintf("node%02dXk4IQMOUUVeiohTt8A", n)
+		tasks := make(map[string]interface{}, tasksPerNode)
+		for t := 0; t < tasksPerNode; t++ {
+			taskID := fmt.Sprintf("%s:%d", nodeID, 100000+t)
+			index := fmt.Sprintf("logs-app%d-2024.01.%02d-%06d", t%7, 1+t%28, t%3)
+			task := map[string]interface{}{
+				"node":                  nodeID,
+				"id":                    100000 + t,
+				"type":                  "transport",
+				"start_time_in_millis":  1704567890000 + int64(t),
+				"running_time_in_nanos": 1000000 + t*3217,
+				"cancellable":           false,
+				"headers":               map[string]interface{}{"X-Opaque-Id": fmt.Sprintf("beat-%d", t%11)},
+			}
+			switch t % 6 {
+			case 0:
+				task["action"] = "indices:data/write/bulk[s]"
+				task["description"] = fmt.Sprintf("requests[%d], index[%s][%d]", 50+t%400, index, t%5)
+			case 1:
+				task["action"] = "indices:data/write/bulk[s][p]"
+				task["description"] = fmt.Sprintf("requests[%d], index[%s][%d]", 50+t%400, index, t%5)
+				task["parent_task_id"] = fmt.Sprintf("%s:%d", nodeID, 100000+t-1)
+			case 2:
+				task["action"] = "indices:data/write/bulk"
+				task["description"] = fmt.Sprintf("requests[%d], indices[%s]", 200+t%800, index)
+			case 3, 4:
+				task["action"] = "indices:data/read/search[phase/query]"
+				task["description"] = fmt.Sprintf("shardId[[%s][%d]]", index, t%5)
+				task["cancellable"] = true
+				task["cancelled"] = false
+			default:
+				task["action"] = "cluster:monitor/nodes/stats[n]"
+				task["description"] = ""
+			}
+			tasks[taskID] = task
+		}
+		nodeEntries[nodeID] = map[string]interface{}{
+			"name":              fmt.Sprintf("es-data-%02d", n),
+			"transport_address": fmt.Sprintf("10.0.4.%d:9300", 10+n),
+			"host":              fmt.Sprintf("es-data-%02d", n),
+			"ip":                fmt.Sprintf("10.0.4.%d:9300", 10+n),
+			"roles":             []string{"data_hot", "ingest"},
+			"attributes":        map[string]interface{}{"zone": fmt.Sprintf("zone-%d", n%3), "xpack.installed": "true"},
+			"tasks":             tasks,
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{"node_failures": []interface{}{}, "nodes": nodeEntries})
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
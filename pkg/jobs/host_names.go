@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/types"
+)
+
+// hostResolver maps the names the nodes of a cluster are reported under to one host name per
+// node, so the series of a node join across sources: _tasks and the node stats report the
+// publish host (often an IP address), the monitoring data source_node.host (often an FQDN).
+// The aliases of an inventory node are its host name, normalized or as loaded, and its IP
+// address; they all resolve to its normalized host name. Other names are only normalized by
+// the hostNames rules of config.yaml.
+type hostResolver struct {
+	aliases map[string]string      // alias -> host name
+	nodes   map[string]*types.Node // host name -> inventory node
+}
+
+// newHostResolver builds the aliases of the inventory nodes of a cluster. An alias shared by
+// nodes (e.g. the IP address of a host running several nodes) resolves to none of them.
+func newHostResolver(cluster *types.ClusterData) *hostResolver {
+	r := &hostResolver{
+		aliases: make(map[string]string),
+		nodes:   make(map[string]*types.Node),
+	}
+	if cluster == nil {
+		return r
+	}
+
+	ambiguous := make(map[string]bool)
+	for _, node := range cluster.Nodes {
+		if node.HostName == "" {
+			continue
+		}
+		hostName := config.NormalizeHostName(node.HostName)
+		if _, exists := r.nodes[hostName]; !exists {
+			r.nodes[hostName] = node
+		}
+		for _, alias := range []string{hostName, node.HostName, node.IPAddress} {
+			if alias == "" || ambiguous[alias] {
+				continue
+			}
+			if existing, exists := r.aliases[alias]; exists && existing != hostName {
+				delete(r.aliases, alias)
+				ambiguous[alias] = true
+				continue
+			}
+			r.aliases[alias] = hostName
+		}
+	}
+	return r
+}
+
+// resolve returns the host name a reported name is stored under
+func (r *hostResolver) resolve(host string) string {
+	if hostName, ok := r.aliases[host]; ok {
+		return hostName
+	}
+	normalized := config.NormalizeHostName(host)
+	if hostName, ok := r.aliases[normalized]; ok {
+		return hostName
+	}
+	return normalized
+}
+
+// nodeHostName returns the host name a node of a stats response is stored under, from its
+// host, else its node name
+func (r *hostResolver) nodeHostName(host, name string) string {
+	if host == "" {
+		host = name
+	}
+	return r.resolve(host)
+}
+
+// node returns the inventory node of a host name or one of its aliases, or nil
+func (r *hostResolver) node(host string) *types.Node {
+	return r.nodes[r.resolve(host)]
+}
+
+// ResolveHostName returns the host name the series of a host of a cluster are stored under,
+// for a host name given as any of its aliases (e.g. by the API)
+func ResolveHostName(clusterName, host string) string {
+	if host == "" {
+		return host
+	}
+	cluster, _ := types.GetCluster(clusterName)
+	return newHostResolver(cluster).resolve(host)
+}
@@ -0,0 +1,320 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/notify"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// mappingFieldsSource is the event store source of field limit and field growth events
+const mappingFieldsSource = "mappingFields"
+
+// defaultTotalFieldsLimit is index.mapping.total_fields.limit when a cluster does not report it
+const defaultTotalFieldsLimit = 1000
+
+// GetIndexFieldCounts counts the mapped fields of every matching index from its mapping, the
+// way Elasticsearch counts them against index.mapping.total_fields.limit: every field and
+// object, multi-field, field alias and runtime field. Each index keeps the latest historySize
+// samples.
+//
+// An index at or above limitPercent of its limit fires a FieldLimitApproaching event (critical
+// once the limit is reached, as documents with new fields are then rejected); an index whose
+// field count grew by minGrowth or more within growthWindow fires a RapidFieldGrowth event.
+// Both kinds of event carry the number of hosts of the cluster under write pressure at the
+// time, as a mapping explosion is a frequent cause of write pressure.
+func GetIndexFieldCounts(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("getIndexFieldCounts", "Starting index field count collection")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("getIndexFieldCounts", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	indices := p.String("indices", "*")
+	historySize := p.IntInRange("historySize", 24, 2, 2016)
+	limitPercent := p.IntInRange("limitPercent", 90, 1, 100)
+	growthWindow := p.Duration("growthWindow", time.Hour)
+	minGrowth := p.IntInRange("minGrowth", 100, 1, 1000000)
+	severity := p.OneOf("severity", "warning", "info", "warning", "critical")
+	cacheTTL := p.Duration("cacheTTL", 0)
+	notifyOwners := p.Bool("notifyOwners", false)
+	if err := checkParams("getIndexFieldCounts", p); err != nil {
+		return err
+	}
+	if growthWindow <= 0 {
+		return fmt.Errorf("growthWindow must be positive, got %s", growthWindow)
+	}
+
+	now := time.Now()
+	var mu sync.Mutex
+	collected := make(map[string]bool)
+	sampledIndices := 0
+
+	_, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		counts, err := indexFieldCounts(ctx, cluster, indices, cacheTTL)
+		if err != nil {
+			return err
+		}
+		types.AddFieldCounts(clusterName, utils.TimeNowMillis(), counts, historySize)
+		fieldCounts, _ := types.GetFieldCounts(clusterName)
+		recordFieldCountMetrics(clusterName, fieldCounts, limitPercent)
+
+		mu.Lock()
+		collected[clusterName] = true
+		sampledIndices += len(counts)
+		mu.Unlock()
+
+		logger.JobInfo("getIndexFieldCounts", "Cluster %s: %d indices sampled", clusterName, len(counts))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Events of clusters that could not be sampled this time are kept as they are
+	observed := make([]events.Observation, 0)
+	for clusterName := range collected {
+		fieldCounts, ok := types.GetFieldCounts(clusterName)
+		if !ok {
+			continue
+		}
+		writePressureHosts := strconv.Itoa(len(events.Query(events.Filter{
+			Source: writePressureSource, State: events.StateFiring, Cluster: clusterName})))
+
+		for index, count := range fieldCounts.Indices {
+			labels := map[string]string{"cluster": clusterName, "index": index}
+			if usedPercent := fieldsUsedPercent(count); usedPercent >= float64(limitPercent) {
+				eventSeverity := severity
+				if count.Fields >= count.Limit {
+					eventSeverity = "critical"
+				}
+				observed = append(observed, events.Observation{
+					Name:     "FieldLimitApproaching",
+					Severity: eventSeverity,
+					Labels:   labels,
+					Annotations: map[string]string{
+						"summary":            fmt.Sprintf("Mapping uses %.0f%% of index.mapping.total_fields.limit", usedPercent),
+						"fields":             strconv.Itoa(count.Fields),
+						"limit":              strconv.Itoa(count.Limit),
+						"dynamic":            count.Dynamic,
+						"writePressureHosts": writePressureHosts,
+					},
+				})
+			}
+
+			growth, since := fieldGrowth(count.Points, growthWindow)
+			if growth >= minGrowth {
+				observed = append(observed, events.Observation{
+					Name:     "RapidFieldGrowth",
+					Severity: severity,
+					Labels:   labels,
+					Annotations: map[string]string{
+						"summary":            fmt.Sprintf("Mapping grew by %d fields within %s", growth, growthWindow),
+						"growth":             strconv.Itoa(growth),
+						"fields":             strconv.Itoa(count.Fields),
+						"limit":              strconv.Itoa(count.Limit),
+						"dynamic":            count.Dynamic,
+						"writePressureHosts": writePressureHosts,
+					},
+					StartsAt: since,
+				})
+			}
+		}
+	}
+	offending := len(observed)
+	observed = append(observed, carriedOverEvents(mappingFieldsSource, collected)...)
+
+	fired, resolved, err := events.Sync(mappingFieldsSource, observed, now)
+	if err != nil {
+		logger.JobWarn("getIndexFieldCounts", "Failed to persist events: %v", err)
+	}
+
+	firedByCluster := make(map[string][]events.Event)
+	for _, event := range fired {
+		logger.JobWarn("getIndexFieldCounts", "%s: cluster=%s index=%s fields=%s limit=%s (event %s)",
+			event.Name, event.Cluster(), event.Labels["index"], event.Annotations["fields"],
+			event.Annotations["limit"], event.ID)
+		firedByCluster[event.Cluster()] = append(firedByCluster[event.Cluster()], event)
+	}
+	for _, event := range resolved {
+		logger.JobInfo("getIndexFieldCounts", "%s resolved: cluster=%s index=%s (event %s)",
+			event.Name, event.Cluster(), event.Labels["index"], event.ID)
+	}
+
+	if notifyOwners {
+		clusterNames := make([]string, 0, len(firedByCluster))
+		for clusterName := range firedByCluster {
+			clusterNames = append(clusterNames, clusterName)
+		}
+		sort.Strings(clusterNames)
+		for _, clusterName := range clusterNames {
+			notifyFieldCounts(ctx, clusterName, firedByCluster[clusterName], severity)
+		}
+	}
+
+	logger.JobInfo("getIndexFieldCounts", "Completed: %d clusters, %d indices sampled, %d field count events (%d new, %d resolved)",
+		len(collected), sampledIndices, offending, len(fired), len(resolved))
+	return nil
+}
+
+// indexFieldCounts reads the mappings and total fields limits of the matching indices
+func indexFieldCounts(ctx context.Context, cluster *types.ClusterData, indices string, cacheTTL time.Duration) (map[string]types.IndexFieldCount, error) {
+	query := queryOptions{JobName: "getIndexFieldCounts", TTL: cacheTTL}
+	pattern := url.PathEscape(indices)
+
+	var mappings map[string]struct {
+		Mappings map[string]interface{} `json:"mappings"`
+	}
+	if err := getClusterJSON(ctx, cluster, "/"+pattern+"/_mapping?expand_wildcards=open", query, &mappings); err != nil {
+		return nil, fmt.Errorf("failed to fetch mappings: %w", err)
+	}
+
+	var settings map[string]struct {
+		Settings map[string]interface{} `json:"settings"`
+		Defaults map[string]interface{} `json:"defaults"`
+	}
+	settingsPath := "/" + pattern + "/_settings/index.mapping.total_fields.limit" +
+		"?include_defaults=true&flat_settings=true&expand_wildcards=open"
+	if err := getClusterJSON(ctx, cluster, settingsPath, query, &settings); err != nil {
+		return nil, fmt.Errorf("failed to fetch total fields limits: %w", err)
+	}
+
+	counts := make(map[string]types.IndexFieldCount, len(mappings))
+	for index, response := range mappings {
+		limit := defaultTotalFieldsLimit
+		if indexSettings, ok := settings[index]; ok {
+			value, set := indexSettings.Settings["index.mapping.total_fields.limit"]
+			if !set {
+				value = indexSettings.Defaults["index.mapping.total_fields.limit"]
+			}
+			if n, err := strconv.Atoi(fmt.Sprint(value)); err == nil && n > 0 {
+				limit = n
+			}
+		}
+
+		dynamic := "true"
+		if value, ok := response.Mappings["dynamic"]; ok {
+			dynamic = fmt.Sprint(value)
+		}
+		counts[index] = types.IndexFieldCount{
+			Fields:  countMappingFields(response.Mappings),
+			Limit:   limit,
+			Dynamic: dynamic,
+		}
+	}
+	return counts, nil
+}
+
+// countMappingFields counts the fields of a mapping that count against the total fields
+// limit: the fields and objects under properties (recursively), their multi-fields and the
+// runtime fields
+func countMappingFields(mapping map[string]interface{}) int {
+	count := 0
+	if runtime, ok := mapping["runtime"].(map[string]interface{}); ok {
+		count += len(runtime)
+	}
+	properties, _ := mapping["properties"].(map[string]interface{})
+	for _, value := range properties {
+		field, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		count++
+		if multiFields, ok := field["fields"].(map[string]interface{}); ok {
+			count += len(multiFields)
+		}
+		count += countMappingFields(map[string]interface{}{"properties": field["properties"]})
+	}
+	return count
+}
+
+// fieldsUsedPercent is the share of its total fields limit an index uses
+func fieldsUsedPercent(count *types.IndexFieldCount) float64 {
+	if count.Limit <= 0 {
+		return 0
+	}
+	return float64(count.Fields) * 100 / float64(count.Limit)
+}
+
+// fieldGrowth returns by how many fields an index grew since its oldest sample within window
+// before the latest sample, and the time of that sample
+func fieldGrowth(points *types.Ring[types.FieldCountPoint], window time.Duration) (int, int64) {
+	latest := points.At(0)
+	oldest := latest
+	for _, point := range points.NewestFirst() {
+		if point.TimeStamp == 0 || latest.TimeStamp-point.TimeStamp > window.Milliseconds() {
+			break
+		}
+		oldest = point
+	}
+	return latest.Fields - oldest.Fields, oldest.TimeStamp
+}
+
+// recordFieldCountMetrics exports the field limit usage of the indices of a cluster
+func recordFieldCountMetrics(clusterName string, fieldCounts *types.ClusterFieldCounts, limitPercent int) {
+	maxUsed := 0.0
+	nearLimit := 0
+	for _, count := range fieldCounts.Indices {
+		usedPercent := fieldsUsedPercent(count)
+		maxUsed = max(maxUsed, usedPercent)
+		if usedPercent >= float64(limitPercent) {
+			nearLimit++
+		}
+	}
+	metrics.IndexFieldsMaxUsedPercent.WithLabelValues(clusterName).Set(maxUsed)
+	metrics.IndicesNearFieldLimit.WithLabelValues(clusterName).Set(float64(nearLimit))
+}
+
+// notifyFieldCounts sends the owner of a cluster one alert for its new field count events
+func notifyFieldCounts(ctx context.Context, clusterName string, clusterEvents []events.Event, severity string) {
+	// Events detected during maintenance are recorded but not alerted on
+	alerting := clusterEvents[:0:0]
+	for _, event := range clusterEvents {
+		if !event.Suppressed {
+			alerting = append(alerting, event)
+		}
+	}
+	if len(alerting) == 0 {
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Mapping field counts of %d indices of cluster %s need attention:\n", len(alerting), clusterName)
+	for _, event := range alerting {
+		if event.Severity == "critical" {
+			severity = "critical"
+		}
+		fmt.Fprintf(&text, "  %s: %s, %s of %s fields, dynamic %s (event %s)\n",
+			event.Labels["index"], event.Annotations["summary"], event.Annotations["fields"],
+			event.Annotations["limit"], event.Annotations["dynamic"], event.ID)
+		writeEventLinks(&text, event.Annotations)
+	}
+	if hosts := alerting[0].Annotations["writePressureHosts"]; hosts != "0" {
+		fmt.Fprintf(&text, "%s hosts of the cluster are under write pressure.\n", hosts)
+	}
+
+	err := notify.NotifyCluster(ctx, clusterName, notify.Message{
+		Subject:  fmt.Sprintf("Mapping field growth on cluster %s", clusterName),
+		Text:     text.String(),
+		Severity: severity,
+	})
+	if err != nil {
+		logger.JobWarn("getIndexFieldCounts", "Failed to notify owner of cluster %s: %v", clusterName, err)
+	}
+}
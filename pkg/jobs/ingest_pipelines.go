@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// nodesIngestStats is the part of the _nodes/stats/ingest response that is used
+type nodesIngestStats struct {
+	Nodes map[string]struct {
+		Host   string `json:"host"`
+		Name   string `json:"name"`
+		Ingest struct {
+			Pipelines map[string]struct {
+				Count        uint64 `json:"count"`
+				TimeInMillis uint64 `json:"time_in_millis"`
+				Current      uint64 `json:"current"`
+				Failed       uint64 `json:"failed"`
+			} `json:"pipelines"`
+		} `json:"ingest"`
+	} `json:"nodes"`
+}
+
+// GetIngestPipelineStats samples the documents processed, the processing time and the failures
+// of every ingest pipeline on every node with _nodes/stats/ingest. The counters are summed per
+// pipeline over the nodes of a cluster and each pipeline keeps the latest historySize samples,
+// with the growth since the previous sample, so ingest time and failures can be attributed to
+// pipelines over a window (/api/pipelines/{clusterName}).
+func GetIngestPipelineStats(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("getIngestPipelineStats", "Starting ingest pipeline stats collection")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("getIngestPipelineStats", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	historySize := p.IntInRange("historySize", 60, 2, 2016)
+	pipelines := p.StringSlice("pipelines")
+	cacheTTL := p.Duration("cacheTTL", 0)
+	if err := checkParams("getIngestPipelineStats", p); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	sampledPipelines := 0
+
+	summary, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		var stats nodesIngestStats
+		query := queryOptions{JobName: "getIngestPipelineStats", TTL: cacheTTL}
+		if err := getClusterJSON(ctx, cluster, "/_nodes/stats/ingest", query, &stats); err != nil {
+			return fmt.Errorf("failed to fetch node ingest stats: %w", err)
+		}
+
+		counters := make(map[string]map[string]types.PipelineCounters, len(stats.Nodes))
+		hosts := newHostResolver(cluster)
+		for _, node := range stats.Nodes {
+			hostName := hosts.nodeHostName(node.Host, node.Name)
+			nodeCounters := make(map[string]types.PipelineCounters, len(node.Ingest.Pipelines))
+			for pipeline, pipelineStats := range node.Ingest.Pipelines {
+				if len(pipelines) > 0 && !utils.Contains(pipelines, pipeline) {
+					continue
+				}
+				nodeCounters[pipeline] = types.PipelineCounters{
+					Count:   pipelineStats.Count,
+					TimeMs:  pipelineStats.TimeInMillis,
+					Failed:  pipelineStats.Failed,
+					Current: pipelineStats.Current,
+				}
+			}
+			counters[hostName] = nodeCounters
+		}
+
+		previous, _ := types.GetPipelineStats(clusterName)
+		types.AddPipelineStats(clusterName, utils.TimeNowMillis(), counters, historySize)
+		current, _ := types.GetPipelineStats(clusterName)
+		recordPipelineMetrics(clusterName, previous, current)
+
+		mu.Lock()
+		sampledPipelines += len(current.Pipelines)
+		mu.Unlock()
+
+		logger.JobInfo("getIngestPipelineStats", "Cluster %s: %d pipelines on %d nodes sampled",
+			clusterName, len(current.Pipelines), len(counters))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.JobInfo("getIngestPipelineStats", "Completed: %d clusters, %d pipelines sampled", summary.Succeeded, sampledPipelines)
+	return nil
+}
+
+// recordPipelineMetrics adds the growth of the latest sample to the pipeline counters of a
+// cluster; pipelines that are gone lose their series
+func recordPipelineMetrics(clusterName string, previous, current *types.ClusterPipelineStats) {
+	if previous != nil {
+		for pipeline := range previous.Pipelines {
+			if _, ok := current.Pipelines[pipeline]; !ok {
+				labels := prometheus.Labels{"cluster": clusterName, "pipeline": pipeline}
+				metrics.IngestPipelineDocumentsTotal.Delete(labels)
+				metrics.IngestPipelineTimeSecondsTotal.Delete(labels)
+				metrics.IngestPipelineFailuresTotal.Delete(labels)
+				metrics.IngestPipelineCurrent.Delete(labels)
+			}
+		}
+	}
+
+	for pipeline, history := range current.Pipelines {
+		point := history.Points.At(0)
+		metrics.IngestPipelineDocumentsTotal.WithLabelValues(clusterName, pipeline).Add(float64(point.CountDelta))
+		metrics.IngestPipelineTimeSecondsTotal.WithLabelValues(clusterName, pipeline).Add(float64(point.TimeMsDelta) / 1000)
+		metrics.IngestPipelineFailuresTotal.WithLabelValues(clusterName, pipeline).Add(float64(point.FailedDelta))
+		metrics.IngestPipelineCurrent.WithLabelValues(clusterName, pipeline).Set(float64(point.Current))
+	}
+}
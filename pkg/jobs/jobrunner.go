@@ -0,0 +1,252 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/scheduler"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// ClusterRunOptions controls how ForEachCluster selects and processes clusters
+type ClusterRunOptions struct {
+	JobName         string        // used for logging
+	IncludeClusters []string      // if set, only these clusters are processed
+	ExcludeClusters []string      // ignored when IncludeClusters is set
+	Tenant          string        // if set, only clusters of this tenant are processed
+	MaxConcurrent   int           // clusters processed at the same time (minimum 1)
+	ClusterTimeout  time.Duration // per-cluster deadline, 0 = none
+	SkipUUIDCheck   bool          // the job does not query the clusters' endpoints, see verifyClusterUUID
+
+	// Skip returns a non-empty reason for clusters that should not be processed.
+	// Skipped clusters are logged as warnings and are not counted as failures.
+	Skip func(cluster *types.ClusterData) string
+}
+
+// clusterRunOptionsFromParams reads the common cluster selection parameters of a job
+// (includeClusters, excludeClusters, clusterTimeout, and tenant for jobs loaded from a
+// tenant directory). MaxConcurrent is left to the job, as jobs name and bound that
+// parameter differently.
+func clusterRunOptionsFromParams(jobName string, p *jobparams.Reader) ClusterRunOptions {
+	return ClusterRunOptions{
+		JobName:         jobName,
+		IncludeClusters: p.StringSlice("includeClusters"),
+		ExcludeClusters: p.StringSlice("excludeClusters"),
+		Tenant:          p.String("tenant", ""),
+		ClusterTimeout:  p.Duration("clusterTimeout", 0),
+	}
+}
+
+// clusterSelection is the includeClusters and excludeClusters selection of the jobs that work
+// on the data already collected for their clusters instead of running ForEachCluster. As in
+// buildClusterList, includeClusters selects the clusters it names and excludeClusters is only
+// applied without it.
+type clusterSelection struct {
+	include []string
+	exclude []string
+}
+
+// clusterSelectionFromParams reads the cluster selection parameters of a job
+func clusterSelectionFromParams(p *jobparams.Reader) clusterSelection {
+	return clusterSelection{
+		include: p.StringSlice("includeClusters"),
+		exclude: p.StringSlice("excludeClusters"),
+	}
+}
+
+// selects reports whether the job processes a cluster
+func (cs clusterSelection) selects(clusterName string) bool {
+	if len(cs.include) > 0 {
+		return utils.Contains(cs.include, clusterName)
+	}
+	return !utils.Contains(cs.exclude, clusterName)
+}
+
+// tenantScopedJobs are the predefined jobs that select their clusters with
+// clusterRunOptionsFromParams and so can be restricted to the clusters of a tenant
+var tenantScopedJobs = map[string]bool{
+	"runCatIndices":            true,
+	"getThreadPoolWriteQueue":  true,
+	"getTDataWriteBulk_sTasks": true,
+	"checkRetention":           true,
+	"getNodeDiskUsage":         true,
+	"getNodeJVMStats":          true,
+	"getThreadPoolRejections":  true,
+	"getNodeSegmentStats":      true,
+	"getShardRecoveries":       true,
+	"checkSettingsDrift":       true,
+	"getIndexFieldCounts":      true,
+	"getIngestPipelineStats":   true,
+	"getRemoteClusters":        true,
+	"getDataTiers":             true,
+	"watchLongRunningTasks":    true,
+	"dumpState":                true,
+	"verifyInventory":          true,
+}
+
+// IsTenantScoped reports whether a predefined job can run for a single tenant
+func IsTenantScoped(internalJobName string) bool {
+	return tenantScopedJobs[internalJobName]
+}
+
+// checkParams logs the values that were adjusted while reading a job's parameters and
+// returns the validation errors, if any
+func checkParams(jobName string, p *jobparams.Reader) error {
+	for _, warning := range p.Warnings() {
+		logger.JobWarn(jobName, "%s", warning)
+	}
+	return p.Err()
+}
+
+// ForEachCluster runs fn for every selected cluster in parallel, records each outcome in the
+// collection status registry, logs each failure and a summary line, and returns the summary.
+// Unless SkipUUIDCheck is set, a cluster whose active endpoint reports another cluster UUID
+// than the inventory fails without calling fn. The error is non-nil only if ctx was cancelled;
+// per-cluster failures are reported in the summary. The succeeded and failed clusters are
+// published as the job results clusters and failedClusters, for the jobs following it.
+func ForEachCluster(ctx context.Context, opts ClusterRunOptions, fn func(ctx context.Context, clusterName string) error) (*RunSummary, error) {
+	clusterList := buildClusterList(opts.JobName, opts.IncludeClusters, opts.ExcludeClusters)
+	if opts.Tenant != "" {
+		clusterList = tenantClusters(opts, clusterList)
+	}
+
+	if opts.Skip != nil {
+		clusters := types.SnapshotClusters()
+		selected := clusterList[:0]
+		for _, clusterName := range clusterList {
+			cluster, exists := clusters[clusterName]
+			if !exists {
+				continue
+			}
+			if reason := opts.Skip(cluster); reason != "" {
+				logger.JobWarn(opts.JobName, "Cluster %s %s, skipping", clusterName, reason)
+				continue
+			}
+			selected = append(selected, clusterName)
+		}
+		clusterList = selected
+	}
+
+	logger.JobInfo(opts.JobName, "Processing %d clusters", len(clusterList))
+
+	var succeededMu sync.Mutex
+	succeeded := make([]string, 0, len(clusterList))
+	summary, err := runParallel(ctx, clusterList, opts.MaxConcurrent, func(runCtx context.Context, clusterName string) error {
+		clusterCtx := runCtx
+		if opts.ClusterTimeout > 0 {
+			var cancel context.CancelFunc
+			clusterCtx, cancel = context.WithTimeout(runCtx, opts.ClusterTimeout)
+			defer cancel()
+		}
+		var err error
+		if !opts.SkipUUIDCheck {
+			err = verifyClusterUUID(clusterCtx, clusterName)
+		}
+		if err == nil {
+			err = fn(clusterCtx, clusterName)
+		}
+		// A cluster timeout is a failure of the cluster, a cancelled run is not
+		if err == nil || runCtx.Err() == nil {
+			recordCollection(opts.JobName, clusterName, err)
+		}
+		if err == nil {
+			succeededMu.Lock()
+			succeeded = append(succeeded, clusterName)
+			succeededMu.Unlock()
+		}
+		return err
+	})
+
+	sort.Strings(succeeded)
+	scheduler.PublishResult(ctx, "clusters", succeeded)
+	scheduler.PublishResult(ctx, "failedClusters", summary.FailedItems())
+
+	for _, clusterName := range summary.FailedItems() {
+		logger.JobError(opts.JobName, "Cluster %s failed: %v", clusterName, summary.Failed[clusterName])
+	}
+	logger.JobInfo(opts.JobName, "Completed: %d succeeded, %d failed, %d skipped",
+		summary.Succeeded, len(summary.Failed), summary.Skipped)
+
+	return summary, err
+}
+
+// recordCollection updates the collection status registry and metrics of a cluster
+func recordCollection(jobName, clusterName string, err error) {
+	status := types.RecordCollection(jobName, clusterName, utils.TimeNowMillis(), err)
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	metrics.CollectionsTotal.WithLabelValues(jobName, clusterName, result).Inc()
+	metrics.CollectionConsecutiveFailures.WithLabelValues(jobName, clusterName).Set(float64(status.ConsecutiveFailures))
+	if status.LastSuccess != 0 {
+		metrics.CollectionLastSuccessSeconds.WithLabelValues(jobName, clusterName).Set(float64(status.LastSuccess) / 1000)
+	}
+}
+
+// carriedOverEvents returns the firing events of a source for clusters that still exist but
+// were not processed this run, as observations, so that Sync keeps them firing
+func carriedOverEvents(source string, processed map[string]bool) []events.Observation {
+	observed := make([]events.Observation, 0)
+	for _, event := range events.Query(events.Filter{Source: source, State: events.StateFiring}) {
+		if !processed[event.Cluster()] && types.ClusterExists(event.Cluster()) {
+			observed = append(observed, events.Observation{
+				Name:        event.Name,
+				Severity:    event.Severity,
+				Labels:      event.Labels,
+				Annotations: event.Annotations,
+				StartsAt:    event.StartsAt,
+			})
+		}
+	}
+	return observed
+}
+
+// tenantClusters keeps the clusters of the job's tenant. Included clusters of other tenants
+// are reported, as a tenant's jobs must never reach them.
+func tenantClusters(opts ClusterRunOptions, clusterList []string) []string {
+	selected := make([]string, 0, len(clusterList))
+	for _, clusterName := range clusterList {
+		if t, _ := types.ClusterTenant(clusterName); t == opts.Tenant {
+			selected = append(selected, clusterName)
+		} else if utils.Contains(opts.IncludeClusters, clusterName) {
+			logger.JobWarn(opts.JobName, "Cluster %s in includeClusters does not belong to tenant %s", clusterName, opts.Tenant)
+		}
+	}
+	return selected
+}
+
+// buildClusterList creates the list of clusters to process
+func buildClusterList(jobName string, includeClusters, excludeClusters []string) []string {
+	allClustersList := types.ClusterNames()
+
+	if len(includeClusters) > 0 {
+		// Use included clusters, but validate they exist
+		validClusters := make([]string, 0, len(includeClusters))
+		for _, clusterName := range includeClusters {
+			if utils.Contains(allClustersList, clusterName) {
+				validClusters = append(validClusters, clusterName)
+			} else {
+				logger.JobWarn(jobName, "Cluster %s in includeClusters not found in global cluster list", clusterName)
+			}
+		}
+		return validClusters
+	}
+
+	// Use all clusters minus excluded ones
+	clusterList := make([]string, 0, len(allClustersList))
+	for _, clusterName := range allClustersList {
+		if !utils.Contains(excludeClusters, clusterName) {
+			clusterList = append(clusterList, clusterName)
+		}
+	}
+	return clusterList
+}
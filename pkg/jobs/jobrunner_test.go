@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"testing"
+
+	jobparams "ElasticObservability/pkg/params"
+)
+
+func TestClusterSelection(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		want   map[string]bool
+	}{
+		{"no lists", map[string]interface{}{}, map[string]bool{"prod-01": true, "prod-02": true}},
+		{"excludeClusters", map[string]interface{}{"excludeClusters": []string{"prod-02"}}, map[string]bool{"prod-01": true, "prod-02": false}},
+		{"includeClusters", map[string]interface{}{"includeClusters": []string{"prod-02"}}, map[string]bool{"prod-01": false, "prod-02": true}},
+		{"includeClusters overrides excludeClusters", map[string]interface{}{
+			"includeClusters": []interface{}{"prod-02"},
+			"excludeClusters": []interface{}{"prod-02"},
+		}, map[string]bool{"prod-01": false, "prod-02": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection := clusterSelectionFromParams(jobparams.New(tt.params))
+			for clusterName, want := range tt.want {
+				if got := selection.selects(clusterName); got != want {
+					t.Errorf("selects(%s) = %v, want %v", clusterName, got, want)
+				}
+			}
+		})
+	}
+}
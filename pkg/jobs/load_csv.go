@@ -3,27 +3,62 @@ package jobs
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"ElasticObservability/pkg/logger"
+	jobparams "ElasticObservability/pkg/params"
 	"ElasticObservability/pkg/types"
 	"ElasticObservability/pkg/utils"
 )
 
-// LoadFromMasterCSV loads cluster data from CSV file
+// Removal policies applied to clusters and nodes that are no longer present in the CSV
+const (
+	removalPolicyNone       = "none"       // keep everything already in memory (legacy behaviour)
+	removalPolicyDeactivate = "deactivate" // mark missing clusters inactive, drop missing nodes
+	removalPolicyRemove     = "remove"     // drop missing clusters (and their collected data) and nodes
+)
+
+// csvClusterRows groups CSV rows belonging to the same cluster, preserving file order
+type csvClusterRows struct {
+	clusterName string
+	rows        []map[string]string
+	rowNumbers  []int
+}
+
+// LoadFromMasterCSV loads cluster data from CSV file.
+// On re-runs the in-memory inventory is reconciled with the file: new clusters and nodes
+// are added, changed fields are updated in place and, depending on removalPolicy,
+// clusters and nodes that disappeared from the CSV are deactivated or removed.
 func LoadFromMasterCSV(ctx context.Context, params map[string]interface{}) error {
 	logger.JobInfo("loadFromMasterCSV", "Starting CSV load job")
 
-	// Get CSV file name from parameters
-	csvFileName, ok := params["csv_fileName"].(string)
-	if !ok || csvFileName == "" {
-		return fmt.Errorf("csv_fileName parameter is required")
+	p := jobparams.New(params)
+
+	// Get CSV file name and input mapping from parameters
+	csvFileName := p.RequiredString("csv_fileName")
+	inputMapping := p.RequiredMap("inputMapping")
+
+	// Get removal policy and filter clusters list (optional)
+	removalPolicy := p.OneOf("removalPolicy", removalPolicyNone, removalPolicyNone, removalPolicyDeactivate, removalPolicyRemove)
+	filterClusters := p.StringSlice("filterClusters")
+	transformations := p.Map("transformations")
+
+	if err := p.Err(); err != nil {
+		return err
 	}
 
-	// Get input mapping
-	inputMapping, ok := params["inputMapping"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("inputMapping parameter is required")
+	// Register named transformations defined in the job configuration (optional)
+	if err := registerConfiguredTransformations(transformations); err != nil {
+		return err
+	}
+
+	// Build the derived fields up front so invalid expressions and transformation arguments
+	// fail the job clearly
+	derived, err := buildDerivedFields(inputMapping)
+	if err != nil {
+		return err
 	}
 
 	// Parse CSV file
@@ -35,28 +70,19 @@ func LoadFromMasterCSV(ctx context.Context, params map[string]interface{}) error
 	rows := parser.GetRows()
 	logger.JobInfo("loadFromMasterCSV", "Parsed %d rows from CSV", len(rows))
 
-	// Get filter clusters list (optional)
-	filterClusters := make([]string, 0)
-	if filterParam, ok := params["filterClusters"].([]interface{}); ok {
-		for _, item := range filterParam {
-			if str, ok := item.(string); ok && str != "" {
-				filterClusters = append(filterClusters, str)
-			}
-		}
-	}
-
 	// Log filter information
 	if len(filterClusters) > 0 {
 		logger.JobInfo("loadFromMasterCSV", "Filter enabled: Only loading %d specific clusters", len(filterClusters))
 	} else {
 		logger.JobInfo("loadFromMasterCSV", "No filter: Loading all clusters from CSV")
 	}
+	logger.JobInfo("loadFromMasterCSV", "Removal policy: %s", removalPolicy)
 
-	// Process each row
-	addedClusters := 0
-	addedNodes := 0
+	// Group rows by cluster before touching global state
 	skippedRows := 0
 	filteredClusters := 0
+	grouped := make([]*csvClusterRows, 0)
+	groupIndex := make(map[string]*csvClusterRows)
 
 	for rowIdx, row := range rows {
 		// Get cluster name
@@ -74,90 +100,248 @@ func LoadFromMasterCSV(ctx context.Context, params map[string]interface{}) error
 			continue
 		}
 
-		// Get or create cluster
-		types.ClustersMu.Lock()
-		cluster, exists := types.AllClusters[clusterName]
+		group, exists := groupIndex[clusterName]
 		if !exists {
-			cluster = &types.ClusterData{
-				ClusterName: clusterName,
-				Active:      true, // Default to active
-				ClusterPort: "9200",
-				KibanaPort:  "5601",
-				Nodes:       make([]*types.Node, 0),
-			}
-			types.AllClusters[clusterName] = cluster
-			types.AllClustersList = append(types.AllClustersList, clusterName)
-			addedClusters++
-			logger.JobInfo("loadFromMasterCSV", "Created new cluster: %s", clusterName)
+			group = &csvClusterRows{clusterName: clusterName}
+			groupIndex[clusterName] = group
+			grouped = append(grouped, group)
 		}
-		types.ClustersMu.Unlock()
+		group.rows = append(group.rows, row)
+		group.rowNumbers = append(group.rowNumbers, rowIdx+1)
+	}
 
-		// Process constant values
-		if err := applyConstantValues(cluster, inputMapping); err != nil {
-			logger.JobWarn("loadFromMasterCSV", "Row %d: Failed to apply constants: %v", rowIdx+1, err)
-		}
+	addedClusters := 0
+	updatedClusters := 0
+	addedNodes := 0
+	updatedNodes := 0
+	removedNodes := 0
+	deactivatedClusters := 0
+	reactivatedClusters := 0
+	removedClusterNames := make([]string, 0)
+
+	// Reconcile everything under the clusters lock so readers never see half-built clusters
+	types.MutateClusters(func(clusters map[string]*types.ClusterData) {
+		for _, group := range grouped {
+			cluster, exists := clusters[group.clusterName]
+			if !exists {
+				cluster = &types.ClusterData{
+					ClusterName: group.clusterName,
+					Active:      true, // Default to active
+					ClusterPort: "9200",
+					KibanaPort:  "5601",
+					Nodes:       make([]*types.Node, 0),
+				}
+				clusters[group.clusterName] = cluster
+				addedClusters++
+				logger.JobInfo("loadFromMasterCSV", "Created new cluster: %s", group.clusterName)
+			}
 
-		// Process straight mappings (cluster level)
-		if err := applyStraightMappingsCluster(cluster, row, inputMapping); err != nil {
-			logger.JobWarn("loadFromMasterCSV", "Row %d: Failed to apply straight mappings: %v", rowIdx+1, err)
-		}
+			before := clusterInventorySignature(cluster)
 
-		// Process derived fields (cluster level)
-		if err := applyDerivedFieldsCluster(cluster, row, inputMapping); err != nil {
-			logger.JobWarn("loadFromMasterCSV", "Row %d: Failed to apply derived fields: %v", rowIdx+1, err)
-		}
+			// A cluster deactivated while missing from the CSV is active again now that it is
+			// back, unless an "active" mapping of its rows says otherwise
+			wasInactive := exists && !cluster.Active
+			if wasInactive {
+				cluster.Active = true
+			}
 
-		// Create and add node
-		node := &types.Node{
-			Port:       "9200", // default
-			KibanaPort: "5601", // default
-		}
+			// A field whose column is empty in every row of the cluster was cleared in the CSV
+			clearEmptyStraightMappingsCluster(cluster, group.rows, inputMapping)
+
+			// Nodes described by the CSV; a host may span several rows (e.g. one row per role
+			// or per port), in which case the rows are aggregated into a single node
+			csvNodes := make(map[string]*types.Node)
+			csvNodeOrder := make([]string, 0, len(group.rows))
+
+			for i, row := range group.rows {
+				rowNum := group.rowNumbers[i]
+
+				// Process constant values
+				if err := applyConstantValues(cluster, inputMapping); err != nil {
+					logger.JobWarn("loadFromMasterCSV", "Row %d: Failed to apply constants: %v", rowNum, err)
+				}
+
+				// Process straight mappings (cluster level)
+				if err := applyStraightMappingsCluster(cluster, row, inputMapping); err != nil {
+					logger.JobWarn("loadFromMasterCSV", "Row %d: Failed to apply straight mappings: %v", rowNum, err)
+				}
+
+				// Process derived fields (cluster level)
+				if err := applyDerivedFieldsCluster(cluster, row, derived); err != nil {
+					logger.JobWarn("loadFromMasterCSV", "Row %d: Failed to apply derived fields: %v", rowNum, err)
+				}
+
+				// Create node from row
+				node := &types.Node{
+					Port:       "9200", // default
+					KibanaPort: "5601", // default
+				}
+
+				// Process node fields
+				if err := applyStraightMappingsNode(node, row, inputMapping); err != nil {
+					logger.JobWarn("loadFromMasterCSV", "Row %d: Failed to apply node mappings: %v", rowNum, err)
+				}
+
+				if err := applyDerivedFieldsNode(node, row, derived); err != nil {
+					logger.JobWarn("loadFromMasterCSV", "Row %d: Failed to apply node derived fields: %v", rowNum, err)
+				}
+
+				if node.HostName == "" {
+					continue
+				}
+
+				if existing, seen := csvNodes[node.HostName]; seen {
+					mergeNodeRows(existing, node)
+					logger.JobDebug("loadFromMasterCSV", "Row %d: Merged additional row for node %s in cluster %s",
+						rowNum, node.HostName, group.clusterName)
+					continue
+				}
+				csvNodes[node.HostName] = node
+				csvNodeOrder = append(csvNodeOrder, node.HostName)
+			}
 
-		// Process node fields
-		if err := applyStraightMappingsNode(node, row, inputMapping); err != nil {
-			logger.JobWarn("loadFromMasterCSV", "Row %d: Failed to apply node mappings: %v", rowIdx+1, err)
-		}
+			// Update existing nodes in place or add new ones
+			for _, hostName := range csvNodeOrder {
+				node := csvNodes[hostName]
+				existingNode := cluster.GetNode(hostName)
+				if existingNode == nil {
+					cluster.Nodes = append(cluster.Nodes, node)
+					addedNodes++
+					continue
+				}
+
+				// Node credentials and their port may come from the credentials CSV rather than
+				// the master CSV
+				if node.AccessCred == nil {
+					node.AccessCred = existingNode.AccessCred
+				}
+				if existingNode.PortFromCredentials {
+					node.Port = existingNode.Port
+					node.PortFromCredentials = true
+				}
+				if !reflect.DeepEqual(*existingNode, *node) {
+					*existingNode = *node
+					updatedNodes++
+					logger.JobInfo("loadFromMasterCSV", "Updated node %s in cluster %s", hostName, group.clusterName)
+				}
+			}
 
-		if err := applyDerivedFieldsNode(node, row, inputMapping); err != nil {
-			logger.JobWarn("loadFromMasterCSV", "Row %d: Failed to apply node derived fields: %v", rowIdx+1, err)
-		}
+			// Drop nodes that are no longer listed for this cluster
+			if removalPolicy != removalPolicyNone {
+				keptNodes := make([]*types.Node, 0, len(cluster.Nodes))
+				for _, node := range cluster.Nodes {
+					if _, inCSV := csvNodes[node.HostName]; inCSV {
+						keptNodes = append(keptNodes, node)
+					} else {
+						removedNodes++
+						logger.JobInfo("loadFromMasterCSV", "Removed node %s from cluster %s (not in CSV)", node.HostName, group.clusterName)
+					}
+				}
+				cluster.Nodes = keptNodes
+			}
 
-		// Check if node already exists
-		nodeExists := false
-		for _, existingNode := range cluster.Nodes {
-			if existingNode.HostName == node.HostName {
-				nodeExists = true
-				logger.JobInfo("loadFromMasterCSV", "Row %d: Node %s already exists in cluster %s, skipping",
-					rowIdx+1, node.HostName, clusterName)
-				skippedRows++
-				break
+			if wasInactive && cluster.Active {
+				reactivatedClusters++
+				logger.JobInfo("loadFromMasterCSV", "Reactivated cluster %s (back in CSV)", group.clusterName)
+			}
+			if exists && before != clusterInventorySignature(cluster) {
+				updatedClusters++
 			}
 		}
 
-		if !nodeExists && node.HostName != "" {
-			cluster.Nodes = append(cluster.Nodes, node)
-			addedNodes++
+		// Handle clusters that are no longer present in the CSV.
+		// An empty result is treated as a bad file rather than "remove everything", and
+		// with filterClusters only the filtered clusters are reconciled: the rows of the
+		// others were skipped, not missing.
+		if removalPolicy != removalPolicyNone && len(grouped) > 0 {
+			for clusterName, cluster := range clusters {
+				if _, inCSV := groupIndex[clusterName]; inCSV {
+					continue
+				}
+				if len(filterClusters) > 0 && !utils.Contains(filterClusters, clusterName) {
+					continue
+				}
+
+				switch removalPolicy {
+				case removalPolicyDeactivate:
+					if cluster.Active {
+						cluster.Active = false
+						deactivatedClusters++
+						logger.JobInfo("loadFromMasterCSV", "Deactivated cluster %s (not in CSV)", clusterName)
+					}
+				case removalPolicyRemove:
+					delete(clusters, clusterName)
+					removedClusterNames = append(removedClusterNames, clusterName)
+					logger.JobInfo("loadFromMasterCSV", "Removed cluster %s (not in CSV)", clusterName)
+				}
+			}
+		} else if removalPolicy != removalPolicyNone {
+			logger.JobWarn("loadFromMasterCSV", "No clusters loaded from CSV, skipping removal of missing clusters")
 		}
-	}
+	})
+	totalClusters := types.ClusterCount()
 
-	// Populate AllClustersList from AllClusters keys
-	types.ClustersMu.Lock()
-	types.AllClustersList = make([]string, 0, len(types.AllClusters))
-	for clusterName := range types.AllClusters {
-		if clusterName != "" {
-			types.AllClustersList = append(types.AllClustersList, clusterName)
-		}
+	// Drop collected data for removed clusters
+	for _, clusterName := range removedClusterNames {
+		types.RemoveClusterData(clusterName)
 	}
-	types.ClustersMu.Unlock()
 
-	logger.JobInfo("loadFromMasterCSV", "Completed: Added %d clusters, %d nodes. Skipped %d rows, Filtered %d clusters",
-		addedClusters, addedNodes, skippedRows, filteredClusters)
-	logger.JobInfo("loadFromMasterCSV", "Total clusters in AllClusters: %d, AllClustersList: %d",
-		len(types.AllClusters), len(types.AllClustersList))
+	logger.JobInfo("loadFromMasterCSV", "Completed: Added %d clusters, %d nodes. Updated %d clusters, %d nodes. Skipped %d rows, Filtered %d clusters",
+		addedClusters, addedNodes, updatedClusters, updatedNodes, skippedRows, filteredClusters)
+	if removalPolicy != removalPolicyNone {
+		logger.JobInfo("loadFromMasterCSV", "Reconciliation: Removed %d clusters, deactivated %d clusters, reactivated %d clusters, removed %d nodes",
+			len(removedClusterNames), deactivatedClusters, reactivatedClusters, removedNodes)
+	}
+	logger.JobInfo("loadFromMasterCSV", "Total clusters in AllClusters: %d", totalClusters)
 
 	return nil
 }
 
+// mergeNodeRows folds an additional CSV row for the same host into the node built so far.
+// Roles are unioned and non-default values from the later row fill in or override.
+func mergeNodeRows(dst, src *types.Node) {
+	for _, t := range src.Type {
+		if !utils.Contains(dst.Type, t) {
+			dst.Type = append(dst.Type, t)
+		}
+	}
+	if src.IPAddress != "" {
+		dst.IPAddress = src.IPAddress
+	}
+	if src.Port != "" && src.Port != "9200" {
+		dst.Port = src.Port
+	}
+	if src.KibanaPort != "" && src.KibanaPort != "5601" {
+		dst.KibanaPort = src.KibanaPort
+	}
+	if src.LogstashPort != "" {
+		dst.LogstashPort = src.LogstashPort
+	}
+	if src.Zone != "" {
+		dst.Zone = src.Zone
+	}
+	if src.DataCenter != "" {
+		dst.DataCenter = src.DataCenter
+	}
+	if src.Rack != "" {
+		dst.Rack = src.Rack
+	}
+	if src.NodeTier != "" {
+		dst.NodeTier = src.NodeTier
+	}
+	if src.AccessCred != nil {
+		dst.AccessCred = src.AccessCred
+	}
+}
+
+// clusterInventorySignature captures the CSV-driven cluster fields so changes can be detected
+func clusterInventorySignature(cluster *types.ClusterData) string {
+	return fmt.Sprintf("%v|%v|%v|%s|%s|%s|%s|%v|%s|%s|%s",
+		cluster.InsecureTLS, cluster.ClusterSAN, cluster.KibanaSAN, cluster.Owner,
+		cluster.ClusterUUID, cluster.CurrentEndpoint, cluster.ZoneIdentifier, cluster.Active, cluster.Env,
+		cluster.Tenant, cluster.MonitoringProfile)
+}
+
 func getClusterNameFromRow(row map[string]string, inputMapping map[string]interface{}) string {
 	straight, ok := inputMapping["straight"].(map[string]interface{})
 	if !ok {
@@ -184,6 +368,14 @@ func applyConstantValues(cluster *types.ClusterData, inputMapping map[string]int
 			if val, ok := value.(bool); ok {
 				cluster.InsecureTLS = val
 			}
+		case "tenant":
+			if val, ok := value.(string); ok {
+				cluster.Tenant = val
+			}
+		case "monitoringProfile":
+			if val, ok := value.(string); ok {
+				cluster.MonitoringProfile = val
+			}
 		case "port":
 			// Port is handled at node level
 		}
@@ -224,55 +416,69 @@ func applyStraightMappingsCluster(cluster *types.ClusterData, row map[string]str
 			cluster.CurrentEndpoint = value
 		case "zoneIdentifier":
 			cluster.ZoneIdentifier = value
+		case "tenant":
+			cluster.Tenant = value
+		case "monitoringProfile":
+			cluster.MonitoringProfile = value
 		}
 	}
 
 	return nil
 }
 
-func applyDerivedFieldsCluster(cluster *types.ClusterData, row map[string]string, inputMapping map[string]interface{}) error {
-	derived, ok := inputMapping["derived"].([]interface{})
-	if !ok {
-		return nil
+// clearEmptyStraightMappingsCluster clears the cluster fields mapped to a column that is present
+// but empty in all the rows of the cluster, before the rows are applied. The cluster UUID and
+// ClusterSAN are kept: the credentials CSV, switchEndpoints and verifyInventory set them too.
+func clearEmptyStraightMappingsCluster(cluster *types.ClusterData, rows []map[string]string, inputMapping map[string]interface{}) {
+	straight, ok := inputMapping["straight"].(map[string]interface{})
+	if !ok || len(rows) == 0 {
+		return
 	}
 
-	for _, item := range derived {
-		derivedField, ok := item.(map[string]interface{})
+	for field, column := range straight {
+		columnStr, ok := column.(string)
 		if !ok {
 			continue
 		}
-
-		field, _ := derivedField["field"].(string)
-		column, _ := derivedField["column"].(string)
-		function, _ := derivedField["function"].(string)
-		arg := derivedField["arg"]
-		retVal := derivedField["retVal"]
-
-		value := utils.GetValue(row, column)
-		if value == "" {
+		if _, present := rows[0][columnStr]; !present {
 			continue
 		}
-
-		var result interface{}
-		var err error
-
-		if function == "strStringCompare" {
-			// Special handling for strStringCompare - always pass as map
-			argsMap := map[string]interface{}{
-				"arg":    arg,
-				"retVal": retVal,
+		empty := true
+		for _, row := range rows {
+			if row[columnStr] != "" {
+				empty = false
+				break
 			}
-			result, err = utils.ApplyTransformation(value, function, argsMap)
-		} else {
-			result, err = utils.ApplyTransformation(value, function, arg)
 		}
-
-		if err != nil {
-			logger.JobWarn("loadFromMasterCSV", "Failed to apply transformation %s: %v", function, err)
+		if !empty {
 			continue
 		}
 
 		switch field {
+		case "kibanaSAN":
+			cluster.KibanaSAN = nil
+		case "owner":
+			cluster.Owner = ""
+		case "currentEndpoint":
+			cluster.CurrentEndpoint = ""
+		case "zoneIdentifier":
+			cluster.ZoneIdentifier = ""
+		case "tenant":
+			cluster.Tenant = ""
+		case "monitoringProfile":
+			cluster.MonitoringProfile = ""
+		}
+	}
+}
+
+func applyDerivedFieldsCluster(cluster *types.ClusterData, row map[string]string, derived []derivedField) error {
+	for _, derivedField := range derived {
+		result, ok := derivedField.apply(row)
+		if !ok {
+			continue
+		}
+
+		switch derivedField.field {
 		case "active":
 			if val, ok := result.(bool); ok {
 				cluster.Active = val
@@ -281,6 +487,22 @@ func applyDerivedFieldsCluster(cluster *types.ClusterData, row map[string]string
 			if val, ok := result.(string); ok {
 				cluster.Env = val
 			}
+		case "owner":
+			if val, ok := result.(string); ok {
+				cluster.Owner = val
+			}
+		case "zoneIdentifier":
+			if val, ok := result.(string); ok {
+				cluster.ZoneIdentifier = val
+			}
+		case "tenant":
+			if val, ok := result.(string); ok {
+				cluster.Tenant = val
+			}
+		case "monitoringProfile":
+			if val, ok := result.(string); ok {
+				cluster.MonitoringProfile = val
+			}
 		}
 	}
 
@@ -323,48 +545,152 @@ func applyStraightMappingsNode(node *types.Node, row map[string]string, inputMap
 			node.Rack = value
 		case "nodeTier":
 			node.NodeTier = value
+		case "nodePreferredAccess":
+			if preferred, err := strconv.ParseUint(value, 10, 8); err == nil {
+				nodeAccessCred(node).Preferred = uint8(preferred)
+			}
+		case "nodeAPIKey":
+			nodeAccessCred(node).APIKey = value
+		case "nodeUserID":
+			nodeAccessCred(node).UserID = value
+		case "nodePassword":
+			nodeAccessCred(node).Password = value
 		}
 	}
 
 	return nil
 }
 
-func applyDerivedFieldsNode(node *types.Node, row map[string]string, inputMapping map[string]interface{}) error {
-	derived, ok := inputMapping["derived"].([]interface{})
-	if !ok {
-		return nil
+// nodeAccessCred returns the node's credential override, creating it on first use
+func nodeAccessCred(node *types.Node) *types.AccessCred {
+	if node.AccessCred == nil {
+		node.AccessCred = &types.AccessCred{}
 	}
+	return node.AccessCred
+}
 
-	for _, item := range derived {
-		derivedField, ok := item.(map[string]interface{})
+func applyDerivedFieldsNode(node *types.Node, row map[string]string, derived []derivedField) error {
+	for _, derivedField := range derived {
+		result, ok := derivedField.apply(row)
 		if !ok {
 			continue
 		}
 
-		field, _ := derivedField["field"].(string)
-		column, _ := derivedField["column"].(string)
-		function, _ := derivedField["function"].(string)
-		arg := derivedField["arg"]
+		switch derivedField.field {
+		case "type":
+			if val, ok := result.([]string); ok {
+				node.Type = val
+			} else if strVal, ok := result.(string); ok {
+				node.Type = utils.GetNodeTypes(strVal)
+			}
+		case "zone":
+			if val, ok := result.(string); ok {
+				node.Zone = val
+			}
+		case "dataCenter":
+			if val, ok := result.(string); ok {
+				node.DataCenter = val
+			}
+		case "rack":
+			if val, ok := result.(string); ok {
+				node.Rack = val
+			}
+		case "nodeTier":
+			if val, ok := result.(string); ok {
+				node.NodeTier = val
+			}
+		}
+	}
 
-		value := utils.GetValue(row, column)
-		if value == "" {
+	return nil
+}
+
+// derivedField is a derived field of the input mapping with its expression or transformation
+// built. apply returns false when the column is empty or evaluation fails.
+type derivedField struct {
+	field string
+	apply func(row map[string]string) (interface{}, bool)
+}
+
+// buildDerivedFields parses the expressions and binds the transformations of the derived
+// fields of the input mapping, once per run
+func buildDerivedFields(inputMapping map[string]interface{}) ([]derivedField, error) {
+	items, _ := inputMapping["derived"].([]interface{})
+	derived := make([]derivedField, 0, len(items))
+	for _, item := range items {
+		definition, ok := item.(map[string]interface{})
+		if !ok {
 			continue
 		}
+		field, _ := definition["field"].(string)
 
-		result, err := utils.ApplyTransformation(value, function, arg)
-		if err != nil {
-			logger.JobWarn("loadFromMasterCSV", "Failed to apply transformation %s: %v", function, err)
+		if expression, ok := definition["expression"].(string); ok && expression != "" {
+			if err := utils.ValidateExpression(expression); err != nil {
+				return nil, fmt.Errorf("invalid expression for derived field %s: %w", field, err)
+			}
+			derived = append(derived, derivedField{field: field, apply: func(row map[string]string) (interface{}, bool) {
+				result, err := utils.EvaluateExpression(expression, row)
+				if err != nil {
+					logger.JobWarn("loadFromMasterCSV", "Failed to evaluate expression %q: %v", expression, err)
+					return nil, false
+				}
+				return result, true
+			}})
 			continue
 		}
 
-		switch field {
-		case "type":
-			if val, ok := result.([]string); ok {
-				node.Type = val
-			} else if strVal, ok := result.(string); ok {
-				node.Type = utils.GetNodeTypes(strVal)
+		column, _ := definition["column"].(string)
+		function, _ := definition["function"].(string)
+		arg := definition["arg"]
+		if function == "strStringCompare" {
+			// Special handling for strStringCompare - always pass as map
+			arg = map[string]interface{}{
+				"arg":    arg,
+				"retVal": definition["retVal"],
+			}
+		}
+		transform, err := utils.BuildTransformation(function, arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transformation for derived field %s: %w", field, err)
+		}
+		derived = append(derived, derivedField{field: field, apply: func(row map[string]string) (interface{}, bool) {
+			value := utils.GetValue(row, column)
+			if value == "" {
+				return nil, false
+			}
+			result, err := transform(value)
+			if err != nil {
+				logger.JobWarn("loadFromMasterCSV", "Failed to apply transformation %s: %v", function, err)
+				return nil, false
+			}
+			return result, true
+		}})
+	}
+	return derived, nil
+}
+
+// registerConfiguredTransformations registers the named transformations defined in the
+// job's "transformations" parameter so derived fields can reference them by name
+func registerConfiguredTransformations(configured map[string]interface{}) error {
+	for name, item := range configured {
+		definition, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("transformation %s must be a map with function and arg", name)
+		}
+
+		function, _ := definition["function"].(string)
+		arg := definition["arg"]
+		if function == "strStringCompare" {
+			arg = map[string]interface{}{
+				"arg":    arg,
+				"retVal": definition["retVal"],
 			}
 		}
+
+		if err := utils.RegisterTransformationAlias(name, function, arg); err != nil {
+			return fmt.Errorf("failed to register transformation %s: %w", name, err)
+		}
+		logger.JobInfo("loadFromMasterCSV", "Registered transformation %s (based on %s)", name, function)
 	}
 
 	return nil
@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ElasticObservability/pkg/types"
+)
+
+// testInputMapping maps the columns of the test master CSVs
+var testInputMapping = map[string]interface{}{
+	"straight": map[string]interface{}{
+		"clusterName": "Cluster",
+		"hostName":    "Host",
+		"port":        "Port",
+	},
+}
+
+// writeTestCSV writes a CSV file into a temporary directory and returns its path
+func writeTestCSV(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadTestMasterCSV runs loadFromMasterCSV on a master CSV with the given mapping
+func loadTestMasterCSV(t *testing.T, content string, inputMapping map[string]interface{}) {
+	t.Helper()
+	params := map[string]interface{}{
+		"csv_fileName": writeTestCSV(t, "master.csv", content),
+		"inputMapping": inputMapping,
+	}
+	if err := LoadFromMasterCSV(context.Background(), params); err != nil {
+		t.Fatalf("LoadFromMasterCSV: %v", err)
+	}
+}
+
+// TestLoadFromMasterCSVKeepsCredentialsPort checks that reloading the master CSV keeps the
+// credentials and port the credentials CSV set on a node, while the other nodes follow the
+// master CSV
+func TestLoadFromMasterCSVKeepsCredentialsPort(t *testing.T) {
+	const clusterName = "csv-credentials-port"
+	t.Cleanup(func() { removeTestCluster(clusterName) })
+
+	loadTestMasterCSV(t, "Cluster,Host,Port\n"+
+		clusterName+",es-01,9200\n"+
+		clusterName+",es-02,9200\n", testInputMapping)
+
+	credentials := writeTestCSV(t, "credentials.csv", "ClusterName,NodeName,ClusterPort,PrefferedAccess,APIKey\n"+
+		clusterName+",es-01,9243,1,node-key\n")
+	if err := UpdateAccessCredentials(context.Background(), map[string]interface{}{"csv_fileName": credentials}); err != nil {
+		t.Fatalf("UpdateAccessCredentials: %v", err)
+	}
+
+	loadTestMasterCSV(t, "Cluster,Host,Port\n"+
+		clusterName+",es-01,9200\n"+
+		clusterName+",es-02,9201\n", testInputMapping)
+
+	cluster, ok := types.GetCluster(clusterName)
+	if !ok {
+		t.Fatalf("cluster %s not loaded", clusterName)
+	}
+	node := cluster.GetNode("es-01")
+	if node == nil || node.Port != "9243" || node.AccessCred == nil || node.AccessCred.APIKey != "node-key" {
+		t.Errorf("es-01 = %+v, want port 9243 and the credentials of the credentials CSV", node)
+	}
+	if node := cluster.GetNode("es-02"); node == nil || node.Port != "9201" {
+		t.Errorf("es-02 = %+v, want the port 9201 of the master CSV", node)
+	}
+}
+
+// TestLoadFromMasterCSVClearsEmptiedFields checks that a cluster field emptied in the CSV is
+// cleared on the next load, unless another row of the cluster still has a value, the column
+// is gone or the field is also set from other sources
+func TestLoadFromMasterCSVClearsEmptiedFields(t *testing.T) {
+	const clusterName = "csv-cleared-fields"
+	t.Cleanup(func() { removeTestCluster(clusterName) })
+	mapping := map[string]interface{}{
+		"straight": map[string]interface{}{
+			"clusterName":       "Cluster",
+			"hostName":          "Host",
+			"owner":             "Owner",
+			"tenant":            "Tenant",
+			"monitoringProfile": "Profile",
+			"kibanaSAN":         "KibanaSAN",
+			"clusterUUID":       "UUID",
+		},
+	}
+
+	loadTestMasterCSV(t, "Cluster,Host,Owner,Tenant,Profile,KibanaSAN,UUID\n"+
+		clusterName+",es-01,team-a,payments,critical,kb-01|kb-02,uuid-1\n"+
+		clusterName+",es-02,team-a,payments,critical,kb-01|kb-02,uuid-1\n", mapping)
+
+	// Owner emptied in every row, tenant in one row only, the profile column dropped
+	loadTestMasterCSV(t, "Cluster,Host,Owner,Tenant,KibanaSAN,UUID\n"+
+		clusterName+",es-01,,payments,,\n"+
+		clusterName+",es-02,,,,\n", mapping)
+
+	cluster, ok := types.GetCluster(clusterName)
+	if !ok {
+		t.Fatalf("cluster %s not loaded", clusterName)
+	}
+	if cluster.Owner != "" || len(cluster.KibanaSAN) != 0 {
+		t.Errorf("owner %q, kibanaSAN %v, want both cleared", cluster.Owner, cluster.KibanaSAN)
+	}
+	if cluster.Tenant != "payments" {
+		t.Errorf("tenant = %q, want payments of the row that still has it", cluster.Tenant)
+	}
+	if cluster.MonitoringProfile != "critical" {
+		t.Errorf("monitoringProfile = %q, want critical kept without its column", cluster.MonitoringProfile)
+	}
+	if cluster.ClusterUUID != "uuid-1" {
+		t.Errorf("clusterUUID = %q, want uuid-1 kept", cluster.ClusterUUID)
+	}
+}
+
+// TestLoadFromMasterCSVDerivedTransformations checks that a derived field is transformed for
+// each row, and that an invalid regexExtract pattern fails the job before any row is loaded
+func TestLoadFromMasterCSVDerivedTransformations(t *testing.T) {
+	const clusterName = "csv-derived-env"
+	t.Cleanup(func() { removeTestCluster(clusterName) })
+	mappingWithPattern := func(pattern string) map[string]interface{} {
+		return map[string]interface{}{
+			"straight": testInputMapping["straight"],
+			"derived": []interface{}{
+				map[string]interface{}{"field": "env", "column": "Host", "function": "regexExtract", "arg": pattern},
+			},
+		}
+	}
+	content := "Cluster,Host,Port\n" + clusterName + ",prd-es-01,9200\n"
+
+	loadTestMasterCSV(t, content, mappingWithPattern(`^([a-z]+)-`))
+	if cluster, ok := types.GetCluster(clusterName); !ok || cluster.Env != "prd" {
+		t.Fatalf("cluster %s = %+v, want env prd from its host", clusterName, cluster)
+	}
+
+	params := map[string]interface{}{
+		"csv_fileName": writeTestCSV(t, "master.csv", content),
+		"inputMapping": mappingWithPattern(`^([a-z+-`),
+	}
+	err := LoadFromMasterCSV(context.Background(), params)
+	if err == nil || !strings.Contains(err.Error(), "invalid transformation for derived field env") {
+		t.Errorf("LoadFromMasterCSV with an invalid pattern = %v, want the derived field error", err)
+	}
+}
@@ -0,0 +1,332 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// longRunningTaskSource is the event store source of long-running task events
+const longRunningTaskSource = "longRunningTask"
+
+// defaultExcludedTaskActions leaves out persistent tasks (ML jobs, CCR followers, transforms,
+// ...), whose actions end in [c] and which run for as long as they are configured
+var defaultExcludedTaskActions = []string{`\[c\]$`}
+
+// maxTaskDescription bounds the task description kept in an event annotation
+const maxTaskDescription = 300
+
+// longRunningTask is a watched task running longer than maxRunningTime
+type longRunningTask struct {
+	id          string // <node id>:<task number>, as used by the _tasks API
+	host        string
+	task        taskInfo
+	runningTime time.Duration
+	cancelled   string // outcome of a cancellation, "" = not cancelled
+}
+
+// WatchLongRunningTasks flags the top-level tasks of every cluster (from _tasks) running for
+// longer than maxRunningTime, e.g. runaway reindexes or delete-by-queries, as events in the
+// event store; an event resolves when its task ends. With allowCancel, flagged cancellable
+// tasks running longer than cancelAfter whose action matches cancelActions are cancelled
+// (POST _tasks/<id>/_cancel). Cancelling also needs a jobPermissions entry for the job, so
+// only the roles it names can trigger it through the API.
+func WatchLongRunningTasks(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("watchLongRunningTasks", "Starting long-running task check")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("watchLongRunningTasks", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	maxRunningTime := p.Duration("maxRunningTime", 30*time.Minute)
+	severity := p.OneOf("severity", "warning", "info", "warning", "critical")
+	insecureTLS := p.Bool("insecureTLS", false)
+	if err := checkParams("watchLongRunningTasks", p); err != nil {
+		return err
+	}
+	watch, err := parseLongRunningTaskParams(params)
+	if err != nil {
+		return err
+	}
+
+	logger.JobInfo("watchLongRunningTasks", "Config: maxRunningTime=%s, allowCancel=%v, cancelAfter=%s, cancelActions=%d",
+		maxRunningTime, watch.allowCancel, watch.cancelAfter, len(watch.cancelActions))
+
+	now := time.Now()
+	var mu sync.Mutex
+	collected := make(map[string]bool)
+	flagged := make(map[string][]*longRunningTask)
+
+	_, err = ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		tasks, err := fetchLongRunningTasks(ctx, cluster, watch, maxRunningTime, insecureTLS)
+		if err != nil {
+			return fmt.Errorf("failed to fetch tasks: %w", err)
+		}
+		for _, task := range tasks {
+			if watch.cancels(task) {
+				cancelLongRunningTask(ctx, cluster, task, insecureTLS)
+			}
+		}
+		metrics.LongRunningTasks.WithLabelValues(clusterName).Set(float64(len(tasks)))
+
+		mu.Lock()
+		collected[clusterName] = true
+		flagged[clusterName] = tasks
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Tasks of clusters that could not be polled this time are kept as they are
+	observed := make([]events.Observation, 0)
+	for clusterName, tasks := range flagged {
+		for _, task := range tasks {
+			annotations := map[string]string{
+				"summary":     fmt.Sprintf("Task running for more than %s", maxRunningTime),
+				"action":      task.task.Action,
+				"description": truncateRunes(task.task.Description, maxTaskDescription),
+				"host":        task.host,
+				"runningTime": task.runningTime.Round(time.Second).String(),
+				"cancellable": strconv.FormatBool(task.task.Cancellable),
+			}
+			if task.cancelled != "" {
+				annotations["cancelled"] = task.cancelled
+			}
+			observed = append(observed, events.Observation{
+				Name:        "LongRunningTask",
+				Severity:    severity,
+				Labels:      map[string]string{"cluster": clusterName, "task": task.id},
+				Annotations: annotations,
+				StartsAt:    task.task.StartTimeInMillis,
+			})
+		}
+	}
+	flaggedCount := len(observed)
+	observed = append(observed, carriedOverEvents(longRunningTaskSource, collected)...)
+
+	fired, resolved, err := events.Sync(longRunningTaskSource, observed, now)
+	if err != nil {
+		logger.JobWarn("watchLongRunningTasks", "Failed to persist events: %v", err)
+	}
+	for _, event := range fired {
+		logger.JobWarn("watchLongRunningTasks", "Long-running task: cluster=%s task=%s action=%s runningTime=%s (event %s)",
+			event.Cluster(), event.Labels["task"], event.Annotations["action"], event.Annotations["runningTime"], event.ID)
+	}
+	for _, event := range resolved {
+		logger.JobInfo("watchLongRunningTasks", "Long-running task ended: cluster=%s task=%s (event %s)",
+			event.Cluster(), event.Labels["task"], event.ID)
+	}
+
+	logger.JobInfo("watchLongRunningTasks", "Completed: %d clusters checked, %d long-running tasks, %d new events, %d resolved",
+		len(collected), flaggedCount, len(fired), len(resolved))
+	return nil
+}
+
+// ValidateLongRunningTaskParams checks the action patterns and the cancellation settings
+// when the job is loaded
+func ValidateLongRunningTaskParams(params map[string]interface{}) error {
+	_, err := parseLongRunningTaskParams(params)
+	return err
+}
+
+// longRunningTaskWatch holds which tasks are watched and which may be cancelled
+type longRunningTaskWatch struct {
+	actions        []*regexp.Regexp // watched actions, empty = all
+	excludeActions []*regexp.Regexp
+	allowCancel    bool
+	cancelActions  []*regexp.Regexp
+	cancelAfter    time.Duration
+}
+
+// parseLongRunningTaskParams reads actions, excludeActions, allowCancel, cancelActions and
+// cancelAfter. Cancelling is refused unless jobPermissions restrict who can trigger the job.
+func parseLongRunningTaskParams(params map[string]interface{}) (*longRunningTaskWatch, error) {
+	p := jobparams.New(params)
+	maxRunningTime := p.Duration("maxRunningTime", 30*time.Minute)
+	watch := &longRunningTaskWatch{
+		allowCancel: p.Bool("allowCancel", false),
+		cancelAfter: p.Duration("cancelAfter", maxRunningTime),
+	}
+	actions := p.StringSlice("actions")
+	excludeActions := defaultExcludedTaskActions
+	if p.Has("excludeActions") {
+		excludeActions = p.StringSlice("excludeActions")
+	}
+	cancelActions := p.StringSlice("cancelActions")
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	if maxRunningTime <= 0 {
+		return nil, fmt.Errorf("maxRunningTime must be positive, got %s", maxRunningTime)
+	}
+	if watch.cancelAfter < maxRunningTime {
+		return nil, fmt.Errorf("cancelAfter (%s) must not be shorter than maxRunningTime (%s)", watch.cancelAfter, maxRunningTime)
+	}
+
+	var err error
+	if watch.actions, err = compileTaskActionPatterns("actions", actions); err != nil {
+		return nil, err
+	}
+	if watch.excludeActions, err = compileTaskActionPatterns("excludeActions", excludeActions); err != nil {
+		return nil, err
+	}
+	if watch.cancelActions, err = compileTaskActionPatterns("cancelActions", cancelActions); err != nil {
+		return nil, err
+	}
+
+	if watch.allowCancel {
+		if len(watch.cancelActions) == 0 {
+			return nil, fmt.Errorf("allowCancel needs cancelActions naming the task actions that may be cancelled")
+		}
+		if !jobTriggerRestricted("watchLongRunningTasks") {
+			return nil, fmt.Errorf("allowCancel needs a jobPermissions entry for watchLongRunningTasks, so only its roles can trigger cancellations")
+		}
+	}
+	return watch, nil
+}
+
+// compileTaskActionPatterns compiles the regular expressions of a task action parameter
+func compileTaskActionPatterns(param string, expressions []string) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(expressions))
+	for _, expression := range expressions {
+		pattern, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %w", param, expression, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// matchesAny reports whether a task action matches one of the patterns
+func matchesAny(patterns []*regexp.Regexp, action string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// jobTriggerRestricted reports whether a jobPermissions entry with roles applies to an
+// internal job name (directly or through "*"), so the API only lets those roles trigger it
+func jobTriggerRestricted(internalJobName string) bool {
+	if config.Global == nil {
+		return false
+	}
+	for _, permission := range config.Global.JobPermissions {
+		if len(permission.Roles) > 0 && (utils.Contains(permission.Jobs, internalJobName) || utils.Contains(permission.Jobs, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// watches reports whether a task is watched: a top-level task whose action is selected
+func (w *longRunningTaskWatch) watches(task taskInfo) bool {
+	if task.ParentTaskID != "" || matchesAny(w.excludeActions, task.Action) {
+		return false
+	}
+	return len(w.actions) == 0 || matchesAny(w.actions, task.Action)
+}
+
+// cancels reports whether a flagged task is to be cancelled
+func (w *longRunningTaskWatch) cancels(task *longRunningTask) bool {
+	return w.allowCancel && task.task.Cancellable && task.runningTime >= w.cancelAfter &&
+		matchesAny(w.cancelActions, task.task.Action)
+}
+
+// fetchLongRunningTasks streams the _tasks response of a cluster and returns the watched tasks
+// running longer than maxRunningTime, longest first
+func fetchLongRunningTasks(ctx context.Context, cluster *types.ClusterData, watch *longRunningTaskWatch, maxRunningTime time.Duration, insecureTLS bool) ([]*longRunningTask, error) {
+	client := esHTTPClient(insecureTLS || cluster.InsecureTLS, 30*time.Second)
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(cluster.ActiveEndpoint, "/")+"/_tasks?detailed=true", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	utils.AddAuthentication(req, &cluster.AccessCred)
+
+	body, err := doQuery(client, req, nil, queryOptions{JobName: "watchLongRunningTasks", Cluster: cluster.ClusterName})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	tasks := make([]*longRunningTask, 0)
+	err = decodeTaskNodes(body, newHostResolver(cluster), func(node *taskNode) {
+		for id, task := range node.Tasks {
+			if task.RunningTimeInNanos == nil || !watch.watches(task) {
+				continue
+			}
+			runningTime := time.Duration(*task.RunningTimeInNanos)
+			if runningTime > maxRunningTime {
+				tasks = append(tasks, &longRunningTask{id: id, host: node.Host, task: task, runningTime: runningTime})
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].runningTime > tasks[j].runningTime })
+	return tasks, nil
+}
+
+// cancelLongRunningTask asks the cluster to cancel a task and records the outcome on it
+func cancelLongRunningTask(ctx context.Context, cluster *types.ClusterData, task *longRunningTask, insecureTLS bool) {
+	endpoint := strings.TrimSuffix(cluster.ActiveEndpoint, "/") + "/_tasks/" + url.PathEscape(task.id) + "/_cancel"
+	err := func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, nil)
+		if err != nil {
+			return err
+		}
+		utils.AddAuthentication(req, &cluster.AccessCred)
+		body, err := doQuery(esHTTPClient(insecureTLS || cluster.InsecureTLS, 30*time.Second), req, nil,
+			queryOptions{JobName: "watchLongRunningTasks", Cluster: cluster.ClusterName})
+		if err != nil {
+			return err
+		}
+		return body.Close()
+	}()
+
+	if err != nil {
+		task.cancelled = "failed: " + err.Error()
+		metrics.TaskCancellationsTotal.WithLabelValues(cluster.ClusterName, "failure").Inc()
+		logger.JobError("watchLongRunningTasks", "Failed to cancel task %s (%s) of cluster %s: %v",
+			task.id, task.task.Action, cluster.ClusterName, err)
+		return
+	}
+	task.cancelled = "true"
+	metrics.TaskCancellationsTotal.WithLabelValues(cluster.ClusterName, "success").Inc()
+	logger.JobWarn("watchLongRunningTasks", "Cancelled task %s (%s) of cluster %s after %s",
+		task.id, task.task.Action, cluster.ClusterName, task.runningTime.Round(time.Second))
+}
+
+// truncateRunes shortens s to at most n runes, marking the cut with "..."
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ElasticObservability/pkg/logger"
+)
+
+// TestMain points the job and app logs at a temporary directory, as the jobs log through
+// the global loggers
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "jobs-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := logger.Init("warn", filepath.Join(dir, "app.log"), filepath.Join(dir, "jobs.log")); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
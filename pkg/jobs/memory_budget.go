@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/memory"
+)
+
+// EnforceMemoryBudgets evicts the oldest history snapshots of every data structure that is
+// over its configured memory budget and refreshes the memory gauges
+func EnforceMemoryBudgets(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("enforceMemoryBudgets", "Starting memory budget enforcement")
+
+	evicted, err := memory.Enforce()
+	if err != nil {
+		logger.JobError("enforceMemoryBudgets", "Failed to enforce memory budgets: %v", err)
+		return fmt.Errorf("failed to enforce memory budgets: %w", err)
+	}
+
+	for subsystem, count := range evicted {
+		if count > 0 {
+			logger.JobInfo("enforceMemoryBudgets", "Evicted %d snapshots from %s", count, subsystem)
+		}
+	}
+
+	usages, err := memory.Collect()
+	if err != nil {
+		return fmt.Errorf("failed to collect memory usage: %w", err)
+	}
+
+	for _, usage := range usages {
+		if usage.Budget > 0 && usage.Bytes > usage.Budget {
+			logger.JobWarn("enforceMemoryBudgets", "%s still over budget after eviction: %d bytes (budget %d)",
+				usage.Subsystem, usage.Bytes, usage.Budget)
+		}
+	}
+
+	logger.JobInfo("enforceMemoryBudgets", "Memory budget enforcement completed")
+	return nil
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"net/http"
+	"path"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// jobMonitoringCluster names the monitoring cluster given by the APIEndPoints and APIKEY
+// parameters of a job
+const jobMonitoringCluster = "job"
+
+// monitoringTarget is the monitoring cluster a query for the .monitoring data of a cluster goes to
+type monitoringTarget struct {
+	name      string
+	endpoints endpointSelection
+	apiKey    string
+	index     string // index pattern searched, "" = the one of the cluster's query profile
+	client    *http.Client
+}
+
+// monitoringRouter routes clusters to the monitoring clusters of config.yaml, falling back to
+// the monitoring cluster of the job parameters
+type monitoringRouter struct {
+	targets map[string]*monitoringTarget // key: monitoring cluster name
+}
+
+// newMonitoringRouter builds the targets of the configured monitoring clusters and, if the job
+// has APIEndPoints, of the job's own. p provides the endpoint selection parameters and the
+// index of the job's monitoring cluster.
+func newMonitoringRouter(p *jobparams.Reader, jobEndpoints []string, apiKey string, insecureTLS bool) *monitoringRouter {
+	router := &monitoringRouter{targets: make(map[string]*monitoringTarget)}
+	for _, m := range config.Global.MonitoringClusters {
+		router.targets[m.Name] = &monitoringTarget{
+			name:      m.Name,
+			endpoints: monitoringEndpointSelection(m.Endpoints, p),
+			apiKey:    m.APIKey,
+			index:     m.Index,
+			client:    esHTTPClient(m.InsecureTLS, 30*time.Second),
+		}
+	}
+	if len(jobEndpoints) > 0 {
+		router.targets[jobMonitoringCluster] = &monitoringTarget{
+			name:      jobMonitoringCluster,
+			endpoints: monitoringEndpointSelection(jobEndpoints, p),
+			apiKey:    apiKey,
+			index:     p.String("index", ""),
+			client:    esHTTPClient(insecureTLS, 30*time.Second),
+		}
+	}
+	return router
+}
+
+// route returns the target holding the .monitoring data of a cluster, nil if none does
+func (r *monitoringRouter) route(cluster *types.ClusterData) *monitoringTarget {
+	if m := MonitoringClusterOf(cluster); m != nil {
+		return r.targets[m.Name]
+	}
+	return r.targets[jobMonitoringCluster]
+}
+
+// MonitoringClusterOf returns the monitoring cluster of config.yaml holding the .monitoring
+// data of a cluster: the first whose routing rules match it, else the one without rules. nil
+// means the monitoring cluster of the job parameters.
+func MonitoringClusterOf(cluster *types.ClusterData) *config.MonitoringCluster {
+	var defaultCluster *config.MonitoringCluster
+	for i := range config.Global.MonitoringClusters {
+		m := &config.Global.MonitoringClusters[i]
+		if m.IsDefault() {
+			defaultCluster = m
+			continue
+		}
+		if routesTo(m, cluster) {
+			return m
+		}
+	}
+	return defaultCluster
+}
+
+// routesTo reports whether a cluster satisfies every routing rule of a monitoring cluster
+func routesTo(m *config.MonitoringCluster, cluster *types.ClusterData) bool {
+	if len(m.Clusters) > 0 {
+		matched := false
+		for _, pattern := range m.Clusters {
+			if ok, _ := path.Match(pattern, cluster.ClusterName); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(m.Envs) > 0 && !utils.Contains(m.Envs, cluster.Env) {
+		return false
+	}
+	if len(m.Tenants) > 0 && !utils.Contains(m.Tenants, cluster.Tenant) {
+		return false
+	}
+	if len(m.Zones) > 0 && !utils.Contains(m.Zones, cluster.ZoneIdentifier) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// dateIndexToken marks the date of the daily indices in an index pattern, e.g.
+// .monitoring-es-8-mb-{date}
+const dateIndexToken = "{date}"
+
+// dateMathEscaper encodes the characters of date math index names for a URL path, see
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/api-conventions.html#api-date-math-index-names
+var dateMathEscaper = strings.NewReplacer(
+	"<", "%3C", ">", "%3E", "/", "%2F", "{", "%7B", "}", "%7D", "|", "%7C", "+", "%2B", ":", "%3A",
+)
+
+// indexExpression returns the indices a query over the last span searches. Each index of a
+// comma-separated pattern holding {date} becomes the daily indices (UTC) that can hold data of
+// the span, as date math names resolved by Elasticsearch, so older backing indices are not
+// searched; other indices are searched as they are.
+func indexExpression(pattern string, span time.Duration) string {
+	if !strings.Contains(pattern, dateIndexToken) {
+		return pattern
+	}
+
+	// The span may start on the day before the first whole day it covers
+	days := int(span/(24*time.Hour)) + 2
+	indices := make([]string, 0)
+	for _, index := range strings.Split(pattern, ",") {
+		index = strings.TrimSpace(index)
+		if !strings.Contains(index, dateIndexToken) {
+			indices = append(indices, index)
+			continue
+		}
+		for day := 0; day < days; day++ {
+			date := "{now/d}"
+			if day > 0 {
+				date = fmt.Sprintf("{now/d-%dd}", day)
+			}
+			indices = append(indices, "<"+strings.ReplaceAll(index, dateIndexToken, date)+">")
+		}
+	}
+	return strings.Join(indices, ",")
+}
+
+// monitoringSearchURL returns the search URL of a monitoring cluster endpoint for an index
+// expression: the expression replaces the __INDEX__ macro of the endpoint, an endpoint without
+// a path gets /<index>/_search, and an endpoint naming its own index is used as it is. Date
+// math indices are searched with ignore_unavailable, as the newest may not exist yet.
+func monitoringSearchURL(endpoint, index string) string {
+	escaped := dateMathEscaper.Replace(index)
+	switch {
+	case strings.Contains(endpoint, "__INDEX__"):
+		endpoint = strings.ReplaceAll(endpoint, "__INDEX__", escaped)
+	case hasNoPath(endpoint):
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/" + escaped + "/_search"
+	default:
+		return endpoint
+	}
+
+	if strings.Contains(index, "<") {
+		separator := "?"
+		if strings.Contains(endpoint, "?") {
+			separator = "&"
+		}
+		endpoint += separator + "ignore_unavailable=true"
+	}
+	return endpoint
+}
+
+// hasNoPath reports whether an endpoint is the bare URL of a cluster, e.g. https://monitoring-es:9200
+func hasNoPath(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	return err == nil && (u.Path == "" || u.Path == "/") && u.RawQuery == ""
+}
@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"ElasticObservability/pkg/config"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+)
+
+// Query profiles of the monitoring data layouts
+const (
+	profileLegacy = "legacy" // .monitoring-es-* indices written by internal collection or Metricbeat
+	profileAgent  = "agent"  // metrics-* data streams written by Elastic Agent
+)
+
+// monitoringProfile describes where a monitoring data layout keeps the node stats of a cluster.
+// The queries of the monitoring cluster jobs are written against the macros of the profile, so
+// one query serves both layouts.
+type monitoringProfile struct {
+	index                    string // index pattern searched unless the monitoring cluster sets one
+	clusterField             string // __CLUSTER_FIELD__, holds the cluster UUID
+	docTypeField             string // __DOC_TYPE_FIELD__
+	docType                  string // __DOC_TYPE__, value of docTypeField in node stats documents
+	nodeField                string // __NODE_FIELD__, the host name of the node
+	timestampField           string // __TIMESTAMP_FIELD__, when the document was written
+	collectionTimestampField string // __COLLECTION_TIMESTAMP_FIELD__, when the stats were collected
+	docsCountField           string // __DOCS_COUNT_FIELD__, documents on the node
+	writeQueueField          string // __WRITE_QUEUE_FIELD__, the thread pool write queue
+}
+
+// monitoringProfiles are the built-in profiles; the profileFields job parameter overrides their fields
+var monitoringProfiles = map[string]monitoringProfile{
+	profileLegacy: {
+		index:                    ".monitoring-es-*",
+		clusterField:             "cluster_uuid",
+		docTypeField:             "type",
+		docType:                  "node_stats",
+		nodeField:                "source_node.host",
+		timestampField:           "timestamp",
+		collectionTimestampField: "source_node.timestamp",
+		docsCountField:           "node_stats.indices.docs.count",
+		writeQueueField:          "node_stats.thread_pool.write.queue",
+	},
+	profileAgent: {
+		index:                    "metrics-elasticsearch.stack_monitoring.node_stats-*",
+		clusterField:             "elasticsearch.cluster.id",
+		docTypeField:             "data_stream.dataset",
+		docType:                  "elasticsearch.stack_monitoring.node_stats",
+		nodeField:                "elasticsearch.node.name",
+		timestampField:           "@timestamp",
+		collectionTimestampField: "@timestamp",
+		docsCountField:           "elasticsearch.node.stats.indices.docs.count",
+		writeQueueField:          "elasticsearch.node.stats.thread_pool.write.queue.count",
+	},
+}
+
+// monitoringProfileNames returns the names of the built-in profiles, sorted
+func monitoringProfileNames() []string {
+	names := make([]string, 0, len(monitoringProfiles))
+	for name := range monitoringProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupMonitoringProfile checks a profile name; "" is the legacy profile
+func lookupMonitoringProfile(name string) (string, error) {
+	if name == "" {
+		return profileLegacy, nil
+	}
+	if _, ok := monitoringProfiles[name]; !ok {
+		return "", fmt.Errorf("unknown monitoring profile %q, expected one of %s", name, strings.Join(monitoringProfileNames(), ", "))
+	}
+	return name, nil
+}
+
+// loadMonitoringProfiles returns the built-in profiles with the fields overridden by the
+// profileFields job parameter, a map of profile name to fields, e.g.
+// {agent: {nodeField: host.name}}
+func loadMonitoringProfiles(overrides map[string]interface{}) (map[string]*monitoringProfile, error) {
+	profiles := make(map[string]*monitoringProfile, len(monitoringProfiles))
+	for name, builtIn := range monitoringProfiles {
+		profile := builtIn
+		profiles[name] = &profile
+	}
+
+	for name, value := range overrides {
+		profile, ok := profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("profileFields: unknown monitoring profile %q, expected one of %s",
+				name, strings.Join(monitoringProfileNames(), ", "))
+		}
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("profileFields.%s must be a map of field names", name)
+		}
+		targets := map[string]*string{
+			"index":                    &profile.index,
+			"clusterField":             &profile.clusterField,
+			"docTypeField":             &profile.docTypeField,
+			"docType":                  &profile.docType,
+			"nodeField":                &profile.nodeField,
+			"timestampField":           &profile.timestampField,
+			"collectionTimestampField": &profile.collectionTimestampField,
+			"docsCountField":           &profile.docsCountField,
+			"writeQueueField":          &profile.writeQueueField,
+		}
+		f := jobparams.New(fields)
+		for field := range fields {
+			target, ok := targets[field]
+			if !ok {
+				return nil, fmt.Errorf("profileFields.%s: unknown field %q", name, field)
+			}
+			*target = f.String(field, *target)
+		}
+		if err := f.Err(); err != nil {
+			return nil, fmt.Errorf("profileFields.%s: %w", name, err)
+		}
+	}
+	return profiles, nil
+}
+
+// expand substitutes the field macros of the profile in a query or a JSON path
+func (mp *monitoringProfile) expand(s string) string {
+	return strings.NewReplacer(
+		"__CLUSTER_FIELD__", mp.clusterField,
+		"__DOC_TYPE_FIELD__", mp.docTypeField,
+		"__DOC_TYPE__", mp.docType,
+		"__NODE_FIELD__", mp.nodeField,
+		"__TIMESTAMP_FIELD__", mp.timestampField,
+		"__COLLECTION_TIMESTAMP_FIELD__", mp.collectionTimestampField,
+		"__DOCS_COUNT_FIELD__", mp.docsCountField,
+		"__WRITE_QUEUE_FIELD__", mp.writeQueueField,
+	).Replace(s)
+}
+
+// monitoringProfileOf returns the profile of the monitoring data of a cluster: its own
+// monitoringProfile from the inventory, else the profile of the monitoring cluster holding its
+// data, else defaultProfile (the profile job parameter)
+func monitoringProfileOf(cluster *types.ClusterData, defaultProfile string) (string, error) {
+	if cluster.MonitoringProfile != "" {
+		return lookupMonitoringProfile(cluster.MonitoringProfile)
+	}
+	if m := MonitoringClusterOf(cluster); m != nil && m.Profile != "" {
+		return lookupMonitoringProfile(m.Profile)
+	}
+	return lookupMonitoringProfile(defaultProfile)
+}
+
+// MonitoringClusterProfile returns the query profile of the data of a monitoring cluster
+func MonitoringClusterProfile(m *config.MonitoringCluster) string {
+	if m.Profile == "" {
+		return profileLegacy
+	}
+	return m.Profile
+}
+
+// checkMonitoringProfiles checks the profile job parameter and the profiles of the configured
+// monitoring clusters
+func checkMonitoringProfiles(defaultProfile string) error {
+	if _, err := lookupMonitoringProfile(defaultProfile); err != nil {
+		return fmt.Errorf("profile: %w", err)
+	}
+	for _, m := range config.Global.MonitoringClusters {
+		if _, err := lookupMonitoringProfile(m.Profile); err != nil {
+			return fmt.Errorf("monitoringClusters.%s: %w", m.Name, err)
+		}
+	}
+	return nil
+}
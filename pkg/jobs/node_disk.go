@@ -0,0 +1,343 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/notify"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// diskWatermarkSource is the event store source of disk watermark breaches
+const diskWatermarkSource = "diskWatermark"
+
+// Elasticsearch's default disk watermarks
+var defaultDiskWatermarks = types.DiskWatermarks{Low: "85%", High: "90%", FloodStage: "95%"}
+
+// nodesFSStats is the part of the _nodes/stats/fs response that is used
+type nodesFSStats struct {
+	Nodes map[string]struct {
+		Host  string   `json:"host"`
+		Name  string   `json:"name"`
+		Roles []string `json:"roles"`
+		FS    struct {
+			Total struct {
+				TotalInBytes     uint64 `json:"total_in_bytes"`
+				AvailableInBytes uint64 `json:"available_in_bytes"`
+			} `json:"total"`
+		} `json:"fs"`
+	} `json:"nodes"`
+}
+
+// diskWatermarkSettings is the part of the _cluster/settings response that is used; each
+// level holds the flat settings of persistent, transient and defaults
+type diskWatermarkSettings map[string]map[string]interface{}
+
+// diskWatermark is a parsed watermark: either a used-disk percentage or an amount of free disk
+type diskWatermark struct {
+	usedPercent float64 // breached when the used percentage is above, 0 = not a percentage
+	freeBytes   uint64  // breached when less disk is free
+}
+
+// breached reports whether a node's disk is beyond the watermark
+func (w diskWatermark) breached(point types.NodeDiskPoint) bool {
+	if w.usedPercent > 0 {
+		return point.UsedPercent > w.usedPercent
+	}
+	return w.freeBytes > 0 && point.AvailableBytes < w.freeBytes
+}
+
+// parseDiskWatermark parses "90%", "0.9" or an absolute amount of free disk such as "50gb"
+func parseDiskWatermark(value string) (diskWatermark, error) {
+	value = strings.TrimSpace(value)
+	if pct, ok := strings.CutSuffix(value, "%"); ok {
+		f, err := strconv.ParseFloat(pct, 64)
+		if err != nil || f <= 0 || f > 100 {
+			return diskWatermark{}, fmt.Errorf("invalid watermark %q", value)
+		}
+		return diskWatermark{usedPercent: f}, nil
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && f > 0 && f <= 1 {
+		return diskWatermark{usedPercent: f * 100}, nil
+	}
+	bytes, err := utils.ParseStorageSize(value)
+	if err != nil || bytes == 0 {
+		return diskWatermark{}, fmt.Errorf("invalid watermark %q", value)
+	}
+	return diskWatermark{freeBytes: bytes}, nil
+}
+
+// GetNodeDiskUsage samples the disk usage of every node with _nodes/stats/fs and compares it
+// with the cluster's disk watermarks (read from the cluster settings). Each node keeps the
+// latest historySize samples, so nodes that repeatedly approach the watermarks stand out.
+// Nodes above the high or flood-stage watermark fire events in the event store.
+func GetNodeDiskUsage(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("getNodeDiskUsage", "Starting node disk usage collection")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("getNodeDiskUsage", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	historySize := p.IntInRange("historySize", 288, 2, 2016)
+	cacheTTL := p.Duration("cacheTTL", 0)
+	notifyOwners := p.Bool("notifyOwners", false)
+	if err := checkParams("getNodeDiskUsage", p); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var mu sync.Mutex
+	collected := make(map[string]bool)
+
+	_, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+		query := queryOptions{JobName: "getNodeDiskUsage", TTL: cacheTTL}
+
+		watermarks := defaultDiskWatermarks
+		var settings diskWatermarkSettings
+		if err := getClusterJSON(ctx, cluster, "/_cluster/settings?include_defaults=true&flat_settings=true", query, &settings); err != nil {
+			logger.JobWarn("getNodeDiskUsage", "Cluster %s: failed to read watermarks, using defaults: %v", clusterName, err)
+		} else {
+			watermarks = clusterDiskWatermarks(settings)
+		}
+		low, lowErr := parseDiskWatermark(watermarks.Low)
+		high, highErr := parseDiskWatermark(watermarks.High)
+		flood, floodErr := parseDiskWatermark(watermarks.FloodStage)
+		if lowErr != nil || highErr != nil || floodErr != nil {
+			return fmt.Errorf("unsupported disk watermarks %+v", watermarks)
+		}
+
+		var stats nodesFSStats
+		if err := getClusterJSON(ctx, cluster, "/_nodes/stats/fs", query, &stats); err != nil {
+			return fmt.Errorf("failed to fetch node fs stats: %w", err)
+		}
+
+		nowMs := utils.TimeNowMillis()
+		points := make(map[string]types.NodeDiskPoint, len(stats.Nodes))
+		roles := make(map[string][]string, len(stats.Nodes))
+		breaching := 0
+		hosts := newHostResolver(cluster)
+		for _, node := range stats.Nodes {
+			total := node.FS.Total
+			if total.TotalInBytes == 0 {
+				continue // e.g. nodes without data paths
+			}
+			hostName := hosts.nodeHostName(node.Host, node.Name)
+			point := types.NodeDiskPoint{
+				TimeStamp:      nowMs,
+				TotalBytes:     total.TotalInBytes,
+				AvailableBytes: total.AvailableInBytes,
+				UsedPercent:    float64(total.TotalInBytes-total.AvailableInBytes) * 100 / float64(total.TotalInBytes),
+			}
+			switch {
+			case flood.breached(point):
+				point.Level = types.DiskLevelFloodStage
+			case high.breached(point):
+				point.Level = types.DiskLevelHigh
+			case low.breached(point):
+				point.Level = types.DiskLevelLow
+			}
+			if point.Level == types.DiskLevelHigh || point.Level == types.DiskLevelFloodStage {
+				breaching++
+			}
+			points[hostName] = point
+			roles[hostName] = node.Roles
+		}
+
+		types.AddDiskUsage(clusterName, nowMs, watermarks, roles, points, historySize)
+		recordDiskMetrics(clusterName, points)
+
+		mu.Lock()
+		collected[clusterName] = true
+		mu.Unlock()
+
+		logger.JobInfo("getNodeDiskUsage", "Cluster %s: %d nodes sampled, %d above the high watermark",
+			clusterName, len(points), breaching)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Breaches of clusters that could not be sampled this time are kept as they are
+	observed := make([]events.Observation, 0)
+	for clusterName := range collected {
+		usage, ok := types.GetDiskUsage(clusterName)
+		if !ok {
+			continue
+		}
+		for hostName, history := range usage.Nodes {
+			point := history.Points.At(0)
+			if point.Level != types.DiskLevelHigh && point.Level != types.DiskLevelFloodStage {
+				continue
+			}
+			observed = append(observed, diskWatermarkObservation(clusterName, hostName, usage.Watermarks, point))
+		}
+	}
+	breaching := len(observed)
+	observed = append(observed, carriedOverEvents(diskWatermarkSource, collected)...)
+
+	fired, resolved, err := events.Sync(diskWatermarkSource, observed, now)
+	if err != nil {
+		logger.JobWarn("getNodeDiskUsage", "Failed to persist events: %v", err)
+	}
+
+	firedByCluster := make(map[string][]events.Event)
+	for _, event := range fired {
+		logger.JobWarn("getNodeDiskUsage", "Disk watermark breached: cluster=%s host=%s level=%s used=%s%% (event %s)",
+			event.Cluster(), event.Labels["host"], event.Annotations["level"], event.Annotations["usedPercent"], event.ID)
+		firedByCluster[event.Cluster()] = append(firedByCluster[event.Cluster()], event)
+	}
+	for _, event := range resolved {
+		logger.JobInfo("getNodeDiskUsage", "Disk watermark breach resolved: cluster=%s host=%s (event %s)",
+			event.Cluster(), event.Labels["host"], event.ID)
+	}
+
+	if notifyOwners {
+		clusterNames := make([]string, 0, len(firedByCluster))
+		for clusterName := range firedByCluster {
+			clusterNames = append(clusterNames, clusterName)
+		}
+		sort.Strings(clusterNames)
+		for _, clusterName := range clusterNames {
+			notifyDiskWatermarks(ctx, clusterName, firedByCluster[clusterName])
+		}
+	}
+
+	logger.JobInfo("getNodeDiskUsage", "Completed: %d clusters sampled, %d nodes above the high watermark (%d new, %d resolved)",
+		len(collected), breaching, len(fired), len(resolved))
+	return nil
+}
+
+// WatermarkReserve returns the free disk a node with totalBytes of disk keeps below a disk
+// watermark ("90%", "0.9" or an amount of free disk such as "50gb")
+func WatermarkReserve(watermark string, totalBytes uint64) (uint64, error) {
+	w, err := parseDiskWatermark(watermark)
+	if err != nil {
+		return 0, err
+	}
+	if w.usedPercent > 0 {
+		return uint64(float64(totalBytes) * (100 - w.usedPercent) / 100), nil
+	}
+	return w.freeBytes, nil
+}
+
+// clusterDiskWatermarks picks the effective watermarks from flat cluster settings:
+// transient over persistent over defaults
+func clusterDiskWatermarks(settings diskWatermarkSettings) types.DiskWatermarks {
+	lookup := func(name, fallback string) string {
+		for _, level := range []string{"transient", "persistent", "defaults"} {
+			if value, ok := settings[level]["cluster.routing.allocation.disk.watermark."+name].(string); ok && value != "" {
+				return value
+			}
+		}
+		return fallback
+	}
+	return types.DiskWatermarks{
+		Low:        lookup("low", defaultDiskWatermarks.Low),
+		High:       lookup("high", defaultDiskWatermarks.High),
+		FloodStage: lookup("flood_stage", defaultDiskWatermarks.FloodStage),
+	}
+}
+
+// recordDiskMetrics exports the latest disk usage of the nodes of a cluster
+func recordDiskMetrics(clusterName string, points map[string]types.NodeDiskPoint) {
+	clusterLabel := prometheus.Labels{"cluster": clusterName}
+	metrics.NodeDiskUsedPercent.DeletePartialMatch(clusterLabel)
+	metrics.NodeDiskWatermarkBreached.DeletePartialMatch(clusterLabel)
+
+	for hostName, point := range points {
+		metrics.NodeDiskUsedPercent.WithLabelValues(clusterName, hostName).Set(point.UsedPercent)
+		for _, level := range []string{types.DiskLevelLow, types.DiskLevelHigh, types.DiskLevelFloodStage} {
+			breached := 0.0
+			if diskLevelRank(point.Level) >= diskLevelRank(level) {
+				breached = 1
+			}
+			metrics.NodeDiskWatermarkBreached.WithLabelValues(clusterName, hostName, level).Set(breached)
+		}
+	}
+}
+
+// diskLevelRank orders the watermark levels
+func diskLevelRank(level string) int {
+	switch level {
+	case types.DiskLevelLow:
+		return 1
+	case types.DiskLevelHigh:
+		return 2
+	case types.DiskLevelFloodStage:
+		return 3
+	}
+	return 0
+}
+
+// diskWatermarkObservation is the event store observation of a node above the high or
+// flood-stage watermark; flood stage is critical, as Elasticsearch blocks writes
+func diskWatermarkObservation(clusterName, hostName string, watermarks types.DiskWatermarks, point types.NodeDiskPoint) events.Observation {
+	severity, watermark := "warning", watermarks.High
+	if point.Level == types.DiskLevelFloodStage {
+		severity, watermark = "critical", watermarks.FloodStage
+	}
+	return events.Observation{
+		Name:     "DiskWatermark",
+		Severity: severity,
+		Labels:   map[string]string{"cluster": clusterName, "host": hostName},
+		Annotations: map[string]string{
+			"level":          point.Level,
+			"watermark":      watermark,
+			"usedPercent":    strconv.FormatFloat(point.UsedPercent, 'f', 1, 64),
+			"availableBytes": strconv.FormatUint(point.AvailableBytes, 10),
+		},
+		StartsAt: point.TimeStamp,
+	}
+}
+
+// notifyDiskWatermarks sends the owner of a cluster one alert for its new breaches
+func notifyDiskWatermarks(ctx context.Context, clusterName string, clusterEvents []events.Event) {
+	// Breaches detected during maintenance are recorded but not alerted on
+	alerting := clusterEvents[:0:0]
+	severity := "warning"
+	for _, event := range clusterEvents {
+		if !event.Suppressed {
+			alerting = append(alerting, event)
+			if event.Severity == "critical" {
+				severity = "critical"
+			}
+		}
+	}
+	if len(alerting) == 0 {
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d nodes of cluster %s are above a disk watermark:\n", len(alerting), clusterName)
+	for _, event := range alerting {
+		fmt.Fprintf(&text, "  %s: %s%% used, above the %s watermark (%s) (event %s)\n",
+			event.Labels["host"], event.Annotations["usedPercent"], event.Annotations["level"],
+			event.Annotations["watermark"], event.ID)
+		writeEventLinks(&text, event.Annotations)
+	}
+
+	err := notify.NotifyCluster(ctx, clusterName, notify.Message{
+		Subject:  fmt.Sprintf("Disk watermarks breached on cluster %s", clusterName),
+		Text:     text.String(),
+		Severity: severity,
+	})
+	if err != nil {
+		logger.JobWarn("getNodeDiskUsage", "Failed to notify owner of cluster %s: %v", clusterName, err)
+	}
+}
@@ -0,0 +1,138 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gcCollectorStats is a garbage collector of the _nodes/stats/jvm response
+type gcCollectorStats struct {
+	CollectionCount        uint64 `json:"collection_count"`
+	CollectionTimeInMillis uint64 `json:"collection_time_in_millis"`
+}
+
+// nodesJVMStats is the part of the _nodes/stats/jvm response that is used
+type nodesJVMStats struct {
+	Nodes map[string]struct {
+		Host  string   `json:"host"`
+		Name  string   `json:"name"`
+		Roles []string `json:"roles"`
+		JVM   struct {
+			Mem struct {
+				HeapUsedInBytes uint64  `json:"heap_used_in_bytes"`
+				HeapUsedPercent float64 `json:"heap_used_percent"`
+				HeapMaxInBytes  uint64  `json:"heap_max_in_bytes"`
+			} `json:"mem"`
+			GC struct {
+				Collectors struct {
+					Young gcCollectorStats `json:"young"`
+					Old   gcCollectorStats `json:"old"`
+				} `json:"collectors"`
+			} `json:"gc"`
+		} `json:"jvm"`
+	} `json:"nodes"`
+}
+
+// GetNodeJVMStats samples the JVM heap usage and the young and old generation garbage
+// collections of every node with _nodes/stats/jvm. Each node keeps the latest historySize
+// samples; a node that does not report gets a missing sample, which checkForHeapPressure
+// treats according to its considerMissingDataPoint parameter.
+func GetNodeJVMStats(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("getNodeJVMStats", "Starting node JVM stats collection")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("getNodeJVMStats", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	historySize := p.IntInRange("historySize", 60, 2, 2016)
+	cacheTTL := p.Duration("cacheTTL", 0)
+	if err := checkParams("getNodeJVMStats", p); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	sampledNodes := 0
+
+	summary, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		var stats nodesJVMStats
+		query := queryOptions{JobName: "getNodeJVMStats", TTL: cacheTTL}
+		if err := getClusterJSON(ctx, cluster, "/_nodes/stats/jvm", query, &stats); err != nil {
+			return fmt.Errorf("failed to fetch node jvm stats: %w", err)
+		}
+
+		nowMs := utils.TimeNowMillis()
+		points := make(map[string]types.NodeJVMPoint, len(stats.Nodes))
+		roles := make(map[string][]string, len(stats.Nodes))
+		hosts := newHostResolver(cluster)
+		for _, node := range stats.Nodes {
+			hostName := hosts.nodeHostName(node.Host, node.Name)
+			collectors := node.JVM.GC.Collectors
+			points[hostName] = types.NodeJVMPoint{
+				TimeStamp:       nowMs,
+				HeapUsedPercent: node.JVM.Mem.HeapUsedPercent,
+				HeapUsedBytes:   node.JVM.Mem.HeapUsedInBytes,
+				HeapMaxBytes:    node.JVM.Mem.HeapMaxInBytes,
+				OldGCCount:      collectors.Old.CollectionCount,
+				OldGCTimeMs:     collectors.Old.CollectionTimeInMillis,
+				YoungGCCount:    collectors.Young.CollectionCount,
+				YoungGCTimeMs:   collectors.Young.CollectionTimeInMillis,
+				Exists:          true,
+			}
+			roles[hostName] = node.Roles
+		}
+
+		types.AddJVMStats(clusterName, nowMs, roles, points, historySize)
+		recordJVMMetrics(clusterName, points)
+
+		mu.Lock()
+		sampledNodes += len(points)
+		mu.Unlock()
+
+		logger.JobInfo("getNodeJVMStats", "Cluster %s: %d nodes sampled", clusterName, len(points))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.JobInfo("getNodeJVMStats", "Completed: %d clusters, %d nodes sampled", summary.Succeeded, sampledNodes)
+	return nil
+}
+
+// recordJVMMetrics exports the latest JVM sample of the nodes of a cluster
+func recordJVMMetrics(clusterName string, points map[string]types.NodeJVMPoint) {
+	clusterLabel := prometheus.Labels{"cluster": clusterName}
+	metrics.NodeHeapUsedPercent.DeletePartialMatch(clusterLabel)
+	metrics.NodeGCCollections.DeletePartialMatch(clusterLabel)
+	metrics.NodeGCTimeSeconds.DeletePartialMatch(clusterLabel)
+
+	for hostName, point := range points {
+		metrics.NodeHeapUsedPercent.WithLabelValues(clusterName, hostName).Set(point.HeapUsedPercent)
+		metrics.NodeGCCollections.WithLabelValues(clusterName, hostName, "young").Set(float64(point.YoungGCCount))
+		metrics.NodeGCCollections.WithLabelValues(clusterName, hostName, "old").Set(float64(point.OldGCCount))
+		metrics.NodeGCTimeSeconds.WithLabelValues(clusterName, hostName, "young").Set(float64(point.YoungGCTimeMs) / 1000)
+		metrics.NodeGCTimeSeconds.WithLabelValues(clusterName, hostName, "old").Set(float64(point.OldGCTimeMs) / 1000)
+	}
+}
+
+// gcDelta is the growth of a cumulative GC counter between two samples. A counter that went
+// down was reset by a node restart, so the new value is all growth.
+func gcDelta(previous, current uint64) uint64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// nodesSegmentStats is the part of the _nodes/stats/indices response that is used
+type nodesSegmentStats struct {
+	Nodes map[string]struct {
+		Host    string   `json:"host"`
+		Name    string   `json:"name"`
+		Roles   []string `json:"roles"`
+		Indices struct {
+			Segments struct {
+				Count uint64 `json:"count"`
+			} `json:"segments"`
+			Merges struct {
+				Current                    uint64 `json:"current"`
+				CurrentDocs                uint64 `json:"current_docs"`
+				CurrentSizeInBytes         uint64 `json:"current_size_in_bytes"`
+				TotalTimeInMillis          uint64 `json:"total_time_in_millis"`
+				TotalThrottledTimeInMillis uint64 `json:"total_throttled_time_in_millis"`
+			} `json:"merges"`
+			Refresh struct {
+				Total             uint64 `json:"total"`
+				TotalTimeInMillis uint64 `json:"total_time_in_millis"`
+			} `json:"refresh"`
+		} `json:"indices"`
+	} `json:"nodes"`
+}
+
+// GetNodeSegmentStats samples the segment count, the running merges and the cumulative merge
+// and refresh times of every node with _nodes/stats/indices. Each node keeps the latest
+// historySize samples, which /api/segments/{clusterName}/{hostName} lines up with the
+// thread pool write queue of the host to show why a write queue spiked.
+func GetNodeSegmentStats(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("getNodeSegmentStats", "Starting node segment stats collection")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("getNodeSegmentStats", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	historySize := p.IntInRange("historySize", 60, 2, 2016)
+	cacheTTL := p.Duration("cacheTTL", 0)
+	if err := checkParams("getNodeSegmentStats", p); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	sampledNodes := 0
+
+	summary, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		var stats nodesSegmentStats
+		query := queryOptions{JobName: "getNodeSegmentStats", TTL: cacheTTL}
+		if err := getClusterJSON(ctx, cluster, "/_nodes/stats/indices/segments,merge,refresh", query, &stats); err != nil {
+			return fmt.Errorf("failed to fetch node indices stats: %w", err)
+		}
+
+		nowMs := utils.TimeNowMillis()
+		points := make(map[string]types.NodeSegmentPoint, len(stats.Nodes))
+		roles := make(map[string][]string, len(stats.Nodes))
+		hosts := newHostResolver(cluster)
+		for _, node := range stats.Nodes {
+			hostName := hosts.nodeHostName(node.Host, node.Name)
+			indices := node.Indices
+			points[hostName] = types.NodeSegmentPoint{
+				TimeStamp:              nowMs,
+				Segments:               indices.Segments.Count,
+				MergesCurrent:          indices.Merges.Current,
+				MergesCurrentDocs:      indices.Merges.CurrentDocs,
+				MergesCurrentSizeBytes: indices.Merges.CurrentSizeInBytes,
+				MergeTimeMs:            indices.Merges.TotalTimeInMillis,
+				MergeThrottledTimeMs:   indices.Merges.TotalThrottledTimeInMillis,
+				Refreshes:              indices.Refresh.Total,
+				RefreshTimeMs:          indices.Refresh.TotalTimeInMillis,
+			}
+			roles[hostName] = node.Roles
+		}
+
+		types.AddSegmentStats(clusterName, nowMs, roles, points, historySize)
+		recordSegmentMetrics(clusterName, points)
+
+		mu.Lock()
+		sampledNodes += len(points)
+		mu.Unlock()
+
+		logger.JobInfo("getNodeSegmentStats", "Cluster %s: %d nodes sampled", clusterName, len(points))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.JobInfo("getNodeSegmentStats", "Completed: %d clusters, %d nodes sampled", summary.Succeeded, sampledNodes)
+	return nil
+}
+
+// recordSegmentMetrics exports the latest segments sample of the nodes of a cluster
+func recordSegmentMetrics(clusterName string, points map[string]types.NodeSegmentPoint) {
+	clusterLabel := prometheus.Labels{"cluster": clusterName}
+	metrics.NodeSegments.DeletePartialMatch(clusterLabel)
+	metrics.NodeMergesCurrent.DeletePartialMatch(clusterLabel)
+
+	for hostName, point := range points {
+		metrics.NodeSegments.WithLabelValues(clusterName, hostName).Set(float64(point.Segments))
+		metrics.NodeMergesCurrent.WithLabelValues(clusterName, hostName).Set(float64(point.MergesCurrent))
+	}
+}
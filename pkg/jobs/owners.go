@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/notify"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// LoadOwners loads the owner directory (owner name -> email/Slack/webhook) from a YAML,
+// JSON or CSV file. Re-running the job replaces the directory.
+func LoadOwners(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("loadOwners", "Starting owner directory load")
+
+	p := jobparams.New(params)
+	fileName := p.RequiredString("file")
+	if err := p.Err(); err != nil {
+		return err
+	}
+
+	owners, err := notify.LoadOwners(fileName)
+	if err != nil {
+		return err
+	}
+	notify.SetOwners(owners)
+
+	// Clusters that cannot be routed are worth knowing about before an alert is missed
+	for ownerName, clusters := range notify.ClustersByOwner() {
+		if ownerName == "" {
+			logger.JobWarn("loadOwners", "No owner (and no default owner) for %d clusters: %s",
+				len(clusters), strings.Join(clusters, ", "))
+		}
+	}
+
+	logger.JobInfo("loadOwners", "Loaded %d owners from %s", len(owners), fileName)
+	return nil
+}
+
+// SendOwnerReports sends every owner a report of its clusters over the last period:
+// clusters whose collections are failing or stale and the write pressure events detected
+func SendOwnerReports(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("sendOwnerReports", "Starting owner reports")
+
+	p := jobparams.New(params)
+	period := p.Duration("period", 7*24*time.Hour)
+	periodLabel := p.String("period", "7d")
+	dryRun := p.Bool("dryRun", false)
+	if err := p.Err(); err != nil {
+		return err
+	}
+
+	since := utils.TimeNowMillis() - period.Milliseconds()
+	sent := 0
+	var failed []string
+
+	for ownerName, clusters := range notify.ClustersByOwner() {
+		if ownerName == "" {
+			continue
+		}
+		owner, ok := notify.GetOwner(ownerName)
+		if !ok {
+			continue
+		}
+
+		msg := buildOwnerReport(owner.Name, clusters, since, periodLabel)
+		if dryRun {
+			logger.JobInfo("sendOwnerReports", "Report for %s (dry run):\n%s", owner.Name, msg.Text)
+			continue
+		}
+		if err := notify.NotifyOwner(ctx, owner, msg); err != nil {
+			logger.JobError("sendOwnerReports", "%v", err)
+			failed = append(failed, owner.Name)
+			continue
+		}
+		sent++
+	}
+
+	logger.JobInfo("sendOwnerReports", "Completed: %d reports sent, %d failed", sent, len(failed))
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to send reports to: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// buildOwnerReport summarises the collection health and write pressure of an owner's clusters
+func buildOwnerReport(ownerName string, clusters []string, since int64, periodLabel string) notify.Message {
+	owned := make(map[string]bool, len(clusters))
+	for _, clusterName := range clusters {
+		owned[clusterName] = true
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Clusters owned by %s: %d\n", ownerName, len(clusters))
+
+	// Collections that are failing or have not succeeded within the period
+	var problems []string
+	for _, status := range types.SnapshotCollectionStatus() {
+		if !owned[status.ClusterName] {
+			continue
+		}
+		switch {
+		case status.ConsecutiveFailures > 0:
+			problems = append(problems, fmt.Sprintf("  %s / %s: %d failures in a row, last error: %s",
+				status.ClusterName, status.JobName, status.ConsecutiveFailures, status.LastError))
+		case status.LastSuccess < since:
+			problems = append(problems, fmt.Sprintf("  %s / %s: no successful collection in the period",
+				status.ClusterName, status.JobName))
+		}
+	}
+	fmt.Fprintf(&text, "\nCollection problems: %d\n", len(problems))
+	for _, line := range problems {
+		text.WriteString(line + "\n")
+	}
+
+	// Write pressure events per cluster
+	eventsByCluster := make(map[string]int)
+	suppressedByCluster := make(map[string]int)
+	for _, event := range events.Query(events.Filter{Source: writePressureSource, From: since}) {
+		if owned[event.Cluster()] {
+			eventsByCluster[event.Cluster()]++
+			if event.Suppressed {
+				suppressedByCluster[event.Cluster()]++
+			}
+		}
+	}
+
+	fmt.Fprintf(&text, "\nWrite pressure events: %d clusters affected\n", len(eventsByCluster))
+	for _, clusterName := range clusters {
+		if count := eventsByCluster[clusterName]; count > 0 {
+			fmt.Fprintf(&text, "  %s: %d events", clusterName, count)
+			if suppressed := suppressedByCluster[clusterName]; suppressed > 0 {
+				fmt.Fprintf(&text, " (%d during maintenance)", suppressed)
+			}
+			text.WriteString("\n")
+		}
+	}
+
+	return notify.Message{
+		Subject:  fmt.Sprintf("ElasticObservability report for %s (last %s)", ownerName, periodLabel),
+		Text:     text.String(),
+		Severity: "info",
+		Clusters: clusters,
+	}
+}
@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// RunSummary summarises a parallel run over clusters (or any other items)
+type RunSummary struct {
+	Succeeded int
+	Failed    map[string]error // item -> error
+	Skipped   int              // items not started because the context was cancelled
+}
+
+// Err aggregates the per-item errors (sorted by item) into one error, or nil
+func (r *RunSummary) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+
+	errs := make([]error, 0, len(r.Failed))
+	for _, item := range r.FailedItems() {
+		errs = append(errs, fmt.Errorf("%s: %w", item, r.Failed[item]))
+	}
+	return errors.Join(errs...)
+}
+
+// FailedItems returns the failed items in sorted order
+func (r *RunSummary) FailedItems() []string {
+	items := make([]string, 0, len(r.Failed))
+	for item := range r.Failed {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return items
+}
+
+// runParallel calls fn for every item with at most limit calls in flight. A failing item does
+// not stop the others; once ctx is cancelled no new items are started and running calls see
+// the cancelled ctx. The returned error is ctx.Err() if the run was cut short, nil otherwise.
+func runParallel(ctx context.Context, items []string, limit int, fn func(ctx context.Context, item string) error) (*RunSummary, error) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	result := &RunSummary{Failed: make(map[string]error)}
+	var mu sync.Mutex
+
+	var g errgroup.Group
+	g.SetLimit(limit)
+
+	for _, item := range items {
+		if ctx.Err() != nil {
+			result.Skipped++
+			continue
+		}
+
+		// Blocks until a slot is free
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				mu.Lock()
+				result.Skipped++
+				mu.Unlock()
+				return nil
+			}
+
+			err := fn(ctx, item)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Failed[item] = err
+			} else {
+				result.Succeeded++
+			}
+			return nil
+		})
+	}
+
+	_ = g.Wait() // fn errors are collected in result, not returned to the group
+	return result, ctx.Err()
+}
@@ -0,0 +1,255 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/utils"
+)
+
+// Directories pruneArtifacts knows by name; other directories of its directories parameter
+// need a path
+const (
+	artifactsProcessedOneTime = "processedOneTime" // processed one-time job files under config_dir
+	artifactsOutDir           = "outDir"           // out_dir, where the output destination local writes
+)
+
+// artifactRetention is the retention of a directory pruned by pruneArtifacts. A file is kept
+// while it is younger than maxAge and among the newest maxFiles files totalling at most
+// maxSize bytes; a zero limit does not apply.
+type artifactRetention struct {
+	name     string
+	path     string
+	maxAge   time.Duration
+	maxFiles int
+	maxSize  uint64
+}
+
+// artifactFile is a file of a pruned directory
+type artifactFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// PruneArtifacts deletes the files beyond the retention of each directory of its directories
+// parameter, a map of directory name to retention, e.g.
+// {processedOneTime: {maxAge: 30d}, outDir: {maxAge: 14d, maxSize: 10gb}}. Directories are
+// searched recursively; files being written (*.tmp) are left alone. With dryRun the files
+// are only logged.
+func PruneArtifacts(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("pruneArtifacts", "Starting artifact pruning")
+
+	p := jobparams.New(params)
+	dryRun := p.Bool("dryRun", false)
+	retentions, err := artifactRetentions(p)
+	if err != nil {
+		return err
+	}
+	if err := checkParams("pruneArtifacts", p); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	pruned := 0
+	var reclaimed int64
+	var failed []string
+	for _, retention := range retentions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, bytes, err := retention.prune(dryRun, now)
+		pruned += n
+		reclaimed += bytes
+		if err != nil {
+			logger.JobError("pruneArtifacts", "Directory %s (%s): %v", retention.name, retention.path, err)
+			failed = append(failed, retention.name)
+		}
+	}
+
+	if dryRun {
+		logger.JobInfo("pruneArtifacts", "Completed (dry run): %d files of %d bytes would be deleted", pruned, reclaimed)
+	} else {
+		logger.JobInfo("pruneArtifacts", "Completed: %d files deleted, %d bytes reclaimed", pruned, reclaimed)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to prune: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// ValidatePruneArtifactsParams checks the retentions of the directories when the job is loaded
+func ValidatePruneArtifactsParams(params map[string]interface{}) error {
+	_, err := artifactRetentions(jobparams.New(params))
+	return err
+}
+
+// artifactRetentions reads the directories parameter, ordered by directory name. Each
+// directory has a path (default for processedOneTime and outDir) and at least one of maxAge,
+// maxFiles and maxSize.
+func artifactRetentions(p *jobparams.Reader) ([]*artifactRetention, error) {
+	configured := p.RequiredMap("directories")
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+
+	retentions := make([]*artifactRetention, 0, len(configured))
+	for name, value := range configured {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("directories.%s must be a map of path, maxAge, maxFiles and maxSize", name)
+		}
+		for field := range fields {
+			switch field {
+			case "path", "maxAge", "maxFiles", "maxSize":
+			default:
+				return nil, fmt.Errorf("directories.%s: unknown field %q", name, field)
+			}
+		}
+
+		f := jobparams.New(fields)
+		retention := &artifactRetention{
+			name:     name,
+			path:     f.String("path", defaultArtifactsPath(name)),
+			maxAge:   f.Duration("maxAge", 0),
+			maxFiles: f.Int("maxFiles", 0),
+		}
+		maxSize := f.String("maxSize", "")
+		if err := f.Err(); err != nil {
+			return nil, fmt.Errorf("directories.%s: %w", name, err)
+		}
+		size, err := utils.ParseStorageSize(maxSize)
+		if err != nil {
+			return nil, fmt.Errorf("directories.%s: maxSize: %w", name, err)
+		}
+		retention.maxSize = size
+
+		switch {
+		case retention.path == "":
+			return nil, fmt.Errorf("directories.%s: path is required", name)
+		case retention.maxAge < 0 || retention.maxFiles < 0:
+			return nil, fmt.Errorf("directories.%s: maxAge and maxFiles must not be negative", name)
+		case retention.maxAge == 0 && retention.maxFiles == 0 && retention.maxSize == 0:
+			return nil, fmt.Errorf("directories.%s: at least one of maxAge, maxFiles and maxSize is required", name)
+		}
+		retentions = append(retentions, retention)
+	}
+
+	sort.Slice(retentions, func(i, j int) bool { return retentions[i].name < retentions[j].name })
+	return retentions, nil
+}
+
+// defaultArtifactsPath returns the path of a directory pruneArtifacts knows by name, else ""
+func defaultArtifactsPath(name string) string {
+	if config.Global == nil {
+		return ""
+	}
+	switch name {
+	case artifactsProcessedOneTime:
+		return filepath.Join(config.Global.ConfigDir, "processedOneTime")
+	case artifactsOutDir:
+		return config.Global.OutDir
+	}
+	return ""
+}
+
+// prune deletes the files of the directory beyond its retention, newest kept first, and
+// returns how many files were deleted and their bytes (those that would be with dryRun). A
+// directory that does not exist has nothing to prune.
+func (ar *artifactRetention) prune(dryRun bool, now time.Time) (int, int64, error) {
+	files, err := listArtifactFiles(ar.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.After(files[j].modTime)
+		}
+		return files[i].path > files[j].path
+	})
+
+	pruned, kept := 0, 0
+	var reclaimed, keptBytes int64
+	full := false // the newest files kept reached maxSize, the older ones go
+	var errs []string
+	for _, file := range files {
+		expired := ar.maxAge > 0 && now.Sub(file.modTime) > ar.maxAge
+		excess := ar.maxFiles > 0 && kept >= ar.maxFiles
+		full = full || (ar.maxSize > 0 && uint64(keptBytes+file.size) > ar.maxSize)
+		if !expired && !excess && !full {
+			kept++
+			keptBytes += file.size
+			continue
+		}
+
+		if dryRun {
+			logger.JobInfo("pruneArtifacts", "Would delete %s (%d bytes, modified %s)",
+				file.path, file.size, file.modTime.UTC().Format(time.RFC3339))
+		} else if err := os.Remove(file.path); err != nil {
+			errs = append(errs, err.Error())
+			keptBytes += file.size
+			continue
+		}
+		pruned++
+		reclaimed += file.size
+	}
+
+	if dryRun {
+		metrics.ArtifactsDirectoryBytes.WithLabelValues(ar.name).Set(float64(keptBytes + reclaimed))
+	} else {
+		metrics.ArtifactsPrunedTotal.WithLabelValues(ar.name).Add(float64(pruned))
+		metrics.ArtifactsReclaimedBytesTotal.WithLabelValues(ar.name).Add(float64(reclaimed))
+		metrics.ArtifactsDirectoryBytes.WithLabelValues(ar.name).Set(float64(keptBytes))
+		if pruned > 0 {
+			logger.JobDebug("pruneArtifacts", "Directory %s: %d files deleted, %d bytes reclaimed", ar.name, pruned, reclaimed)
+		}
+	}
+
+	if len(errs) > 0 {
+		return pruned, reclaimed, fmt.Errorf("failed to delete %s", strings.Join(errs, "; "))
+	}
+	return pruned, reclaimed, nil
+}
+
+// listArtifactFiles returns the regular files under a directory, recursively, except those
+// being written
+func listArtifactFiles(dir string) ([]artifactFile, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	var files []artifactFile
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || filepath.Ext(path) == ".tmp" {
+			return nil
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // deleted meanwhile
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, artifactFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
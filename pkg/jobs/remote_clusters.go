@@ -0,0 +1,286 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/notify"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// remoteDisconnectSource is the event store source of remote cluster disconnects
+const remoteDisconnectSource = "remoteDisconnect"
+
+// remoteInfo is one remote of the _remote/info response. Sniff mode reports seeds and
+// connected nodes, proxy mode a proxy address and connected sockets.
+type remoteInfo struct {
+	Connected                bool     `json:"connected"`
+	Mode                     string   `json:"mode"`
+	Seeds                    []string `json:"seeds"`
+	NumNodesConnected        int      `json:"num_nodes_connected"`
+	ProxyAddress             string   `json:"proxy_address"`
+	NumProxySocketsConnected int      `json:"num_proxy_sockets_connected"`
+	SkipUnavailable          bool     `json:"skip_unavailable"`
+}
+
+// GetRemoteClusters polls _remote/info of every cluster and tracks the connection to each
+// configured remote cluster across polls: when it was last connected, since when it is
+// disconnected and the latest changesKept connection changes. A remote disconnected for
+// longer than disconnectedFor fires an event in the event store, critical when the remote is
+// not skip_unavailable (cross-cluster searches including it then fail); the event resolves
+// when the remote is connected again or removed from the cluster settings.
+func GetRemoteClusters(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("getRemoteClusters", "Starting remote cluster connection check")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("getRemoteClusters", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	disconnectedFor := p.Duration("disconnectedFor", 5*time.Minute)
+	changesKept := p.IntInRange("changesKept", 20, 1, 1000)
+	severity := p.OneOf("severity", "warning", "info", "warning", "critical")
+	notifyOwners := p.Bool("notifyOwners", false)
+	if err := checkParams("getRemoteClusters", p); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var mu sync.Mutex
+	collected := make(map[string]bool)
+	configured, disconnected := 0, 0
+
+	_, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		var info map[string]remoteInfo
+		if err := getClusterJSON(ctx, cluster, "/_remote/info", queryOptions{JobName: "getRemoteClusters"}, &info); err != nil {
+			return fmt.Errorf("failed to fetch remote cluster info: %w", err)
+		}
+
+		var previous map[string]*types.RemoteClusterState
+		if prev, ok := types.GetRemotes(clusterName); ok {
+			previous = prev.Remotes
+		}
+		nowMs := utils.TimeNowMillis()
+		remotes := trackRemotes(info, previous, nowMs, changesKept)
+		types.SetRemotes(clusterName, &types.ClusterRemotes{SnapShotTime: nowMs, Remotes: remotes})
+		recordRemoteMetrics(clusterName, previous, remotes)
+
+		down := 0
+		for alias, remote := range remotes {
+			if remote.Connected {
+				continue
+			}
+			down++
+			if prev, ok := previous[alias]; !ok || prev.Connected {
+				logger.JobWarn("getRemoteClusters", "Cluster %s: remote %s is disconnected", clusterName, alias)
+			}
+		}
+
+		mu.Lock()
+		collected[clusterName] = true
+		configured += len(remotes)
+		disconnected += down
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Disconnects of clusters that could not be polled this time are kept as they are
+	observed := make([]events.Observation, 0)
+	for clusterName := range collected {
+		remotes, ok := types.GetRemotes(clusterName)
+		if !ok {
+			continue
+		}
+		for alias, remote := range remotes.Remotes {
+			if remote.Connected || remotes.SnapShotTime-remote.DisconnectedSince < disconnectedFor.Milliseconds() {
+				continue
+			}
+			eventSeverity := severity
+			if !remote.SkipUnavailable {
+				eventSeverity = "critical"
+			}
+			lastConnected := "never"
+			if remote.LastConnected != 0 {
+				lastConnected = time.UnixMilli(remote.LastConnected).UTC().Format(time.RFC3339)
+			}
+			observed = append(observed, events.Observation{
+				Name:     "RemoteClusterDisconnected",
+				Severity: eventSeverity,
+				Labels:   map[string]string{"cluster": clusterName, "remote": alias},
+				Annotations: map[string]string{
+					"summary":         fmt.Sprintf("Remote cluster %s disconnected for more than %s", alias, disconnectedFor),
+					"mode":            remote.Mode,
+					"addresses":       strings.Join(remote.Addresses, ","),
+					"skipUnavailable": strconv.FormatBool(remote.SkipUnavailable),
+					"lastConnected":   lastConnected,
+				},
+				StartsAt: remote.DisconnectedSince,
+			})
+		}
+	}
+	persistent := len(observed)
+	observed = append(observed, carriedOverEvents(remoteDisconnectSource, collected)...)
+
+	fired, resolved, err := events.Sync(remoteDisconnectSource, observed, now)
+	if err != nil {
+		logger.JobWarn("getRemoteClusters", "Failed to persist events: %v", err)
+	}
+
+	firedByCluster := make(map[string][]events.Event)
+	for _, event := range fired {
+		logger.JobWarn("getRemoteClusters", "Remote cluster disconnected: cluster=%s remote=%s since %s (event %s)",
+			event.Cluster(), event.Labels["remote"], time.UnixMilli(event.StartsAt).UTC().Format(time.RFC3339), event.ID)
+		firedByCluster[event.Cluster()] = append(firedByCluster[event.Cluster()], event)
+	}
+	for _, event := range resolved {
+		logger.JobInfo("getRemoteClusters", "Remote cluster reconnected: cluster=%s remote=%s (event %s)",
+			event.Cluster(), event.Labels["remote"], event.ID)
+	}
+
+	if notifyOwners {
+		clusterNames := make([]string, 0, len(firedByCluster))
+		for clusterName := range firedByCluster {
+			clusterNames = append(clusterNames, clusterName)
+		}
+		sort.Strings(clusterNames)
+		for _, clusterName := range clusterNames {
+			notifyRemoteDisconnects(ctx, clusterName, firedByCluster[clusterName], severity)
+		}
+	}
+
+	logger.JobInfo("getRemoteClusters", "Completed: %d clusters polled, %d remotes configured, %d disconnected, %d persistently (%d new, %d resolved)",
+		len(collected), configured, disconnected, persistent, len(fired), len(resolved))
+	return nil
+}
+
+// trackRemotes turns the _remote/info response into tracked remotes, carrying the connection
+// history over from the previous poll. A remote seen disconnected for the first time is
+// considered disconnected since that poll.
+func trackRemotes(info map[string]remoteInfo, previous map[string]*types.RemoteClusterState, nowMs int64, changesKept int) map[string]*types.RemoteClusterState {
+	remotes := make(map[string]*types.RemoteClusterState, len(info))
+	for alias, remoteStatus := range info {
+		remote := &types.RemoteClusterState{
+			Alias:           alias,
+			Mode:            remoteStatus.Mode,
+			Connected:       remoteStatus.Connected,
+			NodesConnected:  remoteStatus.NumNodesConnected,
+			SkipUnavailable: remoteStatus.SkipUnavailable,
+			FirstSeen:       nowMs,
+			Changes:         types.NewRing[types.RemoteConnectionChange](changesKept),
+		}
+		if remoteStatus.Mode == "proxy" {
+			remote.Addresses = []string{remoteStatus.ProxyAddress}
+			remote.NodesConnected = remoteStatus.NumProxySocketsConnected
+		} else {
+			remote.Addresses = remoteStatus.Seeds
+		}
+
+		prev, known := previous[alias]
+		if known {
+			remote.FirstSeen = prev.FirstSeen
+			remote.LastConnected = prev.LastConnected
+			remote.DisconnectedSince = prev.DisconnectedSince
+			remote.Disconnects = prev.Disconnects
+			// Keep the newest changes of a history whose size changed
+			for i := min(changesKept, prev.Changes.Cap()) - 1; i >= 0; i-- {
+				remote.Changes.Push(prev.Changes.At(i))
+			}
+		}
+
+		if remote.Connected {
+			remote.LastConnected = nowMs
+			remote.DisconnectedSince = 0
+		} else if remote.DisconnectedSince == 0 {
+			remote.DisconnectedSince = nowMs
+		}
+		if !known || prev.Connected != remote.Connected {
+			remote.Changes.Push(types.RemoteConnectionChange{TimeStamp: nowMs, Connected: remote.Connected})
+			if known && !remote.Connected {
+				remote.Disconnects++
+			}
+		}
+		remotes[alias] = remote
+	}
+	return remotes
+}
+
+// recordRemoteMetrics exports the connections of a cluster to its remote clusters
+func recordRemoteMetrics(clusterName string, previous, remotes map[string]*types.RemoteClusterState) {
+	clusterLabel := prometheus.Labels{"cluster": clusterName}
+	metrics.RemoteClusterConnected.DeletePartialMatch(clusterLabel)
+	metrics.RemoteClusterNodesConnected.DeletePartialMatch(clusterLabel)
+
+	for alias := range previous {
+		if _, ok := remotes[alias]; !ok {
+			metrics.RemoteClusterDisconnectsTotal.DeleteLabelValues(clusterName, alias)
+		}
+	}
+
+	for alias, remote := range remotes {
+		connected := 0.0
+		if remote.Connected {
+			connected = 1
+		}
+		metrics.RemoteClusterConnected.WithLabelValues(clusterName, alias).Set(connected)
+		metrics.RemoteClusterNodesConnected.WithLabelValues(clusterName, alias).Set(float64(remote.NodesConnected))
+		disconnects := metrics.RemoteClusterDisconnectsTotal.WithLabelValues(clusterName, alias)
+		if prev, ok := previous[alias]; ok && remote.Disconnects > prev.Disconnects {
+			disconnects.Add(float64(remote.Disconnects - prev.Disconnects))
+		}
+	}
+}
+
+// notifyRemoteDisconnects sends the owner of a cluster one alert for its newly disconnected
+// remote clusters
+func notifyRemoteDisconnects(ctx context.Context, clusterName string, clusterEvents []events.Event, severity string) {
+	// Disconnects detected during maintenance are recorded but not alerted on
+	alerting := clusterEvents[:0:0]
+	for _, event := range clusterEvents {
+		if !event.Suppressed {
+			alerting = append(alerting, event)
+		}
+	}
+	if len(alerting) == 0 {
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Cluster %s lost the connection to %d remote clusters:\n", clusterName, len(alerting))
+	for _, event := range alerting {
+		if event.Severity == "critical" {
+			severity = "critical"
+		}
+		fmt.Fprintf(&text, "  %s (%s mode, %s): disconnected since %s, last connected %s, skip_unavailable %s (event %s)\n",
+			event.Labels["remote"], event.Annotations["mode"], event.Annotations["addresses"],
+			time.UnixMilli(event.StartsAt).UTC().Format(time.RFC3339), event.Annotations["lastConnected"],
+			event.Annotations["skipUnavailable"], event.ID)
+		writeEventLinks(&text, event.Annotations)
+	}
+
+	err := notify.NotifyCluster(ctx, clusterName, notify.Message{
+		Subject:  fmt.Sprintf("Remote cluster disconnected on cluster %s", clusterName),
+		Text:     text.String(),
+		Severity: severity,
+	})
+	if err != nil {
+		logger.JobWarn("getRemoteClusters", "Failed to notify owner of cluster %s: %v", clusterName, err)
+	}
+}
@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// RenameClusterResult is what renaming a cluster moved
+type RenameClusterResult struct {
+	*types.ClusterRename
+	Events           int  `json:"events"`           // events moved to the new name
+	SettingsBaseline bool `json:"settingsBaseline"` // the settings baseline file moved
+}
+
+// RenameCluster renames a cluster when it was renamed in the CMDB, so its history is not
+// lost: a one-time job with the parameters from (the old name) and to (the new name)
+func RenameCluster(ctx context.Context, params map[string]interface{}) error {
+	p := jobparams.New(params)
+	from := p.RequiredString("from")
+	to := p.RequiredString("to")
+	if err := p.Err(); err != nil {
+		return err
+	}
+
+	result, err := ApplyClusterRename(from, to)
+	if err != nil {
+		return err
+	}
+	logger.JobInfo("renameCluster", "Renamed cluster %s to %s (merged: %t): moved %v, %d events, settings baseline: %t",
+		from, to, result.Merged, result.Moved, result.Events, result.SettingsBaseline)
+	return nil
+}
+
+// ValidateRenameClusterParams checks the names of a renameCluster job when it is loaded
+func ValidateRenameClusterParams(params map[string]interface{}) error {
+	p := jobparams.New(params)
+	from := p.RequiredString("from")
+	to := p.RequiredString("to")
+	if err := p.Err(); err != nil {
+		return err
+	}
+	return validateRenameNames(from, to)
+}
+
+// validateRenameNames checks the old and new name of a cluster
+func validateRenameNames(from, to string) error {
+	if !utils.ValidateClusterName(from) || !utils.ValidateClusterName(to) {
+		return fmt.Errorf("invalid cluster name")
+	}
+	if from == to {
+		return fmt.Errorf("from and to are the same cluster name %s", from)
+	}
+	return nil
+}
+
+// ApplyClusterRename re-keys a cluster from one name to another: the inventory and everything
+// collected for it (see types.RenameClusterData), its events and its settings baseline. If the
+// new name is in the inventory already, the two are merged and the data of the old name wins.
+// The inventory is rebuilt by loadFromMasterCSV, so the CSV must carry the new name by then.
+func ApplyClusterRename(from, to string) (*RenameClusterResult, error) {
+	if err := validateRenameNames(from, to); err != nil {
+		return nil, err
+	}
+
+	renamed, err := types.RenameClusterData(from, to)
+	if err != nil {
+		return nil, err
+	}
+	result := &RenameClusterResult{ClusterRename: renamed}
+
+	masterLookupsMu.Lock()
+	delete(masterLookups, from)
+	masterLookupsMu.Unlock()
+	renameUUIDMismatch(from, to)
+
+	// The other moves are done, so a failure to persist them is reported, not undone
+	result.Events, err = events.RenameCluster(from, to, time.Now())
+	if err != nil {
+		return result, fmt.Errorf("cluster %s renamed to %s, but: %w", from, to, err)
+	}
+
+	baselineMu.Lock()
+	defer baselineMu.Unlock()
+	if _, err := os.Stat(settingsBaselinePath(from)); err == nil {
+		if err := os.Rename(settingsBaselinePath(from), settingsBaselinePath(to)); err != nil {
+			return result, fmt.Errorf("cluster %s renamed to %s, but failed to move its settings baseline: %w", from, to, err)
+		}
+		result.SettingsBaseline = true
+	}
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"testing"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/types"
+)
+
+func TestApplyClusterRenameMovesUUIDMismatch(t *testing.T) {
+	saved := config.Global
+	config.Global = &config.GlobalConfig{SettingsBaselineDir: t.TempDir()}
+	const from, to = "rename-uuid-old", "rename-uuid-new"
+	t.Cleanup(func() {
+		config.Global = saved
+		removeTestCluster(from)
+		removeTestCluster(to)
+	})
+	types.MutateClusters(func(clusters map[string]*types.ClusterData) {
+		clusters[from] = &types.ClusterData{ClusterName: from, Active: true}
+	})
+
+	recordUUIDMismatch(from, &events.Observation{
+		Name:     "ClusterUUIDMismatch",
+		Severity: "critical",
+		Labels:   map[string]string{"cluster": from},
+	})
+	if _, err := ApplyClusterRename(from, to); err != nil {
+		t.Fatalf("ApplyClusterRename: %v", err)
+	}
+
+	uuidMismatchesMu.Lock()
+	_, oldKept := uuidMismatches[from]
+	moved, ok := uuidMismatches[to]
+	uuidMismatchesMu.Unlock()
+	if oldKept || !ok || moved.Labels["cluster"] != to {
+		t.Fatalf("uuidMismatches: old kept %t, new %+v, want the mismatch under %s", oldKept, moved, to)
+	}
+
+	// The next matching check resolves the event under the new name; the events of earlier
+	// runs of the test stay resolved in the store
+	resolvedBefore := len(events.Query(events.Filter{Source: clusterUUIDSource, Cluster: to, State: events.StateResolved}))
+	recordUUIDMismatch(to, nil)
+	firing := events.Query(events.Filter{Source: clusterUUIDSource, Cluster: to, State: events.StateFiring})
+	resolved := events.Query(events.Filter{Source: clusterUUIDSource, Cluster: to, State: events.StateResolved})
+	if len(firing) != 0 || len(resolved) != resolvedBefore+1 {
+		t.Errorf("events of %s: %d firing, %d resolved (%d before), want the mismatch resolved", to, len(firing), len(resolved), resolvedBefore)
+	}
+}
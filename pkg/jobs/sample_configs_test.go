@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"os"
+	"testing"
+
+	"ElasticObservability/pkg/config"
+)
+
+// TestSampleJobsValidate loads the shipped config.yaml and job files and runs the validator of
+// every enabled job, as the scheduler does when it adds the jobs. The paths of the samples are
+// relative to the repository root, where the service is started.
+func TestSampleJobsValidate(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	saved := config.Global
+	t.Cleanup(func() {
+		config.Global = saved
+		os.Chdir(wd)
+	})
+	if err := config.LoadGlobalConfig("config.yaml"); err != nil {
+		t.Fatalf("LoadGlobalConfig: %v", err)
+	}
+
+	validators := map[string]func(map[string]interface{}) error{
+		"getThreadPoolWriteQueue":  ValidateThreadPoolWriteQueueParams,
+		"evaluateRules":            ValidateEvaluateRulesParams,
+		"checkForWritePressure":    ValidateWritePressureParams,
+		"getTDataWriteBulk_sTasks": ValidateBulkTasksParams,
+		"watchLongRunningTasks":    ValidateLongRunningTaskParams,
+		"checkRetention":           ValidateCheckRetentionParams,
+		"renameCluster":            ValidateRenameClusterParams,
+		"decommissionNodes":        ValidateDecommissionNodesParams,
+		"pruneArtifacts":           ValidatePruneArtifactsParams,
+	}
+	jobConfigs, err := config.LoadJobConfigs(config.Global.ConfigDir)
+	if err != nil {
+		t.Fatalf("LoadJobConfigs: %v", err)
+	}
+	for _, jobConfig := range jobConfigs {
+		validate, ok := validators[jobConfig.InternalJobName]
+		if !jobConfig.Enabled || !ok {
+			continue
+		}
+		if err := validate(jobConfig.EffectiveParameters()); err != nil {
+			t.Errorf("job %s: %v", jobConfig.Name, err)
+		}
+	}
+}
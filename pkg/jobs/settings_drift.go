@@ -0,0 +1,435 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/notify"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// settingsDriftSource is the event store source of settings drift
+const settingsDriftSource = "settingsDrift"
+
+// Index settings compared when the job does not name any
+var defaultDriftIndexSettings = []string{
+	"index.number_of_replicas",
+	"index.refresh_interval",
+	"index.routing.allocation.enable",
+	"index.blocks.write",
+	"index.blocks.read_only_allow_delete",
+}
+
+// baselineMu serializes reads and writes of the baseline files
+var baselineMu sync.Mutex
+
+// CheckSettingsDrift snapshots the persistent and transient cluster settings and the selected
+// settings of the matching indices, and compares them with the cluster's baseline. The first
+// snapshot of a cluster becomes its baseline; later baselines are accepted through the API.
+// Each drifted cluster setting fires an event, as does each drifted index setting (for all
+// indices drifting on it together); the events resolve when the setting is back to the
+// baseline or a new baseline is accepted.
+//
+// Index settings are only compared for indices present in both snapshots, so indices created
+// or deleted since the baseline do not count as drift.
+func CheckSettingsDrift(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("checkSettingsDrift", "Starting settings drift check")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("checkSettingsDrift", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	indexSettings := p.StringSlice("indexSettings")
+	indices := p.String("indices", "*")
+	ignoreSettings := p.StringSlice("ignoreSettings")
+	severity := p.OneOf("severity", "warning", "info", "warning", "critical")
+	notifyOwners := p.Bool("notifyOwners", false)
+	if err := checkParams("checkSettingsDrift", p); err != nil {
+		return err
+	}
+	if len(indexSettings) == 0 {
+		indexSettings = defaultDriftIndexSettings
+	}
+	for _, pattern := range ignoreSettings {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid ignoreSettings pattern %q: %w", pattern, err)
+		}
+	}
+
+	now := time.Now()
+	var mu sync.Mutex
+	collected := make(map[string]bool)
+	drifted := 0
+
+	_, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		current, err := settingsSnapshot(ctx, cluster, indices, indexSettings, ignoreSettings)
+		if err != nil {
+			return err
+		}
+
+		baselineMu.Lock()
+		baseline, err := loadSettingsBaseline(clusterName)
+		if err == nil && baseline == nil {
+			baseline = current
+			err = saveSettingsBaseline(clusterName, current)
+			if err == nil {
+				logger.JobInfo("checkSettingsDrift", "Cluster %s: settings baseline recorded", clusterName)
+			}
+		}
+		baselineMu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		report := &types.SettingsDriftReport{
+			ClusterName:  clusterName,
+			CheckTime:    current.TimeStamp,
+			BaselineTime: baseline.TimeStamp,
+			Drift:        diffSettings(baseline, current, ignoreSettings),
+			Current:      current,
+		}
+		types.SetSettingsDrift(clusterName, report)
+
+		mu.Lock()
+		collected[clusterName] = true
+		drifted += len(report.Drift)
+		mu.Unlock()
+
+		if len(report.Drift) > 0 {
+			logger.JobInfo("checkSettingsDrift", "Cluster %s: %d settings drifted from the baseline of %s",
+				clusterName, len(report.Drift), time.UnixMilli(baseline.TimeStamp).UTC().Format(time.RFC3339))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Drift of clusters that could not be checked this time is kept as it is
+	observed := make([]events.Observation, 0)
+	for clusterName := range collected {
+		if report, ok := types.GetSettingsDrift(clusterName); ok {
+			observed = append(observed, settingsDriftObservations(report, severity)...)
+		}
+	}
+	observed = append(observed, carriedOverEvents(settingsDriftSource, collected)...)
+
+	fired, resolved, err := events.Sync(settingsDriftSource, observed, now)
+	if err != nil {
+		logger.JobWarn("checkSettingsDrift", "Failed to persist events: %v", err)
+	}
+
+	firedByCluster := make(map[string][]events.Event)
+	for _, event := range fired {
+		logger.JobWarn("checkSettingsDrift", "Settings drift: cluster=%s setting=%s %s (event %s)",
+			event.Cluster(), event.Labels["setting"], event.Annotations["summary"], event.ID)
+		firedByCluster[event.Cluster()] = append(firedByCluster[event.Cluster()], event)
+	}
+	for _, event := range resolved {
+		logger.JobInfo("checkSettingsDrift", "Settings drift resolved: cluster=%s setting=%s (event %s)",
+			event.Cluster(), event.Labels["setting"], event.ID)
+	}
+
+	if notifyOwners {
+		clusterNames := make([]string, 0, len(firedByCluster))
+		for clusterName := range firedByCluster {
+			clusterNames = append(clusterNames, clusterName)
+		}
+		sort.Strings(clusterNames)
+		for _, clusterName := range clusterNames {
+			notifySettingsDrift(ctx, clusterName, firedByCluster[clusterName], severity)
+		}
+	}
+
+	logger.JobInfo("checkSettingsDrift", "Completed: %d clusters checked, %d drifted settings (%d new events, %d resolved)",
+		len(collected), drifted, len(fired), len(resolved))
+	return nil
+}
+
+// settingsSnapshot reads the explicitly set cluster settings and the selected index settings
+func settingsSnapshot(ctx context.Context, cluster *types.ClusterData, indices string, indexSettings, ignoreSettings []string) (*types.SettingsSnapshot, error) {
+	query := queryOptions{JobName: "checkSettingsDrift"}
+	snapshot := &types.SettingsSnapshot{
+		TimeStamp: utils.TimeNowMillis(),
+		Cluster:   make(map[string]string),
+		Indices:   make(map[string]map[string]string),
+	}
+
+	var clusterSettings map[string]map[string]interface{}
+	if err := getClusterJSON(ctx, cluster, "/_cluster/settings?flat_settings=true", query, &clusterSettings); err != nil {
+		return nil, fmt.Errorf("failed to fetch cluster settings: %w", err)
+	}
+	for _, level := range []string{"persistent", "transient"} {
+		for name, value := range clusterSettings[level] {
+			if !settingIgnored(name, ignoreSettings) {
+				snapshot.Cluster[level+"."+name] = settingValue(value)
+			}
+		}
+	}
+
+	var indexResponse map[string]struct {
+		Settings map[string]interface{} `json:"settings"`
+	}
+	settingsPath := fmt.Sprintf("/%s/_settings/%s?flat_settings=true&expand_wildcards=open",
+		url.PathEscape(indices), url.PathEscape(strings.Join(indexSettings, ",")))
+	if err := getClusterJSON(ctx, cluster, settingsPath, query, &indexResponse); err != nil {
+		return nil, fmt.Errorf("failed to fetch index settings: %w", err)
+	}
+	for index, response := range indexResponse {
+		settings := make(map[string]string, len(response.Settings))
+		for name, value := range response.Settings {
+			if !settingIgnored(name, ignoreSettings) {
+				settings[name] = settingValue(value)
+			}
+		}
+		snapshot.Indices[index] = settings
+	}
+	return snapshot, nil
+}
+
+// settingValue renders a flat setting value; list settings are rendered as JSON
+func settingValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// settingIgnored reports whether a setting name matches one of the ignore patterns
+func settingIgnored(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// diffSettings lists the settings of current that differ from the baseline. Settings matching
+// the ignore patterns are skipped, so patterns added later also apply to older baselines.
+func diffSettings(baseline, current *types.SettingsSnapshot, ignoreSettings []string) []types.SettingDrift {
+	drift := make([]types.SettingDrift, 0)
+	compare := func(index string, before, after map[string]string) {
+		for name, value := range after {
+			bare := strings.TrimPrefix(strings.TrimPrefix(name, "persistent."), "transient.")
+			if settingIgnored(bare, ignoreSettings) {
+				continue
+			}
+			old, ok := before[name]
+			switch {
+			case !ok:
+				drift = append(drift, types.SettingDrift{Setting: name, Index: index, Change: types.DriftAdded, Current: value})
+			case old != value:
+				drift = append(drift, types.SettingDrift{Setting: name, Index: index, Change: types.DriftChanged, Baseline: old, Current: value})
+			}
+		}
+		for name, old := range before {
+			bare := strings.TrimPrefix(strings.TrimPrefix(name, "persistent."), "transient.")
+			if _, ok := after[name]; !ok && !settingIgnored(bare, ignoreSettings) {
+				drift = append(drift, types.SettingDrift{Setting: name, Index: index, Change: types.DriftRemoved, Baseline: old})
+			}
+		}
+	}
+
+	compare("", baseline.Cluster, current.Cluster)
+	for index, settings := range current.Indices {
+		if before, ok := baseline.Indices[index]; ok {
+			compare(index, before, settings)
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		a, b := drift[i], drift[j]
+		if (a.Index == "") != (b.Index == "") {
+			return a.Index == ""
+		}
+		if a.Setting != b.Setting {
+			return a.Setting < b.Setting
+		}
+		return a.Index < b.Index
+	})
+	return drift
+}
+
+// settingsDriftObservations turns a drift report into events: one per cluster setting and one
+// per index setting, covering all indices drifting on it
+func settingsDriftObservations(report *types.SettingsDriftReport, severity string) []events.Observation {
+	observations := make([]events.Observation, 0)
+	byIndexSetting := make(map[string][]types.SettingDrift)
+	for _, drift := range report.Drift {
+		if drift.Index != "" {
+			byIndexSetting[drift.Setting] = append(byIndexSetting[drift.Setting], drift)
+			continue
+		}
+		observations = append(observations, events.Observation{
+			Name:     "SettingsDrift",
+			Severity: severity,
+			Labels:   map[string]string{"cluster": report.ClusterName, "setting": drift.Setting},
+			Annotations: map[string]string{
+				"summary":  driftSummary(drift),
+				"change":   drift.Change,
+				"baseline": drift.Baseline,
+				"current":  drift.Current,
+			},
+			StartsAt: report.CheckTime,
+		})
+	}
+
+	for setting, drifts := range byIndexSetting {
+		names := make([]string, 0, min(len(drifts), 5))
+		for _, drift := range drifts[:min(len(drifts), 5)] {
+			names = append(names, drift.Index)
+		}
+		if len(drifts) > len(names) {
+			names = append(names, fmt.Sprintf("and %d more", len(drifts)-len(names)))
+		}
+		observations = append(observations, events.Observation{
+			Name:     "SettingsDrift",
+			Severity: severity,
+			Labels:   map[string]string{"cluster": report.ClusterName, "setting": setting},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("differs from the baseline on %d indices", len(drifts)),
+				"indices": strings.Join(names, ", "),
+			},
+			StartsAt: report.CheckTime,
+		})
+	}
+	return observations
+}
+
+// driftSummary describes the change of one setting
+func driftSummary(drift types.SettingDrift) string {
+	switch drift.Change {
+	case types.DriftAdded:
+		return fmt.Sprintf("set to %q, not set in the baseline", drift.Current)
+	case types.DriftRemoved:
+		return fmt.Sprintf("no longer set, %q in the baseline", drift.Baseline)
+	default:
+		return fmt.Sprintf("changed from %q to %q", drift.Baseline, drift.Current)
+	}
+}
+
+// settingsBaselinePath is the baseline file of a cluster
+func settingsBaselinePath(clusterName string) string {
+	return filepath.Join(config.Global.SettingsBaselineDir, clusterName+".json")
+}
+
+// loadSettingsBaseline reads the baseline of a cluster; a missing baseline is nil. Callers
+// hold baselineMu.
+func loadSettingsBaseline(clusterName string) (*types.SettingsSnapshot, error) {
+	data, err := os.ReadFile(settingsBaselinePath(clusterName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings baseline: %w", err)
+	}
+	var baseline types.SettingsSnapshot
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to parse settings baseline: %w", err)
+	}
+	return &baseline, nil
+}
+
+// saveSettingsBaseline writes the baseline of a cluster. Callers hold baselineMu.
+func saveSettingsBaseline(clusterName string, baseline *types.SettingsSnapshot) error {
+	if err := os.MkdirAll(config.Global.SettingsBaselineDir, 0755); err != nil {
+		return fmt.Errorf("failed to create settings baseline directory: %w", err)
+	}
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal settings baseline: %w", err)
+	}
+	tmp := settingsBaselinePath(clusterName) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write settings baseline: %w", err)
+	}
+	if err := os.Rename(tmp, settingsBaselinePath(clusterName)); err != nil {
+		return fmt.Errorf("failed to write settings baseline: %w", err)
+	}
+	return nil
+}
+
+// AcceptSettingsBaseline makes the latest settings snapshot of a cluster its baseline, so the
+// drift seen so far is no longer reported. The events of the accepted drift resolve with the
+// next checkSettingsDrift run.
+func AcceptSettingsBaseline(clusterName string) (*types.SettingsDriftReport, error) {
+	report, ok := types.GetSettingsDrift(clusterName)
+	if !ok || report.Current == nil {
+		return nil, fmt.Errorf("no settings snapshot of cluster %s yet", clusterName)
+	}
+
+	baselineMu.Lock()
+	err := saveSettingsBaseline(clusterName, report.Current)
+	baselineMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := &types.SettingsDriftReport{
+		ClusterName:  clusterName,
+		CheckTime:    report.CheckTime,
+		BaselineTime: report.Current.TimeStamp,
+		Drift:        []types.SettingDrift{},
+		Current:      report.Current,
+	}
+	types.SetSettingsDrift(clusterName, accepted)
+	logger.AppInfo("Settings baseline of cluster %s replaced (%d drifted settings accepted)", clusterName, len(report.Drift))
+	return accepted, nil
+}
+
+// notifySettingsDrift sends the owner of a cluster one alert for its new drift events
+func notifySettingsDrift(ctx context.Context, clusterName string, clusterEvents []events.Event, severity string) {
+	// Drift detected during maintenance is recorded but not alerted on
+	alerting := clusterEvents[:0:0]
+	for _, event := range clusterEvents {
+		if !event.Suppressed {
+			alerting = append(alerting, event)
+		}
+	}
+	if len(alerting) == 0 {
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d settings of cluster %s drifted from the baseline:\n", len(alerting), clusterName)
+	for _, event := range alerting {
+		fmt.Fprintf(&text, "  %s: %s", event.Labels["setting"], event.Annotations["summary"])
+		if indices := event.Annotations["indices"]; indices != "" {
+			fmt.Fprintf(&text, " (%s)", indices)
+		}
+		fmt.Fprintf(&text, " (event %s)\n", event.ID)
+		writeEventLinks(&text, event.Annotations)
+	}
+
+	err := notify.NotifyCluster(ctx, clusterName, notify.Message{
+		Subject:  fmt.Sprintf("Settings drift on cluster %s", clusterName),
+		Text:     text.String(),
+		Severity: severity,
+	})
+	if err != nil {
+		logger.JobWarn("checkSettingsDrift", "Failed to notify owner of cluster %s: %v", clusterName, err)
+	}
+}
@@ -0,0 +1,289 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/notify"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// recoveryStallSource is the event store source of stalled shard recoveries
+const recoveryStallSource = "recoveryStall"
+
+// catRecoveryPath lists the active recoveries with sizes in bytes and times in milliseconds
+const catRecoveryPath = "/_cat/recovery?format=json&active_only=true&bytes=b&time=ms" +
+	"&h=index,shard,time,type,stage,source_node,target_node,bytes_total,bytes_recovered"
+
+// catRecovery is one row of _cat/recovery; _cat returns all values as strings
+type catRecovery struct {
+	Index          string `json:"index"`
+	Shard          string `json:"shard"`
+	Time           string `json:"time"`
+	Type           string `json:"type"`
+	Stage          string `json:"stage"`
+	SourceNode     string `json:"source_node"`
+	TargetNode     string `json:"target_node"`
+	BytesTotal     string `json:"bytes_total"`
+	BytesRecovered string `json:"bytes_recovered"`
+}
+
+// GetShardRecoveries polls _cat/recovery for the active shard recoveries and relocations of
+// every cluster and tracks them across polls: throughput since the previous poll, average
+// throughput and estimated completion. A recovery copying files (stage index) below
+// minThroughputMBps for longer than stallAfter fires an event in the event store; the event
+// resolves when the recovery speeds up or completes.
+func GetShardRecoveries(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("getShardRecoveries", "Starting shard recovery monitoring")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("getShardRecoveries", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	minThroughputMBps := p.Float("minThroughputMBps", 5)
+	stallAfter := p.Duration("stallAfter", 10*time.Minute)
+	severity := p.OneOf("severity", "warning", "info", "warning", "critical")
+	notifyOwners := p.Bool("notifyOwners", false)
+	if err := checkParams("getShardRecoveries", p); err != nil {
+		return err
+	}
+	if minThroughputMBps <= 0 {
+		return fmt.Errorf("minThroughputMBps must be positive, got %v", minThroughputMBps)
+	}
+	minThroughputBps := minThroughputMBps * 1024 * 1024
+
+	now := time.Now()
+	var mu sync.Mutex
+	collected := make(map[string]bool)
+	active := 0
+
+	_, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		var rows []catRecovery
+		if err := getClusterJSON(ctx, cluster, catRecoveryPath, queryOptions{JobName: "getShardRecoveries"}, &rows); err != nil {
+			return fmt.Errorf("failed to fetch recoveries: %w", err)
+		}
+
+		var previous map[string]*types.ShardRecovery
+		if prev, ok := types.GetRecoveries(clusterName); ok {
+			previous = prev.Recoveries
+		}
+		nowMs := utils.TimeNowMillis()
+		recoveries := trackRecoveries(rows, previous, nowMs, minThroughputBps)
+		types.SetRecoveries(clusterName, &types.ClusterRecoveries{SnapShotTime: nowMs, Recoveries: recoveries})
+
+		completed := 0
+		for key := range previous {
+			if _, ok := recoveries[key]; !ok {
+				completed++
+			}
+		}
+		recordRecoveryMetrics(clusterName, recoveries, stallAfter)
+
+		mu.Lock()
+		collected[clusterName] = true
+		active += len(recoveries)
+		mu.Unlock()
+
+		if len(recoveries) > 0 || completed > 0 {
+			logger.JobInfo("getShardRecoveries", "Cluster %s: %d active recoveries, %d completed since the last poll",
+				clusterName, len(recoveries), completed)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Stalls of clusters that could not be polled this time are kept as they are
+	observed := make([]events.Observation, 0)
+	for clusterName := range collected {
+		recoveries, ok := types.GetRecoveries(clusterName)
+		if !ok {
+			continue
+		}
+		for _, recovery := range recoveries.Recoveries {
+			if !recoveryStalled(recovery, stallAfter) {
+				continue
+			}
+			observed = append(observed, events.Observation{
+				Name:     "RecoveryStalled",
+				Severity: severity,
+				Labels: map[string]string{
+					"cluster": clusterName,
+					"index":   recovery.Index,
+					"shard":   strconv.Itoa(recovery.Shard),
+					"target":  recovery.TargetNode,
+				},
+				Annotations: map[string]string{
+					"summary":        fmt.Sprintf("Recovery below %.1f MB/s for more than %s", minThroughputMBps, stallAfter),
+					"type":           recovery.Type,
+					"source":         recovery.SourceNode,
+					"throughputMBps": strconv.FormatFloat(recovery.ThroughputBps/1024/1024, 'f', 2, 64),
+					"recoveredPercent": strconv.FormatFloat(
+						float64(recovery.BytesRecovered)*100/float64(max(recovery.BytesTotal, 1)), 'f', 1, 64),
+				},
+				StartsAt: recovery.SlowSince,
+			})
+		}
+	}
+	stalled := len(observed)
+	observed = append(observed, carriedOverEvents(recoveryStallSource, collected)...)
+
+	fired, resolved, err := events.Sync(recoveryStallSource, observed, now)
+	if err != nil {
+		logger.JobWarn("getShardRecoveries", "Failed to persist events: %v", err)
+	}
+
+	firedByCluster := make(map[string][]events.Event)
+	for _, event := range fired {
+		logger.JobWarn("getShardRecoveries", "Recovery stalled: cluster=%s index=%s shard=%s target=%s throughput=%sMB/s (event %s)",
+			event.Cluster(), event.Labels["index"], event.Labels["shard"], event.Labels["target"],
+			event.Annotations["throughputMBps"], event.ID)
+		firedByCluster[event.Cluster()] = append(firedByCluster[event.Cluster()], event)
+	}
+	for _, event := range resolved {
+		logger.JobInfo("getShardRecoveries", "Recovery no longer stalled: cluster=%s index=%s shard=%s (event %s)",
+			event.Cluster(), event.Labels["index"], event.Labels["shard"], event.ID)
+	}
+
+	if notifyOwners {
+		clusterNames := make([]string, 0, len(firedByCluster))
+		for clusterName := range firedByCluster {
+			clusterNames = append(clusterNames, clusterName)
+		}
+		sort.Strings(clusterNames)
+		for _, clusterName := range clusterNames {
+			notifyRecoveryStalls(ctx, clusterName, firedByCluster[clusterName], severity)
+		}
+	}
+
+	logger.JobInfo("getShardRecoveries", "Completed: %d clusters polled, %d active recoveries, %d stalled (%d new, %d resolved)",
+		len(collected), active, stalled, len(fired), len(resolved))
+	return nil
+}
+
+// trackRecoveries turns the _cat/recovery rows into tracked recoveries, carrying the first
+// sighting and the slow period over from the previous poll. Throughput is only judged while
+// files are copied (stage index); the other stages move no bytes.
+func trackRecoveries(rows []catRecovery, previous map[string]*types.ShardRecovery, nowMs int64, minThroughputBps float64) map[string]*types.ShardRecovery {
+	recoveries := make(map[string]*types.ShardRecovery, len(rows))
+	for _, row := range rows {
+		shard, _ := strconv.Atoi(row.Shard)
+		elapsedMs, _ := strconv.ParseInt(row.Time, 10, 64)
+		bytesTotal, _ := strconv.ParseUint(row.BytesTotal, 10, 64)
+		bytesRecovered, _ := strconv.ParseUint(row.BytesRecovered, 10, 64)
+
+		recovery := &types.ShardRecovery{
+			Index:          row.Index,
+			Shard:          shard,
+			Type:           row.Type,
+			Stage:          row.Stage,
+			SourceNode:     row.SourceNode,
+			TargetNode:     row.TargetNode,
+			BytesTotal:     bytesTotal,
+			BytesRecovered: bytesRecovered,
+			ElapsedMs:      elapsedMs,
+			FirstSeen:      nowMs,
+			LastSeen:       nowMs,
+		}
+		if elapsedMs > 0 {
+			recovery.AvgThroughputBps = float64(bytesRecovered) * 1000 / float64(elapsedMs)
+		}
+		recovery.ThroughputBps = recovery.AvgThroughputBps
+
+		key := fmt.Sprintf("%s/%d/%s", row.Index, shard, row.TargetNode)
+		if prev, ok := previous[key]; ok {
+			recovery.FirstSeen = prev.FirstSeen
+			if dt := nowMs - prev.LastSeen; dt > 0 && bytesRecovered >= prev.BytesRecovered {
+				recovery.ThroughputBps = float64(bytesRecovered-prev.BytesRecovered) * 1000 / float64(dt)
+			}
+			if recovery.Stage == "index" && recovery.ThroughputBps < minThroughputBps {
+				recovery.SlowSince = prev.SlowSince
+				if recovery.SlowSince == 0 {
+					recovery.SlowSince = prev.LastSeen
+				}
+			}
+		}
+
+		if rate := recovery.ThroughputBps; rate > 0 && bytesTotal > bytesRecovered {
+			recovery.EtaMs = int64(float64(bytesTotal-bytesRecovered) * 1000 / rate)
+		}
+		recoveries[key] = recovery
+	}
+	return recoveries
+}
+
+// recoveryStalled reports whether a recovery has been slow for longer than stallAfter
+func recoveryStalled(recovery *types.ShardRecovery, stallAfter time.Duration) bool {
+	return recovery.SlowSince != 0 && recovery.LastSeen-recovery.SlowSince >= stallAfter.Milliseconds()
+}
+
+// recordRecoveryMetrics exports the active recoveries of a cluster
+func recordRecoveryMetrics(clusterName string, recoveries map[string]*types.ShardRecovery, stallAfter time.Duration) {
+	metrics.RecoveriesActive.DeletePartialMatch(prometheus.Labels{"cluster": clusterName})
+
+	byType := make(map[string]int)
+	stalled := 0
+	throughput := 0.0
+	for _, recovery := range recoveries {
+		byType[recovery.Type]++
+		throughput += recovery.ThroughputBps
+		if recoveryStalled(recovery, stallAfter) {
+			stalled++
+		}
+	}
+	for recoveryType, count := range byType {
+		metrics.RecoveriesActive.WithLabelValues(clusterName, recoveryType).Set(float64(count))
+	}
+	metrics.RecoveriesStalled.WithLabelValues(clusterName).Set(float64(stalled))
+	metrics.RecoveryThroughputBytesPerSecond.WithLabelValues(clusterName).Set(throughput)
+}
+
+// notifyRecoveryStalls sends the owner of a cluster one alert for its newly stalled recoveries
+func notifyRecoveryStalls(ctx context.Context, clusterName string, clusterEvents []events.Event, severity string) {
+	// Stalls detected during maintenance are recorded but not alerted on
+	alerting := clusterEvents[:0:0]
+	for _, event := range clusterEvents {
+		if !event.Suppressed {
+			alerting = append(alerting, event)
+		}
+	}
+	if len(alerting) == 0 {
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d shard recoveries of cluster %s are stalled:\n", len(alerting), clusterName)
+	for _, event := range alerting {
+		fmt.Fprintf(&text, "  %s shard %s -> %s: %s MB/s, %s%% recovered, slow since %s (event %s)\n",
+			event.Labels["index"], event.Labels["shard"], event.Labels["target"],
+			event.Annotations["throughputMBps"], event.Annotations["recoveredPercent"],
+			time.UnixMilli(event.StartsAt).UTC().Format(time.RFC3339), event.ID)
+		writeEventLinks(&text, event.Annotations)
+	}
+
+	err := notify.NotifyCluster(ctx, clusterName, notify.Message{
+		Subject:  fmt.Sprintf("Stalled shard recoveries on cluster %s", clusterName),
+		Text:     text.String(),
+		Severity: severity,
+	})
+	if err != nil {
+		logger.JobWarn("getShardRecoveries", "Failed to notify owner of cluster %s: %v", clusterName, err)
+	}
+}
@@ -0,0 +1,189 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"ElasticObservability/pkg/audit"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// EndpointCheck is the validation of one endpoint of a switch
+type EndpointCheck struct {
+	Endpoint    string `json:"endpoint"`
+	Reachable   bool   `json:"reachable"`
+	ClusterUUID string `json:"clusterUUID,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// EndpointSwitch is the outcome of switching the endpoints of a cluster
+type EndpointSwitch struct {
+	Cluster                string          `json:"cluster"`
+	PreviousActiveEndpoint string          `json:"previousActiveEndpoint"`
+	PreviousClusterSAN     []string        `json:"previousClusterSAN"`
+	ActiveEndpoint         string          `json:"activeEndpoint"`
+	ClusterSAN             []string        `json:"clusterSAN"`
+	ClusterUUID            string          `json:"clusterUUID"`
+	UUIDSource             string          `json:"uuidSource"` // inventory, currentEndpoint or newEndpoints
+	Checks                 []EndpointCheck `json:"checks"`
+	Switched               bool            `json:"switched"` // false for a dry run or a failed validation
+}
+
+// EndpointValidationError is returned when an endpoint of a switch fails validation; the
+// switch carries the checks
+type EndpointValidationError struct {
+	Failed []string
+}
+
+func (e *EndpointValidationError) Error() string {
+	return fmt.Sprintf("endpoint validation failed for %s", strings.Join(e.Failed, ", "))
+}
+
+// clusterRoot is the part of the response of GET / the switch validates
+type clusterRoot struct {
+	ClusterName string `json:"cluster_name"`
+	ClusterUUID string `json:"cluster_uuid"`
+}
+
+// SwitchClusterEndpoints replaces the ClusterSAN set of a cluster, e.g. when its load balancers
+// are rotated, and makes activeEndpoint (by default the first of the set) its active endpoint.
+// Every new endpoint must answer with the cluster's UUID: the one of the inventory, else the
+// one the current active endpoint reports, else they must all agree. Nothing changes unless
+// all of them pass, or with dryRun. A switch is recorded in the audit log with the previous and
+// new endpoints, completing by (who asked for it and from where). The CSV must carry the new
+// set before loadFromMasterCSV runs again.
+func SwitchClusterEndpoints(ctx context.Context, clusterName string, clusterSAN []string, activeEndpoint string,
+	dryRun bool, by audit.Entry) (*EndpointSwitch, error) {
+	cluster, exists := types.GetCluster(clusterName)
+	if !exists {
+		return nil, fmt.Errorf("cluster %s not found", clusterName)
+	}
+	if cluster.AccessCred.Preferred == 0 {
+		return nil, fmt.Errorf("cluster %s has no credentials available (Preferred=0)", clusterName)
+	}
+
+	endpoints := make([]string, 0, len(clusterSAN))
+	for _, endpoint := range clusterSAN {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" && !utils.Contains(endpoints, endpoint) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("clusterSAN must list at least one endpoint")
+	}
+	active := endpoints[0]
+	if activeEndpoint != "" {
+		active = activeEndpoint
+		if !utils.Contains(endpoints, active) {
+			return nil, fmt.Errorf("activeEndpoint %s is not in clusterSAN", activeEndpoint)
+		}
+	}
+
+	result := &EndpointSwitch{
+		Cluster:                clusterName,
+		PreviousActiveEndpoint: cluster.ActiveEndpoint,
+		PreviousClusterSAN:     cluster.ClusterSAN,
+		ActiveEndpoint:         normalizeEndpoint(active, cluster.ClusterPort),
+		ClusterSAN:             endpoints,
+		Checks:                 make([]EndpointCheck, 0, len(endpoints)),
+	}
+	if result.PreviousClusterSAN == nil {
+		result.PreviousClusterSAN = make([]string, 0)
+	}
+
+	// The UUID the new endpoints must report
+	result.ClusterUUID, result.UUIDSource = cluster.ClusterUUID, "inventory"
+	if result.ClusterUUID == "" && cluster.ActiveEndpoint != "" {
+		if root, err := endpointRoot(ctx, cluster, cluster.ActiveEndpoint, queryOptions{JobName: "switchEndpoints"}); err == nil && root.ClusterUUID != "" {
+			result.ClusterUUID, result.UUIDSource = root.ClusterUUID, "currentEndpoint"
+		}
+	}
+	if result.ClusterUUID == "" {
+		result.UUIDSource = "newEndpoints"
+	}
+
+	failed := make([]string, 0)
+	for _, endpoint := range endpoints {
+		check := EndpointCheck{Endpoint: endpoint}
+		root, err := endpointRoot(ctx, cluster, normalizeEndpoint(endpoint, cluster.ClusterPort), queryOptions{JobName: "switchEndpoints"})
+		switch {
+		case err != nil:
+			check.Error = err.Error()
+		case root.ClusterUUID == "":
+			check.Reachable = true
+			check.Error = "the response carries no cluster_uuid"
+		default:
+			check.Reachable = true
+			check.ClusterUUID = root.ClusterUUID
+			if result.ClusterUUID == "" {
+				result.ClusterUUID = root.ClusterUUID
+			}
+			if root.ClusterUUID != result.ClusterUUID {
+				check.Error = fmt.Sprintf("cluster UUID %s (cluster %s) differs from %s", root.ClusterUUID, root.ClusterName, result.ClusterUUID)
+			}
+		}
+		if check.Error != "" {
+			failed = append(failed, endpoint)
+		}
+		result.Checks = append(result.Checks, check)
+	}
+	if len(failed) > 0 {
+		return result, &EndpointValidationError{Failed: failed}
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	if !types.UpdateCluster(clusterName, func(cluster *types.ClusterData) {
+		cluster.ClusterSAN = append([]string(nil), endpoints...)
+		cluster.ActiveEndpoint = result.ActiveEndpoint
+		if cluster.ClusterUUID == "" {
+			cluster.ClusterUUID = result.ClusterUUID
+		}
+	}) {
+		return nil, fmt.Errorf("cluster %s not found", clusterName)
+	}
+	result.Switched = true
+
+	// The master endpoints were found through the previous endpoints
+	masterLookupsMu.Lock()
+	delete(masterLookups, clusterName)
+	masterLookupsMu.Unlock()
+
+	recordEndpointSwitch(by, result)
+	logger.AppInfo("Cluster %s switched by %s to endpoints %v (active %s), previously %v (active %s)", clusterName, by.Principal,
+		result.ClusterSAN, result.ActiveEndpoint, result.PreviousClusterSAN, result.PreviousActiveEndpoint)
+	return result, nil
+}
+
+// endpointRoot returns the response of GET / of an endpoint of a cluster
+func endpointRoot(ctx context.Context, cluster *types.ClusterData, endpoint string, opts queryOptions) (*clusterRoot, error) {
+	target := *cluster
+	target.ActiveEndpoint = endpoint
+	var root clusterRoot
+	if err := getClusterJSON(ctx, &target, "/", opts, &root); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// recordEndpointSwitch records the previous and new endpoints of a switch in the audit log
+func recordEndpointSwitch(entry audit.Entry, result *EndpointSwitch) {
+	entry.Time = utils.TimeNowMillis()
+	entry.Action = "endpointSwitch"
+	entry.Target = result.Cluster
+	entry.Parameters = map[string]string{
+		"previousActiveEndpoint": result.PreviousActiveEndpoint,
+		"previousClusterSAN":     strings.Join(result.PreviousClusterSAN, "|"),
+		"activeEndpoint":         result.ActiveEndpoint,
+		"clusterSAN":             strings.Join(result.ClusterSAN, "|"),
+		"clusterUUID":            result.ClusterUUID,
+	}
+	entry.Result = audit.ResultSuccess
+	if err := audit.Record(entry); err != nil {
+		logger.AppError("Failed to record audit entry for the endpoint switch of cluster %s: %v", result.Cluster, err)
+	}
+}
@@ -3,20 +3,25 @@ package jobs
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"ElasticObservability/pkg/config"
 	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/scheduler"
 	"ElasticObservability/pkg/types"
 	"ElasticObservability/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
@@ -24,39 +29,39 @@ const (
 	"aggs": {
 		"hostname": {
 			"terms": {
-				"field": "source_node.host",
+				"field": "__NODE_FIELD__",
 				"order": {
-					"2[node_stats.thread_pool.write.queue]": "desc"
+					"2[__WRITE_QUEUE_FIELD__]": "desc"
 				},
-				"size": 250
+				"size": __HOST_BUCKETS__
 			},
 			"aggs": {
 				"2": {
 					"top_metrics": {
 						"metrics": {
-							"field": "node_stats.thread_pool.write.queue"
+							"field": "__WRITE_QUEUE_FIELD__"
 						},
 						"size": 1,
 						"sort": {
-							"timestamp": "desc"
+							"__TIMESTAMP_FIELD__": "desc"
 						}
 					}
 				},
 				"date_bucket": {
 					"date_histogram": {
-						"field": "source_node.timestamp",
+						"field": "__COLLECTION_TIMESTAMP_FIELD__",
 						"fixed_interval": "__INTERVAL__",
-						"time_zone": "US/Eastern"
+						"time_zone": "__TIME_ZONE__"
 					},
 					"aggs": {
 						"2": {
 							"top_metrics": {
 								"metrics": {
-									"field": "node_stats.thread_pool.write.queue"
+									"field": "__WRITE_QUEUE_FIELD__"
 								},
 								"size": 1,
 								"sort": {
-									"timestamp": "desc"
+									"__TIMESTAMP_FIELD__": "desc"
 								}
 							}
 						}
@@ -76,11 +81,11 @@ const (
 			"format": "date_time"
 		},
 		{
-			"field": "source_node.timestamp",
+			"field": "__COLLECTION_TIMESTAMP_FIELD__",
 			"format": "date_time"
 		},
 		{
-			"field": "timestamp",
+			"field": "__TIMESTAMP_FIELD__",
 			"format": "date_time"
 		}
 	],
@@ -96,17 +101,17 @@ const (
 			"filter": [
 				{
 					"match_phrase": {
-						"cluster_uuid": "__UUID__"
+						"__CLUSTER_FIELD__": "__UUID__"
 					}
 				},
 				{
 					"match_phrase": {
-						"type": "node_stats"
+						"__DOC_TYPE_FIELD__": "__DOC_TYPE__"
 					}
 				},
 				{
 					"range": {
-						"source_node.timestamp": {
+						"__COLLECTION_TIMESTAMP_FIELD__": {
 							"format": "strict_date_optional_time",
 							"gte": "now-__TIME_SPAN__",
 							"lte": "now"
@@ -118,155 +123,189 @@ const (
 			"must_not": [
 				{
 					"match_phrase": {
-						"node_stats.indices.docs.count": 0
+						"__DOCS_COUNT_FIELD__": 0
 					}
 				}
 			]
 		}
 	}
 }`
+	defaultHostBuckets         = 250
+	defaultSpanInterval        = "30s"
+	defaultTimeSpan            = "10m"
 	defaultHostNamePath        = "aggregations.hostname.buckets.key"
-	defaultMetricsPath         = "aggregations.hostname.buckets.date_bucket.buckets.2.top_metrics.metrics.node_stats.thread_pool.write.queue"
+	defaultMetricsPath         = "aggregations.hostname.buckets.date_bucket.buckets.2.top_metrics.metrics.__WRITE_QUEUE_FIELD__"
 	defaultMetricTimestampPath = "aggregations.hostname.buckets.date_bucket.buckets.key"
 )
 
+// defaultTPWRollups keeps 5 minute averages for 3 days and hourly averages for 4 weeks, next
+// to the raw data points of the last threadPoolWriteQueueDataSets runs
+var defaultTPWRollups = map[string]interface{}{"5m": "3d", "1h": "4w"}
+
+// maxTPWRollupBuckets bounds the buckets of a rollup per host
+const maxTPWRollupBuckets = 10000
+
 type clusterJobResult struct {
 	ClusterName string
 	Data        map[string]*types.TPWQueue
 	Hostnames   []string
+	OtherDocs   int64 // documents of the hosts beyond the host buckets of the query
 	Error       error
 }
 
-// GetThreadPoolWriteQueue collects thread pool write queue metrics from monitoring cluster
+// GetThreadPoolWriteQueue collects thread pool write queue metrics from the monitoring
+// clusters: each cluster is queried in the monitoring cluster config.yaml routes it to, else
+// in the one of the APIEndPoints and APIKEY parameters. The query and the resultsJsonPaths are
+// expanded with the query profile of each cluster, so legacy and agent data layouts mix.
 func GetThreadPoolWriteQueue(ctx context.Context, params map[string]interface{}) error {
 	logger.JobInfo("getThreadPoolWriteQueue", "Starting thread pool write queue monitoring job")
+	generation := time.Now().UnixMilli()
 
 	// Get parameters
-	excludeClusters := getStringSliceParam(params, "excludeClusters")
-	spanInterval := getStringParam(params, "spanInterval", "30s")
-	timeSpan := getStringParam(params, "timeSpan", "10m")
-	parallelRoutines := getIntParam(params, "parallelRoutines", 5)
-	insecureTLS := getBoolParam(params, "insecureTLS", false)
-	apiKey := getStringParam(params, "APIKEY", "")
-	apiEndpoints := getStringSliceParam(params, "APIEndPoints")
-	queryTemplate := getStringParam(params, "query", defaultQuery)
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("getThreadPoolWriteQueue", p)
+	spanInterval := p.String("spanInterval", defaultSpanInterval)
+	timeSpan := p.String("timeSpan", defaultTimeSpan)
+	parallelRoutines := p.Int("parallelRoutines", 5)
+	hostBuckets := p.IntInRange("hostBuckets", defaultHostBuckets, 1, 65536)
+	insecureTLS := p.Bool("insecureTLS", false)
+	apiKey := p.String("APIKEY", "")
+	router := newMonitoringRouter(p, p.StringSlice("APIEndPoints"), apiKey, insecureTLS)
+	queryTemplate := p.String("query", defaultQuery)
+	defaultProfile := p.String("profile", profileLegacy)
+	profileFields := p.Map("profileFields")
+	timeZone := p.String("timeZone", config.Global.TimeZone)
+	cacheTTL := p.Duration("cacheTTL", 0)
+	rollupsParam := defaultTPWRollups
+	if p.Has("rollups") {
+		rollupsParam = p.Map("rollups")
+	}
 
 	// Get JSON paths
-	resultsJsonPaths := getMapParam(params, "resultsJsonPaths")
-	hostNamePath := getStringFromMap(resultsJsonPaths, "hostName", defaultHostNamePath)
-	metricsPath := getStringFromMap(resultsJsonPaths, "metrics", defaultMetricsPath)
-	metricTimestampPath := getStringFromMap(resultsJsonPaths, "metricTimestamp", defaultMetricTimestampPath)
+	resultsJsonPaths := jobparams.New(p.Map("resultsJsonPaths"))
+	hostNamePath := resultsJsonPaths.String("hostName", defaultHostNamePath)
+	metricsPath := resultsJsonPaths.String("metrics", defaultMetricsPath)
+	metricTimestampPath := resultsJsonPaths.String("metricTimestamp", defaultMetricTimestampPath)
 
-	if len(apiEndpoints) == 0 {
-		return fmt.Errorf("APIEndPoints parameter is required")
+	if err := errors.Join(p.Err(), resultsJsonPaths.Err()); err != nil {
+		return err
+	}
+	if err := checkMonitoringParams(p); err != nil {
+		return err
 	}
-	if apiKey == "" {
-		return fmt.Errorf("APIKEY parameter is required")
+	if err := checkMonitoringProfiles(defaultProfile); err != nil {
+		return err
+	}
+	profiles, err := loadMonitoringProfiles(profileFields)
+	if err != nil {
+		return err
+	}
+	profilePaths := make(map[string]*tpwResponsePaths, len(profiles))
+	for name, profile := range profiles {
+		paths, err := parseTPWResponsePaths(profile.expand(hostNamePath), profile.expand(metricsPath),
+			profile.expand(metricTimestampPath))
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		profilePaths[name] = paths
+	}
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return fmt.Errorf("invalid timeZone %q: %w", timeZone, err)
 	}
 
 	// Calculate data points
+	window, err := parseTPWWindow(spanInterval, timeSpan)
+	if err != nil {
+		return err
+	}
+	rollups, err := parseTPWRollups(rollupsParam)
+	if err != nil {
+		return err
+	}
 	dataSets := config.Global.ThreadPoolWriteQueueDataSets
-	dataPointsInDataSet := parseTimeToDataPoints(timeSpan, spanInterval)
+	dataPointsInDataSet := window.pointsPerSet
 	numberOfDataPoints := int(dataSets) * dataPointsInDataSet
-	intervalMs := parseDurationToMillis(spanInterval)
+	intervalMs := window.interval.Milliseconds()
 
 	logger.JobInfo("getThreadPoolWriteQueue", "Config: dataSets=%d, pointsPerSet=%d, total=%d, intervalMs=%d",
 		dataSets, dataPointsInDataSet, numberOfDataPoints, intervalMs)
 
-	// Build cluster list and UUID map
-	types.ClustersMu.RLock()
-	clusterListForTPWQueue := make([]string, 0)
+	// Clusters without a UUID cannot be looked up in the monitoring cluster
 	mapClusterUUID := make(map[string]string)
-
-	for _, clusterName := range types.AllClustersList {
-		if utils.Contains(excludeClusters, clusterName) {
-			continue
-		}
-
-		cluster, exists := types.AllClusters[clusterName]
-		if !exists || cluster.ClusterUUID == "" {
-			logger.JobWarn("getThreadPoolWriteQueue", "Cluster %s has no UUID, skipping", clusterName)
-			continue
-		}
-
-		clusterListForTPWQueue = append(clusterListForTPWQueue, clusterName)
+	mapTarget := make(map[string]*monitoringTarget)
+	mapProfile := make(map[string]string)
+	profileErrs := make(map[string]error)
+	mapHostBuckets := make(map[string]int)
+	for clusterName, cluster := range types.SnapshotClusters() {
 		mapClusterUUID[clusterName] = cluster.ClusterUUID
+		// Every node of the inventory gets a bucket, whatever hostBuckets says
+		mapHostBuckets[clusterName] = max(hostBuckets, len(cluster.Nodes))
+		mapTarget[clusterName] = router.route(cluster)
+		mapProfile[clusterName], profileErrs[clusterName] = monitoringProfileOf(cluster, defaultProfile)
 	}
-	types.ClustersMu.RUnlock()
-
-	logger.JobInfo("getThreadPoolWriteQueue", "Processing %d clusters", len(clusterListForTPWQueue))
-
-	// Create HTTP client
-	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureTLS},
-		},
+	opts.MaxConcurrent = parallelRoutines
+	opts.SkipUUIDCheck = true // the write queues are read from the monitoring cluster by UUID
+	opts.Skip = func(cluster *types.ClusterData) string {
+		if cluster.ClusterUUID == "" {
+			return "has no UUID"
+		}
+		if mapTarget[cluster.ClusterName] == nil {
+			return "is not routed to a monitoring cluster"
+		}
+		if err := profileErrs[cluster.ClusterName]; err != nil {
+			return "has an " + err.Error()
+		}
+		return ""
 	}
 
 	// Process clusters in parallel
-	resultsChan := make(chan clusterJobResult, len(clusterListForTPWQueue))
-	semaphore := make(chan struct{}, parallelRoutines)
-	var wg sync.WaitGroup
-
-	for _, clusterName := range clusterListForTPWQueue {
-		wg.Add(1)
-		go func(cName string) {
-			defer wg.Done()
-			semaphore <- struct{}{}        // Acquire
-			defer func() { <-semaphore }() // Release
-
-			result := processCluster(ctx, cName, mapClusterUUID[cName], apiEndpoints, apiKey,
-				queryTemplate, spanInterval, timeSpan, httpClient,
-				hostNamePath, metricsPath, metricTimestampPath,
-				numberOfDataPoints, intervalMs, dataPointsInDataSet)
-			resultsChan <- result
-		}(clusterName)
-	}
-
-	// Wait for all goroutines and close channel
-	go func() {
-		wg.Wait()
-		close(resultsChan)
-	}()
-
-	// Collect results
-	successCount := 0
-	failCount := 0
-	for result := range resultsChan {
+	_, err = ForEachCluster(ctx, opts, func(ctx context.Context, cName string) error {
+		target, profile := mapTarget[cName], mapProfile[cName]
+		index := profiles[profile].index
+		if target.index != "" {
+			index = target.index
+		}
+		result := processCluster(ctx, cName, mapClusterUUID[cName], target.endpoints, target.apiKey,
+			indexExpression(index, window.span), profiles[profile], queryTemplate, mapHostBuckets[cName],
+			window.esInterval, window.esSpan, timeZone, target.client, cacheTTL, profilePaths[profile],
+			numberOfDataPoints, intervalMs)
 		if result.Error != nil {
-			logger.JobError("getThreadPoolWriteQueue", "Cluster %s failed: %v", result.ClusterName, result.Error)
-			failCount++
-			continue
+			return result.Error
+		}
+		if result.OtherDocs > 0 {
+			logger.JobWarn("getThreadPoolWriteQueue", "Cluster %s: hosts beyond the %d host buckets of the query were dropped "+
+				"(%d documents), raise hostBuckets", cName, mapHostBuckets[cName], result.OtherDocs)
 		}
 
 		// Update global structure (thread-safe)
-		updateGlobalTPWQueue(result.ClusterName, result.Data, result.Hostnames, numberOfDataPoints)
-		successCount++
-		logger.JobInfo("getThreadPoolWriteQueue", "Cluster %s processed successfully with %d hosts",
-			result.ClusterName, len(result.Hostnames))
-	}
-
-	logger.JobInfo("getThreadPoolWriteQueue", "Completed: %d succeeded, %d failed", successCount, failCount)
-	return nil
+		updateGlobalTPWQueue(result.ClusterName, generation, scheduler.RunID(ctx), result.Data, result.Hostnames, numberOfDataPoints,
+			intervalMs, rollups)
+		logger.JobInfo("getThreadPoolWriteQueue", "Cluster %s processed successfully with %d hosts (monitoring cluster %s, profile %s)",
+			result.ClusterName, len(result.Hostnames), target.name, profile)
+		return nil
+	})
+	return err
 }
 
-func processCluster(ctx context.Context, clusterName, clusterUUID string, apiEndpoints []string,
-	apiKey, queryTemplate, spanInterval, timeSpan string, httpClient *http.Client,
-	hostNamePath, metricsPath, metricTimestampPath string,
-	numberOfDataPoints int, intervalMs int64, dataPointsInDataSet int) clusterJobResult {
+func processCluster(ctx context.Context, clusterName, clusterUUID string, endpoints endpointSelection,
+	apiKey, index string, profile *monitoringProfile, queryTemplate string, hostBuckets int,
+	spanInterval, timeSpan, timeZone string, httpClient *http.Client, cacheTTL time.Duration,
+	paths *tpwResponsePaths, numberOfDataPoints int, intervalMs int64) clusterJobResult {
 
 	// Substitute macros in query
-	query := strings.ReplaceAll(queryTemplate, "__UUID__", clusterUUID)
+	query := strings.ReplaceAll(profile.expand(queryTemplate), "__UUID__", clusterUUID)
 	query = strings.ReplaceAll(query, "__INTERVAL__", spanInterval)
 	query = strings.ReplaceAll(query, "__TIME_SPAN__", timeSpan)
+	query = strings.ReplaceAll(query, "__TIME_ZONE__", timeZone)
+	query = strings.ReplaceAll(query, "__HOST_BUCKETS__", strconv.Itoa(hostBuckets))
 
-	// Try each endpoint
-	var responseData map[string]interface{}
+	// Try the endpoints, healthiest first; endpoints that fail are put in cooldown
+	var responseData []byte
 	var lastErr error
 
-	for _, endpoint := range apiEndpoints {
-		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBufferString(query))
+	for _, endpoint := range endpoints.ordered() {
+		// The endpoint health is tracked per configured endpoint, whichever index it searches
+		req, err := http.NewRequestWithContext(ctx, "POST", monitoringSearchURL(endpoint, index), bytes.NewBufferString(query))
 		if err != nil {
 			lastErr = err
 			continue
@@ -275,29 +314,33 @@ func processCluster(ctx context.Context, clusterName, clusterUUID string, apiEnd
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("Authorization", "ApiKey "+apiKey)
 
-		resp, err := httpClient.Do(req)
+		// The data belongs to the monitored cluster, whichever endpoint answers
+		respBody, err := doQuery(httpClient, req, []byte(query), queryOptions{
+			JobName: "getThreadPoolWriteQueue", Cluster: clusterName, TTL: cacheTTL})
 		if err != nil {
 			lastErr = err
+			var statusErr *httpStatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode >= 500 {
+				endpoints.failed(ctx, endpoint, err)
+			}
 			continue
 		}
 
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
+		body, err := io.ReadAll(respBody)
+		respBody.Close()
 
 		if err != nil {
 			lastErr = err
+			endpoints.failed(ctx, endpoint, err)
 			continue
 		}
+		endpoints.succeeded(endpoint)
 
-		if resp.StatusCode != 200 {
-			lastErr = fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
-			continue
-		}
-
-		if err := json.Unmarshal(body, &responseData); err != nil {
-			lastErr = err
+		if !json.Valid(body) {
+			lastErr = fmt.Errorf("invalid JSON response from %s", endpoint)
 			continue
 		}
+		responseData = body
 
 		// Success
 		lastErr = nil
@@ -309,8 +352,9 @@ func processCluster(ctx context.Context, clusterName, clusterUUID string, apiEnd
 	}
 
 	// Parse response
-	hostData, hostnames, err := parseTPWQueueResponse(responseData, hostNamePath, metricsPath,
-		metricTimestampPath, numberOfDataPoints, intervalMs, dataPointsInDataSet)
+	cluster, _ := types.GetCluster(clusterName)
+	hostData, hostnames, otherDocs, err := parseTPWQueueResponse(responseData, paths, newHostResolver(cluster),
+		numberOfDataPoints, intervalMs)
 	if err != nil {
 		return clusterJobResult{ClusterName: clusterName, Error: err}
 	}
@@ -319,296 +363,421 @@ func processCluster(ctx context.Context, clusterName, clusterUUID string, apiEnd
 		ClusterName: clusterName,
 		Data:        hostData,
 		Hostnames:   hostnames,
+		OtherDocs:   otherDocs,
 		Error:       nil,
 	}
 }
 
-func parseTPWQueueResponse(data map[string]interface{}, hostNamePath, metricsPath,
-	metricTimestampPath string, numberOfDataPoints int, intervalMs int64,
-	dataPointsInDataSet int) (map[string]*types.TPWQueue, []string, error) {
+// tpwResponsePaths locate the data in the response to the thread pool write queue query. They
+// are derived from the resultsJsonPaths: hostName "<hostBuckets>.<hostKey>", metricTimestamp
+// "<hostBuckets>.<dateBuckets>.<dateKey>" and metrics
+// "<hostBuckets>.<dateBuckets>.<topMetrics>.top_metrics.metrics.<metric>".
+type tpwResponsePaths struct {
+	hostBuckets string // from the response, e.g. aggregations.hostname.buckets
+	hostKey     string // from a host bucket, e.g. key
+	dateBuckets string // from a host bucket, e.g. date_bucket.buckets
+	dateKey     string // from a date bucket, e.g. key
+	topMetrics  string // from a date bucket, the top_metrics aggregation, e.g. 2
+	metric      string // field of the top metric, e.g. node_stats.thread_pool.write.queue
+}
+
+// parseTPWResponsePaths splits the resultsJsonPaths into the parts of tpwResponsePaths
+func parseTPWResponsePaths(hostNamePath, metricsPath, metricTimestampPath string) (*tpwResponsePaths, error) {
+	paths := &tpwResponsePaths{}
+	var ok bool
 
-	// Navigate to hostname buckets
-	aggregations, ok := data["aggregations"].(map[string]interface{})
+	if paths.hostBuckets, paths.hostKey, ok = cutLast(hostNamePath, "."); !ok {
+		return nil, fmt.Errorf("invalid resultsJsonPaths.hostName %q, expected <buckets>.<key>", hostNamePath)
+	}
+	dateKeyPath, ok := strings.CutPrefix(metricTimestampPath, paths.hostBuckets+".")
+	if ok {
+		paths.dateBuckets, paths.dateKey, ok = cutLast(dateKeyPath, ".")
+	}
 	if !ok {
-		return nil, nil, fmt.Errorf("aggregations not found")
+		return nil, fmt.Errorf("invalid resultsJsonPaths.metricTimestamp %q, expected %s.<date buckets>.<key>",
+			metricTimestampPath, paths.hostBuckets)
 	}
+	metricPath, ok := strings.CutPrefix(metricsPath, paths.hostBuckets+"."+paths.dateBuckets+".")
+	if ok {
+		paths.topMetrics, paths.metric, ok = strings.Cut(metricPath, ".top_metrics.metrics.")
+	}
+	if !ok || paths.topMetrics == "" || paths.metric == "" {
+		return nil, fmt.Errorf("invalid resultsJsonPaths.metrics %q, expected %s.%s.<aggregation>.top_metrics.metrics.<field>",
+			metricsPath, paths.hostBuckets, paths.dateBuckets)
+	}
+	return paths, nil
+}
 
-	hostname, ok := aggregations["hostname"].(map[string]interface{})
-	if !ok {
-		return nil, nil, fmt.Errorf("hostname aggregation not found")
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i <= 0 || i+len(sep) == len(s) {
+		return "", "", false
 	}
+	return s[:i], s[i+len(sep):], true
+}
 
-	buckets, ok := hostname["buckets"].([]interface{})
-	if !ok {
-		return nil, nil, fmt.Errorf("buckets not found")
+// tpwTopMetrics is a top_metrics aggregation of a date bucket; top is empty for buckets
+// without documents
+type tpwTopMetrics struct {
+	Top []struct {
+		Metrics map[string]*float64 `json:"metrics"`
+	} `json:"top"`
+}
+
+// parseTPWQueueResponse reads the write queue data points of every host from the response,
+// and the sum_other_doc_count of a terms aggregation of the hosts: documents of hosts that did
+// not fit in its buckets. Date buckets without a value are missing data points; a response of
+// another shape is an error.
+func parseTPWQueueResponse(data []byte, paths *tpwResponsePaths, hosts *hostResolver, numberOfDataPoints int,
+	intervalMs int64) (map[string]*types.TPWQueue, []string, int64, error) {
+
+	var buckets []json.RawMessage
+	if err := decodePath(data, paths.hostBuckets, &buckets); err != nil {
+		return nil, nil, 0, err
+	}
+	var otherDocs int64
+	if aggregation, ok := strings.CutSuffix(paths.hostBuckets, ".buckets"); ok {
+		// Other aggregations of the hosts have none
+		_ = decodePath(data, aggregation+".sum_other_doc_count", &otherDocs)
 	}
 
 	hostData := make(map[string]*types.TPWQueue)
 	hostnames := make([]string, 0, len(buckets))
 
 	for _, bucket := range buckets {
-		bucketMap, ok := bucket.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
 		// Get hostname
-		hostName, ok := bucketMap["key"].(string)
-		if !ok {
-			continue
+		var hostName string
+		if err := decodePath(bucket, paths.hostKey, &hostName); err != nil {
+			return nil, nil, 0, fmt.Errorf("host bucket: %w", err)
 		}
-
-		// Get date_bucket
-		dateBucket, ok := bucketMap["date_bucket"].(map[string]interface{})
-		if !ok {
-			continue
+		if hostName == "" {
+			return nil, nil, 0, fmt.Errorf("host bucket: empty %s", paths.hostKey)
 		}
-
-		dateBuckets, ok := dateBucket["buckets"].([]interface{})
-		if !ok {
-			continue
+		// Buckets of aliases of a host (e.g. its IP address and FQDN) are one host
+		hostName = hosts.resolve(hostName)
+		tpwq, seen := hostData[hostName]
+		if !seen {
+			tpwq = types.NewBucketedTPWQueue(numberOfDataPoints, intervalMs)
+			hostData[hostName] = tpwq
+			hostnames = append(hostnames, hostName)
 		}
 
-		// Initialize TPWQueue for this host
-		tpwq := &types.TPWQueue{
-			NumberOfDataPoints:    numberOfDataPoints,
-			TimeStamps:            make([]int64, numberOfDataPoints),
-			ThreadPoolWriteQueues: make([]uint32, numberOfDataPoints),
-			DataExists:            make([]bool, numberOfDataPoints),
+		var dateBuckets []json.RawMessage
+		if err := decodePath(bucket, paths.dateBuckets, &dateBuckets); err != nil {
+			return nil, nil, 0, fmt.Errorf("host %s: %w", hostName, err)
 		}
 
-		// Extract metrics and timestamps
-		dataPoints := make([]struct {
-			timestamp int64
-			metric    uint32
-		}, 0, len(dateBuckets))
-
+		// Store the data points by bucket
 		for _, db := range dateBuckets {
-			dbMap, ok := db.(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			// Get timestamp
-			tsVal, ok := dbMap["key"].(float64)
-			if !ok {
-				continue
-			}
-			timestamp := int64(tsVal)
-
-			// Get metric
-			topMetrics, ok := dbMap["2"].(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			topArray, ok := topMetrics["top"].([]interface{})
-			if !ok || len(topArray) == 0 {
-				continue
+			var timestamp int64
+			if err := decodePath(db, paths.dateKey, &timestamp); err != nil {
+				return nil, nil, 0, fmt.Errorf("host %s: %w", hostName, err)
 			}
-
-			topItem, ok := topArray[0].(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			metrics, ok := topItem["metrics"].(map[string]interface{})
-			if !ok {
-				continue
+			var topMetrics tpwTopMetrics
+			if err := decodePath(db, paths.topMetrics, &topMetrics); err != nil {
+				return nil, nil, 0, fmt.Errorf("host %s: %w", hostName, err)
 			}
-
-			metricVal, ok := metrics["node_stats.thread_pool.write.queue"].(float64)
-			if !ok {
+			if len(topMetrics.Top) == 0 || topMetrics.Top[0].Metrics[paths.metric] == nil {
 				continue
 			}
-
-			dataPoints = append(dataPoints, struct {
-				timestamp int64
-				metric    uint32
-			}{timestamp, uint32(metricVal)})
+			tpwq.Add(types.TPWPoint{
+				TimeStamp: timestamp,
+				Queue:     uint32(*topMetrics.Top[0].Metrics[paths.metric]),
+				Exists:    true,
+			})
 		}
-
-		// Sort by timestamp descending (latest first)
-		sort.Slice(dataPoints, func(i, j int) bool {
-			return dataPoints[i].timestamp > dataPoints[j].timestamp
-		})
-
-		// Fill in the data (latest at index 0)
-		if len(dataPoints) > 0 {
-			latestTime := dataPoints[0].timestamp
-
-			for i, dp := range dataPoints {
-				if i >= dataPointsInDataSet {
-					break
-				}
-
-				expectedIndex := int((latestTime - dp.timestamp) / intervalMs)
-				if expectedIndex < 0 || expectedIndex >= dataPointsInDataSet {
-					continue
-				}
-
-				tpwq.TimeStamps[expectedIndex] = dp.timestamp
-				tpwq.ThreadPoolWriteQueues[expectedIndex] = dp.metric
-				tpwq.DataExists[expectedIndex] = true
-			}
-		}
-
-		hostData[hostName] = tpwq
-		hostnames = append(hostnames, hostName)
 	}
 
-	return hostData, hostnames, nil
+	return hostData, hostnames, otherDocs, nil
 }
 
-func updateGlobalTPWQueue(clusterName string, newData map[string]*types.TPWQueue,
-	hostnames []string, numberOfDataPoints int) {
+// updateGlobalTPWQueue merges the data of a run into the write queues of a cluster. The data
+// points are merged by time stamp, so runs overlapping each other (a slow monitoring cluster)
+// cannot misalign them and merging a run twice changes nothing. Only the newest run by
+// generation (its start) adds and removes hosts: an older run finishing later only fills in
+// the data points of the hosts that are left. The job run (runID) of the newest run is kept with
+// its generation.
+func updateGlobalTPWQueue(clusterName string, generation int64, runID string, newData map[string]*types.TPWQueue,
+	hostnames []string, numberOfDataPoints int, intervalMs int64, rollups []tpwRollupSpec) {
 
 	types.TPWQueueMu.Lock()
 	defer types.TPWQueueMu.Unlock()
 
 	existing, exists := types.AllThreadPoolWriteQueues[clusterName]
-
 	if !exists {
-		// First time - just store the data
-		types.AllThreadPoolWriteQueues[clusterName] = &types.ClustersTPWQueue{
-			HostnameList: hostnames,
-			HostTPWQueue: newData,
+		existing = &types.ClustersTPWQueue{
+			HostnameList: make([]string, 0, len(hostnames)),
+			HostTPWQueue: make(map[string]*types.TPWQueue, len(hostnames)),
 		}
-		return
+		types.AllThreadPoolWriteQueues[clusterName] = existing
+	}
+	current := generation >= existing.Generation
+	if !current {
+		logger.JobInfo("getThreadPoolWriteQueue", "Cluster %s: merging the data of a run overtaken by a newer one, its hosts are not applied",
+			clusterName)
 	}
 
-	// Roll existing data and merge with new data
-	dataPointsInDataSet := numberOfDataPoints / int(config.Global.ThreadPoolWriteQueueDataSets)
-
-	for hostName, newTPWQ := range newData {
-		existingTPWQ, hostExists := existing.HostTPWQueue[hostName]
-
+	for _, hostName := range hostnames {
+		tpwq, hostExists := existing.HostTPWQueue[hostName]
 		if !hostExists {
-			// New host - just add it
-			existing.HostTPWQueue[hostName] = newTPWQ
+			if !current {
+				continue
+			}
+			tpwq = types.NewTPWQueue(numberOfDataPoints)
+			existing.HostTPWQueue[hostName] = tpwq
 			existing.HostnameList = append(existing.HostnameList, hostName)
-			continue
 		}
-
-		// Roll the data: move existing data down by dataPointsInDataSet positions
-		rollTPWQueueData(existingTPWQ, newTPWQ, dataPointsInDataSet)
+		mergeTPWQueuePoints(tpwq, newData[hostName], numberOfDataPoints, intervalMs)
+		rollUpTPWQueue(tpwq, newData[hostName], rollups)
 	}
 
-	// Remove hosts that are no longer present
-	newHostSet := make(map[string]bool)
-	for _, h := range hostnames {
-		newHostSet[h] = true
-	}
+	if current {
+		existing.Generation = generation
+		existing.RunID = runID
 
-	updatedHostList := make([]string, 0, len(hostnames))
-	for _, h := range existing.HostnameList {
-		if newHostSet[h] {
-			updatedHostList = append(updatedHostList, h)
-		} else {
-			delete(existing.HostTPWQueue, h)
+		// Remove hosts that are no longer present
+		newHostSet := make(map[string]bool)
+		for _, h := range hostnames {
+			newHostSet[h] = true
 		}
-	}
-	existing.HostnameList = updatedHostList
-}
-
-func rollTPWQueueData(existing, new *types.TPWQueue, dataPointsInDataSet int) {
-	totalSize := existing.NumberOfDataPoints
 
-	// Roll down: move data from position i to position i+dataPointsInDataSet
-	for i := totalSize - 1; i >= dataPointsInDataSet; i-- {
-		sourceIdx := i - dataPointsInDataSet
-		existing.TimeStamps[i] = existing.TimeStamps[sourceIdx]
-		existing.ThreadPoolWriteQueues[i] = existing.ThreadPoolWriteQueues[sourceIdx]
-		existing.DataExists[i] = existing.DataExists[sourceIdx]
+		updatedHostList := make([]string, 0, len(hostnames))
+		for _, h := range existing.HostnameList {
+			if newHostSet[h] {
+				updatedHostList = append(updatedHostList, h)
+			} else {
+				delete(existing.HostTPWQueue, h)
+			}
+		}
+		existing.HostnameList = updatedHostList
 	}
 
-	// Copy new data into positions 0 to dataPointsInDataSet-1
-	for i := 0; i < dataPointsInDataSet && i < len(new.TimeStamps); i++ {
-		existing.TimeStamps[i] = new.TimeStamps[i]
-		existing.ThreadPoolWriteQueues[i] = new.ThreadPoolWriteQueues[i]
-		existing.DataExists[i] = new.DataExists[i]
-	}
+	types.PublishTPWQueue(clusterName, existing)
+	updateWriteQueueMetrics(clusterName, existing)
 }
 
-// Helper functions
-func getStringSliceParam(params map[string]interface{}, key string) []string {
-	if val, ok := params[key].([]interface{}); ok {
-		result := make([]string, 0, len(val))
-		for _, item := range val {
-			if str, ok := item.(string); ok {
-				result = append(result, str)
-			}
+// updateWriteQueueMetrics exports the latest existing data point of every host of a cluster.
+// Hosts that left the cluster or have no data point lose their series.
+func updateWriteQueueMetrics(clusterName string, cluster *types.ClustersTPWQueue) {
+	clusterLabel := prometheus.Labels{"cluster": clusterName}
+	metrics.ThreadPoolWriteQueue.DeletePartialMatch(clusterLabel)
+	metrics.ThreadPoolWriteQueueTimestampSeconds.DeletePartialMatch(clusterLabel)
+
+	for hostName, tpwq := range cluster.HostTPWQueue {
+		if point, ok := tpwq.Points.Latest(); ok {
+			metrics.ThreadPoolWriteQueue.WithLabelValues(clusterName, hostName).Set(float64(point.Queue))
+			metrics.ThreadPoolWriteQueueTimestampSeconds.WithLabelValues(clusterName, hostName).
+				Set(float64(point.TimeStamp) / 1000)
 		}
-		return result
 	}
-	return []string{}
 }
 
-func getStringParam(params map[string]interface{}, key, defaultVal string) string {
-	if val, ok := params[key].(string); ok && val != "" {
-		return val
+// mergeTPWQueuePoints adds the data points of a run to the buckets of a host (see
+// TPWQueue.Add). A host stored with another interval or number of data points, or before the
+// data points were kept by bucket, is rebuilt from its data points first.
+func mergeTPWQueuePoints(tpwq, run *types.TPWQueue, numberOfDataPoints int, intervalMs int64) {
+	if tpwq.IntervalMs != intervalMs || tpwq.Points.Cap() != numberOfDataPoints {
+		rebuilt := types.NewBucketedTPWQueue(numberOfDataPoints, intervalMs)
+		for _, point := range tpwq.Points.WithData() {
+			rebuilt.Add(point)
+		}
+		tpwq.NumberOfDataPoints, tpwq.IntervalMs = rebuilt.NumberOfDataPoints, rebuilt.IntervalMs
+		tpwq.NewestStart, tpwq.Points = rebuilt.NewestStart, rebuilt.Points
+	}
+	for _, point := range run.Points.OldestFirst() {
+		tpwq.Add(point)
 	}
-	return defaultVal
 }
 
-func getIntParam(params map[string]interface{}, key string, defaultVal int) int {
-	if val, ok := params[key].(int); ok {
-		return val
+// rollUpTPWQueue adds the data points of a run that are newer than those already rolled up
+// to the rollups of a host. Rollups are first matched to the configuration: new ones start
+// empty, dropped ones are removed and resized ones keep their latest buckets.
+func rollUpTPWQueue(tpwq, run *types.TPWQueue, rollups []tpwRollupSpec) {
+	current := make([]*types.TPWRollup, 0, len(rollups))
+	for _, spec := range rollups {
+		rollup := tpwq.Rollup(spec.intervalMs)
+		if rollup == nil {
+			rollup = types.NewTPWRollup(spec.intervalMs, spec.buckets)
+		} else if rollup.Points.Cap() != spec.buckets {
+			resized := types.NewTPWRollup(spec.intervalMs, spec.buckets)
+			resized.NewestStart = rollup.NewestStart
+			for i := 0; i < spec.buckets && i < rollup.Points.Cap(); i++ {
+				resized.Points.Set(i, rollup.Points.At(i))
+			}
+			rollup = resized
+		}
+		current = append(current, rollup)
 	}
-	if val, ok := params[key].(float64); ok {
-		return int(val)
+	tpwq.Rollups = current
+
+	for _, point := range run.Points.OldestFirst() {
+		if !point.Exists || point.TimeStamp <= tpwq.RolledUpTo {
+			continue
+		}
+		for _, rollup := range tpwq.Rollups {
+			rollup.Add(point)
+		}
+		tpwq.RolledUpTo = point.TimeStamp
 	}
-	return defaultVal
 }
 
-func getBoolParam(params map[string]interface{}, key string, defaultVal bool) bool {
-	if val, ok := params[key].(bool); ok {
-		return val
-	}
-	return defaultVal
+// tpwRollupSpec is a rollup of the rollups parameter: buckets of intervalMs, as many as the
+// retention holds
+type tpwRollupSpec struct {
+	intervalMs int64
+	buckets    int
 }
 
-func getMapParam(params map[string]interface{}, key string) map[string]interface{} {
-	if val, ok := params[key].(map[string]interface{}); ok {
-		return val
+// parseTPWRollups parses the rollups parameter, a map of bucket interval to retention such as
+// {"5m": "3d", "1h": "4w"}, into rollups sorted finest first. An empty map disables them.
+func parseTPWRollups(rollups map[string]interface{}) ([]tpwRollupSpec, error) {
+	specs := make([]tpwRollupSpec, 0, len(rollups))
+	for intervalText, retentionValue := range rollups {
+		interval, err := utils.ParseDuration(intervalText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rollups interval %q: %w", intervalText, err)
+		}
+		if interval < time.Minute || interval%time.Second != 0 {
+			return nil, fmt.Errorf("rollups interval %s must be a whole number of seconds (at least 1m)", intervalText)
+		}
+		retentionText, ok := retentionValue.(string)
+		if !ok {
+			return nil, fmt.Errorf("rollups retention of %s must be a duration string, got %T", intervalText, retentionValue)
+		}
+		retention, err := utils.ParseDuration(retentionText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rollups retention of %s: %w", intervalText, err)
+		}
+		buckets := int(retention / interval)
+		if buckets < 1 || buckets > maxTPWRollupBuckets {
+			return nil, fmt.Errorf("rollups %s: retention %s must hold between 1 and %d intervals", intervalText, retentionText, maxTPWRollupBuckets)
+		}
+		for _, spec := range specs {
+			if spec.intervalMs == interval.Milliseconds() {
+				return nil, fmt.Errorf("rollups interval %s is configured twice", intervalText)
+			}
+		}
+		specs = append(specs, tpwRollupSpec{intervalMs: interval.Milliseconds(), buckets: buckets})
 	}
-	return make(map[string]interface{})
+	sort.Slice(specs, func(i, j int) bool { return specs[i].intervalMs < specs[j].intervalMs })
+	return specs, nil
 }
 
-func getStringFromMap(m map[string]interface{}, key, defaultVal string) string {
-	if val, ok := m[key].(string); ok && val != "" {
-		return val
-	}
-	return defaultVal
+// tpwWindow is the bucketing of a getThreadPoolWriteQueue run: timeSpan of data in
+// spanInterval buckets
+type tpwWindow struct {
+	interval     time.Duration
+	span         time.Duration
+	esInterval   string // interval in Elasticsearch fixed_interval syntax
+	esSpan       string // span in Elasticsearch date math syntax
+	pointsPerSet int
 }
 
-func parseDurationToMillis(duration string) int64 {
-	duration = strings.ToLower(strings.TrimSpace(duration))
+// parseTPWWindow parses and validates spanInterval and timeSpan. The interval must be a
+// whole number of seconds and the span a non-zero multiple of it, so every run yields the
+// same number of buckets.
+func parseTPWWindow(spanInterval, timeSpan string) (*tpwWindow, error) {
+	interval, err := utils.ParseDuration(spanInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spanInterval: %w", err)
+	}
+	span, err := utils.ParseDuration(timeSpan)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeSpan: %w", err)
+	}
 
-	var value int64
-	var unit string
+	if interval < time.Second || interval%time.Second != 0 {
+		return nil, fmt.Errorf("spanInterval %s must be a whole number of seconds (at least 1s)", spanInterval)
+	}
+	if span < interval {
+		return nil, fmt.Errorf("timeSpan %s is shorter than spanInterval %s", timeSpan, spanInterval)
+	}
+	if span%interval != 0 {
+		return nil, fmt.Errorf("timeSpan %s is not a multiple of spanInterval %s", timeSpan, spanInterval)
+	}
 
-	fmt.Sscanf(duration, "%d%s", &value, &unit)
+	return &tpwWindow{
+		interval:     interval,
+		span:         span,
+		esInterval:   esTimeUnit(interval),
+		esSpan:       esTimeUnit(span),
+		pointsPerSet: int(span / interval),
+	}, nil
+}
 
-	switch unit {
-	case "s", "sec", "second", "seconds":
-		return value * 1000
-	case "m", "min", "minute", "minutes":
-		return value * 60 * 1000
-	case "h", "hour", "hours":
-		return value * 60 * 60 * 1000
-	default:
-		return value * 1000 // Default to seconds
+// esTimeUnit renders a duration with the largest single unit that represents it exactly
+// ("90m", "2d"), as Elasticsearch fixed intervals do not accept composite values
+func esTimeUnit(d time.Duration) string {
+	for _, u := range []struct {
+		name string
+		size time.Duration
+	}{{"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}} {
+		if d%u.size == 0 {
+			return fmt.Sprintf("%d%s", d/u.size, u.name)
+		}
 	}
+	return fmt.Sprintf("%dms", d.Milliseconds())
 }
 
-func parseTimeToDataPoints(timeSpan, interval string) int {
-	spanMs := parseDurationToMillis(timeSpan)
-	intervalMs := parseDurationToMillis(interval)
-
-	if intervalMs == 0 {
-		return 20 // Default
+// checkMonitoringParams checks that the job has a monitoring cluster to query: its own
+// APIEndPoints with an APIKEY, or the monitoringClusters of config.yaml
+func checkMonitoringParams(p *jobparams.Reader) error {
+	endpoints := p.StringSlice("APIEndPoints")
+	apiKey := p.String("APIKEY", "")
+	if len(endpoints) > 0 && apiKey == "" {
+		return fmt.Errorf("APIKEY is required with APIEndPoints")
+	}
+	if len(endpoints) == 0 && len(config.Global.MonitoringClusters) == 0 {
+		return fmt.Errorf("APIEndPoints and APIKEY are required unless monitoringClusters are configured in config.yaml")
 	}
+	return nil
+}
 
-	return int(spanMs / intervalMs)
+// ValidateThreadPoolWriteQueueParams checks the spanInterval/timeSpan combination, the time
+// zone, the rollups, the query profiles, the resultsJsonPaths and the monitoring cluster when
+// the job is loaded, so a bad configuration is reported before the first run
+func ValidateThreadPoolWriteQueueParams(params map[string]interface{}) error {
+	p := jobparams.New(params)
+	spanInterval := p.String("spanInterval", defaultSpanInterval)
+	timeSpan := p.String("timeSpan", defaultTimeSpan)
+	timeZone := p.String("timeZone", "")
+	rollups := p.Map("rollups")
+	defaultProfile := p.String("profile", profileLegacy)
+	profileFields := p.Map("profileFields")
+	p.String("index", "")
+	p.IntInRange("hostBuckets", defaultHostBuckets, 1, 65536)
+	resultsJsonPaths := jobparams.New(p.Map("resultsJsonPaths"))
+	hostNamePath := resultsJsonPaths.String("hostName", defaultHostNamePath)
+	metricsPath := resultsJsonPaths.String("metrics", defaultMetricsPath)
+	metricTimestampPath := resultsJsonPaths.String("metricTimestamp", defaultMetricTimestampPath)
+	if err := errors.Join(p.Err(), resultsJsonPaths.Err()); err != nil {
+		return err
+	}
+	if err := checkMonitoringParams(p); err != nil {
+		return err
+	}
+	if err := checkMonitoringProfiles(defaultProfile); err != nil {
+		return err
+	}
+	profiles, err := loadMonitoringProfiles(profileFields)
+	if err != nil {
+		return err
+	}
+	for name, profile := range profiles {
+		if _, err := parseTPWResponsePaths(profile.expand(hostNamePath), profile.expand(metricsPath),
+			profile.expand(metricTimestampPath)); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	if _, err := parseTPWRollups(rollups); err != nil {
+		return err
+	}
+	if timeZone != "" {
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			return fmt.Errorf("invalid timeZone %q: %w", timeZone, err)
+		}
+	}
+	_, err = parseTPWWindow(spanInterval, timeSpan)
+	return err
 }
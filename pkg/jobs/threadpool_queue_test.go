@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"io"
+	"testing"
+)
+
+// TestParseTPWQueueGolden parses a monitoring search of three hosts, one reported by its IP
+// address, with date buckets without documents and hosts beyond the terms aggregation
+func TestParseTPWQueueGolden(t *testing.T) {
+	data, err := io.ReadAll(openTestdata(t, "monitoring_search.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := defaultTPWResponsePaths(t)
+
+	queues, hostnames, otherDocs, err := parseTPWQueueResponse(data, paths, newHostResolver(goldenCluster), 20, 30000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "monitoring_search", map[string]interface{}{
+		"hostnames": hostnames,
+		"otherDocs": otherDocs,
+		"queues":    queues,
+	})
+}
+
+func TestParseTPWQueueInvalid(t *testing.T) {
+	paths := defaultTPWResponsePaths(t)
+	for name, body := range map[string]string{
+		"no aggregations":         `{"hits":{"hits":[]}}`,
+		"host bucket without key": `{"aggregations":{"hostname":{"buckets":[{"date_bucket":{"buckets":[]}}]}}}`,
+		"date bucket without key": `{"aggregations":{"hostname":{"buckets":[{"key":"h1","date_bucket":{"buckets":[{"2":{"top":[]}}]}}]}}}`,
+		"not json":                `<html>`,
+	} {
+		if _, _, _, err := parseTPWQueueResponse([]byte(body), paths, newHostResolver(nil), 20, 30000); err == nil {
+			t.Errorf("%s: parseTPWQueueResponse = nil error", name)
+		}
+	}
+}
+
+// defaultTPWResponsePaths returns the response paths of the default query of the legacy profile
+func defaultTPWResponsePaths(t *testing.T) *tpwResponsePaths {
+	t.Helper()
+	profile := monitoringProfiles[profileLegacy]
+	paths, err := parseTPWResponsePaths(profile.expand(defaultHostNamePath), profile.expand(defaultMetricsPath),
+		profile.expand(defaultMetricTimestampPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
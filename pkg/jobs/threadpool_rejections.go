@@ -0,0 +1,251 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/notify"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// threadPoolRejectionsSource is the event store source of sustained thread pool rejections
+const threadPoolRejectionsSource = "threadPoolRejections"
+
+// nodesThreadPoolStats is the part of the _nodes/stats/thread_pool response that is used
+type nodesThreadPoolStats struct {
+	Nodes map[string]struct {
+		Host       string   `json:"host"`
+		Name       string   `json:"name"`
+		Roles      []string `json:"roles"`
+		ThreadPool map[string]struct {
+			Queue    uint32 `json:"queue"`
+			Active   uint32 `json:"active"`
+			Rejected uint64 `json:"rejected"`
+		} `json:"thread_pool"`
+	} `json:"nodes"`
+}
+
+// GetThreadPoolRejections samples the rejected executions of every thread pool of every node
+// with _nodes/stats/thread_pool and keeps the latest historySize samples per pool, each with
+// the rejections since the previous sample. A pool whose rejections grew by at least
+// minRejections in each of the latest consecutiveSamples samples fires an event in the event
+// store; the event resolves once a sample shows no such growth.
+func GetThreadPoolRejections(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("getThreadPoolRejections", "Starting thread pool rejections collection")
+
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("getThreadPoolRejections", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 5, 1, 20)
+	historySize := p.IntInRange("historySize", 60, 2, 2016)
+	pools := p.StringSlice("pools")
+	minRejections := p.IntInRange("minRejections", 1, 1, 1000000)
+	consecutiveSamples := p.IntInRange("consecutiveSamples", 3, 1, 2016)
+	severity := p.OneOf("severity", "warning", "info", "warning", "critical")
+	cacheTTL := p.Duration("cacheTTL", 0)
+	notifyOwners := p.Bool("notifyOwners", false)
+	if err := checkParams("getThreadPoolRejections", p); err != nil {
+		return err
+	}
+	if consecutiveSamples > historySize {
+		return fmt.Errorf("consecutiveSamples %d exceeds historySize %d", consecutiveSamples, historySize)
+	}
+
+	now := time.Now()
+	var mu sync.Mutex
+	collected := make(map[string]bool)
+
+	_, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		var stats nodesThreadPoolStats
+		query := queryOptions{JobName: "getThreadPoolRejections", TTL: cacheTTL}
+		if err := getClusterJSON(ctx, cluster, "/_nodes/stats/thread_pool", query, &stats); err != nil {
+			return fmt.Errorf("failed to fetch node thread pool stats: %w", err)
+		}
+
+		nowMs := utils.TimeNowMillis()
+		points := make(map[string]map[string]types.RejectionPoint, len(stats.Nodes))
+		roles := make(map[string][]string, len(stats.Nodes))
+		hosts := newHostResolver(cluster)
+		for _, node := range stats.Nodes {
+			hostName := hosts.nodeHostName(node.Host, node.Name)
+			nodePoints := make(map[string]types.RejectionPoint, len(node.ThreadPool))
+			for pool, tp := range node.ThreadPool {
+				if len(pools) > 0 && !utils.Contains(pools, pool) {
+					continue
+				}
+				nodePoints[pool] = types.RejectionPoint{
+					TimeStamp: nowMs,
+					Rejected:  tp.Rejected,
+					Queue:     tp.Queue,
+					Active:    tp.Active,
+				}
+			}
+			points[hostName] = nodePoints
+			roles[hostName] = node.Roles
+		}
+
+		types.AddThreadPoolRejections(clusterName, nowMs, roles, points, historySize)
+		rejections, _ := types.GetThreadPoolRejections(clusterName)
+		recordRejectionMetrics(clusterName, rejections)
+
+		mu.Lock()
+		collected[clusterName] = true
+		mu.Unlock()
+
+		logger.JobInfo("getThreadPoolRejections", "Cluster %s: %d nodes sampled", clusterName, len(points))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Rejections of clusters that could not be sampled this time are kept as they are
+	observed := make([]events.Observation, 0)
+	for clusterName := range collected {
+		rejections, ok := types.GetThreadPoolRejections(clusterName)
+		if !ok {
+			continue
+		}
+		for hostName, history := range rejections.Nodes {
+			for pool, ring := range history.Pools {
+				sustained, startTime, rejected := sustainedRejections(ring, uint64(minRejections), consecutiveSamples)
+				if !sustained {
+					continue
+				}
+				observed = append(observed, events.Observation{
+					Name:     "ThreadPoolRejections",
+					Severity: severity,
+					Labels:   map[string]string{"cluster": clusterName, "host": hostName, "pool": pool},
+					Annotations: map[string]string{
+						"summary": fmt.Sprintf("Thread pool %s rejected >= %d executions in each of %d consecutive samples",
+							pool, minRejections, consecutiveSamples),
+						"rejected":    strconv.FormatUint(rejected, 10),
+						"latestDelta": strconv.FormatUint(ring.At(0).Delta, 10),
+						"queue":       strconv.FormatUint(uint64(ring.At(0).Queue), 10),
+					},
+					StartsAt: startTime,
+				})
+			}
+		}
+	}
+	sustainedCount := len(observed)
+	observed = append(observed, carriedOverEvents(threadPoolRejectionsSource, collected)...)
+
+	fired, resolved, err := events.Sync(threadPoolRejectionsSource, observed, now)
+	if err != nil {
+		logger.JobWarn("getThreadPoolRejections", "Failed to persist events: %v", err)
+	}
+
+	firedByCluster := make(map[string][]events.Event)
+	for _, event := range fired {
+		logger.JobWarn("getThreadPoolRejections", "Sustained rejections: cluster=%s host=%s pool=%s rejected=%s (event %s)",
+			event.Cluster(), event.Labels["host"], event.Labels["pool"], event.Annotations["rejected"], event.ID)
+		firedByCluster[event.Cluster()] = append(firedByCluster[event.Cluster()], event)
+	}
+	for _, event := range resolved {
+		logger.JobInfo("getThreadPoolRejections", "Rejections stopped: cluster=%s host=%s pool=%s (event %s)",
+			event.Cluster(), event.Labels["host"], event.Labels["pool"], event.ID)
+	}
+
+	if notifyOwners {
+		clusterNames := make([]string, 0, len(firedByCluster))
+		for clusterName := range firedByCluster {
+			clusterNames = append(clusterNames, clusterName)
+		}
+		sort.Strings(clusterNames)
+		for _, clusterName := range clusterNames {
+			notifyThreadPoolRejections(ctx, clusterName, firedByCluster[clusterName], severity)
+		}
+	}
+
+	logger.JobInfo("getThreadPoolRejections", "Completed: %d clusters sampled, %d pools with sustained rejections (%d new, %d resolved)",
+		len(collected), sustainedCount, len(fired), len(resolved))
+	return nil
+}
+
+// sustainedRejections reports whether the latest consecutiveSamples samples of a pool each
+// rejected at least minRejections executions. It returns the time of the first sample of the
+// growth run (which may be longer) and the rejections over the run.
+func sustainedRejections(ring *types.Ring[types.RejectionPoint], minRejections uint64, consecutiveSamples int) (bool, int64, uint64) {
+	var startTime int64
+	var rejected uint64
+	run := 0
+	for _, point := range ring.NewestFirst() {
+		if point.TimeStamp == 0 || point.Delta < minRejections {
+			break
+		}
+		run++
+		startTime = point.TimeStamp
+		rejected += point.Delta
+	}
+	if run < consecutiveSamples {
+		return false, 0, 0
+	}
+	return true, startTime, rejected
+}
+
+// recordRejectionMetrics exports the latest sample of the thread pools of a cluster
+func recordRejectionMetrics(clusterName string, rejections *types.ClusterThreadPoolRejections) {
+	clusterLabel := prometheus.Labels{"cluster": clusterName}
+	metrics.ThreadPoolRejected.DeletePartialMatch(clusterLabel)
+	metrics.ThreadPoolRejectedDelta.DeletePartialMatch(clusterLabel)
+	if rejections == nil {
+		return
+	}
+
+	for hostName, history := range rejections.Nodes {
+		for pool, ring := range history.Pools {
+			point := ring.At(0)
+			metrics.ThreadPoolRejected.WithLabelValues(clusterName, hostName, pool).Set(float64(point.Rejected))
+			metrics.ThreadPoolRejectedDelta.WithLabelValues(clusterName, hostName, pool).Set(float64(point.Delta))
+		}
+	}
+}
+
+// notifyThreadPoolRejections sends the owner of a cluster one alert for its new events
+func notifyThreadPoolRejections(ctx context.Context, clusterName string, clusterEvents []events.Event, severity string) {
+	// Events detected during maintenance are recorded but not alerted on
+	alerting := clusterEvents[:0:0]
+	for _, event := range clusterEvents {
+		if !event.Suppressed {
+			alerting = append(alerting, event)
+		}
+	}
+	if len(alerting) == 0 {
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Sustained thread pool rejections on cluster %s:\n", clusterName)
+	for _, event := range alerting {
+		fmt.Fprintf(&text, "  %s %s: %s rejected since %s, queue %s (event %s)\n",
+			event.Labels["host"], event.Labels["pool"], event.Annotations["rejected"],
+			time.UnixMilli(event.StartsAt).UTC().Format(time.RFC3339), event.Annotations["queue"], event.ID)
+		writeEventLinks(&text, event.Annotations)
+	}
+
+	err := notify.NotifyCluster(ctx, clusterName, notify.Message{
+		Subject:  fmt.Sprintf("Thread pool rejections on cluster %s", clusterName),
+		Text:     text.String(),
+		Severity: severity,
+	})
+	if err != nil {
+		logger.JobWarn("getThreadPoolRejections", "Failed to notify owner of cluster %s: %v", clusterName, err)
+	}
+}
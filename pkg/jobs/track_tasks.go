@@ -0,0 +1,207 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/logger"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// taskProgress is the document count of a by-query or reindex task: its status while it runs,
+// its response once it completed
+type taskProgress struct {
+	Total            int64             `json:"total"`
+	Created          int64             `json:"created"`
+	Updated          int64             `json:"updated"`
+	Deleted          int64             `json:"deleted"`
+	Noops            int64             `json:"noops"`
+	VersionConflicts int64             `json:"version_conflicts"`
+	Failures         []json.RawMessage `json:"failures"`
+}
+
+// done returns the documents processed so far
+func (p *taskProgress) done() int64 {
+	return p.Created + p.Updated + p.Deleted + p.Noops + p.VersionConflicts
+}
+
+// taskResult is the response of GET _tasks/<id>
+type taskResult struct {
+	Completed bool `json:"completed"`
+	Task      struct {
+		taskInfo
+		Status *taskProgress `json:"status"` // nil for tasks without progress, e.g. force merge
+	} `json:"task"`
+	Response *taskProgress          `json:"response"`
+	Error    map[string]interface{} `json:"error"`
+}
+
+// TrackTasks polls the tasks tracked through POST /api/v1/tasks/tracked (reindexes, update
+// and delete by queries, force merges, ...) with GET _tasks/<id>, and computes their progress,
+// document rate and estimated completion. Tasks ended for longer than keepFinished are no
+// longer tracked.
+func TrackTasks(ctx context.Context, params map[string]interface{}) error {
+	logger.JobInfo("trackTasks", "Starting tracked task poll")
+
+	p := jobparams.New(params)
+	maxConcurrent := p.IntInRange("maxConcurrent", 5, 1, 20)
+	keepFinished := p.Duration("keepFinished", 24*time.Hour)
+	insecureTLS := p.Bool("insecureTLS", false)
+	if err := checkParams("trackTasks", p); err != nil {
+		return err
+	}
+
+	if pruned := types.PruneTrackedTasks(utils.TimeNowMillis() - keepFinished.Milliseconds()); pruned > 0 {
+		logger.JobInfo("trackTasks", "Stopped tracking %d tasks that ended more than %s ago", pruned, keepFinished)
+	}
+
+	byCluster := make(map[string][]types.TrackedTask)
+	for _, task := range types.TrackedTasks("") {
+		if task.State == types.TaskRunning {
+			byCluster[task.ClusterName] = append(byCluster[task.ClusterName], task)
+		}
+	}
+	if len(byCluster) == 0 {
+		logger.JobDebug("trackTasks", "No running tracked tasks")
+		return nil
+	}
+	clusterNames := make([]string, 0, len(byCluster))
+	for clusterName := range byCluster {
+		clusterNames = append(clusterNames, clusterName)
+	}
+	sort.Strings(clusterNames)
+
+	opts := ClusterRunOptions{JobName: "trackTasks", IncludeClusters: clusterNames, MaxConcurrent: maxConcurrent}
+	_, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		failed := make([]error, 0)
+		for _, task := range byCluster[clusterName] {
+			result, err := fetchTaskResult(ctx, cluster, task.TaskID, insecureTLS)
+			polled := updateTrackedTask(task, result, err, utils.TimeNowMillis())
+			types.UpdateTrackedTask(polled)
+
+			if err != nil && polled.State == types.TaskRunning {
+				failed = append(failed, fmt.Errorf("task %s: %w", task.TaskID, err))
+				continue
+			}
+			if polled.State != types.TaskRunning {
+				logger.JobInfo("trackTasks", "Tracked task %s of cluster %s %s after %s",
+					task.TaskID, clusterName, polled.State, time.Duration(polled.RunningTimeMs)*time.Millisecond)
+			}
+		}
+		return errors.Join(failed...)
+	})
+	return err
+}
+
+// fetchTaskResult gets a task by ID; a task unknown to the cluster is nil without error
+func fetchTaskResult(ctx context.Context, cluster *types.ClusterData, taskID string, insecureTLS bool) (*taskResult, error) {
+	client := esHTTPClient(insecureTLS || cluster.InsecureTLS, 30*time.Second)
+	endpoint := strings.TrimSuffix(cluster.ActiveEndpoint, "/") + "/_tasks/" + url.PathEscape(taskID)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	utils.AddAuthentication(req, &cluster.AccessCred)
+
+	body, err := doQuery(client, req, nil, queryOptions{JobName: "trackTasks", Cluster: cluster.ClusterName})
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var result taskResult
+	if err := json.NewDecoder(body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// updateTrackedTask returns a tracked task updated with the result of a poll at nowMs. A
+// failed poll only records the error; a task no longer found has ended, or was never there.
+func updateTrackedTask(task types.TrackedTask, result *taskResult, pollErr error, nowMs int64) types.TrackedTask {
+	previousDone, previousPolled := task.Done, task.LastPolled
+	task.LastPolled = nowMs
+	task.LastError = ""
+	if pollErr != nil {
+		task.LastError = pollErr.Error()
+		return task
+	}
+
+	if result == nil {
+		task.State = types.TaskGone
+		if task.StartTime == 0 {
+			task.State = types.TaskNotFound
+		}
+		task.EndTime = nowMs
+		task.EtaMs = 0
+		return task
+	}
+
+	info := result.Task
+	task.Action = info.Action
+	task.Description = info.Description
+	task.StartTime = info.StartTimeInMillis
+	if info.RunningTimeInNanos != nil {
+		task.RunningTimeMs = int64(*info.RunningTimeInNanos / 1e6)
+	}
+
+	progress := info.Status
+	if result.Completed && result.Response != nil {
+		progress = result.Response
+	}
+	task.Progress = -1
+	if progress != nil {
+		task.Total, task.Done = progress.Total, progress.done()
+		if task.Total > 0 {
+			task.Progress = min(float64(task.Done)*100/float64(task.Total), 100)
+		}
+		if task.RunningTimeMs > 0 {
+			task.AvgRatePerSec = float64(task.Done) * 1000 / float64(task.RunningTimeMs)
+		}
+		task.RatePerSec = task.AvgRatePerSec
+		if previousPolled > 0 && nowMs > previousPolled && task.Done >= previousDone {
+			task.RatePerSec = float64(task.Done-previousDone) * 1000 / float64(nowMs-previousPolled)
+		}
+	}
+
+	task.EtaMs = 0
+	if !result.Completed {
+		task.State = types.TaskRunning
+		if task.AvgRatePerSec > 0 && task.Total > task.Done {
+			task.EtaMs = int64(float64(task.Total-task.Done) * 1000 / task.AvgRatePerSec)
+		}
+		return task
+	}
+
+	task.EndTime = nowMs
+	task.State = types.TaskCompleted
+	if progress != nil {
+		task.Progress = 100
+		task.Failures = len(progress.Failures)
+	}
+	if result.Error != nil || task.Failures > 0 {
+		task.State = types.TaskFailed
+	}
+	if reason, ok := result.Error["reason"].(string); ok {
+		task.Error = reason
+	}
+	return task
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"ElasticObservability/pkg/logger"
+	jobparams "ElasticObservability/pkg/params"
 	"ElasticObservability/pkg/types"
 	"ElasticObservability/pkg/utils"
 )
@@ -16,9 +17,10 @@ func UpdateAccessCredentials(ctx context.Context, params map[string]interface{})
 	logger.JobInfo("updateAccessCredentials", "Starting credentials update job")
 
 	// Get CSV file name from parameters
-	csvFileName, ok := params["csv_fileName"].(string)
-	if !ok || csvFileName == "" {
-		return fmt.Errorf("csv_fileName parameter is required")
+	p := jobparams.New(params)
+	csvFileName := p.RequiredString("csv_fileName")
+	if err := p.Err(); err != nil {
+		return err
 	}
 
 	// Parse CSV file
@@ -43,19 +45,45 @@ func UpdateAccessCredentials(ctx context.Context, params map[string]interface{})
 			continue
 		}
 
-		// Check if cluster exists
-		types.ClustersMu.Lock()
-		cluster, exists := types.AllClusters[clusterName]
+		// Rows with a NodeName carry credentials for a dedicated node-level user
+		nodeName := strings.TrimSpace(utils.GetValue(row, "NodeName"))
+		nodeFound := true
+		exists := types.UpdateCluster(clusterName, func(cluster *types.ClusterData) {
+			if nodeName == "" {
+				updateClusterCredentials(cluster, row)
+				return
+			}
+
+			node := cluster.GetNode(nodeName)
+			if node == nil {
+				nodeFound = false
+				return
+			}
+			if node.AccessCred == nil {
+				node.AccessCred = &types.AccessCred{}
+			}
+			updateAccessCred(node.AccessCred, row)
+			if nodePort := strings.TrimSpace(utils.GetValue(row, "ClusterPort")); nodePort != "" {
+				node.Port = nodePort
+				node.PortFromCredentials = true
+			}
+		})
 		if !exists {
-			types.ClustersMu.Unlock()
 			logger.JobWarn("updateAccessCredentials", "Row %d: Cluster %s not found, skipping", rowIdx+1, clusterName)
 			notFoundCount++
 			continue
 		}
 
-		// Parse and update AccessCred
-		updateClusterCredentials(cluster, row)
-		types.ClustersMu.Unlock()
+		if nodeName != "" {
+			if !nodeFound {
+				logger.JobWarn("updateAccessCredentials", "Row %d: Node %s not found in cluster %s, skipping", rowIdx+1, nodeName, clusterName)
+				notFoundCount++
+				continue
+			}
+			updatedCount++
+			logger.JobInfo("updateAccessCredentials", "Row %d: Updated credentials for node %s in cluster: %s", rowIdx+1, nodeName, clusterName)
+			continue
+		}
 
 		updatedCount++
 		logger.JobInfo("updateAccessCredentials", "Row %d: Updated credentials for cluster: %s", rowIdx+1, clusterName)
@@ -74,70 +102,75 @@ func updateClusterCredentials(cluster *types.ClusterData, row map[string]string)
 		cluster.ClusterUUID = clusterUUID
 	}
 
+	updateAccessCred(&cluster.AccessCred, row)
+
+	// Update ClusterPort
+	clusterPort := strings.TrimSpace(utils.GetValue(row, "ClusterPort"))
+	if clusterPort != "" {
+		cluster.ClusterPort = clusterPort
+	}
+
+	// Update ApplicationLBs (ClusterSAN)
+	applicationLBs := strings.TrimSpace(utils.GetValue(row, "ApplicationLBs"))
+	if applicationLBs != "" {
+		// Split by pipe delimiter and trim whitespace
+		lbs := strings.Split(applicationLBs, "|")
+		clusterSAN := make([]string, 0, len(lbs))
+		for _, lb := range lbs {
+			trimmed := strings.TrimSpace(lb)
+			if trimmed != "" {
+				clusterSAN = append(clusterSAN, trimmed)
+			}
+		}
+		if len(clusterSAN) > 0 {
+			cluster.ClusterSAN = clusterSAN
+		}
+	}
+}
+
+// updateAccessCred applies the credential columns of a row to an AccessCred
+func updateAccessCred(cred *types.AccessCred, row map[string]string) {
 	// Parse PrefferedAccess (intentionally matching the typo from requirements)
 	preferredAccessStr := strings.TrimSpace(utils.GetValue(row, "PrefferedAccess"))
 	if preferredAccessStr != "" {
 		if preferredAccess, err := strconv.ParseUint(preferredAccessStr, 10, 8); err == nil {
-			cluster.AccessCred.Preferred = uint8(preferredAccess)
+			cred.Preferred = uint8(preferredAccess)
 		}
 	}
 
 	// Update APIKey
 	apiKey := strings.TrimSpace(utils.GetValue(row, "APIKey"))
 	if apiKey != "" {
-		cluster.AccessCred.APIKey = apiKey
+		cred.APIKey = apiKey
 	}
 
 	// Update UserID
 	userID := strings.TrimSpace(utils.GetValue(row, "UserID"))
 	if userID != "" {
-		cluster.AccessCred.UserID = userID
+		cred.UserID = userID
 	}
 
 	// Update Password
 	password := strings.TrimSpace(utils.GetValue(row, "Password"))
 	if password != "" {
-		cluster.AccessCred.Password = password
+		cred.Password = password
 	}
 
 	// Update ClientCert
 	clientCert := strings.TrimSpace(utils.GetValue(row, "ClientCert"))
 	if clientCert != "" {
-		cluster.AccessCred.ClientCert = clientCert
+		cred.ClientCert = clientCert
 	}
 
 	// Update ClientKey
 	clientKey := strings.TrimSpace(utils.GetValue(row, "ClientKey"))
 	if clientKey != "" {
-		cluster.AccessCred.ClientKey = clientKey
+		cred.ClientKey = clientKey
 	}
 
 	// Update CaCert
 	caCert := strings.TrimSpace(utils.GetValue(row, "Cacert"))
 	if caCert != "" {
-		cluster.AccessCred.CaCert = caCert
-	}
-
-	// Update ClusterPort
-	clusterPort := strings.TrimSpace(utils.GetValue(row, "ClusterPort"))
-	if clusterPort != "" {
-		cluster.ClusterPort = clusterPort
-	}
-
-	// Update ApplicationLBs (ClusterSAN)
-	applicationLBs := strings.TrimSpace(utils.GetValue(row, "ApplicationLBs"))
-	if applicationLBs != "" {
-		// Split by pipe delimiter and trim whitespace
-		lbs := strings.Split(applicationLBs, "|")
-		clusterSAN := make([]string, 0, len(lbs))
-		for _, lb := range lbs {
-			trimmed := strings.TrimSpace(lb)
-			if trimmed != "" {
-				clusterSAN = append(clusterSAN, trimmed)
-			}
-		}
-		if len(clusterSAN) > 0 {
-			cluster.ClusterSAN = clusterSAN
-		}
+		cred.CaCert = caCert
 	}
 }
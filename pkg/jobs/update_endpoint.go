@@ -2,13 +2,15 @@ package jobs
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
 	"ElasticObservability/pkg/logger"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/scheduler"
 	"ElasticObservability/pkg/types"
 	"ElasticObservability/pkg/utils"
 )
@@ -17,29 +19,25 @@ import (
 func UpdateActiveEndpoint(ctx context.Context, params map[string]interface{}) error {
 	logger.JobInfo("updateActiveEndpoint", "Starting endpoint validation job")
 
-	// Get exclude list
-	excludeClusters := make([]string, 0)
-	if exclude, ok := params["excludeClusters"].([]interface{}); ok {
-		for _, item := range exclude {
-			if str, ok := item.(string); ok {
-				excludeClusters = append(excludeClusters, str)
-			}
-		}
+	p := jobparams.New(params)
+	selection := clusterSelectionFromParams(p)
+	if err := p.Err(); err != nil {
+		return err
 	}
 
-	types.ClustersMu.RLock()
-	clustersCopy := make(map[string]*types.ClusterData)
-	for name, cluster := range types.AllClusters {
-		clustersCopy[name] = cluster
-	}
-	types.ClustersMu.RUnlock()
+	clustersCopy := types.SnapshotClusters()
 
-	updatedCount := 0
-	failedCount := 0
+	activeClusters := make([]string, 0, len(clustersCopy))
+	failedClusters := make([]string, 0)
+
+	for _, clusterName := range types.ClusterNames() {
+		cluster, ok := clustersCopy[clusterName]
+		if !ok {
+			continue
+		}
 
-	for clusterName, cluster := range clustersCopy {
-		// Skip excluded clusters
-		if utils.Contains(excludeClusters, clusterName) {
+		// Skip the clusters the job does not select
+		if !selection.selects(clusterName) {
 			logger.JobInfo("updateActiveEndpoint", "Skipping excluded cluster: %s", clusterName)
 			continue
 		}
@@ -47,26 +45,38 @@ func UpdateActiveEndpoint(ctx context.Context, params map[string]interface{}) er
 		// Skip clusters without credentials
 		if cluster.AccessCred.Preferred == 0 {
 			logger.JobInfo("updateActiveEndpoint", "Skipping cluster %s: No credentials available (Preferred=0)", clusterName)
-			cluster.ActiveEndpoint = ""
+			setActiveEndpoint(clusterName, "")
 			continue
 		}
 
 		endpoint := findActiveEndpoint(cluster)
+		setActiveEndpoint(clusterName, endpoint)
 		if endpoint != "" {
-			cluster.ActiveEndpoint = endpoint
-			updatedCount++
+			activeClusters = append(activeClusters, clusterName)
 			logger.JobInfo("updateActiveEndpoint", "Cluster %s: Active endpoint set to %s", clusterName, endpoint)
 		} else {
-			cluster.ActiveEndpoint = ""
-			failedCount++
+			failedClusters = append(failedClusters, clusterName)
 			logger.JobWarn("updateActiveEndpoint", "Cluster %s: Failed to find active endpoint", clusterName)
 		}
 	}
 
-	logger.JobInfo("updateActiveEndpoint", "Completed: %d endpoints updated, %d failed", updatedCount, failedCount)
+	// The clusters with an active endpoint are those the collection jobs can reach
+	sort.Strings(activeClusters)
+	sort.Strings(failedClusters)
+	scheduler.PublishResult(ctx, "activeClusters", activeClusters)
+	scheduler.PublishResult(ctx, "failedClusters", failedClusters)
+
+	logger.JobInfo("updateActiveEndpoint", "Completed: %d endpoints updated, %d failed", len(activeClusters), len(failedClusters))
 	return nil
 }
 
+// setActiveEndpoint stores the endpoint on the live cluster (the job works on snapshots)
+func setActiveEndpoint(clusterName, endpoint string) {
+	types.UpdateCluster(clusterName, func(cluster *types.ClusterData) {
+		cluster.ActiveEndpoint = endpoint
+	})
+}
+
 func findActiveEndpoint(cluster *types.ClusterData) string {
 	// Try ClusterSAN endpoints first
 	for _, endpoint := range cluster.ClusterSAN {
@@ -75,7 +85,7 @@ func findActiveEndpoint(cluster *types.ClusterData) string {
 		}
 		// Normalize endpoint: add https:// if no protocol, add ClusterPort if no port
 		normalizedEndpoint := normalizeEndpoint(endpoint, cluster.ClusterPort)
-		if testConnection(normalizedEndpoint, cluster) {
+		if testConnection(normalizedEndpoint, cluster, &cluster.AccessCred) {
 			return normalizedEndpoint
 		}
 	}
@@ -91,7 +101,7 @@ func findActiveEndpoint(cluster *types.ClusterData) string {
 				port = "9200"
 			}
 			endpoint := fmt.Sprintf("https://%s:%s", node.HostName, port)
-			if testConnection(endpoint, cluster) {
+			if testConnection(endpoint, cluster, cluster.CredentialsForNode(node.HostName)) {
 				return endpoint
 			}
 		}
@@ -108,7 +118,7 @@ func findActiveEndpoint(cluster *types.ClusterData) string {
 				port = "5601"
 			}
 			endpoint := fmt.Sprintf("https://%s:%s", node.HostName, port)
-			if testConnection(endpoint, cluster) {
+			if testConnection(endpoint, cluster, cluster.CredentialsForNode(node.HostName)) {
 				return endpoint
 			}
 		}
@@ -124,7 +134,7 @@ func findActiveEndpoint(cluster *types.ClusterData) string {
 			port = "9200"
 		}
 		endpoint := fmt.Sprintf("https://%s:%s", node.HostName, port)
-		if testConnection(endpoint, cluster) {
+		if testConnection(endpoint, cluster, cluster.CredentialsForNode(node.HostName)) {
 			return endpoint
 		}
 	}
@@ -156,15 +166,10 @@ func normalizeEndpoint(endpoint string, defaultPort string) string {
 	return endpoint
 }
 
-func testConnection(endpoint string, cluster *types.ClusterData) bool {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: cluster.InsecureTLS,
-			},
-		},
-	}
+// testConnection checks that an endpoint is reachable using the given credentials
+// (the cluster credentials, or a node-level override when a node is contacted directly)
+func testConnection(endpoint string, cluster *types.ClusterData, cred *types.AccessCred) bool {
+	client := esHTTPClient(cluster.InsecureTLS, 5*time.Second)
 
 	req, err := http.NewRequest("GET", endpoint, nil)
 	if err != nil {
@@ -172,7 +177,6 @@ func testConnection(endpoint string, cluster *types.ClusterData) bool {
 	}
 
 	// Try authentication methods based on preference
-	cred := &cluster.AccessCred
 	authenticated := false
 
 	// Try preferred method first
@@ -2,51 +2,268 @@ package jobs
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
 
+	"ElasticObservability/pkg/events"
 	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	jobparams "ElasticObservability/pkg/params"
 	"ElasticObservability/pkg/types"
 	"ElasticObservability/pkg/utils"
 )
 
-// UpdateCurrentMasterEndPoints updates the global map of current master node endpoints for all clusters
+// masterChangeSource is the event store source of elected master changes
+const masterChangeSource = "masterChange"
+
+// masterLookup is the latest master lookup of a cluster
+type masterLookup struct {
+	nodeID string // master_node of _cluster/state, "" if it could not be read
+	at     time.Time
+}
+
+var (
+	masterLookupsMu sync.Mutex
+	masterLookups   = make(map[string]*masterLookup) // key: cluster name
+)
+
+// UpdateCurrentMasterEndPoints updates the global map of current master node endpoints for all clusters.
+// With monitorZone or monitorDataCenter set (where this monitor runs), a master elsewhere is
+// reached through a reachable node of the same zone, then of the same data center, since every
+// node forwards master-level requests; the master itself is the fallback.
+//
+// Each run reads the elected master's node ID from _cluster/state/master_node; while it is
+// unchanged, the endpoint of the previous lookup is kept for up to cacheTTL. A change of the
+// elected master is recorded in the cluster's master history (the latest historySize
+// transitions, served by /api/v1/masterHistory/{clusterName}) and fires a MasterChanged event
+// for the cluster, critical once the master changed flapThreshold times within flapWindow; the
+// event resolves when no change happened within flapWindow.
 func UpdateCurrentMasterEndPoints(ctx context.Context, params map[string]interface{}) error {
 	logger.JobInfo("updateCurrentMasterEndPoints", "Starting master endpoints update job")
 
-	// Get list of clusters
-	types.ClustersMu.RLock()
-	clusterList := make([]string, 0, len(types.AllClustersList))
-	for _, clusterName := range types.AllClustersList {
-		if cluster, exists := types.AllClusters[clusterName]; exists && cluster.ActiveEndpoint != "" {
-			clusterList = append(clusterList, clusterName)
+	p := jobparams.New(params)
+	monitorZone := p.String("monitorZone", "")
+	monitorDataCenter := p.String("monitorDataCenter", "")
+	cacheTTL := p.Duration("cacheTTL", time.Hour)
+	flapWindow := p.Duration("flapWindow", time.Hour)
+	flapThreshold := p.IntInRange("flapThreshold", 3, 2, 100)
+	historySize := p.IntInRange("historySize", 100, 10, 1000)
+	opts := clusterRunOptionsFromParams("updateCurrentMasterEndPoints", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 10, 1, 50)
+	opts.Skip = func(cluster *types.ClusterData) string {
+		if cluster.ActiveEndpoint == "" {
+			return "has no active endpoint"
 		}
+		return ""
+	}
+	if err := checkParams("updateCurrentMasterEndPoints", p); err != nil {
+		return err
 	}
-	types.ClustersMu.RUnlock()
 
-	logger.JobInfo("updateCurrentMasterEndPoints", "Processing %d clusters with active endpoints", len(clusterList))
+	var mu sync.Mutex
+	unchanged := 0
+	processed := make(map[string]bool)
 
-	successCount := 0
-	failCount := 0
+	summary, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		now := time.Now()
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
 
-	// Process each cluster
-	for _, clusterName := range clusterList {
-		// Get master endpoint for this cluster
-		masterEndpoint := utils.GetCurrentMasterEndpointForCluster(clusterName)
+		var state struct {
+			MasterNode string `json:"master_node"`
+		}
+		if err := getClusterJSON(ctx, cluster, "/_cluster/state/master_node", queryOptions{JobName: "updateCurrentMasterEndPoints"}, &state); err != nil {
+			logger.JobWarn("updateCurrentMasterEndPoints", "Cluster %s: failed to read master_node, looking up the master: %v", clusterName, err)
+		}
+		nodeID := state.MasterNode
+
+		masterLookupsMu.Lock()
+		lookup, known := masterLookups[clusterName]
+		if !known {
+			lookup = &masterLookup{}
+			masterLookups[clusterName] = lookup
+		}
+		cached := known && nodeID != "" && nodeID == lookup.nodeID && now.Sub(lookup.at) < cacheTTL
+		masterLookupsMu.Unlock()
 
+		if _, stored := types.GetCurrentMasterEndpoint(clusterName); cached && stored {
+			if history, ok := types.GetMasterHistory(clusterName); ok {
+				types.RecordMaster(clusterName, history.Master, now.UnixMilli(), historySize)
+			}
+			mu.Lock()
+			processed[clusterName] = true
+			unchanged++
+			mu.Unlock()
+			return nil
+		}
+
+		// Get master endpoint for this cluster
+		masterEndpoint := utils.GetCurrentMasterEndpointForCluster(ctx, clusterName)
 		if masterEndpoint == "" {
-			logger.JobWarn("updateCurrentMasterEndPoints", "Could not determine master endpoint for cluster: %s", clusterName)
-			failCount++
-			continue
+			return fmt.Errorf("could not determine the master endpoint")
 		}
+		mu.Lock()
+		processed[clusterName] = true
+		mu.Unlock()
 
-		// Update global map (thread-safe)
-		types.CurrentMasterEndPtsMu.Lock()
-		types.AllCurrentMasterEndPoints[clusterName] = masterEndpoint
-		types.CurrentMasterEndPtsMu.Unlock()
+		var master string
+		if masterURL, err := url.Parse(masterEndpoint); err == nil {
+			master = masterURL.Hostname()
+		}
+
+		if monitorZone != "" || monitorDataCenter != "" {
+			masterEndpoint = preferredMasterEndpoint(clusterName, masterEndpoint, monitorZone, monitorDataCenter)
+		}
+
+		if transition, changed := types.RecordMaster(clusterName, master, now.UnixMilli(), historySize); changed {
+			metrics.MasterChangesTotal.WithLabelValues(clusterName).Inc()
+			logger.JobWarn("updateCurrentMasterEndPoints", "Cluster %s: elected master changed from %s to %s", clusterName, transition.From, transition.To)
+		}
+
+		masterLookupsMu.Lock()
+		lookup.nodeID, lookup.at = nodeID, now
+		masterLookupsMu.Unlock()
+
+		types.SetCurrentMasterEndpoint(clusterName, masterEndpoint)
 
 		logger.JobInfo("updateCurrentMasterEndPoints", "Updated master endpoint for cluster %s: %s", clusterName, masterEndpoint)
-		successCount++
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	logger.JobInfo("updateCurrentMasterEndPoints", "Completed: %d succeeded, %d failed", successCount, failCount)
+	syncMasterChangeEvents(processed, flapWindow, flapThreshold, time.Now())
+
+	logger.JobInfo("updateCurrentMasterEndPoints", "%d of %d succeeded clusters kept their master endpoint (master unchanged)", unchanged, summary.Succeeded)
 	return nil
 }
+
+// syncMasterChangeEvents fires a MasterChanged event for each processed cluster whose master
+// changed within flapWindow; the events of clusters not processed this run are kept
+func syncMasterChangeEvents(processed map[string]bool, flapWindow time.Duration, flapThreshold int, now time.Time) {
+	observed := make([]events.Observation, 0)
+
+	masterLookupsMu.Lock()
+	for clusterName := range masterLookups {
+		if !types.ClusterExists(clusterName) {
+			delete(masterLookups, clusterName)
+		}
+	}
+	masterLookupsMu.Unlock()
+
+	for clusterName := range processed {
+		history, ok := types.GetMasterHistory(clusterName)
+		if !ok {
+			continue
+		}
+		recent := make([]types.MasterTransition, 0)
+		for _, transition := range history.Transitions.NewestFirst() {
+			if transition.TimeStamp == 0 || now.UnixMilli()-transition.TimeStamp >= flapWindow.Milliseconds() {
+				break
+			}
+			recent = append(recent, transition)
+		}
+		if len(recent) == 0 {
+			continue
+		}
+
+		severity := "warning"
+		if len(recent) >= flapThreshold {
+			severity = "critical"
+		}
+		observed = append(observed, events.Observation{
+			Name:     "MasterChanged",
+			Severity: severity,
+			Labels:   map[string]string{"cluster": clusterName},
+			Annotations: map[string]string{
+				"previousMaster": recent[0].From,
+				"currentMaster":  recent[0].To,
+				"changes":        strconv.Itoa(len(recent)),
+				"window":         flapWindow.String(),
+			},
+			StartsAt: recent[len(recent)-1].TimeStamp,
+		})
+	}
+
+	observed = append(observed, carriedOverEvents(masterChangeSource, processed)...)
+	fired, resolved, err := events.Sync(masterChangeSource, observed, now)
+	if err != nil {
+		logger.JobWarn("updateCurrentMasterEndPoints", "Failed to persist events: %v", err)
+	}
+	for _, event := range fired {
+		logger.JobWarn("updateCurrentMasterEndPoints", "Master change: cluster=%s master=%s (event %s)", event.Cluster(), event.Annotations["currentMaster"], event.ID)
+	}
+	for _, event := range resolved {
+		logger.JobInfo("updateCurrentMasterEndPoints", "Master stable again: cluster=%s (event %s)", event.Cluster(), event.ID)
+	}
+}
+
+// preferredMasterEndpoint returns the endpoint to reach the elected master of a cluster
+// through: the master when it is in the monitor's zone (or data center, without a zone), else
+// the first reachable Elasticsearch node of the monitor's zone, then of its data center, else
+// the master
+func preferredMasterEndpoint(clusterName, masterEndpoint, monitorZone, monitorDataCenter string) string {
+	cluster, exists := types.GetCluster(clusterName)
+	if !exists {
+		return masterEndpoint
+	}
+
+	local := func(node *types.Node, sameZone bool) bool {
+		if sameZone {
+			return monitorZone != "" && node.Zone == monitorZone
+		}
+		return monitorDataCenter != "" && node.DataCenter == monitorDataCenter
+	}
+
+	var master *types.Node
+	if masterURL, err := url.Parse(masterEndpoint); err == nil {
+		master = cluster.GetNode(masterURL.Hostname())
+	}
+	if master != nil && (local(master, true) || (monitorZone == "" && local(master, false))) {
+		return masterEndpoint
+	}
+
+	for _, sameZone := range []bool{true, false} {
+		for _, node := range cluster.Nodes {
+			// Nodes of the monitor's zone were tried before its data center
+			if node == master || !local(node, sameZone) || (!sameZone && local(node, true)) || !isElasticsearchNode(node) {
+				continue
+			}
+			endpoint := nodeEndpoint(cluster, node)
+			if testConnection(endpoint, cluster, cluster.CredentialsForNode(node.HostName)) {
+				logger.JobInfo("updateCurrentMasterEndPoints", "Cluster %s: master is remote, using local node %s", clusterName, node.HostName)
+				return endpoint + "/"
+			}
+			logger.JobWarn("updateCurrentMasterEndPoints", "Cluster %s: local node %s is unreachable", clusterName, node.HostName)
+		}
+	}
+	return masterEndpoint
+}
+
+// isElasticsearchNode reports whether a node runs Elasticsearch (not only Kibana or Logstash)
+func isElasticsearchNode(node *types.Node) bool {
+	for _, nodeType := range node.Type {
+		if nodeType != "kibana" && nodeType != "logstash" {
+			return true
+		}
+	}
+	return len(node.Type) == 0
+}
+
+// nodeEndpoint returns the Elasticsearch endpoint of a node, without a trailing slash
+func nodeEndpoint(cluster *types.ClusterData, node *types.Node) string {
+	port := node.Port
+	if port == "" {
+		port = cluster.ClusterPort
+	}
+	if port == "" {
+		port = "9200"
+	}
+	return fmt.Sprintf("https://%s:%s", node.HostName, port)
+}
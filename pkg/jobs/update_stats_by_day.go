@@ -3,6 +3,7 @@ package jobs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -10,6 +11,8 @@ import (
 
 	"ElasticObservability/pkg/config"
 	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/output"
+	jobparams "ElasticObservability/pkg/params"
 	"ElasticObservability/pkg/types"
 	"ElasticObservability/pkg/utils"
 )
@@ -18,14 +21,10 @@ import (
 func UpdateStatsByDay(ctx context.Context, params map[string]interface{}) error {
 	logger.JobInfo("updateStatsByDay", "Starting daily statistics update job")
 
-	// Get exclude list
-	excludeClusters := make([]string, 0)
-	if exclude, ok := params["excludeClusters"].([]interface{}); ok {
-		for _, item := range exclude {
-			if str, ok := item.(string); ok && str != "" {
-				excludeClusters = append(excludeClusters, str)
-			}
-		}
+	p := jobparams.New(params)
+	selection := clusterSelectionFromParams(p)
+	if err := p.Err(); err != nil {
+		return err
 	}
 
 	// Get backup file location
@@ -39,20 +38,42 @@ func UpdateStatsByDay(ctx context.Context, params map[string]interface{}) error
 		historyDays = 30
 	}
 
-	// Check if backup exists
+	// Check if backup exists, locally or (after the loss of the instance) at the backup destination
 	backupExists := fileExists(backupFile)
-
+	var backupData []byte
 	if backupExists {
 		logger.JobInfo("updateStatsByDay", "Backup file found at %s, restoring...", backupFile)
-		if err := restoreFromBackup(backupFile); err != nil {
+		data, err := os.ReadFile(backupFile)
+		if err != nil {
+			logger.JobError("updateStatsByDay", "Failed to restore from backup: %v", err)
+			return fmt.Errorf("failed to read backup file: %w", err)
+		}
+		backupData = data
+	} else if destination := config.Global.BackupDestination; destination != "" {
+		data, err := output.Read(ctx, destination, remoteBackupName(backupFile))
+		switch {
+		case err == nil:
+			logger.JobInfo("updateStatsByDay", "Backup file not found locally, restoring from %s",
+				output.Location(destination, remoteBackupName(backupFile)))
+			backupExists = true
+			backupData = data
+		case !errors.Is(err, output.ErrNotFound):
+			// Starting afresh would overwrite the remote backup with empty statistics
+			logger.JobError("updateStatsByDay", "Failed to restore from backup destination %s: %v", destination, err)
+			return err
+		}
+	}
+
+	if backupExists {
+		if err := restoreFromBackup(backupData); err != nil {
 			logger.JobError("updateStatsByDay", "Failed to restore from backup: %v", err)
 			return err
 		}
 
-		// Remove excluded clusters from restored data
+		// Remove the clusters the job does not select from restored data
 		types.StatsByDayMu.Lock()
-		for _, clusterName := range excludeClusters {
-			if _, exists := types.AllStatsByDay[clusterName]; exists {
+		for clusterName := range types.AllStatsByDay {
+			if !selection.selects(clusterName) {
 				delete(types.AllStatsByDay, clusterName)
 				logger.JobInfo("updateStatsByDay", "Removed excluded cluster from stats: %s", clusterName)
 			}
@@ -66,14 +87,17 @@ func UpdateStatsByDay(ctx context.Context, params map[string]interface{}) error
 		}
 	} else {
 		logger.JobInfo("updateStatsByDay", "No backup file found, initializing new statistics")
-		if err := initializeStats(excludeClusters, historyDays); err != nil {
+		if err := initializeStats(selection, historyDays); err != nil {
 			logger.JobError("updateStatsByDay", "Failed to initialize stats: %v", err)
 			return err
 		}
 	}
 
+	// Make the new statistics visible to API readers
+	types.PublishStatsByDay()
+
 	// Persist to backup file
-	if err := saveToBackup(backupFile); err != nil {
+	if err := saveToBackup(ctx, backupFile); err != nil {
 		logger.JobError("updateStatsByDay", "Failed to save backup: %v", err)
 		return err
 	}
@@ -91,13 +115,13 @@ func fileExists(filename string) bool {
 	return !info.IsDir()
 }
 
-// restoreFromBackup restores AllStatsByDay from backup file
-func restoreFromBackup(backupFile string) error {
-	data, err := os.ReadFile(backupFile)
-	if err != nil {
-		return fmt.Errorf("failed to read backup file: %w", err)
-	}
+// remoteBackupName is the name of the stats backup at the backup destination
+func remoteBackupName(backupFile string) string {
+	return "backups/" + filepath.Base(backupFile)
+}
 
+// restoreFromBackup restores AllStatsByDay from the content of a backup
+func restoreFromBackup(data []byte) error {
 	restored := make(map[string]*types.IndicesStatsByDay)
 	if err := json.Unmarshal(data, &restored); err != nil {
 		return fmt.Errorf("failed to unmarshal backup data: %w", err)
@@ -106,13 +130,15 @@ func restoreFromBackup(backupFile string) error {
 	types.StatsByDayMu.Lock()
 	types.AllStatsByDay = restored
 	types.StatsByDayMu.Unlock()
+	types.PublishStatsByDay()
 
 	logger.JobInfo("updateStatsByDay", "Restored statistics for %d clusters from backup", len(restored))
 	return nil
 }
 
-// saveToBackup saves AllStatsByDay to backup file
-func saveToBackup(backupFile string) error {
+// saveToBackup saves AllStatsByDay to backup file, and to the backup destination when one is
+// configured
+func saveToBackup(ctx context.Context, backupFile string) error {
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(backupFile)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -132,6 +158,14 @@ func saveToBackup(backupFile string) error {
 	}
 
 	logger.JobInfo("updateStatsByDay", "Saved statistics to backup file: %s", backupFile)
+
+	if destination := config.Global.BackupDestination; destination != "" {
+		location, err := output.Write(ctx, destination, remoteBackupName(backupFile), data)
+		if err != nil {
+			return fmt.Errorf("failed to write backup to destination %s: %w", destination, err)
+		}
+		logger.JobInfo("updateStatsByDay", "Saved statistics to %s", location)
+	}
 	return nil
 }
 
@@ -170,16 +204,14 @@ func handleExistingStats(historyDays uint8) error {
 }
 
 // initializeStats initializes statistics from scratch
-func initializeStats(excludeClusters []string, historyDays uint8) error {
+func initializeStats(selection clusterSelection, historyDays uint8) error {
 	// Get list of clusters to process
-	types.ClustersMu.RLock()
 	allStatsClustersList := make([]string, 0)
-	for _, clusterName := range types.AllClustersList {
-		if !utils.Contains(excludeClusters, clusterName) {
+	for _, clusterName := range types.ClusterNames() {
+		if selection.selects(clusterName) {
 			allStatsClustersList = append(allStatsClustersList, clusterName)
 		}
 	}
-	types.ClustersMu.RUnlock()
 
 	logger.JobInfo("updateStatsByDay", "Initializing statistics for %d clusters", len(allStatsClustersList))
 
@@ -187,9 +219,7 @@ func initializeStats(excludeClusters []string, historyDays uint8) error {
 
 	// Initialize stats for each cluster
 	for _, clusterName := range allStatsClustersList {
-		types.HistoryMu.RLock()
-		history, exists := types.AllHistory[clusterName]
-		types.HistoryMu.RUnlock()
+		history, exists := types.GetHistory(clusterName)
 
 		if !exists {
 			logger.JobWarn("updateStatsByDay", "No history found for cluster %s, skipping", clusterName)
@@ -197,15 +227,9 @@ func initializeStats(excludeClusters []string, historyDays uint8) error {
 		}
 
 		// Get latest snapshot
-		latestIdx := history.GetLatestIndex()
-		if latestIdx < 0 {
-			logger.JobWarn("updateStatsByDay", "No snapshots found for cluster %s, skipping", clusterName)
-			continue
-		}
-
-		snapshot := history.Ptr[latestIdx]
+		snapshot := history.Latest(0)
 		if snapshot == nil {
-			logger.JobWarn("updateStatsByDay", "Latest snapshot is nil for cluster %s, skipping", clusterName)
+			logger.JobWarn("updateStatsByDay", "No snapshots found for cluster %s, skipping", clusterName)
 			continue
 		}
 
@@ -217,18 +241,14 @@ func initializeStats(excludeClusters []string, historyDays uint8) error {
 
 		// Populate stats for each index
 		for indexName, indexInfo := range snapshot.MapIndices {
-			statHistory := &types.IndexStatHistory{
-				IndexName: indexName,
-				SizeOfPtr: historyDays,
-				StatsPtr:  make([]*types.IndexStat, historyDays+1),
-			}
+			statHistory := types.NewIndexStatHistory(indexName, historyDays)
 
 			// Store current stats in first position
-			statHistory.StatsPtr[0] = &types.IndexStat{
+			statHistory.Stats.Set(0, &types.IndexStat{
 				StatTime:  snapshot.SnapShotTime,
 				TotalSize: indexInfo.TotalStorage,
 				DocCount:  indexInfo.DocCount,
-			}
+			})
 
 			clusterStats.StatHistory[indexName] = statHistory
 		}
@@ -252,24 +272,16 @@ func updateAllClustersStats(daysForward int, historyDays uint8) error {
 
 	for clusterName, clusterStats := range types.AllStatsByDay {
 		// Get latest history for this cluster
-		types.HistoryMu.RLock()
-		history, exists := types.AllHistory[clusterName]
-		types.HistoryMu.RUnlock()
+		history, exists := types.GetHistory(clusterName)
 
 		if !exists {
 			logger.JobWarn("updateStatsByDay", "No history found for cluster %s, skipping update", clusterName)
 			continue
 		}
 
-		latestIdx := history.GetLatestIndex()
-		if latestIdx < 0 {
-			logger.JobWarn("updateStatsByDay", "No snapshots found for cluster %s, skipping update", clusterName)
-			continue
-		}
-
-		snapshot := history.Ptr[latestIdx]
+		snapshot := history.Latest(0)
 		if snapshot == nil {
-			logger.JobWarn("updateStatsByDay", "Latest snapshot is nil for cluster %s, skipping update", clusterName)
+			logger.JobWarn("updateStatsByDay", "No snapshots found for cluster %s, skipping update", clusterName)
 			continue
 		}
 
@@ -302,21 +314,17 @@ func updateAllClustersStats(daysForward int, historyDays uint8) error {
 				rollStatsForward(statHistory, daysForward)
 			} else {
 				// Create new stat history for new index
-				statHistory = &types.IndexStatHistory{
-					IndexName: indexName,
-					SizeOfPtr: historyDays,
-					StatsPtr:  make([]*types.IndexStat, historyDays+1),
-				}
+				statHistory = types.NewIndexStatHistory(indexName, historyDays)
 				clusterStats.StatHistory[indexName] = statHistory
 				logger.JobInfo("updateStatsByDay", "Added new index %s to cluster %s stats", indexName, clusterName)
 			}
 
 			// Store current stats in position 0
-			statHistory.StatsPtr[0] = &types.IndexStat{
+			statHistory.Stats.Set(0, &types.IndexStat{
 				StatTime:  snapshot.SnapShotTime,
 				TotalSize: indexInfo.TotalStorage,
 				DocCount:  indexInfo.DocCount,
-			}
+			})
 		}
 
 		// Update last update time
@@ -327,29 +335,11 @@ func updateAllClustersStats(daysForward int, historyDays uint8) error {
 	return nil
 }
 
-// rollStatsForward rolls statistics pointers forward by specified number of days
+// rollStatsForward ages the statistics by the specified number of days.
+// The newest daysForward slots become empty and the oldest ones are dropped.
 func rollStatsForward(statHistory *types.IndexStatHistory, daysForward int) {
 	if daysForward <= 0 {
 		return
 	}
-
-	size := int(statHistory.SizeOfPtr)
-
-	// If rolling forward more than size, all will be nil except position 0
-	if daysForward > size {
-		for i := 1; i <= size; i++ {
-			statHistory.StatsPtr[i] = nil
-		}
-		return
-	}
-
-	// Roll pointers forward
-	for i := size; i >= daysForward; i-- {
-		statHistory.StatsPtr[i] = statHistory.StatsPtr[i-daysForward]
-	}
-
-	// Set positions 1 to daysForward-1 to nil
-	for i := 1; i < daysForward; i++ {
-		statHistory.StatsPtr[i] = nil
-	}
+	statHistory.Stats.Shift(daysForward)
 }
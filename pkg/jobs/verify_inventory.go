@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+)
+
+// clusterUUIDSource is the event store source of endpoints answering for another cluster
+const clusterUUIDSource = "clusterUUID"
+
+// uuidCheckTTL is how long the GET / of an active endpoint is reused by the UUID checks of
+// the collections, so the jobs of a cluster share one request
+const uuidCheckTTL = time.Minute
+
+var (
+	uuidMismatchesMu sync.Mutex
+	uuidMismatches   = make(map[string]events.Observation) // key: cluster name
+)
+
+// VerifyInventory checks the active endpoint of every cluster against the inventory: a
+// cluster without a UUID gets the one its endpoint reports, a cluster whose endpoint reports
+// another UUID fires a ClusterUUIDMismatch event (someone pointed its SAN at the wrong
+// cluster). Run it at startup after updateActiveEndpoint; the collections verify the UUID of
+// the clusters they process from then on.
+func VerifyInventory(ctx context.Context, params map[string]interface{}) error {
+	p := jobparams.New(params)
+	opts := clusterRunOptionsFromParams("verifyInventory", p)
+	opts.MaxConcurrent = p.IntInRange("maxConcurrent", 10, 1, 50)
+	opts.SkipUUIDCheck = true // the job checks the UUID itself
+	opts.Skip = func(cluster *types.ClusterData) string {
+		if cluster.ActiveEndpoint == "" {
+			return "has no active endpoint"
+		}
+		return ""
+	}
+	if err := checkParams("verifyInventory", p); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	populated := make([]string, 0)
+
+	_, err := ForEachCluster(ctx, opts, func(ctx context.Context, clusterName string) error {
+		cluster, err := queryableCluster(clusterName)
+		if err != nil {
+			return err
+		}
+		if cluster.ClusterUUID != "" {
+			return verifyClusterUUID(ctx, clusterName)
+		}
+
+		root, err := endpointRoot(ctx, cluster, cluster.ActiveEndpoint, queryOptions{JobName: "verifyInventory", TTL: uuidCheckTTL})
+		if err != nil {
+			return err
+		}
+		if root.ClusterUUID == "" {
+			return fmt.Errorf("%s reports no cluster_uuid", cluster.ActiveEndpoint)
+		}
+		types.UpdateCluster(clusterName, func(cluster *types.ClusterData) {
+			if cluster.ClusterUUID == "" {
+				cluster.ClusterUUID = root.ClusterUUID
+			}
+		})
+		logger.JobInfo("verifyInventory", "Cluster %s: UUID set to %s (reported by %s as cluster %s)",
+			clusterName, root.ClusterUUID, cluster.ActiveEndpoint, root.ClusterName)
+
+		mu.Lock()
+		populated = append(populated, clusterName)
+		mu.Unlock()
+		return nil
+	})
+
+	sort.Strings(populated)
+	logger.JobInfo("verifyInventory", "Completed: UUID populated for %d clusters %v", len(populated), populated)
+	return err
+}
+
+// verifyClusterUUID checks that the active endpoint of a cluster answers with the UUID of the
+// inventory. A mismatch fires a ClusterUUIDMismatch event and is returned as an error, so the
+// collection does not store the data of another cluster. Clusters without a UUID or endpoint,
+// and endpoints that cannot be reached (the collection reports those), pass.
+func verifyClusterUUID(ctx context.Context, clusterName string) error {
+	cluster, exists := types.GetCluster(clusterName)
+	if !exists || cluster.ClusterUUID == "" || cluster.ActiveEndpoint == "" || cluster.AccessCred.Preferred == 0 {
+		return nil
+	}
+	root, err := endpointRoot(ctx, cluster, cluster.ActiveEndpoint, queryOptions{JobName: "verifyClusterUUID", TTL: uuidCheckTTL})
+	if err != nil || root.ClusterUUID == "" {
+		return nil
+	}
+
+	if root.ClusterUUID == cluster.ClusterUUID {
+		recordUUIDMismatch(clusterName, nil)
+		return nil
+	}
+	recordUUIDMismatch(clusterName, &events.Observation{
+		Name:     "ClusterUUIDMismatch",
+		Severity: "critical",
+		Labels:   map[string]string{"cluster": clusterName},
+		Annotations: map[string]string{
+			"summary": fmt.Sprintf("Endpoint %s answers for cluster %s (UUID %s), the inventory expects UUID %s",
+				cluster.ActiveEndpoint, root.ClusterName, root.ClusterUUID, cluster.ClusterUUID),
+			"endpoint":            cluster.ActiveEndpoint,
+			"expectedUUID":        cluster.ClusterUUID,
+			"reportedUUID":        root.ClusterUUID,
+			"reportedClusterName": root.ClusterName,
+		},
+	})
+	return fmt.Errorf("endpoint %s answers for cluster %s (UUID %s), expected UUID %s",
+		cluster.ActiveEndpoint, root.ClusterName, root.ClusterUUID, cluster.ClusterUUID)
+}
+
+// renameUUIDMismatch moves the mismatch of a renamed cluster to its new name, where its event
+// is relabeled, so the next check of the cluster resolves or keeps that event
+func renameUUIDMismatch(from, to string) {
+	uuidMismatchesMu.Lock()
+	defer uuidMismatchesMu.Unlock()
+
+	observation, ok := uuidMismatches[from]
+	if !ok {
+		return
+	}
+	delete(uuidMismatches, from)
+	labels := make(map[string]string, len(observation.Labels))
+	for k, v := range observation.Labels {
+		labels[k] = v
+	}
+	labels["cluster"] = to
+	observation.Labels = labels
+	uuidMismatches[to] = observation
+}
+
+// recordUUIDMismatch records the outcome of a UUID check, a nil mismatch for a match, and
+// syncs the ClusterUUIDMismatch events; the match of a cluster without a mismatch changes nothing
+func recordUUIDMismatch(clusterName string, mismatch *events.Observation) {
+	uuidMismatchesMu.Lock()
+	defer uuidMismatchesMu.Unlock()
+
+	_, known := uuidMismatches[clusterName]
+	if mismatch == nil && !known {
+		return
+	}
+	if mismatch != nil {
+		uuidMismatches[clusterName] = *mismatch
+	} else {
+		delete(uuidMismatches, clusterName)
+	}
+
+	observed := make([]events.Observation, 0, len(uuidMismatches))
+	for name, observation := range uuidMismatches {
+		if !types.ClusterExists(name) {
+			delete(uuidMismatches, name)
+			continue
+		}
+		observed = append(observed, observation)
+	}
+	if _, _, err := events.Sync(clusterUUIDSource, observed, time.Now()); err != nil {
+		logger.AppError("Failed to persist the cluster UUID events: %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package jobs
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	jobparams "ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/types"
+)
+
+// Values of the aggregate of a write pressure backtest over a rollup: the queue of a bucket
+const (
+	BacktestAvg = "avg"
+	BacktestMax = "max"
+)
+
+// WritePressureCandidate is a configuration of checkForWritePressure to backtest: job
+// parameters over those of the configured job
+type WritePressureCandidate struct {
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// WritePressureBacktestOptions is what a write pressure backtest replays
+type WritePressureBacktestOptions struct {
+	ResolutionMs int64  // 0 = the raw data points, else the interval of a rollup
+	Aggregate    string // queue of a rollup bucket: BacktestAvg or BacktestMax
+	Window       int    // data points a check sees, 0 = the raw data points kept per host
+}
+
+// WritePressureBacktestResult is what a candidate configuration would have done over the
+// replayed history
+type WritePressureBacktestResult struct {
+	Name                 string         `json:"name"`
+	Threshold            int            `json:"threshold"`
+	CriticalThreshold    int            `json:"criticalThreshold"`
+	ClearThreshold       int            `json:"clearThreshold"`
+	GrowthPerInterval    int            `json:"growthPerInterval"`
+	DetectionMode        string         `json:"detectionMode"`
+	ConsecutiveIntervals int            `json:"consecutiveIntervals"`
+	GrowthIntervals      int            `json:"growthIntervals"`
+	MissingDataMode      string         `json:"missingDataMode"`
+	Events               int            `json:"events"`      // events fired
+	Critical             int            `json:"critical"`    // events fired as or escalated to critical
+	Escalations          int            `json:"escalations"` // warning events escalated to critical
+	Resolved             int            `json:"resolved"`
+	Flaps                int            `json:"flaps"`              // events fired within a window of the previous event of the host resolving
+	StillFiring          int            `json:"stillFiring"`        // events firing at the end of the history
+	PressuredIntervals   int            `json:"pressuredIntervals"` // checks of a host under pressure, all hosts together
+	HostEvents           map[string]int `json:"hostEvents"`         // events per host, hosts without events left out
+}
+
+// WritePressureBacktest is the outcome of a write pressure backtest of a cluster
+type WritePressureBacktest struct {
+	Cluster      string                        `json:"cluster"`
+	ResolutionMs int64                         `json:"resolutionMs"`
+	Aggregate    string                        `json:"aggregate,omitempty"`
+	Window       int                           `json:"window"`
+	Hosts        int                           `json:"hosts"`
+	Intervals    int                           `json:"intervals"` // checks replayed per host, at most
+	From         int64                         `json:"from"`      // oldest data point replayed, epoch milliseconds
+	To           int64                         `json:"to"`        // newest data point replayed
+	Candidates   []WritePressureBacktestResult `json:"candidates"`
+}
+
+// backtestHost is the history of a host replayed by a backtest, oldest first
+type backtestHost struct {
+	name   string
+	points []types.TPWPoint
+	window int
+}
+
+// BacktestWritePressure replays the stored thread pool write queue history of a cluster
+// through candidate configurations of checkForWritePressure, and counts the host events each
+// would have fired. The history is checked once per data point, as if the job ran every
+// interval, with the window of data points a run sees; hysteresis and escalations follow the
+// job. Each candidate is read over base, the parameters of the configured job, including its
+// clusterThresholds. Cluster-wide events are not replayed.
+func BacktestWritePressure(clusterName string, base map[string]interface{}, candidates []WritePressureCandidate,
+	opts WritePressureBacktestOptions) (*WritePressureBacktest, error) {
+	if opts.Aggregate == "" {
+		opts.Aggregate = BacktestAvg
+	}
+	if opts.Aggregate != BacktestAvg && opts.Aggregate != BacktestMax {
+		return nil, fmt.Errorf("aggregate must be %s or %s, got %q", BacktestAvg, BacktestMax, opts.Aggregate)
+	}
+	if opts.Window < 0 {
+		return nil, fmt.Errorf("window must not be negative")
+	}
+
+	type configuration struct {
+		name       string
+		thresholds writePressureThresholds
+		detection  writePressureDetection
+	}
+	configurations := make([]configuration, 0, len(candidates))
+	for i, candidate := range candidates {
+		name := candidate.Name
+		if name == "" {
+			name = fmt.Sprintf("candidate%d", i+1)
+		}
+		merged := make(map[string]interface{}, len(base)+len(candidate.Parameters))
+		for key, value := range base {
+			merged[key] = value
+		}
+		for key, value := range candidate.Parameters {
+			merged[key] = value
+		}
+		p := jobparams.New(merged)
+		detection := writePressureDetectionParams(p)
+		thresholds, clusterThresholds, err := writePressureThresholdParams(p)
+		if err == nil {
+			err = p.Err()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if override, ok := clusterThresholds[clusterName]; ok {
+			thresholds = override
+		}
+		configurations = append(configurations, configuration{name: name, thresholds: thresholds, detection: detection})
+	}
+
+	clusterData, ok := types.GetTPWQueue(clusterName)
+	if !ok {
+		return nil, fmt.Errorf("no thread pool write queue data for cluster %s", clusterName)
+	}
+	hosts := make([]backtestHost, 0, len(clusterData.HostnameList))
+	for _, hostName := range clusterData.HostnameList {
+		tpwq := clusterData.HostTPWQueue[hostName]
+		if tpwq == nil || (opts.ResolutionMs > 0 && tpwq.Rollup(opts.ResolutionMs) == nil) {
+			continue
+		}
+		points := backtestPoints(tpwq, opts.ResolutionMs, opts.Aggregate)
+		window := opts.Window
+		if window == 0 {
+			window = tpwq.NumberOfDataPoints
+		}
+		hosts = append(hosts, backtestHost{name: hostName, points: points, window: max(window, 1)})
+	}
+	if len(hosts) == 0 && opts.ResolutionMs > 0 {
+		return nil, fmt.Errorf("no host of cluster %s keeps a rollup of %s", clusterName, time.Duration(opts.ResolutionMs)*time.Millisecond)
+	}
+
+	report := &WritePressureBacktest{
+		Cluster:      clusterName,
+		ResolutionMs: opts.ResolutionMs,
+		Window:       opts.Window,
+		Hosts:        len(hosts),
+		Candidates:   make([]WritePressureBacktestResult, 0, len(configurations)),
+	}
+	if opts.ResolutionMs > 0 {
+		report.Aggregate = opts.Aggregate
+	}
+	for _, host := range hosts {
+		if len(host.points) == 0 {
+			continue
+		}
+		report.Intervals = max(report.Intervals, len(host.points))
+		if first := host.points[0].TimeStamp; report.From == 0 || first < report.From {
+			report.From = first
+		}
+		report.To = max(report.To, host.points[len(host.points)-1].TimeStamp)
+		if report.Window == 0 {
+			report.Window = host.window
+		}
+	}
+
+	for _, c := range configurations {
+		result := WritePressureBacktestResult{
+			Name:                 c.name,
+			Threshold:            c.thresholds.Warning,
+			CriticalThreshold:    c.thresholds.Critical,
+			ClearThreshold:       c.thresholds.Clear,
+			GrowthPerInterval:    c.thresholds.Growth,
+			DetectionMode:        c.detection.mode,
+			ConsecutiveIntervals: c.detection.consecutiveIntervals,
+			GrowthIntervals:      c.detection.growthIntervals,
+			MissingDataMode:      c.detection.missingDataMode,
+			HostEvents:           make(map[string]int),
+		}
+		for _, host := range hosts {
+			replayHost(host, c.thresholds, c.detection, &result)
+		}
+		report.Candidates = append(report.Candidates, result)
+	}
+	return report, nil
+}
+
+// replayHost checks the history of a host once per data point with a candidate configuration
+// and adds the events it fires and resolves to result
+func replayHost(host backtestHost, thresholds writePressureThresholds, detection writePressureDetection,
+	result *WritePressureBacktestResult) {
+	severity := ""   // severity of the firing event, "" = none
+	resolvedAt := -1 // step the latest event resolved at, -1 = none yet
+	for step := range host.points {
+		tpwq := types.NewTPWQueue(host.window)
+		for _, point := range host.points[max(0, step-host.window+1) : step+1] {
+			tpwq.Points.Push(point)
+		}
+
+		pressure, pressured := checkHostForWritePressure(tpwq, thresholds, detection, severity != "")
+		switch {
+		case pressured && severity == "":
+			result.Events++
+			result.HostEvents[host.name]++
+			if pressure.severity == writePressureCritical {
+				result.Critical++
+			}
+			if resolvedAt >= 0 && step-resolvedAt <= host.window {
+				result.Flaps++
+			}
+		case pressured && severity == writePressureWarning && pressure.severity == writePressureCritical:
+			result.Escalations++
+			result.Critical++
+		case !pressured && severity != "":
+			result.Resolved++
+			resolvedAt = step
+		}
+		severity = ""
+		if pressured {
+			severity = pressure.severity
+			result.PressuredIntervals++
+		}
+	}
+	if severity != "" {
+		result.StillFiring++
+	}
+}
+
+// backtestPoints returns the history of a host oldest first, from its oldest data point: the
+// raw data points, or the buckets of a rollup as data points of their average or maximum queue
+func backtestPoints(tpwq *types.TPWQueue, resolutionMs int64, aggregate string) []types.TPWPoint {
+	points := make([]types.TPWPoint, 0)
+	if resolutionMs == 0 {
+		for _, point := range tpwq.Points.OldestFirst() {
+			if point.Exists || len(points) > 0 {
+				points = append(points, point)
+			}
+		}
+		return points
+	}
+
+	rollup := tpwq.Rollup(resolutionMs)
+	if rollup == nil || rollup.NewestStart == 0 {
+		return points
+	}
+	for i, bucket := range rollup.Points.OldestFirst() {
+		if bucket.Count == 0 && len(points) == 0 {
+			continue
+		}
+		point := types.TPWPoint{
+			TimeStamp: rollup.NewestStart - int64(rollup.Points.Cap()-1-i)*rollup.IntervalMs,
+			Exists:    bucket.Count > 0,
+		}
+		if point.Exists {
+			point.Queue = bucket.Max
+			if aggregate == BacktestAvg {
+				point.Queue = uint32(math.Round(bucket.Avg))
+			}
+		}
+		points = append(points, point)
+	}
+	return points
+}
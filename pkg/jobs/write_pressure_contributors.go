@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/types"
+)
+
+// defaultTopContributors is the number of index shards named in a write pressure event
+const defaultTopContributors = 5
+
+// pressureContributor is the bulk write activity of one index shard on a host, summed over
+// the bulk task snapshots of a write pressure window
+type pressureContributor struct {
+	IndexShard  string // index_shard, as keyed in the bulk task snapshots
+	Tasks       uint
+	Requests    uint
+	TimeTakenMs uint64
+}
+
+// writePressureContributors joins a host under write pressure with the bulk task history of
+// its cluster: it sums the bulk tasks of every index shard on the host over the snapshots
+// taken between from and to (epoch milliseconds) and returns the top shards by time taken,
+// together with the host's total bulk time in the window
+func writePressureContributors(clusterName, hostName string, from, to int64, top int) ([]pressureContributor, uint64) {
+	history, ok := types.GetBulkTasksHistory(clusterName)
+	if !ok || history.PtrClusterDataWriteBulk_sTasks == nil {
+		return nil, 0
+	}
+
+	byShard := make(map[string]*pressureContributor)
+	var totalMs uint64
+	for _, snapshot := range history.PtrClusterDataWriteBulk_sTasks.NewestFirst() {
+		if snapshot == nil || snapshot.SnapShotTime < from || snapshot.SnapShotTime > to {
+			continue
+		}
+		node, ok := snapshot.DataWriteBulk_sTasksByNode[hostName]
+		if !ok || node == nil {
+			continue
+		}
+		for indexShard, agg := range node.DataWriteBulk_sByShard {
+			c, ok := byShard[indexShard]
+			if !ok {
+				c = &pressureContributor{IndexShard: indexShard}
+				byShard[indexShard] = c
+			}
+			c.Tasks += uint(agg.NumberOfTasks)
+			c.Requests += agg.TotalRequests
+			c.TimeTakenMs += agg.TotalTimeTaken_ms
+			totalMs += agg.TotalTimeTaken_ms
+		}
+	}
+
+	contributors := make([]pressureContributor, 0, len(byShard))
+	for _, c := range byShard {
+		contributors = append(contributors, *c)
+	}
+	sort.Slice(contributors, func(i, j int) bool {
+		a, b := contributors[i], contributors[j]
+		if a.TimeTakenMs != b.TimeTakenMs {
+			return a.TimeTakenMs > b.TimeTakenMs
+		}
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.IndexShard < b.IndexShard
+	})
+	if len(contributors) > top {
+		contributors = contributors[:top]
+	}
+	return contributors, totalMs
+}
+
+// formatContributors renders contributors for an event annotation, e.g.
+// "logs-app-2026.01.15[3] 42% (12.3s, 340 requests, 12 tasks); ..."
+func formatContributors(contributors []pressureContributor, totalMs uint64) string {
+	parts := make([]string, 0, len(contributors))
+	for _, c := range contributors {
+		share := 0.0
+		if totalMs > 0 {
+			share = float64(c.TimeTakenMs) * 100 / float64(totalMs)
+		}
+		parts = append(parts, fmt.Sprintf("%s %.0f%% (%s, %d requests, %d tasks)",
+			shardLabel(c.IndexShard), share,
+			(time.Duration(c.TimeTakenMs)*time.Millisecond).Round(100*time.Millisecond),
+			c.Requests, c.Tasks))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// shardLabel renders an index_shard key as index[shard]
+func shardLabel(indexShard string) string {
+	i := strings.LastIndex(indexShard, "_")
+	if i < 0 {
+		return indexShard
+	}
+	return fmt.Sprintf("%s[%s]", indexShard[:i], indexShard[i+1:])
+}
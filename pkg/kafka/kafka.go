@@ -0,0 +1,497 @@
+// Package kafka publishes events and job failures to a Kafka topic, so stream processing and
+// SIEM systems consume them as they happen instead of polling the API.
+//
+// Every event that fires or resolves in the event store (write and heap pressure, alert
+// rules, ...) and every failed job run becomes one message, keyed by its cluster (the job
+// name for job failures) so the messages of a cluster stay in order on one partition.
+// Messages are queued and sent in batches by a background goroutine; when the brokers are
+// unreachable the queue fills up and further messages are dropped rather than blocking the
+// jobs. Messages are JSON or Avro binary encoded (see AvroSchema).
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/utils"
+)
+
+// Message types
+const (
+	TypeEvent      = "event"
+	TypeJobFailure = "jobFailure"
+)
+
+// Batching of the background sender
+const (
+	maxBatch       = 500
+	lingerInterval = 500 * time.Millisecond
+	sendAttempts   = 3
+)
+
+// Message is a published event change or job failure. Job failures carry the job in Labels
+// ("job", "internalJobName") and the error of the run in Error.
+type Message struct {
+	Type        string            `json:"type"`      // event or jobFailure
+	Timestamp   int64             `json:"timestamp"` // epoch milliseconds (UTC) of the change
+	Cluster     string            `json:"cluster"`   // "" for job failures
+	Source      string            `json:"source"`    // event source, "scheduler" for job failures
+	Name        string            `json:"name"`      // event name or job name
+	Severity    string            `json:"severity"`
+	State       string            `json:"state"` // firing, resolved or failed
+	ID          string            `json:"id"`    // event ID, "" for job failures
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    int64             `json:"startsAt"` // epoch milliseconds (UTC)
+	EndsAt      int64             `json:"endsAt"`   // epoch milliseconds (UTC), 0 while firing
+	Suppressed  bool              `json:"suppressed"`
+	Error       string            `json:"error"`
+}
+
+// AvroSchema is the schema of the Avro encoded messages, for the schema registry of the
+// consumers
+const AvroSchema = `{"type":"record","name":"ObservabilityMessage","namespace":"elasticobservability","fields":[` +
+	`{"name":"type","type":"string"},` +
+	`{"name":"timestamp","type":{"type":"long","logicalType":"timestamp-millis"}},` +
+	`{"name":"cluster","type":"string"},` +
+	`{"name":"source","type":"string"},` +
+	`{"name":"name","type":"string"},` +
+	`{"name":"severity","type":"string"},` +
+	`{"name":"state","type":"string"},` +
+	`{"name":"id","type":"string"},` +
+	`{"name":"labels","type":{"type":"map","values":"string"}},` +
+	`{"name":"annotations","type":{"type":"map","values":"string"}},` +
+	`{"name":"startsAt","type":"long"},` +
+	`{"name":"endsAt","type":"long"},` +
+	`{"name":"suppressed","type":"boolean"},` +
+	`{"name":"error","type":"string"}]}`
+
+// settings are the validated publishing settings
+type settings struct {
+	brokers     []string
+	topic       string
+	format      string
+	schemaID    int
+	sources     map[string]bool // nil = all sources
+	jobFailures bool
+	clientID    string
+	acks        int16
+	timeout     time.Duration
+	tls         *tls.Config
+	username    string
+	password    string
+}
+
+// publisher sends the queued messages; conns and meta belong to its goroutine
+type publisher struct {
+	settings *settings
+	queue    chan *Message
+	stop     chan struct{}
+	done     chan struct{}
+	conns    map[string]*brokerConn // key: host:port
+	meta     *topicMetadata
+}
+
+var (
+	mu      sync.RWMutex
+	current *publisher // nil = publishing off
+)
+
+// Configure starts publishing to the configured topic; without brokers and topic publishing
+// stays off. A publisher already running is closed first.
+func Configure(cfg config.KafkaConfig) error {
+	Close()
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return nil
+	}
+
+	s := &settings{
+		brokers:     cfg.Brokers,
+		topic:       cfg.Topic,
+		format:      cfg.Format,
+		schemaID:    cfg.SchemaID,
+		jobFailures: cfg.JobFailures == nil || *cfg.JobFailures,
+		clientID:    cfg.ClientID,
+		acks:        int16(cfg.Acks),
+		username:    cfg.Username,
+		password:    cfg.Password,
+	}
+	if len(cfg.Sources) > 0 {
+		s.sources = make(map[string]bool, len(cfg.Sources))
+		for _, source := range cfg.Sources {
+			s.sources[source] = true
+		}
+	}
+	var err error
+	if s.timeout, err = utils.ParseDuration(cfg.Timeout); err != nil {
+		return fmt.Errorf("invalid kafka.timeout: %w", err)
+	}
+	if cfg.TLS {
+		s.tls = &tls.Config{InsecureSkipVerify: cfg.InsecureTLS}
+		if cfg.CACert != "" {
+			pem, err := os.ReadFile(cfg.CACert)
+			if err != nil {
+				return fmt.Errorf("failed to read kafka.caCert: %w", err)
+			}
+			s.tls.RootCAs = x509.NewCertPool()
+			if !s.tls.RootCAs.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("kafka.caCert %s holds no certificate", cfg.CACert)
+			}
+		}
+	}
+
+	p := &publisher{
+		settings: s,
+		queue:    make(chan *Message, cfg.BufferSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		conns:    make(map[string]*brokerConn),
+	}
+	go p.run()
+
+	mu.Lock()
+	current = p
+	mu.Unlock()
+	return nil
+}
+
+// Close stops publishing, sending the queued messages first
+func Close() {
+	mu.Lock()
+	p := current
+	current = nil
+	mu.Unlock()
+
+	if p != nil {
+		close(p.stop)
+		<-p.done
+	}
+}
+
+// PublishEvent queues an event change, if its source is published; it is subscribed to the
+// event store
+func PublishEvent(event events.Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil || (current.settings.sources != nil && !current.settings.sources[event.Source]) {
+		return
+	}
+
+	timestamp := event.UpdatedAt
+	if timestamp == 0 {
+		timestamp = event.StartsAt
+	}
+	current.enqueue(&Message{
+		Type:        TypeEvent,
+		Timestamp:   timestamp,
+		Cluster:     event.Cluster(),
+		Source:      event.Source,
+		Name:        event.Name,
+		Severity:    event.Severity,
+		State:       event.State,
+		ID:          event.ID,
+		Labels:      event.Labels,
+		Annotations: event.Annotations,
+		StartsAt:    event.StartsAt,
+		EndsAt:      event.EndsAt,
+		Suppressed:  event.Suppressed,
+	})
+}
+
+// PublishJobFailure queues a failed job run that started at started
+func PublishJobFailure(jobName, internalJobName string, started time.Time, runErr error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil || !current.settings.jobFailures {
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	labels := map[string]string{"job": jobName}
+	if internalJobName != "" {
+		labels["internalJobName"] = internalJobName
+	}
+	current.enqueue(&Message{
+		Type:      TypeJobFailure,
+		Timestamp: now,
+		Source:    "scheduler",
+		Name:      jobName,
+		Severity:  "warning",
+		State:     "failed",
+		Labels:    labels,
+		StartsAt:  started.UnixMilli(),
+		EndsAt:    now,
+		Error:     runErr.Error(),
+	})
+}
+
+// enqueue queues a message without blocking; callers hold mu
+func (p *publisher) enqueue(msg *Message) {
+	select {
+	case p.queue <- msg:
+	default:
+		metrics.KafkaMessagesTotal.WithLabelValues(msg.Type, "dropped").Inc()
+	}
+}
+
+// run sends the queued messages in batches until stopped
+func (p *publisher) run() {
+	defer close(p.done)
+	defer func() {
+		for _, conn := range p.conns {
+			conn.close()
+		}
+	}()
+
+	ticker := time.NewTicker(lingerInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Message, 0, maxBatch)
+	for {
+		select {
+		case msg := <-p.queue:
+			if batch = append(batch, msg); len(batch) >= maxBatch {
+				p.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.send(batch)
+				batch = batch[:0]
+			}
+		case <-p.stop:
+			for len(p.queue) > 0 {
+				batch = append(batch, <-p.queue)
+			}
+			if len(batch) > 0 {
+				p.send(batch)
+			}
+			return
+		}
+	}
+}
+
+// outgoing is a queued message with its encoded record
+type outgoing struct {
+	msg *Message
+	rec record
+}
+
+// send publishes a batch, retrying the partitions that failed, and counts the outcome
+func (p *publisher) send(batch []*Message) {
+	pending := make([]outgoing, 0, len(batch))
+	for _, msg := range batch {
+		value, err := p.encode(msg)
+		if err != nil {
+			logger.AppWarn("Kafka: failed to encode %s %s: %v", msg.Type, msg.Name, err)
+			metrics.KafkaMessagesTotal.WithLabelValues(msg.Type, "failed").Inc()
+			continue
+		}
+		key := msg.Cluster
+		if key == "" {
+			key = msg.Name
+		}
+		pending = append(pending, outgoing{msg: msg, rec: record{key: []byte(key), value: value, timestamp: msg.Timestamp}})
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= sendAttempts && len(pending) > 0; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * time.Second)
+		}
+		pending, lastErr = p.sendOnce(pending)
+	}
+
+	for _, out := range pending {
+		metrics.KafkaMessagesTotal.WithLabelValues(out.msg.Type, "failed").Inc()
+	}
+	if len(pending) > 0 {
+		logger.AppWarn("Kafka: %d messages not published to %s: %v", len(pending), p.settings.topic, lastErr)
+	}
+}
+
+// sendOnce sends the pending messages to the leaders of their partitions and returns those
+// not published, in their queue order
+func (p *publisher) sendOnce(pending []outgoing) ([]outgoing, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.settings.timeout)
+	defer cancel()
+
+	if p.meta == nil {
+		meta, err := p.fetchMetadata(ctx)
+		if err != nil {
+			return pending, err
+		}
+		p.meta = meta
+	}
+
+	// Group by leader, then partition, keeping the queue order within a partition
+	byLeader := make(map[int32]map[int32][]outgoing)
+	for _, out := range pending {
+		partition := partitionFor(out.rec.key, len(p.meta.leaders))
+		leader := p.meta.leaders[partition]
+		if byLeader[leader] == nil {
+			byLeader[leader] = make(map[int32][]outgoing)
+		}
+		byLeader[leader][partition] = append(byLeader[leader][partition], out)
+	}
+
+	published := make(map[*Message]bool, len(pending))
+	var errs []error
+	for leader, partitions := range byLeader {
+		addr, ok := p.meta.brokers[leader]
+		if !ok {
+			p.meta = nil
+			errs = append(errs, fmt.Errorf("no leader for %d partitions", len(partitions)))
+			continue
+		}
+		conn, err := p.conn(ctx, addr)
+		if err != nil {
+			p.meta = nil
+			errs = append(errs, err)
+			continue
+		}
+
+		records := make(map[int32][]record, len(partitions))
+		for partition, batch := range partitions {
+			for _, out := range batch {
+				records[partition] = append(records[partition], out.rec)
+			}
+		}
+		failed, err := conn.produce(p.settings.topic, p.settings.acks, p.settings.timeout, records)
+		if err != nil {
+			conn.close()
+			delete(p.conns, addr)
+			p.meta = nil
+			errs = append(errs, fmt.Errorf("broker %s: %w", addr, err))
+			continue
+		}
+		for partition, batch := range partitions {
+			if err, ok := failed[partition]; ok {
+				var code kafkaError
+				if errors.As(err, &code) && code.retriable() {
+					p.meta = nil
+				}
+				errs = append(errs, fmt.Errorf("partition %d: %w", partition, err))
+				continue
+			}
+			for _, out := range batch {
+				published[out.msg] = true
+				metrics.KafkaMessagesTotal.WithLabelValues(out.msg.Type, "published").Inc()
+			}
+		}
+	}
+
+	remaining := pending[:0]
+	for _, out := range pending {
+		if !published[out.msg] {
+			remaining = append(remaining, out)
+		}
+	}
+	return remaining, errors.Join(errs...)
+}
+
+// fetchMetadata reads the partition leaders of the topic from the first broker answering,
+// the configured brokers first
+func (p *publisher) fetchMetadata(ctx context.Context) (*topicMetadata, error) {
+	addrs := append([]string{}, p.settings.brokers...)
+	for addr := range p.conns {
+		addrs = append(addrs, addr)
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := p.conn(ctx, addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		meta, err := conn.metadata(p.settings.topic)
+		if err != nil {
+			conn.close()
+			delete(p.conns, addr)
+			errs = append(errs, fmt.Errorf("broker %s: %w", addr, err))
+			continue
+		}
+		return meta, nil
+	}
+	return nil, fmt.Errorf("failed to read the metadata of topic %s: %w", p.settings.topic, errors.Join(errs...))
+}
+
+// conn returns the connection to a broker, connecting first if needed
+func (p *publisher) conn(ctx context.Context, addr string) (*brokerConn, error) {
+	if conn, ok := p.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := dialBroker(ctx, addr, p.settings)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[addr] = conn
+	return conn, nil
+}
+
+// encode returns the value of a message in the configured format. Avro messages carry the
+// Confluent wire format header when a schema ID is configured.
+func (p *publisher) encode(msg *Message) ([]byte, error) {
+	if p.settings.format != "avro" {
+		return json.Marshal(msg)
+	}
+
+	var buf []byte
+	if p.settings.schemaID > 0 {
+		buf = append(buf, 0)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(p.settings.schemaID))
+	}
+	buf = avroString(buf, msg.Type)
+	buf = binary.AppendVarint(buf, msg.Timestamp)
+	for _, s := range []string{msg.Cluster, msg.Source, msg.Name, msg.Severity, msg.State, msg.ID} {
+		buf = avroString(buf, s)
+	}
+	buf = avroMap(buf, msg.Labels)
+	buf = avroMap(buf, msg.Annotations)
+	buf = binary.AppendVarint(buf, msg.StartsAt)
+	buf = binary.AppendVarint(buf, msg.EndsAt)
+	if msg.Suppressed {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	return avroString(buf, msg.Error), nil
+}
+
+func avroString(buf []byte, s string) []byte {
+	buf = binary.AppendVarint(buf, int64(len(s)))
+	return append(buf, s...)
+}
+
+// avroMap encodes a map as one block, keys sorted
+func avroMap(buf []byte, m map[string]string) []byte {
+	if len(m) == 0 {
+		return binary.AppendVarint(buf, 0)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf = binary.AppendVarint(buf, int64(len(keys)))
+	for _, k := range keys {
+		buf = avroString(buf, k)
+		buf = avroString(buf, m[k])
+	}
+	return binary.AppendVarint(buf, 0)
+}
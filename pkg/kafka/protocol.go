@@ -0,0 +1,443 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The subset of the Kafka protocol the publisher speaks: metadata to find the partition leaders
+// of the topic, produce with v2 record batches (Kafka 0.11 and later), and SASL/PLAIN
+// authentication.
+const (
+	apiProduce          = 0
+	apiMetadata         = 3
+	apiSaslHandshake    = 17
+	apiSaslAuthenticate = 36
+
+	produceVersion  = 3
+	metadataVersion = 1
+)
+
+// maxResponseSize bounds the responses read from a broker
+const maxResponseSize = 64 << 20
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaError is an error code returned by a broker
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	switch e {
+	case 3:
+		return "unknown topic or partition"
+	case 5:
+		return "leader not available"
+	case 6:
+		return "not leader for partition"
+	case 7:
+		return "request timed out"
+	case 10:
+		return "message too large"
+	case 19, 20:
+		return "not enough replicas"
+	case 29:
+		return "topic authorization failed"
+	case 33:
+		return "unsupported SASL mechanism"
+	case 58:
+		return "SASL authentication failed"
+	}
+	return "error code " + strconv.Itoa(int(e))
+}
+
+// retriable reports whether the error clears once the metadata is refreshed
+func (e kafkaError) retriable() bool {
+	return e == 3 || e == 5 || e == 6 || e == 7 || e == 19 || e == 20
+}
+
+// record is a message of a record batch
+type record struct {
+	key       []byte
+	value     []byte
+	timestamp int64 // epoch milliseconds (UTC)
+}
+
+// brokerConn is a connection to one broker; requests on it are serialized
+type brokerConn struct {
+	mu            sync.Mutex
+	conn          net.Conn
+	reader        *bufio.Reader
+	clientID      string
+	timeout       time.Duration
+	correlationID int32
+}
+
+// dialBroker connects to a broker and authenticates when a user is configured
+func dialBroker(ctx context.Context, addr string, settings *settings) (*brokerConn, error) {
+	dialer := &net.Dialer{Timeout: settings.timeout}
+	var conn net.Conn
+	var err error
+	if settings.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: settings.tls}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to broker %s: %w", addr, err)
+	}
+
+	b := &brokerConn{conn: conn, reader: bufio.NewReader(conn), clientID: settings.clientID, timeout: settings.timeout}
+	if settings.username != "" {
+		if err := b.saslPlain(settings.username, settings.password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("broker %s: %w", addr, err)
+		}
+	}
+	return b, nil
+}
+
+func (b *brokerConn) close() {
+	b.conn.Close()
+}
+
+// roundTrip sends a request and returns the body of its response
+func (b *brokerConn) roundTrip(apiKey, apiVersion int16, body []byte) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.correlationID++
+	header := make([]byte, 0, 14+len(b.clientID))
+	header = binary.BigEndian.AppendUint32(header, uint32(10+len(b.clientID)+len(body)))
+	header = binary.BigEndian.AppendUint16(header, uint16(apiKey))
+	header = binary.BigEndian.AppendUint16(header, uint16(apiVersion))
+	header = binary.BigEndian.AppendUint32(header, uint32(b.correlationID))
+	header = appendString(header, b.clientID)
+
+	if err := b.conn.SetDeadline(time.Now().Add(b.timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := b.conn.Write(append(header, body...)); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var sizeAndID [8]byte
+	if _, err := io.ReadFull(b.reader, sizeAndID[:]); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	size := binary.BigEndian.Uint32(sizeAndID[:4])
+	if size < 4 || size > maxResponseSize {
+		return nil, fmt.Errorf("invalid response size %d", size)
+	}
+	if id := int32(binary.BigEndian.Uint32(sizeAndID[4:])); id != b.correlationID {
+		return nil, fmt.Errorf("response to request %d instead of %d", id, b.correlationID)
+	}
+	response := make([]byte, size-4)
+	if _, err := io.ReadFull(b.reader, response); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return response, nil
+}
+
+// saslPlain authenticates the connection with SASL/PLAIN
+func (b *brokerConn) saslPlain(username, password string) error {
+	response, err := b.roundTrip(apiSaslHandshake, 1, appendString(nil, "PLAIN"))
+	if err != nil {
+		return fmt.Errorf("SASL handshake failed: %w", err)
+	}
+	d := decoder{buf: response}
+	if code := d.int16(); code != 0 {
+		return fmt.Errorf("SASL handshake failed: %w", kafkaError(code))
+	}
+
+	token := []byte("\x00" + username + "\x00" + password)
+	response, err = b.roundTrip(apiSaslAuthenticate, 0, appendBytes(nil, token))
+	if err != nil {
+		return fmt.Errorf("SASL authentication failed: %w", err)
+	}
+	d = decoder{buf: response}
+	if code := d.int16(); code != 0 {
+		message := d.nullableString()
+		if message == "" {
+			message = kafkaError(code).Error()
+		}
+		return fmt.Errorf("SASL authentication failed: %s", message)
+	}
+	return d.err
+}
+
+// topicMetadata is the partition leaders of a topic
+type topicMetadata struct {
+	brokers map[int32]string // node ID -> host:port
+	leaders []int32          // leader node ID per partition, -1 = none
+}
+
+// metadata returns the brokers and the partition leaders of a topic
+func (b *brokerConn) metadata(topic string) (*topicMetadata, error) {
+	body := binary.BigEndian.AppendUint32(nil, 1)
+	body = appendString(body, topic)
+	response, err := b.roundTrip(apiMetadata, metadataVersion, body)
+	if err != nil {
+		return nil, err
+	}
+
+	d := decoder{buf: response}
+	meta := &topicMetadata{brokers: make(map[int32]string)}
+	for n := d.arrayLen(); n > 0 && d.err == nil; n-- {
+		nodeID := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.nullableString() // rack
+		meta.brokers[nodeID] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller ID
+
+	found := false
+	for n := d.arrayLen(); n > 0 && d.err == nil; n-- {
+		code := d.int16()
+		name := d.string()
+		d.int8() // is internal
+		partitions := d.arrayLen()
+		if partitions < 0 || partitions > len(d.buf) {
+			d.err = errShortResponse
+			break
+		}
+		leaders := make([]int32, partitions)
+		for i := range leaders {
+			leaders[i] = -1
+		}
+		for p := partitions; p > 0 && d.err == nil; p-- {
+			d.int16() // partition error code
+			index := d.int32()
+			leader := d.int32()
+			d.skipInt32Array() // replicas
+			d.skipInt32Array() // in-sync replicas
+			if index >= 0 && int(index) < partitions {
+				leaders[index] = leader
+			}
+		}
+		if name != topic {
+			continue
+		}
+		if code != 0 {
+			return nil, fmt.Errorf("topic %s: %w", topic, kafkaError(code))
+		}
+		meta.leaders, found = leaders, true
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("invalid metadata response: %w", d.err)
+	}
+	if !found || len(meta.leaders) == 0 {
+		return nil, fmt.Errorf("topic %s: %w", topic, kafkaError(3))
+	}
+	return meta, nil
+}
+
+// produce writes one record batch per partition and returns the error of each partition that
+// failed
+func (b *brokerConn) produce(topic string, acks int16, timeout time.Duration, batches map[int32][]record) (map[int32]error, error) {
+	body := binary.BigEndian.AppendUint16(nil, 0xffff) // no transactional ID
+	body = binary.BigEndian.AppendUint16(body, uint16(acks))
+	body = binary.BigEndian.AppendUint32(body, uint32(timeout.Milliseconds()))
+	body = binary.BigEndian.AppendUint32(body, 1)
+	body = appendString(body, topic)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(batches)))
+	for partition, records := range batches {
+		body = binary.BigEndian.AppendUint32(body, uint32(partition))
+		body = appendBytes(body, recordBatch(records))
+	}
+
+	response, err := b.roundTrip(apiProduce, produceVersion, body)
+	if err != nil {
+		return nil, err
+	}
+
+	d := decoder{buf: response}
+	failed := make(map[int32]error)
+	for n := d.arrayLen(); n > 0 && d.err == nil; n-- {
+		d.string() // topic
+		for p := d.arrayLen(); p > 0 && d.err == nil; p-- {
+			partition := d.int32()
+			code := d.int16()
+			d.int64() // base offset
+			d.int64() // log append time
+			if code != 0 {
+				failed[partition] = kafkaError(code)
+			}
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("invalid produce response: %w", d.err)
+	}
+	return failed, nil
+}
+
+// recordBatch encodes records as an uncompressed v2 record batch
+func recordBatch(records []record) []byte {
+	first, last := records[0].timestamp, records[0].timestamp
+	for _, r := range records {
+		first, last = min(first, r.timestamp), max(last, r.timestamp)
+	}
+
+	// The CRC covers everything from the attributes on
+	body := binary.BigEndian.AppendUint16(nil, 0) // attributes: no compression
+	body = binary.BigEndian.AppendUint32(body, uint32(len(records)-1))
+	body = binary.BigEndian.AppendUint64(body, uint64(first))
+	body = binary.BigEndian.AppendUint64(body, uint64(last))
+	body = binary.BigEndian.AppendUint64(body, 0xffffffffffffffff) // producer ID: none
+	body = binary.BigEndian.AppendUint16(body, 0xffff)             // producer epoch
+	body = binary.BigEndian.AppendUint32(body, 0xffffffff)         // base sequence
+	body = binary.BigEndian.AppendUint32(body, uint32(len(records)))
+	for i, r := range records {
+		var rec []byte
+		rec = append(rec, 0) // attributes
+		rec = binary.AppendVarint(rec, r.timestamp-first)
+		rec = binary.AppendVarint(rec, int64(i))
+		rec = binary.AppendVarint(rec, int64(len(r.key)))
+		rec = append(rec, r.key...)
+		rec = binary.AppendVarint(rec, int64(len(r.value)))
+		rec = append(rec, r.value...)
+		rec = binary.AppendVarint(rec, 0) // headers
+		body = binary.AppendVarint(body, int64(len(rec)))
+		body = append(body, rec...)
+	}
+
+	batch := binary.BigEndian.AppendUint64(nil, 0) // base offset, assigned by the broker
+	batch = binary.BigEndian.AppendUint32(batch, uint32(4+1+4+len(body)))
+	batch = binary.BigEndian.AppendUint32(batch, 0xffffffff) // partition leader epoch
+	batch = append(batch, 2)                                 // magic
+	batch = binary.BigEndian.AppendUint32(batch, crc32.Checksum(body, crc32c))
+	return append(batch, body...)
+}
+
+// partitionFor returns the partition of a key the way the Java client's default partitioner
+// does, so the messages of a cluster land on the same partition as with other producers
+func partitionFor(key []byte, partitions int) int32 {
+	return int32(int(murmur2(key)&0x7fffffff) % partitions)
+}
+
+// murmur2 is the hash of the Java client's default partitioner
+func murmur2(data []byte) uint32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	length := len(data)
+	h := uint32(seed) ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(b)))
+	return append(buf, b...)
+}
+
+var errShortResponse = errors.New("response too short")
+
+// decoder reads the fields of a response; the first error sticks and zero values are
+// returned after it
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.err = errShortResponse
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	return string(d.take(int(d.int16())))
+}
+
+func (d *decoder) nullableString() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+func (d *decoder) arrayLen() int {
+	return int(d.int32())
+}
+
+func (d *decoder) skipInt32Array() {
+	if n := d.arrayLen(); n > 0 {
+		d.take(4 * n)
+	}
+}
@@ -0,0 +1,346 @@
+package kafka
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Frames are written out field by field from the protocol guide
+// (https://kafka.apache.org/protocol) so that the encoder is checked against the wire format
+// rather than against itself.
+
+// testBatch is two records 1.5s apart, keyed by cluster
+var testBatch = []record{
+	{key: []byte("es-prod-01"), value: []byte(`{"a":1}`), timestamp: 1704067200000},
+	{key: []byte("es-prod-02"), value: []byte(`{"a":2}`), timestamp: 1704067201500},
+}
+
+// testBatchFrame is testBatch as a v2 record batch; the CRC32C was computed independently
+const testBatchFrame = "" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00" + // base offset
+	"\x00\x00\x00\x62" + // batch length: 98
+	"\xff\xff\xff\xff" + // partition leader epoch
+	"\x02" + // magic
+	"\xd8\xa6\x69\x25" + // CRC32C of the attributes on
+	"\x00\x00" + // attributes
+	"\x00\x00\x00\x01" + // last offset delta
+	"\x00\x00\x01\x8c\xc2\x51\xf4\x00" + // first timestamp
+	"\x00\x00\x01\x8c\xc2\x51\xf9\xdc" + // max timestamp
+	"\xff\xff\xff\xff\xff\xff\xff\xff" + // producer ID
+	"\xff\xff" + // producer epoch
+	"\xff\xff\xff\xff" + // base sequence
+	"\x00\x00\x00\x02" + // records
+	"\x2e\x00\x00\x00\x14es-prod-01\x0e{\"a\":1}\x00" + // length 23, attributes, timestamp delta 0, offset delta 0, key, value, headers
+	"\x30\x00\xb8\x17\x02\x14es-prod-02\x0e{\"a\":2}\x00" // length 24, attributes, timestamp delta 1500, offset delta 1, key, value, headers
+
+func TestMurmur2(t *testing.T) {
+	// The vectors of the Java client's Utils.murmur2 test
+	tests := []struct {
+		key  string
+		want int32
+	}{
+		{"21", -973932308},
+		{"foobar", -790332482},
+		{"a-little-bit-long-string", -985981536},
+		{"a-little-bit-longer-string", -1486304829},
+		{"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", -58897971},
+		{"abc", 479470107},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := int32(murmur2([]byte(tt.key))); got != tt.want {
+				t.Errorf("murmur2(%q) = %d, want %d", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionFor(t *testing.T) {
+	// The Java client's default partitioner: toPositive(murmur2(key)) % partitions
+	tests := []struct {
+		key        string
+		partitions int
+		want       int32
+	}{
+		{"21", 3, 0},     // 1173551340 % 3
+		{"foobar", 4, 2}, // 1357151166 % 4
+		{"abc", 10, 7},   // 479470107 % 10
+		{"abc", 1, 0},
+	}
+	for _, tt := range tests {
+		if got := partitionFor([]byte(tt.key), tt.partitions); got != tt.want {
+			t.Errorf("partitionFor(%q, %d) = %d, want %d", tt.key, tt.partitions, got, tt.want)
+		}
+	}
+}
+
+func TestCRC32C(t *testing.T) {
+	// The check value of CRC-32C (Castagnoli)
+	if got := crc32.Checksum([]byte("123456789"), crc32c); got != 0xe3069283 {
+		t.Errorf("CRC32C = %#x, want 0xe3069283", got)
+	}
+}
+
+func TestRecordBatch(t *testing.T) {
+	got := recordBatch(testBatch)
+	if !bytes.Equal(got, []byte(testBatchFrame)) {
+		t.Errorf("recordBatch =\n%x\nwant\n%x", got, testBatchFrame)
+	}
+}
+
+// exchange is a request the fake broker expects and the response it sends back
+type exchange struct {
+	request  string
+	response string
+}
+
+// fakeBroker returns a connection to a broker that expects the requests of exchanges in order
+// and answers each with its response
+func fakeBroker(t *testing.T, exchanges ...exchange) *brokerConn {
+	t.Helper()
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer server.Close()
+		for i, ex := range exchanges {
+			request := make([]byte, len(ex.request))
+			if _, err := io.ReadFull(server, request); err != nil {
+				t.Errorf("request %d: %v", i+1, err)
+				return
+			}
+			if !bytes.Equal(request, []byte(ex.request)) {
+				t.Errorf("request %d =\n%x\nwant\n%x", i+1, request, ex.request)
+				return
+			}
+			if _, err := server.Write([]byte(ex.response)); err != nil {
+				t.Errorf("response %d: %v", i+1, err)
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		client.Close()
+		<-done
+	})
+	return &brokerConn{conn: client, reader: bufio.NewReader(client), clientID: "eo", timeout: 5 * time.Second}
+}
+
+func TestSaslPlain(t *testing.T) {
+	handshake := exchange{
+		request: "\x00\x00\x00\x13" + // size: 19
+			"\x00\x11\x00\x01" + // SaslHandshake v1
+			"\x00\x00\x00\x01" + // correlation ID
+			"\x00\x02eo" + // client ID
+			"\x00\x05PLAIN", // mechanism
+		response: "\x00\x00\x00\x11" + // size: 17
+			"\x00\x00\x00\x01" + // correlation ID
+			"\x00\x00" + // error code
+			"\x00\x00\x00\x01\x00\x05PLAIN", // enabled mechanisms
+	}
+	authenticate := "\x00\x00\x00\x1c" + // size: 28
+		"\x00\x24\x00\x00" + // SaslAuthenticate v0
+		"\x00\x00\x00\x02" + // correlation ID
+		"\x00\x02eo" + // client ID
+		"\x00\x00\x00\x0c\x00user\x00secret" // auth bytes
+
+	t.Run("accepted", func(t *testing.T) {
+		b := fakeBroker(t, handshake, exchange{
+			request: authenticate,
+			response: "\x00\x00\x00\x0c" + // size: 12
+				"\x00\x00\x00\x02" + // correlation ID
+				"\x00\x00" + // error code
+				"\xff\xff" + // error message: null
+				"\x00\x00\x00\x00", // auth bytes
+		})
+		if err := b.saslPlain("user", "secret"); err != nil {
+			t.Fatalf("saslPlain: %v", err)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		b := fakeBroker(t, handshake, exchange{
+			request: authenticate,
+			response: "\x00\x00\x00\x20" + // size: 32
+				"\x00\x00\x00\x02" + // correlation ID
+				"\x00\x3a" + // error code 58
+				"\x00\x14Invalid credentials." + // error message
+				"\x00\x00\x00\x00", // auth bytes
+		})
+		err := b.saslPlain("user", "secret")
+		if err == nil || err.Error() != "SASL authentication failed: Invalid credentials." {
+			t.Fatalf("saslPlain = %v, want the broker's message", err)
+		}
+	})
+
+	t.Run("mechanism disabled", func(t *testing.T) {
+		b := fakeBroker(t, exchange{
+			request: handshake.request,
+			response: "\x00\x00\x00\x11" + // size: 17
+				"\x00\x00\x00\x01" + // correlation ID
+				"\x00\x21" + // error code 33
+				"\x00\x00\x00\x01\x00\x05SCRAM", // enabled mechanisms
+		})
+		err := b.saslPlain("user", "secret")
+		if !errors.Is(err, kafkaError(33)) {
+			t.Fatalf("saslPlain = %v, want unsupported SASL mechanism", err)
+		}
+	})
+}
+
+// metadataRequest asks for the metadata of eo-events
+const metadataRequest = "\x00\x00\x00\x1b" + // size: 27
+	"\x00\x03\x00\x01" + // Metadata v1
+	"\x00\x00\x00\x01" + // correlation ID
+	"\x00\x02eo" + // client ID
+	"\x00\x00\x00\x01\x00\x09eo-events" // topics
+
+func TestMetadata(t *testing.T) {
+	b := fakeBroker(t, exchange{
+		request: metadataRequest,
+		response: "\x00\x00\x00\xa0" + // size: 160
+			"\x00\x00\x00\x01" + // correlation ID
+			"\x00\x00\x00\x02" + // brokers
+			"\x00\x00\x00\x01\x00\x07kafka-1\x00\x00\x23\x84\xff\xff" + // node 1, port 9092, no rack
+			"\x00\x00\x00\x02\x00\x07kafka-2\x00\x00\x23\x84\x00\x06rack-b" + // node 2, port 9092, rack-b
+			"\x00\x00\x00\x01" + // controller ID
+			"\x00\x00\x00\x01" + // topics
+			"\x00\x00\x00\x09eo-events\x00" + // error code, name, not internal
+			"\x00\x00\x00\x03" + // partitions
+			"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02" + // partition 0, leader 2
+			"\x00\x00\x00\x02\x00\x00\x00\x02\x00\x00\x00\x01" + // replicas
+			"\x00\x00\x00\x02\x00\x00\x00\x02\x00\x00\x00\x01" + // in-sync replicas
+			"\x00\x00\x00\x00\x00\x01\x00\x00\x00\x01" + // partition 1, leader 1
+			"\x00\x00\x00\x02\x00\x00\x00\x01\x00\x00\x00\x02" + // replicas
+			"\x00\x00\x00\x01\x00\x00\x00\x01" + // in-sync replicas
+			"\x00\x05\x00\x00\x00\x02\xff\xff\xff\xff" + // partition 2: leader not available
+			"\x00\x00\x00\x00" + // replicas
+			"\x00\x00\x00\x00", // in-sync replicas
+	})
+
+	meta, err := b.metadata("eo-events")
+	if err != nil {
+		t.Fatalf("metadata: %v", err)
+	}
+	wantBrokers := map[int32]string{1: "kafka-1:9092", 2: "kafka-2:9092"}
+	if !reflect.DeepEqual(meta.brokers, wantBrokers) {
+		t.Errorf("brokers = %v, want %v", meta.brokers, wantBrokers)
+	}
+	if want := []int32{2, 1, -1}; !reflect.DeepEqual(meta.leaders, want) {
+		t.Errorf("leaders = %v, want %v", meta.leaders, want)
+	}
+}
+
+func TestMetadataErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{
+			name: "unknown topic",
+			response: "\x00\x00\x00\x22" + // size: 34
+				"\x00\x00\x00\x01" + // correlation ID
+				"\x00\x00\x00\x00" + // brokers
+				"\x00\x00\x00\x01" + // controller ID
+				"\x00\x00\x00\x01" + // topics
+				"\x00\x03\x00\x09eo-events\x00" + // error code 3, name, not internal
+				"\x00\x00\x00\x00", // partitions
+			want: "topic eo-events: unknown topic or partition",
+		},
+		{
+			name: "truncated",
+			response: "\x00\x00\x00\x0a" + // size: 10
+				"\x00\x00\x00\x01" + // correlation ID
+				"\x00\x00\x00\x01" + // brokers
+				"\x00\x00", // half a node ID
+			want: "invalid metadata response: response too short",
+		},
+		{
+			name: "other correlation ID",
+			response: "\x00\x00\x00\x08" + // size: 8
+				"\x00\x00\x00\x07" + // correlation ID
+				"\x00\x00\x00\x00",
+			want: "response to request 7 instead of 1",
+		},
+		{
+			name: "oversized",
+			response: "\x10\x00\x00\x00" + // size: 256 MiB
+				"\x00\x00\x00\x01", // correlation ID
+			want: "invalid response size 268435456",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := fakeBroker(t, exchange{request: metadataRequest, response: tt.response})
+			_, err := b.metadata("eo-events")
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("metadata = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProduce(t *testing.T) {
+	request := "\x00\x00\x00\x9d" + // size: 157
+		"\x00\x00\x00\x03" + // Produce v3
+		"\x00\x00\x00\x01" + // correlation ID
+		"\x00\x02eo" + // client ID
+		"\xff\xff" + // transactional ID: null
+		"\xff\xff" + // acks: all
+		"\x00\x00\x27\x10" + // timeout: 10000 ms
+		"\x00\x00\x00\x01\x00\x09eo-events" + // topics
+		"\x00\x00\x00\x01" + // partitions
+		"\x00\x00\x00\x02" + // partition 2
+		"\x00\x00\x00\x6e" + testBatchFrame // records: 110 bytes
+
+	tests := []struct {
+		name   string
+		code   string
+		failed map[int32]error
+	}{
+		{"written", "\x00\x00", map[int32]error{}},
+		{"not leader", "\x00\x06", map[int32]error{2: kafkaError(6)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := fakeBroker(t, exchange{
+				request: request,
+				response: "\x00\x00\x00\x31" + // size: 49
+					"\x00\x00\x00\x01" + // correlation ID
+					"\x00\x00\x00\x01\x00\x09eo-events" + // topics
+					"\x00\x00\x00\x01" + // partitions
+					"\x00\x00\x00\x02" + tt.code + // partition 2, error code
+					"\x00\x00\x00\x00\x00\x00\x00\x2a" + // base offset
+					"\xff\xff\xff\xff\xff\xff\xff\xff" + // log append time
+					"\x00\x00\x00\x00", // throttle time
+			})
+			failed, err := b.produce("eo-events", -1, 10*time.Second, map[int32][]record{2: testBatch})
+			if err != nil {
+				t.Fatalf("produce: %v", err)
+			}
+			if !reflect.DeepEqual(failed, tt.failed) {
+				t.Errorf("failed = %v, want %v", failed, tt.failed)
+			}
+		})
+	}
+}
+
+func TestKafkaErrorRetriable(t *testing.T) {
+	for code, want := range map[int16]bool{3: true, 5: true, 6: true, 7: true, 10: false, 29: false, 58: false} {
+		if got := kafkaError(code).retriable(); got != want {
+			t.Errorf("kafkaError(%d).retriable() = %v, want %v", code, got, want)
+		}
+	}
+	if got := kafkaError(87).Error(); !strings.Contains(got, "87") {
+		t.Errorf("kafkaError(87) = %q, want the code", got)
+	}
+}
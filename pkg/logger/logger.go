@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -19,10 +20,11 @@ const (
 )
 
 var (
-	appLogger  *Logger
-	jobLogger  *Logger
-	logLevel   LogLevel
-	logLevelMu sync.RWMutex
+	appLogger    *Logger
+	jobLogger    *Logger
+	accessLogger *Logger
+	logLevel     LogLevel
+	logLevelMu   sync.RWMutex
 )
 
 // Logger represents a logger instance
@@ -71,6 +73,18 @@ func Init(level string, appLogPath, jobLogPath string) error {
 	return nil
 }
 
+// InitAccessLog opens the access log of the API: one JSON object per request and line
+func InitAccessLog(accessLogPath string) error {
+	accessFile, err := os.OpenFile(accessLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open access log file: %w", err)
+	}
+	accessLogger = &Logger{
+		logger: log.New(accessFile, "", 0),
+	}
+	return nil
+}
+
 // formatLog formats a log message with timestamp and level
 func formatLog(level string, message string) string {
 	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
@@ -163,3 +177,18 @@ func JobError(jobName, format string, v ...interface{}) {
 		jobLogger.mu.Unlock()
 	}
 }
+
+// Access writes an entry to the access log, if it is open. Entries are logged at every log
+// level.
+func Access(entry interface{}) {
+	if accessLogger == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	accessLogger.mu.Lock()
+	accessLogger.logger.Println(string(line))
+	accessLogger.mu.Unlock()
+}
@@ -0,0 +1,117 @@
+package maintenance
+
+import (
+	"fmt"
+	"sort"
+
+	"ElasticObservability/pkg/utils"
+)
+
+// Decommission is a node being decommissioned: its events are suppressed until the
+// decommissionNodes job finds its shards gone and removes it from the inventory. Like
+// silences, decommissions are kept in memory.
+type Decommission struct {
+	Cluster   string `json:"cluster"`
+	Host      string `json:"host"`
+	Reason    string `json:"reason,omitempty"`
+	Since     int64  `json:"since"`               // epoch milliseconds (UTC)
+	Shards    int    `json:"shards"`              // shards on the node at the latest check, -1 = not checked yet
+	CheckedAt int64  `json:"checkedAt,omitempty"` // epoch milliseconds (UTC) of the latest check
+	Error     string `json:"error,omitempty"`     // why the latest check failed
+}
+
+// decommissions are the nodes being decommissioned; guarded by mu
+var decommissions = make(map[[2]string]*Decommission) // key: cluster, host
+
+// StartDecommission marks a node as decommissioning. It returns the existing decommission and
+// false if the node is decommissioning already.
+func StartDecommission(clusterName, hostName, reason string) (*Decommission, bool, error) {
+	if clusterName == "" || hostName == "" {
+		return nil, false, fmt.Errorf("cluster and host are required")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	k := [2]string{clusterName, hostName}
+	if d, ok := decommissions[k]; ok {
+		c := *d
+		return &c, false, nil
+	}
+	d := &Decommission{
+		Cluster: clusterName,
+		Host:    hostName,
+		Reason:  reason,
+		Since:   utils.TimeNowMillis(),
+		Shards:  -1,
+	}
+	decommissions[k] = d
+	c := *d
+	return &c, true, nil
+}
+
+// EndDecommission stops decommissioning a node, cancelled or done; it reports whether the
+// node was decommissioning
+func EndDecommission(clusterName, hostName string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k := [2]string{clusterName, hostName}
+	if _, ok := decommissions[k]; !ok {
+		return false
+	}
+	delete(decommissions, k)
+	return true
+}
+
+// RecordDecommissionCheck records the shards left on a decommissioning node, or why they could
+// not be counted (err)
+func RecordDecommissionCheck(clusterName, hostName string, shards int, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	d, ok := decommissions[[2]string{clusterName, hostName}]
+	if !ok {
+		return
+	}
+	d.CheckedAt = utils.TimeNowMillis()
+	d.Error = ""
+	if err != nil {
+		d.Error = err.Error()
+		return
+	}
+	d.Shards = shards
+}
+
+// Decommissioning reports whether a node is being decommissioned, and why
+func Decommissioning(clusterName, hostName string) (string, bool) {
+	if hostName == "" {
+		return "", false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+
+	d, ok := decommissions[[2]string{clusterName, hostName}]
+	if !ok {
+		return "", false
+	}
+	return reasonOr(d.Reason, "node decommissioning"), true
+}
+
+// Decommissions returns copies of the decommissions, sorted by cluster and host
+func Decommissions() []Decommission {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]Decommission, 0, len(decommissions))
+	for _, d := range decommissions {
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cluster != result[j].Cluster {
+			return result[i].Cluster < result[j].Cluster
+		}
+		return result[i].Host < result[j].Host
+	})
+	return result
+}
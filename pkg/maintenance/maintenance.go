@@ -0,0 +1,212 @@
+// Package maintenance decides whether a cluster is in a maintenance window. Collection
+// continues during maintenance; alerts and write pressure events are suppressed or tagged.
+//
+// Windows come from the maintenanceWindows section of config.yaml (recurring cron windows or
+// absolute ranges). Ad-hoc silences are added at runtime through the API and kept in memory,
+// as are the nodes being decommissioned, whose events are suppressed the same way.
+package maintenance
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/utils"
+)
+
+// allClusters in a cluster list matches every cluster
+const allClusters = "*"
+
+// cronParser accepts the same expressions as job schedules (seconds field first)
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// window is a parsed maintenance window from the configuration
+type window struct {
+	clusters []string
+	schedule cron.Schedule // recurring window start, nil for absolute windows
+	length   time.Duration
+	start    time.Time // absolute windows
+	end      time.Time
+	reason   string
+}
+
+// Silence is an ad-hoc maintenance period created through the API
+type Silence struct {
+	ID       string   `json:"id"`
+	Clusters []string `json:"clusters"` // "*" = all clusters
+	Start    int64    `json:"start"`    // epoch milliseconds (UTC)
+	End      int64    `json:"end"`      // epoch milliseconds (UTC)
+	Reason   string   `json:"reason,omitempty"`
+}
+
+var (
+	mu       sync.RWMutex
+	windows  []window
+	silences = make(map[string]*Silence)
+	nextID   int
+)
+
+// Configure parses the configured maintenance windows and replaces the current ones
+func Configure(configured []config.MaintenanceWindow) error {
+	parsed := make([]window, 0, len(configured))
+	for i, cfg := range configured {
+		w, err := parseWindow(cfg)
+		if err != nil {
+			return fmt.Errorf("maintenanceWindows[%d]: %w", i, err)
+		}
+		parsed = append(parsed, w)
+	}
+
+	mu.Lock()
+	windows = parsed
+	mu.Unlock()
+	return nil
+}
+
+func parseWindow(cfg config.MaintenanceWindow) (window, error) {
+	w := window{clusters: cfg.Clusters, reason: cfg.Reason}
+	if len(cfg.Clusters) == 0 {
+		return w, fmt.Errorf("clusters is required (use \"*\" for all clusters)")
+	}
+
+	switch {
+	case cfg.Cron != "":
+		if cfg.Start != "" || cfg.End != "" {
+			return w, fmt.Errorf("use either cron/duration or start/end")
+		}
+		schedule, err := cronParser.Parse(cfg.Cron)
+		if err != nil {
+			return w, fmt.Errorf("invalid cron %q: %w", cfg.Cron, err)
+		}
+		length, err := utils.ParseDuration(cfg.Duration)
+		if err != nil || length <= 0 {
+			return w, fmt.Errorf("cron windows need a positive duration, got %q", cfg.Duration)
+		}
+		w.schedule = schedule
+		w.length = length
+	case cfg.Start != "" && cfg.End != "":
+		start, err := time.Parse(time.RFC3339, cfg.Start)
+		if err != nil {
+			return w, fmt.Errorf("invalid start: %w", err)
+		}
+		end, err := time.Parse(time.RFC3339, cfg.End)
+		if err != nil {
+			return w, fmt.Errorf("invalid end: %w", err)
+		}
+		if !end.After(start) {
+			return w, fmt.Errorf("end must be after start")
+		}
+		w.start, w.end = start, end
+	default:
+		return w, fmt.Errorf("either cron and duration or start and end are required")
+	}
+	return w, nil
+}
+
+// active reports whether the window covers t
+func (w window) active(t time.Time) bool {
+	if w.schedule == nil {
+		return !t.Before(w.start) && t.Before(w.end)
+	}
+	// The window is open if it started within the last length
+	start := w.schedule.Next(t.Add(-w.length))
+	return !start.After(t)
+}
+
+func matches(clusters []string, clusterName string) bool {
+	return utils.Contains(clusters, allClusters) || utils.Contains(clusters, clusterName)
+}
+
+// InMaintenance reports whether a cluster is in a maintenance window or silenced at t, and why
+func InMaintenance(clusterName string, t time.Time) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, w := range windows {
+		if matches(w.clusters, clusterName) && w.active(t) {
+			return reasonOr(w.reason, "maintenance window"), true
+		}
+	}
+	ms := t.UnixMilli()
+	for _, s := range silences {
+		if matches(s.Clusters, clusterName) && s.Start <= ms && ms < s.End {
+			return reasonOr(s.Reason, "silence "+s.ID), true
+		}
+	}
+	return "", false
+}
+
+func reasonOr(reason, fallback string) string {
+	if reason != "" {
+		return reason
+	}
+	return fallback
+}
+
+// AddSilence silences clusters from now for the given duration and returns the silence
+func AddSilence(clusters []string, duration time.Duration, reason string) (*Silence, error) {
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("at least one cluster (or \"*\") is required")
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	pruneExpired()
+	nextID++
+	now := utils.TimeNowMillis()
+	s := &Silence{
+		ID:       strconv.Itoa(nextID),
+		Clusters: append([]string(nil), clusters...),
+		Start:    now,
+		End:      now + duration.Milliseconds(),
+		Reason:   reason,
+	}
+	silences[s.ID] = s
+	c := *s
+	return &c, nil
+}
+
+// RemoveSilence ends a silence early; it reports whether the silence existed
+func RemoveSilence(id string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := silences[id]; !ok {
+		return false
+	}
+	delete(silences, id)
+	return true
+}
+
+// Silences returns copies of the silences that have not expired, sorted by end time
+func Silences() []Silence {
+	mu.Lock()
+	defer mu.Unlock()
+
+	pruneExpired()
+	result := make([]Silence, 0, len(silences))
+	for _, s := range silences {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].End < result[j].End })
+	return result
+}
+
+// pruneExpired drops expired silences; callers hold mu
+func pruneExpired() {
+	now := utils.TimeNowMillis()
+	for id, s := range silences {
+		if s.End <= now {
+			delete(silences, id)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// Subsystems whose memory is accounted. Only the snapshot histories can be evicted;
+// the other structures have a size fixed by configuration and are reported only.
+const (
+	SubsystemIndicesHistory   = "indicesHistory"
+	SubsystemBulkTasksHistory = "bulkTasksHistory"
+	SubsystemTPWQueue         = "tpwQueue"
+	SubsystemStatsByDay       = "statsByDay"
+	SubsystemIndexingRate     = "indexingRate"
+)
+
+// Subsystems lists all accounted subsystems in reporting order
+var Subsystems = []string{
+	SubsystemIndicesHistory,
+	SubsystemBulkTasksHistory,
+	SubsystemTPWQueue,
+	SubsystemStatsByDay,
+	SubsystemIndexingRate,
+}
+
+// Rough per-object overheads used by the estimates (64-bit runtime)
+const (
+	pointerSize      = 8
+	stringHeader     = 16
+	sliceHeader      = 24
+	mapEntryOverhead = 48
+	mapHeader        = 48
+)
+
+// Usage describes the estimated memory held by one subsystem
+type Usage struct {
+	Subsystem string `json:"subsystem"`
+	Bytes     int64  `json:"bytes"`
+	Budget    int64  `json:"budget"` // 0 = unlimited
+	Items     int    `json:"items"`  // snapshots (histories) or entries
+	Evictable bool   `json:"evictable"`
+}
+
+// snapshotRef identifies one evictable snapshot
+type snapshotRef struct {
+	clusterName  string
+	snapShotTime int64
+	bytes        int64
+}
+
+// Budgets returns the configured budget in bytes per subsystem
+func Budgets() (map[string]int64, error) {
+	budgets := make(map[string]int64)
+	if config.Global == nil {
+		return budgets, nil
+	}
+
+	for subsystem, sizeStr := range config.Global.MemoryBudgets {
+		if !isSubsystem(subsystem) {
+			return nil, fmt.Errorf("unknown memory subsystem %q (valid: %s)", subsystem, strings.Join(Subsystems, ", "))
+		}
+		size, err := utils.ParseStorageSize(sizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory budget for %s: %w", subsystem, err)
+		}
+		budgets[subsystem] = int64(size)
+	}
+	return budgets, nil
+}
+
+func isSubsystem(name string) bool {
+	for _, s := range Subsystems {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Collect estimates the memory held by every subsystem and updates the gauges
+func Collect() ([]Usage, error) {
+	budgets, err := Budgets()
+	if err != nil {
+		return nil, err
+	}
+
+	indicesRefs := indicesHistorySnapshots()
+	bulkRefs := bulkTasksSnapshots()
+	tpwBytes, tpwItems := tpwQueueBytes()
+	statsBytes, statsItems := statsByDayBytes()
+	rateBytes, rateItems := indexingRateBytes()
+
+	usages := []Usage{
+		{Subsystem: SubsystemIndicesHistory, Bytes: sumBytes(indicesRefs), Items: len(indicesRefs), Evictable: true},
+		{Subsystem: SubsystemBulkTasksHistory, Bytes: sumBytes(bulkRefs), Items: len(bulkRefs), Evictable: true},
+		{Subsystem: SubsystemTPWQueue, Bytes: tpwBytes, Items: tpwItems},
+		{Subsystem: SubsystemStatsByDay, Bytes: statsBytes, Items: statsItems},
+		{Subsystem: SubsystemIndexingRate, Bytes: rateBytes, Items: rateItems},
+	}
+
+	for i := range usages {
+		usages[i].Budget = budgets[usages[i].Subsystem]
+		metrics.MemoryBytes.WithLabelValues(usages[i].Subsystem).Set(float64(usages[i].Bytes))
+		metrics.MemoryBudgetBytes.WithLabelValues(usages[i].Subsystem).Set(float64(usages[i].Budget))
+		metrics.MemoryItems.WithLabelValues(usages[i].Subsystem).Set(float64(usages[i].Items))
+	}
+	return usages, nil
+}
+
+// Enforce evicts the oldest snapshots of each evictable subsystem that is over its budget
+// and returns the number of evicted snapshots per subsystem. The latest snapshot of a
+// cluster is never evicted, so a subsystem can stay over budget.
+func Enforce() (map[string]int, error) {
+	budgets, err := Budgets()
+	if err != nil {
+		return nil, err
+	}
+
+	evicted := make(map[string]int)
+
+	if budget := budgets[SubsystemIndicesHistory]; budget > 0 {
+		evicted[SubsystemIndicesHistory] = evictOldest(indicesHistorySnapshots(), budget, func(ref snapshotRef) bool {
+			history, exists := types.GetHistory(ref.clusterName)
+			return exists && history.EvictSnapshot(ref.snapShotTime)
+		})
+	}
+
+	if budget := budgets[SubsystemBulkTasksHistory]; budget > 0 {
+		evicted[SubsystemBulkTasksHistory] = evictOldest(bulkTasksSnapshots(), budget, func(ref snapshotRef) bool {
+			return types.EvictBulkTasksSnapshot(ref.clusterName, ref.snapShotTime)
+		})
+	}
+
+	for subsystem, count := range evicted {
+		metrics.MemoryEvictionsTotal.WithLabelValues(subsystem).Add(float64(count))
+	}
+	return evicted, nil
+}
+
+// evictOldest evicts snapshots oldest first (across all clusters) until the total fits the budget
+func evictOldest(refs []snapshotRef, budget int64, evict func(ref snapshotRef) bool) int {
+	total := sumBytes(refs)
+	if total <= budget {
+		return 0
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].snapShotTime < refs[j].snapShotTime
+	})
+
+	// The latest snapshot of each cluster is kept
+	latest := make(map[string]int64)
+	for _, ref := range refs {
+		if ref.snapShotTime > latest[ref.clusterName] {
+			latest[ref.clusterName] = ref.snapShotTime
+		}
+	}
+
+	count := 0
+	for _, ref := range refs {
+		if total <= budget {
+			break
+		}
+		if ref.snapShotTime == latest[ref.clusterName] {
+			continue
+		}
+		if evict(ref) {
+			total -= ref.bytes
+			count++
+		}
+	}
+	return count
+}
+
+func sumBytes(refs []snapshotRef) int64 {
+	var total int64
+	for _, ref := range refs {
+		total += ref.bytes
+	}
+	return total
+}
+
+// indicesHistorySnapshots lists the snapshots held in the indices histories
+func indicesHistorySnapshots() []snapshotRef {
+	refs := make([]snapshotRef, 0)
+	for clusterName, history := range types.SnapshotHistories() {
+		for _, snapshot := range history.Snapshots.OldestFirst() {
+			if snapshot == nil {
+				continue
+			}
+			refs = append(refs, snapshotRef{
+				clusterName:  clusterName,
+				snapShotTime: snapshot.SnapShotTime,
+				bytes:        indicesSnapshotBytes(snapshot),
+			})
+		}
+	}
+	return refs
+}
+
+func indicesSnapshotBytes(snapshot *types.IndicesSnapShot) int64 {
+	bytes := int64(pointerSize + 8 + mapHeader)
+	for _, indices := range []map[string]*types.IndexInfo{snapshot.MapIndices, snapshot.Previous} {
+		for key, info := range indices {
+			bytes += mapEntryOverhead + stringHeader + int64(len(key)) + pointerSize
+			if info != nil {
+				// 8 numeric fields plus two strings
+				bytes += 8*8 + 2*stringHeader + int64(len(info.Index)+len(info.IndexBase))
+			}
+		}
+	}
+	return bytes
+}
+
+// bulkTasksSnapshots lists the snapshots held in the bulk task histories
+func bulkTasksSnapshots() []snapshotRef {
+	refs := make([]snapshotRef, 0)
+	for clusterName, history := range types.BulkTasksHistories() {
+		for _, snapshot := range history.PtrClusterDataWriteBulk_sTasks.NewestFirst() {
+			if snapshot == nil {
+				continue
+			}
+			refs = append(refs, snapshotRef{
+				clusterName:  clusterName,
+				snapShotTime: snapshot.SnapShotTime,
+				bytes:        bulkTasksSnapshotBytes(snapshot),
+			})
+		}
+	}
+	return refs
+}
+
+func bulkTasksSnapshotBytes(snapshot *types.ClusterDataWriteBulk_sTasks) int64 {
+	bytes := int64(pointerSize+8) + 2*mapHeader + 6*sliceHeader
+	bytes += stringSliceBytes(snapshot.SortedHostsOnTasks) * 3
+	bytes += stringSliceBytes(snapshot.IndicesSortedonTasks) * 3
+	bytes += aggShardMapBytes(snapshot.DataWriteBulk_sTasksByIndex)
+
+	for hostName, node := range snapshot.DataWriteBulk_sTasksByNode {
+		bytes += mapEntryOverhead + stringHeader + int64(len(hostName)) + pointerSize
+		if node == nil {
+			continue
+		}
+		bytes += 3*8 + stringHeader + int64(len(node.Zone)) + mapHeader + 3*sliceHeader
+		bytes += stringSliceBytes(node.SortedShardsOnTasks) * 3
+		bytes += aggShardMapBytes(node.DataWriteBulk_sByShard)
+	}
+
+	// TaskIDs are left out, they are only kept on the newest snapshots
+	if delta := snapshot.Delta; delta != nil {
+		bytes += 3*8 + 2*mapHeader + bulkTaskCountsMapBytes(delta.ByNode) + bulkTaskCountsMapBytes(delta.ByIndex)
+	}
+	return bytes
+}
+
+func bulkTaskCountsMapBytes(m map[string]*types.BulkTaskCounts) int64 {
+	var bytes int64
+	for key := range m {
+		bytes += mapEntryOverhead + stringHeader + int64(len(key)) + pointerSize + 2*8
+	}
+	return bytes
+}
+
+func aggShardMapBytes(m map[string]*types.AggShardTaskDataWriteBulk_s) int64 {
+	var bytes int64
+	for key := range m {
+		bytes += mapEntryOverhead + stringHeader + int64(len(key)) + pointerSize + 3*8
+	}
+	return bytes
+}
+
+// stringSliceBytes counts the slice headers only; the strings are shared with map keys
+func stringSliceBytes(s []string) int64 {
+	return int64(len(s)) * stringHeader
+}
+
+func tpwQueueBytes() (int64, int) {
+	var bytes int64
+	items := 0
+	for _, clusterName := range types.ClusterNames() {
+		queue, exists := types.GetTPWQueue(clusterName)
+		if !exists {
+			continue
+		}
+		bytes += mapHeader + sliceHeader + stringSliceBytes(queue.HostnameList)
+		for hostName, tpwq := range queue.HostTPWQueue {
+			if tpwq == nil {
+				continue
+			}
+			items++
+			// Each TPWPoint is 16 bytes (int64, uint32, bool plus padding)
+			bytes += mapEntryOverhead + stringHeader + int64(len(hostName)) + pointerSize + 8 + pointerSize + sliceHeader + 8
+			bytes += int64(tpwq.Points.Cap()) * 16
+		}
+	}
+	return bytes, items
+}
+
+func statsByDayBytes() (int64, int) {
+	var bytes int64
+	items := 0
+	for _, clusterName := range types.ClusterNames() {
+		stats, exists := types.GetStatsByDay(clusterName)
+		if !exists {
+			continue
+		}
+		bytes += 8 + mapHeader
+		for indexName, statHistory := range stats.StatHistory {
+			if statHistory == nil {
+				continue
+			}
+			items++
+			bytes += mapEntryOverhead + 2*(stringHeader+int64(len(indexName))) + pointerSize + 1 + sliceHeader
+			for _, stat := range statHistory.Stats.NewestFirst() {
+				bytes += pointerSize
+				if stat != nil {
+					bytes += 3 * 8
+				}
+			}
+		}
+	}
+	return bytes, items
+}
+
+// indexingRateBytes counts the rate computations kept in the rate histories, which include
+// the latest computation of each cluster
+func indexingRateBytes() (int64, int) {
+	var bytes int64
+	items := 0
+	for _, rates := range types.IndexingRateHistories() {
+		for _, rate := range rates {
+			bytes += pointerSize
+			if rate == nil {
+				continue
+			}
+			bytes += 8 + mapHeader
+			for indexBase := range rate.MapIndices {
+				items++
+				bytes += mapEntryOverhead + stringHeader + int64(len(indexBase)) + pointerSize + 4*8 + 1
+			}
+		}
+	}
+	return bytes, items
+}
@@ -0,0 +1,663 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace prefixes every metric exported by the application
+const Namespace = "elasticobservability"
+
+// Memory accounting metrics
+var (
+	MemoryBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "memory_bytes",
+		Help:      "Estimated bytes held by each in-memory data structure.",
+	}, []string{"subsystem"})
+
+	MemoryBudgetBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "memory_budget_bytes",
+		Help:      "Configured memory budget of each data structure (0 = unlimited).",
+	}, []string{"subsystem"})
+
+	MemoryItems = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "memory_items",
+		Help:      "Number of snapshots or entries held by each data structure.",
+	}, []string{"subsystem"})
+
+	MemoryEvictionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "memory_evictions_total",
+		Help:      "Snapshots evicted to keep a data structure within its budget.",
+	}, []string{"subsystem"})
+)
+
+// Monitoring cluster metrics
+var (
+	MonitoringEndpointHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "monitoring_endpoint_healthy",
+		Help:      "Whether the last query against a monitoring cluster endpoint succeeded (1) or it is in cooldown (0).",
+	}, []string{"endpoint"})
+)
+
+// Query cache metrics
+var (
+	QueryCacheHitsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "query_cache_hits_total",
+		Help:      "Cluster queries answered from the query cache.",
+	}, []string{"job"})
+
+	QueryCacheMissesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "query_cache_misses_total",
+		Help:      "Cacheable cluster queries that had to be sent to the cluster.",
+	}, []string{"job"})
+
+	QueryCacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "query_cache_entries",
+		Help:      "Responses held by the query cache.",
+	})
+
+	QueryCacheBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "query_cache_bytes",
+		Help:      "Size of the response bodies held by the query cache.",
+	})
+)
+
+// Collection status metrics, per job and cluster
+var (
+	CollectionLastSuccessSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "collection_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful collection of a job for a cluster.",
+	}, []string{"job", "cluster"})
+
+	CollectionConsecutiveFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "collection_consecutive_failures",
+		Help:      "Collections of a job for a cluster that failed in a row.",
+	}, []string{"job", "cluster"})
+
+	CollectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "collections_total",
+		Help:      "Collections of a job for a cluster by result (success or failure).",
+	}, []string{"job", "cluster", "result"})
+)
+
+// Notification metrics
+var (
+	NotificationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "notifications_total",
+		Help:      "Notifications sent by channel (email, slack, webhook, syslog, snmp) and result.",
+	}, []string{"channel", "result"})
+
+	NotificationsSuppressedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "notifications_suppressed_total",
+		Help:      "Cluster alerts not sent because the cluster was in a maintenance window.",
+	})
+)
+
+// Kafka publishing metrics
+var (
+	KafkaMessagesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "kafka_messages_total",
+		Help:      "Messages for the Kafka topic by type (event, jobFailure) and result (published, failed, dropped).",
+	}, []string{"type", "result"})
+)
+
+// Remediation metrics
+var (
+	RemediationRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "remediation_runs_total",
+		Help:      "Remediation runs by action and result (applied, dryRun, failed).",
+	}, []string{"action", "result"})
+
+	RemediationRollbacksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "remediation_rollbacks_total",
+		Help:      "Remediation rollbacks by action and result (success, failure).",
+	}, []string{"action", "result"})
+
+	RemediationsActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "remediations_active",
+		Help:      "Remediations applied and not yet rolled back, by action and cluster.",
+	}, []string{"action", "cluster"})
+)
+
+// Alert rule metrics
+var (
+	AlertsFiring = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "alerts_firing",
+		Help:      "Firing alerts per rule and severity, as of the last evaluateRules run.",
+	}, []string{"rule", "severity"})
+)
+
+// Event store metrics
+var (
+	EventsFiring = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "events_firing",
+		Help:      "Firing events in the event store per source.",
+	}, []string{"source"})
+
+	EventsStored = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "events_stored",
+		Help:      "Events (firing and resolved) kept in the event store.",
+	})
+
+	EventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "events_total",
+		Help:      "Event state changes per source and state (firing, resolved).",
+	}, []string{"source", "state"})
+)
+
+// Cluster rollup metrics, from the latest runCatIndices and analyseIngest runs
+var (
+	ClusterIndices = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "cluster_indices",
+		Help:      "Indices per cluster and health (green, yellow, red, unknown).",
+	}, []string{"cluster", "health"})
+
+	ClusterDocs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "cluster_docs",
+		Help:      "Documents over all indices of a cluster.",
+	}, []string{"cluster"})
+
+	ClusterStorageBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "cluster_storage_bytes",
+		Help:      "Storage over all indices of a cluster (kind: total or primary).",
+	}, []string{"cluster", "kind"})
+
+	ClusterIngestBytesPerSecond = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "cluster_ingest_bytes_per_second",
+		Help:      "Primary storage growth of a cluster per window (3m, 15m, 60m).",
+	}, []string{"cluster", "window"})
+)
+
+// Retention metrics
+var (
+	RetentionViolations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "retention_violations",
+		Help:      "Indices older than their retention per cluster, as of the last checkRetention run.",
+	}, []string{"cluster"})
+)
+
+// Write pressure metrics, for routing write pressure through Alertmanager
+var (
+	WritePressureActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "write_pressure_active",
+		Help:      "Whether a host was under write pressure (1) or not (0) in the last checkForWritePressure run.",
+	}, []string{"cluster", "host"})
+
+	WritePressureSeverity = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "write_pressure_severity",
+		Help:      "Write pressure severity of a host in the last checkForWritePressure run: 0 none, 1 warning, 2 critical.",
+	}, []string{"cluster", "host"})
+
+	WritePressureDataNodesPercent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "write_pressure_data_nodes_percent",
+		Help:      "Percentage of the data nodes of a cluster under write pressure in the last checkForWritePressure run.",
+	}, []string{"cluster"})
+
+	WritePressureEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "write_pressure_events_total",
+		Help:      "Write pressure events fired per host and severity, escalations to critical included.",
+	}, []string{"cluster", "host", "severity"})
+
+	ThreadPoolWriteQueue = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "thread_pool_write_queue",
+		Help:      "Latest collected thread pool write queue of a host.",
+	}, []string{"cluster", "host"})
+
+	ThreadPoolWriteQueueTimestampSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "thread_pool_write_queue_timestamp_seconds",
+		Help:      "Unix time of the latest collected thread pool write queue data point of a host.",
+	}, []string{"cluster", "host"})
+)
+
+// Node disk metrics, from the latest getNodeDiskUsage run
+var (
+	NodeDiskUsedPercent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "node_disk_used_percent",
+		Help:      "Used disk of a node in percent.",
+	}, []string{"cluster", "host"})
+
+	NodeDiskWatermarkBreached = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "node_disk_watermark_breached",
+		Help:      "Whether a node's disk is beyond a watermark (low, high, floodStage).",
+	}, []string{"cluster", "host", "level"})
+)
+
+// JVM metrics, from the latest getNodeJVMStats and checkForHeapPressure runs
+var (
+	NodeHeapUsedPercent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "node_heap_used_percent",
+		Help:      "Used JVM heap of a node in percent.",
+	}, []string{"cluster", "host"})
+
+	NodeGCCollections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "node_gc_collections",
+		Help:      "Garbage collections of a node since it started, per collector (young, old).",
+	}, []string{"cluster", "host", "collector"})
+
+	NodeGCTimeSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "node_gc_time_seconds",
+		Help:      "Time a node spent in garbage collection since it started, per collector (young, old).",
+	}, []string{"cluster", "host", "collector"})
+
+	HeapPressureActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "heap_pressure_active",
+		Help:      "Whether a host was under heap pressure (1) or not (0) in the last checkForHeapPressure run.",
+	}, []string{"cluster", "host"})
+
+	HeapPressureEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "heap_pressure_events_total",
+		Help:      "Heap pressure events fired per host.",
+	}, []string{"cluster", "host"})
+)
+
+// Long-running task metrics, from the latest watchLongRunningTasks run
+var (
+	LongRunningTasks = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "long_running_tasks",
+		Help:      "Watched tasks of a cluster running longer than maxRunningTime.",
+	}, []string{"cluster"})
+
+	TaskCancellationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "task_cancellations_total",
+		Help:      "Cancellations of long-running tasks requested per cluster and result (success, failure).",
+	}, []string{"cluster", "result"})
+)
+
+// Task delta metrics, from getTDataWriteBulk_sTasks with delta; the totals count the tasks
+// first seen since this process started, per task action filter
+var (
+	TasksNewTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "tasks_new_total",
+		Help:      "Tasks of a task action filter first seen on a cluster.",
+	}, []string{"cluster", "action"})
+
+	TaskRequestsNewTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "task_requests_new_total",
+		Help:      "Requests of the tasks of a task action filter first seen on a cluster.",
+	}, []string{"cluster", "action"})
+)
+
+// Thread pool rejection metrics, from the latest getThreadPoolRejections run
+var (
+	ThreadPoolRejected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "thread_pool_rejected",
+		Help:      "Rejected executions of a thread pool of a node since the node started.",
+	}, []string{"cluster", "host", "pool"})
+
+	ThreadPoolRejectedDelta = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "thread_pool_rejected_delta",
+		Help:      "Rejected executions of a thread pool of a node since the previous sample.",
+	}, []string{"cluster", "host", "pool"})
+)
+
+// Segment and merge metrics, from the latest getNodeSegmentStats run
+var (
+	NodeSegments = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "node_segments",
+		Help:      "Lucene segments held by a node.",
+	}, []string{"cluster", "host"})
+
+	NodeMergesCurrent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "node_merges_current",
+		Help:      "Merges running on a node.",
+	}, []string{"cluster", "host"})
+)
+
+// Shard recovery metrics, from the latest getShardRecoveries run
+var (
+	RecoveriesActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "recoveries_active",
+		Help:      "Active shard recoveries of a cluster per recovery type.",
+	}, []string{"cluster", "type"})
+
+	RecoveriesStalled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "recoveries_stalled",
+		Help:      "Active shard recoveries of a cluster below the minimum throughput for longer than allowed.",
+	}, []string{"cluster"})
+
+	RecoveryThroughputBytesPerSecond = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "recovery_throughput_bytes_per_second",
+		Help:      "Combined throughput of the active shard recoveries of a cluster since the previous poll.",
+	}, []string{"cluster"})
+)
+
+// Mapping field count metrics, from the latest getIndexFieldCounts run
+var (
+	IndexFieldsMaxUsedPercent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "index_fields_max_used_percent",
+		Help:      "Highest share of index.mapping.total_fields.limit used by an index of a cluster.",
+	}, []string{"cluster"})
+
+	IndicesNearFieldLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "indices_near_field_limit",
+		Help:      "Indices of a cluster whose mapped fields are at or above the configured share of their limit.",
+	}, []string{"cluster"})
+)
+
+// Ingest pipeline metrics, from getIngestPipelineStats; the totals count the growth seen
+// since this process started, summed over the nodes of a cluster
+var (
+	IngestPipelineDocumentsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "ingest_pipeline_documents_total",
+		Help:      "Documents processed by an ingest pipeline.",
+	}, []string{"cluster", "pipeline"})
+
+	IngestPipelineTimeSecondsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "ingest_pipeline_time_seconds_total",
+		Help:      "Time spent by an ingest pipeline processing documents.",
+	}, []string{"cluster", "pipeline"})
+
+	IngestPipelineFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "ingest_pipeline_failures_total",
+		Help:      "Documents an ingest pipeline failed to process.",
+	}, []string{"cluster", "pipeline"})
+
+	IngestPipelineCurrent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "ingest_pipeline_current",
+		Help:      "Documents an ingest pipeline is processing.",
+	}, []string{"cluster", "pipeline"})
+)
+
+// Remote cluster metrics, from the latest getRemoteClusters run
+var (
+	RemoteClusterConnected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "remote_cluster_connected",
+		Help:      "Whether a cluster is connected to a configured remote cluster (1) or not (0).",
+	}, []string{"cluster", "remote"})
+
+	RemoteClusterNodesConnected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "remote_cluster_nodes_connected",
+		Help:      "Nodes (sniff mode) or sockets (proxy mode) of a remote cluster a cluster is connected to.",
+	}, []string{"cluster", "remote"})
+
+	RemoteClusterDisconnectsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "remote_cluster_disconnects_total",
+		Help:      "Observed disconnects of a cluster from a remote cluster.",
+	}, []string{"cluster", "remote"})
+)
+
+// Elected master metrics, from updateCurrentMasterEndPoints
+var (
+	MasterChangesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "master_changes_total",
+		Help:      "Observed changes of the elected master of a cluster.",
+	}, []string{"cluster"})
+)
+
+// Data tier metrics, from the latest getDataTiers run
+var (
+	TierStoreBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "tier_store_bytes",
+		Help:      "Size of the shards held by the data nodes of a data tier.",
+	}, []string{"cluster", "tier"})
+
+	TierDiskAvailableBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "tier_disk_available_bytes",
+		Help:      "Free disk of the data nodes of a data tier.",
+	}, []string{"cluster", "tier"})
+
+	TierIndexingRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "tier_indexing_docs_per_second",
+		Help:      "Documents indexed per second on the data nodes of a data tier, since the previous collection.",
+	}, []string{"cluster", "tier"})
+)
+
+// Self-telemetry metrics, from the latest run of each job (selfTelemetry.enabled)
+var (
+	JobRunDurationSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "job_run_duration_seconds",
+		Help:      "Wall-clock time of the latest run of a job.",
+	}, []string{"job"})
+
+	JobRunAllocatedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "job_run_allocated_bytes",
+		Help:      "Heap bytes allocated by the process during the latest run of a job.",
+	}, []string{"job"})
+)
+
+// Job scheduling metrics: blackout calendars, retries and adaptive intervals
+var (
+	JobBlackoutSkipsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "job_blackout_skips_total",
+		Help:      "Runs of a job skipped because a blackout calendar held the job.",
+	}, []string{"job"})
+
+	JobRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "job_retries_total",
+		Help:      "Retries of failed runs of a job.",
+	}, []string{"job"})
+
+	JobRetriesExhaustedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "job_retries_exhausted_total",
+		Help:      "Runs of a job that still failed after their last retry.",
+	}, []string{"job"})
+
+	JobIntervalSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "job_interval_seconds",
+		Help:      "Effective interval of an interval job, stretched while its runs take most of the configured interval.",
+	}, []string{"job"})
+
+	JobIntervalAdaptationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "job_interval_adaptations_total",
+		Help:      "Changes of the effective interval of a job, by direction (stretched, shortened).",
+	}, []string{"job", "direction"})
+)
+
+// Output metrics, from report and dump jobs writing artifacts
+var (
+	OutputWritesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "output_writes_total",
+		Help:      "Artifacts written to an output destination by result (success, failure).",
+	}, []string{"destination", "result"})
+
+	OutputBytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "output_bytes_total",
+		Help:      "Bytes of the artifacts written to an output destination.",
+	}, []string{"destination"})
+
+	OutputPrunedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "output_pruned_total",
+		Help:      "Artifacts deleted from an output destination by its retention.",
+	}, []string{"destination"})
+)
+
+// Housekeeping metrics, from pruneArtifacts pruning local directories
+var (
+	ArtifactsPrunedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "artifacts_pruned_total",
+		Help:      "Files deleted from a directory by its retention.",
+	}, []string{"directory"})
+
+	ArtifactsReclaimedBytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "artifacts_reclaimed_bytes_total",
+		Help:      "Bytes of the files deleted from a directory by its retention.",
+	}, []string{"directory"})
+
+	ArtifactsDirectoryBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "artifacts_directory_bytes",
+		Help:      "Bytes of the files left in a directory after its latest pruning.",
+	}, []string{"directory"})
+)
+
+// API metrics
+var (
+	APIRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "api_requests_total",
+		Help:      "API requests by route, method, status code and principal (token name).",
+	}, []string{"route", "method", "code", "principal"})
+
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Time to serve API requests by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	APILegacyRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "api_legacy_requests_total",
+		Help:      "Requests to the deprecated unversioned /api routes, by route.",
+	}, []string{"route"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		MemoryBytes,
+		MemoryBudgetBytes,
+		MemoryItems,
+		MemoryEvictionsTotal,
+		MonitoringEndpointHealthy,
+		QueryCacheHitsTotal,
+		QueryCacheMissesTotal,
+		QueryCacheEntries,
+		QueryCacheBytes,
+		CollectionLastSuccessSeconds,
+		CollectionConsecutiveFailures,
+		CollectionsTotal,
+		NotificationsTotal,
+		NotificationsSuppressedTotal,
+		KafkaMessagesTotal,
+		RemediationRunsTotal,
+		RemediationRollbacksTotal,
+		RemediationsActive,
+		AlertsFiring,
+		EventsFiring,
+		EventsStored,
+		EventsTotal,
+		RetentionViolations,
+		ClusterIndices,
+		ClusterDocs,
+		ClusterStorageBytes,
+		ClusterIngestBytesPerSecond,
+		WritePressureActive,
+		WritePressureSeverity,
+		WritePressureDataNodesPercent,
+		WritePressureEventsTotal,
+		ThreadPoolWriteQueue,
+		ThreadPoolWriteQueueTimestampSeconds,
+		NodeDiskUsedPercent,
+		NodeDiskWatermarkBreached,
+		NodeHeapUsedPercent,
+		NodeGCCollections,
+		NodeGCTimeSeconds,
+		HeapPressureActive,
+		HeapPressureEventsTotal,
+		LongRunningTasks,
+		TaskCancellationsTotal,
+		TasksNewTotal,
+		TaskRequestsNewTotal,
+		ThreadPoolRejected,
+		ThreadPoolRejectedDelta,
+		NodeSegments,
+		NodeMergesCurrent,
+		RecoveriesActive,
+		RecoveriesStalled,
+		RecoveryThroughputBytesPerSecond,
+		IndexFieldsMaxUsedPercent,
+		IndicesNearFieldLimit,
+		IngestPipelineDocumentsTotal,
+		IngestPipelineTimeSecondsTotal,
+		IngestPipelineFailuresTotal,
+		IngestPipelineCurrent,
+		RemoteClusterConnected,
+		RemoteClusterNodesConnected,
+		RemoteClusterDisconnectsTotal,
+		MasterChangesTotal,
+		TierStoreBytes,
+		TierDiskAvailableBytes,
+		TierIndexingRate,
+		JobRunDurationSeconds,
+		JobRunAllocatedBytes,
+		JobBlackoutSkipsTotal,
+		JobRetriesTotal,
+		JobRetriesExhaustedTotal,
+		JobIntervalSeconds,
+		JobIntervalAdaptationsTotal,
+		OutputWritesTotal,
+		OutputBytesTotal,
+		OutputPrunedTotal,
+		ArtifactsPrunedTotal,
+		ArtifactsReclaimedBytesTotal,
+		ArtifactsDirectoryBytes,
+		APIRequestsTotal,
+		APIRequestDuration,
+		APILegacyRequestsTotal,
+	)
+}
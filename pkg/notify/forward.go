@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/utils"
+)
+
+// forwardQueueSize bounds the events waiting to be forwarded; further events are dropped
+const forwardQueueSize = 1000
+
+// eventSender forwards events on one channel
+type eventSender interface {
+	channel() string
+	send(event events.Event) error
+	close()
+}
+
+// forwardChannel is a configured forwarding channel and the events it forwards
+type forwardChannel struct {
+	sender       eventSender
+	severities   []string
+	sendResolved bool
+}
+
+// forwarder sends the queued events on its channels
+type forwarder struct {
+	channels []*forwardChannel
+	queue    chan events.Event
+	done     chan struct{}
+}
+
+var (
+	forwardMu  sync.RWMutex
+	forwarding *forwarder // nil = no channel configured
+)
+
+// ConfigureForwarding starts forwarding events to the syslog and SNMP channels configured in
+// notifications. Forwarding already running is stopped first.
+func ConfigureForwarding(cfg config.NotificationConfig) error {
+	StopForwarding()
+
+	f := &forwarder{queue: make(chan events.Event, forwardQueueSize), done: make(chan struct{})}
+	if cfg.Syslog.Address != "" {
+		sender, err := newSyslogSender(cfg.Syslog)
+		if err != nil {
+			return fmt.Errorf("invalid notifications.syslog: %w", err)
+		}
+		f.channels = append(f.channels, &forwardChannel{sender: sender, severities: cfg.Syslog.Severities, sendResolved: cfg.Syslog.SendResolved})
+	}
+	if cfg.SNMP.Address != "" {
+		sender, err := newSNMPSender(cfg.SNMP)
+		if err != nil {
+			return fmt.Errorf("invalid notifications.snmp: %w", err)
+		}
+		f.channels = append(f.channels, &forwardChannel{sender: sender, severities: cfg.SNMP.Severities, sendResolved: cfg.SNMP.SendResolved})
+	}
+	if len(f.channels) == 0 {
+		return nil
+	}
+	go f.run()
+
+	forwardMu.Lock()
+	forwarding = f
+	forwardMu.Unlock()
+	return nil
+}
+
+// StopForwarding stops forwarding events, sending the queued events first
+func StopForwarding() {
+	forwardMu.Lock()
+	f := forwarding
+	forwarding = nil
+	forwardMu.Unlock()
+
+	if f != nil {
+		close(f.queue)
+		<-f.done
+	}
+}
+
+// ForwardEvent queues an event for the forwarding channels; it is subscribed to the event
+// store. Events suppressed by a maintenance window are not forwarded.
+func ForwardEvent(event events.Event) {
+	if event.Suppressed {
+		return
+	}
+
+	forwardMu.RLock()
+	defer forwardMu.RUnlock()
+	if forwarding == nil {
+		return
+	}
+	select {
+	case forwarding.queue <- event:
+	default:
+		for _, ch := range forwarding.channels {
+			metrics.NotificationsTotal.WithLabelValues(ch.sender.channel(), "dropped").Inc()
+		}
+	}
+}
+
+// run sends the queued events until the queue is closed
+func (f *forwarder) run() {
+	defer close(f.done)
+	for event := range f.queue {
+		for _, ch := range f.channels {
+			if !utils.Contains(ch.severities, event.Severity) || (event.State == events.StateResolved && !ch.sendResolved) {
+				continue
+			}
+			record(ch.sender.channel(), ch.sender.send(event))
+		}
+	}
+	for _, ch := range f.channels {
+		ch.sender.close()
+	}
+}
+
+// eventText describes an event in one line, e.g. "WritePressure firing on cluster c1
+// (host=h1): topContributors: ..."
+func eventText(event events.Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s on cluster %s", event.Name, event.State, event.Cluster())
+
+	labels := make([]string, 0, len(event.Labels))
+	for _, k := range sortedKeys(event.Labels) {
+		if k != "cluster" {
+			labels = append(labels, k+"="+event.Labels[k])
+		}
+	}
+	if len(labels) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(labels, ", "))
+	}
+
+	annotations := make([]string, 0, len(event.Annotations))
+	for _, k := range sortedKeys(event.Annotations) {
+		annotations = append(annotations, k+": "+event.Annotations[k])
+	}
+	if len(annotations) > 0 {
+		b.WriteString(": " + strings.Join(annotations, "; "))
+	}
+	return strings.ReplaceAll(b.String(), "\n", " ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/maintenance"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/utils"
+)
+
+// Message is an alert or report for an owner
+type Message struct {
+	Subject  string   `json:"subject"`
+	Text     string   `json:"text"`
+	Severity string   `json:"severity,omitempty"` // e.g. "warning", "info"
+	Clusters []string `json:"clusters,omitempty"` // clusters the message is about
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// NotifyCluster sends an alert about clusterName to its owner (see OwnerForCluster).
+// Nothing is sent while the cluster is in a maintenance window.
+func NotifyCluster(ctx context.Context, clusterName string, msg Message) error {
+	if reason, ok := maintenance.InMaintenance(clusterName, time.Now()); ok {
+		logger.AppInfo("Suppressed notification %q for cluster %s: %s", msg.Subject, clusterName, reason)
+		metrics.NotificationsSuppressedTotal.Inc()
+		return nil
+	}
+	owner, ok := OwnerForCluster(clusterName)
+	if !ok {
+		return fmt.Errorf("no owner to notify for cluster %s", clusterName)
+	}
+	if len(msg.Clusters) == 0 {
+		msg.Clusters = []string{clusterName}
+	}
+	return NotifyOwner(ctx, owner, msg)
+}
+
+// NotifyOwner sends msg on every channel configured for the owner. A failing channel does
+// not stop the others; all failures are returned together.
+func NotifyOwner(ctx context.Context, owner *Owner, msg Message) error {
+	var errs []error
+	if owner.Email != "" {
+		errs = append(errs, record("email", sendEmail(owner.Email, msg)))
+	}
+	if owner.Slack != "" {
+		errs = append(errs, record("slack", sendSlack(ctx, owner.Slack, msg)))
+	}
+	if owner.Webhook != "" {
+		errs = append(errs, record("webhook", sendWebhook(ctx, owner.Webhook, owner, msg)))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to notify owner %s: %w", owner.Name, err)
+	}
+	return nil
+}
+
+func record(channel string, err error) error {
+	result := "success"
+	if err != nil {
+		result = "failure"
+		err = fmt.Errorf("%s: %w", channel, err)
+	}
+	metrics.NotificationsTotal.WithLabelValues(channel, result).Inc()
+	return err
+}
+
+func sendEmail(addresses string, msg Message) error {
+	cfg := config.Global.Notifications
+	if cfg.SMTPHost == "" || cfg.From == "" {
+		return fmt.Errorf("notifications.smtpHost and notifications.from must be configured")
+	}
+
+	to := make([]string, 0)
+	for _, address := range strings.Split(addresses, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			to = append(to, address)
+		}
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	body.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if cfg.SMTPUser != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	return smtp.SendMail(addr, auth, cfg.From, to, body.Bytes())
+}
+
+func sendSlack(ctx context.Context, url string, msg Message) error {
+	return postJSON(ctx, url, map[string]interface{}{
+		"text": fmt.Sprintf("*%s*\n%s", msg.Subject, msg.Text),
+	})
+}
+
+func sendWebhook(ctx context.Context, url string, owner *Owner, msg Message) error {
+	return postJSON(ctx, url, map[string]interface{}{
+		"owner":     owner.Name,
+		"subject":   msg.Subject,
+		"text":      msg.Text,
+		"severity":  msg.Severity,
+		"clusters":  msg.Clusters,
+		"timestamp": utils.TimeNowMillis(),
+	})
+}
+
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Package notify keeps the owner directory and delivers alerts and reports to the team
+// owning a cluster by email, Slack or a generic webhook, and forwards events to NOC tooling as
+// syslog messages or SNMP traps.
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// Owner is a team that owns clusters (ClusterData.Owner) and how to reach it
+type Owner struct {
+	Name    string `json:"name" yaml:"name"`
+	Email   string `json:"email,omitempty" yaml:"email,omitempty"`     // comma separated addresses
+	Slack   string `json:"slack,omitempty" yaml:"slack,omitempty"`     // Slack incoming webhook URL
+	Webhook string `json:"webhook,omitempty" yaml:"webhook,omitempty"` // generic webhook URL, receives JSON
+}
+
+// ownersFile is the YAML/JSON layout of an owners file
+type ownersFile struct {
+	Owners []*Owner `json:"owners" yaml:"owners"`
+}
+
+var (
+	ownersMu sync.RWMutex
+	owners   = make(map[string]*Owner) // key: lower-cased owner name
+)
+
+// LoadOwners reads an owners file. YAML and JSON files contain an "owners" list; CSV files
+// have a header row with the columns name, email, slack and webhook.
+func LoadOwners(path string) ([]*Owner, error) {
+	var list []*Owner
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read owners file: %w", err)
+		}
+		var file ownersFile
+		if filepath.Ext(path) == ".json" {
+			err = json.Unmarshal(data, &file)
+		} else {
+			err = yaml.Unmarshal(data, &file)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse owners file: %w", err)
+		}
+		list = file.Owners
+	case ".csv":
+		parser := utils.NewCSVParser(path)
+		if err := parser.Parse(); err != nil {
+			return nil, err
+		}
+		for _, row := range parser.GetRows() {
+			list = append(list, &Owner{
+				Name:    strings.TrimSpace(row["name"]),
+				Email:   strings.TrimSpace(row["email"]),
+				Slack:   strings.TrimSpace(row["slack"]),
+				Webhook: strings.TrimSpace(row["webhook"]),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported owners file format: %s", filepath.Ext(path))
+	}
+
+	seen := make(map[string]bool)
+	for i, owner := range list {
+		if owner == nil || owner.Name == "" {
+			return nil, fmt.Errorf("owner %d has no name", i+1)
+		}
+		key := strings.ToLower(owner.Name)
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate owner %q", owner.Name)
+		}
+		seen[key] = true
+		if owner.Email == "" && owner.Slack == "" && owner.Webhook == "" {
+			return nil, fmt.Errorf("owner %q has no email, slack or webhook", owner.Name)
+		}
+	}
+	return list, nil
+}
+
+// SetOwners replaces the owner directory
+func SetOwners(list []*Owner) {
+	next := make(map[string]*Owner, len(list))
+	for _, owner := range list {
+		o := *owner
+		next[strings.ToLower(owner.Name)] = &o
+	}
+
+	ownersMu.Lock()
+	owners = next
+	ownersMu.Unlock()
+}
+
+// GetOwner returns a copy of an owner, matched case-insensitively by name
+func GetOwner(name string) (*Owner, bool) {
+	ownersMu.RLock()
+	defer ownersMu.RUnlock()
+
+	owner, ok := owners[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, false
+	}
+	o := *owner
+	return &o, true
+}
+
+// Owners returns copies of all owners sorted by name
+func Owners() []*Owner {
+	ownersMu.RLock()
+	defer ownersMu.RUnlock()
+
+	list := make([]*Owner, 0, len(owners))
+	for _, owner := range owners {
+		o := *owner
+		list = append(list, &o)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// OwnerForCluster returns the owner of a cluster, or the configured default owner when the
+// cluster's owner is not in the directory
+func OwnerForCluster(clusterName string) (*Owner, bool) {
+	if cluster, ok := types.GetCluster(clusterName); ok {
+		if owner, ok := GetOwner(cluster.Owner); ok {
+			return owner, true
+		}
+	}
+	if config.Global != nil && config.Global.Notifications.DefaultOwner != "" {
+		return GetOwner(config.Global.Notifications.DefaultOwner)
+	}
+	return nil, false
+}
+
+// ClustersByOwner groups the cluster inventory by owner name (as in the directory), using
+// the default owner for clusters without a known owner. Clusters that cannot be routed are
+// returned under the empty name.
+func ClustersByOwner() map[string][]string {
+	result := make(map[string][]string)
+	for _, clusterName := range types.ClusterNames() {
+		name := ""
+		if owner, ok := OwnerForCluster(clusterName); ok {
+			name = owner.Name
+		}
+		result[name] = append(result[name], clusterName)
+	}
+	return result
+}
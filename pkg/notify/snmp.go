@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
+)
+
+// snmpVariables are the event fields a trap carries, in order; field n defaults to the OID
+// <enterpriseOid>.1.<n>
+var snmpVariables = []string{"id", "source", "name", "severity", "state", "cluster", "text", "startsAt"}
+
+// OIDs of the variables every SNMPv2 trap starts with
+var (
+	oidSysUpTime   = []uint32{1, 3, 6, 1, 2, 1, 1, 3, 0}
+	oidSnmpTrapOID = []uint32{1, 3, 6, 1, 6, 3, 1, 1, 4, 1, 0}
+)
+
+// BER tags
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berOID         = 0x06
+	berSequence    = 0x30
+	berTimeTicks   = 0x43
+	berTrapV2      = 0xa7
+)
+
+// snmpSender forwards events as SNMPv2c traps over UDP
+type snmpSender struct {
+	cfg          config.SNMPConfig
+	trapOID      []uint32
+	resolvedOID  []uint32
+	variableOIDs [][]uint32 // per snmpVariables
+	started      time.Time  // sysUpTime is counted from here
+	requestID    int32
+}
+
+func newSNMPSender(cfg config.SNMPConfig) (*snmpSender, error) {
+	s := &snmpSender{cfg: cfg, started: time.Now()}
+	var err error
+	if s.trapOID, err = parseOID(cfg.TrapOID); err != nil {
+		return nil, fmt.Errorf("trapOid: %w", err)
+	}
+	if s.resolvedOID, err = parseOID(cfg.ResolvedTrapOID); err != nil {
+		return nil, fmt.Errorf("resolvedTrapOid: %w", err)
+	}
+
+	for field := range cfg.VariableOIDs {
+		found := false
+		for _, variable := range snmpVariables {
+			found = found || variable == field
+		}
+		if !found {
+			return nil, fmt.Errorf("variableOids: unknown field %q, expected one of %s", field, strings.Join(snmpVariables, ", "))
+		}
+	}
+	for i, field := range snmpVariables {
+		oid := cfg.VariableOIDs[field]
+		if oid == "" {
+			oid = cfg.EnterpriseOID + ".1." + strconv.Itoa(i+1)
+		}
+		parsed, err := parseOID(oid)
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %w", field, err)
+		}
+		s.variableOIDs = append(s.variableOIDs, parsed)
+	}
+	return s, nil
+}
+
+func (s *snmpSender) channel() string {
+	return "snmp"
+}
+
+func (s *snmpSender) close() {}
+
+// send sends the trap of an event
+func (s *snmpSender) send(event events.Event) error {
+	uptime := uint32(time.Since(s.started) / (10 * time.Millisecond))
+	trap := s.trap(event, uptime)
+
+	conn, err := net.DialTimeout("udp", s.cfg.Address, syslogTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", s.cfg.Address, err)
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	if _, err := conn.Write(trap); err != nil {
+		return fmt.Errorf("failed to send trap to %s: %w", s.cfg.Address, err)
+	}
+	return nil
+}
+
+// trap encodes the SNMPv2c trap of an event: the trap OID of its state and its fields as
+// strings, uptime in hundredths of a second
+func (s *snmpSender) trap(event events.Event, uptime uint32) []byte {
+	trapOID := s.trapOID
+	if event.State == events.StateResolved {
+		trapOID = s.resolvedOID
+	}
+	values := map[string]string{
+		"id":       event.ID,
+		"source":   event.Source,
+		"name":     event.Name,
+		"severity": event.Severity,
+		"state":    event.State,
+		"cluster":  event.Cluster(),
+		"text":     eventText(event),
+		"startsAt": time.UnixMilli(event.StartsAt).UTC().Format(time.RFC3339),
+	}
+
+	varbinds := berVarbind(nil, oidSysUpTime, berTLV(berTimeTicks, berUint(uptime)))
+	varbinds = berVarbind(varbinds, oidSnmpTrapOID, berTLV(berOID, berOIDValue(trapOID)))
+	for i, field := range snmpVariables {
+		varbinds = berVarbind(varbinds, s.variableOIDs[i], berTLV(berOctetString, []byte(values[field])))
+	}
+
+	s.requestID++
+	pdu := berTLV(berInteger, berInt(int64(s.requestID)))
+	pdu = append(pdu, berTLV(berInteger, berInt(0))...) // error-status
+	pdu = append(pdu, berTLV(berInteger, berInt(0))...) // error-index
+	pdu = append(pdu, berTLV(berSequence, varbinds)...)
+
+	message := berTLV(berInteger, berInt(1)) // version: SNMPv2c
+	message = append(message, berTLV(berOctetString, []byte(s.cfg.Community))...)
+	message = append(message, berTLV(berTrapV2, pdu)...)
+	return berTLV(berSequence, message)
+}
+
+// parseOID parses a dotted OID such as 1.3.6.1.4.1.32473.1
+func parseOID(s string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	oid := make([]uint32, 0, len(parts))
+	for _, part := range parts {
+		arc, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		oid = append(oid, uint32(arc))
+	}
+	if oid[0] > 2 || (oid[0] < 2 && oid[1] >= 40) {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	return oid, nil
+}
+
+// berVarbind appends a variable binding to the encoded bindings
+func berVarbind(buf []byte, oid []uint32, value []byte) []byte {
+	binding := append(berTLV(berOID, berOIDValue(oid)), value...)
+	return append(buf, berTLV(berSequence, binding)...)
+}
+
+// berTLV encodes a tag, the definite length of the value and the value
+func berTLV(tag byte, value []byte) []byte {
+	buf := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		buf = append(buf, byte(n))
+	case n <= 0xff:
+		buf = append(buf, 0x81, byte(n))
+	default:
+		buf = append(buf, 0x82, byte(n>>8), byte(n))
+	}
+	return append(buf, value...)
+}
+
+// berInt encodes an integer in the fewest two's complement octets
+func berInt(v int64) []byte {
+	buf := []byte{byte(v)}
+	for (v > 0x7f || v < -0x80) && len(buf) < 8 {
+		v >>= 8
+		buf = append([]byte{byte(v)}, buf...)
+	}
+	return buf
+}
+
+// berUint encodes an unsigned integer, with a leading zero octet when its top bit is set
+func berUint(v uint32) []byte {
+	return berInt(int64(v))
+}
+
+// berOIDValue encodes the arcs of an OID
+func berOIDValue(oid []uint32) []byte {
+	buf := berArc(nil, oid[0]*40+oid[1])
+	for _, arc := range oid[2:] {
+		buf = berArc(buf, arc)
+	}
+	return buf
+}
+
+// berArc encodes an OID arc in base 128, high bit set on all but the last octet
+func berArc(buf []byte, arc uint32) []byte {
+	var octets []byte
+	for {
+		octets = append([]byte{byte(arc & 0x7f)}, octets...)
+		if arc >>= 7; arc == 0 {
+			break
+		}
+	}
+	for i := 0; i < len(octets)-1; i++ {
+		octets[i] |= 0x80
+	}
+	return append(buf, octets...)
+}
@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
+)
+
+func TestBERPrimitives(t *testing.T) {
+	// Encodings of X.690: lengths (8.1.3), integers (8.3) and object identifiers (8.19)
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{"integer 0", berTLV(berInteger, berInt(0)), "020100"},
+		{"integer 127", berTLV(berInteger, berInt(127)), "02017f"},
+		{"integer 128", berTLV(berInteger, berInt(128)), "02020080"},
+		{"integer 256", berTLV(berInteger, berInt(256)), "02020100"},
+		{"integer -128", berTLV(berInteger, berInt(-128)), "020180"},
+		{"integer -129", berTLV(berInteger, berInt(-129)), "0202ff7f"},
+		{"timeticks 2^32-1", berTLV(berTimeTicks, berUint(0xffffffff)), "430500ffffffff"},
+		{"OID 2.999.3", berTLV(berOID, berOIDValue([]uint32{2, 999, 3})), "0603883703"},
+		{"OID sysUpTime.0", berTLV(berOID, berOIDValue(oidSysUpTime)), "06082b06010201010300"},
+		{"OID enterprise 32473", berTLV(berOID, berOIDValue([]uint32{1, 3, 6, 1, 4, 1, 32473})), "06082b0601040181fd59"},
+		{"length 127", berTLV(berOctetString, make([]byte, 127))[:2], "047f"},
+		{"length 128", berTLV(berOctetString, make([]byte, 128))[:3], "048180"},
+		{"length 256", berTLV(berOctetString, make([]byte, 256))[:4], "04820100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(tt.got); got != tt.want {
+				t.Errorf("encoded %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOID(t *testing.T) {
+	for _, s := range []string{"1.3.6.1.4.1.32473.1", ".1.3.6.1.4.1.32473.1", "2.999.3"} {
+		if _, err := parseOID(s); err != nil {
+			t.Errorf("parseOID(%q): %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "1", "1.3.x", "3.1", "1.40", "1.3.4294967296"} {
+		if _, err := parseOID(s); err == nil {
+			t.Errorf("parseOID(%q) = nil error, want invalid", s)
+		}
+	}
+}
+
+func testSNMPSender(t *testing.T, address string) *snmpSender {
+	t.Helper()
+	s, err := newSNMPSender(config.SNMPConfig{
+		Address:         address,
+		Community:       "public",
+		EnterpriseOID:   "1.3.6.1.4.1.32473.1",
+		TrapOID:         "1.3.6.1.4.1.32473.1.0.1",
+		ResolvedTrapOID: "1.3.6.1.4.1.32473.1.0.2",
+	})
+	if err != nil {
+		t.Fatalf("newSNMPSender: %v", err)
+	}
+	return s
+}
+
+var testEvent = events.Event{
+	ID:       "ev-1",
+	Source:   "rules",
+	Name:     "HighHeap",
+	Severity: "critical",
+	State:    events.StateFiring,
+	Labels:   map[string]string{"cluster": "c1"},
+	StartsAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli(),
+}
+
+// testTrap is testEvent as the first trap of a sender up for 42.42s, as it goes on the wire
+var testTrap = strings.Join([]string{
+	"30820121",         // message
+	"020101",           // version: 2c
+	"04067075626c6963", // community: public
+	"a7820112",         // SNMPv2-Trap-PDU
+	"020101",           // request ID: 1
+	"020100",           // error status
+	"020100",           // error index
+	"30820105",         // variable bindings
+	"300e",             // sysUpTime.0 = 4242
+	"06082b06010201010300",
+	"43021092",
+	"3019", // snmpTrapOID.0 = <enterprise>.0.1
+	"060a2b060106030101040100",
+	"060b2b0601040181fd59010001",
+	"3013", // <enterprise>.1.1 = id
+	"060b2b0601040181fd59010101",
+	"040465762d31",
+	"3014", // <enterprise>.1.2 = source
+	"060b2b0601040181fd59010102",
+	"040572756c6573",
+	"3017", // <enterprise>.1.3 = name
+	"060b2b0601040181fd59010103",
+	"04084869676848656170",
+	"3017", // <enterprise>.1.4 = severity
+	"060b2b0601040181fd59010104",
+	"0408637269746963616c",
+	"3015", // <enterprise>.1.5 = state
+	"060b2b0601040181fd59010105",
+	"0406666972696e67",
+	"3011", // <enterprise>.1.6 = cluster
+	"060b2b0601040181fd59010106",
+	"04026331",
+	"302c", // <enterprise>.1.7 = text
+	"060b2b0601040181fd59010107",
+	"041d486967684865617020666972696e67206f6e20636c7573746572206331",
+	"3023", // <enterprise>.1.8 = startsAt
+	"060b2b0601040181fd59010108",
+	"0414323032342d30312d30315430303a30303a30305a",
+}, "")
+
+func TestSNMPTrap(t *testing.T) {
+	s := testSNMPSender(t, "127.0.0.1:162")
+	if got := hex.EncodeToString(s.trap(testEvent, 4242)); got != testTrap {
+		t.Errorf("trap =\n%s\nwant\n%s", got, testTrap)
+	}
+
+	// The next trap has the next request ID; a resolution has the resolved trap OID
+	resolved := testEvent
+	resolved.State = events.StateResolved
+	trap := s.trap(resolved, 4242)
+	if pdu := bytes.IndexByte(trap, berTrapV2); pdu < 0 || !bytes.HasPrefix(trap[pdu+4:], []byte("\x02\x01\x02")) {
+		t.Errorf("second trap %x does not have request ID 2", trap)
+	}
+	if !bytes.Contains(trap, []byte("\x06\x0b\x2b\x06\x01\x04\x01\x81\xfd\x59\x01\x00\x02")) {
+		t.Errorf("resolution trap %x does not have the resolved trap OID", trap)
+	}
+}
+
+func TestSNMPSend(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	s := testSNMPSender(t, listener.LocalAddr().String())
+	s.started = time.Now().Add(-42420 * time.Millisecond)
+	if err := s.send(testEvent); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	buf := make([]byte, 1500)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := listener.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	// Only the value of sysUpTime, 42.42s and counting, differs from testTrap
+	want, _ := hex.DecodeString(testTrap)
+	uptime := bytes.Index(want, []byte("\x43\x02\x10\x92")) + 2
+	got := buf[:n]
+	if len(got) != len(want) || !bytes.Equal(got[:uptime], want[:uptime]) || !bytes.Equal(got[uptime+2:], want[uptime+2:]) {
+		t.Fatalf("datagram =\n%x\nwant\n%x", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
+)
+
+// syslogFacilities are the facility codes of RFC 5424
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogTimeout bounds connecting to and writing to the syslog server
+const syslogTimeout = 10 * time.Second
+
+// syslogSender forwards events as RFC 5424 messages over UDP, TCP or TLS
+type syslogSender struct {
+	cfg      config.SyslogConfig
+	facility int
+	hostname string
+	tls      *tls.Config
+	conn     net.Conn // nil until the first message, and after a failed write
+}
+
+func newSyslogSender(cfg config.SyslogConfig) (*syslogSender, error) {
+	facility, ok := syslogFacilities[strings.ToLower(cfg.Facility)]
+	if !ok {
+		return nil, fmt.Errorf("unknown facility %q", cfg.Facility)
+	}
+	s := &syslogSender{cfg: cfg, facility: facility, hostname: "-"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		s.hostname = hostname
+	}
+	if cfg.Protocol == "tls" {
+		s.tls = &tls.Config{InsecureSkipVerify: cfg.InsecureTLS}
+		if cfg.CACert != "" {
+			pem, err := os.ReadFile(cfg.CACert)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read caCert: %w", err)
+			}
+			s.tls.RootCAs = x509.NewCertPool()
+			if !s.tls.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("caCert %s holds no certificate", cfg.CACert)
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *syslogSender) channel() string {
+	return "syslog"
+}
+
+// send writes the message of an event, reconnecting once when the connection broke
+func (s *syslogSender) send(event events.Event) error {
+	message := s.format(event)
+	if s.cfg.Protocol != "udp" {
+		// Octet counting framing (RFC 6587), so messages may span lines
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = s.dial(); err != nil {
+				return err
+			}
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if _, err = s.conn.Write([]byte(message)); err == nil {
+			return nil
+		}
+		s.close()
+	}
+	return fmt.Errorf("failed to write to %s: %w", s.cfg.Address, err)
+}
+
+func (s *syslogSender) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogTimeout}
+	switch s.cfg.Protocol {
+	case "tls":
+		return tls.DialWithDialer(dialer, "tcp", s.cfg.Address, s.tls)
+	case "tcp":
+		return dialer.Dial("tcp", s.cfg.Address)
+	default:
+		return dialer.Dial("udp", s.cfg.Address)
+	}
+}
+
+func (s *syslogSender) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// format returns the RFC 5424 message of an event: the event name as MSGID, its ID, source,
+// state, severity and labels as structured data, and its description as MSG
+func (s *syslogSender) format(event events.Event) string {
+	timestamp := event.StartsAt
+	if event.State == events.StateResolved {
+		timestamp = event.EndsAt
+	}
+
+	var sd strings.Builder
+	fmt.Fprintf(&sd, "[event@%d", s.cfg.EnterpriseNumber)
+	for _, param := range [][2]string{{"id", event.ID}, {"source", event.Source}, {"state", event.State}, {"severity", event.Severity}} {
+		fmt.Fprintf(&sd, " %s=\"%s\"", param[0], sdEscape(param[1]))
+	}
+	sd.WriteString("]")
+	if len(event.Labels) > 0 {
+		fmt.Fprintf(&sd, "[labels@%d", s.cfg.EnterpriseNumber)
+		for _, k := range sortedKeys(event.Labels) {
+			fmt.Fprintf(&sd, " %s=\"%s\"", sdName(k, 32), sdEscape(event.Labels[k]))
+		}
+		sd.WriteString("]")
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s \ufeff%s",
+		s.facility*8+syslogSeverity(event),
+		time.UnixMilli(timestamp).UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		sdName(s.hostname, 255), sdName(s.cfg.AppName, 48), os.Getpid(), sdName(event.Name, 32), sd.String(), eventText(event))
+}
+
+// syslogSeverity maps the severity of an event to a syslog severity; resolutions are notices
+func syslogSeverity(event events.Event) int {
+	if event.State == events.StateResolved {
+		return 5
+	}
+	switch event.Severity {
+	case "critical":
+		return 2
+	case "warning":
+		return 4
+	case "info":
+		return 6
+	}
+	return 5
+}
+
+// sdName makes a header field or structured data name valid: printable ASCII without space,
+// '=', ']' or '"', at most maxLen characters
+func sdName(s string, maxLen int) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(name) > maxLen {
+		name = name[:maxLen]
+	}
+	if name == "" {
+		return "-"
+	}
+	return name
+}
+
+// sdEscape escapes a structured data parameter value
+func sdEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
@@ -0,0 +1,177 @@
+package notify
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
+)
+
+func testSyslogSender(t *testing.T, cfg config.SyslogConfig) *syslogSender {
+	t.Helper()
+	if cfg.Facility == "" {
+		cfg.Facility = "auth"
+	}
+	cfg.AppName, cfg.EnterpriseNumber = "su", 32473
+	s, err := newSyslogSender(cfg)
+	if err != nil {
+		t.Fatalf("newSyslogSender: %v", err)
+	}
+	s.hostname = "mymachine.example.com"
+	return s
+}
+
+// syslogEvent is the event of the first example of RFC 5424 (6.5): a critical message of
+// facility auth, logged at 2003-10-11T22:14:15.003Z with MSGID ID47
+var syslogEvent = events.Event{
+	ID:       "ev-1",
+	Source:   "rules",
+	Name:     "ID47",
+	Severity: "critical",
+	State:    events.StateFiring,
+	Labels:   map[string]string{"cluster": "c1"},
+	StartsAt: time.Date(2003, 10, 11, 22, 14, 15, 3e6, time.UTC).UnixMilli(),
+}
+
+func TestSyslogFormat(t *testing.T) {
+	s := testSyslogSender(t, config.SyslogConfig{})
+	want := "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su " + strconv.Itoa(os.Getpid()) + " ID47 " +
+		`[event@32473 id="ev-1" source="rules" state="firing" severity="critical"][labels@32473 cluster="c1"] ` +
+		"\xef\xbb\xbfID47 firing on cluster c1"
+	if got := s.format(syslogEvent); got != want {
+		t.Errorf("format =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestSyslogPriority(t *testing.T) {
+	tests := []struct {
+		facility string
+		severity string
+		state    string
+		want     string
+	}{
+		{"auth", "critical", events.StateFiring, "<34>"},
+		{"local0", "critical", events.StateFiring, "<130>"},
+		{"local0", "warning", events.StateFiring, "<132>"},
+		{"local0", "info", events.StateFiring, "<134>"},
+		{"local0", "", events.StateFiring, "<133>"},
+		{"local0", "critical", events.StateResolved, "<133>"},
+		{"kern", "critical", events.StateFiring, "<2>"},
+		{"LOCAL7", "info", events.StateFiring, "<190>"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s %s", tt.facility, tt.severity, tt.state), func(t *testing.T) {
+			event := syslogEvent
+			event.Severity, event.State = tt.severity, tt.state
+			got := testSyslogSender(t, config.SyslogConfig{Facility: tt.facility}).format(event)
+			if !strings.HasPrefix(got, tt.want+"1 ") {
+				t.Errorf("format = %q, want PRI %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslogFormatEscapes(t *testing.T) {
+	s := testSyslogSender(t, config.SyslogConfig{})
+	s.hostname = strings.Repeat("h", 300)
+	event := syslogEvent
+	event.Name = "Write Pressure=\"high\""
+	event.State = events.StateResolved
+	event.EndsAt = syslogEvent.StartsAt + 60000
+	event.Labels = map[string]string{"cluster": "c1", "node name": `es "data"\01]`}
+
+	got := s.format(event)
+	want := "<37>1 2003-10-11T22:15:15.003Z " + strings.Repeat("h", 255) + " su " + strconv.Itoa(os.Getpid()) + " Write_Pressure__high_ " +
+		`[event@32473 id="ev-1" source="rules" state="resolved" severity="critical"]` +
+		`[labels@32473 cluster="c1" node_name="es \"data\"\\01\]"] ` +
+		"\xef\xbb\xbfWrite Pressure=\"high\" resolved on cluster c1 (node name=es \"data\"\\01])"
+	if got != want {
+		t.Errorf("format =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestSyslogSendUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	s := testSyslogSender(t, config.SyslogConfig{Address: listener.LocalAddr().String(), Protocol: "udp"})
+	defer s.close()
+	if err := s.send(syslogEvent); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	buf := make([]byte, 2048)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := listener.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got, want := string(buf[:n]), s.format(syslogEvent); got != want {
+		t.Errorf("datagram = %q, want %q", got, want)
+	}
+}
+
+func TestSyslogSendTCPOctetCounting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		reader := bufio.NewReader(conn)
+		var frames []string
+		for len(frames) < 2 {
+			// MSG-LEN SP SYSLOG-MSG (RFC 6587 3.4.1)
+			count, err := reader.ReadString(' ')
+			if err != nil {
+				break
+			}
+			n, err := strconv.Atoi(strings.TrimSuffix(count, " "))
+			if err != nil {
+				break
+			}
+			frame := make([]byte, n)
+			if _, err := io.ReadFull(reader, frame); err != nil {
+				break
+			}
+			frames = append(frames, string(frame))
+		}
+		received <- frames
+	}()
+
+	s := testSyslogSender(t, config.SyslogConfig{Address: listener.Addr().String(), Protocol: "tcp"})
+	defer s.close()
+	annotated := syslogEvent
+	annotated.Annotations = map[string]string{"description": "heap above 90%"}
+	for _, event := range []events.Event{syslogEvent, annotated} {
+		if err := s.send(event); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	frames := <-received
+	want := []string{s.format(syslogEvent), s.format(annotated)}
+	if len(frames) != len(want) || frames[0] != want[0] || frames[1] != want[1] {
+		t.Errorf("frames = %q, want %q", frames, want)
+	}
+}
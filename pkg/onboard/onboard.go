@@ -0,0 +1,254 @@
+// Package onboard generates the configuration a new cluster needs: its rows of the master
+// CSV (loadFromMasterCSV), a credentials row with placeholders (updateAccessCredentials) and
+// the job parameters that must list the cluster for it to be monitored.
+//
+// The CSV rows come from text/template templates. The built-in templates match the columns of
+// data/clusters.csv and data/credentials.csv; sites with other columns (see inputMapping of
+// loadFromMasterCSV) put their own clusters.csv.tmpl and credentials.csv.tmpl into
+// onboarding.templateDir. A template file defines a "header" and a "row" template.
+package onboard
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/utils"
+)
+
+// Template file names in onboarding.templateDir
+const (
+	ClustersTemplate    = "clusters.csv.tmpl"
+	CredentialsTemplate = "credentials.csv.tmpl"
+)
+
+// Built-in templates, matching data/clusters.csv and data/credentials.csv
+const (
+	defaultClustersTemplate = `{{define "header"}}Cluster Name,API Endpoint,Kibana Endpoint,Owner,Node Name,IP Address,Zone,Data Center,Status,Environment,Node Type{{end}}
+{{define "row"}}{{csv .Cluster}},{{csv .Endpoints}},,{{csv .Owner}},{{csv .Host}},{{csv .IP}},{{csv .Zone}},{{csv .DataCenter}},active,{{csv .Environment}},{{csv .NodeType}}{{end}}`
+
+	defaultCredentialsTemplate = `{{define "header"}}ClusterName,PrefferedAccess,APIKey,UserID,Password,ClientCert,ClientKey,Cacert,ClusterPort,ApplicationLBs{{end}}
+{{define "row"}}{{csv .Cluster}},1,<API key of {{.Cluster}}>,,,,,,{{csv .Port}},{{end}}`
+)
+
+// Request describes a cluster to onboard
+type Request struct {
+	Cluster     string   `json:"cluster"`
+	Hosts       []string `json:"hosts"`                 // host names or IPs of the nodes
+	Owner       string   `json:"owner,omitempty"`       // owning team
+	Environment string   `json:"environment,omitempty"` // default production
+	Port        string   `json:"port,omitempty"`        // default 9200
+	NodeType    string   `json:"nodeType,omitempty"`    // roles of the nodes, default "master data"
+	Zone        string   `json:"zone,omitempty"`
+	DataCenter  string   `json:"dataCenter,omitempty"`
+}
+
+// JobEntry is a job parameter to change for the cluster to be monitored by the job
+type JobEntry struct {
+	Job       string   `json:"job"`
+	Parameter string   `json:"parameter"`
+	Value     []string `json:"value"` // the recommended value of the parameter
+	Reason    string   `json:"reason"`
+}
+
+// Result is the generated configuration of a cluster
+type Result struct {
+	Cluster        string     `json:"cluster"`
+	ClustersCSV    string     `json:"clustersCsv"`    // header and one row per host, for the master CSV
+	CredentialsCSV string     `json:"credentialsCsv"` // header and a row with placeholders, for the credentials CSV
+	JobEntries     []JobEntry `json:"jobEntries"`
+	Warnings       []string   `json:"warnings,omitempty"`
+}
+
+// templateData is what the templates are rendered with; Host and IP are those of the row's
+// node in the clusters template
+type templateData struct {
+	Request
+	Hosts     []string
+	Endpoints string // https://host:port of every host, separated by |
+	Host      string
+	IP        string // the host, if it is an IP address
+}
+
+// Generate renders the CSV rows of a cluster from the templates in templateDir ("" = built-in
+// templates) and lists the parameters of the given jobs that must change to include it.
+func Generate(req Request, templateDir string, jobs []*config.JobConfig) (*Result, error) {
+	req.Cluster = strings.TrimSpace(req.Cluster)
+	if req.Cluster == "" {
+		return nil, errors.New("cluster is required")
+	}
+	hosts := make([]string, 0, len(req.Hosts))
+	for _, host := range req.Hosts {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if utils.Contains(hosts, host) {
+			return nil, fmt.Errorf("host %s is given twice", host)
+		}
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("at least one host is required")
+	}
+	if req.Environment == "" {
+		req.Environment = "production"
+	}
+	if req.Port == "" {
+		req.Port = "9200"
+	}
+	if req.NodeType == "" {
+		req.NodeType = "master data"
+	}
+
+	endpoints := make([]string, len(hosts))
+	for i, host := range hosts {
+		endpoints[i] = "https://" + net.JoinHostPort(host, req.Port)
+	}
+	data := templateData{Request: req, Hosts: hosts, Endpoints: strings.Join(endpoints, "|")}
+
+	clustersTmpl, err := loadTemplate(templateDir, ClustersTemplate, defaultClustersTemplate)
+	if err != nil {
+		return nil, err
+	}
+	credentialsTmpl, err := loadTemplate(templateDir, CredentialsTemplate, defaultCredentialsTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]templateData, len(hosts))
+	for i, host := range hosts {
+		rows[i] = data
+		rows[i].Host = host
+		if net.ParseIP(host) != nil {
+			rows[i].IP = host
+		}
+	}
+	clustersCSV, err := renderCSV(clustersTmpl, ClustersTemplate, rows)
+	if err != nil {
+		return nil, err
+	}
+	credentialsCSV, err := renderCSV(credentialsTmpl, CredentialsTemplate, []templateData{data})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Cluster:        req.Cluster,
+		ClustersCSV:    clustersCSV,
+		CredentialsCSV: credentialsCSV,
+		JobEntries:     jobEntries(req.Cluster, jobs),
+	}, nil
+}
+
+// loadTemplate parses a template file of templateDir, or the built-in template when the
+// directory has none
+func loadTemplate(templateDir, name, builtIn string) (*template.Template, error) {
+	text := builtIn
+	if templateDir != "" {
+		content, err := os.ReadFile(filepath.Join(templateDir, name))
+		switch {
+		case err == nil:
+			text = string(content)
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+		}
+	}
+
+	tmpl, err := template.New(name).Funcs(template.FuncMap{"csv": csvField}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", name, err)
+	}
+	for _, defined := range []string{"header", "row"} {
+		if tmpl.Lookup(defined) == nil {
+			return nil, fmt.Errorf("invalid template %s: no %q template defined", name, defined)
+		}
+	}
+	return tmpl, nil
+}
+
+// renderCSV renders the header and a row per item, and checks that every row has the
+// columns of the header: loadFromMasterCSV skips rows that do not
+func renderCSV(tmpl *template.Template, name string, rows []templateData) (string, error) {
+	var out bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&out, "header", nil); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	out.WriteString("\n")
+	for _, row := range rows {
+		if err := tmpl.ExecuteTemplate(&out, "row", row); err != nil {
+			return "", fmt.Errorf("failed to render %s: %w", name, err)
+		}
+		out.WriteString("\n")
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(out.Bytes())).ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("%s renders invalid CSV: %w", name, err)
+	}
+	for i, record := range records[1:] {
+		if len(record) != len(records[0]) {
+			return "", fmt.Errorf("%s renders row %d with %d columns, the header has %d", name, i+1, len(record), len(records[0]))
+		}
+	}
+	return out.String(), nil
+}
+
+// csvField quotes a value for a CSV field when needed
+func csvField(value string) string {
+	if !strings.ContainsAny(value, ",\"\r\n") {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
+
+// jobEntries lists the cluster lists of the jobs that keep the cluster from being monitored:
+// non-empty includeClusters (and filterClusters of loadFromMasterCSV) without it, and
+// excludeClusters with it
+func jobEntries(clusterName string, jobs []*config.JobConfig) []JobEntry {
+	entries := make([]JobEntry, 0)
+	for _, job := range jobs {
+		if !job.Enabled {
+			continue
+		}
+		p := params.New(job.EffectiveParameters())
+
+		for _, parameter := range []string{"includeClusters", "filterClusters"} {
+			clusters := p.StringSlice(parameter)
+			if len(clusters) == 0 || utils.Contains(clusters, clusterName) {
+				continue
+			}
+			entries = append(entries, JobEntry{
+				Job:       job.Name,
+				Parameter: parameter,
+				Value:     append(append([]string{}, clusters...), clusterName),
+				Reason:    fmt.Sprintf("%s is set, so %s only covers the listed clusters", parameter, job.Name),
+			})
+		}
+
+		excluded := p.StringSlice("excludeClusters")
+		if utils.Contains(excluded, clusterName) {
+			kept := make([]string, 0, len(excluded))
+			for _, name := range excluded {
+				if name != clusterName {
+					kept = append(kept, name)
+				}
+			}
+			entries = append(entries, JobEntry{
+				Job:       job.Name,
+				Parameter: "excludeClusters",
+				Value:     kept,
+				Reason:    fmt.Sprintf("%s excludes the cluster", job.Name),
+			})
+		}
+	}
+	return entries
+}
@@ -0,0 +1,181 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/config"
+)
+
+// azureAPIVersion is the Blob service version requested
+const azureAPIVersion = "2021-08-06"
+
+// azureStore keeps artifacts in an Azure Blob container, authorized with a SAS token that
+// allows writing, listing and deleting blobs
+type azureStore struct {
+	containerURL string // account URL and container
+	sas          url.Values
+	prefix       string
+	client       *http.Client
+}
+
+func newAzureStore(cfg config.OutputConfig) (*azureStore, error) {
+	if cfg.AccountURL == "" || cfg.Container == "" {
+		return nil, fmt.Errorf("accountUrl and container are required")
+	}
+	if cfg.SASToken == "" {
+		return nil, fmt.Errorf("sasToken is required")
+	}
+	sas, err := url.ParseQuery(strings.TrimPrefix(cfg.SASToken, "?"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sasToken: %w", err)
+	}
+	return &azureStore{
+		containerURL: strings.TrimSuffix(cfg.AccountURL, "/") + "/" + url.PathEscape(cfg.Container),
+		sas:          sas,
+		prefix:       cfg.Path,
+		client:       &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *azureStore) Put(ctx context.Context, name string, data []byte) error {
+	resp, err := s.do(ctx, "PUT", joinKey(s.prefix, name), nil, data, map[string]string{"x-ms-blob-type": "BlockBlob"})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *azureStore) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := s.do(ctx, "GET", joinKey(s.prefix, name), nil, nil, nil)
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// enumerationResults is the part of the List Blobs response that is used
+type enumerationResults struct {
+	Blobs struct {
+		Blob []struct {
+			Name       string `xml:"Name"`
+			Properties struct {
+				LastModified  string `xml:"Last-Modified"`
+				ContentLength int64  `xml:"Content-Length"`
+			} `xml:"Properties"`
+		} `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (s *azureStore) List(ctx context.Context, folder string) ([]Object, error) {
+	blobPrefix := joinKey(s.prefix, folder)
+	if blobPrefix != "" {
+		blobPrefix += "/"
+	}
+
+	objects := make([]Object, 0)
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {blobPrefix}, "delimiter": {"/"}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		resp, err := s.do(ctx, "GET", "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result enumerationResults
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse blob listing: %w", err)
+		}
+
+		for _, blob := range result.Blobs.Blob {
+			modTime, _ := time.Parse(time.RFC1123, blob.Properties.LastModified)
+			objects = append(objects, Object{
+				Name:    strings.TrimPrefix(blob.Name, joinKey(s.prefix, "")+"/"),
+				Size:    blob.Properties.ContentLength,
+				ModTime: modTime,
+			})
+		}
+		if result.NextMarker == "" {
+			return objects, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+func (s *azureStore) Delete(ctx context.Context, name string) error {
+	resp, err := s.do(ctx, "DELETE", joinKey(s.prefix, name), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *azureStore) Location(name string) string {
+	return s.containerURL + "/" + joinKey(s.prefix, name)
+}
+
+// do sends a request for a blob ("" = the container) with the SAS token and returns the
+// response of a successful request
+func (s *azureStore) do(ctx context.Context, method, blob string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	target := s.containerURL
+	if blob != "" {
+		segments := strings.Split(blob, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		target += "/" + strings.Join(segments, "/")
+	}
+	values := url.Values{}
+	for name, value := range s.sas {
+		values[name] = value
+	}
+	for name, value := range query {
+		values[name] = value
+	}
+	target += "?" + values.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The error of the client carries the URL, SAS token included
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("%s %s/%s: %w", method, s.containerURL, blob, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &statusError{status: resp.StatusCode, message: strings.TrimSpace(string(message))}
+	}
+	return resp, nil
+}
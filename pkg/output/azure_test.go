@@ -0,0 +1,183 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ElasticObservability/pkg/config"
+)
+
+// testSASToken is a container SAS token in the form the portal generates it; its signature is
+// base64 and must reach the service as issued
+const testSASToken = "?sp=racwdl&st=2024-01-01T00:00:00Z&se=2030-01-01T00:00:00Z&spr=https&sv=2021-08-06&sr=c&sig=aB3%2Bc%2FdE%3D"
+
+// blobRequest is a request received by the fake Blob service
+type blobRequest struct {
+	method  string
+	path    string
+	query   map[string]string
+	headers http.Header
+	body    string
+}
+
+// fakeBlobService records the requests it receives and answers them with handle
+func fakeBlobService(t *testing.T, handle func(w http.ResponseWriter, r *http.Request)) (*azureStore, *[]blobRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []blobRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		query := make(map[string]string)
+		for name, values := range r.URL.Query() {
+			query[name] = strings.Join(values, ",")
+		}
+		mu.Lock()
+		requests = append(requests, blobRequest{method: r.Method, path: r.URL.EscapedPath(), query: query, headers: r.Header, body: string(body)})
+		mu.Unlock()
+		handle(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	s, err := newAzureStore(config.OutputConfig{AccountURL: server.URL + "/", Container: "eo-reports", SASToken: testSASToken, Path: "prod"})
+	if err != nil {
+		t.Fatalf("newAzureStore: %v", err)
+	}
+	return s, &requests
+}
+
+func TestAzurePut(t *testing.T) {
+	s, requests := fakeBlobService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	if err := s.Put(context.Background(), "daily/stats a+b.json", []byte(`{"a":1}`)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("requests = %+v, want 1", *requests)
+	}
+	req := (*requests)[0]
+	if req.method != "PUT" || req.path != "/eo-reports/prod/daily/stats%20a+b.json" {
+		t.Errorf("request = %s %s, want PUT /eo-reports/prod/daily/stats%%20a+b.json", req.method, req.path)
+	}
+	if req.query["sig"] != "aB3+c/dE=" || req.query["sp"] != "racwdl" || req.query["sv"] != "2021-08-06" || len(req.query) != 7 {
+		t.Errorf("query = %v, want the SAS token as issued", req.query)
+	}
+	if got := req.headers.Get("x-ms-blob-type"); got != "BlockBlob" {
+		t.Errorf("x-ms-blob-type = %q, want BlockBlob", got)
+	}
+	if got := req.headers.Get("x-ms-version"); got != azureAPIVersion {
+		t.Errorf("x-ms-version = %q, want %s", got, azureAPIVersion)
+	}
+	if req.body != `{"a":1}` || req.headers.Get("Content-Length") != "7" {
+		t.Errorf("body = %q (Content-Length %s), want the artifact", req.body, req.headers.Get("Content-Length"))
+	}
+}
+
+func TestAzureList(t *testing.T) {
+	// Pages of the List Blobs response in the documented shape, the second reached by NextMarker
+	pages := map[string]string{
+		"": `<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults ServiceEndpoint="https://myaccount.blob.core.windows.net/" ContainerName="eo-reports">
+  <Prefix>prod/daily/</Prefix>
+  <Delimiter>/</Delimiter>
+  <Blobs>
+    <Blob>
+      <Name>prod/daily/stats-1.json</Name>
+      <Properties>
+        <Last-Modified>Mon, 15 Jan 2024 10:30:00 GMT</Last-Modified>
+        <Content-Length>1234</Content-Length>
+        <BlobType>BlockBlob</BlobType>
+      </Properties>
+    </Blob>
+    <BlobPrefix>
+      <Name>prod/daily/archive/</Name>
+    </BlobPrefix>
+  </Blobs>
+  <NextMarker>2!84!cHJvZC9kYWlseS9zdGF0cy0xLmpzb24-</NextMarker>
+</EnumerationResults>`,
+		"2!84!cHJvZC9kYWlseS9zdGF0cy0xLmpzb24-": `<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults ServiceEndpoint="https://myaccount.blob.core.windows.net/" ContainerName="eo-reports">
+  <Blobs>
+    <Blob>
+      <Name>prod/daily/stats-2.json</Name>
+      <Properties>
+        <Last-Modified>Tue, 16 Jan 2024 10:30:00 GMT</Last-Modified>
+        <Content-Length>99</Content-Length>
+      </Properties>
+    </Blob>
+  </Blobs>
+  <NextMarker />
+</EnumerationResults>`,
+	}
+	s, requests := fakeBlobService(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, pages[r.URL.Query().Get("marker")])
+	})
+
+	objects, err := s.List(context.Background(), "daily")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := []Object{
+		{Name: "daily/stats-1.json", Size: 1234, ModTime: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)},
+		{Name: "daily/stats-2.json", Size: 99, ModTime: time.Date(2024, 1, 16, 10, 30, 0, 0, time.UTC)},
+	}
+	if len(objects) != len(want) {
+		t.Fatalf("List = %+v, want %+v", objects, want)
+	}
+	for i := range want {
+		if objects[i].Name != want[i].Name || objects[i].Size != want[i].Size || !objects[i].ModTime.Equal(want[i].ModTime) {
+			t.Errorf("objects[%d] = %+v, want %+v", i, objects[i], want[i])
+		}
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("requests = %d, want 2 pages", len(*requests))
+	}
+	first := (*requests)[0]
+	if first.path != "/eo-reports" || first.query["restype"] != "container" || first.query["comp"] != "list" ||
+		first.query["prefix"] != "prod/daily/" || first.query["delimiter"] != "/" || first.query["sig"] != "aB3+c/dE=" {
+		t.Errorf("first page request = %s %v", first.path, first.query)
+	}
+	if marker := (*requests)[1].query["marker"]; marker != "2!84!cHJvZC9kYWlseS9zdGF0cy0xLmpzb24-" {
+		t.Errorf("second page marker = %q", marker)
+	}
+}
+
+func TestAzureErrors(t *testing.T) {
+	s, _ := fakeBlobService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "BlobNotFound")
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "AuthenticationFailed")
+	})
+
+	if _, err := s.Get(context.Background(), "daily/missing.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing blob = %v, want ErrNotFound", err)
+	}
+	err := s.Delete(context.Background(), "daily/stats-1.json")
+	var status *statusError
+	if !errors.As(err, &status) || status.status != http.StatusForbidden {
+		t.Errorf("Delete = %v, want a 403 status error", err)
+	}
+
+	// The error of an unreachable service names the blob but not the SAS token
+	unreachable, err := newAzureStore(config.OutputConfig{AccountURL: "http://127.0.0.1:1", Container: "eo-reports", SASToken: testSASToken})
+	if err != nil {
+		t.Fatalf("newAzureStore: %v", err)
+	}
+	err = unreachable.Put(context.Background(), "stats.json", nil)
+	if err == nil || !strings.Contains(err.Error(), "PUT http://127.0.0.1:1/eo-reports/stats.json") || strings.Contains(err.Error(), "sig=") {
+		t.Errorf("Put to an unreachable service = %v, want the blob without the SAS token", err)
+	}
+}
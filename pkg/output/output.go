@@ -0,0 +1,296 @@
+// Package output writes the artifacts of report and dump jobs to the destinations configured
+// in the outputs section of config.yaml: a local directory, an S3 (or S3-compatible) bucket,
+// an Azure Blob container or a directory on an SFTP server.
+//
+// Artifact names are slash separated and relative to the destination, e.g.
+// "stateDump/20240115-103000.json". Every destination has its own retention: after each
+// write, the artifacts in the folder written to that are older than maxAge, or beyond the
+// newest maxFiles, are deleted. The destination "local" always exists and writes to out_dir
+// unless it is configured otherwise.
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/utils"
+)
+
+// DefaultDestination is the destination jobs write to unless told otherwise
+const DefaultDestination = "local"
+
+// ErrNotFound is returned by Read for an artifact a destination does not have
+var ErrNotFound = errors.New("artifact not found")
+
+// Object is an artifact stored at a destination
+type Object struct {
+	Name    string    `json:"name"` // relative to the destination
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// Store keeps the artifacts of a destination
+type Store interface {
+	// Put writes an artifact, replacing an existing one of the same name
+	Put(ctx context.Context, name string, data []byte) error
+	// Get reads an artifact, or returns ErrNotFound
+	Get(ctx context.Context, name string) ([]byte, error)
+	// List returns the artifacts directly in a folder ("" = the top folder)
+	List(ctx context.Context, folder string) ([]Object, error)
+	// Delete removes an artifact
+	Delete(ctx context.Context, name string) error
+	// Location describes where an artifact is stored, for logs
+	Location(name string) string
+}
+
+// destination is a configured store with its retention
+type destination struct {
+	name     string
+	kind     string
+	store    Store
+	maxAge   time.Duration
+	maxFiles int
+}
+
+var (
+	mu           sync.RWMutex
+	destinations = make(map[string]*destination)
+)
+
+// Configure creates the configured destinations and replaces the current ones. The
+// destination "local" writes to outDir unless one of that name is configured.
+func Configure(outDir string, configured []config.OutputConfig) error {
+	parsed := make(map[string]*destination, len(configured)+1)
+	parsed[DefaultDestination] = &destination{name: DefaultDestination, kind: "local", store: newLocalStore(outDir)}
+
+	seen := make(map[string]bool, len(configured))
+	for i, cfg := range configured {
+		if cfg.Name == "" {
+			return fmt.Errorf("outputs[%d]: name is required", i)
+		}
+		if seen[cfg.Name] {
+			return fmt.Errorf("outputs[%d]: duplicate name %q", i, cfg.Name)
+		}
+		seen[cfg.Name] = true
+
+		d, err := newDestination(cfg)
+		if err != nil {
+			return fmt.Errorf("outputs[%d] (%s): %w", i, cfg.Name, err)
+		}
+		parsed[cfg.Name] = d
+	}
+
+	mu.Lock()
+	destinations = parsed
+	mu.Unlock()
+	return nil
+}
+
+func newDestination(cfg config.OutputConfig) (*destination, error) {
+	d := &destination{name: cfg.Name, kind: cfg.Type, maxFiles: cfg.Retention.MaxFiles}
+	if cfg.Retention.MaxAge != "" {
+		maxAge, err := utils.ParseDuration(cfg.Retention.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("retention.maxAge: %w", err)
+		}
+		d.maxAge = maxAge
+	}
+	if d.maxFiles < 0 {
+		return nil, fmt.Errorf("retention.maxFiles must not be negative")
+	}
+
+	var err error
+	switch cfg.Type {
+	case "local":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("path is required")
+		}
+		d.store = newLocalStore(cfg.Path)
+	case "s3":
+		d.store, err = newS3Store(cfg)
+	case "azure":
+		d.store, err = newAzureStore(cfg)
+	case "sftp":
+		d.store, err = newSFTPStore(cfg)
+	default:
+		return nil, fmt.Errorf("unknown type %q (expected local, s3, azure or sftp)", cfg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Exists reports whether a destination is configured
+func Exists(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := destinations[name]
+	return ok
+}
+
+// Names returns the configured destinations, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(destinations))
+	for name := range destinations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Write stores an artifact at a destination and applies the retention of the destination to
+// the folder of the artifact. It returns where the artifact was stored. Retention failures
+// are logged, not returned: the artifact itself was written.
+func Write(ctx context.Context, destinationName, name string, data []byte) (string, error) {
+	mu.RLock()
+	d, ok := destinations[destinationName]
+	mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown output destination %q", destinationName)
+	}
+
+	name, err := cleanName(name)
+	if err != nil {
+		return "", err
+	}
+
+	if err := d.store.Put(ctx, name, data); err != nil {
+		metrics.OutputWritesTotal.WithLabelValues(d.name, "failure").Inc()
+		return "", fmt.Errorf("failed to write %s to %s: %w", name, d.name, err)
+	}
+	metrics.OutputWritesTotal.WithLabelValues(d.name, "success").Inc()
+	metrics.OutputBytesTotal.WithLabelValues(d.name).Add(float64(len(data)))
+
+	if d.maxAge > 0 || d.maxFiles > 0 {
+		if pruned, err := d.prune(ctx, path.Dir(name), time.Now()); err != nil {
+			logger.AppWarn("Output %s: retention of %s failed: %v", d.name, path.Dir(name), err)
+		} else if pruned > 0 {
+			logger.AppInfo("Output %s: retention deleted %d artifacts from %s", d.name, pruned, path.Dir(name))
+		}
+	}
+	return d.store.Location(name), nil
+}
+
+// Read returns an artifact of a destination, or an error wrapping ErrNotFound when the
+// destination does not have it
+func Read(ctx context.Context, destinationName, name string) ([]byte, error) {
+	mu.RLock()
+	d, ok := destinations[destinationName]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown output destination %q", destinationName)
+	}
+
+	name, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.store.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from %s: %w", name, d.name, err)
+	}
+	return data, nil
+}
+
+// Location describes where an artifact of a destination is stored, for logs
+func Location(destinationName, name string) string {
+	mu.RLock()
+	d, ok := destinations[destinationName]
+	mu.RUnlock()
+	if !ok {
+		return destinationName + ":" + name
+	}
+	return d.store.Location(name)
+}
+
+// prune deletes the artifacts of a folder beyond the retention of the destination and
+// returns how many were deleted
+func (d *destination) prune(ctx context.Context, folder string, now time.Time) (int, error) {
+	if folder == "." {
+		folder = ""
+	}
+	objects, err := d.store.List(ctx, folder)
+	if err != nil {
+		return 0, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		if !objects[i].ModTime.Equal(objects[j].ModTime) {
+			return objects[i].ModTime.After(objects[j].ModTime)
+		}
+		return objects[i].Name > objects[j].Name
+	})
+
+	pruned := 0
+	var errs []string
+	for i, object := range objects {
+		expired := d.maxAge > 0 && now.Sub(object.ModTime) > d.maxAge
+		excess := d.maxFiles > 0 && i >= d.maxFiles
+		if !expired && !excess {
+			continue
+		}
+		if err := d.store.Delete(ctx, object.Name); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", object.Name, err))
+			continue
+		}
+		pruned++
+	}
+	metrics.OutputPrunedTotal.WithLabelValues(d.name).Add(float64(pruned))
+
+	if len(errs) > 0 {
+		return pruned, fmt.Errorf("failed to delete %s", strings.Join(errs, "; "))
+	}
+	return pruned, nil
+}
+
+// statusError is an unsuccessful response of an HTTP store
+type statusError struct {
+	status  int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.status, e.message)
+}
+
+// isNotFound reports whether a store answered 404 Not Found
+func isNotFound(err error) bool {
+	var statusErr *statusError
+	return errors.As(err, &statusErr) && statusErr.status == 404
+}
+
+// cleanName validates an artifact name: relative, slash separated, without "..", and not
+// naming a folder
+func cleanName(name string) (string, error) {
+	cleaned := path.Clean(strings.TrimPrefix(name, "/"))
+	if name == "" || cleaned == "." || strings.HasSuffix(name, "/") ||
+		cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid artifact name %q", name)
+	}
+	return cleaned, nil
+}
+
+// joinKey prefixes an artifact name with the key prefix of a bucket or container
+func joinKey(prefix, name string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return name
+	}
+	if name == "" {
+		return prefix
+	}
+	return prefix + "/" + name
+}
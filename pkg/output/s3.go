@@ -0,0 +1,381 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/logger"
+)
+
+// s3Store keeps artifacts in an S3 bucket, or a bucket of an S3-compatible store such as
+// MinIO, with requests signed with AWS Signature Version 4. Artifacts can be encrypted at rest
+// (SSE-S3 or SSE-KMS), and the bucket can be versioned so overwritten and deleted artifacts
+// stay recoverable.
+type s3Store struct {
+	bucket       string
+	region       string
+	prefix       string
+	endpoint     string // scheme://host of the requests
+	pathStyle    bool   // bucket in the path (custom endpoints) instead of the host name
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	encryption   string // x-amz-server-side-encryption, "" = bucket default
+	kmsKeyID     string
+	versioning   bool
+	client       *http.Client
+
+	versioningMu      sync.Mutex
+	versioningEnsured bool
+}
+
+func newS3Store(cfg config.OutputConfig) (*s3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+	s := &s3Store{
+		bucket:       cfg.Bucket,
+		region:       cfg.Region,
+		prefix:       cfg.Path,
+		accessKey:    cfg.AccessKeyID,
+		secretKey:    cfg.SecretAccessKey,
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		encryption:   cfg.ServerSideEncryption,
+		kmsKeyID:     cfg.KMSKeyID,
+		versioning:   cfg.Versioning,
+		client:       &http.Client{Timeout: 5 * time.Minute},
+	}
+	switch s.encryption {
+	case "", "AES256":
+		if s.kmsKeyID != "" {
+			return nil, fmt.Errorf("kmsKeyId requires serverSideEncryption aws:kms")
+		}
+	case "aws:kms":
+	default:
+		return nil, fmt.Errorf("unknown serverSideEncryption %q (expected AES256 or aws:kms)", s.encryption)
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	if s.accessKey == "" && s.secretKey == "" {
+		s.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, fmt.Errorf("accessKeyId and secretAccessKey (or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY) are required")
+	}
+
+	if cfg.Endpoint != "" {
+		endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q", cfg.Endpoint)
+		}
+		s.endpoint = endpoint.Scheme + "://" + endpoint.Host
+		s.pathStyle = true
+	} else {
+		s.endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", s.bucket, s.region)
+	}
+	return s, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, name string, data []byte) error {
+	if s.versioning {
+		if err := s.ensureVersioning(ctx); err != nil {
+			return err
+		}
+	}
+
+	headers := map[string]string{}
+	if s.encryption != "" {
+		headers["X-Amz-Server-Side-Encryption"] = s.encryption
+	}
+	if s.kmsKeyID != "" {
+		headers["X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"] = s.kmsKeyID
+	}
+	resp, err := s.do(ctx, "PUT", joinKey(s.prefix, name), nil, data, headers)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3Store) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := s.do(ctx, "GET", joinKey(s.prefix, name), nil, nil, nil)
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// versioningConfiguration is the versioning state of a bucket
+type versioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	Status  string   `xml:"Status,omitempty"` // Enabled, Suspended or "" (never enabled)
+}
+
+// ensureVersioning enables versioning on the bucket unless it already is. It is checked once
+// per process; a failure is returned and checked again on the next write.
+func (s *s3Store) ensureVersioning(ctx context.Context) error {
+	s.versioningMu.Lock()
+	defer s.versioningMu.Unlock()
+	if s.versioningEnsured {
+		return nil
+	}
+
+	query := url.Values{"versioning": {""}}
+	resp, err := s.do(ctx, "GET", "", query, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get bucket versioning: %w", err)
+	}
+	var current versioningConfiguration
+	err = xml.NewDecoder(resp.Body).Decode(&current)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to parse bucket versioning: %w", err)
+	}
+
+	if current.Status != "Enabled" {
+		body, _ := xml.Marshal(versioningConfiguration{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/", Status: "Enabled"})
+		sum := md5.Sum(body)
+		resp, err := s.do(ctx, "PUT", "", query, body, map[string]string{"Content-MD5": base64.StdEncoding.EncodeToString(sum[:])})
+		if err != nil {
+			return fmt.Errorf("failed to enable bucket versioning: %w", err)
+		}
+		resp.Body.Close()
+		logger.AppInfo("Enabled versioning on bucket %s", s.bucket)
+	}
+	s.versioningEnsured = true
+	return nil
+}
+
+// listBucketResult is the part of the ListObjectsV2 response that is used
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3Store) List(ctx context.Context, folder string) ([]Object, error) {
+	keyPrefix := joinKey(s.prefix, folder)
+	if keyPrefix != "" {
+		keyPrefix += "/"
+	}
+
+	objects := make([]Object, 0)
+	continuation := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {keyPrefix}, "delimiter": {"/"}}
+		if continuation != "" {
+			query.Set("continuation-token", continuation)
+		}
+		resp, err := s.do(ctx, "GET", "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bucket listing: %w", err)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, Object{
+				Name:    strings.TrimPrefix(content.Key, joinKey(s.prefix, "")+"/"),
+				Size:    content.Size,
+				ModTime: content.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		continuation = result.NextContinuationToken
+	}
+}
+
+func (s *s3Store) Delete(ctx context.Context, name string) error {
+	resp, err := s.do(ctx, "DELETE", joinKey(s.prefix, name), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3Store) Location(name string) string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, joinKey(s.prefix, name))
+}
+
+// do sends a signed request for a key ("" = the bucket) and returns the response of a
+// successful request
+func (s *s3Store) do(ctx context.Context, method, key string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	objectPath := "/" + key
+	if s.pathStyle {
+		objectPath = "/" + s.bucket
+		if key != "" {
+			objectPath += "/" + key
+		}
+	}
+
+	target, _ := url.Parse(s.endpoint)
+	target.Path = objectPath
+	target.RawPath = s3EscapePath(objectPath)
+	target.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &statusError{status: resp.StatusCode, message: strings.TrimSpace(string(message))}
+	}
+	return resp, nil
+}
+
+// sign adds the AWS Signature Version 4 authorization of a request, signing the host, the
+// x-amz-* headers and the payload hash
+func (s *s3Store) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	signV4(req, hex.EncodeToString(payloadHash[:]), now, s.region, "s3", s.accessKey, s.secretKey)
+}
+
+// signV4 sets the X-Amz-Date and the Authorization of a request of a service, signing the
+// host and the x-amz-* headers. The path and query of the request must already be escaped
+// the way Signature Version 4 canonicalizes them (s3EscapePath, s3CanonicalQuery).
+func signV4(req *http.Request, payloadHash string, now time.Time, region, service, accessKey, secretKey string) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	signature := hex.EncodeToString(hmacSHA256(signingKeyV4(secretKey, day, region, service), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// signingKeyV4 derives the Signature Version 4 key of a day, region and service
+func signingKeyV4(secretKey, day, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes everything but the unreserved characters, as Signature Version 4
+// requires
+func s3Escape(s string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			escaped.WriteByte(c)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+// s3EscapePath escapes every segment of a path
+func s3EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery encodes query parameters sorted by name, as Signature Version 4 requires
+func s3CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,192 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"ElasticObservability/pkg/config"
+)
+
+// sftpStore keeps artifacts in a directory of an SFTP server. It runs the OpenSSH sftp
+// command in batch mode with key authentication, so host keys and keys are managed the way
+// operators already do for ssh (known_hosts, identity file).
+type sftpStore struct {
+	target       string // user@host
+	port         int
+	identityFile string
+	dir          string
+}
+
+func newSFTPStore(cfg config.OutputConfig) (*sftpStore, error) {
+	if cfg.Host == "" || cfg.User == "" {
+		return nil, fmt.Errorf("host and user are required")
+	}
+	// sftp runs ssh for the connection, so both must be installed
+	for _, command := range []string{"sftp", "ssh"} {
+		if _, err := exec.LookPath(command); err != nil {
+			return nil, fmt.Errorf("the %s command is required: %w", command, err)
+		}
+	}
+	s := &sftpStore{
+		target:       cfg.User + "@" + cfg.Host,
+		port:         cfg.Port,
+		identityFile: cfg.IdentityFile,
+		dir:          strings.TrimSuffix(cfg.Path, "/"),
+	}
+	if s.port == 0 {
+		s.port = 22
+	}
+	if s.dir == "" {
+		s.dir = "."
+	}
+	return s, nil
+}
+
+// Put uploads the artifact under a temporary name first, so readers never see a partial one.
+// Missing folders are created (the "-" prefix ignores the error of existing ones).
+func (s *sftpStore) Put(ctx context.Context, name string, data []byte) error {
+	local, err := os.CreateTemp("", "eobs-sftp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(local.Name())
+	if _, err := local.Write(data); err != nil {
+		local.Close()
+		return err
+	}
+	if err := local.Close(); err != nil {
+		return err
+	}
+
+	remote := s.remotePath(name)
+	var batch strings.Builder
+	if folder := path.Dir(name); folder != "." {
+		folders := strings.Split(folder, "/")
+		for i := range folders {
+			fmt.Fprintf(&batch, "-mkdir %s\n", sftpQuote(s.remotePath(path.Join(folders[:i+1]...))))
+		}
+	}
+	fmt.Fprintf(&batch, "put %s %s\n", sftpQuote(local.Name()), sftpQuote(remote+".tmp"))
+	fmt.Fprintf(&batch, "-rm %s\n", sftpQuote(remote))
+	fmt.Fprintf(&batch, "rename %s %s\n", sftpQuote(remote+".tmp"), sftpQuote(remote))
+	_, err = s.run(ctx, batch.String())
+	return err
+}
+
+// Get downloads the artifact to a temporary file. sftp reports a missing file only in its
+// message, which names the remote path as "not found".
+func (s *sftpStore) Get(ctx context.Context, name string) ([]byte, error) {
+	local, err := os.CreateTemp("", "eobs-sftp-*")
+	if err != nil {
+		return nil, err
+	}
+	local.Close()
+	defer os.Remove(local.Name())
+
+	_, err = s.run(ctx, fmt.Sprintf("get %s %s\n", sftpQuote(s.remotePath(name)), sftpQuote(local.Name())))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "No such file") {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return os.ReadFile(local.Name())
+}
+
+// List parses the long listing of the folder. sftp shows minutes for recent files and only the
+// day for files older than six months, which is precise enough for retention.
+func (s *sftpStore) List(ctx context.Context, folder string) ([]Object, error) {
+	out, err := s.run(ctx, fmt.Sprintf("-ls -ln %s\n", sftpQuote(s.remotePath(folder))))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	objects := make([]Object, 0)
+	for _, line := range strings.Split(out, "\n") {
+		// -rw-r--r--    1 1000     1000         1234 Jan 15 10:30 dir/name
+		fields := strings.Fields(line)
+		if len(fields) < 9 || !strings.HasPrefix(fields[0], "-") {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			continue
+		}
+		modTime, ok := parseSFTPTime(fields[5], fields[6], fields[7], now)
+		if !ok {
+			continue
+		}
+		fileName := path.Base(strings.Join(fields[8:], " "))
+		if strings.HasSuffix(fileName, ".tmp") {
+			continue
+		}
+		objects = append(objects, Object{Name: path.Join(folder, fileName), Size: size, ModTime: modTime})
+	}
+	return objects, nil
+}
+
+func (s *sftpStore) Delete(ctx context.Context, name string) error {
+	_, err := s.run(ctx, fmt.Sprintf("rm %s\n", sftpQuote(s.remotePath(name))))
+	return err
+}
+
+func (s *sftpStore) Location(name string) string {
+	return fmt.Sprintf("sftp://%s:%d/%s", s.target, s.port, strings.TrimPrefix(s.remotePath(name), "/"))
+}
+
+func (s *sftpStore) remotePath(name string) string {
+	if name == "" {
+		return s.dir
+	}
+	return s.dir + "/" + name
+}
+
+// run executes sftp commands in batch mode and returns their output. Batch mode stops at the
+// first failing command not prefixed with "-".
+func (s *sftpStore) run(ctx context.Context, batch string) (string, error) {
+	args := []string{"-b", "-", "-P", strconv.Itoa(s.port), "-o", "BatchMode=yes"}
+	if s.identityFile != "" {
+		args = append(args, "-i", s.identityFile)
+	}
+	args = append(args, s.target)
+
+	cmd := exec.CommandContext(ctx, "sftp", args...)
+	cmd.Stdin = strings.NewReader(batch)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("sftp %s: %w: %s", s.target, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// sftpQuote quotes a path for the sftp batch syntax
+func sftpQuote(p string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(p, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+// parseSFTPTime parses the time of a long listing: "Jan 15 10:30" within the last six months,
+// "Jan 15 2023" before
+func parseSFTPTime(month, day, clockOrYear string, now time.Time) (time.Time, bool) {
+	if strings.Contains(clockOrYear, ":") {
+		t, err := time.ParseInLocation("Jan 2 15:04 2006", fmt.Sprintf("%s %s %s %d", month, day, clockOrYear, now.Year()), time.Local)
+		if err != nil {
+			return time.Time{}, false
+		}
+		if t.After(now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t, true
+	}
+	t, err := time.ParseInLocation("Jan 2 2006", fmt.Sprintf("%s %s %s", month, day, clockOrYear), time.Local)
+	return t, err == nil
+}
@@ -0,0 +1,124 @@
+package output
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ElasticObservability/pkg/config"
+)
+
+// fakeSFTPPath makes PATH an empty directory with an ssh that does nothing and, with sftp set,
+// an sftp that records its arguments and batch in the directory and prints listing. The
+// scripts only use shell builtins.
+func fakeSFTPPath(t *testing.T, sftp bool, listing string) string {
+	t.Helper()
+	dir := t.TempDir()
+	write := func(name, content string, mode os.FileMode) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("ssh", "#!/bin/sh\nexit 0\n", 0o755)
+	if sftp {
+		write("listing", listing, 0o644)
+		write("sftp", `#!/bin/sh
+echo "$@" > "`+dir+`/args"
+while IFS= read -r line; do echo "$line"; done > "`+dir+`/batch"
+while IFS= read -r line; do echo "$line"; done < "`+dir+`/listing"
+`, 0o755)
+	}
+	t.Setenv("PATH", dir)
+	return dir
+}
+
+func testSFTPConfig() config.OutputConfig {
+	return config.OutputConfig{Name: "archive", Type: "sftp", Host: "backup.example.com", User: "eo", IdentityFile: "/keys/eo", Path: "/srv/eo/"}
+}
+
+func TestNewSFTPStoreRequiresCommands(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	err := Configure(t.TempDir(), []config.OutputConfig{testSFTPConfig()})
+	if err == nil || !strings.Contains(err.Error(), "the sftp command is required") {
+		t.Errorf("Configure without sftp = %v, want the sftp command required", err)
+	}
+
+	fakeSFTPPath(t, false, "")
+	if _, err := newSFTPStore(testSFTPConfig()); err == nil || !strings.Contains(err.Error(), "the sftp command is required") {
+		t.Errorf("newSFTPStore with only ssh = %v, want the sftp command required", err)
+	}
+
+	dir := fakeSFTPPath(t, true, "")
+	os.Remove(filepath.Join(dir, "ssh"))
+	if _, err := newSFTPStore(testSFTPConfig()); err == nil || !strings.Contains(err.Error(), "the ssh command is required") {
+		t.Errorf("newSFTPStore without ssh = %v, want the ssh command required", err)
+	}
+}
+
+func TestSFTPPutBatch(t *testing.T) {
+	dir := fakeSFTPPath(t, true, "")
+	s, err := newSFTPStore(testSFTPConfig())
+	if err != nil {
+		t.Fatalf("newSFTPStore: %v", err)
+	}
+	if err := s.Put(context.Background(), `daily/2024-01-01/stats "a".json`, []byte("{}")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if got, want := strings.TrimSpace(string(args)), "-b - -P 22 -o BatchMode=yes -i /keys/eo eo@backup.example.com"; got != want {
+		t.Errorf("args = %q, want %q", got, want)
+	}
+	batch, _ := os.ReadFile(filepath.Join(dir, "batch"))
+	lines := strings.Split(strings.TrimSpace(string(batch)), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("batch = %q, want 5 commands", batch)
+	}
+	want := []string{
+		`-mkdir "/srv/eo/daily"`,
+		`-mkdir "/srv/eo/daily/2024-01-01"`,
+		``, // put of the temporary local file
+		`-rm "/srv/eo/daily/2024-01-01/stats \"a\".json"`,
+		`rename "/srv/eo/daily/2024-01-01/stats \"a\".json.tmp" "/srv/eo/daily/2024-01-01/stats \"a\".json"`,
+	}
+	for i, line := range lines {
+		if i == 2 {
+			if !strings.HasPrefix(line, `put "`) || !strings.HasSuffix(line, `" "/srv/eo/daily/2024-01-01/stats \"a\".json.tmp"`) {
+				t.Errorf("batch line 3 = %q, want a put to the .tmp name", line)
+			}
+			continue
+		}
+		if line != want[i] {
+			t.Errorf("batch line %d = %q, want %q", i+1, line, want[i])
+		}
+	}
+}
+
+func TestSFTPList(t *testing.T) {
+	fakeSFTPPath(t, true, `sftp> -ls -ln "/srv/eo/daily"
+drwxr-xr-x    2 1000     1000         4096 Jan  2 10:30 /srv/eo/daily/old
+-rw-r--r--    1 1000     1000         1234 Jan 15 10:30 /srv/eo/daily/stats 1.json
+-rw-r--r--    1 1000     1000           99 Mar  3  2023 /srv/eo/daily/stats-2.json
+-rw-r--r--    1 1000     1000           10 Jan 15 10:31 /srv/eo/daily/stats-3.json.tmp
+`)
+	s, err := newSFTPStore(testSFTPConfig())
+	if err != nil {
+		t.Fatalf("newSFTPStore: %v", err)
+	}
+	objects, err := s.List(context.Background(), "daily")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("List = %+v, want the 2 complete files", objects)
+	}
+	if o := objects[0]; o.Name != "daily/stats 1.json" || o.Size != 1234 || o.ModTime.Month() != time.January || o.ModTime.Day() != 15 {
+		t.Errorf("objects[0] = %+v, want daily/stats 1.json of 1234 bytes from Jan 15", o)
+	}
+	if o := objects[1]; o.Name != "daily/stats-2.json" || !o.ModTime.Equal(time.Date(2023, 3, 3, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("objects[1] = %+v, want daily/stats-2.json from 2023-03-03", o)
+	}
+}
@@ -0,0 +1,238 @@
+// Package params provides typed access to job parameters.
+//
+// Job parameters come from YAML (ints, strings, lists, maps) or JSON (numbers are float64),
+// so every getter accepts the representations both decoders produce. Getters return the
+// default when a parameter is absent; a parameter that is present with the wrong type, a
+// missing required parameter or an unparsable duration is recorded and reported by Err,
+// so a job can validate all of its parameters in one place.
+package params
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"ElasticObservability/pkg/utils"
+)
+
+// Reader reads typed values from a parameter map
+type Reader struct {
+	values   map[string]interface{}
+	errs     []error
+	invalid  map[string]bool // keys with a type error
+	warnings []string
+}
+
+// New creates a reader over a parameter map (nil is treated as empty)
+func New(values map[string]interface{}) *Reader {
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	return &Reader{values: values, invalid: make(map[string]bool)}
+}
+
+// Err returns all recorded validation errors joined together, or nil
+func (r *Reader) Err() error {
+	return errors.Join(r.errs...)
+}
+
+// Warnings returns notes about values that were adjusted (e.g. clamped to a range)
+func (r *Reader) Warnings() []string {
+	return r.warnings
+}
+
+// Has reports whether a parameter is set (to any non-nil value)
+func (r *Reader) Has(key string) bool {
+	val, ok := r.values[key]
+	return ok && val != nil
+}
+
+// Raw returns the parameter as decoded, or nil if absent
+func (r *Reader) Raw(key string) interface{} {
+	return r.values[key]
+}
+
+func (r *Reader) typeError(key, expected string, val interface{}) {
+	r.invalid[key] = true
+	r.errs = append(r.errs, fmt.Errorf("%s parameter must be %s, got %T", key, expected, val))
+}
+
+// String returns a string parameter; an empty string counts as absent
+func (r *Reader) String(key, defaultVal string) string {
+	if !r.Has(key) {
+		return defaultVal
+	}
+	val, ok := r.values[key].(string)
+	if !ok {
+		r.typeError(key, "a string", r.values[key])
+		return defaultVal
+	}
+	if val == "" {
+		return defaultVal
+	}
+	return val
+}
+
+// RequiredString returns a string parameter and records an error if it is absent or empty
+func (r *Reader) RequiredString(key string) string {
+	val := r.String(key, "")
+	if val == "" && !r.invalid[key] {
+		r.errs = append(r.errs, fmt.Errorf("%s parameter is required", key))
+	}
+	return val
+}
+
+// OneOf returns a string parameter that must be one of the allowed values
+func (r *Reader) OneOf(key, defaultVal string, allowed ...string) string {
+	val := r.String(key, defaultVal)
+	for _, a := range allowed {
+		if val == a {
+			return val
+		}
+	}
+	r.errs = append(r.errs, fmt.Errorf("invalid %s value: %s (must be one of %q)", key, val, allowed))
+	return defaultVal
+}
+
+// Int returns an integer parameter
+func (r *Reader) Int(key string, defaultVal int) int {
+	if !r.Has(key) {
+		return defaultVal
+	}
+	switch val := r.values[key].(type) {
+	case int:
+		return val
+	case int64:
+		return int(val)
+	case uint64:
+		return int(val)
+	case float64:
+		if val == math.Trunc(val) {
+			return int(val)
+		}
+	}
+	r.typeError(key, "an integer", r.values[key])
+	return defaultVal
+}
+
+// IntInRange returns an integer parameter clamped to [min, max]. Clamping is noted in
+// Warnings, so the job can log it.
+func (r *Reader) IntInRange(key string, defaultVal, min, max int) int {
+	val := r.Int(key, defaultVal)
+	if val < min {
+		r.warnings = append(r.warnings, fmt.Sprintf("%s too small, using minimum value: %d", key, min))
+		return min
+	}
+	if val > max {
+		r.warnings = append(r.warnings, fmt.Sprintf("%s too large, using maximum value: %d", key, max))
+		return max
+	}
+	return val
+}
+
+// Float returns a numeric parameter
+func (r *Reader) Float(key string, defaultVal float64) float64 {
+	if !r.Has(key) {
+		return defaultVal
+	}
+	switch val := r.values[key].(type) {
+	case float64:
+		return val
+	case int:
+		return float64(val)
+	case int64:
+		return float64(val)
+	}
+	r.typeError(key, "a number", r.values[key])
+	return defaultVal
+}
+
+// Bool returns a boolean parameter
+func (r *Reader) Bool(key string, defaultVal bool) bool {
+	if !r.Has(key) {
+		return defaultVal
+	}
+	val, ok := r.values[key].(bool)
+	if !ok {
+		r.typeError(key, "a boolean", r.values[key])
+		return defaultVal
+	}
+	return val
+}
+
+// StringSlice returns a list of strings; empty entries are dropped
+func (r *Reader) StringSlice(key string) []string {
+	result := make([]string, 0)
+	if !r.Has(key) {
+		return result
+	}
+
+	switch val := r.values[key].(type) {
+	case []string:
+		for _, s := range val {
+			if s != "" {
+				result = append(result, s)
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			s, ok := item.(string)
+			if !ok {
+				r.typeError(key, "a list of strings", item)
+				continue
+			}
+			if s != "" {
+				result = append(result, s)
+			}
+		}
+	default:
+		r.typeError(key, "a list of strings", val)
+	}
+	return result
+}
+
+// RequiredStringSlice returns a non-empty list of strings and records an error otherwise
+func (r *Reader) RequiredStringSlice(key string) []string {
+	val := r.StringSlice(key)
+	if len(val) == 0 && !r.invalid[key] {
+		r.errs = append(r.errs, fmt.Errorf("%s parameter is required", key))
+	}
+	return val
+}
+
+// Map returns a nested map parameter (empty if absent)
+func (r *Reader) Map(key string) map[string]interface{} {
+	if !r.Has(key) {
+		return map[string]interface{}{}
+	}
+	val, ok := r.values[key].(map[string]interface{})
+	if !ok {
+		r.typeError(key, "a map", r.values[key])
+		return map[string]interface{}{}
+	}
+	return val
+}
+
+// RequiredMap returns a nested map parameter and records an error if it is absent
+func (r *Reader) RequiredMap(key string) map[string]interface{} {
+	if !r.Has(key) {
+		r.errs = append(r.errs, fmt.Errorf("%s parameter is required", key))
+		return map[string]interface{}{}
+	}
+	return r.Map(key)
+}
+
+// Duration returns a duration parameter such as "30s", "1h30m" or "2d" (see utils.ParseDuration)
+func (r *Reader) Duration(key string, defaultVal time.Duration) time.Duration {
+	s := r.String(key, "")
+	if s == "" {
+		return defaultVal
+	}
+	d, err := utils.ParseDuration(s)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s parameter is not a valid duration: %q", key, s))
+		return defaultVal
+	}
+	return d
+}
@@ -0,0 +1,211 @@
+package params
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]interface{}
+		want    int
+		wantErr bool
+	}{
+		{"missing key", map[string]interface{}{}, 7, false},
+		{"nil value", map[string]interface{}{"n": nil}, 7, false},
+		{"yaml int", map[string]interface{}{"n": 42}, 42, false},
+		{"int64", map[string]interface{}{"n": int64(42)}, 42, false},
+		{"uint64", map[string]interface{}{"n": uint64(42)}, 42, false},
+		{"json float64", map[string]interface{}{"n": float64(42)}, 42, false},
+		{"fractional float64", map[string]interface{}{"n": 4.5}, 7, true},
+		{"string", map[string]interface{}{"n": "42"}, 7, true},
+		{"bool", map[string]interface{}{"n": true}, 7, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.values)
+			if got := r.Int("n", 7); got != tt.want {
+				t.Errorf("Int = %d, want %d", got, tt.want)
+			}
+			if err := r.Err(); (err != nil) != tt.wantErr {
+				t.Errorf("Err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIntInRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      map[string]interface{}
+		want        int
+		wantErr     bool
+		wantWarning bool
+	}{
+		{"missing key", map[string]interface{}{}, 5, false, false},
+		{"in range", map[string]interface{}{"n": 10}, 10, false, false},
+		{"json float64 in range", map[string]interface{}{"n": float64(10)}, 10, false, false},
+		{"at minimum", map[string]interface{}{"n": 1}, 1, false, false},
+		{"at maximum", map[string]interface{}{"n": 20}, 20, false, false},
+		{"below minimum", map[string]interface{}{"n": 0}, 1, false, true},
+		{"above maximum", map[string]interface{}{"n": 100}, 20, false, true},
+		{"json float64 above maximum", map[string]interface{}{"n": float64(100)}, 20, false, true},
+		{"wrong type", map[string]interface{}{"n": "10"}, 5, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.values)
+			if got := r.IntInRange("n", 5, 1, 20); got != tt.want {
+				t.Errorf("IntInRange = %d, want %d", got, tt.want)
+			}
+			if err := r.Err(); (err != nil) != tt.wantErr {
+				t.Errorf("Err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := len(r.Warnings()) > 0; got != tt.wantWarning {
+				t.Errorf("Warnings = %q, want warning %v", r.Warnings(), tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestBool(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]interface{}
+		want    bool
+		wantErr bool
+	}{
+		{"missing key", map[string]interface{}{}, true, false},
+		{"false", map[string]interface{}{"b": false}, false, false},
+		{"true", map[string]interface{}{"b": true}, true, false},
+		{"string", map[string]interface{}{"b": "false"}, true, true},
+		{"number", map[string]interface{}{"b": float64(0)}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.values)
+			if got := r.Bool("b", true); got != tt.want {
+				t.Errorf("Bool = %v, want %v", got, tt.want)
+			}
+			if err := r.Err(); (err != nil) != tt.wantErr {
+				t.Errorf("Err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]interface{}
+		want    time.Duration
+		wantErr bool
+	}{
+		{"missing key", map[string]interface{}{}, time.Minute, false},
+		{"empty string", map[string]interface{}{"d": ""}, time.Minute, false},
+		{"seconds", map[string]interface{}{"d": "30s"}, 30 * time.Second, false},
+		{"compound", map[string]interface{}{"d": "1h30m"}, 90 * time.Minute, false},
+		{"days", map[string]interface{}{"d": "2d"}, 48 * time.Hour, false},
+		{"unparsable", map[string]interface{}{"d": "soon"}, time.Minute, true},
+		{"number", map[string]interface{}{"d": float64(30)}, time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.values)
+			if got := r.Duration("d", time.Minute); got != tt.want {
+				t.Errorf("Duration = %v, want %v", got, tt.want)
+			}
+			if err := r.Err(); (err != nil) != tt.wantErr {
+				t.Errorf("Err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStringSlice(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]interface{}
+		want    []string
+		wantErr bool
+	}{
+		{"missing key", map[string]interface{}{}, []string{}, false},
+		{"string slice", map[string]interface{}{"s": []string{"a", "", "b"}}, []string{"a", "b"}, false},
+		{"decoded list", map[string]interface{}{"s": []interface{}{"a", "", "b"}}, []string{"a", "b"}, false},
+		{"list with a number", map[string]interface{}{"s": []interface{}{"a", float64(1)}}, []string{"a"}, true},
+		{"string", map[string]interface{}{"s": "a"}, []string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.values)
+			if got := r.StringSlice("s"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StringSlice = %q, want %q", got, tt.want)
+			}
+			if err := r.Err(); (err != nil) != tt.wantErr {
+				t.Errorf("Err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMap(t *testing.T) {
+	nested := map[string]interface{}{"k": "v"}
+	tests := []struct {
+		name    string
+		values  map[string]interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{"missing key", map[string]interface{}{}, map[string]interface{}{}, false},
+		{"map", map[string]interface{}{"m": nested}, nested, false},
+		{"list", map[string]interface{}{"m": []interface{}{"k"}}, map[string]interface{}{}, true},
+		{"string", map[string]interface{}{"m": "k=v"}, map[string]interface{}{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.values)
+			if got := r.Map("m"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Map = %v, want %v", got, tt.want)
+			}
+			if err := r.Err(); (err != nil) != tt.wantErr {
+				t.Errorf("Err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHas(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		want   bool
+	}{
+		{"nil map", nil, false},
+		{"missing key", map[string]interface{}{}, false},
+		{"nil value", map[string]interface{}{"k": nil}, false},
+		{"zero value", map[string]interface{}{"k": 0}, true},
+		{"empty string", map[string]interface{}{"k": ""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.values).Has("k"); got != tt.want {
+				t.Errorf("Has = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequiredReportsMissingAndWrongTypeOnce(t *testing.T) {
+	r := New(map[string]interface{}{"wrong": float64(1)})
+	r.RequiredString("missing")
+	r.RequiredString("wrong")
+	r.RequiredMap("absent")
+	if err := r.Err(); err == nil {
+		t.Fatal("Err = nil, want errors")
+	}
+	if got := len(r.errs); got != 3 {
+		t.Errorf("recorded %d errors, want 3: %v", got, r.Err())
+	}
+}
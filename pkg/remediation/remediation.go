@@ -0,0 +1,696 @@
+// Package remediation runs pre-approved remediations: changes of index settings (a longer
+// refresh interval, fewer replicas, no default ingest pipeline) made when a matching event
+// fires or on request through the API, and rolled back automatically after a while.
+//
+// A run records the settings the indices had before, so its rollback restores them exactly.
+// Runs are persisted, so pending rollbacks survive restarts. Runs only log what they would
+// change while dry-run is on (the default), and the runs and rollbacks not requested through
+// the API, which audits itself, are recorded in the audit log.
+package remediation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/audit"
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/utils"
+)
+
+// Action types
+const (
+	TypeRefreshInterval = "refreshInterval"
+	TypeReplicas        = "replicas"
+	TypeDisablePipeline = "disablePipeline"
+)
+
+// Run states
+const (
+	StateDryRun     = "dryRun"     // nothing was changed
+	StateActive     = "active"     // settings changed, rollback pending
+	StateRolledBack = "rolledBack" // previous settings restored
+	StateFailed     = "failed"     // the change failed, nothing to roll back
+)
+
+const (
+	queueSize             = 100              // events waiting for the worker; further events are dropped
+	rollbackCheckInterval = 30 * time.Second // how often due rollbacks are run
+	requestTimeout        = time.Minute      // bounds a run or rollback started by the worker
+	maxRuns               = 500              // ended runs kept, newest first; active runs are always kept
+	systemPrincipal       = "system"         // principal of the audit entries of the worker
+)
+
+// Errors of Execute and Rollback
+var (
+	ErrDisabled       = errors.New("remediations are disabled")
+	ErrUnknownAction  = errors.New("remediation not found")
+	ErrClusterDenied  = errors.New("remediation is not allowed on this cluster")
+	ErrAlreadyActive  = errors.New("remediation is already active on this cluster")
+	ErrUnknownRun     = errors.New("remediation run not found")
+	ErrNotActive      = errors.New("remediation run is not active")
+	ErrTooManyIndices = errors.New("remediation matches more indices than allowed")
+)
+
+// Action is a configured remediation
+type Action struct {
+	Name          string                     `json:"name"`
+	Type          string                     `json:"type"`
+	Indices       string                     `json:"indices"`
+	Value         string                     `json:"value,omitempty"`
+	Pipeline      string                     `json:"pipeline,omitempty"`
+	On            *config.RemediationTrigger `json:"on,omitempty"`
+	Clusters      []string                   `json:"clusters"`
+	RollbackAfter string                     `json:"rollbackAfter"`
+	Cooldown      string                     `json:"cooldown"`
+	MaxIndices    int                        `json:"maxIndices"`
+	Roles         []string                   `json:"roles,omitempty"`
+	DryRun        bool                       `json:"dryRun"`
+
+	rollbackAfter time.Duration
+	cooldown      time.Duration
+}
+
+// Run is one execution of an action on a cluster
+type Run struct {
+	ID           string             `json:"id"`
+	Action       string             `json:"action"`
+	Cluster      string             `json:"cluster"`
+	Setting      string             `json:"setting"` // e.g. index.refresh_interval
+	Value        string             `json:"value"`
+	Previous     map[string]*string `json:"previous"`           // index -> setting before the run, null = not set
+	Restored     []string           `json:"restored,omitempty"` // indices already restored by a rollback that failed part way
+	TriggeredBy  string             `json:"triggeredBy"`        // principal, or "event <id>"
+	State        string             `json:"state"`
+	StartedAt    int64              `json:"startedAt"`            // epoch milliseconds (UTC)
+	RollbackAt   int64              `json:"rollbackAt,omitempty"` // epoch milliseconds (UTC), active runs
+	EndedAt      int64              `json:"endedAt,omitempty"`    // epoch milliseconds (UTC) rolled back
+	RolledBackBy string             `json:"rolledBackBy,omitempty"`
+	Error        string             `json:"error,omitempty"` // failed runs, or the last failed rollback attempt
+}
+
+// storeFile is the layout of the persisted runs
+type storeFile struct {
+	NextID int    `json:"nextId"`
+	Runs   []*Run `json:"runs"`
+}
+
+// worker runs the actions of queued events and the due rollbacks
+type worker struct {
+	queue chan events.Event
+	done  chan struct{}
+}
+
+var (
+	mu        sync.RWMutex
+	enabled   bool
+	actions   = make(map[string]*Action)
+	runs      = make(map[string]*Run)
+	busy      = make(map[string]bool) // action/cluster pairs and run IDs being changed
+	nextID    int
+	path      string // persistence file, "" = memory only
+	running   *worker
+	runningMu sync.RWMutex
+)
+
+// Configure sets the remediations from the configuration, loads the persisted runs (a missing
+// file has none) and, when enabled, starts running the actions of events and the due
+// rollbacks. A worker already running is stopped first.
+func Configure(cfg config.RemediationsConfig) error {
+	Stop()
+
+	parsed := make(map[string]*Action, len(cfg.Actions))
+	for _, configured := range cfg.Actions {
+		action, err := parseAction(configured, cfg.DryRun == nil || *cfg.DryRun)
+		if err != nil {
+			return fmt.Errorf("remediations.actions %s: %w", configured.Name, err)
+		}
+		parsed[action.Name] = action
+	}
+
+	mu.Lock()
+	enabled = cfg.Enabled
+	actions = parsed
+	runs = make(map[string]*Run)
+	nextID = 0
+	path = cfg.File
+	err := load()
+	updateMetrics()
+	mu.Unlock()
+	if err != nil || !cfg.Enabled {
+		return err
+	}
+
+	w := &worker{queue: make(chan events.Event, queueSize), done: make(chan struct{})}
+	go w.run()
+	runningMu.Lock()
+	running = w
+	runningMu.Unlock()
+	return nil
+}
+
+// parseAction validates the durations of a configured action and resolves its dry-run mode
+func parseAction(cfg config.RemediationAction, dryRun bool) (*Action, error) {
+	rollbackAfter, err := utils.ParseDuration(cfg.RollbackAfter)
+	if err != nil || rollbackAfter <= 0 {
+		return nil, fmt.Errorf("invalid rollbackAfter %q", cfg.RollbackAfter)
+	}
+	cooldown, err := utils.ParseDuration(cfg.Cooldown)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cooldown %q", cfg.Cooldown)
+	}
+	if cfg.DryRun != nil {
+		dryRun = *cfg.DryRun
+	}
+	return &Action{
+		Name:          cfg.Name,
+		Type:          cfg.Type,
+		Indices:       cfg.Indices,
+		Value:         cfg.Value,
+		Pipeline:      cfg.Pipeline,
+		On:            cfg.On,
+		Clusters:      cfg.Clusters,
+		RollbackAfter: cfg.RollbackAfter,
+		Cooldown:      cfg.Cooldown,
+		MaxIndices:    cfg.MaxIndices,
+		Roles:         cfg.Roles,
+		DryRun:        dryRun,
+		rollbackAfter: rollbackAfter,
+		cooldown:      cooldown,
+	}, nil
+}
+
+// Stop stops running the actions of events and the due rollbacks. Active runs stay persisted
+// and are rolled back once remediations run again.
+func Stop() {
+	runningMu.Lock()
+	w := running
+	running = nil
+	runningMu.Unlock()
+
+	if w != nil {
+		close(w.queue)
+		<-w.done
+	}
+}
+
+// Enabled reports whether remediations are enabled
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return enabled
+}
+
+// HandleEvent queues a firing event for the actions it triggers; it is subscribed to the event
+// store. Events suppressed by a maintenance window trigger nothing.
+func HandleEvent(event events.Event) {
+	if event.State != events.StateFiring || event.Suppressed {
+		return
+	}
+
+	runningMu.RLock()
+	defer runningMu.RUnlock()
+	if running == nil {
+		return
+	}
+	select {
+	case running.queue <- event:
+	default:
+		logger.AppWarn("Remediation queue full, dropped event %s (%s on cluster %s)", event.ID, event.Name, event.Cluster())
+	}
+}
+
+// run handles the queued events and the due rollbacks until the queue is closed
+func (w *worker) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(rollbackCheckInterval)
+	defer ticker.Stop()
+
+	w.rollbackDue()
+	for {
+		select {
+		case event, ok := <-w.queue:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case <-ticker.C:
+			w.rollbackDue()
+		}
+	}
+}
+
+// handle runs the actions triggered by an event that are out of their cooldown on its cluster
+func (w *worker) handle(event events.Event) {
+	clusterName := event.Cluster()
+	for _, action := range Actions() {
+		if !action.triggeredBy(event) || !action.allows(clusterName) {
+			continue
+		}
+		if last, ok := lastRun(action.Name, clusterName); ok && time.Since(time.UnixMilli(last)) < action.cooldown {
+			logger.AppInfo("Remediation %s not run on cluster %s for event %s: in cooldown", action.Name, clusterName, event.ID)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		run, err := Execute(ctx, action.Name, clusterName, "event "+event.ID, false)
+		cancel()
+		if errors.Is(err, ErrAlreadyActive) {
+			continue
+		}
+		recordAudit("remediationRun", action.Name, clusterName, run, err)
+	}
+}
+
+// rollbackDue rolls back the active runs whose rollback time has come
+func (w *worker) rollbackDue() {
+	now := utils.TimeNowMillis()
+	due := make([]string, 0)
+	mu.RLock()
+	for id, run := range runs {
+		if run.State == StateActive && run.RollbackAt <= now {
+			due = append(due, id)
+		}
+	}
+	mu.RUnlock()
+	sort.Strings(due)
+
+	for _, id := range due {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		run, err := Rollback(ctx, id, systemPrincipal)
+		cancel()
+		if errors.Is(err, ErrNotActive) {
+			continue
+		}
+		recordAudit("remediationRollback", run.Action, run.Cluster, run, err)
+	}
+}
+
+// recordAudit records a run or rollback made by the worker in the audit log
+func recordAudit(auditAction, actionName, clusterName string, run Run, err error) {
+	entry := audit.Entry{
+		Time:      utils.TimeNowMillis(),
+		Principal: systemPrincipal,
+		Action:    auditAction,
+		Target:    actionName,
+		Parameters: map[string]string{
+			"cluster":     clusterName,
+			"run":         run.ID,
+			"state":       run.State,
+			"indices":     strconv.Itoa(len(run.Previous)),
+			"triggeredBy": run.TriggeredBy,
+		},
+		Result: audit.ResultSuccess,
+	}
+	if err != nil {
+		entry.Result = audit.ResultFailure
+		entry.Error = err.Error()
+	}
+	if err := audit.Record(entry); err != nil {
+		logger.AppError("Failed to record audit entry for remediation %s on cluster %s: %v", actionName, clusterName, err)
+	}
+}
+
+// triggeredBy reports whether a firing event triggers the action
+func (a *Action) triggeredBy(event events.Event) bool {
+	return a.On != nil && a.On.Source == event.Source &&
+		(a.On.Name == "" || a.On.Name == event.Name) &&
+		(len(a.On.Severities) == 0 || utils.Contains(a.On.Severities, event.Severity))
+}
+
+// allows reports whether the action may run on a cluster
+func (a *Action) allows(clusterName string) bool {
+	return clusterName != "" && (utils.Contains(a.Clusters, "*") || utils.Contains(a.Clusters, clusterName))
+}
+
+// Actions returns the configured actions sorted by name
+func Actions() []Action {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Action, 0, len(actions))
+	for _, action := range actions {
+		list = append(list, *action)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// GetAction returns a configured action by name
+func GetAction(name string) (Action, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	action, ok := actions[name]
+	if !ok {
+		return Action{}, false
+	}
+	return *action, true
+}
+
+// Runs returns the runs of a cluster ("" = all), newest first
+func Runs(clusterName string) []Run {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Run, 0, len(runs))
+	for _, run := range runs {
+		if clusterName == "" || run.Cluster == clusterName {
+			list = append(list, *run)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(&list[i], &list[j]) })
+	return list
+}
+
+// GetRun returns a run by ID
+func GetRun(id string) (Run, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	run, ok := runs[id]
+	if !ok {
+		return Run{}, false
+	}
+	return *run, true
+}
+
+// newer orders runs newest first
+func newer(a, b *Run) bool {
+	if a.StartedAt != b.StartedAt {
+		return a.StartedAt > b.StartedAt
+	}
+	ai, _ := strconv.Atoi(a.ID)
+	bi, _ := strconv.Atoi(b.ID)
+	return ai > bi
+}
+
+// lastRun returns when an action last ran on a cluster, dry runs included
+func lastRun(actionName, clusterName string) (int64, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	var last int64
+	found := false
+	for _, run := range runs {
+		if run.Action == actionName && run.Cluster == clusterName && run.StartedAt >= last {
+			last, found = run.StartedAt, true
+		}
+	}
+	return last, found
+}
+
+// Execute runs an action on a cluster: it reads the setting of the matching indices and, unless
+// the run is a dry run (requested, or configured for the action), changes the indices that need
+// it and schedules the rollback. The run is returned with the error of a failed change.
+func Execute(ctx context.Context, actionName, clusterName, triggeredBy string, dryRun bool) (Run, error) {
+	mu.Lock()
+	if !enabled {
+		mu.Unlock()
+		return Run{}, ErrDisabled
+	}
+	action, ok := actions[actionName]
+	if !ok {
+		mu.Unlock()
+		return Run{}, ErrUnknownAction
+	}
+	if !action.allows(clusterName) {
+		mu.Unlock()
+		return Run{}, ErrClusterDenied
+	}
+	key := actionName + "/" + clusterName
+	if busy[key] || activeRun(actionName, clusterName) != nil {
+		mu.Unlock()
+		return Run{}, ErrAlreadyActive
+	}
+	busy[key] = true
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		delete(busy, key)
+		mu.Unlock()
+	}()
+
+	change := changeOf(action)
+	run := &Run{
+		Action:      action.Name,
+		Cluster:     clusterName,
+		Setting:     change.setting,
+		Value:       change.value,
+		TriggeredBy: triggeredBy,
+		StartedAt:   utils.TimeNowMillis(),
+	}
+
+	previous, err := change.matching(ctx, clusterName, action.Indices)
+	if err == nil && len(previous) > action.MaxIndices {
+		err = fmt.Errorf("%w: %d indices, at most %d", ErrTooManyIndices, len(previous), action.MaxIndices)
+	}
+	run.Previous = previous
+	switch {
+	case err != nil:
+		run.State = StateFailed
+	case dryRun || action.DryRun:
+		run.State = StateDryRun
+	case len(previous) == 0:
+		run.State = StateRolledBack // nothing needed the change, so there is nothing to roll back
+		run.EndedAt = run.StartedAt
+	default:
+		if err = putSetting(ctx, clusterName, indexNames(previous), change.setting, &change.value); err != nil {
+			run.State = StateFailed
+		} else {
+			run.State = StateActive
+			run.RollbackAt = run.StartedAt + action.rollbackAfter.Milliseconds()
+		}
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	result := run.State
+	if run.State == StateActive || run.State == StateRolledBack {
+		result = "applied"
+	}
+	metrics.RemediationRunsTotal.WithLabelValues(action.Name, result).Inc()
+	logRun(run, action)
+
+	mu.Lock()
+	defer mu.Unlock()
+	nextID++
+	run.ID = strconv.Itoa(nextID)
+	runs[run.ID] = run
+	prune()
+	updateMetrics()
+	if saveErr := save(); saveErr != nil {
+		logger.AppError("Failed to save remediation runs: %v", saveErr)
+	}
+	return *run, err
+}
+
+// logRun logs the outcome of a run
+func logRun(run *Run, action *Action) {
+	indices := strings.Join(indexNames(run.Previous), ",")
+	switch run.State {
+	case StateFailed:
+		logger.AppError("Remediation %s on cluster %s (%s) failed: %s", action.Name, run.Cluster, run.TriggeredBy, run.Error)
+	case StateDryRun:
+		logger.AppInfo("Remediation %s on cluster %s (%s) dry run: would set %s to %s on %d indices [%s], rolled back after %s",
+			action.Name, run.Cluster, run.TriggeredBy, run.Setting, run.Value, len(run.Previous), indices, action.RollbackAfter)
+	case StateActive:
+		logger.AppWarn("Remediation %s on cluster %s (%s): set %s to %s on %d indices [%s], rolled back after %s",
+			action.Name, run.Cluster, run.TriggeredBy, run.Setting, run.Value, len(run.Previous), indices, action.RollbackAfter)
+	default:
+		logger.AppInfo("Remediation %s on cluster %s (%s): no index of %s needs the change", action.Name, run.Cluster, run.TriggeredBy, action.Indices)
+	}
+}
+
+// activeRun returns the active run of an action on a cluster, or nil; callers hold mu
+func activeRun(actionName, clusterName string) *Run {
+	for _, run := range runs {
+		if run.Action == actionName && run.Cluster == clusterName && run.State == StateActive {
+			return run
+		}
+	}
+	return nil
+}
+
+// Rollback restores the settings an active run changed. When a request fails the run stays
+// active, and the next attempt skips the indices already restored.
+func Rollback(ctx context.Context, id, by string) (Run, error) {
+	mu.Lock()
+	run, ok := runs[id]
+	if !ok {
+		mu.Unlock()
+		return Run{}, ErrUnknownRun
+	}
+	if run.State != StateActive || busy[id] {
+		mu.Unlock()
+		return *run, ErrNotActive
+	}
+	busy[id] = true
+	snapshot := *run
+	snapshot.Restored = append([]string(nil), run.Restored...)
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		delete(busy, id)
+		mu.Unlock()
+	}()
+
+	restored, err := restore(ctx, &snapshot)
+
+	mu.Lock()
+	defer mu.Unlock()
+	run.Restored = append(run.Restored, restored...)
+	if err != nil {
+		run.Error = err.Error()
+		metrics.RemediationRollbacksTotal.WithLabelValues(run.Action, "failure").Inc()
+		logger.AppError("Rollback of remediation %s (run %s) on cluster %s failed: %v", run.Action, run.ID, run.Cluster, err)
+	} else {
+		run.State = StateRolledBack
+		run.EndedAt = utils.TimeNowMillis()
+		run.RolledBackBy = by
+		run.Restored = nil
+		run.Error = ""
+		metrics.RemediationRollbacksTotal.WithLabelValues(run.Action, "success").Inc()
+		logger.AppInfo("Rolled back remediation %s (run %s) on cluster %s: restored %s on %d indices",
+			run.Action, run.ID, run.Cluster, run.Setting, len(snapshot.Previous))
+	}
+	updateMetrics()
+	if saveErr := save(); saveErr != nil {
+		logger.AppError("Failed to save remediation runs: %v", saveErr)
+	}
+	return *run, err
+}
+
+// restore sets the indices of a run not restored yet back to their previous values, one
+// request per previous value, and returns the indices restored
+func restore(ctx context.Context, run *Run) ([]string, error) {
+	groups := make(map[string][]string) // previous value ("" = not set) -> indices
+	for index, value := range run.Previous {
+		if utils.Contains(run.Restored, index) {
+			continue
+		}
+		key := ""
+		if value != nil {
+			key = "=" + *value
+		}
+		groups[key] = append(groups[key], index)
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	restored := make([]string, 0, len(run.Previous))
+	for _, key := range keys {
+		var value *string
+		if previous, ok := strings.CutPrefix(key, "="); ok {
+			value = &previous
+		}
+		sort.Strings(groups[key])
+		if err := putSetting(ctx, run.Cluster, groups[key], run.Setting, value); err != nil {
+			return restored, err
+		}
+		restored = append(restored, groups[key]...)
+	}
+	return restored, nil
+}
+
+// indexNames returns the sorted indices of a run
+func indexNames(previous map[string]*string) []string {
+	names := make([]string, 0, len(previous))
+	for index := range previous {
+		names = append(names, index)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prune drops the oldest ended runs beyond maxRuns; callers hold mu
+func prune() {
+	ended := make([]*Run, 0, len(runs))
+	for _, run := range runs {
+		if run.State != StateActive {
+			ended = append(ended, run)
+		}
+	}
+	if len(ended) <= maxRuns {
+		return
+	}
+	sort.Slice(ended, func(i, j int) bool { return newer(ended[i], ended[j]) })
+	for _, run := range ended[maxRuns:] {
+		delete(runs, run.ID)
+	}
+}
+
+// load reads the persisted runs; callers hold mu
+func load() error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read remediation runs: %w", err)
+	}
+
+	var stored storeFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to parse remediation runs %s: %w", path, err)
+	}
+	nextID = stored.NextID
+	for _, run := range stored.Runs {
+		runs[run.ID] = run
+	}
+	return nil
+}
+
+// save writes the runs to their file; callers hold mu
+func save() error {
+	if path == "" {
+		return nil
+	}
+
+	stored := storeFile{NextID: nextID, Runs: make([]*Run, 0, len(runs))}
+	for _, run := range runs {
+		stored.Runs = append(stored.Runs, run)
+	}
+	sort.Slice(stored.Runs, func(i, j int) bool { return newer(stored.Runs[i], stored.Runs[j]) })
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal remediation runs: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create remediation runs directory: %w", err)
+	}
+
+	// Write and rename, so a crash never leaves a truncated file behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write remediation runs: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write remediation runs: %w", err)
+	}
+	return nil
+}
+
+// updateMetrics refreshes the active remediations gauge; callers hold mu
+func updateMetrics() {
+	metrics.RemediationsActive.Reset()
+	for _, run := range runs {
+		if run.State == StateActive {
+			metrics.RemediationsActive.WithLabelValues(run.Action, run.Cluster).Inc()
+		}
+	}
+}
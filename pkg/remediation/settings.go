@@ -0,0 +1,160 @@
+package remediation
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/types"
+	"ElasticObservability/pkg/utils"
+)
+
+// noPipeline is the default_pipeline value that disables the default ingest pipeline
+const noPipeline = "_none"
+
+// change is the index setting an action sets, and which indices need it
+type change struct {
+	setting string
+	value   string
+	needs   func(current *string) bool // whether an index with the current setting (nil = not set) is changed
+}
+
+// changeOf returns the setting change of an action
+func changeOf(action *Action) change {
+	switch action.Type {
+	case TypeReplicas:
+		target, _ := strconv.Atoi(action.Value)
+		return change{setting: "index.number_of_replicas", value: action.Value, needs: func(current *string) bool {
+			// Only ever reduces the replicas
+			replicas, err := strconv.Atoi(deref(current))
+			return err == nil && replicas > target
+		}}
+	case TypeDisablePipeline:
+		return change{setting: "index.default_pipeline", value: noPipeline, needs: func(current *string) bool {
+			return deref(current) == action.Pipeline
+		}}
+	default:
+		return change{setting: "index.refresh_interval", value: action.Value, needs: func(current *string) bool {
+			return deref(current) != action.Value
+		}}
+	}
+}
+
+// deref returns the value of an optional setting, "" when not set
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// matching returns the open indices matching a pattern that need the change, with their
+// current setting (nil = not set)
+func (c change) matching(ctx context.Context, clusterName, pattern string) (map[string]*string, error) {
+	query := url.Values{
+		"flat_settings":      {"true"},
+		"expand_wildcards":   {"open"},
+		"ignore_unavailable": {"true"},
+		"allow_no_indices":   {"true"},
+	}
+	body, err := request(ctx, clusterName, http.MethodGet, escapeIndices(pattern)+"/_settings/"+c.setting+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]struct {
+		Settings map[string]string `json:"settings"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode index settings: %w", err)
+	}
+	matching := make(map[string]*string)
+	for index, settings := range response {
+		var current *string
+		if value, ok := settings.Settings[c.setting]; ok {
+			current = &value
+		}
+		if c.needs(current) {
+			matching[index] = current
+		}
+	}
+	return matching, nil
+}
+
+// putSetting sets a setting of indices; nil resets it to its default
+func putSetting(ctx context.Context, clusterName string, indices []string, setting string, value *string) error {
+	body, err := json.Marshal(map[string]*string{setting: value})
+	if err != nil {
+		return fmt.Errorf("failed to marshal settings: %w", err)
+	}
+	_, err = request(ctx, clusterName, http.MethodPut, escapeIndices(strings.Join(indices, ","))+"/_settings", body)
+	return err
+}
+
+// escapeIndices escapes an index list or pattern for a request path
+func escapeIndices(indices string) string {
+	return strings.ReplaceAll(url.PathEscape(indices), "%2C", ",")
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[bool]*http.Client) // key: insecure TLS
+)
+
+// httpClient returns the shared client for a TLS setting
+func httpClient(insecureTLS bool) *http.Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if client, ok := clients[insecureTLS]; ok {
+		return client
+	}
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureTLS},
+		},
+	}
+	clients[insecureTLS] = client
+	return client
+}
+
+// request sends a request to the active endpoint of a cluster and returns the response body
+func request(ctx context.Context, clusterName, method, path string, body []byte) ([]byte, error) {
+	cluster, ok := types.GetCluster(clusterName)
+	if !ok || cluster.ActiveEndpoint == "" {
+		return nil, fmt.Errorf("cluster %s not found or has no active endpoint", clusterName)
+	}
+
+	endpoint := strings.TrimSuffix(cluster.ActiveEndpoint, "/") + "/" + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	utils.AddAuthentication(req, &cluster.AccessCred)
+
+	resp, err := httpClient(cluster.InsecureTLS).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16*1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s returned status %d: %s", method, strings.SplitN(path, "?", 2)[0], resp.StatusCode, strings.TrimSpace(string(data[:min(len(data), 512)])))
+	}
+	return data, nil
+}
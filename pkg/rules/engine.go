@@ -0,0 +1,156 @@
+package rules
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/maintenance"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/types"
+)
+
+// Alert states
+const (
+	StatePending  = "pending"  // condition holds, "for" duration not reached yet
+	StateFiring   = "firing"   // condition has held for the "for" duration
+	StateResolved = "resolved" // condition no longer holds (only reported in Result)
+)
+
+// Alert is a rule whose condition holds for one entity
+type Alert struct {
+	Rule          string            `json:"rule"`
+	Severity      string            `json:"severity"`
+	State         string            `json:"state"`
+	Labels        map[string]string `json:"labels"` // series labels plus the rule's labels
+	Annotations   map[string]string `json:"annotations,omitempty"`
+	Value         float64           `json:"value"`
+	ActiveSince   int64             `json:"activeSince"`           // epoch milliseconds (UTC) the condition started to hold
+	FiringSince   int64             `json:"firingSince,omitempty"` // epoch milliseconds (UTC), 0 while pending
+	ResolvedAt    int64             `json:"resolvedAt,omitempty"`  // epoch milliseconds (UTC), resolved alerts only
+	LastEvaluated int64             `json:"lastEvaluated"`         // epoch milliseconds (UTC)
+	Suppressed    bool              `json:"suppressed,omitempty"`  // cluster in a maintenance window
+}
+
+// Cluster returns the cluster the alert is about
+func (a *Alert) Cluster() string {
+	return a.Labels["cluster"]
+}
+
+// Result lists the alerts that changed state in one evaluation
+type Result struct {
+	Fired    []Alert // pending -> firing (or firing directly without "for")
+	Resolved []Alert // firing -> resolved
+	Pending  int
+	Firing   int
+}
+
+var (
+	mu     sync.RWMutex
+	active = make(map[string]*Alert) // key: rule name + label fingerprint
+)
+
+// Evaluate evaluates the rules at now, updates the active alerts and returns the changes.
+// Alerts of rules that are no longer defined are resolved.
+func Evaluate(list []*Rule, now time.Time) Result {
+	nowMs := now.UnixMilli()
+	clusters := types.ClusterNames()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var result Result
+	seen := make(map[string]bool)
+
+	for _, rule := range list {
+		for _, m := range rule.matches(now, clusters) {
+			labels := make(map[string]string, len(m.labels)+len(rule.Labels))
+			for k, v := range rule.Labels {
+				labels[k] = v
+			}
+			for k, v := range m.labels {
+				labels[k] = v
+			}
+
+			key := rule.Name + "|" + fingerprint(m.labels)
+			seen[key] = true
+
+			alert, exists := active[key]
+			if !exists {
+				alert = &Alert{Rule: rule.Name, State: StatePending, ActiveSince: nowMs}
+				active[key] = alert
+			}
+			alert.Severity = rule.Severity
+			alert.Labels = labels
+			alert.Value = m.value
+			alert.LastEvaluated = nowMs
+			alert.Annotations = make(map[string]string, len(rule.Annotations))
+			for k, text := range rule.Annotations {
+				alert.Annotations[k] = expand(text, labels, m.value)
+			}
+			for k, text := range rule.metadata() {
+				if text != "" {
+					alert.Annotations[k] = expand(text, labels, m.value)
+				}
+			}
+			_, alert.Suppressed = maintenance.InMaintenance(alert.Cluster(), now)
+			if _, decommissioning := maintenance.Decommissioning(alert.Cluster(), alert.Labels["host"]); decommissioning {
+				alert.Suppressed = true
+			}
+
+			if alert.State == StatePending && nowMs-alert.ActiveSince >= rule.forDuration.Milliseconds() {
+				alert.State = StateFiring
+				alert.FiringSince = nowMs
+				result.Fired = append(result.Fired, *alert)
+			}
+		}
+	}
+
+	// Alerts whose condition no longer holds
+	for key, alert := range active {
+		if seen[key] {
+			continue
+		}
+		if alert.State == StateFiring {
+			resolved := *alert
+			resolved.State = StateResolved
+			resolved.ResolvedAt = nowMs
+			result.Resolved = append(result.Resolved, resolved)
+		}
+		delete(active, key)
+	}
+
+	firing := make(map[[2]string]float64)
+	for _, alert := range active {
+		if alert.State == StateFiring {
+			result.Firing++
+			firing[[2]string{alert.Rule, alert.Severity}]++
+		} else {
+			result.Pending++
+		}
+	}
+	metrics.AlertsFiring.Reset()
+	for k, count := range firing {
+		metrics.AlertsFiring.WithLabelValues(k[0], k[1]).Set(count)
+	}
+
+	return result
+}
+
+// Alerts returns copies of the active (pending and firing) alerts, sorted by rule and labels
+func Alerts() []Alert {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	keys := make([]string, 0, len(active))
+	for key := range active {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]Alert, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, *active[key])
+	}
+	return result
+}
@@ -0,0 +1,277 @@
+// Package rules evaluates alert rules over the collected series (see series.go).
+//
+// Rules are defined in YAML and evaluated by the evaluateRules job. A rule compares a
+// series against a threshold, compares the ratio of two series against a threshold, or
+// fires when a series has no (recent) data for a cluster. A rule whose condition holds is
+// pending until it has held for its "for" duration, then firing; when the condition stops
+// holding the alert is resolved.
+package rules
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"ElasticObservability/pkg/events"
+	"ElasticObservability/pkg/utils"
+)
+
+// Rule conditions
+const (
+	ConditionThreshold = "threshold" // series <op> value
+	ConditionRatio     = "ratio"     // series / denominator <op> value
+	ConditionAbsence   = "absence"   // no sample (younger than maxAge) for a cluster
+)
+
+// Severities, in increasing order
+var severities = []string{"info", "warning", "critical"}
+
+// Rule is an alert rule as defined in YAML
+type Rule struct {
+	Name            string            `json:"name" yaml:"name"`
+	Series          string            `json:"series" yaml:"series"`
+	Condition       string            `json:"condition,omitempty" yaml:"condition,omitempty"` // default threshold
+	Op              string            `json:"op,omitempty" yaml:"op,omitempty"`               // >, >=, <, <=, ==, !=
+	Value           float64           `json:"value" yaml:"value"`
+	Denominator     string            `json:"denominator,omitempty" yaml:"denominator,omitempty"` // ratio only
+	MaxAge          string            `json:"maxAge,omitempty" yaml:"maxAge,omitempty"`           // absence only, e.g. "15m"
+	For             string            `json:"for,omitempty" yaml:"for,omitempty"`                 // e.g. "10m", default 0
+	Severity        string            `json:"severity,omitempty" yaml:"severity,omitempty"`       // info, warning (default), critical
+	IncludeClusters []string          `json:"includeClusters,omitempty" yaml:"includeClusters,omitempty"`
+	ExcludeClusters []string          `json:"excludeClusters,omitempty" yaml:"excludeClusters,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`           // added to the alert
+	Annotations     map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"` // {{label}} and {{value}} are expanded
+	// Responder metadata added to the alert's annotations, {{label}} and {{value}} expanded;
+	// they win over the eventMetadata configured for the rule
+	RunbookURL   string `json:"runbookUrl,omitempty" yaml:"runbookUrl,omitempty"`
+	DashboardURL string `json:"dashboardUrl,omitempty" yaml:"dashboardUrl,omitempty"`
+	Owner        string `json:"owner,omitempty" yaml:"owner,omitempty"`
+
+	forDuration time.Duration
+	maxAge      time.Duration
+}
+
+// rulesFile is the layout of a rules file
+type rulesFile struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// LoadFile reads and validates the rules of a YAML file
+func LoadFile(path string) ([]*Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	var file rulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+	return Prepare(file.Rules)
+}
+
+// Parse decodes rules given inline as job parameters (a list of maps) and validates them
+func Parse(raw interface{}) ([]*Rule, error) {
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var list []*Rule
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	return Prepare(list)
+}
+
+// Prepare applies defaults to rules and validates them
+func Prepare(list []*Rule) ([]*Rule, error) {
+	seen := make(map[string]bool)
+	for i, rule := range list {
+		if rule == nil || rule.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i+1)
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule %q", rule.Name)
+		}
+		seen[rule.Name] = true
+		if err := rule.prepare(); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+	return list, nil
+}
+
+func (r *Rule) prepare() error {
+	if _, ok := seriesSources[r.Series]; !ok {
+		return fmt.Errorf("unknown series %q (available: %s)", r.Series, strings.Join(SeriesNames(), ", "))
+	}
+	if r.Condition == "" {
+		r.Condition = ConditionThreshold
+	}
+	if r.Severity == "" {
+		r.Severity = "warning"
+	}
+	if !utils.Contains(severities, r.Severity) {
+		return fmt.Errorf("invalid severity %q (must be one of %q)", r.Severity, severities)
+	}
+
+	switch r.Condition {
+	case ConditionThreshold, ConditionRatio:
+		if _, err := compare(0, r.Op, 0); err != nil {
+			return err
+		}
+		if r.Condition == ConditionRatio {
+			if _, ok := seriesSources[r.Denominator]; !ok {
+				return fmt.Errorf("ratio rules need a known denominator series, got %q", r.Denominator)
+			}
+		}
+	case ConditionAbsence:
+		if r.MaxAge != "" {
+			d, err := utils.ParseDuration(r.MaxAge)
+			if err != nil {
+				return fmt.Errorf("invalid maxAge: %w", err)
+			}
+			r.maxAge = d
+		}
+	default:
+		return fmt.Errorf("invalid condition %q (must be threshold, ratio or absence)", r.Condition)
+	}
+
+	if r.For != "" {
+		d, err := utils.ParseDuration(r.For)
+		if err != nil {
+			return fmt.Errorf("invalid for: %w", err)
+		}
+		r.forDuration = d
+	}
+	return nil
+}
+
+// compare evaluates a <op> b
+func compare(a float64, op string, b float64) (bool, error) {
+	switch op {
+	case ">":
+		return a > b, nil
+	case ">=":
+		return a >= b, nil
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	case "==":
+		return a == b, nil
+	case "!=":
+		return a != b, nil
+	}
+	return false, fmt.Errorf("invalid op %q (must be one of >, >=, <, <=, ==, !=)", op)
+}
+
+// selected reports whether the rule applies to a cluster
+func (r *Rule) selected(clusterName string) bool {
+	if len(r.IncludeClusters) > 0 {
+		return utils.Contains(r.IncludeClusters, clusterName)
+	}
+	return !utils.Contains(r.ExcludeClusters, clusterName)
+}
+
+// match is one entity for which the rule's condition holds
+type match struct {
+	labels map[string]string
+	value  float64
+}
+
+// matches returns the entities for which the rule's condition currently holds
+func (r *Rule) matches(now time.Time, clusters []string) []match {
+	samples, _ := Series(r.Series)
+
+	var result []match
+	switch r.Condition {
+	case ConditionThreshold:
+		for _, sample := range samples {
+			if !r.selected(sample.Labels["cluster"]) {
+				continue
+			}
+			if ok, _ := compare(sample.Value, r.Op, r.Value); ok {
+				result = append(result, match{labels: sample.Labels, value: sample.Value})
+			}
+		}
+
+	case ConditionRatio:
+		denominators, _ := Series(r.Denominator)
+		byLabels := make(map[string]float64, len(denominators))
+		var keys []string
+		for _, sample := range denominators {
+			byLabels[fingerprint(sample.Labels)] = sample.Value
+			if keys == nil {
+				for k := range sample.Labels {
+					keys = append(keys, k)
+				}
+			}
+		}
+		for _, sample := range samples {
+			if !r.selected(sample.Labels["cluster"]) {
+				continue
+			}
+			// Join on the denominator's labels, e.g. per host or per cluster
+			joined := make(map[string]string, len(keys))
+			for _, k := range keys {
+				joined[k] = sample.Labels[k]
+			}
+			denominator, ok := byLabels[fingerprint(joined)]
+			if !ok || denominator == 0 {
+				continue
+			}
+			ratio := sample.Value / denominator
+			if ok, _ := compare(ratio, r.Op, r.Value); ok {
+				result = append(result, match{labels: sample.Labels, value: ratio})
+			}
+		}
+
+	case ConditionAbsence:
+		cutoff := int64(0)
+		if r.maxAge > 0 {
+			cutoff = now.Add(-r.maxAge).UnixMilli()
+		}
+		present := make(map[string]bool)
+		for _, sample := range samples {
+			if sample.Timestamp >= cutoff {
+				present[sample.Labels["cluster"]] = true
+			}
+		}
+		for _, clusterName := range clusters {
+			if r.selected(clusterName) && !present[clusterName] {
+				result = append(result, match{labels: map[string]string{"cluster": clusterName}})
+			}
+		}
+	}
+	return result
+}
+
+// metadata returns the responder metadata of the rule by annotation key
+func (r *Rule) metadata() map[string]string {
+	return map[string]string{
+		events.AnnotationRunbookURL:   r.RunbookURL,
+		events.AnnotationDashboardURL: r.DashboardURL,
+		events.AnnotationOwner:        r.Owner,
+	}
+}
+
+var placeholderRegex = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.]+)\s*\}\}`)
+
+// expand replaces {{label}} and {{value}} in an annotation
+func expand(text string, labels map[string]string, value float64) string {
+	return placeholderRegex.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := placeholderRegex.FindStringSubmatch(placeholder)[1]
+		if name == "value" {
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+		if v, ok := labels[name]; ok {
+			return v
+		}
+		return placeholder
+	})
+}
@@ -0,0 +1,209 @@
+package rules
+
+import (
+	"sort"
+	"strings"
+
+	"ElasticObservability/pkg/types"
+)
+
+// Sample is the current value of one series for one entity (cluster, host, index, ...)
+type Sample struct {
+	Labels    map[string]string `json:"labels"`
+	Value     float64           `json:"value"`
+	Timestamp int64             `json:"timestamp"` // epoch milliseconds (UTC) the value was observed
+}
+
+// seriesSources maps series names to functions returning their current samples. Every
+// sample carries a "cluster" label.
+var seriesSources = map[string]func() []Sample{
+	"tpwQueue":                   tpwQueueSamples,
+	"indexingRate.fromCreation":  indexingRateSamples(func(r *types.IndexingRate) float64 { return r.FromCreation }),
+	"indexingRate.last3Minutes":  indexingRateSamples(func(r *types.IndexingRate) float64 { return r.Last3Minutes }),
+	"indexingRate.last15Minutes": indexingRateSamples(func(r *types.IndexingRate) float64 { return r.Last15Minutes }),
+	"indexingRate.last60Minutes": indexingRateSamples(func(r *types.IndexingRate) float64 { return r.Last60Minutes }),
+	"indexHealth":                indexSamples(func(i *types.IndexInfo) float64 { return float64(i.Health) }),
+	"indexDocCount":              indexSamples(func(i *types.IndexInfo) float64 { return float64(i.DocCount) }),
+	"indexStoreBytes":            indexSamples(func(i *types.IndexInfo) float64 { return float64(i.TotalStorage) }),
+	"clusterHealth":              clusterHealthSamples,
+	"bulkTasks":                  bulkNodeSamples(func(n *types.NodeDataWriteBulk_sTasks) float64 { return float64(n.TotalWiteBulk_sTasks) }),
+	"bulkRequests":               bulkNodeSamples(func(n *types.NodeDataWriteBulk_sTasks) float64 { return float64(n.TotalWriteBulk_sRequests) }),
+	"bulkTimeMs":                 bulkNodeSamples(func(n *types.NodeDataWriteBulk_sTasks) float64 { return float64(n.TotalWrietBulk_sTimeTaken_ms) }),
+	"collectionFailures":         collectionFailureSamples,
+}
+
+// SeriesNames returns the names of all series rules can reference, sorted
+func SeriesNames() []string {
+	names := make([]string, 0, len(seriesSources))
+	for name := range seriesSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Series returns the current samples of a series
+func Series(name string) ([]Sample, bool) {
+	source, ok := seriesSources[name]
+	if !ok {
+		return nil, false
+	}
+	return source(), true
+}
+
+// fingerprint identifies a label set
+func fingerprint(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// tpwQueueSamples returns the latest thread pool write queue value of each host
+func tpwQueueSamples() []Sample {
+	samples := make([]Sample, 0)
+	for _, clusterName := range types.ClusterNames() {
+		queue, ok := types.GetTPWQueue(clusterName)
+		if !ok {
+			continue
+		}
+		for hostName, tpwq := range queue.HostTPWQueue {
+			if tpwq == nil || tpwq.Points == nil {
+				continue
+			}
+			if point, ok := tpwq.Points.Latest(); ok {
+				samples = append(samples, Sample{
+					Labels:    map[string]string{"cluster": clusterName, "host": hostName},
+					Value:     float64(point.Queue),
+					Timestamp: point.TimeStamp,
+				})
+			}
+		}
+	}
+	return samples
+}
+
+// indexingRateSamples returns one indexing rate window (bytes/ms per shard) of each index base
+func indexingRateSamples(value func(*types.IndexingRate) float64) func() []Sample {
+	return func() []Sample {
+		samples := make([]Sample, 0)
+		for _, clusterName := range types.ClusterNames() {
+			rate, ok := types.GetIndexingRate(clusterName)
+			if !ok || rate == nil {
+				continue
+			}
+			for indexBase, indexRate := range rate.MapIndices {
+				if indexRate == nil {
+					continue
+				}
+				samples = append(samples, Sample{
+					Labels:    map[string]string{"cluster": clusterName, "index": indexBase},
+					Value:     value(indexRate),
+					Timestamp: rate.Timestamp,
+				})
+			}
+		}
+		return samples
+	}
+}
+
+// latestIndices returns the latest _cat/indices snapshot of every cluster
+func latestIndices() map[string]*types.IndicesSnapShot {
+	result := make(map[string]*types.IndicesSnapShot)
+	for clusterName, history := range types.SnapshotHistories() {
+		if history == nil {
+			continue
+		}
+		if snapshot := history.Latest(0); snapshot != nil {
+			result[clusterName] = snapshot
+		}
+	}
+	return result
+}
+
+// indexSamples returns a value of each index in the latest snapshot
+func indexSamples(value func(*types.IndexInfo) float64) func() []Sample {
+	return func() []Sample {
+		samples := make([]Sample, 0)
+		for clusterName, snapshot := range latestIndices() {
+			for indexName, info := range snapshot.MapIndices {
+				samples = append(samples, Sample{
+					Labels:    map[string]string{"cluster": clusterName, "index": indexName},
+					Value:     value(info),
+					Timestamp: snapshot.SnapShotTime,
+				})
+			}
+		}
+		return samples
+	}
+}
+
+// clusterHealthSamples returns the worst index health of each cluster (1=green, 2=yellow, 3=red)
+func clusterHealthSamples() []Sample {
+	samples := make([]Sample, 0)
+	for clusterName, snapshot := range latestIndices() {
+		worst := uint8(0)
+		for _, info := range snapshot.MapIndices {
+			if info.Health > worst {
+				worst = info.Health
+			}
+		}
+		if worst == 0 {
+			continue
+		}
+		samples = append(samples, Sample{
+			Labels:    map[string]string{"cluster": clusterName},
+			Value:     float64(worst),
+			Timestamp: snapshot.SnapShotTime,
+		})
+	}
+	return samples
+}
+
+// bulkNodeSamples returns a value of each node in the latest bulk tasks snapshot
+func bulkNodeSamples(value func(*types.NodeDataWriteBulk_sTasks) float64) func() []Sample {
+	return func() []Sample {
+		samples := make([]Sample, 0)
+		for clusterName, history := range types.BulkTasksHistories() {
+			if history == nil || history.PtrClusterDataWriteBulk_sTasks == nil {
+				continue
+			}
+			snapshot := history.PtrClusterDataWriteBulk_sTasks.At(0)
+			if snapshot == nil {
+				continue
+			}
+			for hostName, node := range snapshot.DataWriteBulk_sTasksByNode {
+				samples = append(samples, Sample{
+					Labels:    map[string]string{"cluster": clusterName, "host": hostName},
+					Value:     value(node),
+					Timestamp: snapshot.SnapShotTime,
+				})
+			}
+		}
+		return samples
+	}
+}
+
+// collectionFailureSamples returns the consecutive collection failures per job and cluster
+func collectionFailureSamples() []Sample {
+	statuses := types.SnapshotCollectionStatus()
+	samples := make([]Sample, 0, len(statuses))
+	for _, status := range statuses {
+		samples = append(samples, Sample{
+			Labels:    map[string]string{"cluster": status.ClusterName, "job": status.JobName},
+			Value:     float64(status.ConsecutiveFailures),
+			Timestamp: status.LastAttempt,
+		})
+	}
+	return samples
+}
@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+)
+
+// Adaptive intervals: a run taking longer than adaptiveHighWater of its job's interval stretches
+// the interval so the run takes adaptiveTarget of it (up to the maximum interval), instead of
+// the next runs being skipped while it is still running. Runs shorter than adaptiveLowWater of
+// a stretched interval bring it back towards the configured interval.
+const (
+	adaptiveHighWater = 0.8
+	adaptiveLowWater  = 0.5
+	adaptiveTarget    = 0.6
+	defaultMaxStretch = 4 // default maximum interval, in configured intervals
+)
+
+// adaptiveInterval is the schedule of an interval job: the configured interval, stretched
+// while the job's runs take too long
+type adaptiveInterval struct {
+	mu          sync.Mutex
+	base        time.Duration // configured interval
+	max         time.Duration
+	current     time.Duration
+	lastRun     time.Duration // duration of the latest run
+	adaptive    bool
+	adaptations int
+}
+
+func newAdaptiveInterval(base, max time.Duration, adaptive bool) *adaptiveInterval {
+	return &adaptiveInterval{base: base, max: max, current: base, adaptive: adaptive}
+}
+
+// Next returns the next run after t, on a whole second like cron.Every
+func (a *adaptiveInterval) Next(t time.Time) time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return t.Add(a.current - time.Duration(t.Nanosecond())*time.Nanosecond)
+}
+
+// observe adapts the interval to the duration of a run and returns the interval before and
+// after, and whether it changed
+func (a *adaptiveInterval) observe(run time.Duration) (time.Duration, time.Duration, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lastRun = run
+	previous := a.current
+	if !a.adaptive {
+		return previous, previous, false
+	}
+
+	target := (time.Duration(float64(run) / adaptiveTarget)).Round(time.Second)
+	switch {
+	case run > time.Duration(float64(a.current)*adaptiveHighWater) && a.current < a.max:
+		a.current = min(max(target, a.current), a.max)
+	case a.current > a.base && run < time.Duration(float64(a.current)*adaptiveLowWater):
+		a.current = max(target, a.base)
+	}
+	if a.current == previous {
+		return previous, previous, false
+	}
+	a.adaptations++
+	return previous, a.current, true
+}
+
+// status returns the interval settings reported by GetJobStatus
+func (a *adaptiveInterval) status() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return map[string]interface{}{
+		"interval":          a.base.String(),
+		"effectiveInterval": a.current.String(),
+		"maxInterval":       a.max.String(),
+		"adaptive":          a.adaptive,
+		"adaptations":       a.adaptations,
+		"lastRunDuration":   a.lastRun.Round(time.Millisecond).String(),
+	}
+}
+
+// adaptInterval adapts the interval of an interval job to the duration of its latest run
+func (s *Scheduler) adaptInterval(job *Job, run time.Duration) {
+	if job.interval == nil {
+		return
+	}
+	previous, current, changed := job.interval.observe(run)
+	if !changed {
+		return
+	}
+	metrics.JobIntervalSeconds.WithLabelValues(job.Config.Name).Set(current.Seconds())
+	if current > previous {
+		metrics.JobIntervalAdaptationsTotal.WithLabelValues(job.Config.Name, "stretched").Inc()
+		logger.JobWarn(job.Config.Name, "Run took %s of the %s interval, stretching the interval to %s",
+			run.Round(time.Second), previous, current)
+		return
+	}
+	metrics.JobIntervalAdaptationsTotal.WithLabelValues(job.Config.Name, "shortened").Inc()
+	logger.JobInfo(job.Config.Name, "Run took %s, shortening the interval from %s to %s",
+		run.Round(time.Second), previous, current)
+}
@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"ElasticObservability/pkg/logger"
+)
+
+// resultRefKey is the parameter map key referring to a result: a parameter given as
+//
+//	includeClusters:
+//	  fromResult: update_endpoints.activeClusters
+//
+// takes the value the job update_endpoints published as activeClusters
+const resultRefKey = "fromResult"
+
+// chainRun is the run-scoped context of a chain of jobs: a run and the runs of the jobs it
+// triggers (dependsOn, triggerJobs), or the jobs of a group run, share it and see the results
+// published by the jobs of the chain
+type chainRun struct {
+	mu      sync.Mutex
+	results map[string]map[string]interface{} // job name -> key -> value
+}
+
+func newChainRun() *chainRun {
+	return &chainRun{results: make(map[string]map[string]interface{})}
+}
+
+func (c *chainRun) get(jobName, key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.results[jobName][key]
+	return value, ok
+}
+
+func (c *chainRun) set(jobName, key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results[jobName] == nil {
+		c.results[jobName] = make(map[string]interface{})
+	}
+	c.results[jobName][key] = value
+}
+
+// resultSink receives the results a job run publishes
+type resultSink struct {
+	jobName string
+	runID   string
+	run     *chainRun
+	latest  func(key string, value interface{})
+}
+
+type resultSinkKey struct{}
+
+// PublishResult publishes a result of the running job under a key, for the jobs following it
+// in the chain to consume as parameters (see resultRefKey). Values are strings, numbers,
+// booleans or lists of strings; other values are ignored with a warning. Outside a job run
+// PublishResult does nothing.
+func PublishResult(ctx context.Context, key string, value interface{}) {
+	sink, ok := ctx.Value(resultSinkKey{}).(*resultSink)
+	if !ok {
+		return
+	}
+	switch v := value.(type) {
+	case string, bool, int, int64, float64:
+	case []string:
+		value = append([]string{}, v...)
+	default:
+		logger.JobWarn(sink.jobName, "Result %s has unsupported type %T, not published", key, value)
+		return
+	}
+	sink.run.set(sink.jobName, key, value)
+	sink.latest(key, value)
+}
+
+// RunID returns the ID of the running job run, "<job>-<start epoch ms>", shared by the retries
+// of the run so the data they collect again can be recognized. Outside a job run it is "".
+func RunID(ctx context.Context) string {
+	if sink, ok := ctx.Value(resultSinkKey{}).(*resultSink); ok {
+		return sink.runID
+	}
+	return ""
+}
+
+// withResultSink returns the context a job runs in, publishing into a chain run
+func (s *Scheduler) withResultSink(ctx context.Context, jobName, runID string, run *chainRun) context.Context {
+	return context.WithValue(ctx, resultSinkKey{}, &resultSink{
+		jobName: jobName,
+		runID:   runID,
+		run:     run,
+		latest: func(key string, value interface{}) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.latestResults[jobName] == nil {
+				s.latestResults[jobName] = make(map[string]interface{})
+			}
+			s.latestResults[jobName][key] = value
+		},
+	})
+}
+
+// resultRef returns the job and key a parameter refers to, if it is a result reference
+func resultRef(value interface{}) (string, string, bool, error) {
+	ref, ok := value.(map[string]interface{})
+	if !ok || len(ref) != 1 {
+		return "", "", false, nil
+	}
+	target, ok := ref[resultRefKey]
+	if !ok {
+		return "", "", false, nil
+	}
+	s, _ := target.(string)
+	jobName, key, found := strings.Cut(s, ".")
+	if !found || jobName == "" || key == "" {
+		return "", "", true, fmt.Errorf("%s must be <job>.<result>, got %v", resultRefKey, target)
+	}
+	return jobName, key, true, nil
+}
+
+// resolveParameters returns the effective parameters of a job (see
+// config.JobConfig.EffectiveParameters) with the result references replaced by the results of
+// the chain run. A result the chain has not published is taken from the latest run of the
+// publishing job, with a warning; a result never published fails the run.
+func (s *Scheduler) resolveParameters(job *Job, run *chainRun) (map[string]interface{}, error) {
+	parameters := job.Config.EffectiveParameters()
+	var resolved map[string]interface{}
+	for name, value := range parameters {
+		jobName, key, isRef, err := resultRef(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+		if !isRef {
+			continue
+		}
+		if resolved == nil {
+			resolved = make(map[string]interface{}, len(parameters))
+			for k, v := range parameters {
+				resolved[k] = v
+			}
+		}
+
+		result, ok := run.get(jobName, key)
+		if !ok {
+			s.mu.RLock()
+			result, ok = s.latestResults[jobName][key]
+			s.mu.RUnlock()
+			if !ok {
+				return nil, fmt.Errorf("parameter %s: job %s has not published %s", name, jobName, key)
+			}
+			logger.JobWarn(job.Config.Name, "Parameter %s: job %s did not run in this chain, using its latest %s", name, jobName, key)
+		}
+		resolved[name] = result
+	}
+	if resolved == nil {
+		return parameters, nil
+	}
+	return resolved, nil
+}
+
+// withoutResultRefs returns the parameters without result references, which are only known
+// at run time, for validation when a job is loaded
+func withoutResultRefs(parameters map[string]interface{}) (map[string]interface{}, error) {
+	stripped := make(map[string]interface{}, len(parameters))
+	for name, value := range parameters {
+		_, _, isRef, err := resultRef(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+		if !isRef {
+			stripped[name] = value
+		}
+	}
+	return stripped, nil
+}
@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/utils"
+)
+
+// Retry limits: a job retries a failed run at most maxRetriesLimit times, and a job whose runs
+// keep failing after all their retries stops retrying after retrySuspendAfter such runs, until
+// a run succeeds, so a permanently broken job does not retry every run
+const (
+	maxRetriesLimit      = 10
+	retrySuspendAfter    = 3
+	defaultRetryBackoff  = 30 * time.Second
+	defaultRetryMaxDelay = 10 * time.Minute
+)
+
+// retryPolicy is the parsed retry configuration of a job
+type retryPolicy struct {
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// parseRetry parses the retry configuration of a job; nil means no retries
+func parseRetry(cfg *config.RetryConfig) (retryPolicy, error) {
+	if cfg == nil {
+		return retryPolicy{}, nil
+	}
+	policy := retryPolicy{maxRetries: cfg.MaxRetries, backoff: defaultRetryBackoff, maxBackoff: defaultRetryMaxDelay}
+	if cfg.MaxRetries < 0 || cfg.MaxRetries > maxRetriesLimit {
+		return policy, fmt.Errorf("maxRetries must be between 0 and %d", maxRetriesLimit)
+	}
+	if cfg.Backoff != "" {
+		d, err := utils.ParseDuration(cfg.Backoff)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("invalid backoff %q", cfg.Backoff)
+		}
+		policy.backoff = d
+	}
+	if cfg.MaxBackoff != "" {
+		d, err := utils.ParseDuration(cfg.MaxBackoff)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("invalid maxBackoff %q", cfg.MaxBackoff)
+		}
+		policy.maxBackoff = d
+	}
+	if policy.maxBackoff < policy.backoff {
+		policy.maxBackoff = policy.backoff
+	}
+	return policy, nil
+}
+
+// delay returns the wait before a retry (1 = first retry): backoff, doubled for each further
+// retry, up to maxBackoff
+func (p retryPolicy) delay(retry int) time.Duration {
+	d := p.backoff
+	for i := 1; i < retry && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	return d
+}
+
+// executeWithRetries executes a run of a job, retrying it while it fails as its retry policy
+// allows. The job stays running while it waits, so scheduled runs in the meantime are skipped.
+func (s *Scheduler) executeWithRetries(job *Job, run *chainRun) error {
+	err := s.execute(job, run)
+	if err == nil {
+		job.mu.Lock()
+		job.exhaustedRuns = 0
+		job.mu.Unlock()
+		return nil
+	}
+	if job.retry.maxRetries == 0 {
+		return err
+	}
+
+	job.mu.RLock()
+	suspended := job.exhaustedRuns >= retrySuspendAfter
+	job.mu.RUnlock()
+	if suspended {
+		logger.JobWarn(job.Config.Name, "Not retrying: the last %d runs failed after all retries", retrySuspendAfter)
+		return err
+	}
+
+	for retry := 1; retry <= job.retry.maxRetries; retry++ {
+		wait := job.retry.delay(retry)
+		logger.JobWarn(job.Config.Name, "Run failed: %v; retry %d of %d in %s", err, retry, job.retry.maxRetries, wait)
+		select {
+		case <-s.ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+
+		metrics.JobRetriesTotal.WithLabelValues(job.Config.Name).Inc()
+		if err = s.execute(job, run); err == nil {
+			logger.JobInfo(job.Config.Name, "Retry %d succeeded", retry)
+			job.mu.Lock()
+			job.exhaustedRuns = 0
+			job.mu.Unlock()
+			return nil
+		}
+	}
+
+	metrics.JobRetriesExhaustedTotal.WithLabelValues(job.Config.Name).Inc()
+	job.mu.Lock()
+	job.exhaustedRuns++
+	job.mu.Unlock()
+	return fmt.Errorf("failed after %d retries: %w", job.retry.maxRetries, err)
+}
@@ -2,29 +2,49 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime/pprof"
+	"sort"
 	"sync"
 	"time"
 
+	"ElasticObservability/pkg/blackout"
 	"ElasticObservability/pkg/config"
+	"ElasticObservability/pkg/kafka"
 	"ElasticObservability/pkg/logger"
+	"ElasticObservability/pkg/metrics"
+	"ElasticObservability/pkg/params"
+	"ElasticObservability/pkg/selftelemetry"
+	"ElasticObservability/pkg/utils"
 
 	"github.com/robfig/cron/v3"
 )
 
+// errJobRunning is returned by runJob for a job that is already running
+var errJobRunning = errors.New("job is already running")
+
+// ErrBlackout is returned for runs of a job held by a blackout calendar
+var ErrBlackout = errors.New("job is held by a blackout calendar")
+
 // JobFunc represents a job execution function
 type JobFunc func(ctx context.Context, params map[string]interface{}) error
 
+// ValidateFunc checks the parameters of a predefined job when the job is loaded
+type ValidateFunc func(params map[string]interface{}) error
+
 // Scheduler manages job scheduling and execution
 type Scheduler struct {
 	cron          *cron.Cron
 	jobs          map[string]*Job
 	jobFuncs      map[string]JobFunc
+	validators    map[string]ValidateFunc
 	mu            sync.RWMutex
 	ctx           context.Context
 	cancel        context.CancelFunc
 	initJobs      []*Job
-	dependencyMap map[string][]string // job name -> list of dependent job names
+	dependencyMap map[string][]string               // job name -> list of dependent job names
+	latestResults map[string]map[string]interface{} // job name -> results of its latest run
 }
 
 // Job represents a scheduled job
@@ -37,6 +57,11 @@ type Job struct {
 	RunCount   int
 	ErrorCount int
 	mu         sync.RWMutex
+
+	retry         retryPolicy
+	exhaustedRuns int               // consecutive runs that failed after all their retries
+	interval      *adaptiveInterval // schedule of interval jobs
+	runID         string            // ID of the current run, shared by its retries
 }
 
 // NewScheduler creates a new scheduler instance
@@ -46,10 +71,12 @@ func NewScheduler() *Scheduler {
 		cron:          cron.New(cron.WithSeconds()),
 		jobs:          make(map[string]*Job),
 		jobFuncs:      make(map[string]JobFunc),
+		validators:    make(map[string]ValidateFunc),
 		ctx:           ctx,
 		cancel:        cancel,
 		initJobs:      make([]*Job, 0),
 		dependencyMap: make(map[string][]string),
+		latestResults: make(map[string]map[string]interface{}),
 	}
 }
 
@@ -61,6 +88,13 @@ func (s *Scheduler) RegisterJobFunc(name string, fn JobFunc) {
 	logger.AppInfo("Registered job function: %s", name)
 }
 
+// RegisterJobValidator registers a parameter check for a predefined job function
+func (s *Scheduler) RegisterJobValidator(name string, fn ValidateFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validators[name] = fn
+}
+
 // AddJob adds a job to the scheduler
 func (s *Scheduler) AddJob(jobConfig *config.JobConfig) error {
 	s.mu.Lock()
@@ -71,8 +105,41 @@ func (s *Scheduler) AddJob(jobConfig *config.JobConfig) error {
 		return nil
 	}
 
+	for _, calendar := range jobConfig.BlackoutCalendars {
+		if !blackout.Exists(calendar) {
+			return fmt.Errorf("unknown blackout calendar %q", calendar)
+		}
+	}
+
+	if err := checkClusterLists(jobConfig); err != nil {
+		return err
+	}
+
+	// Result references are resolved at run time; the other parameters are checked now
+	parameters, err := withoutResultRefs(jobConfig.EffectiveParameters())
+	if err != nil {
+		return fmt.Errorf("invalid parameters: %w", err)
+	}
+	if validate, ok := s.validators[jobConfig.InternalJobName]; ok {
+		if err := validate(parameters); err != nil {
+			return fmt.Errorf("invalid parameters: %w", err)
+		}
+	}
+
+	switch jobConfig.InitPolicy {
+	case "", config.InitRequired, config.InitOptional:
+	default:
+		return fmt.Errorf("invalid initPolicy %q: use %s or %s", jobConfig.InitPolicy, config.InitRequired, config.InitOptional)
+	}
+
+	retry, err := parseRetry(jobConfig.Retry)
+	if err != nil {
+		return fmt.Errorf("invalid retry: %w", err)
+	}
+
 	job := &Job{
 		Config: jobConfig,
+		retry:  retry,
 	}
 
 	s.jobs[jobConfig.Name] = job
@@ -102,6 +169,34 @@ func (s *Scheduler) AddJob(jobConfig *config.JobConfig) error {
 	return nil
 }
 
+// checkClusterLists rejects cluster lists set both on a job and in its parameters, and warns
+// about lists set in the parameters only: they belong on the job
+func checkClusterLists(jobConfig *config.JobConfig) error {
+	for name, jobLevel := range map[string][]string{
+		"includeClusters": jobConfig.IncludeClusters,
+		"excludeClusters": jobConfig.ExcludeClusters,
+	} {
+		value, ok := jobConfig.Parameters[name]
+		if !ok {
+			continue
+		}
+		if _, _, isRef, _ := resultRef(value); isRef {
+			if len(jobLevel) > 0 {
+				return fmt.Errorf("%s is set both on the job and as a result reference in its parameters", name)
+			}
+			continue
+		}
+		if len(params.New(jobConfig.Parameters).StringSlice(name)) == 0 {
+			continue
+		}
+		if len(jobLevel) > 0 {
+			return fmt.Errorf("%s is set both on the job and in its parameters", name)
+		}
+		logger.AppWarn("Job %s: %s in parameters is deprecated, set it on the job", jobConfig.Name, name)
+	}
+	return nil
+}
+
 // scheduleJob schedules a job based on its configuration
 func (s *Scheduler) scheduleJob(job *Job) error {
 	schedule := job.Config.Schedule
@@ -109,7 +204,7 @@ func (s *Scheduler) scheduleJob(job *Job) error {
 	// Parse initial wait duration
 	var initialWait time.Duration
 	if schedule.InitialWait != "" {
-		dur, err := time.ParseDuration(schedule.InitialWait)
+		dur, err := utils.ParseDuration(schedule.InitialWait)
 		if err != nil {
 			return fmt.Errorf("invalid initial wait duration: %w", err)
 		}
@@ -132,17 +227,26 @@ func (s *Scheduler) scheduleJob(job *Job) error {
 		}
 		logger.AppInfo("Scheduled job %s with cron: %s", job.Config.Name, schedule.Cron)
 	} else if schedule.Interval != "" {
-		_, err := time.ParseDuration(schedule.Interval)
+		interval, err := utils.ParseDuration(schedule.Interval)
 		if err != nil {
 			return fmt.Errorf("invalid interval duration: %w", err)
 		}
-
-		// Use cron-like interval scheduling
-		cronSpec := fmt.Sprintf("@every %s", schedule.Interval)
-		entryID, err = s.cron.AddFunc(cronSpec, wrappedFunc)
-		if err != nil {
-			return fmt.Errorf("failed to schedule interval job: %w", err)
+		if interval < time.Second {
+			return fmt.Errorf("invalid interval duration: %s is shorter than 1s", schedule.Interval)
 		}
+		maxInterval := defaultMaxStretch * interval
+		if schedule.MaxInterval != "" {
+			maxInterval, err = utils.ParseDuration(schedule.MaxInterval)
+			if err != nil || maxInterval < interval {
+				return fmt.Errorf("invalid maxInterval %q: must be a duration of at least the interval", schedule.MaxInterval)
+			}
+		}
+		adaptive := schedule.Adaptive == nil || *schedule.Adaptive
+
+		// Use cron-like interval scheduling (also accepts d/w units, unlike "@every")
+		job.interval = newAdaptiveInterval(interval, maxInterval, adaptive)
+		entryID = s.cron.Schedule(job.interval, cron.FuncJob(wrappedFunc))
+		metrics.JobIntervalSeconds.WithLabelValues(job.Config.Name).Set(interval.Seconds())
 		logger.AppInfo("Scheduled job %s with interval: %s", job.Config.Name, schedule.Interval)
 	}
 
@@ -159,16 +263,30 @@ func (s *Scheduler) scheduleJob(job *Job) error {
 	return nil
 }
 
-// executeJob executes a job
+// executeJob executes a job, starting a new chain run
 func (s *Scheduler) executeJob(job *Job) {
+	s.runJob(job, nil, newChainRun())
+}
+
+// runJob executes a job within a chain run and then triggers the jobs following it, except
+// those in skipFollowers, in the same chain run, and returns the error of the run
+func (s *Scheduler) runJob(job *Job, skipFollowers map[string]bool, run *chainRun) error {
+	// Runs held by a blackout calendar are skipped, and do not trigger the jobs following
+	if calendar, blocked := blackout.Blocks(job.Config, time.Now()); blocked {
+		logger.JobInfo(job.Config.Name, "Skipping run: blackout calendar %s", calendar)
+		metrics.JobBlackoutSkipsTotal.WithLabelValues(job.Config.Name).Inc()
+		return fmt.Errorf("%w: %s", ErrBlackout, calendar)
+	}
+
 	job.mu.Lock()
 	if job.Running {
 		job.mu.Unlock()
 		logger.JobWarn(job.Config.Name, "Job is already running, skipping")
-		return
+		return errJobRunning
 	}
 	job.Running = true
 	job.LastRun = time.Now()
+	job.runID = fmt.Sprintf("%s-%d", job.Config.Name, job.LastRun.UnixMilli())
 	job.mu.Unlock()
 
 	defer func() {
@@ -179,16 +297,34 @@ func (s *Scheduler) executeJob(job *Job) {
 
 		// Collect all jobs to trigger (from both dependsOn and triggerJobs)
 		// and execute them once, avoiding duplicates
-		s.executeAllTriggeredJobs(job)
+		s.executeAllTriggeredJobs(job, skipFollowers, run)
 	}()
 
 	logger.JobInfo(job.Config.Name, "Starting job execution")
 
+	started := time.Now()
+	err := s.executeWithRetries(job, run)
+	s.adaptInterval(job, time.Since(started))
+	if err != nil {
+		job.mu.Lock()
+		job.ErrorCount++
+		job.mu.Unlock()
+		logger.JobError(job.Config.Name, "Job execution failed: %v", err)
+		kafka.PublishJobFailure(job.Config.Name, job.Config.InternalJobName, started, err)
+	} else {
+		logger.JobInfo(job.Config.Name, "Job execution completed successfully")
+	}
+	return err
+}
+
+// execute executes a job once, by its type
+func (s *Scheduler) execute(job *Job, run *chainRun) error {
+	finish := selftelemetry.Begin(job.Config.Name, job.Config.InternalJobName)
 	var err error
 
 	switch job.Config.Type {
 	case "preDefined", "func":
-		err = s.executePredefinedJob(job)
+		err = s.executePredefinedJob(job, run)
 	case "shell":
 		err = s.executeShellJob(job)
 	case "api":
@@ -196,19 +332,13 @@ func (s *Scheduler) executeJob(job *Job) {
 	default:
 		err = fmt.Errorf("unknown job type: %s", job.Config.Type)
 	}
-
-	if err != nil {
-		job.mu.Lock()
-		job.ErrorCount++
-		job.mu.Unlock()
-		logger.JobError(job.Config.Name, "Job execution failed: %v", err)
-	} else {
-		logger.JobInfo(job.Config.Name, "Job execution completed successfully")
-	}
+	finish(err != nil)
+	return err
 }
 
-// executePredefinedJob executes a predefined job function
-func (s *Scheduler) executePredefinedJob(job *Job) error {
+// executePredefinedJob executes a predefined job function with its parameters resolved in
+// the chain run
+func (s *Scheduler) executePredefinedJob(job *Job, run *chainRun) error {
 	s.mu.RLock()
 	fn, exists := s.jobFuncs[job.Config.InternalJobName]
 	s.mu.RUnlock()
@@ -217,7 +347,21 @@ func (s *Scheduler) executePredefinedJob(job *Job) error {
 		return fmt.Errorf("job function not registered: %s", job.Config.InternalJobName)
 	}
 
-	return fn(s.ctx, job.Config.Parameters)
+	parameters, err := s.resolveParameters(job, run)
+	if err != nil {
+		return err
+	}
+
+	job.mu.RLock()
+	runID := job.runID
+	job.mu.RUnlock()
+
+	// The label attributes the job's samples in CPU and goroutine profiles
+	ctx := s.withResultSink(s.ctx, job.Config.Name, runID, run)
+	pprof.Do(ctx, pprof.Labels("job", job.Config.Name), func(ctx context.Context) {
+		err = fn(ctx, parameters)
+	})
+	return err
 }
 
 // executeShellJob executes a shell command job
@@ -256,8 +400,9 @@ func (s *Scheduler) executeDependentJobs(completedJobName string) {
 }
 
 // executeAllTriggeredJobs collects jobs from both dependsOn and triggerJobs,
-// removes duplicates, and executes each job only once
-func (s *Scheduler) executeAllTriggeredJobs(completedJob *Job) {
+// removes duplicates, and executes each job only once, in the chain run of the completed
+// job. Jobs in skip are left out.
+func (s *Scheduler) executeAllTriggeredJobs(completedJob *Job, skip map[string]bool, run *chainRun) {
 	// Use map to track unique job names
 	uniqueJobs := make(map[string]bool)
 
@@ -273,16 +418,12 @@ func (s *Scheduler) executeAllTriggeredJobs(completedJob *Job) {
 	}
 
 	// Collect jobs from triggerJobs parameter
-	if completedJob.Config.Parameters != nil {
-		if triggerJobsParam, ok := completedJob.Config.Parameters["triggerJobs"]; ok {
-			if triggerList, ok := triggerJobsParam.([]interface{}); ok {
-				for _, item := range triggerList {
-					if jobName, ok := item.(string); ok && jobName != "" {
-						uniqueJobs[jobName] = true
-					}
-				}
-			}
-		}
+	for _, jobName := range params.New(completedJob.Config.Parameters).StringSlice("triggerJobs") {
+		uniqueJobs[jobName] = true
+	}
+
+	for jobName := range skip {
+		delete(uniqueJobs, jobName)
 	}
 
 	// If no jobs to trigger, return early
@@ -306,42 +447,36 @@ func (s *Scheduler) executeAllTriggeredJobs(completedJob *Job) {
 
 		if exists {
 			logger.AppInfo("Triggering job %s (from %s)", jobName, completedJob.Config.Name)
-			go s.executeJob(job)
+			go s.runJob(job, nil, run)
 		} else {
 			logger.AppWarn("Trigger job %s not found (from %s)", jobName, completedJob.Config.Name)
 		}
 	}
 }
 
-// RunInitJobs runs all initialization jobs
+// RunInitJobs runs all initialization jobs in order. It fails on the first failed required job;
+// failed optional jobs are logged and skipped.
 func (s *Scheduler) RunInitJobs() error {
 	logger.AppInfo("Running %d initialization jobs", len(s.initJobs))
 
+	// Each init job completes, including its retries, before the next one starts
+	var failed []string
 	for _, job := range s.initJobs {
-		s.executeJob(job)
-
-		// Wait for init job to complete before starting next one
-		for {
-			job.mu.RLock()
-			running := job.Running
-			job.mu.RUnlock()
-
-			if !running {
-				break
-			}
-			time.Sleep(100 * time.Millisecond)
-		}
-
-		// Check if job failed
-		job.mu.RLock()
-		errorCount := job.ErrorCount
-		job.mu.RUnlock()
-
-		if errorCount > 0 {
-			return fmt.Errorf("initialization job %s failed", job.Config.Name)
+		err := s.runJob(job, nil, newChainRun())
+		switch {
+		case err == nil, errors.Is(err, ErrBlackout):
+		case job.Config.InitPolicy == config.InitOptional:
+			logger.AppWarn("Optional initialization job %s failed, continuing: %v", job.Config.Name, err)
+			failed = append(failed, job.Config.Name)
+		default:
+			return fmt.Errorf("initialization job %s failed: %w", job.Config.Name, err)
 		}
 	}
 
+	if len(failed) > 0 {
+		logger.AppWarn("Initialization completed without optional jobs %v", failed)
+		return nil
+	}
 	logger.AppInfo("All initialization jobs completed successfully")
 	return nil
 }
@@ -368,19 +503,66 @@ func (s *Scheduler) GetJobStatus() map[string]interface{} {
 	status := make(map[string]interface{})
 	for name, job := range s.jobs {
 		job.mu.RLock()
-		status[name] = map[string]interface{}{
+		entry := map[string]interface{}{
 			"running":    job.Running,
 			"lastRun":    job.LastRun,
 			"nextRun":    job.NextRun,
 			"runCount":   job.RunCount,
 			"errorCount": job.ErrorCount,
 		}
+		if job.Config.Tenant != "" {
+			entry["tenant"] = job.Config.Tenant
+		}
+		if calendar, blocked := blackout.Blocks(job.Config, time.Now()); blocked {
+			entry["blackout"] = calendar
+		}
+		if job.interval != nil {
+			entry["schedule"] = job.interval.status()
+		}
+		status[name] = entry
 		job.mu.RUnlock()
 	}
 
 	return status
 }
 
+// JobTenant returns the tenant of a job ("" for jobs that are not a tenant's)
+func (s *Scheduler) JobTenant(jobName string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, exists := s.jobs[jobName]
+	if !exists {
+		return "", false
+	}
+	return job.Config.Tenant, true
+}
+
+// JobInternalName returns the internal job name of a job ("" for non-predefined jobs)
+func (s *Scheduler) JobInternalName(jobName string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, exists := s.jobs[jobName]
+	if !exists {
+		return "", false
+	}
+	return job.Config.InternalJobName, true
+}
+
+// JobConfigs returns the configurations of the loaded jobs
+func (s *Scheduler) JobConfigs() []*config.JobConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	configs := make([]*config.JobConfig, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		configs = append(configs, job.Config)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+	return configs
+}
+
 // TriggerJob manually triggers a job by name
 func (s *Scheduler) TriggerJob(jobName string) error {
 	s.mu.RLock()
@@ -390,7 +572,104 @@ func (s *Scheduler) TriggerJob(jobName string) error {
 	if !exists {
 		return fmt.Errorf("job not found: %s", jobName)
 	}
+	if calendar, blocked := blackout.Blocks(job.Config, time.Now()); blocked {
+		return fmt.Errorf("%w: %s", ErrBlackout, calendar)
+	}
 
 	go s.executeJob(job)
 	return nil
 }
+
+// Statuses of a job in a group run
+const (
+	RunSucceeded      = "succeeded"
+	RunFailed         = "failed"
+	RunAlreadyRunning = "alreadyRunning"
+	RunBlackout       = "blackout"
+	RunNotFound       = "notFound"
+	RunSkipped        = "skipped"
+)
+
+// JobRun is the outcome of a job in a group run
+type JobRun struct {
+	Job        string `json:"job"`
+	Status     string `json:"status"`              // RunSucceeded, RunFailed, ...
+	StartedAt  int64  `json:"startedAt,omitempty"` // epoch milliseconds (UTC)
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// GroupRun is the combined report of jobs run one after another
+type GroupRun struct {
+	Group      string   `json:"group,omitempty"` // name of the configured job group, if any
+	Status     string   `json:"status"`          // RunSucceeded when every job succeeded, else RunFailed
+	StartedAt  int64    `json:"startedAt"`       // epoch milliseconds (UTC)
+	DurationMs int64    `json:"durationMs"`
+	Jobs       []JobRun `json:"jobs"`
+}
+
+// RunJobs runs jobs one after another, each once the previous one finished, e.g. a refresh
+// chain, and reports every run. A job that fails, is already running, is held by a blackout
+// calendar or does not exist fails the group; the jobs after it are skipped unless
+// continueOnError is set. Jobs a job triggers (dependsOn, triggerJobs) are not triggered when
+// they come later in the list, as the list runs them in order. The jobs share a chain run, so
+// each sees the results published by the jobs before it.
+func (s *Scheduler) RunJobs(jobNames []string, continueOnError bool) *GroupRun {
+	started := time.Now()
+	chain := newChainRun()
+	run := &GroupRun{
+		Status:    RunSucceeded,
+		StartedAt: started.UnixMilli(),
+		Jobs:      make([]JobRun, 0, len(jobNames)),
+	}
+
+	for i, jobName := range jobNames {
+		jobRun := JobRun{Job: jobName}
+		if (run.Status == RunFailed && !continueOnError) || s.ctx.Err() != nil {
+			jobRun.Status = RunSkipped
+			run.Jobs = append(run.Jobs, jobRun)
+			continue
+		}
+
+		s.mu.RLock()
+		job, exists := s.jobs[jobName]
+		s.mu.RUnlock()
+		if !exists {
+			jobRun.Status = RunNotFound
+			jobRun.Error = fmt.Sprintf("job not found: %s", jobName)
+			run.Status = RunFailed
+			run.Jobs = append(run.Jobs, jobRun)
+			continue
+		}
+
+		later := make(map[string]bool)
+		for _, laterJob := range jobNames[i+1:] {
+			later[laterJob] = true
+		}
+
+		jobStarted := time.Now()
+		err := s.runJob(job, later, chain)
+		jobRun.StartedAt = jobStarted.UnixMilli()
+		jobRun.DurationMs = time.Since(jobStarted).Milliseconds()
+		switch {
+		case errors.Is(err, errJobRunning):
+			jobRun.Status = RunAlreadyRunning
+			jobRun.Error = err.Error()
+		case errors.Is(err, ErrBlackout):
+			jobRun.Status = RunBlackout
+			jobRun.Error = err.Error()
+		case err != nil:
+			jobRun.Status = RunFailed
+			jobRun.Error = err.Error()
+		default:
+			jobRun.Status = RunSucceeded
+		}
+		if err != nil {
+			run.Status = RunFailed
+		}
+		run.Jobs = append(run.Jobs, jobRun)
+	}
+
+	run.DurationMs = time.Since(started).Milliseconds()
+	return run
+}
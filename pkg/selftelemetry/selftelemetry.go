@@ -0,0 +1,216 @@
+// Package selftelemetry records the resource usage of the job runs of this process: the
+// wall-clock time, the heap allocated and the goroutines before and after each run, so the
+// job responsible for a growing instance can be found.
+//
+// Allocations are read from the process-wide runtime counters, so a run that overlapped
+// with other job runs shares its allocations with them; such runs are flagged. Profiles
+// attributed to jobs exactly come from pprof: the scheduler runs every job with a "job"
+// profiler label, which CPU and goroutine profiles carry.
+package selftelemetry
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"ElasticObservability/pkg/metrics"
+)
+
+// Run is the resource usage of one job run
+type Run struct {
+	Job             string `json:"job"`
+	InternalJobName string `json:"internalJobName,omitempty"`
+	StartTime       int64  `json:"startTime"` // epoch milliseconds (UTC)
+	WallMs          int64  `json:"wallMs"`
+	Failed          bool   `json:"failed"`
+	AllocBytes      uint64 `json:"allocBytes"` // heap bytes allocated by the process during the run
+	Mallocs         uint64 `json:"mallocs"`    // heap objects allocated by the process during the run
+	GCCycles        uint32 `json:"gcCycles"`   // garbage collections during the run
+	GoroutinesStart int    `json:"goroutinesStart"`
+	GoroutinesEnd   int    `json:"goroutinesEnd"`
+	HeapInUseEnd    uint64 `json:"heapInUseEnd"` // heap in use by the process after the run
+	Overlapped      bool   `json:"overlapped"`   // other job runs were active, so the allocations are shared
+}
+
+// JobSummary aggregates the kept runs of a job
+type JobSummary struct {
+	Job             string `json:"job"`
+	InternalJobName string `json:"internalJobName,omitempty"`
+	Runs            int    `json:"runs"`
+	Failed          int    `json:"failed"`
+	LastRun         int64  `json:"lastRun"` // epoch milliseconds (UTC)
+	AvgWallMs       int64  `json:"avgWallMs"`
+	MaxWallMs       int64  `json:"maxWallMs"`
+	AvgAllocBytes   uint64 `json:"avgAllocBytes"`
+	MaxAllocBytes   uint64 `json:"maxAllocBytes"`
+	TotalAllocBytes uint64 `json:"totalAllocBytes"`
+	GoroutineGrowth int    `json:"goroutineGrowth"` // goroutines left behind by the kept runs, summed
+}
+
+// Process is the current resource usage of the whole process
+type Process struct {
+	Goroutines      int     `json:"goroutines"`
+	HeapAllocBytes  uint64  `json:"heapAllocBytes"`
+	HeapInUseBytes  uint64  `json:"heapInUseBytes"`
+	HeapObjects     uint64  `json:"heapObjects"`
+	SysBytes        uint64  `json:"sysBytes"`
+	TotalAllocBytes uint64  `json:"totalAllocBytes"`
+	NumGC           uint32  `json:"numGC"`
+	GCPauseTotalMs  float64 `json:"gcPauseTotalMs"`
+}
+
+// activeRun is a run that has not finished yet
+type activeRun struct {
+	overlapped bool
+}
+
+var (
+	mu       sync.Mutex
+	enabled  bool
+	runsKept = 50
+	runs     = make(map[string][]Run) // job -> runs, oldest first, at most runsKept
+	active   = make(map[*activeRun]bool)
+)
+
+// Configure enables or disables the recording of job runs and sets the runs kept per job
+func Configure(enable bool, keep int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	enabled = enable
+	if keep > 0 {
+		runsKept = keep
+	}
+	runs = make(map[string][]Run)
+}
+
+// Enabled reports whether job runs are recorded
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return enabled
+}
+
+// Begin starts recording a job run and returns the function that finishes it. Without
+// self-telemetry the returned function does nothing.
+func Begin(job, internalJobName string) func(failed bool) {
+	mu.Lock()
+	if !enabled {
+		mu.Unlock()
+		return func(bool) {}
+	}
+	run := &activeRun{overlapped: len(active) > 0}
+	for other := range active {
+		other.overlapped = true
+	}
+	active[run] = true
+	mu.Unlock()
+
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	goroutinesStart := runtime.NumGoroutine()
+
+	return func(failed bool) {
+		wall := time.Since(start)
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+
+		mu.Lock()
+		defer mu.Unlock()
+		delete(active, run)
+		if !enabled {
+			return
+		}
+
+		record := Run{
+			Job:             job,
+			InternalJobName: internalJobName,
+			StartTime:       start.UnixMilli(),
+			WallMs:          wall.Milliseconds(),
+			Failed:          failed,
+			AllocBytes:      after.TotalAlloc - before.TotalAlloc,
+			Mallocs:         after.Mallocs - before.Mallocs,
+			GCCycles:        after.NumGC - before.NumGC,
+			GoroutinesStart: goroutinesStart,
+			GoroutinesEnd:   runtime.NumGoroutine(),
+			HeapInUseEnd:    after.HeapInuse,
+			Overlapped:      run.overlapped,
+		}
+		jobRuns := append(runs[job], record)
+		if len(jobRuns) > runsKept {
+			jobRuns = append([]Run(nil), jobRuns[len(jobRuns)-runsKept:]...)
+		}
+		runs[job] = jobRuns
+
+		metrics.JobRunDurationSeconds.WithLabelValues(job).Set(wall.Seconds())
+		metrics.JobRunAllocatedBytes.WithLabelValues(job).Set(float64(record.AllocBytes))
+	}
+}
+
+// Runs returns copies of the kept runs of a job, newest first
+func Runs(job string) []Run {
+	mu.Lock()
+	defer mu.Unlock()
+
+	jobRuns := runs[job]
+	result := make([]Run, 0, len(jobRuns))
+	for i := len(jobRuns) - 1; i >= 0; i-- {
+		result = append(result, jobRuns[i])
+	}
+	return result
+}
+
+// Summaries aggregates the kept runs of every job that ran, most allocating first
+func Summaries() []JobSummary {
+	mu.Lock()
+	defer mu.Unlock()
+
+	summaries := make([]JobSummary, 0, len(runs))
+	for job, jobRuns := range runs {
+		summary := JobSummary{Job: job, Runs: len(jobRuns)}
+		var totalWallMs int64
+		for _, run := range jobRuns {
+			summary.InternalJobName = run.InternalJobName
+			summary.LastRun = max(summary.LastRun, run.StartTime)
+			if run.Failed {
+				summary.Failed++
+			}
+			totalWallMs += run.WallMs
+			summary.MaxWallMs = max(summary.MaxWallMs, run.WallMs)
+			summary.TotalAllocBytes += run.AllocBytes
+			summary.MaxAllocBytes = max(summary.MaxAllocBytes, run.AllocBytes)
+			summary.GoroutineGrowth += run.GoroutinesEnd - run.GoroutinesStart
+		}
+		if len(jobRuns) > 0 {
+			summary.AvgWallMs = totalWallMs / int64(len(jobRuns))
+			summary.AvgAllocBytes = summary.TotalAllocBytes / uint64(len(jobRuns))
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].AvgAllocBytes != summaries[j].AvgAllocBytes {
+			return summaries[i].AvgAllocBytes > summaries[j].AvgAllocBytes
+		}
+		return summaries[i].Job < summaries[j].Job
+	})
+	return summaries
+}
+
+// ProcessStats returns the current resource usage of the process
+func ProcessStats() Process {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return Process{
+		Goroutines:      runtime.NumGoroutine(),
+		HeapAllocBytes:  stats.HeapAlloc,
+		HeapInUseBytes:  stats.HeapInuse,
+		HeapObjects:     stats.HeapObjects,
+		SysBytes:        stats.Sys,
+		TotalAllocBytes: stats.TotalAlloc,
+		NumGC:           stats.NumGC,
+		GCPauseTotalMs:  float64(stats.PauseTotalNs) / 1e6,
+	}
+}
@@ -0,0 +1,176 @@
+// Package testsupport helps integration-test the collection jobs end-to-end: FakeES is an
+// Elasticsearch simulator serving the root endpoint and recorded _cat/indices, _tasks and
+// monitoring search responses, and RegisterCluster points a cluster of the shared state at it.
+package testsupport
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+
+	"ElasticObservability/pkg/types"
+)
+
+//go:embed responses/*.json
+var recorded embed.FS
+
+// Recorded responses served by default, in responses/
+const (
+	CatIndicesResponse       = "cat_indices.json"       // GET /_cat/indices?format=json
+	TasksResponse            = "tasks.json"             // GET /_tasks?detailed=true
+	MonitoringSearchResponse = "monitoring_search.json" // POST /.monitoring-es-*/_search, thread pool write queue
+)
+
+// FakeClusterUUID is the cluster UUID GET / answers with until RegisterCluster names another
+const FakeClusterUUID = "fAkEeSuUiDfAkEeSuUiD00"
+
+// Request is a request received by the fake
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+// route answers the requests of a method whose path matches pattern
+type route struct {
+	method  string
+	pattern string // path.Match syntax
+	status  int
+	body    []byte
+}
+
+// FakeES is an httptest server answering like Elasticsearch with canned responses. Requests
+// without a matching route get a 404 in the Elasticsearch error format.
+type FakeES struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	routes   []route // the last matching route answers
+	requests []Request
+}
+
+// NewFakeES starts a fake Elasticsearch serving GET / (cluster fake-es, FakeClusterUUID) and
+// the recorded responses for _cat/indices, _tasks and searches of any index (the monitoring
+// search of getThreadPoolWriteQueue). Close it at the end of the test.
+func NewFakeES() *FakeES {
+	f := &FakeES{}
+	f.Handle(http.MethodGet, "/", http.StatusOK, Root("fake-es", FakeClusterUUID))
+	f.Handle(http.MethodGet, "/_cat/indices", http.StatusOK, Recorded(CatIndicesResponse))
+	f.Handle(http.MethodGet, "/_tasks", http.StatusOK, Recorded(TasksResponse))
+	f.Handle(http.MethodPost, "/*/_search", http.StatusOK, Recorded(MonitoringSearchResponse))
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// Recorded returns a recorded response by name, e.g. CatIndicesResponse, to serve it
+// modified. It panics on an unknown name.
+func Recorded(name string) []byte {
+	body, err := recorded.ReadFile("responses/" + name)
+	if err != nil {
+		panic(fmt.Sprintf("testsupport: no recorded response %s", name))
+	}
+	return body
+}
+
+// Root returns a GET / response of a cluster, as checked against the inventory by the
+// cluster UUID verification of the collection jobs
+func Root(clusterName, clusterUUID string) []byte {
+	body, _ := json.Marshal(map[string]interface{}{
+		"name":         "fake-es-node",
+		"cluster_name": clusterName,
+		"cluster_uuid": clusterUUID,
+		"version":      map[string]interface{}{"number": "8.11.3"},
+		"tagline":      "You Know, for Search",
+	})
+	return body
+}
+
+// Handle answers the requests of method whose path matches pattern (path.Match syntax, e.g.
+// "/*/_search") with status and body. It takes precedence over the routes handled before,
+// the recorded responses included.
+func (f *FakeES) Handle(method, pattern string, status int, body []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.routes = append(f.routes, route{method: method, pattern: pattern, status: status, body: body})
+}
+
+// HandleFile answers like Handle with the content of a file, e.g. a response recorded from a
+// real cluster
+func (f *FakeES) HandleFile(method, pattern string, status int, file string) error {
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	f.Handle(method, pattern, status, body)
+	return nil
+}
+
+// Requests returns the requests received so far, oldest first
+func (f *FakeES) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
+
+// RegisterCluster adds a cluster whose active and current master endpoints are the fake,
+// with an API key, replacing a cluster of the same name, and answers GET / with its name and
+// UUID. The cluster is returned for further changes through types.UpdateCluster.
+func (f *FakeES) RegisterCluster(clusterName, clusterUUID string) *types.ClusterData {
+	f.Handle(http.MethodGet, "/", http.StatusOK, Root(clusterName, clusterUUID))
+	cluster := &types.ClusterData{
+		ClusterName:    clusterName,
+		ClusterUUID:    clusterUUID,
+		Active:         true,
+		ActiveEndpoint: f.URL,
+		AccessCred:     types.AccessCred{Preferred: 1, APIKey: "fake-es-api-key"},
+	}
+	types.MutateClusters(func(clusters map[string]*types.ClusterData) {
+		clusters[clusterName] = cluster
+	})
+	types.SetCurrentMasterEndpoint(clusterName, f.URL)
+	return cluster
+}
+
+// MonitoringEndpoint returns the monitoring search endpoint of the fake, for the
+// APIEndPoints parameter of getThreadPoolWriteQueue
+func (f *FakeES) MonitoringEndpoint() string {
+	return f.URL + "/.monitoring-es-*/_search"
+}
+
+// serve records a request and answers it from the last matching route
+func (f *FakeES) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	matched, found := route{}, false
+	for i := len(f.routes) - 1; i >= 0 && !found; i-- {
+		if ok, _ := path.Match(f.routes[i].pattern, r.URL.Path); ok && f.routes[i].method == r.Method {
+			matched, found = f.routes[i], true
+		}
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error":{"type":"fake_es_no_route","reason":%q},"status":404}`, "no response for "+r.Method+" "+r.URL.Path)
+		return
+	}
+	w.WriteHeader(matched.status)
+	w.Write(matched.body)
+}
@@ -0,0 +1,166 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ClusterRename is the outcome of renaming a cluster in the global structures
+type ClusterRename struct {
+	From   string   `json:"from"`
+	To     string   `json:"to"`
+	Merged bool     `json:"merged"` // the new name was in the inventory already
+	Moved  []string `json:"moved"`  // structures holding data of the cluster that moved to the new name, sorted
+}
+
+// renameLocks are the mutexes of the per-cluster structures in the order RenameClusterData
+// takes them, the order of RemoveClusterData
+var renameLocks = []*sync.RWMutex{
+	&ClustersMu, &HistoryMu, &IndexingRateMu, &StatsByDayMu, &TPWQueueMu, &CurrentMasterEndPtsMu,
+	&ClusterDataWriteBulkTasksHistoryMu, &CollectionStatusMu, &RetentionMu, &DiskUsageMu,
+	&JVMStatsMu, &RejectionsMu, &SegmentStatsMu, &RecoveriesMu, &TrackedTasksMu,
+	&SettingsDriftMu, &FieldCountsMu, &PipelineStatsMu, &RemotesMu, &MasterHistoryMu, &TiersMu,
+}
+
+// rekey moves the value of oldName in m to newName. The value replaces one the new name holds
+// already, so the history of the renamed cluster carries over a merge. It reports whether
+// there was a value to move.
+func rekey[T any](m map[string]T, oldName, newName string) bool {
+	value, ok := m[oldName]
+	if !ok {
+		return false
+	}
+	delete(m, oldName)
+	m[newName] = value
+	return true
+}
+
+// RenameClusterData re-keys a cluster and everything collected for it from oldName to
+// newName, holding the locks of all structures so readers never see the cluster under both
+// names or half moved. If newName is in the inventory already (the CMDB rename was loaded
+// before the cluster was renamed here), the clusters are merged: the inventory entry of
+// newName is kept, and the data collected under oldName replaces what newName collected since.
+func RenameClusterData(oldName, newName string) (*ClusterRename, error) {
+	if oldName == newName {
+		return nil, fmt.Errorf("cluster %s: old and new name are the same", oldName)
+	}
+	for _, mu := range renameLocks {
+		mu.Lock()
+	}
+	defer func() {
+		for i := len(renameLocks) - 1; i >= 0; i-- {
+			renameLocks[i].Unlock()
+		}
+	}()
+
+	cluster, exists := AllClusters[oldName]
+	if !exists {
+		return nil, fmt.Errorf("cluster %s not found", oldName)
+	}
+	result := &ClusterRename{From: oldName, To: newName, Moved: make([]string, 0)}
+	moved := func(structure string, ok bool) {
+		if ok {
+			result.Moved = append(result.Moved, structure)
+		}
+	}
+
+	// Inventory: a merge keeps the entry loaded under the new name
+	delete(AllClusters, oldName)
+	if _, result.Merged = AllClusters[newName]; !result.Merged {
+		cluster.ClusterName = newName
+		AllClusters[newName] = cluster
+	}
+	published := make(map[string]*ClusterData, len(AllClusters))
+	for clusterName, c := range AllClusters {
+		published[clusterName] = c.Copy()
+	}
+	clustersView.Replace(published)
+
+	moved("indicesHistory", rekey(AllHistory, oldName, newName))
+
+	if rekey(AllIndexingRate, oldName, newName) {
+		result.Moved = append(result.Moved, "indexingRate")
+		indexingRateView.Remove(oldName)
+		indexingRateView.Publish(newName, AllIndexingRate[newName])
+	}
+	moved("indexingRateHistory", rekey(AllIndexingRateHistory, oldName, newName))
+
+	if rekey(AllStatsByDay, oldName, newName) {
+		result.Moved = append(result.Moved, "statsByDay")
+		statsByDayView.Remove(oldName)
+		statsByDayView.Publish(newName, AllStatsByDay[newName].Copy())
+	}
+
+	if rekey(AllThreadPoolWriteQueues, oldName, newName) {
+		result.Moved = append(result.Moved, "tpwQueue")
+		tpwQueueView.Remove(oldName)
+		PublishTPWQueue(newName, AllThreadPoolWriteQueues[newName])
+	}
+
+	moved("currentMasterEndPoint", rekey(AllCurrentMasterEndPoints, oldName, newName))
+
+	if rekey(AllClusterDataWriteBulk_sTasksHistory, oldName, newName) {
+		result.Moved = append(result.Moved, "bulkTasksHistory")
+		history := AllClusterDataWriteBulk_sTasksHistory[newName]
+		history.ClusterName = newName
+		bulkTasksHistoryView.Remove(oldName)
+		PublishBulkTasksHistory(newName, history)
+	}
+	if rekey(AllTaskActionHistory, oldName, newName) {
+		result.Moved = append(result.Moved, "taskActionHistory")
+		next := make(map[string]*ClusterDataWriteBulk_sTasksHistory, len(AllTaskActionHistory[newName]))
+		for action, history := range AllTaskActionHistory[newName] {
+			history.ClusterName = newName
+			next[action] = history.Copy()
+		}
+		taskActionView.Remove(oldName)
+		taskActionView.Publish(newName, next)
+	}
+
+	collections := false
+	for _, byCluster := range AllCollectionStatus {
+		if rekey(byCluster, oldName, newName) {
+			byCluster[newName].ClusterName = newName
+			collections = true
+		}
+	}
+	moved("collectionStatus", collections)
+
+	// Reports are never modified once published, so the renamed ones are copies
+	if rekey(AllRetentionReports, oldName, newName) {
+		result.Moved = append(result.Moved, "retention")
+		report := *AllRetentionReports[newName]
+		report.ClusterName = newName
+		AllRetentionReports[newName] = &report
+	}
+	if rekey(AllSettingsDrift, oldName, newName) {
+		result.Moved = append(result.Moved, "settingsDrift")
+		report := *AllSettingsDrift[newName]
+		report.ClusterName = newName
+		AllSettingsDrift[newName] = &report
+	}
+	if rekey(AllTrackedTasks, oldName, newName) {
+		result.Moved = append(result.Moved, "trackedTasks")
+		tasks := AllTrackedTasks[newName]
+		for taskID, task := range tasks {
+			renamed := *task
+			renamed.ClusterName = newName
+			tasks[taskID] = &renamed
+		}
+	}
+
+	moved("diskUsage", rekey(AllDiskUsage, oldName, newName))
+	moved("jvmStats", rekey(AllJVMStats, oldName, newName))
+	moved("threadPoolRejections", rekey(AllThreadPoolRejections, oldName, newName))
+	moved("segmentStats", rekey(AllSegmentStats, oldName, newName))
+	moved("recoveries", rekey(AllRecoveries, oldName, newName))
+	moved("fieldCounts", rekey(AllFieldCounts, oldName, newName))
+	moved("pipelineStats", rekey(AllPipelineStats, oldName, newName))
+	moved("remoteClusters", rekey(AllRemotes, oldName, newName))
+	moved("masterHistory", rekey(AllMasterHistory, oldName, newName))
+	moved("tiers", rekey(AllTiers, oldName, newName))
+
+	sort.Strings(result.Moved)
+	return result, nil
+}
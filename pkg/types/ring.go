@@ -0,0 +1,119 @@
+package types
+
+import "encoding/json"
+
+// Ring is a fixed-capacity history buffer. Slot 0 is the newest entry and slot Cap()-1 the
+// oldest; empty slots hold the zero value. Adding entries moves a head index instead of
+// shifting the elements, so rolling a history is O(1) per entry.
+//
+// Ring is not safe for concurrent use; callers guard it with the lock of the owning structure.
+// It is encoded in JSON as a newest-first array, the layout the histories used before.
+type Ring[T any] struct {
+	buf  []T
+	head int // position of the newest slot in buf
+}
+
+// NewRing creates a ring with capacity empty slots
+func NewRing[T any](capacity int) *Ring[T] {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &Ring[T]{buf: make([]T, capacity)}
+}
+
+// Cap returns the number of slots
+func (r *Ring[T]) Cap() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.buf)
+}
+
+// pos maps a slot (0 = newest) to its position in buf
+func (r *Ring[T]) pos(slot int) int {
+	return (r.head - slot + len(r.buf)) % len(r.buf)
+}
+
+// At returns the entry in slot i (0 = newest). Out of range slots return the zero value.
+func (r *Ring[T]) At(i int) T {
+	var zero T
+	if i < 0 || i >= r.Cap() {
+		return zero
+	}
+	return r.buf[r.pos(i)]
+}
+
+// Set stores v in slot i (0 = newest). Out of range slots are ignored.
+func (r *Ring[T]) Set(i int, v T) {
+	if i < 0 || i >= r.Cap() {
+		return
+	}
+	r.buf[r.pos(i)] = v
+}
+
+// Push adds v as the newest entry, dropping the oldest one
+func (r *Ring[T]) Push(v T) {
+	if r.Cap() == 0 {
+		return
+	}
+	r.head = (r.head + 1) % len(r.buf)
+	r.buf[r.head] = v
+}
+
+// Shift ages every entry by n slots. The n newest slots become empty and the n oldest
+// entries are dropped.
+func (r *Ring[T]) Shift(n int) {
+	var zero T
+	if n > r.Cap() {
+		n = r.Cap()
+	}
+	for ; n > 0; n-- {
+		r.head = (r.head + 1) % len(r.buf)
+		r.buf[r.head] = zero
+	}
+}
+
+// NewestFirst returns the slots from newest to oldest
+func (r *Ring[T]) NewestFirst() []T {
+	out := make([]T, r.Cap())
+	for i := range out {
+		out[i] = r.At(i)
+	}
+	return out
+}
+
+// OldestFirst returns the slots from oldest to newest
+func (r *Ring[T]) OldestFirst() []T {
+	out := make([]T, r.Cap())
+	for i := range out {
+		out[i] = r.At(len(out) - 1 - i)
+	}
+	return out
+}
+
+// Clone returns a copy of the ring (entries are copied by value)
+func (r *Ring[T]) Clone() *Ring[T] {
+	if r == nil {
+		return nil
+	}
+	return &Ring[T]{buf: append([]T(nil), r.buf...), head: r.head}
+}
+
+// MarshalJSON encodes the ring as a newest-first array
+func (r *Ring[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.NewestFirst())
+}
+
+// UnmarshalJSON decodes a newest-first array; the capacity becomes the array length
+func (r *Ring[T]) UnmarshalJSON(data []byte) error {
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	r.buf = make([]T, len(items))
+	r.head = 0
+	for i, item := range items {
+		r.Set(i, item)
+	}
+	return nil
+}
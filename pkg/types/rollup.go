@@ -0,0 +1,70 @@
+package types
+
+// TPWRollupPoint is the thread pool write queue of a host over one rollup bucket
+type TPWRollupPoint struct {
+	TimeStamp int64   `json:"timeStamp"` // start of the bucket, epoch milliseconds
+	Avg       float64 `json:"avg"`
+	Max       uint32  `json:"max"`
+	Count     int     `json:"count"` // raw data points averaged, 0 = no data for the bucket
+}
+
+// TPWRollup is a downsampled thread pool write queue history: one bucket per IntervalMs,
+// slot 0 being the bucket of the latest data point. Buckets are aligned to the interval in
+// UTC, so hourly buckets start on the hour.
+type TPWRollup struct {
+	IntervalMs  int64                 `json:"intervalMs"`
+	NewestStart int64                 `json:"newestStart"` // start of the bucket in slot 0, 0 = empty
+	Points      *Ring[TPWRollupPoint] `json:"points"`
+}
+
+// NewTPWRollup creates an empty rollup of buckets of intervalMs
+func NewTPWRollup(intervalMs int64, buckets int) *TPWRollup {
+	return &TPWRollup{IntervalMs: intervalMs, Points: NewRing[TPWRollupPoint](buckets)}
+}
+
+// Add folds a raw data point into the bucket of its time stamp. A point newer than the latest
+// bucket starts a new one (buckets skipped over stay empty); a point older than the oldest
+// bucket is dropped.
+func (r *TPWRollup) Add(point TPWPoint) {
+	if !point.Exists || r.IntervalMs <= 0 {
+		return
+	}
+	start := point.TimeStamp - point.TimeStamp%r.IntervalMs
+	if r.NewestStart == 0 || start > r.NewestStart {
+		if r.NewestStart != 0 {
+			r.Points.Shift(int((start - r.NewestStart) / r.IntervalMs))
+		}
+		r.NewestStart = start
+	}
+
+	slot := int((r.NewestStart - start) / r.IntervalMs)
+	if slot >= r.Points.Cap() {
+		return
+	}
+	bucket := r.Points.At(slot)
+	if bucket.Count == 0 {
+		bucket = TPWRollupPoint{TimeStamp: start}
+	}
+	bucket.Count++
+	bucket.Avg += (float64(point.Queue) - bucket.Avg) / float64(bucket.Count)
+	bucket.Max = max(bucket.Max, point.Queue)
+	r.Points.Set(slot, bucket)
+}
+
+// Clone returns a copy of the rollup
+func (r *TPWRollup) Clone() *TPWRollup {
+	if r == nil {
+		return nil
+	}
+	return &TPWRollup{IntervalMs: r.IntervalMs, NewestStart: r.NewestStart, Points: r.Points.Clone()}
+}
+
+// Rollup returns the rollup of a host with buckets of intervalMs, or nil
+func (q *TPWQueue) Rollup(intervalMs int64) *TPWRollup {
+	for _, rollup := range q.Rollups {
+		if rollup.IntervalMs == intervalMs {
+			return rollup
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package types
+
+// Sample is an entry of a Series: a data point taken at a time, which may hold no data (a
+// missing data point, or a nil pointer)
+type Sample interface {
+	SampleTime() int64 // epoch milliseconds
+	HasData() bool
+}
+
+// Series is a time series kept in a Ring: slot 0 is the newest sample and slot Cap()-1 the
+// oldest. The Ring methods roll and read the slots; the Series methods read the samples by
+// whether they hold data, so callers do not each skip missing slots their own way.
+//
+// Like Ring, Series is not safe for concurrent use and is encoded in JSON as a newest-first
+// array.
+type Series[T Sample] struct {
+	Ring[T]
+}
+
+// NewSeries creates a series with capacity empty slots
+func NewSeries[T Sample](capacity int) *Series[T] {
+	return &Series[T]{Ring: *NewRing[T](capacity)}
+}
+
+// Clone returns a copy of the series (samples are copied by value)
+func (s *Series[T]) Clone() *Series[T] {
+	if s == nil {
+		return nil
+	}
+	return &Series[T]{Ring: *s.Ring.Clone()}
+}
+
+// Latest returns the newest sample holding data
+func (s *Series[T]) Latest() (T, bool) {
+	for i := 0; i < s.Cap(); i++ {
+		if sample := s.At(i); sample.HasData() {
+			return sample, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Window returns the n newest slots, oldest first, with or without data. A series of fewer
+// slots returns all of them.
+func (s *Series[T]) Window(n int) []T {
+	n = min(max(n, 0), s.Cap())
+	out := make([]T, n)
+	for i := range out {
+		out[i] = s.At(n - 1 - i)
+	}
+	return out
+}
+
+// WithData returns the samples holding data, newest first
+func (s *Series[T]) WithData() []T {
+	out := make([]T, 0, s.Cap())
+	for i := 0; i < s.Cap(); i++ {
+		if sample := s.At(i); sample.HasData() {
+			out = append(out, sample)
+		}
+	}
+	return out
+}
+
+// Closest returns the earlier sample whose age relative to the sample in slot 0 is closest to
+// lookback (milliseconds), if that age is within tolerance of lookback
+func (s *Series[T]) Closest(lookback, tolerance int64) (T, bool) {
+	var closest T
+	latest := s.At(0)
+	if !latest.HasData() {
+		return closest, false
+	}
+	found := false
+	var closestDiff int64
+	for i := 1; i < s.Cap(); i++ {
+		sample := s.At(i)
+		if !sample.HasData() || sample.SampleTime() >= latest.SampleTime() {
+			continue
+		}
+		diff := latest.SampleTime() - sample.SampleTime() - lookback
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= tolerance && (!found || diff < closestDiff) {
+			closest, closestDiff, found = sample, diff, true
+		}
+	}
+	return closest, found
+}
+
+// Delta returns by how much value grew from the sample in slot older to the one in slot newer,
+// if both hold data
+func (s *Series[T]) Delta(newer, older int, value func(T) float64) (float64, bool) {
+	to, from := s.At(newer), s.At(older)
+	if !to.HasData() || !from.HasData() {
+		return 0, false
+	}
+	return value(to) - value(from), true
+}
+
+// Rate returns the growth of value per second from the sample in slot older to the one in slot
+// newer, if both hold data and newer was taken later
+func (s *Series[T]) Rate(newer, older int, value func(T) float64) (float64, bool) {
+	delta, ok := s.Delta(newer, older, value)
+	if !ok {
+		return 0, false
+	}
+	elapsed := s.At(newer).SampleTime() - s.At(older).SampleTime()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return delta * 1000 / float64(elapsed), true
+}
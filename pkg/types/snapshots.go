@@ -0,0 +1,71 @@
+package types
+
+// SnapshotInsert is the outcome of inserting a snapshot into the history of a cluster
+type SnapshotInsert int
+
+const (
+	SnapshotAdded     SnapshotInsert = iota // stored as the latest snapshot
+	SnapshotReplaced                        // replaced the latest snapshot, taken by the same job run (a retry)
+	SnapshotDuplicate                       // taken at the time of the latest snapshot, dropped
+	SnapshotLate                            // older than the latest snapshot, dropped
+)
+
+// Dropped reports whether the snapshot was not stored
+func (o SnapshotInsert) Dropped() bool {
+	return o == SnapshotDuplicate || o == SnapshotLate
+}
+
+func (o SnapshotInsert) String() string {
+	switch o {
+	case SnapshotAdded:
+		return "added"
+	case SnapshotReplaced:
+		return "replaced"
+	case SnapshotDuplicate:
+		return "duplicate"
+	case SnapshotLate:
+		return "late"
+	}
+	return "unknown"
+}
+
+// identifiedSnapshot is a snapshot of a cluster history, identified within the history by
+// when it was taken and by the job run that took it
+type identifiedSnapshot interface {
+	snapshotID() (snapShotTime int64, runID string)
+}
+
+// insertSnapshot adds a snapshot to a history ring, slot 0 being the latest, idempotently: a
+// snapshot of the job run of the latest one replaces it, so a retried run keeps one snapshot
+// per cluster, and a snapshot taken at or before the time of the latest one is dropped, so
+// duplicate or late data does not break the order of the history.
+func insertSnapshot[T identifiedSnapshot](ring *Ring[T], snapshot T) SnapshotInsert {
+	latestTime, latestRun := ring.At(0).snapshotID()
+	snapShotTime, runID := snapshot.snapshotID()
+	switch {
+	case latestTime == 0:
+	case runID != "" && runID == latestRun && snapShotTime >= latestTime:
+		ring.Set(0, snapshot)
+		return SnapshotReplaced
+	case snapShotTime == latestTime:
+		return SnapshotDuplicate
+	case snapShotTime < latestTime:
+		return SnapshotLate
+	}
+	ring.Push(snapshot)
+	return SnapshotAdded
+}
+
+func (s *IndicesSnapShot) snapshotID() (int64, string) {
+	if s == nil {
+		return 0, ""
+	}
+	return s.SnapShotTime, s.RunID
+}
+
+func (s *ClusterDataWriteBulk_sTasks) snapshotID() (int64, string) {
+	if s == nil {
+		return 0, ""
+	}
+	return s.SnapShotTime, s.RunID
+}
@@ -0,0 +1,1070 @@
+package types
+
+// Accessors over the global data structures. Jobs and API handlers should use these
+// instead of locking the mutexes themselves so the locking discipline lives in one place.
+//
+// Cluster reads return copies (snapshots): callers can use them freely without holding
+// any lock, and changes must go through UpdateCluster or MutateClusters. Reads are served
+// from the atomically swapped views in views.go and never take a lock.
+
+import "sort"
+
+// Copy returns a deep copy of the cluster data
+func (cd *ClusterData) Copy() *ClusterData {
+	if cd == nil {
+		return nil
+	}
+
+	c := *cd
+	c.ClusterSAN = append([]string(nil), cd.ClusterSAN...)
+	c.KibanaSAN = append([]string(nil), cd.KibanaSAN...)
+	c.Nodes = make([]*Node, 0, len(cd.Nodes))
+	for _, node := range cd.Nodes {
+		c.Nodes = append(c.Nodes, node.Copy())
+	}
+	return &c
+}
+
+// Copy returns a deep copy of the node
+func (n *Node) Copy() *Node {
+	if n == nil {
+		return nil
+	}
+
+	c := *n
+	c.Type = append([]string(nil), n.Type...)
+	if n.AccessCred != nil {
+		cred := *n.AccessCred
+		c.AccessCred = &cred
+	}
+	return &c
+}
+
+// GetCluster returns a snapshot of a cluster
+func GetCluster(clusterName string) (*ClusterData, bool) {
+	cluster, exists := clustersView.Get(clusterName)
+	if !exists {
+		return nil, false
+	}
+	return cluster.Copy(), true
+}
+
+// ClusterExists reports whether a cluster is in the inventory
+func ClusterExists(clusterName string) bool {
+	_, exists := clustersView.Get(clusterName)
+	return exists
+}
+
+// ClusterCount returns the number of clusters in the inventory
+func ClusterCount() int {
+	return len(clustersView.Load())
+}
+
+// ClusterNames returns the sorted cluster names. The list is derived from the inventory on
+// every call, so it cannot drift from it when clusters are removed or renamed.
+func ClusterNames() []string {
+	return sortedKeys(clustersView.Load())
+}
+
+// ClusterTenant returns the tenant of a cluster ("" = none)
+func ClusterTenant(clusterName string) (string, bool) {
+	cluster, exists := clustersView.Get(clusterName)
+	if !exists {
+		return "", false
+	}
+	return cluster.Tenant, true
+}
+
+// ClustersOfTenant returns the sorted names of the clusters belonging to a tenant
+func ClustersOfTenant(tenant string) []string {
+	names := make([]string, 0)
+	for _, clusterName := range ClusterNames() {
+		if t, _ := ClusterTenant(clusterName); t == tenant {
+			names = append(names, clusterName)
+		}
+	}
+	return names
+}
+
+// SnapshotClusters returns snapshots of all clusters keyed by name
+func SnapshotClusters() map[string]*ClusterData {
+	published := clustersView.Load()
+	snapshot := make(map[string]*ClusterData, len(published))
+	for name, cluster := range published {
+		snapshot[name] = cluster.Copy()
+	}
+	return snapshot
+}
+
+// RangeClusters calls fn with a snapshot of each cluster until fn returns false.
+// No lock is held while fn runs.
+func RangeClusters(fn func(clusterName string, cluster *ClusterData) bool) {
+	for name, cluster := range SnapshotClusters() {
+		if !fn(name, cluster) {
+			return
+		}
+	}
+}
+
+// UpdateCluster applies fn to the live cluster under the write lock.
+// It returns false if the cluster doesn't exist.
+func UpdateCluster(clusterName string, fn func(cluster *ClusterData)) bool {
+	ClustersMu.Lock()
+	defer ClustersMu.Unlock()
+
+	cluster, exists := AllClusters[clusterName]
+	if !exists {
+		return false
+	}
+	fn(cluster)
+	clustersView.Publish(clusterName, cluster.Copy())
+	return true
+}
+
+// MutateClusters gives fn exclusive access to the live cluster map for bulk changes
+// (adding and removing clusters). The views are republished afterwards.
+func MutateClusters(fn func(clusters map[string]*ClusterData)) {
+	ClustersMu.Lock()
+	defer ClustersMu.Unlock()
+
+	fn(AllClusters)
+
+	published := make(map[string]*ClusterData, len(AllClusters))
+	for clusterName, cluster := range AllClusters {
+		published[clusterName] = cluster.Copy()
+	}
+	clustersView.Replace(published)
+}
+
+// GetHistory returns the indices history of a cluster
+func GetHistory(clusterName string) (*IndicesHistory, bool) {
+	HistoryMu.RLock()
+	defer HistoryMu.RUnlock()
+
+	history, exists := AllHistory[clusterName]
+	return history, exists
+}
+
+// GetOrCreateHistory returns the indices history of a cluster, creating it with the
+// given size if it doesn't exist yet
+func GetOrCreateHistory(clusterName string, size uint8) *IndicesHistory {
+	HistoryMu.Lock()
+	defer HistoryMu.Unlock()
+
+	history, exists := AllHistory[clusterName]
+	if !exists {
+		history = NewIndicesHistory(size)
+		AllHistory[clusterName] = history
+	}
+	return history
+}
+
+// SnapshotHistories returns copies of all indices histories keyed by cluster name
+func SnapshotHistories() map[string]*IndicesHistory {
+	HistoryMu.RLock()
+	defer HistoryMu.RUnlock()
+
+	snapshot := make(map[string]*IndicesHistory, len(AllHistory))
+	for clusterName, history := range AllHistory {
+		if history != nil {
+			snapshot[clusterName] = history.GetCopy()
+		}
+	}
+	return snapshot
+}
+
+// GetIndexingRate returns the latest indexing rate computed for a cluster (read-only)
+func GetIndexingRate(clusterName string) (*ClusterIndexingRate, bool) {
+	rate, exists := indexingRateView.Get(clusterName)
+	return rate, exists && rate != nil
+}
+
+// SetIndexingRate stores the indexing rate computed for a cluster and adds it to the
+// cluster's rate history, which keeps the latest historySize computations.
+// The rate must not be modified afterwards.
+func SetIndexingRate(clusterName string, rate *ClusterIndexingRate, historySize int) {
+	IndexingRateMu.Lock()
+	defer IndexingRateMu.Unlock()
+	AllIndexingRate[clusterName] = rate
+	indexingRateView.Publish(clusterName, rate)
+
+	history, exists := AllIndexingRateHistory[clusterName]
+	if !exists || history.HistorySize != historySize {
+		// Keep the newest entries of a history whose size changed
+		resized := &IndexingRateHistory{HistorySize: historySize, Rates: NewRing[*ClusterIndexingRate](historySize)}
+		if exists {
+			for i := historySize - 1; i >= 0; i-- {
+				resized.Rates.Push(history.Rates.At(i))
+			}
+		}
+		history = resized
+		AllIndexingRateHistory[clusterName] = history
+	}
+	// A rerun on the same indices snapshot replaces the computation instead of adding one
+	if latest := history.Rates.At(0); latest != nil && latest.Timestamp == rate.Timestamp {
+		history.Rates.Set(0, rate)
+		return
+	}
+	history.Rates.Push(rate)
+}
+
+// IndexingRateHistorySince returns the indexing rate computations of a cluster taken at or
+// after since (epoch milliseconds), oldest first (read-only)
+func IndexingRateHistorySince(clusterName string, since int64) ([]*ClusterIndexingRate, bool) {
+	IndexingRateMu.RLock()
+	defer IndexingRateMu.RUnlock()
+
+	history, exists := AllIndexingRateHistory[clusterName]
+	if !exists {
+		return nil, false
+	}
+	rates := make([]*ClusterIndexingRate, 0, history.Rates.Cap())
+	for _, rate := range history.Rates.OldestFirst() {
+		if rate != nil && rate.Timestamp >= since {
+			rates = append(rates, rate)
+		}
+	}
+	return rates, true
+}
+
+// IndexingRateHistories returns the rate computations kept per cluster, newest first (read-only)
+func IndexingRateHistories() map[string][]*ClusterIndexingRate {
+	IndexingRateMu.RLock()
+	defer IndexingRateMu.RUnlock()
+
+	result := make(map[string][]*ClusterIndexingRate, len(AllIndexingRateHistory))
+	for clusterName, history := range AllIndexingRateHistory {
+		result[clusterName] = history.Rates.NewestFirst()
+	}
+	return result
+}
+
+// IndexingRateCount returns the number of clusters with indexing rates
+func IndexingRateCount() int {
+	return len(indexingRateView.Load())
+}
+
+// GetCurrentMasterEndpoint returns the current master endpoint of a cluster
+func GetCurrentMasterEndpoint(clusterName string) (string, bool) {
+	CurrentMasterEndPtsMu.RLock()
+	defer CurrentMasterEndPtsMu.RUnlock()
+
+	endpoint, exists := AllCurrentMasterEndPoints[clusterName]
+	return endpoint, exists && endpoint != ""
+}
+
+// SetCurrentMasterEndpoint stores the current master endpoint of a cluster
+func SetCurrentMasterEndpoint(clusterName, endpoint string) {
+	CurrentMasterEndPtsMu.Lock()
+	defer CurrentMasterEndPtsMu.Unlock()
+	AllCurrentMasterEndPoints[clusterName] = endpoint
+}
+
+// EvictBulkTasksSnapshot drops the bulk task snapshot taken at snapShotTime from a cluster's
+// history (used to enforce memory budgets). The latest snapshot is never evicted.
+func EvictBulkTasksSnapshot(clusterName string, snapShotTime int64) bool {
+	ClusterDataWriteBulkTasksHistoryMu.Lock()
+	defer ClusterDataWriteBulkTasksHistoryMu.Unlock()
+
+	history, exists := AllClusterDataWriteBulk_sTasksHistory[clusterName]
+	if !exists || history == nil {
+		return false
+	}
+	for i := 1; i < history.PtrClusterDataWriteBulk_sTasks.Cap(); i++ {
+		snapshot := history.PtrClusterDataWriteBulk_sTasks.At(i)
+		if snapshot != nil && snapshot.SnapShotTime == snapShotTime {
+			history.PtrClusterDataWriteBulk_sTasks.Set(i, nil)
+			PublishBulkTasksHistory(clusterName, history)
+			return true
+		}
+	}
+	return false
+}
+
+// RecordCollection records the outcome of one collection of jobName for a cluster at
+// epochMillis (err == nil means success) and returns a copy of the updated status
+func RecordCollection(jobName, clusterName string, epochMillis int64, err error) CollectionStatus {
+	CollectionStatusMu.Lock()
+	defer CollectionStatusMu.Unlock()
+
+	byCluster, ok := AllCollectionStatus[jobName]
+	if !ok {
+		byCluster = make(map[string]*CollectionStatus)
+		AllCollectionStatus[jobName] = byCluster
+	}
+	status, ok := byCluster[clusterName]
+	if !ok {
+		status = &CollectionStatus{JobName: jobName, ClusterName: clusterName}
+		byCluster[clusterName] = status
+	}
+
+	status.LastAttempt = epochMillis
+	if err == nil {
+		status.LastSuccess = epochMillis
+		status.ConsecutiveFailures = 0
+	} else {
+		status.LastErrorTime = epochMillis
+		status.LastError = err.Error()
+		status.ConsecutiveFailures++
+	}
+	return *status
+}
+
+// SnapshotCollectionStatus returns copies of all collection statuses sorted by job and cluster
+func SnapshotCollectionStatus() []CollectionStatus {
+	CollectionStatusMu.RLock()
+	defer CollectionStatusMu.RUnlock()
+
+	result := make([]CollectionStatus, 0)
+	for _, jobName := range sortedKeys(AllCollectionStatus) {
+		byCluster := AllCollectionStatus[jobName]
+		for _, clusterName := range sortedKeys(byCluster) {
+			result = append(result, *byCluster[clusterName])
+		}
+	}
+	return result
+}
+
+// SetRetentionReport publishes the latest retention report of a cluster
+func SetRetentionReport(clusterName string, report *RetentionReport) {
+	RetentionMu.Lock()
+	defer RetentionMu.Unlock()
+	AllRetentionReports[clusterName] = report
+}
+
+// GetRetentionReport returns the latest retention report of a cluster (read-only)
+func GetRetentionReport(clusterName string) (*RetentionReport, bool) {
+	RetentionMu.RLock()
+	defer RetentionMu.RUnlock()
+	report, ok := AllRetentionReports[clusterName]
+	return report, ok
+}
+
+// RetentionReports returns the latest retention report of every cluster (read-only)
+func RetentionReports() map[string]*RetentionReport {
+	RetentionMu.RLock()
+	defer RetentionMu.RUnlock()
+	reports := make(map[string]*RetentionReport, len(AllRetentionReports))
+	for clusterName, report := range AllRetentionReports {
+		reports[clusterName] = report
+	}
+	return reports
+}
+
+// AddDiskUsage adds one disk usage sample per node to the history of a cluster, which keeps
+// the latest historySize samples per node. Nodes that are not sampled any more are dropped.
+func AddDiskUsage(clusterName string, snapShotTime int64, watermarks DiskWatermarks, roles map[string][]string,
+	points map[string]NodeDiskPoint, historySize int) {
+	DiskUsageMu.Lock()
+	defer DiskUsageMu.Unlock()
+
+	usage := &ClusterDiskUsage{
+		SnapShotTime: snapShotTime,
+		HistorySize:  historySize,
+		Watermarks:   watermarks,
+		Nodes:        make(map[string]*NodeDiskHistory, len(points)),
+	}
+	previous := AllDiskUsage[clusterName]
+
+	for hostName, point := range points {
+		history := &NodeDiskHistory{HostName: hostName, Roles: roles[hostName], Points: NewRing[NodeDiskPoint](historySize)}
+		if previous != nil {
+			if old, ok := previous.Nodes[hostName]; ok {
+				// Keep the newest samples of a history whose size changed
+				for i := min(historySize, old.Points.Cap()) - 1; i >= 0; i-- {
+					history.Points.Push(old.Points.At(i))
+				}
+			}
+		}
+		history.Points.Push(point)
+		usage.Nodes[hostName] = history
+	}
+
+	// Published histories are replaced, never modified
+	AllDiskUsage[clusterName] = usage
+}
+
+// GetDiskUsage returns the disk usage history of a cluster (read-only)
+func GetDiskUsage(clusterName string) (*ClusterDiskUsage, bool) {
+	DiskUsageMu.RLock()
+	defer DiskUsageMu.RUnlock()
+	usage, ok := AllDiskUsage[clusterName]
+	return usage, ok
+}
+
+// AddJVMStats adds one JVM sample per node to the history of a cluster, which keeps the
+// latest historySize samples per node. A node that did not report gets a missing sample, so
+// gaps stay visible; it is dropped once its history holds no reported sample any more.
+func AddJVMStats(clusterName string, snapShotTime int64, roles map[string][]string,
+	points map[string]NodeJVMPoint, historySize int) {
+	JVMStatsMu.Lock()
+	defer JVMStatsMu.Unlock()
+
+	stats := &ClusterJVMStats{
+		SnapShotTime: snapShotTime,
+		HistorySize:  historySize,
+		Nodes:        make(map[string]*NodeJVMHistory, len(points)),
+	}
+	previous := AllJVMStats[clusterName]
+
+	hostNames := make(map[string]bool, len(points))
+	for hostName := range points {
+		hostNames[hostName] = true
+	}
+	if previous != nil {
+		for hostName := range previous.Nodes {
+			hostNames[hostName] = true
+		}
+	}
+
+	for hostName := range hostNames {
+		history := &NodeJVMHistory{HostName: hostName, Roles: roles[hostName], Points: NewRing[NodeJVMPoint](historySize)}
+		if previous != nil {
+			if old, ok := previous.Nodes[hostName]; ok {
+				if history.Roles == nil {
+					history.Roles = old.Roles
+				}
+				// Keep the newest samples of a history whose size changed
+				for i := min(historySize, old.Points.Cap()) - 1; i >= 0; i-- {
+					history.Points.Push(old.Points.At(i))
+				}
+			}
+		}
+		point, reported := points[hostName]
+		if !reported {
+			point = NodeJVMPoint{TimeStamp: snapShotTime}
+		}
+		history.Points.Push(point)
+
+		for _, p := range history.Points.NewestFirst() {
+			if p.Exists {
+				stats.Nodes[hostName] = history
+				break
+			}
+		}
+	}
+
+	// Published histories are replaced, never modified
+	AllJVMStats[clusterName] = stats
+}
+
+// GetJVMStats returns the JVM history of a cluster (read-only)
+func GetJVMStats(clusterName string) (*ClusterJVMStats, bool) {
+	JVMStatsMu.RLock()
+	defer JVMStatsMu.RUnlock()
+	stats, ok := AllJVMStats[clusterName]
+	return stats, ok
+}
+
+// JVMStatsClusters returns the names of the clusters with a JVM history
+func JVMStatsClusters() []string {
+	JVMStatsMu.RLock()
+	defer JVMStatsMu.RUnlock()
+	clusterNames := make([]string, 0, len(AllJVMStats))
+	for clusterName := range AllJVMStats {
+		clusterNames = append(clusterNames, clusterName)
+	}
+	return clusterNames
+}
+
+// AddThreadPoolRejections adds one sample per node and thread pool to the history of a
+// cluster, which keeps the latest historySize samples per pool. The Delta of each sample is
+// computed against the previous sample of the pool; a counter that went down was reset by a
+// node restart, so its new value is all delta. Nodes and pools that are not sampled any more
+// are dropped.
+func AddThreadPoolRejections(clusterName string, snapShotTime int64, roles map[string][]string,
+	points map[string]map[string]RejectionPoint, historySize int) {
+	RejectionsMu.Lock()
+	defer RejectionsMu.Unlock()
+
+	rejections := &ClusterThreadPoolRejections{
+		SnapShotTime: snapShotTime,
+		HistorySize:  historySize,
+		Nodes:        make(map[string]*NodeRejectionHistory, len(points)),
+	}
+	previous := AllThreadPoolRejections[clusterName]
+
+	for hostName, pools := range points {
+		history := &NodeRejectionHistory{
+			HostName: hostName,
+			Roles:    roles[hostName],
+			Pools:    make(map[string]*Ring[RejectionPoint], len(pools)),
+		}
+		var old *NodeRejectionHistory
+		if previous != nil {
+			old = previous.Nodes[hostName]
+		}
+		for pool, point := range pools {
+			ring := NewRing[RejectionPoint](historySize)
+			if old != nil {
+				if oldRing, ok := old.Pools[pool]; ok {
+					// Keep the newest samples of a history whose size changed
+					for i := min(historySize, oldRing.Cap()) - 1; i >= 0; i-- {
+						ring.Push(oldRing.At(i))
+					}
+					if last := oldRing.At(0); last.TimeStamp != 0 {
+						if point.Rejected >= last.Rejected {
+							point.Delta = point.Rejected - last.Rejected
+						} else {
+							point.Delta = point.Rejected
+						}
+					}
+				}
+			}
+			ring.Push(point)
+			history.Pools[pool] = ring
+		}
+		rejections.Nodes[hostName] = history
+	}
+
+	// Published histories are replaced, never modified
+	AllThreadPoolRejections[clusterName] = rejections
+}
+
+// GetThreadPoolRejections returns the thread pool history of a cluster (read-only)
+func GetThreadPoolRejections(clusterName string) (*ClusterThreadPoolRejections, bool) {
+	RejectionsMu.RLock()
+	defer RejectionsMu.RUnlock()
+	rejections, ok := AllThreadPoolRejections[clusterName]
+	return rejections, ok
+}
+
+// AddSegmentStats adds one segments sample per node to the history of a cluster, which keeps
+// the latest historySize samples per node. Nodes that are not sampled any more are dropped.
+func AddSegmentStats(clusterName string, snapShotTime int64, roles map[string][]string,
+	points map[string]NodeSegmentPoint, historySize int) {
+	SegmentStatsMu.Lock()
+	defer SegmentStatsMu.Unlock()
+
+	stats := &ClusterSegmentStats{
+		SnapShotTime: snapShotTime,
+		HistorySize:  historySize,
+		Nodes:        make(map[string]*NodeSegmentHistory, len(points)),
+	}
+	previous := AllSegmentStats[clusterName]
+
+	for hostName, point := range points {
+		history := &NodeSegmentHistory{HostName: hostName, Roles: roles[hostName], Points: NewRing[NodeSegmentPoint](historySize)}
+		if previous != nil {
+			if old, ok := previous.Nodes[hostName]; ok {
+				// Keep the newest samples of a history whose size changed
+				for i := min(historySize, old.Points.Cap()) - 1; i >= 0; i-- {
+					history.Points.Push(old.Points.At(i))
+				}
+			}
+		}
+		history.Points.Push(point)
+		stats.Nodes[hostName] = history
+	}
+
+	// Published histories are replaced, never modified
+	AllSegmentStats[clusterName] = stats
+}
+
+// GetSegmentStats returns the segment history of a cluster (read-only)
+func GetSegmentStats(clusterName string) (*ClusterSegmentStats, bool) {
+	SegmentStatsMu.RLock()
+	defer SegmentStatsMu.RUnlock()
+	stats, ok := AllSegmentStats[clusterName]
+	return stats, ok
+}
+
+// SetRecoveries replaces the active shard recoveries of a cluster
+func SetRecoveries(clusterName string, recoveries *ClusterRecoveries) {
+	RecoveriesMu.Lock()
+	defer RecoveriesMu.Unlock()
+	AllRecoveries[clusterName] = recoveries
+}
+
+// GetRecoveries returns the active shard recoveries of a cluster (read-only)
+func GetRecoveries(clusterName string) (*ClusterRecoveries, bool) {
+	RecoveriesMu.RLock()
+	defer RecoveriesMu.RUnlock()
+	recoveries, ok := AllRecoveries[clusterName]
+	return recoveries, ok
+}
+
+// TrackTask starts tracking a task. It returns false if the task is tracked already.
+func TrackTask(task TrackedTask) bool {
+	TrackedTasksMu.Lock()
+	defer TrackedTasksMu.Unlock()
+
+	tasks, ok := AllTrackedTasks[task.ClusterName]
+	if !ok {
+		tasks = make(map[string]*TrackedTask)
+		AllTrackedTasks[task.ClusterName] = tasks
+	}
+	if _, exists := tasks[task.TaskID]; exists {
+		return false
+	}
+	tasks[task.TaskID] = &task
+	return true
+}
+
+// UntrackTask stops tracking a task. It returns false if the task is not tracked.
+func UntrackTask(clusterName, taskID string) bool {
+	TrackedTasksMu.Lock()
+	defer TrackedTasksMu.Unlock()
+
+	if _, exists := AllTrackedTasks[clusterName][taskID]; !exists {
+		return false
+	}
+	delete(AllTrackedTasks[clusterName], taskID)
+	if len(AllTrackedTasks[clusterName]) == 0 {
+		delete(AllTrackedTasks, clusterName)
+	}
+	return true
+}
+
+// UpdateTrackedTask stores the result of a poll of a task. A task untracked in the meantime
+// stays untracked.
+func UpdateTrackedTask(task TrackedTask) {
+	TrackedTasksMu.Lock()
+	defer TrackedTasksMu.Unlock()
+
+	if _, exists := AllTrackedTasks[task.ClusterName][task.TaskID]; exists {
+		AllTrackedTasks[task.ClusterName][task.TaskID] = &task
+	}
+}
+
+// TrackedTasks returns copies of the tracked tasks of a cluster ("" = all clusters), by
+// cluster and in the order they were added
+func TrackedTasks(clusterName string) []TrackedTask {
+	TrackedTasksMu.RLock()
+	defer TrackedTasksMu.RUnlock()
+
+	tasks := make([]TrackedTask, 0)
+	for name, byID := range AllTrackedTasks {
+		if clusterName != "" && name != clusterName {
+			continue
+		}
+		for _, task := range byID {
+			tasks = append(tasks, *task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].ClusterName != tasks[j].ClusterName {
+			return tasks[i].ClusterName < tasks[j].ClusterName
+		}
+		if tasks[i].AddedAt != tasks[j].AddedAt {
+			return tasks[i].AddedAt < tasks[j].AddedAt
+		}
+		return tasks[i].TaskID < tasks[j].TaskID
+	})
+	return tasks
+}
+
+// PruneTrackedTasks stops tracking the tasks that ended before endedBefore (epoch
+// milliseconds) and returns how many were dropped
+func PruneTrackedTasks(endedBefore int64) int {
+	TrackedTasksMu.Lock()
+	defer TrackedTasksMu.Unlock()
+
+	pruned := 0
+	for clusterName, byID := range AllTrackedTasks {
+		for taskID, task := range byID {
+			if task.State != TaskRunning && task.EndTime != 0 && task.EndTime < endedBefore {
+				delete(byID, taskID)
+				pruned++
+			}
+		}
+		if len(byID) == 0 {
+			delete(AllTrackedTasks, clusterName)
+		}
+	}
+	return pruned
+}
+
+// SetSettingsDrift replaces the settings drift report of a cluster
+func SetSettingsDrift(clusterName string, report *SettingsDriftReport) {
+	SettingsDriftMu.Lock()
+	defer SettingsDriftMu.Unlock()
+	AllSettingsDrift[clusterName] = report
+}
+
+// GetSettingsDrift returns the settings drift report of a cluster (read-only)
+func GetSettingsDrift(clusterName string) (*SettingsDriftReport, bool) {
+	SettingsDriftMu.RLock()
+	defer SettingsDriftMu.RUnlock()
+	report, ok := AllSettingsDrift[clusterName]
+	return report, ok
+}
+
+// SettingsDriftReports returns the settings drift report of every cluster (read-only)
+func SettingsDriftReports() map[string]*SettingsDriftReport {
+	SettingsDriftMu.RLock()
+	defer SettingsDriftMu.RUnlock()
+	reports := make(map[string]*SettingsDriftReport, len(AllSettingsDrift))
+	for clusterName, report := range AllSettingsDrift {
+		reports[clusterName] = report
+	}
+	return reports
+}
+
+// AddFieldCounts adds one field count sample per index to the history of a cluster, which
+// keeps the latest historySize samples per index. Indices that are not sampled any more
+// (deleted, closed or no longer matching) are dropped.
+func AddFieldCounts(clusterName string, snapShotTime int64, counts map[string]IndexFieldCount, historySize int) {
+	FieldCountsMu.Lock()
+	defer FieldCountsMu.Unlock()
+
+	fieldCounts := &ClusterFieldCounts{
+		SnapShotTime: snapShotTime,
+		HistorySize:  historySize,
+		Indices:      make(map[string]*IndexFieldCount, len(counts)),
+	}
+	previous := AllFieldCounts[clusterName]
+
+	for index, count := range counts {
+		current := count
+		current.Index = index
+		current.Points = NewRing[FieldCountPoint](historySize)
+		if previous != nil {
+			if old, ok := previous.Indices[index]; ok {
+				// Keep the newest samples of a history whose size changed
+				for i := min(historySize, old.Points.Cap()) - 1; i >= 0; i-- {
+					current.Points.Push(old.Points.At(i))
+				}
+			}
+		}
+		current.Points.Push(FieldCountPoint{TimeStamp: snapShotTime, Fields: count.Fields})
+		fieldCounts.Indices[index] = &current
+	}
+
+	// Published histories are replaced, never modified
+	AllFieldCounts[clusterName] = fieldCounts
+}
+
+// GetFieldCounts returns the field count history of a cluster (read-only)
+func GetFieldCounts(clusterName string) (*ClusterFieldCounts, bool) {
+	FieldCountsMu.RLock()
+	defer FieldCountsMu.RUnlock()
+	fieldCounts, ok := AllFieldCounts[clusterName]
+	return fieldCounts, ok
+}
+
+// AddPipelineStats adds one sample of the ingest pipelines of a cluster, given the cumulative
+// counters per node and pipeline, to the history of the cluster, which keeps the latest
+// historySize samples per pipeline. Pipelines no node reports any more are dropped.
+//
+// The deltas of a pipeline are summed over its nodes. A counter that went down was reset by
+// a node restart, so its new value is all growth; a node not seen in the previous sample
+// adds no growth, as its counters may cover a long time.
+func AddPipelineStats(clusterName string, snapShotTime int64, counters map[string]map[string]PipelineCounters, historySize int) {
+	PipelineStatsMu.Lock()
+	defer PipelineStatsMu.Unlock()
+
+	stats := &ClusterPipelineStats{
+		SnapShotTime: snapShotTime,
+		HistorySize:  historySize,
+		Pipelines:    make(map[string]*PipelineHistory),
+		NodeCounters: counters,
+	}
+	previous := AllPipelineStats[clusterName]
+
+	growth := func(previous, current uint64) uint64 {
+		if current < previous {
+			return current
+		}
+		return current - previous
+	}
+
+	points := make(map[string]PipelinePoint)
+	nodes := make(map[string]int)
+	for hostName, pipelines := range counters {
+		var oldNode map[string]PipelineCounters
+		if previous != nil {
+			oldNode = previous.NodeCounters[hostName]
+		}
+		for pipeline, current := range pipelines {
+			point := points[pipeline]
+			point.TimeStamp = snapShotTime
+			point.Count += current.Count
+			point.TimeMs += current.TimeMs
+			point.Failed += current.Failed
+			point.Current += current.Current
+			if oldNode != nil {
+				old := oldNode[pipeline] // a pipeline new on the node starts from zero
+				point.CountDelta += growth(old.Count, current.Count)
+				point.TimeMsDelta += growth(old.TimeMs, current.TimeMs)
+				point.FailedDelta += growth(old.Failed, current.Failed)
+			}
+			points[pipeline] = point
+			nodes[pipeline]++
+		}
+	}
+
+	for pipeline, point := range points {
+		history := &PipelineHistory{Pipeline: pipeline, Nodes: nodes[pipeline], Points: NewRing[PipelinePoint](historySize)}
+		if previous != nil {
+			if old, ok := previous.Pipelines[pipeline]; ok {
+				// Keep the newest samples of a history whose size changed
+				for i := min(historySize, old.Points.Cap()) - 1; i >= 0; i-- {
+					history.Points.Push(old.Points.At(i))
+				}
+			}
+		}
+		history.Points.Push(point)
+		stats.Pipelines[pipeline] = history
+	}
+
+	// Published histories are replaced, never modified
+	AllPipelineStats[clusterName] = stats
+}
+
+// GetPipelineStats returns the ingest pipeline history of a cluster (read-only)
+func GetPipelineStats(clusterName string) (*ClusterPipelineStats, bool) {
+	PipelineStatsMu.RLock()
+	defer PipelineStatsMu.RUnlock()
+	stats, ok := AllPipelineStats[clusterName]
+	return stats, ok
+}
+
+// SetRemotes replaces the remote clusters of a cluster
+func SetRemotes(clusterName string, remotes *ClusterRemotes) {
+	RemotesMu.Lock()
+	defer RemotesMu.Unlock()
+	AllRemotes[clusterName] = remotes
+}
+
+// GetRemotes returns the remote clusters of a cluster (read-only)
+func GetRemotes(clusterName string) (*ClusterRemotes, bool) {
+	RemotesMu.RLock()
+	defer RemotesMu.RUnlock()
+	remotes, ok := AllRemotes[clusterName]
+	return remotes, ok
+}
+
+// RemotesByCluster returns the remote clusters of every cluster (read-only)
+func RemotesByCluster() map[string]*ClusterRemotes {
+	RemotesMu.RLock()
+	defer RemotesMu.RUnlock()
+	remotes := make(map[string]*ClusterRemotes, len(AllRemotes))
+	for clusterName, clusterRemotes := range AllRemotes {
+		remotes[clusterName] = clusterRemotes
+	}
+	return remotes
+}
+
+// RecordMaster records the elected master a lookup found at nowMs and returns the transition
+// when the master changed; the latest historySize transitions are kept
+func RecordMaster(clusterName, master string, nowMs int64, historySize int) (MasterTransition, bool) {
+	MasterHistoryMu.Lock()
+	defer MasterHistoryMu.Unlock()
+
+	previous, ok := AllMasterHistory[clusterName]
+	if !ok {
+		AllMasterHistory[clusterName] = &MasterHistory{
+			Master:      master,
+			Since:       nowMs,
+			LastChecked: nowMs,
+			Transitions: NewRing[MasterTransition](historySize),
+		}
+		return MasterTransition{}, false
+	}
+
+	// Published histories are replaced, never modified
+	history := *previous
+	history.LastChecked = nowMs
+	var transition MasterTransition
+	changed := master != previous.Master
+	if changed || historySize != previous.Transitions.Cap() {
+		history.Transitions = NewRing[MasterTransition](historySize)
+		for i := min(historySize, previous.Transitions.Cap()) - 1; i >= 0; i-- {
+			history.Transitions.Push(previous.Transitions.At(i))
+		}
+	}
+	if changed {
+		transition = MasterTransition{TimeStamp: nowMs, From: previous.Master, To: master}
+		history.Transitions.Push(transition)
+		history.Master = master
+		history.Since = nowMs
+		history.Changes++
+	}
+	AllMasterHistory[clusterName] = &history
+	return transition, changed
+}
+
+// GetMasterHistory returns the elected master history of a cluster (read-only)
+func GetMasterHistory(clusterName string) (*MasterHistory, bool) {
+	MasterHistoryMu.RLock()
+	defer MasterHistoryMu.RUnlock()
+	history, ok := AllMasterHistory[clusterName]
+	return history, ok
+}
+
+// SetTiers replaces the data tier breakdown of a cluster
+func SetTiers(clusterName string, tiers *ClusterTiers) {
+	TiersMu.Lock()
+	defer TiersMu.Unlock()
+	AllTiers[clusterName] = tiers
+}
+
+// GetTiers returns the data tier breakdown of a cluster (read-only)
+func GetTiers(clusterName string) (*ClusterTiers, bool) {
+	TiersMu.RLock()
+	defer TiersMu.RUnlock()
+	tiers, ok := AllTiers[clusterName]
+	return tiers, ok
+}
+
+// RemoveClusterData removes everything collected for a cluster from all global structures
+// (the inventory entry itself is managed through MutateClusters)
+func RemoveClusterData(clusterName string) {
+	HistoryMu.Lock()
+	delete(AllHistory, clusterName)
+	HistoryMu.Unlock()
+
+	IndexingRateMu.Lock()
+	delete(AllIndexingRate, clusterName)
+	delete(AllIndexingRateHistory, clusterName)
+	indexingRateView.Remove(clusterName)
+	IndexingRateMu.Unlock()
+
+	StatsByDayMu.Lock()
+	delete(AllStatsByDay, clusterName)
+	statsByDayView.Remove(clusterName)
+	StatsByDayMu.Unlock()
+
+	TPWQueueMu.Lock()
+	delete(AllThreadPoolWriteQueues, clusterName)
+	tpwQueueView.Remove(clusterName)
+	TPWQueueMu.Unlock()
+
+	CurrentMasterEndPtsMu.Lock()
+	delete(AllCurrentMasterEndPoints, clusterName)
+	CurrentMasterEndPtsMu.Unlock()
+
+	ClusterDataWriteBulkTasksHistoryMu.Lock()
+	delete(AllClusterDataWriteBulk_sTasksHistory, clusterName)
+	bulkTasksHistoryView.Remove(clusterName)
+	delete(AllTaskActionHistory, clusterName)
+	taskActionView.Remove(clusterName)
+	ClusterDataWriteBulkTasksHistoryMu.Unlock()
+
+	CollectionStatusMu.Lock()
+	for _, byCluster := range AllCollectionStatus {
+		delete(byCluster, clusterName)
+	}
+	CollectionStatusMu.Unlock()
+
+	RetentionMu.Lock()
+	delete(AllRetentionReports, clusterName)
+	RetentionMu.Unlock()
+
+	DiskUsageMu.Lock()
+	delete(AllDiskUsage, clusterName)
+	DiskUsageMu.Unlock()
+
+	JVMStatsMu.Lock()
+	delete(AllJVMStats, clusterName)
+	JVMStatsMu.Unlock()
+
+	RejectionsMu.Lock()
+	delete(AllThreadPoolRejections, clusterName)
+	RejectionsMu.Unlock()
+
+	SegmentStatsMu.Lock()
+	delete(AllSegmentStats, clusterName)
+	SegmentStatsMu.Unlock()
+
+	RecoveriesMu.Lock()
+	delete(AllRecoveries, clusterName)
+	RecoveriesMu.Unlock()
+
+	TrackedTasksMu.Lock()
+	delete(AllTrackedTasks, clusterName)
+	TrackedTasksMu.Unlock()
+
+	SettingsDriftMu.Lock()
+	delete(AllSettingsDrift, clusterName)
+	SettingsDriftMu.Unlock()
+
+	FieldCountsMu.Lock()
+	delete(AllFieldCounts, clusterName)
+	FieldCountsMu.Unlock()
+
+	PipelineStatsMu.Lock()
+	delete(AllPipelineStats, clusterName)
+	PipelineStatsMu.Unlock()
+
+	RemotesMu.Lock()
+	delete(AllRemotes, clusterName)
+	RemotesMu.Unlock()
+
+	MasterHistoryMu.Lock()
+	delete(AllMasterHistory, clusterName)
+	MasterHistoryMu.Unlock()
+
+	TiersMu.Lock()
+	delete(AllTiers, clusterName)
+	TiersMu.Unlock()
+}
+
+// RemoveNodeData removes a decommissioned node from the host-keyed structures of its cluster:
+// its thread pool write queue and its entries in the bulk and other task action snapshots
+func RemoveNodeData(clusterName, hostName string) {
+	TPWQueueMu.Lock()
+	if queue, ok := AllThreadPoolWriteQueues[clusterName]; ok && queue != nil {
+		if _, ok := queue.HostTPWQueue[hostName]; ok {
+			delete(queue.HostTPWQueue, hostName)
+			queue.HostnameList = withoutString(queue.HostnameList, hostName)
+			PublishTPWQueue(clusterName, queue)
+		}
+	}
+	TPWQueueMu.Unlock()
+
+	ClusterDataWriteBulkTasksHistoryMu.Lock()
+	defer ClusterDataWriteBulkTasksHistoryMu.Unlock()
+	if history, ok := AllClusterDataWriteBulk_sTasksHistory[clusterName]; ok && history != nil {
+		if removeNodeTasks(history, hostName) {
+			PublishBulkTasksHistory(clusterName, history)
+		}
+	}
+	for action, history := range AllTaskActionHistory[clusterName] {
+		if history != nil && removeNodeTasks(history, hostName) {
+			PublishTaskActionHistory(clusterName, action, history)
+		}
+	}
+}
+
+// removeNodeTasks drops a host from every snapshot of a task history and reports whether it
+// was in one. Published copies share the snapshots, so those holding the host are replaced by
+// copies without it. The per-index aggregates are kept, they cover the tasks of all nodes.
+func removeNodeTasks(history *ClusterDataWriteBulk_sTasksHistory, hostName string) bool {
+	removed := false
+	snapshots := history.PtrClusterDataWriteBulk_sTasks
+	for i := 0; i < snapshots.Cap(); i++ {
+		snapshot := snapshots.At(i)
+		if snapshot == nil {
+			continue
+		}
+		if _, ok := snapshot.DataWriteBulk_sTasksByNode[hostName]; !ok {
+			continue
+		}
+		pruned := *snapshot
+		pruned.DataWriteBulk_sTasksByNode = make(map[string]*NodeDataWriteBulk_sTasks, len(snapshot.DataWriteBulk_sTasksByNode))
+		for host, tasks := range snapshot.DataWriteBulk_sTasksByNode {
+			if host != hostName {
+				pruned.DataWriteBulk_sTasksByNode[host] = tasks
+			}
+		}
+		pruned.SortedHostsOnTasks = withoutString(snapshot.SortedHostsOnTasks, hostName)
+		pruned.SortedHostsOnTimetaken = withoutString(snapshot.SortedHostsOnTimetaken, hostName)
+		pruned.SortedHostsOnRequest = withoutString(snapshot.SortedHostsOnRequest, hostName)
+		snapshots.Set(i, &pruned)
+		removed = true
+	}
+	return removed
+}
+
+// withoutString returns list without s, as a new slice
+func withoutString(list []string, s string) []string {
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package types
+
+// Add stores a data point in the slot of its bucket, keyed by time stamp rather than by the
+// order the points arrive in: a point newer than the newest bucket starts a new one (buckets
+// skipped over stay empty), a point of a bucket already held replaces it when it is at least
+// as new, and a point older than the oldest bucket is dropped. Adding a point twice changes
+// nothing.
+func (q *TPWQueue) Add(point TPWPoint) {
+	if !point.Exists || q.IntervalMs <= 0 {
+		return
+	}
+	start := point.TimeStamp - point.TimeStamp%q.IntervalMs
+	if q.NewestStart == 0 || start > q.NewestStart {
+		if q.NewestStart != 0 {
+			q.Points.Shift(int(min((start-q.NewestStart)/q.IntervalMs, int64(q.Points.Cap()))))
+		}
+		q.NewestStart = start
+	}
+
+	slot := int((q.NewestStart - start) / q.IntervalMs)
+	if slot >= q.Points.Cap() {
+		return
+	}
+	if held := q.Points.At(slot); held.Exists && held.TimeStamp > point.TimeStamp {
+		return
+	}
+	q.Points.Set(slot, point)
+}
+
+// SlotStart returns the start of the bucket of slot i, 0 for a queue filled in arrival order
+// or without data
+func (q *TPWQueue) SlotStart(i int) int64 {
+	if q.IntervalMs <= 0 || q.NewestStart == 0 {
+		return 0
+	}
+	return q.NewestStart - int64(i)*q.IntervalMs
+}
+
+// View returns the n buckets ending with the bucket starting at newestStart, oldest first, as
+// data points: buckets without data have their start as time stamp and Exists false (no time
+// stamp at all without a newestStart). Views of the hosts of a cluster ending at the same
+// bucket line up slot by slot, wherever each host's own data ends. A queue filled in arrival
+// order returns its n newest slots.
+func (q *TPWQueue) View(newestStart int64, n int) []TPWPoint {
+	if q.IntervalMs <= 0 {
+		return q.Points.Window(n)
+	}
+	view := make([]TPWPoint, max(n, 0))
+	if newestStart <= 0 {
+		return view
+	}
+	newestStart -= newestStart % q.IntervalMs
+	for k := range view {
+		start := newestStart - int64(len(view)-1-k)*q.IntervalMs
+		view[k] = TPWPoint{TimeStamp: start}
+		if q.NewestStart == 0 || start > q.NewestStart {
+			continue
+		}
+		if point := q.Points.At(int((q.NewestStart - start) / q.IntervalMs)); point.Exists {
+			view[k] = point
+		}
+	}
+	return view
+}
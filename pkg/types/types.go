@@ -14,6 +14,11 @@ type Node struct {
 	DataCenter   string   `json:"dataCenter" yaml:"dataCenter"`
 	Rack         string   `json:"rack" yaml:"rack"`
 	NodeTier     string   `json:"nodeTier" yaml:"nodeTier"` // hot, warm, cold
+	// AccessCred overrides the cluster credentials when this node is contacted directly
+	AccessCred *AccessCred `json:"accessCred,omitempty" yaml:"accessCred,omitempty"`
+	// PortFromCredentials is set when the credentials CSV set Port, which loadFromMasterCSV
+	// then leaves alone
+	PortFromCredentials bool `json:"-" yaml:"-"`
 }
 
 // AccessCred holds authentication credentials
@@ -40,10 +45,35 @@ type ClusterData struct {
 	KibanaSAN       []string
 	Owner           string
 	Env             string
+	Tenant          string // business unit the cluster belongs to, "" = none
 	ClusterPort     string // Default: "9200"
 	KibanaPort      string // Default: "5601"
 	AccessCred      AccessCred
 	Nodes           []*Node
+
+	// Layout of the cluster's node stats in its monitoring cluster: legacy or agent, "" = the
+	// profile of the monitoring cluster
+	MonitoringProfile string
+}
+
+// GetNode returns the node with the given host name, or nil if the cluster doesn't have it
+func (cd *ClusterData) GetNode(hostName string) *Node {
+	for _, node := range cd.Nodes {
+		if node.HostName == hostName {
+			return node
+		}
+	}
+	return nil
+}
+
+// CredentialsForNode returns the credentials to use when contacting a node directly.
+// A node-level override is used when it has a preferred access method, otherwise
+// the cluster credentials apply.
+func (cd *ClusterData) CredentialsForNode(hostName string) *AccessCred {
+	if node := cd.GetNode(hostName); node != nil && node.AccessCred != nil && node.AccessCred.Preferred != 0 {
+		return node.AccessCred
+	}
+	return &cd.AccessCred
 }
 
 // IndexInfo represents information about an index
@@ -60,17 +90,71 @@ type IndexInfo struct {
 	PrimaryStorage uint64 `json:"primaryStorage"` // pri.store.size in bytes
 }
 
+// IndicesTotals are the cluster-wide totals of an indices snapshot, over all listed indices
+// (MapIndices only keeps the latest index of each index base)
+type IndicesTotals struct {
+	IndexCount     int    `json:"indexCount"`
+	OpenIndices    int    `json:"openIndices"`
+	ClosedIndices  int    `json:"closedIndices"`
+	Green          int    `json:"green"`
+	Yellow         int    `json:"yellow"`
+	Red            int    `json:"red"`
+	UnknownHealth  int    `json:"unknownHealth"` // closed indices report no health
+	DocCount       uint64 `json:"docCount"`
+	TotalStorage   uint64 `json:"totalStorage"`   // bytes
+	PrimaryStorage uint64 `json:"primaryStorage"` // bytes
+}
+
+// Add counts an index in the totals
+func (t *IndicesTotals) Add(index *IndexInfo) {
+	t.IndexCount++
+	if index.IsOpen {
+		t.OpenIndices++
+	} else {
+		t.ClosedIndices++
+	}
+	switch index.Health {
+	case 1:
+		t.Green++
+	case 2:
+		t.Yellow++
+	case 3:
+		t.Red++
+	default:
+		t.UnknownHealth++
+	}
+	t.DocCount += index.DocCount
+	t.TotalStorage += index.TotalStorage
+	t.PrimaryStorage += index.PrimaryStorage
+}
+
 // IndicesSnapShot represents a snapshot of indices at a point in time
 type IndicesSnapShot struct {
-	SnapShotTime int64                 `json:"snapShotTime"` // epoch milliseconds
-	MapIndices   map[string]*IndexInfo `json:"mapIndices"`   // map[index_base]*IndexInfo
+	SnapShotTime int64                 `json:"snapShotTime"`    // epoch milliseconds
+	RunID        string                `json:"runId,omitempty"` // job run that took the snapshot
+	MapIndices   map[string]*IndexInfo `json:"mapIndices"`      // map[index_base]*IndexInfo
+	Totals       IndicesTotals         `json:"totals"`
+	// Previous is the generation before the latest one of each rolled over index base, so
+	// rates can follow an index base through a rollover
+	Previous map[string]*IndexInfo `json:"previous,omitempty"` // map[index_base]*IndexInfo
 }
 
+// SampleTime returns the time the snapshot was taken
+func (s *IndicesSnapShot) SampleTime() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.SnapShotTime
+}
+
+// HasData reports whether there is a snapshot
+func (s *IndicesSnapShot) HasData() bool { return s != nil }
+
 // IndicesHistory maintains history of index snapshots
 type IndicesHistory struct {
-	SizeOfPtr uint8              `json:"sizeOfPtr"`
-	Ptr       []*IndicesSnapShot `json:"ptr"`
-	mu        sync.RWMutex       // for thread-safe access
+	SizeOfPtr uint8                     `json:"sizeOfPtr"`
+	Snapshots *Series[*IndicesSnapShot] `json:"snapshots"` // slot 0 is the latest snapshot
+	mu        sync.RWMutex              // for thread-safe access
 }
 
 // IndexingRate represents indexing rate metrics
@@ -82,10 +166,35 @@ type IndexingRate struct {
 	NumberOfShards uint8   `json:"numberOfShards"` // number of primary shards
 }
 
+// ClusterIngestRate is the ingest rate of a whole cluster: the per-shard rates of its indices
+// times their primary shards, summed. -1 = no index had enough data for the window.
+type ClusterIngestRate struct {
+	Last3Minutes  float64 `json:"last3Minutes"`  // bytes/s
+	Last15Minutes float64 `json:"last15Minutes"` // bytes/s
+	Last60Minutes float64 `json:"last60Minutes"` // bytes/s
+}
+
+// RateWindows are the actual time spans the rates of a computation were taken over: the time
+// between the latest snapshot and the one closest to each lookback. 0 = no snapshot close
+// enough to the lookback.
+type RateWindows struct {
+	Last3Minutes  int64 `json:"last3Minutes"`  // milliseconds
+	Last15Minutes int64 `json:"last15Minutes"` // milliseconds
+	Last60Minutes int64 `json:"last60Minutes"` // milliseconds
+}
+
 // ClusterIndexingRate represents indexing rate for all indices in a cluster
 type ClusterIndexingRate struct {
 	Timestamp  int64                    `json:"timestamp"`  // epoch milliseconds
 	MapIndices map[string]*IndexingRate `json:"mapIndices"` // map[index_base]*IndexingRate
+	Total      ClusterIngestRate        `json:"total"`
+	Windows    RateWindows              `json:"windows"`
+}
+
+// IndexingRateHistory keeps the recent indexing rate computations of a cluster
+type IndexingRateHistory struct {
+	HistorySize int                         `json:"historySize"`
+	Rates       *Ring[*ClusterIndexingRate] `json:"rates"` // slot 0 is the latest computation
 }
 
 // IndexStat represents statistics for an index at a point in time
@@ -95,11 +204,31 @@ type IndexStat struct {
 	DocCount  uint64 `json:"docCount"`  // document count
 }
 
+// SampleTime returns the time the statistics were taken
+func (s *IndexStat) SampleTime() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.StatTime
+}
+
+// HasData reports whether there are statistics
+func (s *IndexStat) HasData() bool { return s != nil }
+
 // IndexStatHistory maintains daily statistics for an index
 type IndexStatHistory struct {
-	IndexName string       `json:"indexName"`
-	SizeOfPtr uint8        `json:"sizeOfPtr"`
-	StatsPtr  []*IndexStat `json:"statsPtr"`
+	IndexName string              `json:"indexName"`
+	SizeOfPtr uint8               `json:"sizeOfPtr"`
+	Stats     *Series[*IndexStat] `json:"statsPtr"` // slot n holds the stats of n days ago
+}
+
+// NewIndexStatHistory creates an empty daily statistics history keeping historyDays days
+func NewIndexStatHistory(indexName string, historyDays uint8) *IndexStatHistory {
+	return &IndexStatHistory{
+		IndexName: indexName,
+		SizeOfPtr: historyDays,
+		Stats:     NewSeries[*IndexStat](int(historyDays) + 1),
+	}
 }
 
 // IndicesStatsByDay maintains daily statistics for all indices in a cluster
@@ -108,25 +237,417 @@ type IndicesStatsByDay struct {
 	StatHistory    map[string]*IndexStatHistory `json:"statHistory"`    // map[indexName]*IndexStatHistory
 }
 
-// TPWQueue stores thread pool write queue metrics for a host
+// IndexRetention is the daily growth and retention status of an index
+type IndexRetention struct {
+	Index        string `json:"index"`
+	DataStream   string `json:"dataStream,omitempty"` // for data stream backing indices
+	CreationTime int64  `json:"creationTime"`         // epoch milliseconds
+	TotalSize    uint64 `json:"totalSize"`            // bytes
+	DocCount     uint64 `json:"docCount"`
+	HasGrowth    bool   `json:"hasGrowth"`           // false until the daily stats hold the day before
+	SizeGrowth   int64  `json:"sizeGrowth"`          // bytes since the day before
+	DocGrowth    int64  `json:"docGrowth"`           // documents since the day before
+	Policy       string `json:"policy,omitempty"`    // retention pattern matching the index
+	MaxAge       string `json:"maxAge,omitempty"`    // retention of the policy, as configured
+	Violation    bool   `json:"violation"`           // older than the retention plus grace period
+	OverdueMs    int64  `json:"overdueMs,omitempty"` // how long ago the index should have been deleted
+}
+
+// RetentionReport is the outcome of a retention check of a cluster. Reports are replaced,
+// never modified, once published.
+type RetentionReport struct {
+	ClusterName string            `json:"clusterName"`
+	CheckTime   int64             `json:"checkTime"`  // epoch milliseconds
+	SizeGrowth  int64             `json:"sizeGrowth"` // bytes since the day before, over the indices with growth
+	DocGrowth   int64             `json:"docGrowth"`
+	Violations  int               `json:"violations"`
+	Indices     []*IndexRetention `json:"indices"` // sorted by size growth, largest first
+}
+
+// Disk watermark levels, in increasing order of severity
+const (
+	DiskLevelNone       = ""
+	DiskLevelLow        = "low"
+	DiskLevelHigh       = "high"
+	DiskLevelFloodStage = "floodStage"
+)
+
+// DiskWatermarks are the disk allocation watermarks of a cluster, as configured in its
+// cluster settings: a percentage or ratio of used disk, or an absolute amount of free disk
+type DiskWatermarks struct {
+	Low        string `json:"low"`
+	High       string `json:"high"`
+	FloodStage string `json:"floodStage"`
+}
+
+// NodeDiskPoint is one disk usage sample of a node
+type NodeDiskPoint struct {
+	TimeStamp      int64   `json:"timeStamp"` // epoch milliseconds (UTC)
+	TotalBytes     uint64  `json:"totalBytes"`
+	AvailableBytes uint64  `json:"availableBytes"`
+	UsedPercent    float64 `json:"usedPercent"`
+	Level          string  `json:"level,omitempty"` // highest watermark breached, "" = none
+}
+
+// NodeDiskHistory keeps the disk usage samples of a node
+type NodeDiskHistory struct {
+	HostName string               `json:"hostName"`
+	Roles    []string             `json:"roles"`
+	Points   *Ring[NodeDiskPoint] `json:"points"` // slot 0 is the latest sample
+}
+
+// ClusterDiskUsage holds the disk usage history of all nodes of a cluster
+type ClusterDiskUsage struct {
+	SnapShotTime int64                       `json:"snapShotTime"` // epoch milliseconds (UTC) of the latest collection
+	HistorySize  int                         `json:"historySize"`
+	Watermarks   DiskWatermarks              `json:"watermarks"`
+	Nodes        map[string]*NodeDiskHistory `json:"nodes"` // key: hostName
+}
+
+// NodeJVMPoint is one JVM heap and garbage collection sample of a node. The GC counters are
+// cumulative since the node started.
+type NodeJVMPoint struct {
+	TimeStamp       int64   `json:"timeStamp"` // epoch milliseconds (UTC)
+	HeapUsedPercent float64 `json:"heapUsedPercent"`
+	HeapUsedBytes   uint64  `json:"heapUsedBytes"`
+	HeapMaxBytes    uint64  `json:"heapMaxBytes"`
+	OldGCCount      uint64  `json:"oldGCCount"`
+	OldGCTimeMs     uint64  `json:"oldGCTimeMs"`
+	YoungGCCount    uint64  `json:"youngGCCount"`
+	YoungGCTimeMs   uint64  `json:"youngGCTimeMs"`
+	Exists          bool    `json:"exists"` // false when the node did not report in the collection
+}
+
+// NodeJVMHistory keeps the JVM samples of a node
+type NodeJVMHistory struct {
+	HostName string              `json:"hostName"`
+	Roles    []string            `json:"roles"`
+	Points   *Ring[NodeJVMPoint] `json:"points"` // slot 0 is the latest sample
+}
+
+// ClusterJVMStats holds the JVM history of all nodes of a cluster
+type ClusterJVMStats struct {
+	SnapShotTime int64                      `json:"snapShotTime"` // epoch milliseconds (UTC) of the latest collection
+	HistorySize  int                        `json:"historySize"`
+	Nodes        map[string]*NodeJVMHistory `json:"nodes"` // key: hostName
+}
+
+// RejectionPoint is one sample of a thread pool of a node
+type RejectionPoint struct {
+	TimeStamp int64  `json:"timeStamp"` // epoch milliseconds (UTC)
+	Rejected  uint64 `json:"rejected"`  // cumulative since the node started
+	Delta     uint64 `json:"delta"`     // rejections since the previous sample
+	Queue     uint32 `json:"queue"`
+	Active    uint32 `json:"active"`
+}
+
+// NodeRejectionHistory keeps the thread pool samples of a node
+type NodeRejectionHistory struct {
+	HostName string                           `json:"hostName"`
+	Roles    []string                         `json:"roles"`
+	Pools    map[string]*Ring[RejectionPoint] `json:"pools"` // key: thread pool name; slot 0 is the latest sample
+}
+
+// ClusterThreadPoolRejections holds the thread pool history of all nodes of a cluster
+type ClusterThreadPoolRejections struct {
+	SnapShotTime int64                            `json:"snapShotTime"` // epoch milliseconds (UTC) of the latest collection
+	HistorySize  int                              `json:"historySize"`
+	Nodes        map[string]*NodeRejectionHistory `json:"nodes"` // key: hostName
+}
+
+// NodeSegmentPoint is one segments, merges and refresh sample of a node. The time and total
+// counters are cumulative since the node started.
+type NodeSegmentPoint struct {
+	TimeStamp              int64  `json:"timeStamp"` // epoch milliseconds (UTC)
+	Segments               uint64 `json:"segments"`
+	MergesCurrent          uint64 `json:"mergesCurrent"`
+	MergesCurrentDocs      uint64 `json:"mergesCurrentDocs"`
+	MergesCurrentSizeBytes uint64 `json:"mergesCurrentSizeBytes"`
+	MergeTimeMs            uint64 `json:"mergeTimeMs"`
+	MergeThrottledTimeMs   uint64 `json:"mergeThrottledTimeMs"`
+	Refreshes              uint64 `json:"refreshes"`
+	RefreshTimeMs          uint64 `json:"refreshTimeMs"`
+}
+
+// NodeSegmentHistory keeps the segment samples of a node
+type NodeSegmentHistory struct {
+	HostName string                  `json:"hostName"`
+	Roles    []string                `json:"roles"`
+	Points   *Ring[NodeSegmentPoint] `json:"points"` // slot 0 is the latest sample
+}
+
+// ClusterSegmentStats holds the segment history of all nodes of a cluster
+type ClusterSegmentStats struct {
+	SnapShotTime int64                          `json:"snapShotTime"` // epoch milliseconds (UTC) of the latest collection
+	HistorySize  int                            `json:"historySize"`
+	Nodes        map[string]*NodeSegmentHistory `json:"nodes"` // key: hostName
+}
+
+// ShardRecovery is an active shard recovery or relocation, as tracked across _cat/recovery polls
+type ShardRecovery struct {
+	Index            string  `json:"index"`
+	Shard            int     `json:"shard"`
+	Type             string  `json:"type"`  // e.g. peer, snapshot, existing_store
+	Stage            string  `json:"stage"` // init, index, verify_index, translog, finalize
+	SourceNode       string  `json:"sourceNode,omitempty"`
+	TargetNode       string  `json:"targetNode"`
+	BytesTotal       uint64  `json:"bytesTotal"`
+	BytesRecovered   uint64  `json:"bytesRecovered"`
+	ElapsedMs        int64   `json:"elapsedMs"`        // recovery time reported by Elasticsearch
+	FirstSeen        int64   `json:"firstSeen"`        // epoch milliseconds (UTC)
+	LastSeen         int64   `json:"lastSeen"`         // epoch milliseconds (UTC)
+	ThroughputBps    float64 `json:"throughputBps"`    // bytes per second since the previous poll
+	AvgThroughputBps float64 `json:"avgThroughputBps"` // bytes per second over the whole recovery
+	EtaMs            int64   `json:"etaMs"`            // estimated time to completion, 0 = unknown
+	SlowSince        int64   `json:"slowSince"`        // epoch milliseconds (UTC) since the throughput is below the minimum, 0 = not slow
+}
+
+// ClusterRecoveries holds the active shard recoveries of a cluster
+type ClusterRecoveries struct {
+	SnapShotTime int64                     `json:"snapShotTime"` // epoch milliseconds (UTC)
+	Recoveries   map[string]*ShardRecovery `json:"recoveries"`   // key: index/shard/targetNode
+}
+
+// Tracked task states
+const (
+	TaskRunning   = "running"
+	TaskCompleted = "completed"
+	TaskFailed    = "failed"   // completed with an error or with failures
+	TaskGone      = "gone"     // no longer known to the cluster and no stored result, e.g. a force merge that ended
+	TaskNotFound  = "notFound" // never found on the cluster
+)
+
+// TrackedTask is a long-running task (reindex, update or delete by query, force merge) followed
+// by task ID across polls of trackTasks
+type TrackedTask struct {
+	ClusterName   string  `json:"clusterName"`
+	TaskID        string  `json:"taskId"`         // <node id>:<task number>
+	Note          string  `json:"note,omitempty"` // given when tracking started, e.g. the migration step
+	AddedAt       int64   `json:"addedAt"`        // epoch milliseconds (UTC)
+	State         string  `json:"state"`
+	Action        string  `json:"action,omitempty"`
+	Description   string  `json:"description,omitempty"`
+	StartTime     int64   `json:"startTime,omitempty"`  // epoch milliseconds (UTC), 0 until found
+	EndTime       int64   `json:"endTime,omitempty"`    // epoch milliseconds (UTC) the task was seen ended
+	LastPolled    int64   `json:"lastPolled,omitempty"` // epoch milliseconds (UTC), 0 = not polled yet
+	LastError     string  `json:"lastError,omitempty"`  // error of the latest poll, "" once a poll succeeds
+	RunningTimeMs int64   `json:"runningTimeMs"`
+	Total         int64   `json:"total"`              // documents to process, 0 = unknown (e.g. force merge)
+	Done          int64   `json:"done"`               // documents created, updated, deleted, skipped or in conflict
+	Progress      float64 `json:"progress"`           // percent, -1 = unknown
+	RatePerSec    float64 `json:"ratePerSec"`         // documents per second since the previous poll
+	AvgRatePerSec float64 `json:"avgRatePerSec"`      // documents per second since the task started
+	EtaMs         int64   `json:"etaMs"`              // estimated time to completion at the average rate, 0 = unknown
+	Failures      int     `json:"failures,omitempty"` // failures reported by a completed task
+	Error         string  `json:"error,omitempty"`    // error of a failed task
+}
+
+// Settings drift changes
+const (
+	DriftChanged = "changed"
+	DriftAdded   = "added"
+	DriftRemoved = "removed"
+)
+
+// SettingsSnapshot is a copy of the explicitly set settings of a cluster: the persistent and
+// transient cluster settings (keyed "persistent.<name>" and "transient.<name>") and the
+// selected settings of each index
+type SettingsSnapshot struct {
+	TimeStamp int64                        `json:"timeStamp"` // epoch milliseconds (UTC)
+	Cluster   map[string]string            `json:"cluster"`
+	Indices   map[string]map[string]string `json:"indices"` // index -> setting -> value
+}
+
+// SettingDrift is one setting that differs from the baseline
+type SettingDrift struct {
+	Setting  string `json:"setting"`
+	Index    string `json:"index,omitempty"` // "" for cluster settings
+	Change   string `json:"change"`          // changed, added or removed
+	Baseline string `json:"baseline,omitempty"`
+	Current  string `json:"current,omitempty"`
+}
+
+// SettingsDriftReport is the latest comparison of a cluster's settings with its baseline
+type SettingsDriftReport struct {
+	ClusterName  string            `json:"clusterName"`
+	CheckTime    int64             `json:"checkTime"`    // epoch milliseconds (UTC)
+	BaselineTime int64             `json:"baselineTime"` // epoch milliseconds (UTC) of the baseline snapshot
+	Drift        []SettingDrift    `json:"drift"`        // cluster settings first, then by setting and index
+	Current      *SettingsSnapshot `json:"-"`            // the snapshot the report was made from
+}
+
+// FieldCountPoint is one sample of the mapped field count of an index
+type FieldCountPoint struct {
+	TimeStamp int64 `json:"timeStamp"` // epoch milliseconds (UTC)
+	Fields    int   `json:"fields"`
+}
+
+// IndexFieldCount is the mapped field count of an index and its total fields limit
+type IndexFieldCount struct {
+	Index   string                 `json:"index"`
+	Fields  int                    `json:"fields"`  // fields counted against the limit in the latest sample
+	Limit   int                    `json:"limit"`   // index.mapping.total_fields.limit
+	Dynamic string                 `json:"dynamic"` // dynamic mapping setting of the root object ("true" when unset)
+	Points  *Ring[FieldCountPoint] `json:"points"`  // slot 0 is the latest sample
+}
+
+// ClusterFieldCounts holds the field count history of the indices of a cluster
+type ClusterFieldCounts struct {
+	SnapShotTime int64                       `json:"snapShotTime"` // epoch milliseconds (UTC) of the latest collection
+	HistorySize  int                         `json:"historySize"`
+	Indices      map[string]*IndexFieldCount `json:"indices"` // key: index name
+}
+
+// PipelineCounters are the cumulative ingest counters of a pipeline on one node
+type PipelineCounters struct {
+	Count   uint64 `json:"count"`   // documents processed
+	TimeMs  uint64 `json:"timeMs"`  // time spent processing documents
+	Failed  uint64 `json:"failed"`  // documents that failed
+	Current uint64 `json:"current"` // documents in flight
+}
+
+// PipelinePoint is one sample of an ingest pipeline summed over the nodes of a cluster. The
+// deltas are the growth since the previous sample, summed per node so that node restarts do
+// not count as negative growth.
+type PipelinePoint struct {
+	TimeStamp   int64  `json:"timeStamp"` // epoch milliseconds (UTC)
+	Count       uint64 `json:"count"`
+	TimeMs      uint64 `json:"timeMs"`
+	Failed      uint64 `json:"failed"`
+	Current     uint64 `json:"current"`
+	CountDelta  uint64 `json:"countDelta"`
+	TimeMsDelta uint64 `json:"timeMsDelta"`
+	FailedDelta uint64 `json:"failedDelta"`
+}
+
+// PipelineHistory is the sample history of an ingest pipeline
+type PipelineHistory struct {
+	Pipeline string               `json:"pipeline"`
+	Nodes    int                  `json:"nodes"`  // nodes that reported the pipeline in the latest sample
+	Points   *Ring[PipelinePoint] `json:"points"` // slot 0 is the latest sample
+}
+
+// ClusterPipelineStats holds the ingest pipeline history of a cluster
+type ClusterPipelineStats struct {
+	SnapShotTime int64                                  `json:"snapShotTime"` // epoch milliseconds (UTC) of the latest collection
+	HistorySize  int                                    `json:"historySize"`
+	Pipelines    map[string]*PipelineHistory            `json:"pipelines"` // key: pipeline ID
+	NodeCounters map[string]map[string]PipelineCounters `json:"-"`         // host -> pipeline -> counters of the latest sample
+}
+
+// RemoteConnectionChange is an observed change of the connection to a remote cluster
+type RemoteConnectionChange struct {
+	TimeStamp int64 `json:"timeStamp"` // epoch milliseconds (UTC) of the poll that saw the change
+	Connected bool  `json:"connected"`
+}
+
+// RemoteClusterState is a remote cluster configured for cross-cluster search or replication,
+// as tracked across _remote/info polls
+type RemoteClusterState struct {
+	Alias             string                        `json:"alias"`
+	Mode              string                        `json:"mode"`      // sniff or proxy
+	Addresses         []string                      `json:"addresses"` // seeds (sniff) or proxy address (proxy)
+	Connected         bool                          `json:"connected"`
+	NodesConnected    int                           `json:"nodesConnected"` // nodes (sniff) or sockets (proxy) connected
+	SkipUnavailable   bool                          `json:"skipUnavailable"`
+	FirstSeen         int64                         `json:"firstSeen"`         // epoch milliseconds (UTC)
+	LastConnected     int64                         `json:"lastConnected"`     // epoch milliseconds (UTC) of the last poll that saw it connected, 0 = never
+	DisconnectedSince int64                         `json:"disconnectedSince"` // epoch milliseconds (UTC), 0 = connected
+	Disconnects       int                           `json:"disconnects"`       // connected -> disconnected changes observed
+	Changes           *Ring[RemoteConnectionChange] `json:"changes"`           // slot 0 is the latest change
+}
+
+// ClusterRemotes holds the remote clusters configured on a cluster
+type ClusterRemotes struct {
+	SnapShotTime int64                          `json:"snapShotTime"` // epoch milliseconds (UTC)
+	Remotes      map[string]*RemoteClusterState `json:"remotes"`      // key: remote alias
+}
+
+// MasterTransition is an observed change of the elected master of a cluster
+type MasterTransition struct {
+	TimeStamp int64  `json:"timeStamp"` // epoch milliseconds (UTC) of the lookup that saw the change
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+// MasterHistory is the elected master of a cluster as tracked across master lookups
+type MasterHistory struct {
+	Master      string                  `json:"master"`      // host name of the elected master
+	Since       int64                   `json:"since"`       // epoch milliseconds (UTC) the master was first seen elected
+	LastChecked int64                   `json:"lastChecked"` // epoch milliseconds (UTC) of the latest lookup
+	Changes     int                     `json:"changes"`     // transitions observed since the monitor started
+	Transitions *Ring[MasterTransition] `json:"transitions"` // slot 0 is the latest transition
+}
+
+// TierUsage is the storage and write load of the data nodes of one data tier
+type TierUsage struct {
+	Tier               string   `json:"tier"`  // hot, warm, cold, frozen, or untiered
+	Nodes              []string `json:"nodes"` // host names, sorted
+	Shards             uint64   `json:"shards"`
+	Docs               uint64   `json:"docs"`
+	StoreBytes         uint64   `json:"storeBytes"`
+	DiskTotalBytes     uint64   `json:"diskTotalBytes"`
+	DiskAvailableBytes uint64   `json:"diskAvailableBytes"`
+	IndexingRate       float64  `json:"indexingRate"` // indexed docs/s since the previous collection, -1 = unknown
+}
+
+// ClusterTiers is the breakdown of a cluster's storage and write load by data tier
+type ClusterTiers struct {
+	SnapShotTime int64                 `json:"snapShotTime"` // epoch milliseconds (UTC)
+	Tiers        map[string]*TierUsage `json:"tiers"`        // key: tier
+	IndexTotals  map[string]uint64     `json:"indexTotals"`  // cumulative indexed docs per host, for the next rates
+}
+
+// TPWPoint is one thread pool write queue data point
+type TPWPoint struct {
+	TimeStamp int64  `json:"timeStamp"`
+	Queue     uint32 `json:"queue"`
+	Exists    bool   `json:"exists"` // false when the monitoring cluster had no data for the interval
+}
+
+// SampleTime returns the time stamp of the data point
+func (p TPWPoint) SampleTime() int64 { return p.TimeStamp }
+
+// HasData reports whether the monitoring cluster had data for the interval
+func (p TPWPoint) HasData() bool { return p.Exists }
+
+// TPWQueue stores thread pool write queue metrics for a host: the raw data points and their
+// downsampled rollups, finest first. The collected data points are kept by bucket (see Add):
+// slot i holds the bucket starting IntervalMs*i before NewestStart.
 type TPWQueue struct {
-	NumberOfDataPoints    int      `json:"numberOfDataPoints"`
-	TimeStamps            []int64  `json:"timeStamps"`
-	ThreadPoolWriteQueues []uint32 `json:"threadPoolWriteQueues"`
-	DataExists            []bool   `json:"dataExists"`
+	NumberOfDataPoints int               `json:"numberOfDataPoints"`
+	IntervalMs         int64             `json:"intervalMs,omitempty"`  // bucket of a slot, 0 = slots in arrival order
+	NewestStart        int64             `json:"newestStart,omitempty"` // start of the bucket in slot 0, 0 = empty
+	Points             *Series[TPWPoint] `json:"points"`                // slot 0 is the latest data point
+	Rollups            []*TPWRollup      `json:"rollups,omitempty"`
+	RolledUpTo         int64             `json:"rolledUpTo"` // time stamp of the latest point added to the rollups
+}
+
+// NewTPWQueue creates an empty TPWQueue with numberOfDataPoints slots filled in arrival order
+// (Push)
+func NewTPWQueue(numberOfDataPoints int) *TPWQueue {
+	return &TPWQueue{
+		NumberOfDataPoints: numberOfDataPoints,
+		Points:             NewSeries[TPWPoint](numberOfDataPoints),
+	}
+}
+
+// NewBucketedTPWQueue creates an empty TPWQueue with numberOfDataPoints slots of buckets of
+// intervalMs, filled with Add
+func NewBucketedTPWQueue(numberOfDataPoints int, intervalMs int64) *TPWQueue {
+	q := NewTPWQueue(numberOfDataPoints)
+	q.IntervalMs = intervalMs
+	return q
 }
 
 // ClustersTPWQueue holds thread pool write queue data for all hosts in a cluster
 type ClustersTPWQueue struct {
 	HostnameList []string             `json:"hostnameList"`
 	HostTPWQueue map[string]*TPWQueue `json:"hostTPWQueue"` // map[hostName]*TPWQueue
-}
-
-// WritePressureEvent represents a write pressure event for a host
-type WritePressureEvent struct {
-	EventStartTime int64  `json:"eventStartTime"` // epoch seconds when the event started
-	HostName       string `json:"hostName"`
-	ClusterName    string `json:"clusterName"`
+	// Start (epoch ms) of the collection run that last set the hosts; the data of older runs
+	// finishing later is merged without changing them
+	Generation int64  `json:"generation,omitempty"`
+	RunID      string `json:"runId,omitempty"` // job run of Generation
 }
 
 // AggShardTaskDataWriteBulk_s aggregates bulk write task data for a shard
@@ -150,7 +671,8 @@ type NodeDataWriteBulk_sTasks struct {
 
 // ClusterDataWriteBulk_sTasks stores cluster-wide bulk write task data
 type ClusterDataWriteBulk_sTasks struct {
-	SnapShotTime                int64                                   `json:"snapShotTime"`              // epoch seconds
+	SnapShotTime                int64                                   `json:"snapShotTime"`              // epoch milliseconds (UTC)
+	RunID                       string                                  `json:"runId,omitempty"`           // job run that took the snapshot
 	DataWriteBulk_sTasksByNode  map[string]*NodeDataWriteBulk_sTasks    `json:"dataWriteBulkSTasksByNode"` // key: hostName
 	SortedHostsOnTasks          []string                                `json:"sortedHostsOnTasks"`
 	SortedHostsOnTimetaken      []string                                `json:"sortedHostsOnTimetaken"`
@@ -159,27 +681,86 @@ type ClusterDataWriteBulk_sTasks struct {
 	IndicesSortedonTasks        []string                                `json:"indicesSortedonTasks"`
 	IndicesSortedOnRequests     []string                                `json:"indicesSortedOnRequests"`
 	IndicesSortedOnTimetaken    []string                                `json:"indicesSortedOnTimetaken"`
+
+	// Delta holds the tasks first seen in this snapshot, in delta mode and when the previous
+	// snapshot is known; the totals above count running tasks, so long-running ones again
+	// in every snapshot
+	Delta *BulkTasksDelta `json:"delta,omitempty"`
+	// TaskIDs are the IDs (<node id>:<task number>) of the counted tasks, kept in delta mode
+	// on the newest snapshots only, to correlate the next one
+	TaskIDs map[string]struct{} `json:"-"`
+}
+
+// BulkTaskCounts counts the tasks first seen in a snapshot and their requests
+type BulkTaskCounts struct {
+	NewTasks    uint `json:"newTasks"`
+	NewRequests uint `json:"newRequests"`
 }
 
+// BulkTasksDelta is what changed since the previous snapshot of the same task action filter
+type BulkTasksDelta struct {
+	IntervalMs int64 `json:"intervalMs"` // time since the previous snapshot
+	BulkTaskCounts
+	ByNode  map[string]*BulkTaskCounts `json:"byNode"`  // key: hostName
+	ByIndex map[string]*BulkTaskCounts `json:"byIndex"` // key: index name
+}
+
+// BulkTaskAction names the task action filter of the bulk write shard tasks, whose histories
+// are AllClusterDataWriteBulk_sTasksHistory. Other filters (e.g. delete-by-query) keep their
+// histories in AllTaskActionHistory.
+const BulkTaskAction = "bulk"
+
 // ClusterDataWriteBulk_sTasksHistory maintains history of bulk write tasks for a cluster
 type ClusterDataWriteBulk_sTasksHistory struct {
-	LatestSnapShotTime             int64                          `json:"latestSnapShotTime"` // epoch seconds
-	HistorySize                    uint                           `json:"historySize"`
-	ClusterName                    string                         `json:"clusterName"`
-	PtrClusterDataWriteBulk_sTasks []*ClusterDataWriteBulk_sTasks `json:"ptrClusterDataWriteBulkSTasks"`
+	LatestSnapShotTime             int64                               `json:"latestSnapShotTime"` // epoch milliseconds (UTC)
+	HistorySize                    uint                                `json:"historySize"`
+	ClusterName                    string                              `json:"clusterName"`
+	Action                         string                              `json:"action"`                        // name of the task action filter, BulkTaskAction for bulk writes
+	PtrClusterDataWriteBulk_sTasks *Ring[*ClusterDataWriteBulk_sTasks] `json:"ptrClusterDataWriteBulkSTasks"` // slot 0 is the latest snapshot
+}
+
+// AddSnapshot adds a snapshot to the history like IndicesHistory.AddSnapshot (callers hold
+// ClusterDataWriteBulkTasksHistoryMu)
+func (h *ClusterDataWriteBulk_sTasksHistory) AddSnapshot(snapshot *ClusterDataWriteBulk_sTasks) SnapshotInsert {
+	return insertSnapshot(h.PtrClusterDataWriteBulk_sTasks, snapshot)
+}
+
+// CollectionStatus records how the collections of one job for one cluster went
+type CollectionStatus struct {
+	JobName             string `json:"jobName"`
+	ClusterName         string `json:"clusterName"`
+	LastAttempt         int64  `json:"lastAttempt"`         // epoch milliseconds (UTC)
+	LastSuccess         int64  `json:"lastSuccess"`         // epoch milliseconds (UTC), 0 = never succeeded
+	LastErrorTime       int64  `json:"lastErrorTime"`       // epoch milliseconds (UTC), 0 = never failed
+	LastError           string `json:"lastError,omitempty"` // error of the latest failure, kept after recovery
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
 }
 
 // Global data structures
 var (
-	AllClusters                           map[string]*ClusterData                        // map[clusterName]*ClusterData
-	AllClustersList                       []string                                       // list of all cluster names
-	AllHistory                            map[string]*IndicesHistory                     // map[clusterName]*IndicesHistory
-	AllIndexingRate                       map[string]*ClusterIndexingRate                // map[clusterName]*ClusterIndexingRate
-	AllStatsByDay                         map[string]*IndicesStatsByDay                  // map[clusterName]*IndicesStatsByDay
-	AllThreadPoolWriteQueues              map[string]*ClustersTPWQueue                   // map[clusterName]*ClustersTPWQueue
-	WritePressureMap                      map[string]*WritePressureEvent                 // map[key]*WritePressureEvent, key="hostname_epochseconds"
-	AllCurrentMasterEndPoints             map[string]string                              // map[clusterName]masterEndpoint
-	AllClusterDataWriteBulk_sTasksHistory map[string]*ClusterDataWriteBulk_sTasksHistory // map[clusterName]*ClusterDataWriteBulk_sTasksHistory
+	AllClusters                           map[string]*ClusterData                                   // map[clusterName]*ClusterData
+	AllHistory                            map[string]*IndicesHistory                                // map[clusterName]*IndicesHistory
+	AllIndexingRate                       map[string]*ClusterIndexingRate                           // map[clusterName]*ClusterIndexingRate
+	AllIndexingRateHistory                map[string]*IndexingRateHistory                           // map[clusterName]*IndexingRateHistory
+	AllStatsByDay                         map[string]*IndicesStatsByDay                             // map[clusterName]*IndicesStatsByDay
+	AllThreadPoolWriteQueues              map[string]*ClustersTPWQueue                              // map[clusterName]*ClustersTPWQueue
+	AllCurrentMasterEndPoints             map[string]string                                         // map[clusterName]masterEndpoint
+	AllClusterDataWriteBulk_sTasksHistory map[string]*ClusterDataWriteBulk_sTasksHistory            // map[clusterName]*ClusterDataWriteBulk_sTasksHistory
+	AllTaskActionHistory                  map[string]map[string]*ClusterDataWriteBulk_sTasksHistory // map[clusterName]map[action]*ClusterDataWriteBulk_sTasksHistory, actions other than BulkTaskAction
+	AllCollectionStatus                   map[string]map[string]*CollectionStatus                   // map[jobName]map[clusterName]*CollectionStatus
+	AllRetentionReports                   map[string]*RetentionReport                               // map[clusterName]*RetentionReport
+	AllDiskUsage                          map[string]*ClusterDiskUsage                              // map[clusterName]*ClusterDiskUsage
+	AllJVMStats                           map[string]*ClusterJVMStats                               // map[clusterName]*ClusterJVMStats
+	AllThreadPoolRejections               map[string]*ClusterThreadPoolRejections                   // map[clusterName]*ClusterThreadPoolRejections
+	AllSegmentStats                       map[string]*ClusterSegmentStats                           // map[clusterName]*ClusterSegmentStats
+	AllRecoveries                         map[string]*ClusterRecoveries                             // map[clusterName]*ClusterRecoveries
+	AllTrackedTasks                       map[string]map[string]*TrackedTask                        // map[clusterName]map[taskID]*TrackedTask
+	AllSettingsDrift                      map[string]*SettingsDriftReport                           // map[clusterName]*SettingsDriftReport
+	AllFieldCounts                        map[string]*ClusterFieldCounts                            // map[clusterName]*ClusterFieldCounts
+	AllPipelineStats                      map[string]*ClusterPipelineStats                          // map[clusterName]*ClusterPipelineStats
+	AllRemotes                            map[string]*ClusterRemotes                                // map[clusterName]*ClusterRemotes
+	AllMasterHistory                      map[string]*MasterHistory                                 // map[clusterName]*MasterHistory
+	AllTiers                              map[string]*ClusterTiers                                  // map[clusterName]*ClusterTiers
 
 	// Mutexes for thread-safe access
 	ClustersMu                         sync.RWMutex
@@ -187,41 +768,64 @@ var (
 	IndexingRateMu                     sync.RWMutex
 	StatsByDayMu                       sync.RWMutex
 	TPWQueueMu                         sync.RWMutex
-	WritePressureMu                    sync.RWMutex
 	CurrentMasterEndPtsMu              sync.RWMutex
 	ClusterDataWriteBulkTasksHistoryMu sync.RWMutex
+	CollectionStatusMu                 sync.RWMutex
+	RetentionMu                        sync.RWMutex
+	DiskUsageMu                        sync.RWMutex
+	JVMStatsMu                         sync.RWMutex
+	RejectionsMu                       sync.RWMutex
+	SegmentStatsMu                     sync.RWMutex
+	RecoveriesMu                       sync.RWMutex
+	TrackedTasksMu                     sync.RWMutex
+	SettingsDriftMu                    sync.RWMutex
+	FieldCountsMu                      sync.RWMutex
+	PipelineStatsMu                    sync.RWMutex
+	RemotesMu                          sync.RWMutex
+	MasterHistoryMu                    sync.RWMutex
+	TiersMu                            sync.RWMutex
 )
 
 func init() {
 	AllClusters = make(map[string]*ClusterData)
-	AllClustersList = make([]string, 0)
 	AllHistory = make(map[string]*IndicesHistory)
 	AllIndexingRate = make(map[string]*ClusterIndexingRate)
+	AllIndexingRateHistory = make(map[string]*IndexingRateHistory)
 	AllStatsByDay = make(map[string]*IndicesStatsByDay)
 	AllThreadPoolWriteQueues = make(map[string]*ClustersTPWQueue)
-	WritePressureMap = make(map[string]*WritePressureEvent)
 	AllCurrentMasterEndPoints = make(map[string]string)
 	AllClusterDataWriteBulk_sTasksHistory = make(map[string]*ClusterDataWriteBulk_sTasksHistory)
+	AllTaskActionHistory = make(map[string]map[string]*ClusterDataWriteBulk_sTasksHistory)
+	AllCollectionStatus = make(map[string]map[string]*CollectionStatus)
+	AllRetentionReports = make(map[string]*RetentionReport)
+	AllDiskUsage = make(map[string]*ClusterDiskUsage)
+	AllJVMStats = make(map[string]*ClusterJVMStats)
+	AllThreadPoolRejections = make(map[string]*ClusterThreadPoolRejections)
+	AllSegmentStats = make(map[string]*ClusterSegmentStats)
+	AllRecoveries = make(map[string]*ClusterRecoveries)
+	AllTrackedTasks = make(map[string]map[string]*TrackedTask)
+	AllSettingsDrift = make(map[string]*SettingsDriftReport)
+	AllFieldCounts = make(map[string]*ClusterFieldCounts)
+	AllPipelineStats = make(map[string]*ClusterPipelineStats)
+	AllRemotes = make(map[string]*ClusterRemotes)
+	AllMasterHistory = make(map[string]*MasterHistory)
+	AllTiers = make(map[string]*ClusterTiers)
 }
 
 // NewIndicesHistory creates a new IndicesHistory with specified size
 func NewIndicesHistory(size uint8) *IndicesHistory {
 	return &IndicesHistory{
 		SizeOfPtr: size,
-		Ptr:       make([]*IndicesSnapShot, size+1),
+		Snapshots: NewSeries[*IndicesSnapShot](int(size) + 1),
 	}
 }
 
-// AddSnapshot adds a new snapshot to history (thread-safe)
-func (ih *IndicesHistory) AddSnapshot(snapshot *IndicesSnapShot) {
+// AddSnapshot adds a new snapshot to history, dropping the oldest one, unless it is a
+// duplicate or late; a snapshot of the job run of the latest one replaces it (thread-safe)
+func (ih *IndicesHistory) AddSnapshot(snapshot *IndicesSnapShot) SnapshotInsert {
 	ih.mu.Lock()
 	defer ih.mu.Unlock()
-
-	// Roll over old snapshots
-	for i := 0; i < int(ih.SizeOfPtr); i++ {
-		ih.Ptr[i] = ih.Ptr[i+1]
-	}
-	ih.Ptr[ih.SizeOfPtr] = snapshot
+	return insertSnapshot(&ih.Snapshots.Ring, snapshot)
 }
 
 // GetCopy returns a copy of the history (thread-safe, shallow copy of pointers)
@@ -229,25 +833,46 @@ func (ih *IndicesHistory) GetCopy() *IndicesHistory {
 	ih.mu.RLock()
 	defer ih.mu.RUnlock()
 
-	copy := &IndicesHistory{
+	return &IndicesHistory{
 		SizeOfPtr: ih.SizeOfPtr,
-		Ptr:       make([]*IndicesSnapShot, len(ih.Ptr)),
-	}
-	for i := range ih.Ptr {
-		copy.Ptr[i] = ih.Ptr[i]
+		Snapshots: ih.Snapshots.Clone(),
 	}
-	return copy
 }
 
-// GetLatestIndex returns the index of the latest non-nil snapshot
-func (ih *IndicesHistory) GetLatestIndex() int {
+// Latest returns the snapshot taken back snapshots before the latest one (0 = latest),
+// or nil if there is none
+func (ih *IndicesHistory) Latest(back int) *IndicesSnapShot {
 	ih.mu.RLock()
 	defer ih.mu.RUnlock()
+	return ih.Snapshots.At(back)
+}
+
+// LatestAndClosest returns the latest snapshot (nil if there is none) and, for each lookback
+// (milliseconds), the earlier snapshot whose age relative to it is closest to the lookback,
+// within tolerance (a fraction of the lookback), else nil. All are read under one lock, so
+// the earlier snapshots are relative to the latest one returned.
+func (ih *IndicesHistory) LatestAndClosest(lookbacks []int64, tolerance float64) (*IndicesSnapShot, []*IndicesSnapShot) {
+	ih.mu.RLock()
+	defer ih.mu.RUnlock()
+
+	closest := make([]*IndicesSnapShot, len(lookbacks))
+	for i, lookback := range lookbacks {
+		closest[i], _ = ih.Snapshots.Closest(lookback, int64(float64(lookback)*tolerance))
+	}
+	return ih.Snapshots.At(0), closest
+}
+
+// EvictSnapshot drops the snapshot taken at snapShotTime (used to enforce memory budgets).
+// The latest snapshot is never evicted.
+func (ih *IndicesHistory) EvictSnapshot(snapShotTime int64) bool {
+	ih.mu.Lock()
+	defer ih.mu.Unlock()
 
-	for i := int(ih.SizeOfPtr); i >= 0; i-- {
-		if ih.Ptr[i] != nil {
-			return i
+	for i := 1; i < ih.Snapshots.Cap(); i++ {
+		if snapshot := ih.Snapshots.At(i); snapshot != nil && snapshot.SnapShotTime == snapShotTime {
+			ih.Snapshots.Set(i, nil)
+			return true
 		}
 	}
-	return -1
+	return false
 }
@@ -0,0 +1,268 @@
+package types
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// View is a read-only map of per-cluster values that is swapped atomically on every change.
+// Writers publish values they will never modify again (usually copies made under the owning
+// mutex); readers load the current map without any lock and never observe a partial update.
+type View[T any] struct {
+	mu  sync.Mutex // serialises publishers
+	ptr atomic.Pointer[map[string]T]
+}
+
+// Load returns the current map. It is shared between readers and must not be modified.
+func (v *View[T]) Load() map[string]T {
+	if m := v.ptr.Load(); m != nil {
+		return *m
+	}
+	return map[string]T{}
+}
+
+// Get returns the current value for a key
+func (v *View[T]) Get(key string) (T, bool) {
+	val, ok := v.Load()[key]
+	return val, ok
+}
+
+// Publish makes val visible to readers under key
+func (v *View[T]) Publish(key string, val T) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	current := v.Load()
+	next := make(map[string]T, len(current)+1)
+	for k, existing := range current {
+		next[k] = existing
+	}
+	next[key] = val
+	v.ptr.Store(&next)
+}
+
+// Remove drops key from the view
+func (v *View[T]) Remove(key string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	current := v.Load()
+	if _, ok := current[key]; !ok {
+		return
+	}
+	next := make(map[string]T, len(current))
+	for k, existing := range current {
+		if k != key {
+			next[k] = existing
+		}
+	}
+	v.ptr.Store(&next)
+}
+
+// Replace swaps in a whole new map; the caller hands over ownership of m
+func (v *View[T]) Replace(m map[string]T) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.ptr.Store(&m)
+}
+
+// Read-only views over the global data structures, used by the API and other readers
+var (
+	clustersView         View[*ClusterData]
+	indexingRateView     View[*ClusterIndexingRate]
+	statsByDayView       View[*IndicesStatsByDay]
+	tpwQueueView         View[*ClustersTPWQueue]
+	bulkTasksHistoryView View[*ClusterDataWriteBulk_sTasksHistory]
+	taskActionView       View[map[string]*ClusterDataWriteBulk_sTasksHistory] // key: clusterName, then action
+)
+
+// sortedKeys returns the keys of a view map in sorted order
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Copy returns a deep copy of the daily statistics
+func (s *IndicesStatsByDay) Copy() *IndicesStatsByDay {
+	if s == nil {
+		return nil
+	}
+
+	c := &IndicesStatsByDay{
+		LastUpdateTime: s.LastUpdateTime,
+		StatHistory:    make(map[string]*IndexStatHistory, len(s.StatHistory)),
+	}
+	for indexName, statHistory := range s.StatHistory {
+		if statHistory == nil {
+			continue
+		}
+		h := &IndexStatHistory{
+			IndexName: statHistory.IndexName,
+			SizeOfPtr: statHistory.SizeOfPtr,
+			Stats:     statHistory.Stats.Clone(),
+		}
+		for i := 0; i < h.Stats.Cap(); i++ {
+			if stat := h.Stats.At(i); stat != nil {
+				statCopy := *stat
+				h.Stats.Set(i, &statCopy)
+			}
+		}
+		c.StatHistory[indexName] = h
+	}
+	return c
+}
+
+// Copy returns a deep copy of the thread pool write queue data of a cluster
+func (q *ClustersTPWQueue) Copy() *ClustersTPWQueue {
+	if q == nil {
+		return nil
+	}
+
+	c := &ClustersTPWQueue{
+		HostnameList: append([]string(nil), q.HostnameList...),
+		HostTPWQueue: make(map[string]*TPWQueue, len(q.HostTPWQueue)),
+		Generation:   q.Generation,
+		RunID:        q.RunID,
+	}
+	for hostName, tpwq := range q.HostTPWQueue {
+		if tpwq == nil {
+			continue
+		}
+		c.HostTPWQueue[hostName] = &TPWQueue{
+			NumberOfDataPoints: tpwq.NumberOfDataPoints,
+			IntervalMs:         tpwq.IntervalMs,
+			NewestStart:        tpwq.NewestStart,
+			Points:             tpwq.Points.Clone(),
+			RolledUpTo:         tpwq.RolledUpTo,
+		}
+		for _, rollup := range tpwq.Rollups {
+			c.HostTPWQueue[hostName].Rollups = append(c.HostTPWQueue[hostName].Rollups, rollup.Clone())
+		}
+	}
+	return c
+}
+
+// Copy returns a copy of the bulk task history. Snapshots are never modified once stored,
+// so only the ring of pointers is copied.
+func (h *ClusterDataWriteBulk_sTasksHistory) Copy() *ClusterDataWriteBulk_sTasksHistory {
+	if h == nil {
+		return nil
+	}
+
+	c := *h
+	c.PtrClusterDataWriteBulk_sTasks = h.PtrClusterDataWriteBulk_sTasks.Clone()
+	return &c
+}
+
+// GetStatsByDay returns the published daily statistics of a cluster (read-only)
+func GetStatsByDay(clusterName string) (*IndicesStatsByDay, bool) {
+	stats, exists := statsByDayView.Get(clusterName)
+	return stats, exists && stats != nil
+}
+
+// PublishStatsByDay publishes a copy of all daily statistics to readers
+func PublishStatsByDay() {
+	StatsByDayMu.RLock()
+	published := make(map[string]*IndicesStatsByDay, len(AllStatsByDay))
+	for clusterName, stats := range AllStatsByDay {
+		if stats != nil {
+			published[clusterName] = stats.Copy()
+		}
+	}
+	StatsByDayMu.RUnlock()
+
+	statsByDayView.Replace(published)
+}
+
+// GetTPWQueue returns the published thread pool write queue data of a cluster (read-only)
+func GetTPWQueue(clusterName string) (*ClustersTPWQueue, bool) {
+	queue, exists := tpwQueueView.Get(clusterName)
+	return queue, exists && queue != nil
+}
+
+// PublishTPWQueue publishes the thread pool write queue data of a cluster. The caller holds
+// TPWQueueMu, so the published copy is consistent.
+func PublishTPWQueue(clusterName string, queue *ClustersTPWQueue) {
+	tpwQueueView.Publish(clusterName, queue.Copy())
+}
+
+// GetBulkTasksHistory returns the published bulk task history of a cluster (read-only)
+func GetBulkTasksHistory(clusterName string) (*ClusterDataWriteBulk_sTasksHistory, bool) {
+	history, exists := bulkTasksHistoryView.Get(clusterName)
+	return history, exists && history != nil
+}
+
+// BulkTasksHistories returns the published bulk task histories of all clusters (read-only)
+func BulkTasksHistories() map[string]*ClusterDataWriteBulk_sTasksHistory {
+	return bulkTasksHistoryView.Load()
+}
+
+// PublishBulkTasksHistory publishes the bulk task history of a cluster. The caller holds
+// ClusterDataWriteBulkTasksHistoryMu, so the published copy is consistent.
+func PublishBulkTasksHistory(clusterName string, history *ClusterDataWriteBulk_sTasksHistory) {
+	bulkTasksHistoryView.Publish(clusterName, history.Copy())
+}
+
+// GetTaskActionHistory returns the published task history of a cluster for a task action
+// filter (read-only); BulkTaskAction is the bulk task history
+func GetTaskActionHistory(clusterName, action string) (*ClusterDataWriteBulk_sTasksHistory, bool) {
+	if action == BulkTaskAction {
+		return GetBulkTasksHistory(clusterName)
+	}
+	histories, _ := taskActionView.Get(clusterName)
+	history, exists := histories[action]
+	return history, exists && history != nil
+}
+
+// TaskActions returns the task action filters with a published history for a cluster, sorted
+func TaskActions(clusterName string) []string {
+	histories, _ := taskActionView.Get(clusterName)
+	actions := sortedKeys(histories)
+	if _, ok := GetBulkTasksHistory(clusterName); ok {
+		actions = append([]string{BulkTaskAction}, actions...)
+	}
+	return actions
+}
+
+// PublishTaskActionHistory publishes the task history of a cluster for a task action filter
+// other than BulkTaskAction. The caller holds ClusterDataWriteBulkTasksHistoryMu, so the
+// published copy is consistent.
+func PublishTaskActionHistory(clusterName, action string, history *ClusterDataWriteBulk_sTasksHistory) {
+	current, _ := taskActionView.Get(clusterName)
+	next := make(map[string]*ClusterDataWriteBulk_sTasksHistory, len(current)+1)
+	for name, published := range current {
+		next[name] = published
+	}
+	next[action] = history.Copy()
+	taskActionView.Publish(clusterName, next)
+}
+
+// HeldTPWQueue returns a copy of the thread pool write queue data of a cluster as the
+// collection holds it, which the published data should equal, for debugging
+func HeldTPWQueue(clusterName string) (*ClustersTPWQueue, bool) {
+	TPWQueueMu.RLock()
+	defer TPWQueueMu.RUnlock()
+
+	queue := AllThreadPoolWriteQueues[clusterName]
+	return queue.Copy(), queue != nil
+}
+
+// HeldTaskActionHistory returns a copy of the task history of a cluster for a task action
+// filter as the collection holds it, which the published history should equal, for debugging
+func HeldTaskActionHistory(clusterName, action string) (*ClusterDataWriteBulk_sTasksHistory, bool) {
+	ClusterDataWriteBulkTasksHistoryMu.RLock()
+	defer ClusterDataWriteBulkTasksHistoryMu.RUnlock()
+
+	history := AllClusterDataWriteBulk_sTasksHistory[clusterName]
+	if action != BulkTaskAction {
+		history = AllTaskActionHistory[clusterName][action]
+	}
+	return history.Copy(), history != nil
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/csv"
 	"fmt"
 	"os"
-	"strconv"
 )
 
 // CSVParser handles CSV file parsing
@@ -76,95 +75,3 @@ func GetValue(row map[string]string, columnName string) string {
 	}
 	return ""
 }
-
-// ApplyTransformation applies a transformation function to a field value
-func ApplyTransformation(value string, funcName string, args interface{}) (interface{}, error) {
-	switch funcName {
-	case "toLower":
-		return ToLower(value), nil
-
-	case "toUpper":
-		return ToUpper(value), nil
-
-	case "removeNonAlphaNumeric":
-		return RemoveNonAlphaNumeric(value), nil
-
-	case "boolStringCompare":
-		compareList, ok := args.([]interface{})
-		if !ok {
-			return false, fmt.Errorf("invalid args for boolStringCompare")
-		}
-		strList := make([]string, 0, len(compareList))
-		for _, item := range compareList {
-			if str, ok := item.(string); ok {
-				strList = append(strList, str)
-			}
-		}
-		return BoolStringCompare(value, strList), nil
-
-	case "strStringCompare":
-		// args should be a map with "arg" and "retVal" keys
-		argsMap, ok := args.(map[string]interface{})
-		if !ok {
-			return value, fmt.Errorf("invalid args for strStringCompare")
-		}
-
-		argList, ok := argsMap["arg"].([]interface{})
-		if !ok {
-			return value, fmt.Errorf("invalid arg list for strStringCompare")
-		}
-
-		retValList, ok := argsMap["retVal"].([]interface{})
-		if !ok {
-			return value, fmt.Errorf("invalid retVal list for strStringCompare")
-		}
-
-		// Convert []interface{} to [][]string
-		compareList := make([][]string, 0, len(argList))
-		for _, item := range argList {
-			subList, ok := item.([]interface{})
-			if !ok {
-				continue
-			}
-			strSubList := make([]string, 0, len(subList))
-			for _, subItem := range subList {
-				if str, ok := subItem.(string); ok {
-					strSubList = append(strSubList, str)
-				}
-			}
-			compareList = append(compareList, strSubList)
-		}
-
-		// Convert []interface{} to []string
-		retVals := make([]string, 0, len(retValList))
-		for _, item := range retValList {
-			if str, ok := item.(string); ok {
-				retVals = append(retVals, str)
-			}
-		}
-
-		return StrStringCompare(value, compareList, retVals), nil
-
-	case "splitString":
-		delimiter := ","
-		if args != nil {
-			if delim, ok := args.(string); ok {
-				delimiter = delim
-			}
-		}
-		return SplitString(value, delimiter), nil
-
-	case "parseInt":
-		val, err := strconv.Atoi(value)
-		if err != nil {
-			return 0, err
-		}
-		return val, nil
-
-	case "parseBool":
-		return BoolStringCompare(value, []string{"true", "yes", "1", "on"}), nil
-
-	default:
-		return value, fmt.Errorf("unknown transformation function: %s", funcName)
-	}
-}
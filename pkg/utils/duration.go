@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationUnits maps the accepted unit spellings to their length. On top of the units of
+// time.ParseDuration it accepts days and weeks and the long names used in older configs.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond, "µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// ParseDuration parses durations such as "30s", "90s", "1h30m", "2d" or "1w3d12h".
+// It accepts everything time.ParseDuration does plus d (24h) and w (7d) units, long unit
+// names ("10 minutes") and, for compatibility, a bare number meaning seconds. Negative
+// durations are rejected.
+func ParseDuration(s string) (time.Duration, error) {
+	orig := s
+	s = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+	if s == "0" {
+		return 0, nil
+	}
+
+	// Bare number: seconds
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		if n < 0 {
+			return 0, fmt.Errorf("negative duration %q", orig)
+		}
+		return time.Duration(n * float64(time.Second)), nil
+	}
+
+	var total time.Duration
+	for s != "" {
+		// Number (integer or decimal)
+		i := 0
+		for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		value, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		s = s[i:]
+
+		// Unit
+		j := 0
+		for j < len(s) && !(s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+			j++
+		}
+		unit, ok := durationUnits[s[:j]]
+		if !ok {
+			if j == 0 {
+				return 0, fmt.Errorf("missing unit in duration %q", orig)
+			}
+			return 0, fmt.Errorf("unknown unit %q in duration %q", s[:j], orig)
+		}
+		s = s[j:]
+
+		total += time.Duration(value * float64(unit))
+	}
+	return total, nil
+}
@@ -0,0 +1,657 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Expressions are a small language for derived CSV fields, e.g.
+//
+//	contains(lower(col("dc")), "prd") ? "prod" : "nonprod"
+//
+// Supported: string/number/bool literals, col("Column"), function calls, parentheses,
+// the ternary operator, ||, &&, !, ==, !=, <, <=, >, >=, +, -, * and /. + adds two numbers
+// and joins anything else: columns are strings, so col("a") + col("b") joins them and
+// number(col("a")) + number(col("b")) adds them.
+
+// ExprFunc is a function callable from an expression
+type ExprFunc func(args []interface{}) (interface{}, error)
+
+// exprNode is a node of a parsed expression
+type exprNode interface {
+	eval(row map[string]string) (interface{}, error)
+}
+
+var (
+	exprCache   = make(map[string]exprNode) // map[expression]parsed expression
+	exprCacheMu sync.RWMutex
+
+	exprFuncs   = make(map[string]ExprFunc)
+	exprFuncsMu sync.RWMutex
+)
+
+func init() {
+	RegisterExprFunc("lower", exprStringFunc(strings.ToLower))
+	RegisterExprFunc("upper", exprStringFunc(strings.ToUpper))
+	RegisterExprFunc("trim", exprStringFunc(strings.TrimSpace))
+	RegisterExprFunc("contains", exprStringPredicate(strings.Contains))
+	RegisterExprFunc("startsWith", exprStringPredicate(strings.HasPrefix))
+	RegisterExprFunc("endsWith", exprStringPredicate(strings.HasSuffix))
+	RegisterExprFunc("matches", exprMatches)
+	RegisterExprFunc("split", exprSplit)
+	RegisterExprFunc("replace", exprReplace)
+	RegisterExprFunc("transform", exprTransform)
+	RegisterExprFunc("number", exprNumber)
+}
+
+// RegisterExprFunc registers (or replaces) a function callable from expressions
+func RegisterExprFunc(name string, fn ExprFunc) {
+	exprFuncsMu.Lock()
+	defer exprFuncsMu.Unlock()
+	exprFuncs[name] = fn
+}
+
+// EvaluateExpression evaluates an expression against a CSV row. Parsed expressions are cached.
+func EvaluateExpression(expression string, row map[string]string) (interface{}, error) {
+	node, err := compileExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	return node.eval(row)
+}
+
+// ValidateExpression checks the syntax of an expression without evaluating it
+func ValidateExpression(expression string) error {
+	_, err := compileExpression(expression)
+	return err
+}
+
+// compileExpression parses an expression and caches the result
+func compileExpression(expression string) (exprNode, error) {
+	exprCacheMu.RLock()
+	node, exists := exprCache[expression]
+	exprCacheMu.RUnlock()
+	if exists {
+		return node, nil
+	}
+
+	tokens, err := tokenizeExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	node, err = p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+
+	exprCacheMu.Lock()
+	exprCache[expression] = node
+	exprCacheMu.Unlock()
+	return node, nil
+}
+
+// ---- tokenizer ----
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "?", ":", "(", ")", ",", "+", "-", "*", "/"}
+
+func tokenizeExpression(s string) ([]exprToken, error) {
+	tokens := make([]exprToken, 0)
+	i := 0
+	for i < len(s) {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			quote := s[i]
+			start := i
+			i++
+			var sb strings.Builder
+			for i < len(s) && s[i] != quote {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				sb.WriteByte(s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++ // closing quote
+			tokens = append(tokens, exprToken{kind: tokString, text: sb.String(), pos: start})
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(s) && (unicode.IsDigit(rune(s[i])) || s[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokNumber, text: s[start:i], pos: start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(s) && (unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i])) || s[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokIdent, text: s[start:i], pos: start})
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(s[i:], op) {
+					tokens = append(tokens, exprToken{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+		}
+	}
+	tokens = append(tokens, exprToken{kind: tokEOF, pos: len(s)})
+	return tokens, nil
+}
+
+// ---- parser ----
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) acceptOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expectOp(op string) error {
+	if _, ok := p.acceptOp(op); !ok {
+		t := p.peek()
+		return fmt.Errorf("expected %q at position %d, found %q", op, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *exprParser) parseTernary() (exprNode, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.acceptOp("?"); !ok {
+		return cond, nil
+	}
+	ifTrue, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectOp(":"); err != nil {
+		return nil, err
+	}
+	ifFalse, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	return &ternaryNode{cond: cond, ifTrue: ifTrue, ifFalse: ifFalse}, nil
+}
+
+// binary operator precedence levels, lowest first
+var exprPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+func (p *exprParser) parseBinary(level int) (exprNode, error) {
+	if level >= len(exprPrecedence) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp(exprPrecedence[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if op, ok := p.acceptOp("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return &literalNode{value: t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.text, t.pos)
+		}
+		return &literalNode{value: f}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		if err := p.expectOp("("); err != nil {
+			return nil, fmt.Errorf("unknown identifier %q at position %d", t.text, t.pos)
+		}
+		args := make([]exprNode, 0)
+		if _, ok := p.acceptOp(")"); !ok {
+			for {
+				arg, err := p.parseTernary()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				if _, ok := p.acceptOp(","); ok {
+					continue
+				}
+				if err := p.expectOp(")"); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		if t.text == "col" {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("col() takes exactly one argument")
+			}
+			return &columnNode{column: args[0]}, nil
+		}
+		return &callNode{name: t.text, args: args}, nil
+	case tokOp:
+		if t.text == "(" {
+			inner, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+// ---- evaluation ----
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(row map[string]string) (interface{}, error) {
+	return n.value, nil
+}
+
+type columnNode struct {
+	column exprNode
+}
+
+func (n *columnNode) eval(row map[string]string) (interface{}, error) {
+	name, err := n.column.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	return GetValue(row, exprToString(name)), nil
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+func (n *callNode) eval(row map[string]string) (interface{}, error) {
+	exprFuncsMu.RLock()
+	fn, exists := exprFuncs[n.name]
+	exprFuncsMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unknown function: %s", n.name)
+	}
+
+	args := make([]interface{}, 0, len(n.args))
+	for _, arg := range n.args {
+		val, err := arg.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, val)
+	}
+	return fn(args)
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n *unaryNode) eval(row map[string]string) (interface{}, error) {
+	val, err := n.operand.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		return !exprTruthy(val), nil
+	}
+	f, ok := exprToNumber(val)
+	if !ok {
+		return nil, fmt.Errorf("cannot negate non-numeric value %v", val)
+	}
+	return -f, nil
+}
+
+type ternaryNode struct {
+	cond, ifTrue, ifFalse exprNode
+}
+
+func (n *ternaryNode) eval(row map[string]string) (interface{}, error) {
+	cond, err := n.cond.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	if exprTruthy(cond) {
+		return n.ifTrue.eval(row)
+	}
+	return n.ifFalse.eval(row)
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) eval(row map[string]string) (interface{}, error) {
+	left, err := n.left.eval(row)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit logical operators
+	switch n.op {
+	case "&&":
+		if !exprTruthy(left) {
+			return false, nil
+		}
+		right, err := n.right.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		return exprTruthy(right), nil
+	case "||":
+		if exprTruthy(left) {
+			return true, nil
+		}
+		right, err := n.right.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		return exprTruthy(right), nil
+	}
+
+	right, err := n.right.eval(row)
+	if err != nil {
+		return nil, err
+	}
+
+	lNum, lIsNum := exprToNumber(left)
+	rNum, rIsNum := exprToNumber(right)
+	bothNumeric := lIsNum && rIsNum
+
+	switch n.op {
+	case "==":
+		if bothNumeric {
+			return lNum == rNum, nil
+		}
+		return exprToString(left) == exprToString(right), nil
+	case "!=":
+		if bothNumeric {
+			return lNum != rNum, nil
+		}
+		return exprToString(left) != exprToString(right), nil
+	case "<", "<=", ">", ">=":
+		if bothNumeric {
+			return compareOrdered(n.op, lNum, rNum), nil
+		}
+		return compareOrdered(n.op, exprToString(left), exprToString(right)), nil
+	case "+":
+		// Numeric strings are joined too, e.g. the parts of a zip code or two IDs
+		if l, ok := left.(float64); ok {
+			if r, ok := right.(float64); ok {
+				return l + r, nil
+			}
+		}
+		return exprToString(left) + exprToString(right), nil
+	case "-":
+		if !bothNumeric {
+			return nil, fmt.Errorf("cannot subtract non-numeric values %v and %v", left, right)
+		}
+		return lNum - rNum, nil
+	case "*":
+		if !bothNumeric {
+			return nil, fmt.Errorf("cannot multiply non-numeric values %v and %v", left, right)
+		}
+		return lNum * rNum, nil
+	case "/":
+		if !bothNumeric {
+			return nil, fmt.Errorf("cannot divide non-numeric values %v and %v", left, right)
+		}
+		if rNum == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return lNum / rNum, nil
+	}
+	return nil, fmt.Errorf("unknown operator: %s", n.op)
+}
+
+func compareOrdered[T float64 | string](op string, a, b T) bool {
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+// exprTruthy: false, nil, "", "false", "0" and 0 are false, everything else is true
+func exprTruthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case float64:
+		return val != 0
+	case string:
+		lower := strings.ToLower(strings.TrimSpace(val))
+		return lower != "" && lower != "false" && lower != "0"
+	case []string:
+		return len(val) > 0
+	}
+	return true
+}
+
+// exprToNumber converts numbers and numeric strings to float64
+func exprToNumber(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func exprToString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case []string:
+		return strings.Join(val, ",")
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// ---- built-in functions ----
+
+func exprStringFunc(fn func(string) string) ExprFunc {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		return fn(exprToString(args[0])), nil
+	}
+}
+
+func exprStringPredicate(fn func(string, string) bool) ExprFunc {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		return fn(exprToString(args[0]), exprToString(args[1])), nil
+	}
+}
+
+// exprMatches: matches(value, pattern) reports whether value matches the regular expression
+func exprMatches(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("matches expects 2 arguments, got %d", len(args))
+	}
+	re, err := regexp.Compile(exprToString(args[1]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern for matches: %w", err)
+	}
+	return re.MatchString(exprToString(args[0])), nil
+}
+
+// exprSplit: split(value[, delimiter]) splits and trims a value (default delimiter ",")
+func exprSplit(args []interface{}) (interface{}, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("split expects 1 or 2 arguments, got %d", len(args))
+	}
+	delimiter := ","
+	if len(args) == 2 {
+		delimiter = exprToString(args[1])
+	}
+	return SplitString(exprToString(args[0]), delimiter), nil
+}
+
+// exprReplace: replace(value, old, new) replaces all occurrences of old with new
+func exprReplace(args []interface{}) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("replace expects 3 arguments, got %d", len(args))
+	}
+	return strings.ReplaceAll(exprToString(args[0]), exprToString(args[1]), exprToString(args[2])), nil
+}
+
+// exprNumber: number(value) converts a numeric string, e.g. a column, to a number
+func exprNumber(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("number expects 1 argument, got %d", len(args))
+	}
+	f, ok := exprToNumber(args[0])
+	if !ok {
+		return nil, fmt.Errorf("not a number: %q", exprToString(args[0]))
+	}
+	return f, nil
+}
+
+// exprTransform: transform("name", value) applies a registered transformation with no arguments
+func exprTransform(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("transform expects 2 arguments, got %d", len(args))
+	}
+	result, err := ApplyTransformation(exprToString(args[1]), exprToString(args[0]), nil)
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := result.(int); ok {
+		return float64(n), nil
+	}
+	if n, ok := result.(int64); ok {
+		return float64(n), nil
+	}
+	return result, nil
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	row := map[string]string{
+		"Zip Prefix": "01",
+		"Zip Suffix": "23",
+		"Port":       "9200",
+		"Offset":     "3",
+		"Status":     "active",
+		"Empty":      "",
+		"Roles":      "master, data",
+	}
+	tests := []struct {
+		name       string
+		expression string
+		want       interface{}
+	}{
+		// Precedence and parentheses
+		{"multiplication before addition", `1 + 2 * 3`, float64(7)},
+		{"division before subtraction", `10 - 6 / 2`, float64(7)},
+		{"left associative", `10 - 4 - 3`, float64(3)},
+		{"parentheses", `(1 + 2) * 3`, float64(9)},
+		{"nested parentheses", `((2))`, float64(2)},
+		{"comparison after arithmetic", `1 + 1 == 2`, true},
+		{"and before or", `false && false || true`, true},
+		{"or before ternary", `false || true ? "yes" : "no"`, "yes"},
+		{"nested ternary", `false ? "a" : true ? "b" : "c"`, "b"},
+
+		// Unary operators
+		{"unary minus", `-3 + 5`, float64(2)},
+		{"double unary minus", `--3`, float64(3)},
+		{"unary minus of parentheses", `-(1 + 2)`, float64(-3)},
+		{"unary minus of a numeric column", `-col("Offset")`, float64(-3)},
+		{"not", `!col("Empty")`, true},
+
+		// Strings and numbers
+		{"numbers add", `1 + 2`, float64(3)},
+		{"numeric string literals join", `"01" + "23"`, "0123"},
+		{"numeric columns join", `col("Zip Prefix") + col("Zip Suffix")`, "0123"},
+		{"string and number join", `"es-" + 1`, "es-1"},
+		{"column and number join", `col("Port") + 1`, "92001"},
+		{"number() adds columns", `number(col("Port")) + number(col("Offset"))`, float64(9203)},
+		{"numeric strings subtract", `col("Port") - col("Offset")`, float64(9197)},
+		{"numeric comparison of columns", `col("Port") > col("Offset")`, true},
+		{"string comparison", `"b" > "a"`, true},
+		{"string equality", `col("Status") == "active"`, true},
+		{"missing column is empty", `col("Missing") == ""`, true},
+		{"null", `null`, nil},
+
+		// Functions
+		{"function call", `upper(trim(" prd "))`, "PRD"},
+		{"split", `split(col("Roles"))`, []string{"master", "data"}},
+		{"matches", `matches(col("Status"), "^act")`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EvaluateExpression(tt.expression, row)
+			if err != nil {
+				t.Fatalf("EvaluateExpression(%s): %v", tt.expression, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EvaluateExpression(%s) = %#v, want %#v", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateExpressionErrors(t *testing.T) {
+	row := map[string]string{"Port": "9200", "Status": "active"}
+	tests := []struct {
+		name       string
+		expression string
+		wantErr    string
+	}{
+		{"division by zero", `1 / 0`, "division by zero"},
+		{"division by a zero column", `col("Port") / (col("Port") - 9200)`, "division by zero"},
+		{"subtraction of a string", `col("Status") - 1`, "cannot subtract"},
+		{"multiplication of a string", `"a" * 2`, "cannot multiply"},
+		{"negation of a string", `-col("Status")`, "cannot negate"},
+		{"number() of a string", `number(col("Status"))`, "not a number"},
+		{"unknown function", `nope(1)`, "unknown function"},
+		{"wrong argument count", `lower("a", "b")`, "expected 1 argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EvaluateExpression(tt.expression, row)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("EvaluateExpression(%s) = %v, %v, want error %q", tt.expression, got, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateExpressionParseErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantErr    string
+	}{
+		{``, "unexpected end of expression"},
+		{`1 +`, "unexpected end of expression"},
+		{`(1 + 2`, `expected ")"`},
+		{`1 + 2)`, `unexpected ")"`},
+		{`true ? 1`, `expected ":"`},
+		{`"unterminated`, "unterminated string"},
+		{`1 # 2`, "unexpected character"},
+		{`col()`, "col() takes exactly one argument"},
+		{`col("a", "b")`, "col() takes exactly one argument"},
+		{`status == 1`, `unknown identifier "status"`},
+		{`1.2.3`, "invalid number"},
+		{`lower("a",)`, "unexpected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			err := ValidateExpression(tt.expression)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateExpression(%s) = %v, want error %q", tt.expression, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,477 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TransformFunc transforms a CSV field value using function-specific arguments
+type TransformFunc func(value string, args interface{}) (interface{}, error)
+
+// Transform is a transformation bound to its arguments
+type Transform func(value string) (interface{}, error)
+
+// TransformBuilder checks and prepares the arguments of a transformation once (e.g. compiles
+// its pattern), so invalid arguments are reported when a job loads rather than for each row
+type TransformBuilder func(args interface{}) (Transform, error)
+
+var (
+	transformations   = make(map[string]TransformFunc)
+	transformBuilders = make(map[string]TransformBuilder) // functions whose arguments are prepared
+	transformationsMu sync.RWMutex
+
+	lookupTables   = make(map[string]*lookupTable) // map[filePath]*lookupTable
+	lookupTablesMu sync.Mutex
+)
+
+// lookupTable is a key/value table loaded from a file, reloaded when the file changes
+type lookupTable struct {
+	modTime time.Time
+	values  map[string]string
+}
+
+func init() {
+	RegisterTransformation("toLower", transformToLower)
+	RegisterTransformation("toUpper", transformToUpper)
+	RegisterTransformation("removeNonAlphaNumeric", transformRemoveNonAlphaNumeric)
+	RegisterTransformation("boolStringCompare", transformBoolStringCompare)
+	RegisterTransformation("strStringCompare", transformStrStringCompare)
+	RegisterTransformation("splitString", transformSplitString)
+	RegisterTransformation("parseInt", transformParseInt)
+	RegisterTransformation("parseBool", transformParseBool)
+	registerTransformBuilder("regexExtract", buildRegexExtract)
+	registerTransformBuilder("parseDate", buildParseDate)
+	registerTransformBuilder("rangeBucket", buildRangeBucket)
+	registerTransformBuilder("lookup", buildLookup)
+}
+
+// RegisterTransformation registers (or replaces) a named transformation function
+func RegisterTransformation(name string, fn TransformFunc) {
+	transformationsMu.Lock()
+	defer transformationsMu.Unlock()
+	transformations[name] = fn
+	delete(transformBuilders, name)
+}
+
+// registerTransformBuilder registers a transformation whose arguments are prepared by build
+func registerTransformBuilder(name string, build TransformBuilder) {
+	transformationsMu.Lock()
+	defer transformationsMu.Unlock()
+	transformations[name] = func(value string, args interface{}) (interface{}, error) {
+		transform, err := build(args)
+		if err != nil {
+			return value, err
+		}
+		return transform(value)
+	}
+	transformBuilders[name] = build
+}
+
+// RegisterTransformationAlias registers a named transformation that calls an already
+// registered function with fixed arguments. This lets job configuration define new
+// transformations (e.g. a particular regex or lookup file) without recompiling.
+func RegisterTransformationAlias(name, baseFunc string, baseArgs interface{}) error {
+	if name == "" {
+		return fmt.Errorf("transformation name is required")
+	}
+	if name == baseFunc {
+		return fmt.Errorf("transformation %s cannot alias itself", name)
+	}
+
+	transform, err := BuildTransformation(baseFunc, baseArgs)
+	if err != nil {
+		return err
+	}
+
+	transformationsMu.Lock()
+	defer transformationsMu.Unlock()
+	transformations[name] = func(value string, args interface{}) (interface{}, error) {
+		return transform(value)
+	}
+	delete(transformBuilders, name)
+	return nil
+}
+
+// BuildTransformation binds a registered transformation to its arguments, checking and
+// preparing them once
+func BuildTransformation(funcName string, args interface{}) (Transform, error) {
+	transformationsMu.RLock()
+	fn, exists := transformations[funcName]
+	build := transformBuilders[funcName]
+	transformationsMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown transformation function: %s", funcName)
+	}
+	if build != nil {
+		return build(args)
+	}
+	return func(value string) (interface{}, error) {
+		return fn(value, args)
+	}, nil
+}
+
+// TransformationNames returns the names of all registered transformations (sorted)
+func TransformationNames() []string {
+	transformationsMu.RLock()
+	defer transformationsMu.RUnlock()
+
+	names := make([]string, 0, len(transformations))
+	for name := range transformations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyTransformation applies a registered transformation function to a field value
+func ApplyTransformation(value string, funcName string, args interface{}) (interface{}, error) {
+	transformationsMu.RLock()
+	fn, exists := transformations[funcName]
+	transformationsMu.RUnlock()
+
+	if !exists {
+		return value, fmt.Errorf("unknown transformation function: %s", funcName)
+	}
+	return fn(value, args)
+}
+
+func transformToLower(value string, args interface{}) (interface{}, error) {
+	return ToLower(value), nil
+}
+
+func transformToUpper(value string, args interface{}) (interface{}, error) {
+	return ToUpper(value), nil
+}
+
+func transformRemoveNonAlphaNumeric(value string, args interface{}) (interface{}, error) {
+	return RemoveNonAlphaNumeric(value), nil
+}
+
+func transformBoolStringCompare(value string, args interface{}) (interface{}, error) {
+	compareList, ok := args.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("invalid args for boolStringCompare")
+	}
+	return BoolStringCompare(value, toStringList(compareList)), nil
+}
+
+// transformStrStringCompare expects a map with "arg" and "retVal" keys
+func transformStrStringCompare(value string, args interface{}) (interface{}, error) {
+	argsMap, ok := args.(map[string]interface{})
+	if !ok {
+		return value, fmt.Errorf("invalid args for strStringCompare")
+	}
+
+	argList, ok := argsMap["arg"].([]interface{})
+	if !ok {
+		return value, fmt.Errorf("invalid arg list for strStringCompare")
+	}
+
+	retValList, ok := argsMap["retVal"].([]interface{})
+	if !ok {
+		return value, fmt.Errorf("invalid retVal list for strStringCompare")
+	}
+
+	// Convert []interface{} to [][]string
+	compareList := make([][]string, 0, len(argList))
+	for _, item := range argList {
+		subList, ok := item.([]interface{})
+		if !ok {
+			continue
+		}
+		compareList = append(compareList, toStringList(subList))
+	}
+
+	return StrStringCompare(value, compareList, toStringList(retValList)), nil
+}
+
+func transformSplitString(value string, args interface{}) (interface{}, error) {
+	delimiter := ","
+	if args != nil {
+		if delim, ok := args.(string); ok {
+			delimiter = delim
+		}
+	}
+	return SplitString(value, delimiter), nil
+}
+
+func transformParseInt(value string, args interface{}) (interface{}, error) {
+	val, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	return val, nil
+}
+
+func transformParseBool(value string, args interface{}) (interface{}, error) {
+	return BoolStringCompare(value, []string{"true", "yes", "1", "on"}), nil
+}
+
+// buildRegexExtract returns a capture group of a regular expression match.
+// args is either the pattern string (first group, or whole match when there are no groups)
+// or a map with "pattern", optional "group" and optional "default".
+func buildRegexExtract(args interface{}) (Transform, error) {
+	pattern := ""
+	group := -1
+	defaultVal := ""
+
+	switch a := args.(type) {
+	case string:
+		pattern = a
+	case map[string]interface{}:
+		pattern, _ = a["pattern"].(string)
+		if g, ok := toInt(a["group"]); ok {
+			group = g
+		}
+		defaultVal, _ = a["default"].(string)
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("invalid args for regexExtract: pattern is required")
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexExtract pattern: %w", err)
+	}
+	if group < 0 {
+		group = 0
+		if re.NumSubexp() > 0 {
+			group = 1
+		}
+	}
+	if group > re.NumSubexp() {
+		return nil, fmt.Errorf("regexExtract group %d out of range", group)
+	}
+
+	return func(value string) (interface{}, error) {
+		matches := re.FindStringSubmatch(value)
+		if matches == nil {
+			return defaultVal, nil
+		}
+		return matches[group], nil
+	}, nil
+}
+
+// buildParseDate parses a date using a Go time layout (default RFC3339).
+// args is the layout string or a map with "layout", optional "timezone" and optional
+// "outputLayout"; without outputLayout the result is epoch milliseconds.
+func buildParseDate(args interface{}) (Transform, error) {
+	layout := time.RFC3339
+	outputLayout := ""
+	location := time.UTC
+
+	switch a := args.(type) {
+	case string:
+		if a != "" {
+			layout = a
+		}
+	case map[string]interface{}:
+		if l, ok := a["layout"].(string); ok && l != "" {
+			layout = l
+		}
+		outputLayout, _ = a["outputLayout"].(string)
+		if tz, ok := a["timezone"].(string); ok && tz != "" {
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timezone for parseDate: %w", err)
+			}
+			location = loc
+		}
+	}
+
+	return func(value string) (interface{}, error) {
+		t, err := time.ParseInLocation(layout, strings.TrimSpace(value), location)
+		if err != nil {
+			return value, fmt.Errorf("failed to parse date %q: %w", value, err)
+		}
+		if outputLayout != "" {
+			return t.Format(outputLayout), nil
+		}
+		return t.UnixMilli(), nil
+	}, nil
+}
+
+// buildRangeBucket maps a number to a label using ascending upper bounds.
+// args is a map with "bounds" (n numbers) and "labels" (n+1 labels, last one for
+// values above the highest bound). A value belongs to the first bucket whose bound it
+// does not exceed.
+func buildRangeBucket(args interface{}) (Transform, error) {
+	argsMap, ok := args.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid args for rangeBucket")
+	}
+
+	boundList, _ := argsMap["bounds"].([]interface{})
+	labels := toStringList(argsMap["labels"])
+	if len(boundList) == 0 || len(labels) != len(boundList)+1 {
+		return nil, fmt.Errorf("rangeBucket requires n bounds and n+1 labels")
+	}
+	bounds := make([]float64, len(boundList))
+	for i, item := range boundList {
+		bound, ok := toFloat(item)
+		if !ok {
+			return nil, fmt.Errorf("rangeBucket bound %v is not numeric", item)
+		}
+		if i > 0 && bound < bounds[i-1] {
+			return nil, fmt.Errorf("rangeBucket bounds must be ascending")
+		}
+		bounds[i] = bound
+	}
+
+	return func(value string) (interface{}, error) {
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return value, fmt.Errorf("rangeBucket value is not numeric: %s", value)
+		}
+		for i, bound := range bounds {
+			if number <= bound {
+				return labels[i], nil
+			}
+		}
+		return labels[len(labels)-1], nil
+	}, nil
+}
+
+// buildLookup maps a value through a key/value table loaded from a file.
+// args is the file path or a map with "file", optional "default" and optional
+// "caseInsensitive". CSV files use the first two columns (with a header row);
+// YAML and JSON files hold a flat map. The file is read when a value is looked up, and
+// again once it changes.
+func buildLookup(args interface{}) (Transform, error) {
+	filePath := ""
+	defaultVal := ""
+	hasDefault := false
+	caseInsensitive := false
+
+	switch a := args.(type) {
+	case string:
+		filePath = a
+	case map[string]interface{}:
+		filePath, _ = a["file"].(string)
+		defaultVal, hasDefault = a["default"].(string)
+		caseInsensitive, _ = a["caseInsensitive"].(bool)
+	}
+	if filePath == "" {
+		return nil, fmt.Errorf("invalid args for lookup: file is required")
+	}
+
+	return func(value string) (interface{}, error) {
+		table, err := loadLookupTable(filePath)
+		if err != nil {
+			return value, err
+		}
+
+		key := strings.TrimSpace(value)
+		if result, ok := table[key]; ok {
+			return result, nil
+		}
+		if caseInsensitive {
+			for k, v := range table {
+				if strings.EqualFold(k, key) {
+					return v, nil
+				}
+			}
+		}
+		if hasDefault {
+			return defaultVal, nil
+		}
+		return value, nil
+	}, nil
+}
+
+// loadLookupTable returns the table for a file, re-reading it only when it has changed
+func loadLookupTable(filePath string) (map[string]string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat lookup file: %w", err)
+	}
+
+	lookupTablesMu.Lock()
+	defer lookupTablesMu.Unlock()
+
+	if cached, ok := lookupTables[filePath]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.values, nil
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lookup file: %w", err)
+	}
+
+	values := make(map[string]string)
+	switch filepath.Ext(filePath) {
+	case ".csv":
+		reader := csv.NewReader(strings.NewReader(string(data)))
+		reader.FieldsPerRecord = -1 // Short rows are skipped below
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lookup CSV: %w", err)
+		}
+		for i, record := range records {
+			if i == 0 || len(record) < 2 {
+				continue // Skip header and short rows
+			}
+			values[strings.TrimSpace(record[0])] = strings.TrimSpace(record[1])
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse lookup YAML: %w", err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse lookup JSON: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported lookup file format: %s", filepath.Ext(filePath))
+	}
+
+	lookupTables[filePath] = &lookupTable{modTime: info.ModTime(), values: values}
+	return values, nil
+}
+
+// toStringList converts a []interface{} of strings to []string, skipping other types
+func toStringList(v interface{}) []string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return []string{}
+	}
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		if str, ok := item.(string); ok {
+			result = append(result, str)
+		}
+	}
+	return result
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
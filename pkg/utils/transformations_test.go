@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// transformCase is a value run through a transformation bound to args, with either the
+// result or the error it must give
+type transformCase struct {
+	name    string
+	args    interface{}
+	value   string
+	want    interface{}
+	wantErr string
+}
+
+// runTransformCases builds funcName with the args of each case and applies it to the value
+func runTransformCases(t *testing.T, funcName string, tests []transformCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transform, err := BuildTransformation(funcName, tt.args)
+			if err == nil {
+				var got interface{}
+				got, err = transform(tt.value)
+				if tt.wantErr == "" && !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("%s(%q) = %#v, want %#v", funcName, tt.value, got, tt.want)
+				}
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("%s(%q): %v", funcName, tt.value, err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("%s(%q) error = %v, want %q", funcName, tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegexExtract(t *testing.T) {
+	runTransformCases(t, "regexExtract", []transformCase{
+		{name: "first group", args: `^[a-z]+-([a-z0-9]+)-`, value: "es-dc1-01", want: "dc1"},
+		{name: "whole match without groups", args: `[0-9]+`, value: "node-42", want: "42"},
+		{name: "named group", args: map[string]interface{}{"pattern": `^(\w+)-(\w+)$`, "group": 2}, value: "es-dc1", want: "dc1"},
+		{name: "group 0 of a pattern with groups", args: map[string]interface{}{"pattern": `(\w+)-\d+`, "group": float64(0)}, value: "es-01", want: "es-01"},
+		{name: "no match is empty", args: `^prd-(\w+)`, value: "dev-01", want: ""},
+		{name: "no match gives the default", args: map[string]interface{}{"pattern": `^prd-(\w+)`, "default": "unknown"}, value: "dev-01", want: "unknown"},
+		{name: "invalid pattern", args: `([a-z`, wantErr: "invalid regexExtract pattern"},
+		{name: "group out of range", args: map[string]interface{}{"pattern": `(\w+)`, "group": 2}, wantErr: "group 2 out of range"},
+		{name: "missing pattern", args: map[string]interface{}{"group": 1}, wantErr: "pattern is required"},
+		{name: "no args", args: nil, wantErr: "pattern is required"},
+	})
+}
+
+func TestParseDate(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	runTransformCases(t, "parseDate", []transformCase{
+		{name: "RFC3339 by default", args: nil, value: "2024-01-02T03:04:05Z", want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).UnixMilli()},
+		{name: "surrounding spaces", args: "2006-01-02", value: " 2024-01-02 ", want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC).UnixMilli()},
+		{name: "layout and timezone", args: map[string]interface{}{"layout": "2006-01-02 15:04", "timezone": "America/New_York"}, value: "2024-07-01 09:30",
+			want: time.Date(2024, 7, 1, 9, 30, 0, 0, newYork).UnixMilli()},
+		{name: "output layout", args: map[string]interface{}{"layout": "02/01/2006", "outputLayout": "2006-01-02"}, value: "31/12/2023", want: "2023-12-31"},
+		{name: "bad date", args: "2006-01-02", value: "2024-13-45", wantErr: "failed to parse date"},
+		{name: "empty date", args: nil, value: "", wantErr: "failed to parse date"},
+		{name: "bad timezone", args: map[string]interface{}{"timezone": "Mars/Olympus"}, wantErr: "invalid timezone"},
+	})
+}
+
+func TestRangeBucket(t *testing.T) {
+	sizes := map[string]interface{}{
+		"bounds": []interface{}{10, 100.5},
+		"labels": []interface{}{"small", "medium", "large"},
+	}
+	runTransformCases(t, "rangeBucket", []transformCase{
+		{name: "below the lowest bound", args: sizes, value: "-3", want: "small"},
+		{name: "on a bound", args: sizes, value: "10", want: "small"},
+		{name: "between bounds", args: sizes, value: " 10.1 ", want: "medium"},
+		{name: "above the highest bound", args: sizes, value: "1000", want: "large"},
+		{name: "non-numeric value", args: sizes, value: "ten", wantErr: "not numeric"},
+		{name: "one label too few", args: map[string]interface{}{"bounds": []interface{}{10, 100}, "labels": []interface{}{"small", "large"}}, wantErr: "n bounds and n+1 labels"},
+		{name: "no bounds", args: map[string]interface{}{"labels": []interface{}{"all"}}, wantErr: "n bounds and n+1 labels"},
+		{name: "non-numeric bound", args: map[string]interface{}{"bounds": []interface{}{"ten"}, "labels": []interface{}{"small", "large"}}, wantErr: "bound ten is not numeric"},
+		{name: "descending bounds", args: map[string]interface{}{"bounds": []interface{}{100, 10}, "labels": []interface{}{"a", "b", "c"}}, wantErr: "ascending"},
+		{name: "not a map", args: "10,100", wantErr: "invalid args for rangeBucket"},
+	})
+}
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	csvFile := writeFile("owners.csv", "cluster,owner\nprd-01, payments \nshort\n")
+	yamlFile := writeFile("owners.yaml", "prd-01: payments\n")
+	jsonFile := writeFile("owners.json", `{"prd-01": "payments"}`)
+	badFile := writeFile("owners.txt", "prd-01=payments\n")
+
+	runTransformCases(t, "lookup", []transformCase{
+		{name: "CSV", args: csvFile, value: "prd-01", want: "payments"},
+		{name: "YAML", args: yamlFile, value: " prd-01 ", want: "payments"},
+		{name: "JSON", args: map[string]interface{}{"file": jsonFile}, value: "prd-01", want: "payments"},
+		{name: "unknown key keeps the value", args: csvFile, value: "dev-01", want: "dev-01"},
+		{name: "unknown key gives the default", args: map[string]interface{}{"file": csvFile, "default": "unassigned"}, value: "dev-01", want: "unassigned"},
+		{name: "empty default", args: map[string]interface{}{"file": csvFile, "default": ""}, value: "dev-01", want: ""},
+		{name: "case-sensitive by default", args: csvFile, value: "PRD-01", want: "PRD-01"},
+		{name: "case-insensitive", args: map[string]interface{}{"file": csvFile, "caseInsensitive": true}, value: "PRD-01", want: "payments"},
+		{name: "missing file", args: filepath.Join(dir, "missing.csv"), value: "prd-01", wantErr: "failed to stat lookup file"},
+		{name: "unsupported format", args: badFile, value: "prd-01", wantErr: "unsupported lookup file format"},
+		{name: "no file", args: map[string]interface{}{"default": "unassigned"}, wantErr: "file is required"},
+	})
+}
+
+func TestBuildTransformationErrorsAtLoad(t *testing.T) {
+	if _, err := BuildTransformation("nope", nil); err == nil || !strings.Contains(err.Error(), "unknown transformation function: nope") {
+		t.Errorf("BuildTransformation of an unknown function = %v", err)
+	}
+	// A simple transformation is bound to its args as is
+	transform, err := BuildTransformation("splitString", "|")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := transform("a|b"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("splitString(a|b) = %#v", got)
+	}
+
+	if err := RegisterTransformationAlias("testBadPattern", "regexExtract", `([a-z`); err == nil || !strings.Contains(err.Error(), "invalid regexExtract pattern") {
+		t.Errorf("RegisterTransformationAlias with an invalid pattern = %v, want the pattern error", err)
+	}
+	if _, err := BuildTransformation("testBadPattern", nil); err == nil {
+		t.Error("an alias that failed to register was registered")
+	}
+
+	if err := RegisterTransformationAlias("testDC", "regexExtract", map[string]interface{}{"pattern": `^[a-z]+-([a-z0-9]+)-`}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		transformationsMu.Lock()
+		delete(transformations, "testDC")
+		transformationsMu.Unlock()
+	})
+	if got, err := ApplyTransformation("es-dc2-01", "testDC", nil); err != nil || got != "dc2" {
+		t.Errorf("testDC(es-dc2-01) = %v, %v, want dc2", got, err)
+	}
+}
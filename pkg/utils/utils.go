@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -247,11 +248,9 @@ func (realTime) UnixNano() int64 {
 
 // GetCurrentMasterForCluster retrieves the current master node name for a given cluster
 // Returns the hostname of the master node, or empty string if unable to determine
-func GetCurrentMasterForCluster(clusterName string) string {
+func GetCurrentMasterForCluster(ctx context.Context, clusterName string) string {
 	// Get cluster data
-	types.ClustersMu.RLock()
-	cluster, exists := types.AllClusters[clusterName]
-	types.ClustersMu.RUnlock()
+	cluster, exists := types.GetCluster(clusterName)
 
 	if !exists {
 		return ""
@@ -281,7 +280,7 @@ func GetCurrentMasterForCluster(clusterName string) string {
 	}
 
 	// Create request
-	req, err := http.NewRequest("GET", endpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return ""
 	}
@@ -322,22 +321,27 @@ func GetCurrentMasterForCluster(clusterName string) string {
 
 // GetCurrentMasterEndpointForCluster retrieves the API endpoint for the current master node
 // Returns the endpoint URL (https://hostname:9200/), or empty string if unable to determine
-func GetCurrentMasterEndpointForCluster(clusterName string) string {
-	currentMaster := GetCurrentMasterForCluster(clusterName)
+func GetCurrentMasterEndpointForCluster(ctx context.Context, clusterName string) string {
+	currentMaster := GetCurrentMasterForCluster(ctx, clusterName)
 	if currentMaster == "" {
 		return ""
 	}
 
 	// Get cluster data for port information
-	types.ClustersMu.RLock()
-	cluster, exists := types.AllClusters[clusterName]
-	types.ClustersMu.RUnlock()
+	cluster, exists := types.GetCluster(clusterName)
 
 	port := "9200"
 	if exists && cluster.ClusterPort != "" {
 		port = cluster.ClusterPort
 	}
 
+	// Prefer the node-specific port when the master is a known node
+	if exists {
+		if node := cluster.GetNode(currentMaster); node != nil && node.Port != "" {
+			port = node.Port
+		}
+	}
+
 	return fmt.Sprintf("https://%s:%s/", currentMaster, port)
 }
 